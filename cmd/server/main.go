@@ -15,9 +15,13 @@ import (
 	"yuyu-test/internal/api/handlers"
 	"yuyu-test/internal/api/middleware"
 	"yuyu-test/internal/auth"
+	"yuyu-test/internal/breach"
 	"yuyu-test/internal/common"
 	"yuyu-test/internal/config"
 	"yuyu-test/internal/internal_service"
+	"yuyu-test/internal/lockout"
+	"yuyu-test/internal/mailer"
+	"yuyu-test/internal/revocation"
 	"yuyu-test/internal/store/database"
 	"yuyu-test/internal/tenant"
 	"yuyu-test/internal/user"
@@ -143,6 +147,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 加入轮换前的旧密钥，旧密钥签发的令牌在过期前仍可通过校验
+	if err := addPreviousKeys(userSigner, cfg.JWTAlgorithm, cfg.JWTUserPreviousKeys); err != nil {
+		slog.Error("Failed to load previous user JWT keys", "error", err)
+		os.Exit(1)
+	}
+	if err := addPreviousKeys(internalServiceSigner, cfg.JWTAlgorithm, cfg.JWTServicePreviousKeys); err != nil {
+		slog.Error("Failed to load previous service JWT keys", "error", err)
+		os.Exit(1)
+	}
+
 	// 初始化数据库连接
 	sqlDB, err := sql.Open("postgres", cfg.DatabaseURL)
 	if err != nil {
@@ -152,21 +166,89 @@ func main() {
 	defer sqlDB.Close()
 	queries := database.New(sqlDB)
 
+	// 初始化邮件发送器，未配置SMTP时只写日志
+	var mailSender mailer.Mailer
+	if cfg.SMTPHost != "" {
+		mailSender = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	} else {
+		slog.Warn("SMTP_HOST not configured, emails will only be logged")
+		mailSender = mailer.NewLogMailer(logger)
+	}
+
+	// 初始化访问令牌吊销表，记录保留时长需覆盖内部接口签发的24小时令牌
+	revocations := revocation.NewStore(queries, 24*time.Hour)
+	if err := revocations.Sync(context.Background()); err != nil {
+		slog.Error("Failed to load token revocations", "error", err)
+		os.Exit(1)
+	}
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go revocations.Run(backgroundCtx, 10*time.Second)
+
+	// 初始化登录失败计数与锁定
+	lockoutDuration := time.Duration(cfg.LockoutDuration) * time.Second
+	guard := lockout.NewGuard(queries, map[string]lockout.Policy{
+		lockout.ScopeAccount: {Threshold: cfg.LockoutAccountThreshold, LockoutDuration: lockoutDuration, BaseDelay: time.Second},
+		lockout.ScopeIP:      {Threshold: cfg.LockoutIPThreshold, LockoutDuration: lockoutDuration, BaseDelay: time.Second},
+		lockout.ScopeClient:  {Threshold: cfg.LockoutClientThreshold, LockoutDuration: lockoutDuration, BaseDelay: time.Second},
+		// 单个MFA挑战的验证码错误上限，与账号阈值无关，始终生效
+		lockout.ScopeMFAChallenge: user.MFAChallengePolicy,
+	})
+	go guard.Run(backgroundCtx, time.Minute)
+
+	// 初始化密码哈希器，用户密码、租户私钥和内部客户端密钥共用
+	hasherConfig := auth.DefaultHasherConfig()
+	hasherConfig.Algorithm = cfg.PasswordHashAlgorithm
+	hasherConfig.Argon2id.Memory = uint32(cfg.Argon2Memory)
+	hasherConfig.Argon2id.Iterations = uint32(cfg.Argon2Iterations)
+	hasherConfig.Argon2id.Parallelism = uint8(cfg.Argon2Parallelism)
+	hasherConfig.BcryptCost = cfg.BcryptCost
+	hasher, err := auth.NewPasswordHasher(hasherConfig)
+	if err != nil {
+		slog.Error("Invalid password hash configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// 加载泄露密码库，未配置时不检查
+	var breached breach.Checker
+	if cfg.BreachedPasswordsDir != "" {
+		corpus, err := breach.Open(cfg.BreachedPasswordsDir)
+		if err != nil {
+			slog.Error("Failed to open breached password corpus", "error", err)
+			os.Exit(1)
+		}
+		breached = corpus
+	} else {
+		slog.Warn("BREACHED_PASSWORDS_DIR not configured, passwords will not be screened")
+	}
+
 	// 初始化服务
-	tenantService := tenant.NewService(queries)
-	userService := user.NewService(queries, userSigner)
+	tenantService := tenant.NewService(queries, hasher)
+	userService := user.NewService(queries, userSigner, mailSender, revocations, guard, breached, hasher, []byte(cfg.PasskeyDecoySecret))
+	go userService.RunImports(backgroundCtx, 5*time.Second)
+	go userService.RunErasures(backgroundCtx, time.Hour)
+	go userService.RunTokenCleanup(backgroundCtx, time.Hour)
+	// id_token 须能由依赖方通过 JWKS 校验，对称密钥下不启用 OpenID Provider
+	var oidcHandler *handlers.OIDCHandler
+	if oidcProvider, err := user.NewOIDCProvider(userService, cfg.PublicURL); err != nil {
+		slog.Warn("OpenID Connect provider disabled", "error", err, "alg", cfg.JWTAlgorithm)
+	} else {
+		go oidcProvider.RunCleanup(backgroundCtx, time.Hour)
+		oidcHandler = handlers.NewOIDCHandler(oidcProvider)
+	}
 
 	// 初始化中间件
-	authMiddleware := middleware.NewAuthMiddleware(tenantService, userSigner)
+	authMiddleware := middleware.NewAuthMiddleware(tenantService, userSigner, revocations, userService)
 
 	// 初始化对内服务管理服务和相关组件
-	internalService := internal_service.NewService(queries, internalServiceSigner, logger, time.Duration(cfg.ServiceTokenExpiration)*time.Second)
+	internalService := internal_service.NewService(queries, internalServiceSigner, logger, time.Duration(cfg.ServiceTokenExpiration)*time.Second, guard, hasher)
 	internalServiceHandler := handlers.NewInternalServiceHandler(internalService, logger)
 	internalAuthMiddleware := middleware.NewInternalAuthMiddleware(internalService, logger)
 
 	// 初始化认证处理器，传递多算法参数
 	authHandler := handlers.NewAuthHandler(userService, userSigner)
-	internalAuthHandler := handlers.NewInternalAuthHandler(queries, internalServiceSigner)
+	jwksHandler := handlers.NewJWKSHandler(userSigner, internalServiceSigner)
+	internalAuthHandler := handlers.NewInternalAuthHandler(queries, internalServiceSigner, guard, hasher, userService, oidcHandler)
 
 	// 初始化路由
 	router := api.NewRouter(
@@ -175,6 +257,8 @@ func main() {
 		authMiddleware,
 		authHandler,
 		internalAuthHandler,
+		oidcHandler,
+		jwksHandler,
 		internalServiceHandler,
 		internalAuthMiddleware,
 		sqlDB,
@@ -213,3 +297,30 @@ func main() {
 
 	slog.Info("Server exited")
 }
+
+// addPreviousKeys 解析轮换前的旧密钥并加入签名器的验证密钥
+func addPreviousKeys(signer auth.JWTSigner, algorithm string, keys []string) error {
+	for _, value := range keys {
+		var key interface{} = value
+		if algorithm != "HS256" {
+			pemKey, err := config.LoadPEMKey(value)
+			if err != nil {
+				return err
+			}
+			if algorithm == "RS256" {
+				key, err = common.ParseRSAPublicKeyFromPEM([]byte(pemKey))
+			} else {
+				key, err = common.ParseECPublicKeyFromPEM([]byte(pemKey))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		kid, err := signer.AddVerificationKey(key)
+		if err != nil {
+			return err
+		}
+		slog.Info("Added previous JWT verification key", "kid", kid)
+	}
+	return nil
+}
@@ -1,15 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"time"
 
 	"yuyu-test/internal/auth"
 	"yuyu-test/internal/store/database"
 	"yuyu-test/internal/user"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthHandler 认证处理器
@@ -44,6 +43,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	tenant := tenantInterface.(*database.Tenant)
 	response, err := h.userService.Register(c.Request.Context(), tenant.ID, req)
 	if err != nil {
+		if writePasswordPolicyError(c, err) || writeProfileError(c, err) {
+			return
+		}
+		if errors.Is(err, user.ErrSignupNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -67,8 +73,21 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	tenant := tenantInterface.(*database.Tenant)
-	response, err := h.userService.Login(c.Request.Context(), tenant.ID, req)
+	response, err := h.userService.Login(c.Request.Context(), tenant.ID, req, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
+		if errors.Is(err, user.ErrEmailNotVerified) || errors.Is(err, user.ErrPasswordResetRequired) || errors.Is(err, user.ErrPasswordExpired) ||
+			errors.Is(err, user.ErrUserDisabled) || errors.Is(err, user.ErrUserLocked) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, user.ErrSessionLimitReached) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if setRetryAfter(c, err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -76,38 +95,279 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GenerateToken 生成用户JWT（内部API，需要auth:token权限）
-func (h *AuthHandler) GenerateToken(c *gin.Context) {
-	var req struct {
-		UserID string `json:"user_id" binding:"required"`
-	}
+// VerifyEmail 校验邮箱验证令牌
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req user.VerifyEmailRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+
 	// 从中间件获取租户信息
 	tenantInterface, exists := c.Get("tenant")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
 		return
 	}
+
 	tenant := tenantInterface.(*database.Tenant)
-	// 这里只做简单演示，实际应校验用户、权限等
-	claims := auth.Claims{
-		UserID:   req.UserID,
-		TenantID: tenant.ID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-	token, err := h.signer.Sign(&claims)
+	response, err := h.userService.VerifyEmail(c.Request.Context(), tenant.ID, req)
 	if err != nil {
+		if errors.Is(err, user.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResendVerificationEmail 重新发送邮箱验证邮件
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	var req user.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	if err := h.userService.ResendVerificationEmail(c.Request.Context(), tenant.ID, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 无论账号是否存在都返回相同响应
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists and is not verified, a verification email has been sent"})
+}
+
+// ForgotPassword 发送密码重置邮件
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req user.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	if err := h.userService.ForgotPassword(c.Request.Context(), tenant.ID, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 无论账号是否存在都返回相同响应
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a password reset email has been sent"})
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req user.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	if err := h.userService.ResetPassword(c.Request.Context(), tenant.ID, req); err != nil {
+		if writePasswordPolicyError(c, err) {
+			return
+		}
+		if errors.Is(err, user.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"token": token})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// VerifyMFA 登录第二步：校验挑战令牌和验证码后签发令牌
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req user.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	response, err := h.userService.VerifyMFA(c.Request.Context(), tenant.ID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnrollMFA 租户强制MFA时，未绑定用户凭挑战令牌开始绑定TOTP
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	var req user.MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	response, err := h.userService.EnrollMFAWithChallenge(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// BeginPasskeyLogin 开始Passkey登录，返回 navigator.credentials.get() 选项
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	var req user.PasskeyLoginBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	response, err := h.userService.BeginPasskeyLogin(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// FinishPasskeyLogin 校验Passkey断言并签发令牌
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var req user.PasskeyLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	response, err := h.userService.FinishPasskeyLogin(c.Request.Context(), tenant.ID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// StartPasswordless 发送免密码登录的魔法链接或验证码
+func (h *AuthHandler) StartPasswordless(c *gin.Context) {
+	var req user.PasswordlessStartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	if err := h.userService.StartPasswordless(c.Request.Context(), tenant.ID, req); err != nil {
+		switch {
+		case errors.Is(err, user.ErrPasswordlessDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// 无论账号是否存在都返回相同响应
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a sign-in email has been sent"})
+}
+
+// VerifyPasswordless 校验魔法链接或验证码并签发令牌
+func (h *AuthHandler) VerifyPasswordless(c *gin.Context) {
+	var req user.PasswordlessVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	response, err := h.userService.VerifyPasswordless(c.Request.Context(), tenant.ID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrPasswordlessDisabled), errors.Is(err, user.ErrPasswordResetRequired),
+			errors.Is(err, user.ErrUserDisabled), errors.Is(err, user.ErrUserLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrInvalidPasswordlessToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrSessionLimitReached):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // ValidateToken 校验JWT（内部API，需auth:token权限）
@@ -129,6 +389,24 @@ func (h *AuthHandler) ValidateToken(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"claims": claims})
 }
 
+// Logout 退出登录：当前访问令牌立即失效，所属会话的refresh token一并吊销
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+	claims, _ := c.Get("claims")
+
+	if err := h.userService.Logout(c.Request.Context(), tenantID.(string), userID.(string), claims.(*auth.Claims)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
 // RefreshToken 刷新access_token
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	type RefreshRequest struct {
@@ -139,16 +417,26 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// 解析refresh_token，查库校验
-	userID, err := h.userService.VerifyAndRefreshToken(c.Request.Context(), req.RefreshToken, c.ClientIP(), c.Request.UserAgent())
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
 		return
 	}
-	// 生成新access_token和refresh_token
-	resp, err := h.userService.IssueNewTokens(c.Request.Context(), userID, c.ClientIP(), c.Request.UserAgent())
+
+	tenant := tenantInterface.(*database.Tenant)
+
+	// 轮换refresh_token，令牌只能在签发它的租户下使用
+	resp, err := h.userService.RefreshTokens(c.Request.Context(), tenant.ID, req.RefreshToken, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, user.ErrInvalidRefreshToken) || errors.Is(err, user.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, user.ErrUserDisabled) || errors.Is(err, user.ErrUserLocked) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
 		return
 	}
 	c.JSON(http.StatusOK, resp)
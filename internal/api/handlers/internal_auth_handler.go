@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
+	"yuyu-test/internal/internal_service"
+	"yuyu-test/internal/lockout"
 	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/user"
 
 	"encoding/base64"
 
@@ -13,61 +19,62 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // InternalAuthHandler 对内服务认证处理器
-// 只实现/oauth/token端点
+// 实现/oauth/token（client_credentials、令牌交换）和/oauth/introspect端点
 
 type InternalAuthHandler struct {
-	db     database.Querier
-	signer authpkg.JWTSigner
+	db          database.Querier
+	signer      authpkg.JWTSigner
+	guard       *lockout.Guard
+	hasher      *authpkg.PasswordHasher
+	userService *user.Service
+	// oidc 处理 OIDC 客户端的 authorization_code 和 refresh_token 授权，未启用 OIDC 时为nil
+	oidc *OIDCHandler
 }
 
-func NewInternalAuthHandler(db database.Querier, signer authpkg.JWTSigner) *InternalAuthHandler {
+func NewInternalAuthHandler(db database.Querier, signer authpkg.JWTSigner, guard *lockout.Guard, hasher *authpkg.PasswordHasher, userService *user.Service, oidc *OIDCHandler) *InternalAuthHandler {
 	return &InternalAuthHandler{
-		db:     db,
-		signer: signer,
+		db:          db,
+		signer:      signer,
+		guard:       guard,
+		hasher:      hasher,
+		userService: userService,
+		oidc:        oidc,
 	}
 }
 
 // POST /oauth/token
 // Basic Auth: client_id/client_secret
-// grant_type=client_credentials
+// grant_type=client_credentials 或 urn:ietf:params:oauth:grant-type:token-exchange；
+// authorization_code 和 refresh_token 由 OIDC 客户端使用，见 OIDCHandler.Token
 func (h *InternalAuthHandler) Token(c *gin.Context) {
-	auth := c.GetHeader("Authorization")
-	if !strings.HasPrefix(auth, "Basic ") {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Basic authorization required"})
-		return
-	}
-	// 解码Basic Auth
-	payload, err := decodeBasicAuth(auth)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid basic auth"})
+	switch c.PostForm("grant_type") {
+	case user.GrantTypeAuthorizationCode, user.GrantTypeRefreshToken:
+		if h.oidc == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type", "error_description": "OpenID Connect is not enabled"})
+			return
+		}
+		h.oidc.Token(c)
 		return
 	}
-	clientID, clientSecret, ok := payload[0], payload[1], len(payload) == 2
+	clientID, ok := h.authenticateClient(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid basic auth format"})
 		return
 	}
-	// 查找client
-	client, err := h.db.GetInternalClientByID(c.Request.Context(), clientID)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Client not found"})
-		return
-	}
-	// 校验secret
-	if bcrypt.CompareHashAndPassword([]byte(client.ClientSecretHash), []byte(clientSecret)) != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid client secret"})
-		return
-	}
-	// grant_type
-	grantType := c.PostForm("grant_type")
-	if grantType != "client_credentials" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "grant_type must be client_credentials"})
-		return
+	switch c.PostForm("grant_type") {
+	case "client_credentials":
+		h.clientCredentials(c, clientID)
+	case internal_service.GrantTypeTokenExchange:
+		h.tokenExchange(c, clientID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grant_type must be client_credentials, " + internal_service.GrantTypeTokenExchange + ", " + user.GrantTypeAuthorizationCode + " or " + user.GrantTypeRefreshToken})
 	}
+}
+
+// clientCredentials 为客户端自身签发服务令牌，携带客户端的全部scope
+func (h *InternalAuthHandler) clientCredentials(c *gin.Context, clientID string) {
 	// 查询scope
 	scopes, err := h.db.GetClientScopes(c.Request.Context(), clientID)
 	if err != nil {
@@ -86,13 +93,23 @@ func (h *InternalAuthHandler) Token(c *gin.Context) {
 		"scope": strings.Join(scopeNames, " "),
 		"exp":   now.Add(time.Second * time.Duration(expiresIn)).Unix(),
 		"iat":   now.Unix(),
-		"iss":   "https://auth.yoursaas.com",
+		"iss":   internal_service.OAuthIssuer,
 	}
 	tokenStr, err := h.signer.Sign(claims)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
 		return
 	}
+	// 登记令牌，使其可以被撤销和内省
+	if err := h.db.StoreServiceToken(c.Request.Context(), database.StoreServiceTokenParams{
+		ClientID:  clientID,
+		TokenHash: internal_service.HashToken(tokenStr),
+		Scopes:    scopeNames,
+		ExpiresAt: now.Add(time.Second * time.Duration(expiresIn)),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store token"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"access_token": tokenStr,
 		"token_type":   "Bearer",
@@ -100,6 +117,165 @@ func (h *InternalAuthHandler) Token(c *gin.Context) {
 	})
 }
 
+// tokenExchange 令牌交换（RFC 8693）：以用户访问令牌或服务令牌为主体，为 audience 指定的目标服务
+// 签发收窄权限的令牌，act 声明记录发起交换的客户端。允许的受众、主体类型和权限由客户端的交换策略决定
+// subject_token=<令牌>&subject_token_type=urn:ietf:params:oauth:token-type:access_token&audience=<目标服务>&scope=<可选>
+func (h *InternalAuthHandler) tokenExchange(c *gin.Context, clientID string) {
+	subjectToken, subjectType := c.PostForm("subject_token"), c.PostForm("subject_token_type")
+	audience := c.PostForm("audience")
+	if subjectToken == "" || audience == "" {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "subject_token and audience are required")
+		return
+	}
+	if subjectType != internal_service.TokenTypeAccessToken && subjectType != internal_service.TokenTypeJWT {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "unsupported subject_token_type")
+		return
+	}
+	if t := c.PostForm("requested_token_type"); t != "" && t != internal_service.TokenTypeAccessToken {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "unsupported requested_token_type")
+		return
+	}
+	ctx := c.Request.Context()
+	policy, scopes, err := internal_service.TokenExchangeScopes(ctx, h.db, clientID, audience, strings.Fields(c.PostForm("scope")))
+	switch {
+	case errors.Is(err, internal_service.ErrTokenExchangePolicyNotFound):
+		writeOAuthError(c, http.StatusBadRequest, "invalid_target", "token exchange to this audience is not allowed")
+		return
+	case errors.Is(err, internal_service.ErrScopeNotAllowed):
+		writeOAuthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	case err != nil:
+		slog.Error("Failed to get token exchange policy", "client_id", clientID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange token"})
+		return
+	}
+
+	req := authpkg.ExchangeRequest{SubjectToken: subjectToken, ClientID: clientID, Audience: audience, Scopes: scopes, TTL: internal_service.ExchangedTokenTTL}
+	var issued *authpkg.ExchangedToken
+	if slices.Contains(policy.SubjectTypes, internal_service.SubjectTypeUser) {
+		issued, err = h.userService.ExchangeToken(ctx, req)
+	}
+	if issued == nil && err == nil && slices.Contains(policy.SubjectTypes, internal_service.SubjectTypeService) {
+		issued, err = internal_service.ExchangeServiceToken(ctx, h.db, h.signer, req)
+	}
+	switch {
+	case errors.Is(err, authpkg.ErrSubjectAudienceMismatch):
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	case err != nil:
+		slog.Error("Failed to exchange token", "client_id", clientID, "audience", audience, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange token"})
+		return
+	case issued == nil:
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "subject_token is invalid or its type is not allowed by the token exchange policy")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":      issued.Token,
+		"issued_token_type": internal_service.TokenTypeAccessToken,
+		"token_type":        "Bearer",
+		"expires_in":        int64(time.Until(issued.ExpiresAt).Seconds()),
+		"scope":             strings.Join(issued.Scopes, " "),
+	})
+}
+
+// writeOAuthError 按 RFC 6749 第5.2节的格式返回错误
+func writeOAuthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// POST /oauth/introspect（RFC 7662）
+// Basic Auth: client_id/client_secret
+// token=<令牌>&token_type_hint=access_token|refresh_token
+// 支持用户访问令牌、用户refresh token和服务令牌，无效令牌只返回 {"active": false}
+func (h *InternalAuthHandler) Introspect(c *gin.Context) {
+	if _, ok := h.authenticateClient(c); !ok {
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+	ctx := c.Request.Context()
+	info, err := h.userService.IntrospectToken(ctx, token, c.PostForm("token_type_hint"))
+	if err == nil && info == nil {
+		info, err = internal_service.IntrospectServiceToken(ctx, h.db, h.signer, token)
+	}
+	if err != nil {
+		slog.Error("Failed to introspect token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to introspect token"})
+		return
+	}
+	if info == nil {
+		info = &authpkg.Introspection{}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
+
+// authenticateClient 以Basic Auth认证内部客户端，失败时写入响应并返回false。
+// 失败按 client_id 和来源IP计数，超过阈值后暂时锁定
+func (h *InternalAuthHandler) authenticateClient(c *gin.Context) (string, bool) {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Basic ") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Basic authorization required"})
+		return "", false
+	}
+	// 解码Basic Auth
+	payload, err := decodeBasicAuth(auth)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid basic auth"})
+		return "", false
+	}
+	if len(payload) != 2 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid basic auth format"})
+		return "", false
+	}
+	clientID, clientSecret := payload[0], payload[1]
+	ctx := c.Request.Context()
+	keys := []lockout.Key{lockout.ClientKey(clientID), lockout.IPKey(c.ClientIP())}
+	if err := h.guard.Check(ctx, keys...); err != nil {
+		h.writeAuthError(c, err)
+		return "", false
+	}
+	// 查找client并校验secret。client不存在时同样做一次哈希比较，
+	// 两种失败返回相同的错误，避免探测client是否存在
+	var match, rehash bool
+	client, err := h.db.GetInternalClientByID(ctx, clientID)
+	if err == nil {
+		match, rehash = h.hasher.Verify(clientSecret, client.ClientSecretHash)
+	} else {
+		h.hasher.VerifyDummy(clientSecret)
+	}
+	if !match {
+		if err := h.guard.Fail(ctx, c.ClientIP(), c.Request.UserAgent(), keys...); err != nil {
+			h.writeAuthError(c, err)
+			return "", false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid client credentials"})
+		return "", false
+	}
+	if err := h.guard.Succeed(ctx, keys[0]); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset lockout"})
+		return "", false
+	}
+	if rehash {
+		internal_service.RehashClientSecret(ctx, h.db, h.hasher, slog.Default(), client, clientSecret)
+	}
+	return clientID, true
+}
+
+// writeAuthError 锁定返回429，其他错误返回500
+func (h *InternalAuthHandler) writeAuthError(c *gin.Context, err error) {
+	if setRetryAfter(c, err) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check lockout"})
+}
+
 // decodeBasicAuth 解析Basic Auth
 func decodeBasicAuth(auth string) ([]string, error) {
 	// "Basic base64(client_id:client_secret)"
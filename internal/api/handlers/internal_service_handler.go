@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -84,6 +85,7 @@ func (h *InternalServiceHandler) RegisterService(c *gin.Context) {
 // @Success 200 {object} internal_service.AuthenticateServiceResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /internal/services/authenticate [post]
 func (h *InternalServiceHandler) AuthenticateService(c *gin.Context) {
@@ -97,9 +99,16 @@ func (h *InternalServiceHandler) AuthenticateService(c *gin.Context) {
 		return
 	}
 
-	response, err := h.service.AuthenticateService(c.Request.Context(), req)
+	response, err := h.service.AuthenticateService(c.Request.Context(), req, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		h.logger.Error("failed to authenticate service", "error", err)
+		if setRetryAfter(c, err) {
+			c.JSON(http.StatusTooManyRequests, ErrorResponse{
+				Error:   "Authentication failed",
+				Message: err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "Authentication failed",
 			Message: err.Error(),
@@ -422,6 +431,121 @@ func (h *InternalServiceHandler) CleanupExpiredTokens(c *gin.Context) {
 	})
 }
 
+// UnlockService 解除客户端因多次认证失败导致的锁定
+// @Summary 解除客户端锁定
+// @Tags 内部服务管理
+// @Produce json
+// @Param client_id path string true "客户端ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Router /internal/services/{client_id}/unlock [post]
+func (h *InternalServiceHandler) UnlockService(c *gin.Context) {
+	clientID := c.Param("client_id")
+	if err := h.service.UnlockClient(c.Request.Context(), clientID); err != nil {
+		h.logger.Error("failed to unlock client", "error", err, "client_id", clientID)
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Client not found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Client unlocked",
+	})
+}
+
+// ListTokenExchangePolicies 列出客户端的令牌交换策略
+// @Summary 列出令牌交换策略
+// @Tags 内部服务管理
+// @Produce json
+// @Param client_id path string true "客户端ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} ErrorResponse
+// @Router /api/internal/admin/services/{client_id}/token-exchange-policies [get]
+func (h *InternalServiceHandler) ListTokenExchangePolicies(c *gin.Context) {
+	policies, err := h.service.ListTokenExchangePolicies(c.Request.Context(), c.Param("client_id"))
+	if err != nil {
+		h.logger.Error("failed to list token exchange policies", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"policies": policies,
+	})
+}
+
+// SetTokenExchangePolicy 设置客户端为目标服务交换令牌的策略
+// @Summary 设置令牌交换策略
+// @Tags 内部服务管理
+// @Accept json
+// @Produce json
+// @Param client_id path string true "客户端ID"
+// @Param audience path string true "目标服务"
+// @Param request body internal_service.TokenExchangePolicyRequest true "策略"
+// @Success 200 {object} internal_service.TokenExchangePolicyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/internal/admin/services/{client_id}/token-exchange-policies/{audience} [put]
+func (h *InternalServiceHandler) SetTokenExchangePolicy(c *gin.Context) {
+	var req internal_service.TokenExchangePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := h.service.SetTokenExchangePolicy(c.Request.Context(), c.Param("client_id"), c.Param("audience"), req)
+	switch {
+	case errors.Is(err, internal_service.ErrInvalidTokenExchangePolicy):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request", Message: err.Error()})
+		return
+	case errors.Is(err, internal_service.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Client not found", Message: err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("failed to set token exchange policy", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteTokenExchangePolicy 删除客户端为目标服务交换令牌的策略
+// @Summary 删除令牌交换策略
+// @Tags 内部服务管理
+// @Produce json
+// @Param client_id path string true "客户端ID"
+// @Param audience path string true "目标服务"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/internal/admin/services/{client_id}/token-exchange-policies/{audience} [delete]
+func (h *InternalServiceHandler) DeleteTokenExchangePolicy(c *gin.Context) {
+	err := h.service.DeleteTokenExchangePolicy(c.Request.Context(), c.Param("client_id"), c.Param("audience"))
+	switch {
+	case errors.Is(err, internal_service.ErrTokenExchangePolicyNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Policy not found", Message: err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("failed to delete token exchange policy", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Token exchange policy deleted",
+	})
+}
+
 // ErrorResponse 通用错误响应结构体
 type ErrorResponse struct {
 	Error   string `json:"error"`
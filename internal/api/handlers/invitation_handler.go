@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvitationHandler 邀请处理器。租户级路由以 :invitation_id 标识邀请；
+// 组织下的路由另以 :id 标识组织，邀请必须属于该组织
+type InvitationHandler struct {
+	userService *user.Service
+}
+
+// NewInvitationHandler 创建新的邀请处理器
+func NewInvitationHandler(userService *user.Service) *InvitationHandler {
+	return &InvitationHandler{
+		userService: userService,
+	}
+}
+
+// writeInvitationError 将邀请相关错误映射为HTTP状态码
+func writeInvitationError(c *gin.Context, err error) {
+	if writePasswordPolicyError(c, err) || writeProfileError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, user.ErrInvitationEmailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrInvitationUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrInvitationNotFound), errors.Is(err, user.ErrOrganizationNotFound),
+		errors.Is(err, user.ErrRoleNotFound), errors.Is(err, user.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// CreateInvitation 邀请邮箱加入租户或组织并发送邀请邮件（需租户私钥或user:write权限）
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req user.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if orgID := c.Param("id"); orgID != "" {
+		req.OrganizationID = orgID
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.CreateInvitation(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListInvitations 列出邀请，支持按 status、organization_id 过滤（需租户私钥或user:read权限）
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	var req user.ListInvitationsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if orgID := c.Param("id"); orgID != "" {
+		req.OrganizationID = orgID
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	invitations, err := h.userService.ListInvitations(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// ResendInvitation 重新发送邀请邮件并延长有效期（需租户私钥或user:write权限）
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.ResendInvitation(c.Request.Context(), tenant.ID, c.Param("id"), c.Param("invitation_id"))
+	if err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeInvitation 撤销尚未接受的邀请（需租户私钥或user:write权限）
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.RevokeInvitation(c.Request.Context(), tenant.ID, c.Param("id"), c.Param("invitation_id")); err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation 当前用户接受邀请，用户邮箱须与受邀邮箱一致
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req user.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.AcceptInvitation(c.Request.Context(), tenantID.(string), userID.(string), req)
+	if err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Signup 受邀者凭邀请令牌注册并设置密码，不受租户注册方式限制
+func (h *InvitationHandler) Signup(c *gin.Context) {
+	var req user.InvitationSignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.SignupWithInvitation(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
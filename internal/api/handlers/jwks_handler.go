@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"yuyu-test/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jwksMaxAge JWKS 的缓存时间（秒）。轮换密钥时新公钥应先作为旧密钥发布，等缓存过期后再用于签名
+const jwksMaxAge = "3600"
+
+// JWKSHandler 发布用户令牌和服务令牌的验证公钥
+type JWKSHandler struct {
+	userSigner    auth.JWTSigner
+	serviceSigner auth.JWTSigner
+}
+
+// NewJWKSHandler 创建新的 JWKS 处理器
+func NewJWKSHandler(userSigner, serviceSigner auth.JWTSigner) *JWKSHandler {
+	return &JWKSHandler{
+		userSigner:    userSigner,
+		serviceSigner: serviceSigner,
+	}
+}
+
+// UserKeys GET /.well-known/jwks.json
+// 用户访问令牌和 id_token 的验证公钥，HS256 时为空集合
+func (h *JWKSHandler) UserKeys(c *gin.Context) {
+	writeJWKS(c, h.userSigner.Keys().JWKS())
+}
+
+// ServiceKeys GET /.well-known/service-jwks.json
+// 服务令牌的验证公钥，HS256 时为空集合
+func (h *JWKSHandler) ServiceKeys(c *gin.Context) {
+	writeJWKS(c, h.serviceSigner.Keys().JWKS())
+}
+
+// writeJWKS 输出公钥集合，带 ETag 以便验证方条件请求
+func writeJWKS(c *gin.Context, jwks auth.JWKS) {
+	body, err := json.Marshal(jwks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	c.Header("Cache-Control", "public, max-age="+jwksMaxAge)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", body)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"yuyu-test/internal/lockout"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setRetryAfter 错误为登录锁定时设置 Retry-After 响应头并返回true，由调用方返回429
+func setRetryAfter(c *gin.Context, err error) bool {
+	var locked *lockout.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	return true
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCClientHandler OIDC 客户端管理处理器，以 :id 标识客户端
+type OIDCClientHandler struct {
+	userService *user.Service
+}
+
+// NewOIDCClientHandler 创建新的 OIDC 客户端管理处理器
+func NewOIDCClientHandler(userService *user.Service) *OIDCClientHandler {
+	return &OIDCClientHandler{
+		userService: userService,
+	}
+}
+
+// writeOIDCClientError 将 OIDC 客户端相关错误映射为HTTP状态码
+func writeOIDCClientError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrInvalidRedirectURI):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrOIDCClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// CreateClient 登记 OIDC 客户端，机密客户端的密钥只返回一次（需租户私钥或tenant:write权限）
+func (h *OIDCClientHandler) CreateClient(c *gin.Context) {
+	var req user.OIDCClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.CreateOIDCClient(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writeOIDCClientError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListClients 列出租户的 OIDC 客户端（需租户私钥或tenant:read权限）
+func (h *OIDCClientHandler) ListClients(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	clients, err := h.userService.ListOIDCClients(c.Request.Context(), tenant.ID)
+	if err != nil {
+		writeOIDCClientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// GetClient 获取 OIDC 客户端（需租户私钥或tenant:read权限）
+func (h *OIDCClientHandler) GetClient(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.GetOIDCClient(c.Request.Context(), tenant.ID, c.Param("id"))
+	if err != nil {
+		writeOIDCClientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateClient 修改 OIDC 客户端（需租户私钥或tenant:write权限）
+func (h *OIDCClientHandler) UpdateClient(c *gin.Context) {
+	var req user.OIDCClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.UpdateOIDCClient(c.Request.Context(), tenant.ID, c.Param("id"), req)
+	if err != nil {
+		writeOIDCClientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetClientSecret 重置机密客户端的密钥，旧密钥立即失效（需租户私钥或tenant:write权限）
+func (h *OIDCClientHandler) ResetClientSecret(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.ResetOIDCClientSecret(c.Request.Context(), tenant.ID, c.Param("id"))
+	if err != nil {
+		writeOIDCClientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteClient 删除 OIDC 客户端，其签发的 refresh token 一并失效（需租户私钥或tenant:write权限）
+func (h *OIDCClientHandler) DeleteClient(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.DeleteOIDCClient(c.Request.Context(), tenant.ID, c.Param("id")); err != nil {
+		writeOIDCClientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "OIDC client deleted"})
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"yuyu-test/internal/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 托管登录页使用的 cookie，路径限定在 /oidc/<tenant_id>
+const (
+	// oidcSessionCookie 登录会话，有效期内再次授权无需重新登录
+	oidcSessionCookie = "oidc_session"
+	// oidcCSRFCookie 与表单中的 csrf_token 比对（双重提交），防止跨站提交登录和授权同意表单
+	oidcCSRFCookie = "oidc_csrf"
+)
+
+// OIDCHandler OpenID Provider 处理器：发现文档、授权端点、托管登录页和 userinfo。
+// /oauth/token 的 authorization_code 和 refresh_token 授权由 InternalAuthHandler 转交给 Token
+type OIDCHandler struct {
+	provider *user.OIDCProvider
+	// secureCookies 对外地址为 https 时 cookie 只通过 https 发送
+	secureCookies bool
+}
+
+// NewOIDCHandler 创建新的 OIDC 处理器
+func NewOIDCHandler(provider *user.OIDCProvider) *OIDCHandler {
+	return &OIDCHandler{
+		provider:      provider,
+		secureCookies: strings.HasPrefix(provider.BaseURL(), "https://"),
+	}
+}
+
+// Discovery GET /oidc/:tenant_id/.well-known/openid-configuration
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	doc, err := h.provider.Discovery(c.Request.Context(), c.Param("tenant_id"))
+	if errors.Is(err, user.ErrTenantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// Authorize GET /oidc/:tenant_id/authorize
+// 已登录且已同意时直接携带授权码重定向回客户端，否则显示登录页或授权同意页
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	var req user.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		renderOIDCError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tenantID := c.Param("tenant_id")
+	result, err := h.provider.Authorize(c.Request.Context(), tenantID, h.sessionToken(c), req)
+	h.respond(c, http.StatusFound, req, result, err)
+}
+
+// Login POST /oidc/:tenant_id/login
+// 托管登录页提交邮箱和密码，登录成功后回到授权端点继续处理
+func (h *OIDCHandler) Login(c *gin.Context) {
+	req, result, ok := h.formRequest(c)
+	if !ok {
+		return
+	}
+	email := c.PostForm("email")
+	login, err := h.provider.Login(c.Request.Context(), c.Param("tenant_id"), user.LoginRequest{
+		Email:    email,
+		Password: c.PostForm("password"),
+	}, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		h.renderLoginError(c, req, result, email, err)
+		return
+	}
+	h.completeLogin(c, req, result, email, login)
+}
+
+// MFA POST /oidc/:tenant_id/mfa
+// 托管登录页提交二次验证码或恢复码
+func (h *OIDCHandler) MFA(c *gin.Context) {
+	req, result, ok := h.formRequest(c)
+	if !ok {
+		return
+	}
+	mfaReq := user.MFAVerifyRequest{MFAToken: c.PostForm("mfa_token")}
+	// 6位数字为TOTP验证码，其余按恢复码处理
+	if code := strings.TrimSpace(c.PostForm("code")); len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		mfaReq.Code = code
+	} else {
+		mfaReq.RecoveryCode = code
+	}
+	login, err := h.provider.VerifyMFA(c.Request.Context(), c.Param("tenant_id"), mfaReq, c.ClientIP(), c.Request.UserAgent())
+	switch {
+	case errors.Is(err, user.ErrInvalidMFACode):
+		page := h.page(c, req, result)
+		page.MFAToken = mfaReq.MFAToken
+		_, page.Error = loginErrorMessage(c, err)
+		renderOIDCPage(c, http.StatusUnauthorized, mfaPage, page)
+		return
+	case errors.Is(err, user.ErrInvalidMFAToken):
+		page := h.page(c, req, result)
+		page.Error = "Your sign-in has expired. Please sign in again."
+		renderOIDCPage(c, http.StatusUnauthorized, loginPage, page)
+		return
+	case err != nil:
+		h.renderLoginError(c, req, result, "", err)
+		return
+	}
+	h.completeLogin(c, req, result, "", login)
+}
+
+// Consent POST /oidc/:tenant_id/consent
+// 授权同意页提交，action=allow 同意，其他值拒绝
+func (h *OIDCHandler) Consent(c *gin.Context) {
+	req, _, ok := h.formRequest(c)
+	if !ok {
+		return
+	}
+	allow := c.PostForm("action") == "allow"
+	result, err := h.provider.Consent(c.Request.Context(), c.Param("tenant_id"), h.sessionToken(c), req, allow)
+	h.respond(c, http.StatusSeeOther, req, result, err)
+}
+
+// UserInfo GET/POST /oidc/:tenant_id/userinfo
+// Authorization: Bearer <通过授权码签发的访问令牌>
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		c.Header("WWW-Authenticate", `Bearer`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": "Bearer access token required"})
+		return
+	}
+	claims, err := h.provider.UserInfo(c.Request.Context(), c.Param("tenant_id"), token)
+	switch {
+	case errors.Is(err, user.ErrInvalidAccessToken):
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": err.Error()})
+		return
+	case errors.Is(err, user.ErrInsufficientScope):
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "error_description": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, claims)
+}
+
+// Token /oauth/token 的 authorization_code 和 refresh_token 授权。
+// 机密客户端使用 Basic Auth 或表单中的 client_id、client_secret 认证，公开客户端只提供 client_id
+// grant_type=authorization_code&code=<授权码>&redirect_uri=<回调地址>&code_verifier=<PKCE>
+// grant_type=refresh_token&refresh_token=<令牌>
+func (h *OIDCHandler) Token(c *gin.Context) {
+	clientID, clientSecret, ok := oidcClientCredentials(c)
+	if !ok {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "client credentials must be provided using exactly one method")
+		return
+	}
+	ctx := c.Request.Context()
+	var (
+		resp *user.OIDCTokenResponse
+		err  error
+	)
+	switch c.PostForm("grant_type") {
+	case user.GrantTypeAuthorizationCode:
+		code, redirectURI, verifier := c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier")
+		if code == "" || redirectURI == "" || verifier == "" {
+			writeOAuthError(c, http.StatusBadRequest, "invalid_request", "code, redirect_uri and code_verifier are required")
+			return
+		}
+		resp, err = h.provider.ExchangeCode(ctx, clientID, clientSecret, code, redirectURI, verifier, c.ClientIP(), c.Request.UserAgent())
+	case user.GrantTypeRefreshToken:
+		refreshToken := c.PostForm("refresh_token")
+		if refreshToken == "" {
+			writeOAuthError(c, http.StatusBadRequest, "invalid_request", "refresh_token is required")
+			return
+		}
+		resp, err = h.provider.Refresh(ctx, clientID, clientSecret, refreshToken, c.ClientIP(), c.Request.UserAgent())
+	default:
+		writeOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "grant_type is not supported")
+		return
+	}
+	switch {
+	case errors.Is(err, user.ErrInvalidOIDCClient):
+		if c.GetHeader("Authorization") != "" {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	case errors.Is(err, user.ErrInvalidGrant):
+		writeOAuthError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	case err != nil:
+		slog.Error("Failed to issue oidc tokens", "client_id", clientID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue tokens"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, resp)
+}
+
+// oidcClientCredentials 从 Basic Auth 或表单中取出客户端凭据，同时使用两种方式或缺少 client_id 时返回false
+func oidcClientCredentials(c *gin.Context) (string, string, bool) {
+	formID, formSecret := c.PostForm("client_id"), c.PostForm("client_secret")
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return formID, formSecret, formID != ""
+	}
+	if formSecret != "" || !strings.HasPrefix(header, "Basic ") {
+		return "", "", false
+	}
+	parts, err := decodeBasicAuth(header)
+	if err != nil || len(parts) != 2 {
+		return "", "", false
+	}
+	// RFC 6749 2.3.1：Basic Auth 中的凭据先经过表单编码
+	clientID, err1 := url.QueryUnescape(parts[0])
+	clientSecret, err2 := url.QueryUnescape(parts[1])
+	if err1 != nil || err2 != nil || clientID == "" || (formID != "" && formID != clientID) {
+		return "", "", false
+	}
+	return clientID, clientSecret, true
+}
+
+// formRequest 校验托管登录页表单的 CSRF 令牌和其中的授权请求，失败时写入响应并返回false
+func (h *OIDCHandler) formRequest(c *gin.Context) (user.AuthorizeRequest, *user.AuthorizeResult, bool) {
+	var req user.AuthorizeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		renderOIDCError(c, http.StatusBadRequest, err.Error())
+		return req, nil, false
+	}
+	cookie, err := c.Cookie(oidcCSRFCookie)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.PostForm("csrf_token"))) != 1 {
+		renderOIDCError(c, http.StatusForbidden, "Your session has expired. Please return to the application and try again.")
+		return req, nil, false
+	}
+	// 不带会话校验授权请求，取得页面需要的客户端信息
+	result, err := h.provider.Authorize(c.Request.Context(), c.Param("tenant_id"), "", req)
+	if err != nil || result.RedirectURL != "" {
+		h.respond(c, http.StatusSeeOther, req, result, err)
+		return req, nil, false
+	}
+	return req, result, true
+}
+
+// respond 按授权结果重定向回客户端，或显示登录页、授权同意页
+func (h *OIDCHandler) respond(c *gin.Context, redirectStatus int, req user.AuthorizeRequest, result *user.AuthorizeResult, err error) {
+	switch {
+	case errors.Is(err, user.ErrOIDCClientNotFound), errors.Is(err, user.ErrInvalidRedirectURI):
+		renderOIDCError(c, http.StatusBadRequest, "The application is not registered or its redirect_uri is not allowed.")
+	case err != nil:
+		slog.Error("Failed to process authorization request", "tenant_id", c.Param("tenant_id"), "client_id", req.ClientID, "error", err)
+		renderOIDCError(c, http.StatusInternalServerError, "Something went wrong. Please try again later.")
+	case result.RedirectURL != "":
+		c.Redirect(redirectStatus, result.RedirectURL)
+	case result.LoginRequired:
+		renderOIDCPage(c, http.StatusOK, loginPage, h.page(c, req, result))
+	case result.ConsentRequired:
+		page := h.page(c, req, result)
+		page.UserEmail = result.User.Email
+		page.Scopes = result.Scopes
+		renderOIDCPage(c, http.StatusOK, consentPage, page)
+	}
+}
+
+// completeLogin 登录完成后写入会话 cookie 并回到授权端点；需要二次验证时显示验证码页
+func (h *OIDCHandler) completeLogin(c *gin.Context, req user.AuthorizeRequest, result *user.AuthorizeResult, email string, login *user.OIDCLoginResponse) {
+	if login.MFAEnrollmentRequired {
+		page := h.page(c, req, result)
+		page.Email = email
+		page.Error = "Two-step verification must be set up before signing in. Please set it up in the application first."
+		renderOIDCPage(c, http.StatusForbidden, loginPage, page)
+		return
+	}
+	if login.MFARequired {
+		page := h.page(c, req, result)
+		page.MFAToken = login.MFAToken
+		renderOIDCPage(c, http.StatusOK, mfaPage, page)
+		return
+	}
+	h.setCookie(c, oidcSessionCookie, login.SessionToken, int(user.OIDCSessionTTL.Seconds()))
+
+	// 已经重新登录，不再要求 prompt=login
+	params := req.Values()
+	prompts := strings.Fields(req.Prompt)
+	kept := prompts[:0]
+	for _, prompt := range prompts {
+		if prompt != "login" {
+			kept = append(kept, prompt)
+		}
+	}
+	params.Del("prompt")
+	if len(kept) > 0 {
+		params.Set("prompt", strings.Join(kept, " "))
+	}
+	c.Redirect(http.StatusSeeOther, "authorize?"+params.Encode())
+}
+
+// renderLoginError 登录失败时重新显示登录页
+func (h *OIDCHandler) renderLoginError(c *gin.Context, req user.AuthorizeRequest, result *user.AuthorizeResult, email string, err error) {
+	status, message := loginErrorMessage(c, err)
+	if message == "" {
+		slog.Error("Hosted login failed", "tenant_id", c.Param("tenant_id"), "error", err)
+		message = "Something went wrong. Please try again later."
+	}
+	page := h.page(c, req, result)
+	page.Email = email
+	page.Error = message
+	renderOIDCPage(c, status, loginPage, page)
+}
+
+// page 生成托管登录页的公共数据，必要时下发 CSRF cookie
+func (h *OIDCHandler) page(c *gin.Context, req user.AuthorizeRequest, result *user.AuthorizeResult) *oidcPage {
+	token, err := c.Cookie(oidcCSRFCookie)
+	if err != nil || token == "" {
+		b := make([]byte, 32)
+		rand.Read(b)
+		token = base64.RawURLEncoding.EncodeToString(b)
+		h.setCookie(c, oidcCSRFCookie, token, 0)
+	}
+	return &oidcPage{
+		TenantName: result.TenantName,
+		ClientName: result.Client.Name,
+		Params:     req.Values(),
+		CSRFToken:  token,
+	}
+}
+
+// sessionToken 读取会话 cookie，不存在时返回空
+func (h *OIDCHandler) sessionToken(c *gin.Context) string {
+	token, _ := c.Cookie(oidcSessionCookie)
+	return token
+}
+
+// setCookie 写入限定在租户路径下的 HttpOnly cookie，maxAge 为0时为会话 cookie
+func (h *OIDCHandler) setCookie(c *gin.Context, name, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/oidc/" + c.Param("tenant_id"),
+		MaxAge:   maxAge,
+		Secure:   h.secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"yuyu-test/internal/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcPage 托管登录页的页面数据
+type oidcPage struct {
+	TenantName string
+	ClientName string
+	// Params 授权请求参数，作为隐藏字段随表单提交
+	Params    url.Values
+	CSRFToken string
+	Error     string
+	Email     string
+	MFAToken  string
+	// UserEmail 授权同意页显示的当前用户
+	UserEmail string
+	Scopes    []string
+	Message   string
+}
+
+// scopeDescriptions 授权同意页上 scope 的说明
+var scopeDescriptions = map[string]string{
+	user.ScopeOpenID:        "Sign you in with your account",
+	user.ScopeProfile:       "View your basic profile (name, picture, locale)",
+	user.ScopeEmail:         "View your email address",
+	user.ScopeOfflineAccess: "Stay signed in when you are not using the application",
+}
+
+var oidcTemplates = template.Must(template.New("layout").Funcs(template.FuncMap{
+	"describeScope": func(scope string) string { return scopeDescriptions[scope] },
+}).Parse(`{{define "layout"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .TenantName}}{{.TenantName}}{{else}}Sign in{{end}}</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#f4f5f7;margin:0;display:flex;justify-content:center}
+main{background:#fff;margin-top:10vh;padding:2rem;border-radius:8px;width:100%;max-width:360px;box-shadow:0 1px 4px rgba(0,0,0,.1)}
+h1{font-size:1.25rem;margin:0 0 1rem}
+label{display:block;margin:.75rem 0 .25rem;font-size:.9rem}
+input[type=email],input[type=password],input[type=text]{width:100%;box-sizing:border-box;padding:.5rem;border:1px solid #ccc;border-radius:4px}
+button{margin-top:1rem;padding:.5rem 1rem;border:0;border-radius:4px;background:#2563eb;color:#fff;cursor:pointer}
+button.secondary{background:#e5e7eb;color:#111}
+.error{color:#b91c1c;font-size:.9rem}
+ul{padding-left:1.25rem}
+</style>
+</head>
+<body><main>{{template "content" .}}</main></body>
+</html>{{end}}
+{{define "params"}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">{{range $name, $values := .Params}}{{range $values}}
+<input type="hidden" name="{{$name}}" value="{{.}}">{{end}}{{end}}{{end}}`))
+
+var (
+	loginPage = template.Must(template.Must(oidcTemplates.Clone()).Parse(`{{define "content"}}
+<h1>Sign in to {{.ClientName}}</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="login">
+{{template "params" .}}
+<label for="email">Email</label>
+<input type="email" id="email" name="email" value="{{.Email}}" autocomplete="username" required autofocus>
+<label for="password">Password</label>
+<input type="password" id="password" name="password" autocomplete="current-password" required>
+<button type="submit">Sign in</button>
+</form>
+{{end}}`))
+
+	mfaPage = template.Must(template.Must(oidcTemplates.Clone()).Parse(`{{define "content"}}
+<h1>Two-step verification</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="mfa">
+{{template "params" .}}
+<input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+<label for="code">Authenticator code or recovery code</label>
+<input type="text" id="code" name="code" inputmode="numeric" autocomplete="one-time-code" required autofocus>
+<button type="submit">Verify</button>
+</form>
+{{end}}`))
+
+	consentPage = template.Must(template.Must(oidcTemplates.Clone()).Parse(`{{define "content"}}
+<h1>{{.ClientName}} wants to access your account</h1>
+<p>Signed in as {{.UserEmail}}</p>
+<ul>{{range .Scopes}}<li>{{describeScope .}}</li>{{end}}</ul>
+<form method="post" action="consent">
+{{template "params" .}}
+<button type="submit" name="action" value="allow">Allow</button>
+<button type="submit" name="action" value="deny" class="secondary">Deny</button>
+</form>
+{{end}}`))
+
+	errorPage = template.Must(template.Must(oidcTemplates.Clone()).Parse(`{{define "content"}}
+<h1>Unable to sign in</h1>
+<p class="error">{{.Message}}</p>
+{{end}}`))
+)
+
+// renderOIDCPage 输出托管登录页。页面不能被嵌入其他站点，也不能被缓存
+func renderOIDCPage(c *gin.Context, status int, page *template.Template, data *oidcPage) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := page.ExecuteTemplate(c.Writer, "layout", data); err != nil {
+		slog.Error("Failed to render oidc page", "error", err)
+	}
+}
+
+// renderOIDCError 输出错误页，用于不能重定向回客户端的错误
+func renderOIDCError(c *gin.Context, status int, message string) {
+	renderOIDCPage(c, status, errorPage, &oidcPage{Message: message})
+}
+
+// 托管登录页的错误提示
+var loginErrorMessages = map[error]string{
+	user.ErrInvalidCredentials:    "Incorrect email or password.",
+	user.ErrEmailNotVerified:      "Please verify your email address before signing in.",
+	user.ErrPasswordResetRequired: "You need to reset your password before signing in.",
+	user.ErrPasswordExpired:       "Your password has expired. Please reset it before signing in.",
+	user.ErrUserDisabled:          "This account has been disabled.",
+	user.ErrUserLocked:            "This account has been locked.",
+	user.ErrSessionLimitReached:   "You are signed in on too many devices. Sign out of another device and try again.",
+	user.ErrInvalidMFACode:        "The code is incorrect. Please try again.",
+}
+
+// loginErrorMessage 将登录错误转换为页面提示和状态码，未知错误返回空提示
+func loginErrorMessage(c *gin.Context, err error) (int, string) {
+	for target, message := range loginErrorMessages {
+		if errors.Is(err, target) {
+			return http.StatusUnauthorized, message
+		}
+	}
+	if setRetryAfter(c, err) {
+		return http.StatusTooManyRequests, "Too many failed attempts. Please try again later."
+	}
+	return http.StatusInternalServerError, ""
+}
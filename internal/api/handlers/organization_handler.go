@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHandler 租户内组织和成员处理器
+type OrganizationHandler struct {
+	userService *user.Service
+}
+
+// NewOrganizationHandler 创建新的组织处理器
+func NewOrganizationHandler(userService *user.Service) *OrganizationHandler {
+	return &OrganizationHandler{
+		userService: userService,
+	}
+}
+
+// writeOrganizationError 将组织相关错误映射为HTTP状态码
+func writeOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrSessionNotFound):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrUserDisabled), errors.Is(err, user.ErrUserLocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrOrganizationNotFound), errors.Is(err, user.ErrNotOrganizationMember),
+		errors.Is(err, user.ErrRoleNotFound), errors.Is(err, user.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// CreateOrganization 创建组织（需租户私钥或user:write权限）
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req user.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.CreateOrganization(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListOrganizations 列出租户的全部组织（需租户私钥或user:read权限）
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	orgs, err := h.userService.ListOrganizations(c.Request.Context(), tenant.ID)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// GetOrganization 获取组织信息（需租户私钥或user:read权限）
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.GetOrganization(c.Request.Context(), tenant.ID, c.Param("id"))
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateOrganization 修改组织名称（需租户私钥或user:write权限）
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	var req user.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.UpdateOrganization(c.Request.Context(), tenant.ID, c.Param("id"), req)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteOrganization 删除组织（需租户私钥或user:delete权限）
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.DeleteOrganization(c.Request.Context(), tenant.ID, c.Param("id")); err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted"})
+}
+
+// ListMembers 分页列出组织成员，查询参数与用户列表相同（需租户私钥或user:read权限）
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	var req user.ListUsersRequest
+	if !bindListUsersQuery(c, &req) {
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.ListOrganizationMembers(c.Request.Context(), tenant.ID, c.Param("id"), req)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMember 获取组织成员及其组织角色（需租户私钥或user:read权限）
+func (h *OrganizationHandler) GetMember(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.GetOrganizationMember(c.Request.Context(), tenant.ID, c.Param("id"), c.Param("user_id"))
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SetMember 将用户加入组织并设置其组织角色（需租户私钥或user:write权限）
+func (h *OrganizationHandler) SetMember(c *gin.Context) {
+	var req user.SetOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.SetOrganizationMember(c.Request.Context(), tenant.ID, c.Param("id"), c.Param("user_id"), req)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RemoveMember 将用户移出组织（需租户私钥或user:write权限）
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.RemoveOrganizationMember(c.Request.Context(), tenant.ID, c.Param("id"), c.Param("user_id")); err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// ListMyOrganizations 列出当前用户所属的组织，active 标记访问令牌中的当前组织
+func (h *OrganizationHandler) ListMyOrganizations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+	claims, _ := c.Get("claims")
+
+	orgs, err := h.userService.ListMyOrganizations(c.Request.Context(), tenantID.(string), userID.(string), claims.(*auth.Claims).OrganizationID)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// SwitchOrganization 切换当前会话的组织，返回带有新 org_id 的访问令牌，refresh token 不变
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	var req user.SwitchOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+	claimsValue, _ := c.Get("claims")
+	claims := claimsValue.(*auth.Claims)
+
+	response, err := h.userService.SwitchOrganization(c.Request.Context(), tenantID.(string), userID.(string), claims.SessionID, claims.AMR, req)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
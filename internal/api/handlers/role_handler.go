@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler 租户角色和权限处理器
+type RoleHandler struct {
+	userService *user.Service
+}
+
+// NewRoleHandler 创建新的角色处理器
+func NewRoleHandler(userService *user.Service) *RoleHandler {
+	return &RoleHandler{
+		userService: userService,
+	}
+}
+
+// writeRoleError 将角色相关错误映射为HTTP状态码
+func writeRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrRoleNotFound), errors.Is(err, user.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrRoleExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// CreateRole 创建角色（需租户私钥或tenant:write权限）
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req user.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.CreateRole(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListRoles 列出租户的全部角色（需租户私钥或tenant:read权限）
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	roles, err := h.userService.ListRoles(c.Request.Context(), tenant.ID)
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetRole 获取角色及其权限（需租户私钥或tenant:read权限）
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.GetRole(c.Request.Context(), tenant.ID, c.Param("id"))
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteRole 删除角色（需租户私钥或tenant:write权限）
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.DeleteRole(c.Request.Context(), tenant.ID, c.Param("id")); err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+// AddRolePermissions 为角色添加权限（需租户私钥或tenant:write权限）
+func (h *RoleHandler) AddRolePermissions(c *gin.Context) {
+	var req user.RolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.AddRolePermissions(c.Request.Context(), tenant.ID, c.Param("id"), req)
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RemoveRolePermission 移除角色的一个权限（需租户私钥或tenant:write权限）
+func (h *RoleHandler) RemoveRolePermission(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.RemoveRolePermission(c.Request.Context(), tenant.ID, c.Param("id"), c.Param("permission")); err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Permission removed"})
+}
+
+// GetUserRoles 获取指定用户当前生效的角色和权限，organization_id 查询参数非空时包含该组织内的角色（需租户私钥或user:read权限）
+func (h *RoleHandler) GetUserRoles(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	response, err := h.userService.GetUserAuthorization(c.Request.Context(), tenant.ID, c.Param("id"), c.Query("organization_id"))
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AssignUserRole 为用户分配角色（需租户私钥或user:write权限）
+func (h *RoleHandler) AssignUserRole(c *gin.Context) {
+	var req user.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.AssignRole(c.Request.Context(), tenant.ID, c.Param("id"), req); err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned"})
+}
+
+// UnassignUserRole 移除用户的角色（需租户私钥或user:write权限）
+func (h *RoleHandler) UnassignUserRole(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.UnassignRole(c.Request.Context(), tenant.ID, c.Param("id"), c.Param("role_id")); err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role unassigned"})
+}
+
+// GetMyPermissions 查询当前用户的角色和权限，访问令牌因大小上限未携带权限时使用
+func (h *RoleHandler) GetMyPermissions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+	claims, _ := c.Get("claims")
+
+	response, err := h.userService.GetUserAuthorization(c.Request.Context(), tenantID.(string), userID.(string), claims.(*auth.Claims).OrganizationID)
+	if err != nil {
+		writeRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -63,3 +63,29 @@ func (h *TenantHandler) GetTenants(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"tenants": tenants})
 }
+
+// GetTenantSettings 获取租户策略配置（内部API，需tenant:read权限）
+func (h *TenantHandler) GetTenantSettings(c *gin.Context) {
+	settings, err := h.tenantService.GetSettings(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateTenantSettings 更新租户策略配置（内部API，需tenant:write权限）
+func (h *TenantHandler) UpdateTenantSettings(c *gin.Context) {
+	var req tenant.Settings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.tenantService.UpdateSettings(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
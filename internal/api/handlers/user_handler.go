@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
+	"strings"
 
+	"yuyu-test/internal/claimsmap"
+	"yuyu-test/internal/profileschema"
 	"yuyu-test/internal/store/database"
 	"yuyu-test/internal/user"
 
@@ -63,8 +71,61 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GetUsers 获取租户下的所有用户
+// bindListUsersQuery 解析用户列表的查询参数，包括 profile.<key> 属性过滤；失败时已写入响应
+func bindListUsersQuery(c *gin.Context, req *user.ListUsersRequest) bool {
+	if err := c.ShouldBindQuery(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	for key, values := range c.Request.URL.Query() {
+		if attr, ok := strings.CutPrefix(key, "profile."); ok && attr != "" && len(values) > 0 {
+			if req.Profile == nil {
+				req.Profile = make(map[string]string)
+			}
+			req.Profile[attr] = values[0]
+		}
+	}
+	return true
+}
+
+// GetUsers 分页列出租户下的用户，支持按邮箱前缀、创建时间、状态、组织和 profile.<key> 属性过滤
 func (h *UserHandler) GetUsers(c *gin.Context) {
+	var req user.ListUsersRequest
+	if !bindListUsersQuery(c, &req) {
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+
+	tenant := tenantInterface.(*database.Tenant)
+	response, err := h.userService.ListUsers(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SearchUsers 全文搜索租户下的用户，q 为搜索词，按相关度返回前 limit 个结果
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	var req struct {
+		Query          string `form:"q" binding:"required"`
+		OrganizationID string `form:"organization_id"`
+		Limit          int    `form:"limit" binding:"omitempty,min=1,max=200"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	// 从中间件获取租户信息
 	tenantInterface, exists := c.Get("tenant")
 	if !exists {
@@ -73,8 +134,12 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 	}
 
 	tenant := tenantInterface.(*database.Tenant)
-	users, err := h.userService.GetUsersByTenant(c.Request.Context(), tenant.ID)
+	users, err := h.userService.SearchUsers(c.Request.Context(), tenant.ID, req.Query, req.OrganizationID, req.Limit)
 	if err != nil {
+		if errors.Is(err, user.ErrInvalidSearchQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -96,22 +161,25 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 	tenant := tenantInterface.(*database.Tenant)
-	response, err := h.userService.Register(c.Request.Context(), tenant.ID, req)
+	response, err := h.userService.CreateUser(c.Request.Context(), tenant.ID, req)
 	if err != nil {
+		if writePasswordPolicyError(c, err) || writeProfileError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, response)
 }
 
-// UpdateUser 更新用户（内部API，需user:write权限）
+// UpdateUser 部分更新用户的邮箱、资料、密码或状态（需租户私钥或user:write权限）
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	userID := c.Param("id")
 	if userID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "user ID is required"})
 		return
 	}
-	var req user.RegisterRequest
+	var req user.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -123,11 +191,881 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 	tenant := tenantInterface.(*database.Tenant)
-	// 这里只做简单演示，实际应调用 UpdateUser 业务逻辑
-	response, err := h.userService.Register(c.Request.Context(), tenant.ID, req)
+
+	response, err := h.userService.UpdateUser(c.Request.Context(), tenant.ID, userID, req)
+	if err != nil {
+		if writePasswordPolicyError(c, err) || writeProfileError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, user.ErrInvalidUserStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteUser 删除用户，默认软删除，?hard=true 时硬删除（需租户私钥或user:delete权限）
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user ID is required"})
+		return
+	}
+	hard := c.Query("hard") == "true"
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.DeleteUser(c.Request.Context(), tenant.ID, userID, hard); err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+// ImportUsers 创建异步批量导入任务。文件可以直接作为请求体（text/csv、application/x-ndjson），
+// 也可以通过 multipart 的 file 字段上传；格式由 format 参数、Content-Type 或文件扩展名决定
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, user.MaxImportSize)
+	format := c.Query("format")
+
+	var payload []byte
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		var header *multipart.FileHeader
+		if header, err = c.FormFile("file"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if format == "" {
+			format = importFormatFromName(header.Filename)
+		}
+		var file multipart.File
+		if file, err = header.Open(); err == nil {
+			payload, err = io.ReadAll(file)
+			file.Close()
+		}
+	} else {
+		if format == "" {
+			format = importFormatFromContentType(c.ContentType())
+		}
+		payload, err = io.ReadAll(c.Request.Body)
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import file too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	job, err := h.userService.CreateImportJob(c.Request.Context(), tenant.ID, format, payload)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidImport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func importFormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ndjson", ".jsonl":
+		return user.ImportFormatNDJSON
+	default:
+		return user.ImportFormatCSV
+	}
+}
+
+func importFormatFromContentType(contentType string) string {
+	switch contentType {
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return user.ImportFormatNDJSON
+	default:
+		return user.ImportFormatCSV
+	}
+}
+
+// GetImportJob 查询导入任务的进度和逐行错误，errors_after 为上一页最后一个错误的行号
+func (h *UserHandler) GetImportJob(c *gin.Context) {
+	var req struct {
+		ErrorsAfter int32 `form:"errors_after" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	job, err := h.userService.GetImportJob(c.Request.Context(), tenant.ID, c.Param("id"), req.ErrorsAfter)
+	if err != nil {
+		if errors.Is(err, user.ErrImportJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// writeUserDataExport 以附件形式返回用户数据导出
+func (h *UserHandler) writeUserDataExport(c *gin.Context, tenantID, userID, sessionID string) {
+	export, err := h.userService.ExportUserData(c.Request.Context(), tenantID, userID, sessionID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%s-export.json"`, userID))
+	c.IndentedJSON(http.StatusOK, export)
+}
+
+// ExportMyData 导出当前用户的全部数据
+func (h *UserHandler) ExportMyData(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	h.writeUserDataExport(c, tenantID.(string), userID.(string), c.GetString("session_id"))
+}
+
+// ExportUserData 导出指定用户的全部数据，包括已软删除的用户（需租户私钥或user:read权限）
+func (h *UserHandler) ExportUserData(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	h.writeUserDataExport(c, tenant.ID, c.Param("id"), "")
+}
+
+// writeErasureError 将数据删除相关错误映射为HTTP响应
+func writeErasureError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound), errors.Is(err, user.ErrErasureNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// RequestMyErasure 当前用户申请删除自己的全部数据，账号立即停用并退出所有设备
+func (h *UserHandler) RequestMyErasure(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	erasure, err := h.userService.RequestErasure(c.Request.Context(), tenantID.(string), userID.(string), user.ErasureRequestedByUser)
+	if err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, erasure)
+}
+
+// RequestUserErasure 申请删除指定用户的全部数据（需租户私钥或user:delete权限）
+func (h *UserHandler) RequestUserErasure(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	erasure, err := h.userService.RequestErasure(c.Request.Context(), tenant.ID, c.Param("id"), user.ErasureRequestedByAdmin)
+	if err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, erasure)
+}
+
+// GetUserErasure 查询指定用户的数据删除请求，数据删除后仍可查询（需租户私钥或user:read权限）
+func (h *UserHandler) GetUserErasure(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	erasure, err := h.userService.GetErasure(c.Request.Context(), tenant.ID, c.Param("id"))
+	if err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, erasure)
+}
+
+// CancelUserErasure 在宽限期内撤销数据删除请求（需租户私钥或user:delete权限）
+func (h *UserHandler) CancelUserErasure(c *gin.Context) {
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.CancelErasure(c.Request.Context(), tenant.ID, c.Param("id")); err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Erasure request cancelled"})
+}
+
+// UpdateMe 修改当前用户的资料，不能修改只读字段
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	var req user.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.UpdateProfile(c.Request.Context(), tenantID.(string), userID.(string), req)
 	if err != nil {
+		if writeProfileError(c, err) {
+			return
+		}
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+
 	c.JSON(http.StatusOK, response)
 }
+
+// ChangePassword 当前用户修改密码
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req user.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	err := h.userService.ChangePassword(c.Request.Context(), tenantID.(string), userID.(string), req)
+	if err != nil {
+		if writePasswordPolicyError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, user.ErrInvalidCurrentPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// ForcePasswordReset 强制用户重置密码（内部API，需user:write权限）
+func (h *UserHandler) ForcePasswordReset(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user ID is required"})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.ForcePasswordReset(c.Request.Context(), tenant.ID, userID); err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset required, reset email sent"})
+}
+
+// UnlockUser 解除用户因多次登录失败导致的锁定（内部API，需user:write权限）
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user ID is required"})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	if err := h.userService.UnlockUser(c.Request.Context(), tenant.ID, userID); err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// writeMFAError 将MFA相关错误映射为HTTP状态码
+func writeMFAError(c *gin.Context, err error) {
+	if setRetryAfter(c, err) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case errors.Is(err, user.ErrInvalidMFAToken), errors.Is(err, user.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrMFAAlreadyEnabled), errors.Is(err, user.ErrMFANotEnabled), errors.Is(err, user.ErrSessionLimitReached):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrUserDisabled), errors.Is(err, user.ErrUserLocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetMFAStatus 获取当前用户的MFA状态
+func (h *UserHandler) GetMFAStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.GetMFAStatus(c.Request.Context(), tenantID.(string), userID.(string))
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnrollTOTP 当前用户开始绑定TOTP，返回密钥和otpauth URI
+func (h *UserHandler) EnrollTOTP(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.EnrollTOTP(c.Request.Context(), tenantID.(string), userID.(string))
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConfirmTOTP 当前用户用验证码确认TOTP绑定，返回恢复码
+func (h *UserHandler) ConfirmTOTP(c *gin.Context) {
+	var req user.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.ConfirmTOTP(c.Request.Context(), tenantID.(string), userID.(string), req)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DisableTOTP 当前用户校验验证码后解绑TOTP
+func (h *UserHandler) DisableTOTP(c *gin.Context) {
+	var req user.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	if err := h.userService.DisableTOTP(c.Request.Context(), tenantID.(string), userID.(string), req, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "TOTP disabled"})
+}
+
+// RegenerateRecoveryCodes 当前用户校验验证码后重新生成恢复码
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req user.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.RegenerateRecoveryCodes(c.Request.Context(), tenantID.(string), userID.(string), req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// writePasskeyError 将Passkey相关错误映射为HTTP状态码
+func writePasskeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrWebAuthnNotConfigured):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrInvalidWebAuthnResponse):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrEmailNotVerified), errors.Is(err, user.ErrPasswordResetRequired),
+		errors.Is(err, user.ErrUserDisabled), errors.Is(err, user.ErrUserLocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrPasskeyNotFound), errors.Is(err, user.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrSessionLimitReached):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// BeginPasskeyRegistration 当前用户开始注册Passkey，返回 navigator.credentials.create() 选项
+func (h *UserHandler) BeginPasskeyRegistration(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.BeginPasskeyRegistration(c.Request.Context(), tenantID.(string), userID.(string))
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// FinishPasskeyRegistration 当前用户完成Passkey注册
+func (h *UserHandler) FinishPasskeyRegistration(c *gin.Context) {
+	var req user.PasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	response, err := h.userService.FinishPasskeyRegistration(c.Request.Context(), tenantID.(string), userID.(string), req)
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListPasskeys 列出当前用户的Passkey
+func (h *UserHandler) ListPasskeys(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	passkeys, err := h.userService.ListPasskeys(c.Request.Context(), tenantID.(string), userID.(string))
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+// DeletePasskey 删除当前用户的Passkey
+func (h *UserHandler) DeletePasskey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	if err := h.userService.DeletePasskey(c.Request.Context(), tenantID.(string), userID.(string), c.Param("id")); err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted"})
+}
+
+// writePasswordPolicyError 密码不符合租户策略时返回400及违规明细，返回false表示不是策略错误
+func writePasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *user.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      user.ErrPasswordPolicy.Error(),
+		"violations": policyErr.Violations,
+	})
+	return true
+}
+
+// writeProfileError 资料不符合租户 schema 时返回400及违规项，其他错误返回false
+func writeProfileError(c *gin.Context, err error) bool {
+	var profileErr *user.ProfileValidationError
+	if !errors.As(err, &profileErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":          user.ErrProfileInvalid.Error(),
+		"schema_version": profileErr.SchemaVersion,
+		"violations":     profileErr.Violations,
+	})
+	return true
+}
+
+// writeSessionError 将会话相关错误映射为HTTP状态码
+func writeSessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrSessionNotFound), errors.Is(err, user.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListSessions 列出当前用户的登录会话（设备）
+func (h *UserHandler) ListSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+	sessionID := c.GetString("session_id")
+
+	sessions, err := h.userService.ListSessions(c.Request.Context(), tenantID.(string), userID.(string), sessionID)
+	if err != nil {
+		writeSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession 注销当前用户的指定会话
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	if err := h.userService.RevokeSession(c.Request.Context(), tenantID.(string), userID.(string), c.Param("id")); err != nil {
+		writeSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAllSessions 在所有设备上退出登录
+func (h *UserHandler) RevokeAllSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+	tenantID, _ := c.Get("tenant_id")
+
+	if err := h.userService.RevokeAllSessions(c.Request.Context(), tenantID.(string), userID.(string)); err != nil {
+		writeSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+// GetProfileSchema 获取租户的资料 schema，?version 指定版本，默认为当前生效的版本（需tenant:read权限）
+func (h *UserHandler) GetProfileSchema(c *gin.Context) {
+	var query struct {
+		Version int32 `form:"version" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.userService.GetProfileSchema(c.Request.Context(), c.Param("id"), query.Version)
+	if err != nil {
+		if errors.Is(err, user.ErrProfileSchemaNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PublishProfileSchema 发布新版本的资料 schema，请求体为 schema 本身（需tenant:write权限）
+func (h *UserHandler) PublishProfileSchema(c *gin.Context) {
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.userService.PublishProfileSchema(c.Request.Context(), c.Param("id"), raw)
+	if err != nil {
+		if errors.Is(err, profileschema.ErrInvalidSchema) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// DryRunProfileSchema 用请求体中的 schema 检查现有用户，不发布（需tenant:write权限）
+func (h *UserHandler) DryRunProfileSchema(c *gin.Context) {
+	var query struct {
+		Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 100
+	}
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.userService.DryRunProfileSchema(c.Request.Context(), c.Param("id"), raw, query.Limit)
+	if err != nil {
+		if errors.Is(err, profileschema.ErrInvalidSchema) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetClaimsMapping 获取租户的访问令牌声明映射（需tenant:read权限）
+func (h *UserHandler) GetClaimsMapping(c *gin.Context) {
+	response, err := h.userService.GetClaimsMapping(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, user.ErrClaimsMappingNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SetClaimsMapping 设置租户的访问令牌声明映射，请求体为映射本身（需tenant:write权限）
+func (h *UserHandler) SetClaimsMapping(c *gin.Context) {
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.userService.SetClaimsMapping(c.Request.Context(), c.Param("id"), raw)
+	if err != nil {
+		if errors.Is(err, claimsmap.ErrInvalidMapping) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteClaimsMapping 删除租户的访问令牌声明映射，恢复默认声明（需tenant:write权限）
+func (h *UserHandler) DeleteClaimsMapping(c *gin.Context) {
+	if err := h.userService.DeleteClaimsMapping(c.Request.Context(), c.Param("id")); err != nil {
+		if errors.Is(err, user.ErrClaimsMappingNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Claims mapping deleted"})
+}
+
+// Impersonate 以用户身份签发模拟令牌（内部API，需user:impersonate权限）
+func (h *UserHandler) Impersonate(c *gin.Context) {
+	var req user.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	resp, err := h.userService.Impersonate(c.Request.Context(), tenant.ID, c.Param("id"), c.GetString("client_id"), req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrImpersonationReasonRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrUserDisabled), errors.Is(err, user.ErrUserLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListImpersonations 查询租户的模拟审计记录（内部API，需user:read权限）
+func (h *UserHandler) ListImpersonations(c *gin.Context) {
+	var req user.ListImpersonationsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 从中间件获取租户信息
+	tenantInterface, exists := c.Get("tenant")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "tenant not found"})
+		return
+	}
+	tenant := tenantInterface.(*database.Tenant)
+
+	records, err := h.userService.ListImpersonations(c.Request.Context(), tenant.ID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"impersonations": records})
+}
@@ -1,26 +1,39 @@
 package middleware
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
 	"yuyu-test/internal/auth"
+	"yuyu-test/internal/revocation"
 	"yuyu-test/internal/tenant"
 
 	"github.com/gin-gonic/gin"
 )
 
+// UserPermissionLookup 查询用户当前的权限，由 user.Service 实现
+type UserPermissionLookup interface {
+	UserPermissions(ctx context.Context, tenantID, userID, organizationID string) ([]string, error)
+}
+
 // AuthMiddleware 认证中间件
 type AuthMiddleware struct {
 	tenantService *tenant.Service
 	signer        auth.JWTSigner
+	revocations   *revocation.Store
+	permissions   UserPermissionLookup
 }
 
 // NewAuthMiddleware 创建新的认证中间件
-func NewAuthMiddleware(tenantService *tenant.Service, signer auth.JWTSigner) *AuthMiddleware {
+func NewAuthMiddleware(tenantService *tenant.Service, signer auth.JWTSigner, revocations *revocation.Store, permissions UserPermissionLookup) *AuthMiddleware {
 	return &AuthMiddleware{
 		tenantService: tenantService,
 		signer:        signer,
+		revocations:   revocations,
+		permissions:   permissions,
 	}
 }
 
@@ -53,7 +66,45 @@ func (m *AuthMiddleware) APIKeyAuth() gin.HandlerFunc {
 			return
 		}
 
-		// 将租户信息存储到上下文中
+		// 将租户信息存储到上下文中，私钥形如 "<公钥>.<随机串>"
+		c.Set("tenant", tenant)
+		c.Set("api_key_secret", strings.Contains(apiKey, "."))
+		c.Next()
+	}
+}
+
+// RequireSecretKey 要求使用租户私钥认证，公钥会下发到前端，不能用于管理操作。
+// 需放在 APIKeyAuth 之后使用
+func (m *AuthMiddleware) RequireSecretKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("api_key_secret") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "secret API key required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// InternalTenantContext 内部服务调用时通过 X-Tenant-ID 请求头指定目标租户。
+// 需放在服务认证中间件之后使用
+func (m *AuthMiddleware) InternalTenantContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := c.GetHeader("X-Tenant-ID")
+		if tenantID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID header required"})
+			c.Abort()
+			return
+		}
+
+		tenant, err := m.tenantService.GetTenantByID(c.Request.Context(), tenantID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+			c.Abort()
+			return
+		}
+
+		// 与APIKeyAuth保持一致，将租户信息存储到上下文中
 		c.Set("tenant", tenant)
 		c.Next()
 	}
@@ -80,19 +131,86 @@ func (m *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 
 		tokenString := parts[1]
 
-		// 解析JWT令牌
+		// 解析JWT令牌，签发给其他服务的令牌不能调用用户接口
 		claims := &auth.Claims{}
 		err := m.signer.Parse(tokenString, claims)
-		if err != nil {
+		if err != nil || claims.UserID == "" || !claims.IsFirstParty() {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		// 已退出登录、会话被注销或密码已修改的令牌立即失效
+		if m.revocations.IsRevoked(claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("user_id", claims.UserID)
 		c.Set("tenant_id", claims.TenantID)
 		c.Set("email", claims.Email)
+		c.Set("email_verified", claims.EmailVerified)
+		c.Set("session_id", claims.SessionID)
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// RejectImpersonation 拒绝模拟令牌（带 act 声明），用于修改凭据、删除账号等只能由用户本人执行的操作。
+// 需放在 JWTAuth 之后使用
+func (m *AuthMiddleware) RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		if claims, ok := value.(*auth.Claims); ok && claims.Act != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": "This operation is not allowed with an impersonation token",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUserPermission 要求终端用户拥有指定权限，需放在 JWTAuth 之后使用。
+// 优先使用令牌中的权限；令牌因大小上限未携带权限（authz_overflow）时查询数据库
+func (m *AuthMiddleware) RequireUserPermission(requiredPermission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("claims")
+		claims, ok := value.(*auth.Claims)
+		if !exists || !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			c.Abort()
+			return
+		}
+
+		permissions := claims.Permissions
+		if claims.AuthzOverflow {
+			var err error
+			permissions, err = m.permissions.UserPermissions(c.Request.Context(), claims.TenantID, claims.UserID, claims.OrganizationID)
+			if err != nil {
+				slog.Error("Failed to look up user permissions", "user_id", claims.UserID, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Internal server error",
+					"message": "Failed to check permission",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		if !slices.Contains(permissions, requiredPermission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":               "Forbidden",
+				"message":             "Insufficient permissions",
+				"required_permission": requiredPermission,
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
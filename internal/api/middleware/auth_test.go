@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+)
+
+// fakeTenantStore 内存实现的Querier，只实现租户查询
+type fakeTenantStore struct {
+	database.Querier
+	tenants map[string]database.Tenant
+}
+
+func (f *fakeTenantStore) GetTenantByID(ctx context.Context, id string) (database.Tenant, error) {
+	t, ok := f.tenants[id]
+	if !ok {
+		return database.Tenant{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func TestInternalTenantContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &fakeTenantStore{tenants: map[string]database.Tenant{"tnt_test": {ID: "tnt_test"}}}
+	m := &AuthMiddleware{tenantService: tenant.NewService(store, nil)}
+
+	router := gin.New()
+	router.GET("/users", m.InternalTenantContext(), func(c *gin.Context) {
+		tenant := c.MustGet("tenant").(*database.Tenant)
+		c.String(http.StatusOK, tenant.ID)
+	})
+
+	cases := []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"tnt_missing", http.StatusNotFound},
+		{"tnt_test", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		if tc.header != "" {
+			req.Header.Set("X-Tenant-ID", tc.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("X-Tenant-ID %q: expected %d, got %d", tc.header, tc.status, w.Code)
+		}
+		if tc.status == http.StatusOK && w.Body.String() != tc.header {
+			t.Errorf("expected the handler to see tenant %s, got %q", tc.header, w.Body.String())
+		}
+	}
+}
@@ -29,103 +29,111 @@ func NewInternalAuthMiddleware(internalService *internal_service.Service, logger
 // RequireAuth 要求认证中间件
 func (m *InternalAuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		start := time.Now()
-
-		// 从Authorization头获取令牌
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			m.logger.Error("missing authorization header")
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "Unauthorized",
-				"message": "Missing authorization header",
-			})
-			c.Abort()
+		if !m.authenticate(c) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// 验证令牌格式
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			m.logger.Error("invalid authorization header format")
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "Unauthorized",
-				"message": "Invalid authorization header format",
-			})
-			c.Abort()
-			return
-		}
+// authenticate 校验服务令牌并把客户端信息写入上下文，失败时中止请求。
+// 不调用 c.Next()，以便权限中间件在后续处理器执行前完成权限检查
+func (m *InternalAuthMiddleware) authenticate(c *gin.Context) bool {
+	start := time.Now()
+
+	// 从Authorization头获取令牌
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		m.logger.Error("missing authorization header")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "Missing authorization header",
+		})
+		c.Abort()
+		return false
+	}
 
-		// 提取客户端ID
-		clientID, err := m.internalService.ExtractClientIDFromToken(authHeader)
-		if err != nil {
-			m.logger.Error("failed to extract client ID from token", "error", err)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "Unauthorized",
-				"message": "Invalid token",
-			})
-			c.Abort()
-			return
-		}
+	// 验证令牌格式
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		m.logger.Error("invalid authorization header format")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "Invalid authorization header format",
+		})
+		c.Abort()
+		return false
+	}
 
-		// 验证令牌
-		validationReq := internal_service.ValidateTokenRequest{
-			Token: strings.TrimPrefix(authHeader, "Bearer "),
-		}
+	// 提取客户端ID
+	clientID, err := m.internalService.ExtractClientIDFromToken(authHeader)
+	if err != nil {
+		m.logger.Error("failed to extract client ID from token", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "Invalid token",
+		})
+		c.Abort()
+		return false
+	}
 
-		validationResp, err := m.internalService.ValidateToken(c.Request.Context(), validationReq)
-		if err != nil {
-			m.logger.Error("failed to validate token", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Internal server error",
-				"message": "Failed to validate token",
-			})
-			c.Abort()
-			return
-		}
+	// 验证令牌
+	validationReq := internal_service.ValidateTokenRequest{
+		Token: strings.TrimPrefix(authHeader, "Bearer "),
+	}
 
-		if !validationResp.Valid {
-			m.logger.Error("invalid token", "client_id", clientID)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "Unauthorized",
-				"message": validationResp.Message,
-			})
-			c.Abort()
-			return
-		}
+	validationResp, err := m.internalService.ValidateToken(c.Request.Context(), validationReq)
+	if err != nil {
+		m.logger.Error("failed to validate token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "Failed to validate token",
+		})
+		c.Abort()
+		return false
+	}
 
-		// 将客户端信息存储到上下文中
-		c.Set("client_id", clientID)
-		c.Set("scopes", validationResp.Scopes)
+	if !validationResp.Valid {
+		m.logger.Error("invalid token", "client_id", clientID)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": validationResp.Message,
+		})
+		c.Abort()
+		return false
+	}
 
-		// 记录访问日志
-		go func() {
-			responseTime := time.Since(start).Milliseconds()
-			err := m.internalService.LogAccess(
-				c.Request.Context(),
-				clientID,
-				c.Request.URL.Path,
-				c.Request.Method,
-				c.Writer.Status(),
-				int(responseTime),
-				c.ClientIP(),
-				c.Request.UserAgent(),
-				"", // 请求体（可选）
-				"", // 响应体（可选）
-			)
-			if err != nil {
-				m.logger.Error("failed to log access", "error", err)
-			}
-		}()
+	// 将客户端信息存储到上下文中
+	c.Set("client_id", clientID)
+	c.Set("scopes", validationResp.Scopes)
+
+	// 记录访问日志
+	go func() {
+		responseTime := time.Since(start).Milliseconds()
+		err := m.internalService.LogAccess(
+			c.Request.Context(),
+			clientID,
+			c.Request.URL.Path,
+			c.Request.Method,
+			c.Writer.Status(),
+			int(responseTime),
+			c.ClientIP(),
+			c.Request.UserAgent(),
+			"", // 请求体（可选）
+			"", // 响应体（可选）
+		)
+		if err != nil {
+			m.logger.Error("failed to log access", "error", err)
+		}
+	}()
 
-		c.Next()
-	}
+	return true
 }
 
 // RequireScope 要求特定权限的中间件
 func (m *InternalAuthMiddleware) RequireScope(requiredScope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 首先进行认证
-		m.RequireAuth()(c)
-		if c.IsAborted() {
+		if !m.authenticate(c) {
 			return
 		}
 
@@ -177,8 +185,7 @@ func (m *InternalAuthMiddleware) RequireScope(requiredScope string) gin.HandlerF
 func (m *InternalAuthMiddleware) RequireAnyScope(requiredScopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 首先进行认证
-		m.RequireAuth()(c)
-		if c.IsAborted() {
+		if !m.authenticate(c) {
 			return
 		}
 
@@ -233,8 +240,7 @@ func (m *InternalAuthMiddleware) RequireAnyScope(requiredScopes ...string) gin.H
 func (m *InternalAuthMiddleware) RequireAllScopes(requiredScopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 首先进行认证
-		m.RequireAuth()(c)
-		if c.IsAborted() {
+		if !m.authenticate(c) {
 			return
 		}
 
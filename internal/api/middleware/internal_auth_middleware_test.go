@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/internal_service"
+	"yuyu-test/internal/store/database"
+)
+
+// fakeServiceStore 内存实现的内部服务存储，只实现中间件用到的方法
+type fakeServiceStore struct {
+	internal_service.Store
+	scopes map[string][]string
+}
+
+func (f *fakeServiceStore) GetServiceToken(ctx context.Context, tokenHash string) (database.ServiceToken, error) {
+	return database.ServiceToken{TokenHash: tokenHash}, nil
+}
+
+func (f *fakeServiceStore) CheckClientHasScope(ctx context.Context, arg database.CheckClientHasScopeParams) (bool, error) {
+	for _, scope := range f.scopes[arg.ClientID] {
+		if scope == arg.ScopeName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeServiceStore) LogServiceAccess(ctx context.Context, arg database.LogServiceAccessParams) error {
+	return nil
+}
+
+// newTestInternalAuth 返回内部认证中间件和为指定客户端签发服务令牌的函数
+func newTestInternalAuth(t *testing.T, scopes map[string][]string) (*InternalAuthMiddleware, func(clientID string) string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	signer := auth.NewRS256Signer(key, &key.PublicKey)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := internal_service.NewService(&fakeServiceStore{scopes: scopes}, signer, logger, time.Minute, nil, nil)
+	issue := func(clientID string) string {
+		token, err := signer.Sign(jwt.MapClaims{
+			"sub":    clientID,
+			"scopes": scopes[clientID],
+			"exp":    time.Now().Add(time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return token
+	}
+	return NewInternalAuthMiddleware(service, logger), issue
+}
+
+// 权限不足时处理器不能先于权限检查执行
+func TestRequireScopeRunsHandlerOnlyWithScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, issue := newTestInternalAuth(t, map[string][]string{
+		"reader": {"user:read"},
+		"writer": {"user:write"},
+	})
+
+	calls := 0
+	router := gin.New()
+	router.POST("/users", m.RequireScope("user:write"), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusCreated)
+	})
+
+	cases := []struct {
+		clientID string
+		status   int
+		calls    int
+	}{
+		{"reader", http.StatusForbidden, 0},
+		{"writer", http.StatusCreated, 1},
+	}
+	for _, tc := range cases {
+		calls = 0
+		req := httptest.NewRequest(http.MethodPost, "/users", nil)
+		req.Header.Set("Authorization", "Bearer "+issue(tc.clientID))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.status || calls != tc.calls {
+			t.Errorf("%s: expected status %d and %d handler calls, got %d and %d", tc.clientID, tc.status, tc.calls, w.Code, calls)
+		}
+	}
+}
@@ -63,6 +63,12 @@ type Router struct {
 	tenantHandler          *handlers.TenantHandler
 	authHandler            *handlers.AuthHandler
 	userHandler            *handlers.UserHandler
+	roleHandler            *handlers.RoleHandler
+	organizationHandler    *handlers.OrganizationHandler
+	invitationHandler      *handlers.InvitationHandler
+	oidcClientHandler      *handlers.OIDCClientHandler
+	oidcHandler            *handlers.OIDCHandler
+	jwksHandler            *handlers.JWKSHandler
 	authMiddleware         *middleware.AuthMiddleware
 	internalAuthHandler    *handlers.InternalAuthHandler
 	internalServiceHandler *handlers.InternalServiceHandler
@@ -77,6 +83,8 @@ func NewRouter(
 	authMiddleware *middleware.AuthMiddleware,
 	authHandler *handlers.AuthHandler,
 	internalAuthHandler *handlers.InternalAuthHandler,
+	oidcHandler *handlers.OIDCHandler,
+	jwksHandler *handlers.JWKSHandler,
 	internalServiceHandler *handlers.InternalServiceHandler,
 	internalAuthMiddleware *middleware.InternalAuthMiddleware,
 	sqlDB *sql.DB, // 新增参数
@@ -85,6 +93,12 @@ func NewRouter(
 		tenantHandler:          handlers.NewTenantHandler(tenantService),
 		authHandler:            authHandler,
 		userHandler:            handlers.NewUserHandler(userService),
+		roleHandler:            handlers.NewRoleHandler(userService),
+		organizationHandler:    handlers.NewOrganizationHandler(userService),
+		invitationHandler:      handlers.NewInvitationHandler(userService),
+		oidcClientHandler:      handlers.NewOIDCClientHandler(userService),
+		oidcHandler:            oidcHandler,
+		jwksHandler:            jwksHandler,
 		authMiddleware:         authMiddleware,
 		internalAuthHandler:    internalAuthHandler,
 		internalServiceHandler: internalServiceHandler,
@@ -139,6 +153,23 @@ func (r *Router) Setup() *gin.Engine {
 
 	// 对内服务认证API
 	router.POST("/oauth/token", r.internalAuthHandler.Token)
+	router.POST("/oauth/introspect", r.internalAuthHandler.Introspect)
+
+	// 令牌验证公钥（用户令牌、服务令牌分开发布）
+	router.GET("/.well-known/jwks.json", r.jwksHandler.UserKeys)
+	router.GET("/.well-known/service-jwks.json", r.jwksHandler.ServiceKeys)
+
+	// OpenID Provider，每个租户一个 issuer（托管登录页，无需API密钥）；用户令牌使用对称密钥时不启用
+	if r.oidcHandler != nil {
+		oidc := router.Group("/oidc/:tenant_id")
+		oidc.GET("/.well-known/openid-configuration", r.oidcHandler.Discovery)
+		oidc.GET("/authorize", r.oidcHandler.Authorize)
+		oidc.POST("/login", r.oidcHandler.Login)
+		oidc.POST("/mfa", r.oidcHandler.MFA)
+		oidc.POST("/consent", r.oidcHandler.Consent)
+		oidc.GET("/userinfo", r.oidcHandler.UserInfo)
+		oidc.POST("/userinfo", r.oidcHandler.UserInfo)
+	}
 
 	// API版本控制
 	v1 := router.Group("/v1")
@@ -157,21 +188,128 @@ func (r *Router) Setup() *gin.Engine {
 			auth.POST("/register", r.authHandler.Register)
 			auth.POST("/login", r.authHandler.Login)
 			auth.POST("/refresh", r.authHandler.RefreshToken) // 新增refresh token接口
+			auth.POST("/verify-email", r.authHandler.VerifyEmail)
+			auth.POST("/verify-email/resend", r.authHandler.ResendVerificationEmail)
+			auth.POST("/password/forgot", r.authHandler.ForgotPassword)
+			auth.POST("/password/reset", r.authHandler.ResetPassword)
+			auth.POST("/mfa/verify", r.authHandler.VerifyMFA)
+			auth.POST("/mfa/enroll", r.authHandler.EnrollMFA)
+			auth.POST("/passwordless/start", r.authHandler.StartPasswordless)
+			auth.POST("/passwordless/verify", r.authHandler.VerifyPasswordless)
+			auth.POST("/passkey/login/begin", r.authHandler.BeginPasskeyLogin)
+			auth.POST("/passkey/login/finish", r.authHandler.FinishPasskeyLogin)
+			auth.POST("/invitations/accept", r.invitationHandler.Signup)
 		}
 
+		// 退出登录（需要JWT认证）
+		v1.POST("/auth/logout", r.authMiddleware.JWTAuth(), r.authHandler.Logout)
+
 		// 用户管理（需要JWT认证）
 		users := v1.Group("/users")
 		users.Use(r.authMiddleware.JWTAuth())
+		// 凭据、MFA、会话管理和账号数据相关操作只能由用户本人执行
+		noImpersonation := r.authMiddleware.RejectImpersonation()
 		{
 			users.GET("/me", r.userHandler.GetMe)
+			users.PATCH("/me", r.userHandler.UpdateMe)
+			users.PUT("/me/password", noImpersonation, r.userHandler.ChangePassword)
+			users.GET("/me/mfa", r.userHandler.GetMFAStatus)
+			users.POST("/me/mfa/totp", noImpersonation, r.userHandler.EnrollTOTP)
+			users.POST("/me/mfa/totp/confirm", noImpersonation, r.userHandler.ConfirmTOTP)
+			users.DELETE("/me/mfa/totp", noImpersonation, r.userHandler.DisableTOTP)
+			users.POST("/me/mfa/recovery-codes", noImpersonation, r.userHandler.RegenerateRecoveryCodes)
+			users.GET("/me/passkeys", r.userHandler.ListPasskeys)
+			users.POST("/me/passkeys/register/begin", noImpersonation, r.userHandler.BeginPasskeyRegistration)
+			users.POST("/me/passkeys/register/finish", noImpersonation, r.userHandler.FinishPasskeyRegistration)
+			users.DELETE("/me/passkeys/:id", noImpersonation, r.userHandler.DeletePasskey)
+			users.GET("/me/sessions", r.userHandler.ListSessions)
+			users.DELETE("/me/sessions", noImpersonation, r.userHandler.RevokeAllSessions)
+			users.DELETE("/me/sessions/:id", noImpersonation, r.userHandler.RevokeSession)
+			users.GET("/me/export", noImpersonation, r.userHandler.ExportMyData)
+			users.POST("/me/erasure", noImpersonation, r.userHandler.RequestMyErasure)
+			users.GET("/me/permissions", r.roleHandler.GetMyPermissions)
+			users.GET("/me/organizations", r.organizationHandler.ListMyOrganizations)
+			users.POST("/me/organizations/switch", r.organizationHandler.SwitchOrganization)
+			users.POST("/me/invitations/accept", noImpersonation, r.invitationHandler.AcceptInvitation)
+		}
+
+		// 用户查询（保持原有认证方式，租户公钥或私钥均可）
+		readUsers := v1.Group("/users")
+		readUsers.Use(r.authMiddleware.APIKeyAuth())
+		{
+			readUsers.GET("", r.userHandler.GetUsers)
+			readUsers.GET("/:id", r.userHandler.GetUser)
 		}
 
-		// 用户管理（需要API密钥认证）
+		// 用户管理（需要租户私钥认证）
 		adminUsers := v1.Group("/users")
-		adminUsers.Use(r.authMiddleware.APIKeyAuth())
+		adminUsers.Use(r.authMiddleware.APIKeyAuth(), r.authMiddleware.RequireSecretKey())
+		{
+			adminUsers.GET("/search", r.userHandler.SearchUsers)
+			adminUsers.POST("/imports", r.userHandler.ImportUsers)
+			adminUsers.GET("/imports/:id", r.userHandler.GetImportJob)
+			adminUsers.PATCH("/:id", r.userHandler.UpdateUser)
+			adminUsers.DELETE("/:id", r.userHandler.DeleteUser)
+			adminUsers.GET("/:id/export", r.userHandler.ExportUserData)
+			adminUsers.GET("/:id/erasure", r.userHandler.GetUserErasure)
+			adminUsers.POST("/:id/erasure", r.userHandler.RequestUserErasure)
+			adminUsers.DELETE("/:id/erasure", r.userHandler.CancelUserErasure)
+			adminUsers.GET("/:id/roles", r.roleHandler.GetUserRoles)
+			adminUsers.POST("/:id/roles", r.roleHandler.AssignUserRole)
+			adminUsers.DELETE("/:id/roles/:role_id", r.roleHandler.UnassignUserRole)
+		}
+
+		// 角色管理（需要租户私钥认证）
+		adminRoles := v1.Group("/roles")
+		adminRoles.Use(r.authMiddleware.APIKeyAuth(), r.authMiddleware.RequireSecretKey())
 		{
-			adminUsers.GET("", r.userHandler.GetUsers)
-			adminUsers.GET("/:id", r.userHandler.GetUser)
+			adminRoles.GET("", r.roleHandler.ListRoles)
+			adminRoles.POST("", r.roleHandler.CreateRole)
+			adminRoles.GET("/:id", r.roleHandler.GetRole)
+			adminRoles.DELETE("/:id", r.roleHandler.DeleteRole)
+			adminRoles.POST("/:id/permissions", r.roleHandler.AddRolePermissions)
+			adminRoles.DELETE("/:id/permissions/:permission", r.roleHandler.RemoveRolePermission)
+		}
+
+		// 组织管理（需要租户私钥认证）
+		adminOrganizations := v1.Group("/organizations")
+		adminOrganizations.Use(r.authMiddleware.APIKeyAuth(), r.authMiddleware.RequireSecretKey())
+		{
+			adminOrganizations.GET("", r.organizationHandler.ListOrganizations)
+			adminOrganizations.POST("", r.organizationHandler.CreateOrganization)
+			adminOrganizations.GET("/:id", r.organizationHandler.GetOrganization)
+			adminOrganizations.PATCH("/:id", r.organizationHandler.UpdateOrganization)
+			adminOrganizations.DELETE("/:id", r.organizationHandler.DeleteOrganization)
+			adminOrganizations.GET("/:id/members", r.organizationHandler.ListMembers)
+			adminOrganizations.GET("/:id/members/:user_id", r.organizationHandler.GetMember)
+			adminOrganizations.PUT("/:id/members/:user_id", r.organizationHandler.SetMember)
+			adminOrganizations.DELETE("/:id/members/:user_id", r.organizationHandler.RemoveMember)
+			adminOrganizations.GET("/:id/invitations", r.invitationHandler.ListInvitations)
+			adminOrganizations.POST("/:id/invitations", r.invitationHandler.CreateInvitation)
+			adminOrganizations.POST("/:id/invitations/:invitation_id/resend", r.invitationHandler.ResendInvitation)
+			adminOrganizations.DELETE("/:id/invitations/:invitation_id", r.invitationHandler.RevokeInvitation)
+		}
+
+		// 邀请管理（需要租户私钥认证）
+		adminInvitations := v1.Group("/invitations")
+		adminInvitations.Use(r.authMiddleware.APIKeyAuth(), r.authMiddleware.RequireSecretKey())
+		{
+			adminInvitations.GET("", r.invitationHandler.ListInvitations)
+			adminInvitations.POST("", r.invitationHandler.CreateInvitation)
+			adminInvitations.POST("/:invitation_id/resend", r.invitationHandler.ResendInvitation)
+			adminInvitations.DELETE("/:invitation_id", r.invitationHandler.RevokeInvitation)
+		}
+
+		// OIDC 客户端管理（需要租户私钥认证）
+		adminOIDCClients := v1.Group("/oidc/clients")
+		adminOIDCClients.Use(r.authMiddleware.APIKeyAuth(), r.authMiddleware.RequireSecretKey())
+		{
+			adminOIDCClients.GET("", r.oidcClientHandler.ListClients)
+			adminOIDCClients.POST("", r.oidcClientHandler.CreateClient)
+			adminOIDCClients.GET("/:id", r.oidcClientHandler.GetClient)
+			adminOIDCClients.PUT("/:id", r.oidcClientHandler.UpdateClient)
+			adminOIDCClients.DELETE("/:id", r.oidcClientHandler.DeleteClient)
+			adminOIDCClients.POST("/:id/secret", r.oidcClientHandler.ResetClientSecret)
 		}
 
 		// 内部服务管理API
@@ -201,6 +339,9 @@ func (r *Router) Setup() *gin.Engine {
 				authenticated.GET("/:client_id/logs", r.internalServiceHandler.GetServiceAccessLogs)
 				authenticated.GET("/:client_id/statistics", r.internalServiceHandler.GetServiceStatistics)
 
+				// 解除认证失败锁定
+				authenticated.POST("/:client_id/unlock", r.internalServiceHandler.UnlockService)
+
 				// 系统维护
 				authenticated.POST("/cleanup-tokens", r.internalServiceHandler.CleanupExpiredTokens)
 			}
@@ -212,18 +353,38 @@ func (r *Router) Setup() *gin.Engine {
 	{
 		// 用户管理API（需要user:read权限）
 		internalUsers := internalAPI.Group("/users")
-		internalUsers.Use(r.internalAuthMiddleware.RequireScope("user:read"))
+		internalUsers.Use(r.internalAuthMiddleware.RequireScope("user:read"), r.authMiddleware.InternalTenantContext())
 		{
 			internalUsers.GET("", r.userHandler.GetUsers)
+			internalUsers.GET("/search", r.userHandler.SearchUsers)
+			internalUsers.GET("/imports/:id", r.userHandler.GetImportJob)
+			internalUsers.GET("/:id/export", r.userHandler.ExportUserData)
+			internalUsers.GET("/:id/erasure", r.userHandler.GetUserErasure)
+			internalUsers.GET("/:id/roles", r.roleHandler.GetUserRoles)
 			internalUsers.GET("/:id", r.userHandler.GetUser)
 		}
 
 		// 用户写入API（需要user:write权限）
 		internalUserWrite := internalAPI.Group("/users")
-		internalUserWrite.Use(r.internalAuthMiddleware.RequireScope("user:write"))
+		internalUserWrite.Use(r.internalAuthMiddleware.RequireScope("user:write"), r.authMiddleware.InternalTenantContext())
 		{
 			internalUserWrite.POST("", r.userHandler.CreateUser)
+			internalUserWrite.POST("/imports", r.userHandler.ImportUsers)
 			internalUserWrite.PUT("/:id", r.userHandler.UpdateUser)
+			internalUserWrite.PATCH("/:id", r.userHandler.UpdateUser)
+			internalUserWrite.POST("/:id/password-reset", r.userHandler.ForcePasswordReset)
+			internalUserWrite.POST("/:id/unlock", r.userHandler.UnlockUser)
+			internalUserWrite.POST("/:id/roles", r.roleHandler.AssignUserRole)
+			internalUserWrite.DELETE("/:id/roles/:role_id", r.roleHandler.UnassignUserRole)
+		}
+
+		// 用户删除API（需要user:delete权限）
+		internalUserDelete := internalAPI.Group("/users")
+		internalUserDelete.Use(r.internalAuthMiddleware.RequireScope("user:delete"), r.authMiddleware.InternalTenantContext())
+		{
+			internalUserDelete.DELETE("/:id", r.userHandler.DeleteUser)
+			internalUserDelete.POST("/:id/erasure", r.userHandler.RequestUserErasure)
+			internalUserDelete.DELETE("/:id/erasure", r.userHandler.CancelUserErasure)
 		}
 
 		// 租户管理API（需要tenant:read权限）
@@ -232,16 +393,126 @@ func (r *Router) Setup() *gin.Engine {
 		{
 			internalTenants.GET("", r.tenantHandler.GetTenants)
 			internalTenants.GET("/:id", r.tenantHandler.GetTenant)
+			internalTenants.GET("/:id/settings", r.tenantHandler.GetTenantSettings)
+			internalTenants.GET("/:id/profile-schema", r.userHandler.GetProfileSchema)
+			internalTenants.GET("/:id/claims-mapping", r.userHandler.GetClaimsMapping)
+		}
+
+		// 租户配置API（需要tenant:write权限）
+		internalTenantWrite := internalAPI.Group("/tenants")
+		internalTenantWrite.Use(r.internalAuthMiddleware.RequireScope("tenant:write"))
+		{
+			internalTenantWrite.PUT("/:id/settings", r.tenantHandler.UpdateTenantSettings)
+			internalTenantWrite.PUT("/:id/profile-schema", r.userHandler.PublishProfileSchema)
+			internalTenantWrite.POST("/:id/profile-schema/dry-run", r.userHandler.DryRunProfileSchema)
+			internalTenantWrite.PUT("/:id/claims-mapping", r.userHandler.SetClaimsMapping)
+			internalTenantWrite.DELETE("/:id/claims-mapping", r.userHandler.DeleteClaimsMapping)
+		}
+
+		// 角色管理API（需要tenant:read权限）
+		internalRoles := internalAPI.Group("/roles")
+		internalRoles.Use(r.internalAuthMiddleware.RequireScope("tenant:read"), r.authMiddleware.InternalTenantContext())
+		{
+			internalRoles.GET("", r.roleHandler.ListRoles)
+			internalRoles.GET("/:id", r.roleHandler.GetRole)
+		}
+
+		// 角色配置API（需要tenant:write权限）
+		internalRoleWrite := internalAPI.Group("/roles")
+		internalRoleWrite.Use(r.internalAuthMiddleware.RequireScope("tenant:write"), r.authMiddleware.InternalTenantContext())
+		{
+			internalRoleWrite.POST("", r.roleHandler.CreateRole)
+			internalRoleWrite.DELETE("/:id", r.roleHandler.DeleteRole)
+			internalRoleWrite.POST("/:id/permissions", r.roleHandler.AddRolePermissions)
+			internalRoleWrite.DELETE("/:id/permissions/:permission", r.roleHandler.RemoveRolePermission)
+		}
+
+		// 组织查询API（需要user:read权限）
+		internalOrganizations := internalAPI.Group("/organizations")
+		internalOrganizations.Use(r.internalAuthMiddleware.RequireScope("user:read"), r.authMiddleware.InternalTenantContext())
+		{
+			internalOrganizations.GET("", r.organizationHandler.ListOrganizations)
+			internalOrganizations.GET("/:id", r.organizationHandler.GetOrganization)
+			internalOrganizations.GET("/:id/members", r.organizationHandler.ListMembers)
+			internalOrganizations.GET("/:id/members/:user_id", r.organizationHandler.GetMember)
+			internalOrganizations.GET("/:id/invitations", r.invitationHandler.ListInvitations)
+		}
+
+		// 组织写入API（需要user:write权限）
+		internalOrganizationWrite := internalAPI.Group("/organizations")
+		internalOrganizationWrite.Use(r.internalAuthMiddleware.RequireScope("user:write"), r.authMiddleware.InternalTenantContext())
+		{
+			internalOrganizationWrite.POST("", r.organizationHandler.CreateOrganization)
+			internalOrganizationWrite.PATCH("/:id", r.organizationHandler.UpdateOrganization)
+			internalOrganizationWrite.PUT("/:id/members/:user_id", r.organizationHandler.SetMember)
+			internalOrganizationWrite.DELETE("/:id/members/:user_id", r.organizationHandler.RemoveMember)
+			internalOrganizationWrite.POST("/:id/invitations", r.invitationHandler.CreateInvitation)
+			internalOrganizationWrite.POST("/:id/invitations/:invitation_id/resend", r.invitationHandler.ResendInvitation)
+			internalOrganizationWrite.DELETE("/:id/invitations/:invitation_id", r.invitationHandler.RevokeInvitation)
+		}
+
+		// 邀请查询API（需要user:read权限）
+		internalInvitations := internalAPI.Group("/invitations")
+		internalInvitations.Use(r.internalAuthMiddleware.RequireScope("user:read"), r.authMiddleware.InternalTenantContext())
+		{
+			internalInvitations.GET("", r.invitationHandler.ListInvitations)
+		}
+
+		// 邀请写入API（需要user:write权限）
+		internalInvitationWrite := internalAPI.Group("/invitations")
+		internalInvitationWrite.Use(r.internalAuthMiddleware.RequireScope("user:write"), r.authMiddleware.InternalTenantContext())
+		{
+			internalInvitationWrite.POST("", r.invitationHandler.CreateInvitation)
+			internalInvitationWrite.POST("/:invitation_id/resend", r.invitationHandler.ResendInvitation)
+			internalInvitationWrite.DELETE("/:invitation_id", r.invitationHandler.RevokeInvitation)
+		}
+
+		// 组织删除API（需要user:delete权限）
+		internalOrganizationDelete := internalAPI.Group("/organizations")
+		internalOrganizationDelete.Use(r.internalAuthMiddleware.RequireScope("user:delete"), r.authMiddleware.InternalTenantContext())
+		{
+			internalOrganizationDelete.DELETE("/:id", r.organizationHandler.DeleteOrganization)
 		}
 
 		// 认证API（需要auth:token权限）
 		internalAuth := internalAPI.Group("/auth")
 		internalAuth.Use(r.internalAuthMiddleware.RequireScope("auth:token"))
 		{
-			internalAuth.POST("/token", r.authHandler.GenerateToken)
 			internalAuth.POST("/validate", r.authHandler.ValidateToken)
 		}
 
+		// OIDC 客户端API（需要tenant:read权限）
+		internalOIDCClients := internalAPI.Group("/oidc/clients")
+		internalOIDCClients.Use(r.internalAuthMiddleware.RequireScope("tenant:read"), r.authMiddleware.InternalTenantContext())
+		{
+			internalOIDCClients.GET("", r.oidcClientHandler.ListClients)
+			internalOIDCClients.GET("/:id", r.oidcClientHandler.GetClient)
+		}
+
+		// OIDC 客户端配置API（需要tenant:write权限）
+		internalOIDCClientWrite := internalAPI.Group("/oidc/clients")
+		internalOIDCClientWrite.Use(r.internalAuthMiddleware.RequireScope("tenant:write"), r.authMiddleware.InternalTenantContext())
+		{
+			internalOIDCClientWrite.POST("", r.oidcClientHandler.CreateClient)
+			internalOIDCClientWrite.PUT("/:id", r.oidcClientHandler.UpdateClient)
+			internalOIDCClientWrite.DELETE("/:id", r.oidcClientHandler.DeleteClient)
+			internalOIDCClientWrite.POST("/:id/secret", r.oidcClientHandler.ResetClientSecret)
+		}
+
+		// 用户模拟API（需要user:impersonate权限）
+		internalImpersonate := internalAPI.Group("/users")
+		internalImpersonate.Use(r.internalAuthMiddleware.RequireScope("user:impersonate"), r.authMiddleware.InternalTenantContext())
+		{
+			internalImpersonate.POST("/:id/impersonate", r.userHandler.Impersonate)
+		}
+
+		// 模拟审计API（需要user:read权限）
+		internalImpersonations := internalAPI.Group("/impersonations")
+		internalImpersonations.Use(r.internalAuthMiddleware.RequireScope("user:read"), r.authMiddleware.InternalTenantContext())
+		{
+			internalImpersonations.GET("", r.userHandler.ListImpersonations)
+		}
+
 		// 管理API（需要internal:admin权限）
 		internalAdmin := internalAPI.Group("/admin")
 		internalAdmin.Use(r.internalAuthMiddleware.RequireScope("internal:admin"))
@@ -249,6 +520,9 @@ func (r *Router) Setup() *gin.Engine {
 			internalAdmin.GET("/services", r.internalServiceHandler.ListServices)
 			internalAdmin.POST("/services/grant-scope", r.internalServiceHandler.GrantScope)
 			internalAdmin.POST("/services/revoke-scope", r.internalServiceHandler.RevokeScope)
+			internalAdmin.GET("/services/:client_id/token-exchange-policies", r.internalServiceHandler.ListTokenExchangePolicies)
+			internalAdmin.PUT("/services/:client_id/token-exchange-policies/:audience", r.internalServiceHandler.SetTokenExchangePolicy)
+			internalAdmin.DELETE("/services/:client_id/token-exchange-policies/:audience", r.internalServiceHandler.DeleteTokenExchangePolicy)
 		}
 
 		// 复合权限API示例
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrSubjectAudienceMismatch 主体令牌声明了受众，但不包括发起交换的服务
+var ErrSubjectAudienceMismatch = errors.New("subject token was not issued for this client")
+
+// ExchangeRequest 令牌交换请求（RFC 8693），Scopes 由调用方按策略确定
+type ExchangeRequest struct {
+	SubjectToken string
+	// ClientID 发起交换的内部服务，写入新令牌的 act
+	ClientID string
+	// Audience 新令牌的受众（目标服务）
+	Audience string
+	// Scopes 允许携带的权限上限，新令牌携带主体权限与其交集
+	Scopes []string
+	// TTL 新令牌的最长有效期，不超过主体令牌的剩余有效期
+	TTL time.Duration
+}
+
+// ExchangedToken 交换得到的令牌
+type ExchangedToken struct {
+	Token     string
+	ExpiresAt time.Time
+	Scopes    []string
+}
+
+// CheckSubjectAudience 主体令牌声明了受众时，发起交换的服务必须是其中之一
+func CheckSubjectAudience(aud jwt.ClaimStrings, clientID string) error {
+	if len(aud) > 0 && !slices.Contains(aud, clientID) {
+		return ErrSubjectAudienceMismatch
+	}
+	return nil
+}
+
+// ExchangeExpiry 新令牌的过期时间：ttl 之后或主体令牌过期时，取较早者
+func ExchangeExpiry(subjectExpiresAt *jwt.NumericDate, ttl time.Duration) time.Time {
+	expiresAt := time.Now().Add(ttl)
+	if subjectExpiresAt != nil && subjectExpiresAt.Before(expiresAt) {
+		return subjectExpiresAt.Time
+	}
+	return expiresAt
+}
+
+// IntersectScopes 返回 granted 中同时出现在 allowed 中的权限，保持 granted 的顺序
+func IntersectScopes(granted, allowed []string) []string {
+	result := []string{}
+	for _, scope := range granted {
+		if slices.Contains(allowed, scope) && !slices.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
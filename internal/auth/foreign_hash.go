@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// 导入的外部哈希参数上限，避免异常参数使每次登录校验耗尽CPU或内存
+const (
+	maxImportedArgon2Memory     = 256 * 1024 // KiB
+	maxImportedArgon2Iterations = 16
+	maxImportedScryptLogN       = 20
+	maxImportedScryptRP         = 64
+	maxImportedPBKDF2Iterations = 10_000_000
+)
+
+// ValidateHash 检查从其他身份系统导入的哈希能否被 Verify 识别。支持：
+//   - bcrypt：$2a$、$2b$、$2y$
+//   - argon2id、argon2i：PHC字符串 $argon2id$v=19$m=...,t=...,p=...$salt$hash
+//   - scrypt：$scrypt$ln=...,r=...,p=...$salt$hash（passlib格式）
+//   - PBKDF2：$pbkdf2-sha256$i=...$salt$hash（PHC/passlib格式，支持sha1、sha256、sha512）
+//     以及 Django 的 pbkdf2_sha256$iterations$salt$hash
+func ValidateHash(encoded string) error {
+	switch {
+	case strings.HasPrefix(encoded, "$2"):
+		if _, err := bcrypt.Cost([]byte(encoded)); err != nil {
+			return ErrUnsupportedHash
+		}
+		return nil
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, _, _, err := decodeArgon2id(encoded)
+		if err != nil {
+			return err
+		}
+		return checkImportedArgon2(p)
+	default:
+		v, err := decodeForeignHash(encoded)
+		if err != nil {
+			return err
+		}
+		return v.checkLimits()
+	}
+}
+
+func checkImportedArgon2(p Argon2idParams) error {
+	if p.Memory > maxImportedArgon2Memory || p.Iterations > maxImportedArgon2Iterations || p.Iterations < 1 || p.Parallelism < 1 {
+		return fmt.Errorf("%w: argon2 parameters out of range", ErrUnsupportedHash)
+	}
+	return nil
+}
+
+// foreignHash 解析后的外部哈希，derive 根据密码计算与 key 等长的派生值
+type foreignHash struct {
+	key    []byte
+	derive func(password []byte) ([]byte, error)
+	limits func() error
+}
+
+func (f *foreignHash) checkLimits() error {
+	if f.limits == nil {
+		return nil
+	}
+	return f.limits()
+}
+
+// verifyForeignHash 校验外部哈希，匹配时总是需要用当前配置重新哈希
+func verifyForeignHash(password, encoded string) (match, rehash bool) {
+	f, err := decodeForeignHash(encoded)
+	if err != nil || f.checkLimits() != nil {
+		return false, false
+	}
+	candidate, err := f.derive([]byte(password))
+	if err != nil || subtle.ConstantTimeCompare(candidate, f.key) != 1 {
+		return false, false
+	}
+	return true, true
+}
+
+func decodeForeignHash(encoded string) (*foreignHash, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2i$"):
+		return decodeArgon2i(encoded)
+	case strings.HasPrefix(encoded, "$scrypt$"):
+		return decodeScrypt(encoded)
+	case strings.HasPrefix(encoded, "$pbkdf2"):
+		return decodePBKDF2(encoded)
+	case strings.HasPrefix(encoded, "pbkdf2_"):
+		return decodeDjangoPBKDF2(encoded)
+	default:
+		return nil, ErrUnsupportedHash
+	}
+}
+
+// decodeHashBase64 解码哈希中的salt或hash，兼容标准base64（有无填充）和passlib的"ab64"（以 . 代替 +）
+func decodeHashBase64(s string) ([]byte, error) {
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(strings.ReplaceAll(s, ".", "+"), "="))
+	if err != nil || len(b) == 0 {
+		return nil, ErrUnsupportedHash
+	}
+	return b, nil
+}
+
+// parseHashParams 解析 "k=v,k=v" 形式的参数
+func parseHashParams(s string) (map[string]int, error) {
+	params := make(map[string]int)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		n, err := strconv.Atoi(v)
+		if !ok || err != nil || n < 0 {
+			return nil, ErrUnsupportedHash
+		}
+		params[k] = n
+	}
+	return params, nil
+}
+
+func decodeArgon2i(encoded string) (*foreignHash, error) {
+	// 与argon2id的PHC格式相同，只是算法名不同
+	p, salt, key, err := decodeArgon2id(strings.Replace(encoded, "$argon2i$", "$argon2id$", 1))
+	if err != nil {
+		return nil, err
+	}
+	return &foreignHash{
+		key: key,
+		derive: func(password []byte) ([]byte, error) {
+			return argon2.Key(password, salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key))), nil
+		},
+		limits: func() error { return checkImportedArgon2(p) },
+	}, nil
+}
+
+func decodeScrypt(encoded string) (*foreignHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return nil, ErrUnsupportedHash
+	}
+	params, err := parseHashParams(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	logN, r, p := params["ln"], params["r"], params["p"]
+	salt, err := decodeHashBase64(parts[3])
+	if err != nil {
+		return nil, err
+	}
+	key, err := decodeHashBase64(parts[4])
+	if err != nil {
+		return nil, err
+	}
+	return &foreignHash{
+		key: key,
+		derive: func(password []byte) ([]byte, error) {
+			return scrypt.Key(password, salt, 1<<logN, r, p, len(key))
+		},
+		limits: func() error {
+			if logN < 1 || logN > maxImportedScryptLogN || r < 1 || p < 1 || r*p > maxImportedScryptRP {
+				return fmt.Errorf("%w: scrypt parameters out of range", ErrUnsupportedHash)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// pbkdf2Digest 按名称选择PBKDF2使用的摘要算法
+func pbkdf2Digest(name string) (func() hash.Hash, error) {
+	switch name {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, ErrUnsupportedHash
+	}
+}
+
+func newPBKDF2Hash(digest func() hash.Hash, iterations int, salt, key []byte) *foreignHash {
+	return &foreignHash{
+		key: key,
+		derive: func(password []byte) ([]byte, error) {
+			return pbkdf2.Key(password, salt, iterations, len(key), digest), nil
+		},
+		limits: func() error {
+			if iterations < 1 || iterations > maxImportedPBKDF2Iterations {
+				return fmt.Errorf("%w: pbkdf2 iterations out of range", ErrUnsupportedHash)
+			}
+			return nil
+		},
+	}
+}
+
+// decodePBKDF2 解析 $pbkdf2-<digest>$i=<iterations>$salt$hash，passlib 省略 "i="
+func decodePBKDF2(encoded string) (*foreignHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return nil, ErrUnsupportedHash
+	}
+	name, ok := strings.CutPrefix(parts[1], "pbkdf2")
+	if name == "" {
+		name = "-sha1"
+	}
+	digest, err := pbkdf2Digest(strings.TrimPrefix(name, "-"))
+	if !ok || err != nil {
+		return nil, ErrUnsupportedHash
+	}
+	rounds := parts[2]
+	if strings.Contains(rounds, "=") {
+		params, err := parseHashParams(rounds)
+		if err != nil {
+			return nil, err
+		}
+		rounds = strconv.Itoa(params["i"])
+	}
+	iterations, err := strconv.Atoi(rounds)
+	if err != nil {
+		return nil, ErrUnsupportedHash
+	}
+	salt, err := decodeHashBase64(parts[3])
+	if err != nil {
+		return nil, err
+	}
+	key, err := decodeHashBase64(parts[4])
+	if err != nil {
+		return nil, err
+	}
+	return newPBKDF2Hash(digest, iterations, salt, key), nil
+}
+
+// decodeDjangoPBKDF2 解析 Django 的 pbkdf2_<digest>$iterations$salt$hash，salt 按原字符串使用
+func decodeDjangoPBKDF2(encoded string) (*foreignHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[2] == "" {
+		return nil, ErrUnsupportedHash
+	}
+	digest, err := pbkdf2Digest(strings.TrimPrefix(parts[0], "pbkdf2_"))
+	if err != nil {
+		return nil, err
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrUnsupportedHash
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return nil, ErrUnsupportedHash
+	}
+	return newPBKDF2Hash(digest, iterations, []byte(parts[2]), key), nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import "github.com/golang-jwt/jwt/v5"
+
+// 内省结果中的 token_type
+const (
+	TokenTypeBearer  = "Bearer"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// Introspection 令牌内省结果（RFC 7662）。令牌无效时只返回 active=false
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	// Aud 令牌未声明受众时省略
+	Aud jwt.ClaimStrings `json:"aud,omitempty"`
+	// Iss 令牌未声明签发者时省略
+	Iss string `json:"iss,omitempty"`
+	Jti string `json:"jti,omitempty"`
+	// TenantID 用户令牌所属租户（扩展字段）
+	TenantID string `json:"tenant_id,omitempty"`
+	// Act 模拟令牌的实际操作方
+	Act *Actor `json:"act,omitempty"`
+}
+
+// unixTime 将可能为空的 NumericDate 转为秒级时间戳，为空时返回0
+func unixTime(d *jwt.NumericDate) int64 {
+	if d == nil {
+		return 0
+	}
+	return d.Unix()
+}
+
+// SetTimes 从注册声明中填入 exp、iat 和 nbf
+func (i *Introspection) SetTimes(claims jwt.RegisteredClaims) {
+	i.Exp = unixTime(claims.ExpiresAt)
+	i.Iat = unixTime(claims.IssuedAt)
+	i.Nbf = unixTime(claims.NotBefore)
+}
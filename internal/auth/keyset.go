@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrUnknownKeyID JWT 头部的 kid 不在验证密钥中
+	ErrUnknownKeyID = errors.New("unknown key id")
+	// ErrInvalidVerificationKey 验证密钥的类型与签名算法不符
+	ErrInvalidVerificationKey = errors.New("invalid verification key")
+)
+
+// JWK 公钥的 JSON Web Key 表示（RFC 7517）
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS 公钥集合，/.well-known/jwks.json 的响应
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySet 签名器的验证密钥，按 JWT 头部的 kid 选择。
+// 除当前签名密钥外可以加入轮换前的旧密钥，旧密钥签发的令牌在过期前仍然有效
+type KeySet struct {
+	alg string
+	// current 当前签名密钥的 kid
+	current string
+	keys    map[string]interface{}
+	// published 对外发布的公钥，按加入顺序排列，对称密钥不发布
+	published []JWK
+}
+
+func newKeySet(alg string) *KeySet {
+	return &KeySet{alg: alg, keys: map[string]interface{}{}, published: []JWK{}}
+}
+
+// KeyID 当前签名密钥的 kid
+func (k *KeySet) KeyID() string {
+	return k.current
+}
+
+// JWKS 对外发布的公钥，HS256 时为空集合
+func (k *KeySet) JWKS() JWKS {
+	return JWKS{Keys: append([]JWK{}, k.published...)}
+}
+
+// Keyfunc 按 kid 选择验证密钥。没有 kid 的令牌（启用 kid 之前签发）使用当前签名密钥
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = k.current
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+	return key, nil
+}
+
+// add 加入验证密钥，返回其 kid；重复加入同一密钥不产生新条目
+func (k *KeySet) add(key interface{}) (string, error) {
+	var (
+		kid string
+		jwk *JWK
+		err error
+	)
+	switch key := key.(type) {
+	case []byte:
+		if k.alg != "HS256" {
+			return "", ErrInvalidVerificationKey
+		}
+		// 对称密钥不公开，kid 只需稳定且不同密钥不同
+		sum := sha256.Sum256(key)
+		kid = base64.RawURLEncoding.EncodeToString(sum[:12])
+	case *rsa.PublicKey:
+		if k.alg != "RS256" {
+			return "", ErrInvalidVerificationKey
+		}
+		jwk = &JWK{Kty: "RSA", N: encodeBigInt(key.N), E: encodeBigInt(big.NewInt(int64(key.E)))}
+	case *ecdsa.PublicKey:
+		if k.alg != "ES256" || key.Curve != elliptic.P256() {
+			return "", ErrInvalidVerificationKey
+		}
+		jwk = &JWK{Kty: "EC", Crv: "P-256", X: encodeCoordinate(key.X), Y: encodeCoordinate(key.Y)}
+	default:
+		return "", ErrInvalidVerificationKey
+	}
+	if jwk != nil {
+		if kid, err = thumbprint(*jwk); err != nil {
+			return "", err
+		}
+	}
+	if _, exists := k.keys[kid]; exists {
+		return kid, nil
+	}
+	k.keys[kid] = key
+	if jwk != nil {
+		jwk.Use, jwk.Alg, jwk.Kid = "sig", k.alg, kid
+		k.published = append(k.published, *jwk)
+	}
+	return kid, nil
+}
+
+// setSigningKey 加入签名密钥对应的验证密钥并将其设为当前密钥
+func (k *KeySet) setSigningKey(key interface{}) {
+	kid, err := k.add(key)
+	if err != nil {
+		// 签名器构造时密钥类型由构造函数保证
+		panic(err)
+	}
+	k.current = kid
+}
+
+// sign 签名，头部带当前密钥的 kid
+func (k *KeySet) sign(method jwt.SigningMethod, key interface{}, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = k.current
+	return token.SignedString(key)
+}
+
+// parse 校验签名并解析声明，只接受该密钥集的算法
+func (k *KeySet) parse(tokenString string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, k.Keyfunc, jwt.WithValidMethods([]string{k.alg}))
+	return err
+}
+
+// thumbprint JWK SHA-256 指纹（RFC 7638），作为公钥的 kid
+func thumbprint(jwk JWK) (string, error) {
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		return "", ErrInvalidVerificationKey
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// encodeCoordinate P-256 坐标固定为32字节（RFC 7518 6.2.1.2）
+func encodeCoordinate(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, 32)))
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RFC 7638 第3.1节的示例密钥和指纹
+func TestThumbprintRFC7638Vector(t *testing.T) {
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+	}
+	got, err := thumbprint(jwk)
+	if err != nil {
+		t.Fatalf("thumbprint: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Fatalf("thumbprint = %s, want %s", got, want)
+	}
+}
+
+func testClaims() *jwt.RegisteredClaims {
+	return &jwt.RegisteredClaims{Subject: "usr_test", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestSignerKeyRotation(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	oldSigner := NewES256Signer(oldKey, &oldKey.PublicKey)
+	signer := NewES256Signer(newKey, &newKey.PublicKey)
+	oldToken, err := oldSigner.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// 签发的令牌头部带 kid，kid 为公钥指纹，同一密钥稳定不变
+	token, err := signer.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil || parsed.Header["kid"] != signer.Keys().KeyID() {
+		t.Fatalf("expected kid %s in header, got %v (%v)", signer.Keys().KeyID(), parsed.Header["kid"], err)
+	}
+	if NewES256Signer(newKey, &newKey.PublicKey).Keys().KeyID() != signer.Keys().KeyID() {
+		t.Fatal("expected a stable kid for the same key")
+	}
+
+	// 旧密钥签发的令牌在加入旧公钥之前无法校验
+	if err := signer.Parse(oldToken, &jwt.RegisteredClaims{}); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("expected ErrUnknownKeyID, got %v", err)
+	}
+	kid, err := signer.AddVerificationKey(&oldKey.PublicKey)
+	if err != nil || kid != oldSigner.Keys().KeyID() {
+		t.Fatalf("AddVerificationKey = %s, %v", kid, err)
+	}
+	if err := signer.Parse(oldToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("expected token signed by the previous key to verify: %v", err)
+	}
+	if err := signer.Parse(token, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if signer.Keys().KeyID() == kid {
+		t.Fatal("adding a verification key must not change the signing key")
+	}
+
+	jwks := signer.Keys().JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != signer.Keys().KeyID() || jwks.Keys[1].Kid != kid {
+		t.Fatalf("expected current and previous keys to be published, got %+v", jwks)
+	}
+	if k := jwks.Keys[0]; k.Kty != "EC" || k.Crv != "P-256" || k.Alg != "ES256" || k.Use != "sig" || len(k.X) != 43 || len(k.Y) != 43 {
+		t.Fatalf("unexpected jwk: %+v", k)
+	}
+
+	// 公钥类型与算法不符
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := signer.AddVerificationKey(&rsaKey.PublicKey); !errors.Is(err, ErrInvalidVerificationKey) {
+		t.Fatalf("expected ErrInvalidVerificationKey, got %v", err)
+	}
+}
+
+func TestHS256SignerKeys(t *testing.T) {
+	signer := NewHS256Signer("current-secret-for-keyset-tests")
+	old := NewHS256Signer("previous-secret-for-keyset-tests")
+	oldToken, _ := old.Sign(testClaims())
+
+	// 启用 kid 之前签发的令牌没有 kid，使用当前密钥校验
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("current-secret-for-keyset-tests"))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	if err := signer.Parse(legacy, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("expected a token without kid to verify with the current key: %v", err)
+	}
+
+	if _, err := signer.AddVerificationKey("previous-secret-for-keyset-tests"); err != nil {
+		t.Fatalf("AddVerificationKey: %v", err)
+	}
+	if err := signer.Parse(oldToken, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("expected token signed by the previous secret to verify: %v", err)
+	}
+	if jwks := signer.Keys().JWKS(); jwks.Keys == nil || len(jwks.Keys) != 0 {
+		t.Fatalf("expected no published keys for HS256, got %+v", jwks)
+	}
+
+	// 只接受密钥集的算法：用 RS256 签名器的 kid 伪造头部也不能通过
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaToken, _ := NewRS256Signer(rsaKey, &rsaKey.PublicKey).Sign(testClaims())
+	if err := signer.Parse(rsaToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("expected a token signed with another algorithm to be rejected")
+	}
+}
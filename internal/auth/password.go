@@ -1,20 +1,181 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword 使用bcrypt哈希密码
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+// 支持的密码哈希算法
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// ErrUnsupportedHash 无法识别的哈希编码
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// Argon2idParams argon2id参数
+type Argon2idParams struct {
+	// Memory 内存开销，单位KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// HasherConfig 密码哈希配置，Algorithm 决定新哈希使用的算法
+type HasherConfig struct {
+	Algorithm  string
+	Argon2id   Argon2idParams
+	BcryptCost int
+}
+
+// DefaultHasherConfig 默认使用argon2id（OWASP推荐参数：19MiB内存、2次迭代、1并行度）
+func DefaultHasherConfig() HasherConfig {
+	return HasherConfig{
+		Algorithm: AlgorithmArgon2id,
+		Argon2id: Argon2idParams{
+			Memory:      19 * 1024,
+			Iterations:  2,
+			Parallelism: 1,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		BcryptCost: bcrypt.DefaultCost,
+	}
+}
+
+// PasswordHasher 带版本的密码哈希。
+// argon2id 以PHC字符串编码（$argon2id$v=19$m=...,t=...,p=...$salt$hash），
+// bcrypt 沿用其自身的 $2a$/$2b$ 编码。校验时按编码识别算法，
+// 算法或参数与当前配置不一致的哈希（包括导入的scrypt、PBKDF2等外部哈希）会被标记为需要重新哈希
+type PasswordHasher struct {
+	cfg HasherConfig
+	// dummyHash 用于账号不存在时的等价校验
+	dummyHash func() string
+}
+
+// NewPasswordHasher 创建密码哈希器
+func NewPasswordHasher(cfg HasherConfig) (*PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		p := cfg.Argon2id
+		if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 || p.SaltLength < 8 || p.KeyLength < 16 {
+			return nil, fmt.Errorf("invalid argon2id parameters: %+v", p)
+		}
+	case AlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost: %d", cfg.BcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %s", cfg.Algorithm)
+	}
+	h := &PasswordHasher{cfg: cfg}
+	h.dummyHash = sync.OnceValue(func() string {
+		hash, _ := h.Hash("dummy-password-for-timing")
+		return hash
+	})
+	return h, nil
+}
+
+// Hash 按当前配置哈希密码
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	p := h.cfg.Argon2id
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(bytes), nil
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return encodeArgon2id(p, salt, key), nil
+}
+
+// Verify 校验密码是否匹配。匹配且哈希使用的算法或参数已过时时 rehash 为true，
+// 调用方应使用 Hash 重新生成并保存
+func (h *PasswordHasher) Verify(password, encoded string) (match, rehash bool) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false
+		}
+		candidate := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false
+		}
+		p.KeyLength, p.SaltLength = uint32(len(key)), uint32(len(salt))
+		return true, h.cfg.Algorithm != AlgorithmArgon2id || p != h.cfg.Argon2id
+	case strings.HasPrefix(encoded, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return true, err != nil || h.cfg.Algorithm != AlgorithmBcrypt || cost != h.cfg.BcryptCost
+	default:
+		// 从其他身份系统导入的哈希，见 ValidateHash
+		return verifyForeignHash(password, encoded)
+	}
+}
+
+// MaxPasswordBytes 当前算法能完整处理的最大密码字节数，0表示不限制。
+// bcrypt只使用前72字节，更长的密码应当拒绝而不是截断
+func (h *PasswordHasher) MaxPasswordBytes() int {
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		return 72
+	}
+	return 0
+}
+
+// VerifyDummy 账号不存在时同样做一次等开销的校验，使响应时间一致，避免探测账号是否存在
+func (h *PasswordHasher) VerifyDummy(password string) {
+	h.Verify(password, h.dummyHash())
 }
 
-// CheckPassword 验证密码是否匹配
-func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// encodeArgon2id 编码为PHC字符串，salt和hash使用无填充的标准base64
+func encodeArgon2id(p Argon2idParams, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeArgon2id 解析argon2id的PHC字符串
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return p, nil, nil, ErrUnsupportedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnsupportedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnsupportedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnsupportedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnsupportedHash
+	}
+	return p, salt, key, nil
 }
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newHasher(t *testing.T, algorithm string, memory uint32, cost int) *PasswordHasher {
+	t.Helper()
+	cfg := DefaultHasherConfig()
+	cfg.Algorithm = algorithm
+	cfg.Argon2id.Memory = memory
+	cfg.Argon2id.Iterations = 1
+	cfg.BcryptCost = cost
+	h, err := NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewPasswordHasher: %v", err)
+	}
+	return h
+}
+
+func TestArgon2idPHCEncoding(t *testing.T) {
+	h := newHasher(t, AlgorithmArgon2id, 64, bcrypt.MinCost)
+	hash, err := h.Hash("s3cret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") || strings.Count(hash, "$") != 5 {
+		t.Fatalf("unexpected PHC string %q", hash)
+	}
+	if other, _ := h.Hash("s3cret"); other == hash {
+		t.Fatal("expected a random salt per hash")
+	}
+
+	if match, rehash := h.Verify("s3cret", hash); !match || rehash {
+		t.Fatalf("Verify = %v, %v; want match without rehash", match, rehash)
+	}
+	if match, _ := h.Verify("wrong", hash); match {
+		t.Fatal("wrong password should not match")
+	}
+	for _, bad := range []string{"", "plain", "$argon2id$v=19$m=64,t=1,p=1$!!$!!", "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5"} {
+		if match, _ := h.Verify("s3cret", bad); match {
+			t.Fatalf("malformed hash %q should not match", bad)
+		}
+	}
+}
+
+func TestVerifyFlagsOutdatedHashes(t *testing.T) {
+	current := newHasher(t, AlgorithmArgon2id, 128, bcrypt.MinCost)
+	weaker := newHasher(t, AlgorithmArgon2id, 64, bcrypt.MinCost)
+	legacy := newHasher(t, AlgorithmBcrypt, 64, bcrypt.MinCost)
+
+	weakHash, _ := weaker.Hash("s3cret")
+	if match, rehash := current.Verify("s3cret", weakHash); !match || !rehash {
+		t.Fatalf("changed argon2id parameters: Verify = %v, %v; want match and rehash", match, rehash)
+	}
+
+	bcryptHash, _ := legacy.Hash("s3cret")
+	if match, rehash := current.Verify("s3cret", bcryptHash); !match || !rehash {
+		t.Fatalf("bcrypt hash under argon2id config: Verify = %v, %v; want match and rehash", match, rehash)
+	}
+	if match, rehash := legacy.Verify("s3cret", bcryptHash); !match || rehash {
+		t.Fatalf("bcrypt hash under bcrypt config: Verify = %v, %v; want match without rehash", match, rehash)
+	}
+	if legacy.MaxPasswordBytes() != 72 || current.MaxPasswordBytes() != 0 {
+		t.Fatal("only bcrypt should limit password bytes")
+	}
+}
+
+func TestNewPasswordHasherRejectsInvalidConfig(t *testing.T) {
+	cfg := DefaultHasherConfig()
+	cfg.Algorithm = "md5"
+	if _, err := NewPasswordHasher(cfg); err == nil {
+		t.Fatal("expected error for unsupported algorithm")
+	}
+	cfg = DefaultHasherConfig()
+	cfg.Argon2id.Iterations = 0
+	if _, err := NewPasswordHasher(cfg); err == nil {
+		t.Fatal("expected error for zero iterations")
+	}
+	cfg = DefaultHasherConfig()
+	cfg.Algorithm = AlgorithmBcrypt
+	cfg.BcryptCost = 2
+	if _, err := NewPasswordHasher(cfg); err == nil {
+		t.Fatal("expected error for bcrypt cost below minimum")
+	}
+}
+
+func TestVerifyImportedHashes(t *testing.T) {
+	h := newHasher(t, AlgorithmArgon2id, 64, bcrypt.MinCost)
+	// 由其他实现（Python hashlib、argon2参考实现）生成的哈希
+	hashes := map[string]string{
+		"pbkdf2 passlib": "$pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$Pz0s4HPO.TyHnyTxIR8Lx.fEZojc3Lc26DQkf4ZOWCk",
+		"pbkdf2 phc":     "$pbkdf2-sha512$i=1000$MDEyMzQ1Njc4OWFiY2RlZg$I6jnt8bM4J9r711KfpVquZzzOZZLouvfTxmoWgwaE0lSzZcDYtr9Xx67UMVGZQSo8iap1qZ+soOki4c/+3eq/g",
+		"pbkdf2 django":  "pbkdf2_sha256$1000$djangosalt$ouBhjvwNxLXb8Rduzjf0oIjDFHUWkqIOpgX+oeR1KZc=",
+		"scrypt":         "$scrypt$ln=4,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$MomXuMw9YekkEH5CXhpWEZ7aT3P7uIj0zEYtEnXoIY4",
+	}
+	for name, encoded := range hashes {
+		if err := ValidateHash(encoded); err != nil {
+			t.Fatalf("%s: ValidateHash: %v", name, err)
+		}
+		if match, rehash := h.Verify("s3cret", encoded); !match || !rehash {
+			t.Fatalf("%s: Verify = %v, %v; want match and rehash", name, match, rehash)
+		}
+		if match, _ := h.Verify("wrong", encoded); match {
+			t.Fatalf("%s: wrong password should not match", name)
+		}
+	}
+
+	argon2i := "$argon2i$v=19$m=65536,t=2,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"
+	if match, rehash := h.Verify("password", argon2i); !match || !rehash {
+		t.Fatalf("argon2i: Verify = %v, %v; want match and rehash", match, rehash)
+	}
+
+	for _, bad := range []string{
+		"",
+		"md5$abc",
+		"$pbkdf2-md5$1000$c2FsdA$a2V5",
+		"$pbkdf2-sha256$99999999$c2FsdA$a2V5",
+		"$scrypt$ln=30,r=8,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$a2V5",
+		"pbkdf2_sha256$1000$$a2V5",
+	} {
+		if err := ValidateHash(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -7,32 +7,46 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// JWTSigner 签发和校验JWT。签发的令牌头部带当前密钥的 kid，校验时按 kid 选择密钥
 type JWTSigner interface {
 	Sign(claims jwt.Claims) (string, error)
 	Parse(tokenString string, claims jwt.Claims) error
 	Algorithm() string
 	PublicKey() interface{}
 	PrivateKey() interface{}
+	// Keys 验证密钥集合，用于发布 JWKS
+	Keys() *KeySet
+	// AddVerificationKey 加入轮换前的旧密钥（HS256 为 string，RS256、ES256 为对应的公钥），只用于校验
+	AddVerificationKey(key interface{}) (string, error)
 }
 
 type HS256Signer struct {
 	secret string
+	keys   *KeySet
 }
 
 func NewHS256Signer(secret string) *HS256Signer {
-	return &HS256Signer{secret: secret}
+	keys := newKeySet("HS256")
+	keys.setSigningKey([]byte(secret))
+	return &HS256Signer{secret: secret, keys: keys}
 }
 
 func (s *HS256Signer) Sign(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secret))
+	return s.keys.sign(jwt.SigningMethodHS256, []byte(s.secret), claims)
 }
 
 func (s *HS256Signer) Parse(tokenString string, claims jwt.Claims) error {
-	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.secret), nil
-	})
-	return err
+	return s.keys.parse(tokenString, claims)
+}
+
+func (s *HS256Signer) Keys() *KeySet { return s.keys }
+
+func (s *HS256Signer) AddVerificationKey(key interface{}) (string, error) {
+	secret, ok := key.(string)
+	if !ok || secret == "" {
+		return "", ErrInvalidVerificationKey
+	}
+	return s.keys.add([]byte(secret))
 }
 
 func (s *HS256Signer) Algorithm() string { return "HS256" }
@@ -45,22 +59,30 @@ func (s *HS256Signer) PrivateKey() interface{} { return s.secret }
 type RS256Signer struct {
 	privateKey *rsa.PrivateKey
 	publicKey  *rsa.PublicKey
+	keys       *KeySet
 }
 
 func NewRS256Signer(privateKey *rsa.PrivateKey, publicKey *rsa.PublicKey) *RS256Signer {
-	return &RS256Signer{privateKey: privateKey, publicKey: publicKey}
+	keys := newKeySet("RS256")
+	keys.setSigningKey(publicKey)
+	return &RS256Signer{privateKey: privateKey, publicKey: publicKey, keys: keys}
 }
 
 func (s *RS256Signer) Sign(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	return token.SignedString(s.privateKey)
+	return s.keys.sign(jwt.SigningMethodRS256, s.privateKey, claims)
 }
 
 func (s *RS256Signer) Parse(tokenString string, claims jwt.Claims) error {
-	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return s.publicKey, nil
-	})
-	return err
+	return s.keys.parse(tokenString, claims)
+}
+
+func (s *RS256Signer) Keys() *KeySet { return s.keys }
+
+func (s *RS256Signer) AddVerificationKey(key interface{}) (string, error) {
+	if _, ok := key.(*rsa.PublicKey); !ok {
+		return "", ErrInvalidVerificationKey
+	}
+	return s.keys.add(key)
 }
 
 func (s *RS256Signer) Algorithm() string { return "RS256" }
@@ -73,22 +95,30 @@ func (s *RS256Signer) PrivateKey() interface{} { return s.privateKey }
 type ES256Signer struct {
 	privateKey *ecdsa.PrivateKey
 	publicKey  *ecdsa.PublicKey
+	keys       *KeySet
 }
 
 func NewES256Signer(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey) *ES256Signer {
-	return &ES256Signer{privateKey: privateKey, publicKey: publicKey}
+	keys := newKeySet("ES256")
+	keys.setSigningKey(publicKey)
+	return &ES256Signer{privateKey: privateKey, publicKey: publicKey, keys: keys}
 }
 
 func (s *ES256Signer) Sign(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
-	return token.SignedString(s.privateKey)
+	return s.keys.sign(jwt.SigningMethodES256, s.privateKey, claims)
 }
 
 func (s *ES256Signer) Parse(tokenString string, claims jwt.Claims) error {
-	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return s.publicKey, nil
-	})
-	return err
+	return s.keys.parse(tokenString, claims)
+}
+
+func (s *ES256Signer) Keys() *KeySet { return s.keys }
+
+func (s *ES256Signer) AddVerificationKey(key interface{}) (string, error) {
+	if _, ok := key.(*ecdsa.PublicKey); !ok {
+		return "", ErrInvalidVerificationKey
+	}
+	return s.keys.add(key)
 }
 
 func (s *ES256Signer) Algorithm() string { return "ES256" }
@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -16,9 +18,76 @@ type Claims struct {
 	UserID   string `json:"user_id"`
 	TenantID string `json:"tenant_id"`
 	Email    string `json:"email"`
+	// EmailVerified 用户邮箱是否已验证
+	EmailVerified bool `json:"email_verified"`
+	// AMR 认证方式引用（RFC 8176），如 pwd、otp、mfa
+	AMR []string `json:"amr,omitempty"`
+	// SessionID 签发该令牌的登录会话ID
+	SessionID string `json:"sid,omitempty"`
+	// Roles 用户在租户内的角色名
+	Roles []string `json:"roles,omitempty"`
+	// Permissions 用户通过角色获得的全部权限
+	Permissions []string `json:"permissions,omitempty"`
+	// AuthzOverflow 角色和权限超出令牌大小上限而未写入，需通过 /v1/users/me/permissions 查询
+	AuthzOverflow bool `json:"authz_overflow,omitempty"`
+	// OrganizationID 会话当前选择的组织，Roles 和 Permissions 包含用户在该组织内的角色
+	OrganizationID string `json:"org_id,omitempty"`
+	// Act 模拟令牌的实际操作方（RFC 8693），普通令牌为空
+	Act *Actor `json:"act,omitempty"`
+	// ClientID 通过 OpenID Connect 授权码签发时为依赖方的 client_id（RFC 9068）
+	ClientID string `json:"client_id,omitempty"`
+	// Scope 通过 OpenID Connect 授权码签发时为授予的 scope，空格分隔
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IsFirstParty 是否为调用本服务用户接口的访问令牌。登录、刷新和模拟签发的令牌不带受众，
+// 令牌交换签发给其他服务的令牌带 aud，只能由目标服务使用；
+// 签发给 OpenID Connect 依赖方的令牌带 client_id，只能调用 userinfo
+func (c *Claims) IsFirstParty() bool {
+	return len(c.Audience) == 0 && c.ClientID == ""
+}
+
+// Actor 代替用户操作的一方
+type Actor struct {
+	// Subject 操作人，如客服人员
+	Subject string `json:"sub"`
+	// ClientID 发起模拟或令牌交换的内部服务
+	ClientID string `json:"client_id,omitempty"`
+	// Act 主体令牌原有的操作方，令牌多次交换时形成链条，最外层为最近一次
+	Act *Actor `json:"act,omitempty"`
+}
+
+// NewTokenID 生成令牌唯一标识（jti），用于按令牌吊销
+func NewTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// PurposeClaims 一次性/短时用途令牌（如邮箱验证）的声明。
+// 用户ID放在sub中且不带user_id，避免被当作访问令牌使用
+type PurposeClaims struct {
+	Purpose  string `json:"purpose"`
+	TenantID string `json:"tenant_id"`
+	Email    string `json:"email,omitempty"`
+	// AMR 签发该令牌前已完成的认证方式（如MFA挑战中的第一因素）
+	AMR []string `json:"amr,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ParsePurposeToken 解析并校验用途令牌
+func ParsePurposeToken(signer JWTSigner, tokenString, purpose string) (*PurposeClaims, error) {
+	claims := &PurposeClaims{}
+	if err := signer.Parse(tokenString, claims); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.Purpose != purpose || claims.Subject == "" {
+		return nil, errors.New("invalid token purpose")
+	}
+	return claims, nil
+}
+
 // GenerateToken 生成JWT令牌（支持HS256/RS256）
 func GenerateToken(userID, tenantID, email, algorithm string, privateKey string, expiration time.Duration) (string, error) {
 	claims := Claims{
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B的SHA1测试向量（取低6位）
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range cases {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", tc.unix, err)
+		}
+		if got != tc.code {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tc.unix, got, tc.code)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	prev, _ := TOTPCode(secret, TOTPStep(now)-1)
+	if step, ok := ValidateTOTP(secret, prev, now); !ok || step != TOTPStep(now)-1 {
+		t.Fatalf("previous step code should be accepted, got step=%d ok=%v", step, ok)
+	}
+	old, _ := TOTPCode(secret, TOTPStep(now)-3)
+	if _, ok := ValidateTOTP(secret, old, now); ok {
+		t.Fatal("code outside skew window should be rejected")
+	}
+}
@@ -0,0 +1,90 @@
+// Package breach 基于本地泄露密码库的密码筛查。
+// 密码库采用 Have I Been Pwned 的 range 格式离线保存：按 SHA-1 前5位十六进制分文件，
+// 文件名为前缀（如 5BAA6 或 5BAA6.txt），每行为 "剩余35位后缀:出现次数"，
+// 可用官方 PwnedPasswordsDownloader 等工具下载
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefixLen range 文件名使用的哈希前缀长度
+const prefixLen = 5
+
+// Checker 泄露密码检查
+type Checker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// Corpus 目录形式的本地泄露密码库，每次检查只读取对应前缀的一个文件
+type Corpus struct {
+	dir string
+}
+
+// Open 打开泄露密码库目录
+func Open(dir string) (*Corpus, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password corpus: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password corpus %s is not a directory", dir)
+	}
+	return &Corpus{dir: dir}, nil
+}
+
+// IsBreached 密码是否出现在泄露密码库中。
+// 缺少对应前缀的文件视为未泄露；出现次数为0的行是填充数据，同样视为未泄露
+func (c *Corpus) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLen], hash[prefixLen:]
+
+	f, err := c.openRange(prefix)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to open range %s: %w", prefix, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(entry, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		return err == nil && n > 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read range %s: %w", prefix, err)
+	}
+	return false, nil
+}
+
+// openRange 打开前缀对应的文件，兼容带 .txt 扩展名和小写文件名
+func (c *Corpus) openRange(prefix string) (*os.File, error) {
+	var err error
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		var f *os.File
+		f, err = os.Open(filepath.Join(c.dir, name))
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
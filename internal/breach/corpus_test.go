@@ -0,0 +1,44 @@
+package breach
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCorpusIsBreached(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	// SHA-1("123456")   = 7C4A8D09CA3762AF61E59520943DC26494F8941B
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "7C4A8"), []byte("D09CA3762AF61E59520943DC26494F8941B:0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	cases := map[string]bool{
+		"password": true,
+		// 出现次数为0的填充行
+		"123456": false,
+		// 没有对应前缀文件
+		"correct horse battery staple": false,
+	}
+	for password, want := range cases {
+		got, err := corpus.IsBreached(password)
+		if err != nil {
+			t.Fatalf("IsBreached(%q): %v", password, err)
+		}
+		if got != want {
+			t.Errorf("IsBreached(%q) = %v, want %v", password, got, want)
+		}
+	}
+
+	if _, err := Open(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected error for missing corpus directory")
+	}
+}
@@ -0,0 +1,236 @@
+// Package claimsmap 实现租户对用户访问令牌声明的自定义映射：重命名或移除可配置的默认声明，
+// 以及加入取自用户资料字段、角色、权限或静态值的自定义声明。
+//
+// 服务端校验令牌时依赖的声明（user_id、tenant_id、sid 等）和 RFC 7519 注册声明为保留名称，
+// 不能被修改或占用。自定义声明的总大小受 MaxCustomClaimsBytes 限制
+package claimsmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidMapping 映射不是合法的 JSON，或使用了保留名称、未知来源或超出限制
+var ErrInvalidMapping = errors.New("invalid claims mapping")
+
+// 映射限制
+const (
+	// MaxClaims 自定义声明的最大数量
+	MaxClaims = 32
+	// MaxNameLength 声明名的最大长度
+	MaxNameLength = 64
+	// MaxCustomClaimsBytes 单个令牌中自定义声明序列化后的总字节数上限，超出的声明不写入令牌
+	MaxCustomClaimsBytes = 2048
+)
+
+// 自定义声明的取值来源
+const (
+	SourceProfile     = "profile"
+	SourceRoles       = "roles"
+	SourcePermissions = "permissions"
+	SourceStatic      = "static"
+)
+
+var sources = []string{SourceProfile, SourceRoles, SourcePermissions, SourceStatic}
+
+// Reserved 保留的声明名：RFC 7519 注册声明和服务端校验令牌时读取的声明
+var Reserved = []string{
+	"iss", "sub", "aud", "exp", "nbf", "iat", "jti",
+	"user_id", "tenant_id", "sid", "amr", "roles", "permissions", "authz_overflow", "org_id", "purpose", "act",
+	"client_id", "scope",
+}
+
+// Defaults 可以重命名或移除的默认声明
+var Defaults = []string{"email", "email_verified"}
+
+// Mapping 租户的声明映射
+type Mapping struct {
+	// Claims 加入令牌的自定义声明，按顺序写入
+	Claims []Claim `json:"claims,omitempty"`
+	// Rename 默认声明的新名称，如 {"email": "mail"}
+	Rename map[string]string `json:"rename,omitempty"`
+	// Remove 从令牌中移除的默认声明
+	Remove []string `json:"remove,omitempty"`
+}
+
+// Claim 一个自定义声明
+type Claim struct {
+	Name string `json:"name"`
+	// Source 取值来源：profile、roles、permissions 或 static
+	Source string `json:"source"`
+	// Field Source 为 profile 时的资料字段路径，嵌套字段以 . 分隔，如 address.city
+	Field string `json:"field,omitempty"`
+	// Value Source 为 static 时的取值，可以是任意 JSON 值
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Source 自定义声明的取值来源数据
+type Source struct {
+	Profile     map[string]any
+	Roles       []string
+	Permissions []string
+}
+
+// Parse 解析并检查映射
+func Parse(raw []byte) (*Mapping, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var m Mapping
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMapping, err)
+	}
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// check 检查声明名不与保留名称及仍保留在令牌中的默认声明冲突
+func (m *Mapping) check() error {
+	// taken 令牌中已占用的声明名
+	taken := map[string]bool{}
+	for _, name := range Defaults {
+		taken[name] = true
+	}
+	for _, name := range m.Remove {
+		if !slices.Contains(Defaults, name) {
+			return fmt.Errorf("%w: remove: %q is not a configurable default claim", ErrInvalidMapping, name)
+		}
+		delete(taken, name)
+	}
+	for from := range m.Rename {
+		if !slices.Contains(Defaults, from) {
+			return fmt.Errorf("%w: rename: %q is not a configurable default claim", ErrInvalidMapping, from)
+		}
+		if slices.Contains(m.Remove, from) {
+			return fmt.Errorf("%w: rename: %q is also removed", ErrInvalidMapping, from)
+		}
+		delete(taken, from)
+	}
+	claim := func(context, name string) error {
+		if err := checkName(name); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidMapping, context, err)
+		}
+		if taken[name] {
+			return fmt.Errorf("%w: %s: claim %q is already present in the token", ErrInvalidMapping, context, name)
+		}
+		taken[name] = true
+		return nil
+	}
+	// 按名称顺序检查重命名，保证重复时的错误信息稳定
+	froms := make([]string, 0, len(m.Rename))
+	for from := range m.Rename {
+		froms = append(froms, from)
+	}
+	slices.Sort(froms)
+	for _, from := range froms {
+		if err := claim("rename "+from, m.Rename[from]); err != nil {
+			return err
+		}
+	}
+
+	if len(m.Claims) > MaxClaims {
+		return fmt.Errorf("%w: at most %d claims are allowed", ErrInvalidMapping, MaxClaims)
+	}
+	staticBytes := 0
+	for i, c := range m.Claims {
+		context := fmt.Sprintf("claims[%d]", i)
+		if err := claim(context, c.Name); err != nil {
+			return err
+		}
+		if !slices.Contains(sources, c.Source) {
+			return fmt.Errorf("%w: %s: unsupported source %q", ErrInvalidMapping, context, c.Source)
+		}
+		if (c.Source == SourceProfile) != (c.Field != "") {
+			return fmt.Errorf("%w: %s: field is required for and only allowed with the profile source", ErrInvalidMapping, context)
+		}
+		if (c.Source == SourceStatic) != (len(c.Value) > 0) {
+			return fmt.Errorf("%w: %s: value is required for and only allowed with the static source", ErrInvalidMapping, context)
+		}
+		staticBytes += len(c.Value)
+	}
+	if staticBytes > MaxCustomClaimsBytes {
+		return fmt.Errorf("%w: static values exceed %d bytes", ErrInvalidMapping, MaxCustomClaimsBytes)
+	}
+	return nil
+}
+
+func checkName(name string) error {
+	if name == "" || len(name) > MaxNameLength {
+		return fmt.Errorf("claim name must be 1-%d characters", MaxNameLength)
+	}
+	if slices.Contains(Reserved, name) {
+		return fmt.Errorf("claim name %q is reserved", name)
+	}
+	return nil
+}
+
+// Apply 按映射修改 claims 中的默认声明并加入自定义声明。取值为空（资料字段不存在或为 null）的声明不写入；
+// 自定义声明累计超出 MaxCustomClaimsBytes 时，之后的声明不写入，返回未写入的声明名
+func (m *Mapping) Apply(claims map[string]any, src Source) (skipped []string) {
+	for _, name := range m.Remove {
+		delete(claims, name)
+	}
+	for from, to := range m.Rename {
+		if v, ok := claims[from]; ok {
+			delete(claims, from)
+			claims[to] = v
+		}
+	}
+
+	size := 0
+	for _, c := range m.Claims {
+		value, ok := c.value(src)
+		if !ok {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		// 名称、引号、冒号和逗号
+		n := len(c.Name) + 4 + len(encoded)
+		if size+n > MaxCustomClaimsBytes {
+			skipped = append(skipped, c.Name)
+			continue
+		}
+		size += n
+		claims[c.Name] = json.RawMessage(encoded)
+	}
+	return skipped
+}
+
+// value 从来源中取出声明的值
+func (c Claim) value(src Source) (any, bool) {
+	switch c.Source {
+	case SourceProfile:
+		var v any = src.Profile
+		for _, key := range strings.Split(c.Field, ".") {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			v = obj[key]
+		}
+		return v, v != nil
+	case SourceRoles:
+		return nonNil(src.Roles), true
+	case SourcePermissions:
+		return nonNil(src.Permissions), true
+	case SourceStatic:
+		return c.Value, true
+	}
+	return nil, false
+}
+
+// nonNil 空列表写为 []
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
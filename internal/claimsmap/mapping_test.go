@@ -0,0 +1,94 @@
+package claimsmap
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRejectsInvalidMappings(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":         `{"claims": [], "extra": true}`,
+		"reserved name":         `{"claims": [{"name": "sub", "source": "static", "value": "x"}]}`,
+		"service claim":         `{"claims": [{"name": "tenant_id", "source": "static", "value": "x"}]}`,
+		"default still present": `{"claims": [{"name": "email", "source": "profile", "field": "work_email"}]}`,
+		"duplicate name":        `{"claims": [{"name": "a", "source": "roles"}, {"name": "a", "source": "permissions"}]}`,
+		"unknown source":        `{"claims": [{"name": "a", "source": "session"}]}`,
+		"profile without field": `{"claims": [{"name": "a", "source": "profile"}]}`,
+		"static without value":  `{"claims": [{"name": "a", "source": "static"}]}`,
+		"field on roles":        `{"claims": [{"name": "a", "source": "roles", "field": "x"}]}`,
+		"rename reserved":       `{"rename": {"email": "user_id"}}`,
+		"rename non default":    `{"rename": {"user_id": "uid"}}`,
+		"remove non default":    `{"remove": ["tenant_id"]}`,
+		"rename and remove":     `{"rename": {"email": "mail"}, "remove": ["email"]}`,
+		"static too large":      `{"claims": [{"name": "a", "source": "static", "value": "` + strings.Repeat("x", MaxCustomClaimsBytes) + `"}]}`,
+	}
+	for name, raw := range cases {
+		if _, err := Parse([]byte(raw)); !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("%s: expected ErrInvalidMapping, got %v", name, err)
+		}
+	}
+
+	// 移除或重命名后默认声明的名称可以被自定义声明使用
+	if _, err := Parse([]byte(`{"rename": {"email": "mail"}, "claims": [{"name": "email", "source": "profile", "field": "work_email"}]}`)); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	m, err := Parse([]byte(`{
+		"rename": {"email": "mail"},
+		"remove": ["email_verified"],
+		"claims": [
+			{"name": "city", "source": "profile", "field": "address.city"},
+			{"name": "missing", "source": "profile", "field": "address.zip"},
+			{"name": "groups", "source": "roles"},
+			{"name": "plan", "source": "static", "value": {"tier": "pro"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	claims := map[string]any{"user_id": "usr_1", "email": "kai@example.com", "email_verified": true}
+	skipped := m.Apply(claims, Source{
+		Profile: map[string]any{"address": map[string]any{"city": "Berlin"}},
+		Roles:   []string{"editor"},
+	})
+	if len(skipped) != 0 {
+		t.Fatalf("unexpected skipped claims: %v", skipped)
+	}
+	encoded, _ := json.Marshal(claims)
+	var got map[string]any
+	json.Unmarshal(encoded, &got)
+	want := map[string]any{
+		"user_id": "usr_1",
+		"mail":    "kai@example.com",
+		"city":    "Berlin",
+		"groups":  []any{"editor"},
+		"plan":    map[string]any{"tier": "pro"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected claims:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestApplyEnforcesSizeLimit(t *testing.T) {
+	m, err := Parse([]byte(`{"claims": [
+		{"name": "bio", "source": "profile", "field": "bio"},
+		{"name": "plan", "source": "static", "value": "pro"}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	claims := map[string]any{}
+	skipped := m.Apply(claims, Source{Profile: map[string]any{"bio": strings.Repeat("x", MaxCustomClaimsBytes)}})
+	if !reflect.DeepEqual(skipped, []string{"bio"}) {
+		t.Fatalf("expected bio to be skipped, got %v", skipped)
+	}
+	if _, ok := claims["plan"]; !ok {
+		t.Fatal("expected claims within the limit to be kept")
+	}
+}
@@ -9,18 +9,39 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	DatabaseURL            string
-	JWTAlgorithm           string // 新增，HS256/RS256
-	JWTUserSecret          string // HS256密钥
-	JWTServiceSecret       string // HS256密钥
-	JWTUserPrivateKey      string // RS256私钥PEM内容
-	JWTUserPublicKey       string // RS256公钥PEM内容
-	JWTServicePrivateKey   string // RS256私钥PEM内容
-	JWTServicePublicKey    string // RS256公钥PEM内容
-	UserTokenExpiration    int    // 单位秒
-	ServiceTokenExpiration int    // 单位秒
-	Port                   int
-	Environment            string
+	DatabaseURL             string
+	JWTAlgorithm            string // 新增，HS256/RS256
+	JWTUserSecret           string // HS256密钥
+	JWTServiceSecret        string // HS256密钥
+	JWTUserPrivateKey       string // RS256私钥PEM内容
+	JWTUserPublicKey        string // RS256公钥PEM内容
+	JWTServicePrivateKey    string // RS256私钥PEM内容
+	JWTServicePublicKey     string // RS256公钥PEM内容
+	UserTokenExpiration     int    // 单位秒
+	ServiceTokenExpiration  int    // 单位秒
+	Port                    int
+	Environment             string
+	SMTPHost                string // 为空时使用日志邮件发送器
+	SMTPPort                int
+	SMTPUsername            string
+	SMTPPassword            string
+	SMTPFrom                string
+	LockoutAccountThreshold int    // 单个账号连续登录失败锁定阈值，0表示不限制
+	LockoutIPThreshold      int    // 单个IP认证失败锁定阈值
+	LockoutClientThreshold  int    // 单个client_id认证失败锁定阈值
+	LockoutDuration         int    // 锁定时长，单位秒
+	BreachedPasswordsDir    string // 泄露密码库目录（HIBP range格式），为空时不检查
+	PasswordHashAlgorithm   string // 新密码哈希算法，argon2id（默认）或bcrypt
+	Argon2Memory            int    // argon2id内存开销，单位KiB
+	Argon2Iterations        int
+	Argon2Parallelism       int
+	BcryptCost              int
+	PublicURL               string // 对外访问地址，OIDC issuer 的前缀，不带结尾斜杠
+	PasskeyDecoySecret      string // 为不存在的账号生成Passkey诱饵凭证的密钥，为空时每次启动随机生成
+
+	// 轮换前的JWT密钥，只用于校验：HS256为密钥，RS256/ES256为公钥PEM内容或文件路径
+	JWTUserPreviousKeys    []string
+	JWTServicePreviousKeys []string
 }
 
 // JWTConfigValidator 定义算法校验接口
@@ -107,6 +128,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList 获取逗号分隔的环境变量，忽略空项
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // Load 从环境变量加载配置
 func Load() (*Config, error) {
 	port, err := strconv.Atoi(getEnv("PORT", "8080"))
@@ -117,6 +149,21 @@ func Load() (*Config, error) {
 	userTokenExp, _ := strconv.Atoi(getEnv("USER_TOKEN_EXPIRATION", "3600"))      // 默认1小时
 	serviceTokenExp, _ := strconv.Atoi(getEnv("SERVICE_TOKEN_EXPIRATION", "300")) // 默认5分钟
 
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+	}
+
+	lockoutAccount, _ := strconv.Atoi(getEnv("LOCKOUT_ACCOUNT_THRESHOLD", "5"))
+	lockoutIP, _ := strconv.Atoi(getEnv("LOCKOUT_IP_THRESHOLD", "50"))
+	lockoutClient, _ := strconv.Atoi(getEnv("LOCKOUT_CLIENT_THRESHOLD", "10"))
+	lockoutDuration, _ := strconv.Atoi(getEnv("LOCKOUT_DURATION", "900")) // 默认15分钟
+
+	argon2Memory, _ := strconv.Atoi(getEnv("ARGON2_MEMORY", "19456")) // 默认19MiB
+	argon2Iterations, _ := strconv.Atoi(getEnv("ARGON2_ITERATIONS", "2"))
+	argon2Parallelism, _ := strconv.Atoi(getEnv("ARGON2_PARALLELISM", "1"))
+	bcryptCost, _ := strconv.Atoi(getEnv("BCRYPT_COST", "10"))
+
 	algorithm := strings.ToUpper(getEnv("JWT_ALGORITHM", "HS256"))
 
 	userSecret := getEnv("JWT_USER_SECRET_KEY", "")
@@ -133,20 +180,41 @@ func Load() (*Config, error) {
 	userPubKey := getEnv("JWT_USER_PUBLIC_KEY", "")
 	servicePrivKey := getEnv("JWT_SERVICE_PRIVATE_KEY", "")
 	servicePubKey := getEnv("JWT_SERVICE_PUBLIC_KEY", "")
+	userPreviousKeys := getEnvList("JWT_USER_PREVIOUS_KEYS")
+	servicePreviousKeys := getEnvList("JWT_SERVICE_PREVIOUS_KEYS")
 
 	config := &Config{
-		DatabaseURL:            getEnv("DATABASE_URL", ""),
-		JWTAlgorithm:           algorithm,
-		JWTUserSecret:          userSecret,
-		JWTServiceSecret:       serviceSecret,
-		JWTUserPrivateKey:      userPrivKey,
-		JWTUserPublicKey:       userPubKey,
-		JWTServicePrivateKey:   servicePrivKey,
-		JWTServicePublicKey:    servicePubKey,
-		UserTokenExpiration:    userTokenExp,
-		ServiceTokenExpiration: serviceTokenExp,
-		Port:                   port,
-		Environment:            getEnv("GO_ENV", "development"),
+		DatabaseURL:             getEnv("DATABASE_URL", ""),
+		JWTAlgorithm:            algorithm,
+		JWTUserSecret:           userSecret,
+		JWTServiceSecret:        serviceSecret,
+		JWTUserPrivateKey:       userPrivKey,
+		JWTUserPublicKey:        userPubKey,
+		JWTServicePrivateKey:    servicePrivKey,
+		JWTServicePublicKey:     servicePubKey,
+		JWTUserPreviousKeys:     userPreviousKeys,
+		JWTServicePreviousKeys:  servicePreviousKeys,
+		UserTokenExpiration:     userTokenExp,
+		ServiceTokenExpiration:  serviceTokenExp,
+		Port:                    port,
+		Environment:             getEnv("GO_ENV", "development"),
+		SMTPHost:                getEnv("SMTP_HOST", ""),
+		SMTPPort:                smtpPort,
+		SMTPUsername:            getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:            getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:                getEnv("SMTP_FROM", "no-reply@localhost"),
+		LockoutAccountThreshold: lockoutAccount,
+		LockoutIPThreshold:      lockoutIP,
+		LockoutClientThreshold:  lockoutClient,
+		LockoutDuration:         lockoutDuration,
+		BreachedPasswordsDir:    getEnv("BREACHED_PASSWORDS_DIR", ""),
+		PasswordHashAlgorithm:   strings.ToLower(getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")),
+		Argon2Memory:            argon2Memory,
+		Argon2Iterations:        argon2Iterations,
+		Argon2Parallelism:       argon2Parallelism,
+		BcryptCost:              bcryptCost,
+		PublicURL:               strings.TrimRight(getEnv("PUBLIC_URL", fmt.Sprintf("http://localhost:%d", port)), "/"),
+		PasskeyDecoySecret:      getEnv("PASSKEY_DECOY_SECRET", ""),
 	}
 
 	if config.DatabaseURL == "" {
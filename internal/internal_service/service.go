@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -14,9 +15,9 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sqlc-dev/pqtype"
-	"golang.org/x/crypto/bcrypt"
 
 	"yuyu-test/internal/auth"
+	"yuyu-test/internal/lockout"
 	"yuyu-test/internal/store/database"
 )
 
@@ -26,6 +27,8 @@ type Service struct {
 	signer          auth.JWTSigner
 	logger          *slog.Logger
 	tokenExpiration time.Duration
+	guard           *lockout.Guard
+	hasher          *auth.PasswordHasher
 }
 
 // Store 数据存储接口
@@ -54,15 +57,22 @@ type Store interface {
 	RevokeServiceToken(ctx context.Context, tokenHash string) error
 	CleanupExpiredTokens(ctx context.Context) error
 	GetClientStatistics(ctx context.Context, arg database.GetClientStatisticsParams) (database.GetClientStatisticsRow, error)
+	RehashInternalClientSecret(ctx context.Context, arg database.RehashInternalClientSecretParams) error
+	GetTokenExchangePolicy(ctx context.Context, arg database.GetTokenExchangePolicyParams) (database.TokenExchangePolicy, error)
+	ListTokenExchangePolicies(ctx context.Context, clientID string) ([]database.TokenExchangePolicy, error)
+	UpsertTokenExchangePolicy(ctx context.Context, arg database.UpsertTokenExchangePolicyParams) (database.TokenExchangePolicy, error)
+	DeleteTokenExchangePolicy(ctx context.Context, arg database.DeleteTokenExchangePolicyParams) (int64, error)
 }
 
 // NewService 创建内部服务管理服务实例
-func NewService(store Store, signer auth.JWTSigner, logger *slog.Logger, tokenExpiration time.Duration) *Service {
+func NewService(store Store, signer auth.JWTSigner, logger *slog.Logger, tokenExpiration time.Duration, guard *lockout.Guard, hasher *auth.PasswordHasher) *Service {
 	return &Service{
 		store:           store,
 		signer:          signer,
 		logger:          logger,
 		tokenExpiration: tokenExpiration,
+		guard:           guard,
+		hasher:          hasher,
 	}
 }
 
@@ -91,7 +101,7 @@ func (s *Service) RegisterService(ctx context.Context, req RegisterServiceReques
 		s.logger.Error("failed to generate client secret", "error", err)
 		return nil, fmt.Errorf("failed to generate client secret: %w", err)
 	}
-	hashedSecret, err := bcrypt.GenerateFromPassword([]byte(clientSecret), bcrypt.DefaultCost)
+	hashedSecret, err := s.hasher.Hash(clientSecret)
 	if err != nil {
 		s.logger.Error("failed to hash client secret", "error", err)
 		return nil, fmt.Errorf("failed to hash client secret: %w", err)
@@ -99,7 +109,7 @@ func (s *Service) RegisterService(ctx context.Context, req RegisterServiceReques
 	// 创建内部客户端
 	client, err := s.store.CreateInternalClient(ctx, database.CreateInternalClientParams{
 		ClientID:         clientID,
-		ClientSecretHash: string(hashedSecret),
+		ClientSecretHash: hashedSecret,
 		ServiceName:      req.ServiceName,
 		Description:      sql.NullString{String: req.Description, Valid: req.Description != ""},
 	})
@@ -133,20 +143,35 @@ type AuthenticateServiceResponse struct {
 	Scopes      []string `json:"scopes"`
 }
 
-// AuthenticateService 认证内部服务并颁发JWT令牌
-func (s *Service) AuthenticateService(ctx context.Context, req AuthenticateServiceRequest) (*AuthenticateServiceResponse, error) {
+// AuthenticateService 认证内部服务并颁发JWT令牌。
+// 失败按 client_id 和来源IP计数，超过阈值后暂时锁定
+func (s *Service) AuthenticateService(ctx context.Context, req AuthenticateServiceRequest, clientIP, userAgent string) (*AuthenticateServiceResponse, error) {
+	keys := []lockout.Key{lockout.ClientKey(req.ClientID), lockout.IPKey(clientIP)}
+	if err := s.guard.Check(ctx, keys...); err != nil {
+		s.logger.Warn("client authentication locked", "client_id", req.ClientID, "client_ip", clientIP)
+		return nil, err
+	}
+
 	// 获取内部客户端
 	client, err := s.store.GetInternalClient(ctx, req.ClientID)
 	if err != nil {
 		s.logger.Error("failed to get internal client", "error", err, "client_id", req.ClientID)
-		return nil, fmt.Errorf("invalid client credentials")
+		// 客户端不存在时同样做一次哈希比较，使响应时间一致
+		s.hasher.VerifyDummy(req.ClientSecret)
+		return nil, s.authenticationFailed(ctx, keys, clientIP, userAgent)
 	}
 
 	// 验证客户端密钥
-	err = bcrypt.CompareHashAndPassword([]byte(client.ClientSecretHash), []byte(req.ClientSecret))
-	if err != nil {
-		s.logger.Error("invalid client secret", "error", err, "client_id", req.ClientID)
-		return nil, fmt.Errorf("invalid client credentials")
+	match, rehash := s.hasher.Verify(req.ClientSecret, client.ClientSecretHash)
+	if !match {
+		s.logger.Error("invalid client secret", "client_id", req.ClientID)
+		return nil, s.authenticationFailed(ctx, keys, clientIP, userAgent)
+	}
+	if rehash {
+		RehashClientSecret(ctx, s.store, s.hasher, s.logger, client, req.ClientSecret)
+	}
+	if err := s.guard.Succeed(ctx, keys[0]); err != nil {
+		return nil, err
 	}
 
 	// 获取客户端权限
@@ -200,6 +225,50 @@ func (s *Service) AuthenticateService(ctx context.Context, req AuthenticateServi
 	}, nil
 }
 
+// OAuthIssuer /oauth/token 签发的服务令牌的 iss
+const OAuthIssuer = "https://auth.yoursaas.com"
+
+// ErrInvalidClientCredentials 客户端不存在或密钥错误（不区分两者）
+var ErrInvalidClientCredentials = errors.New("invalid client credentials")
+
+// RehashClientSecret 认证通过后用当前哈希配置重新哈希过时的客户端密钥，失败只记日志
+// /oauth/token 与服务认证接口共用
+func RehashClientSecret(ctx context.Context, store Store, hasher *auth.PasswordHasher, logger *slog.Logger, client database.InternalClient, secret string) {
+	hash, err := hasher.Hash(secret)
+	if err == nil {
+		err = store.RehashInternalClientSecret(ctx, database.RehashInternalClientSecretParams{
+			NewHash:  hash,
+			ClientID: client.ClientID,
+			OldHash:  client.ClientSecretHash,
+		})
+	}
+	if err != nil {
+		logger.Error("failed to rehash client secret", "error", err, "client_id", client.ClientID)
+		return
+	}
+	logger.Info("client secret rehashed", "client_id", client.ClientID)
+}
+
+// authenticationFailed 记录一次客户端认证失败，统一返回 ErrInvalidClientCredentials
+func (s *Service) authenticationFailed(ctx context.Context, keys []lockout.Key, clientIP, userAgent string) error {
+	if err := s.guard.Fail(ctx, clientIP, userAgent, keys...); err != nil {
+		return err
+	}
+	return ErrInvalidClientCredentials
+}
+
+// UnlockClient 解除客户端的认证锁定
+func (s *Service) UnlockClient(ctx context.Context, clientID string) error {
+	if _, err := s.store.GetInternalClient(ctx, clientID); err != nil {
+		return fmt.Errorf("client not found: %w", err)
+	}
+	if err := s.guard.Unlock(ctx, lockout.ClientKey(clientID)); err != nil {
+		return err
+	}
+	s.logger.Info("client authentication unlocked", "client_id", clientID)
+	return nil
+}
+
 // ValidateTokenRequest 令牌验证请求
 type ValidateTokenRequest struct {
 	Token string `json:"token" binding:"required"`
@@ -215,7 +284,7 @@ type ValidateTokenResponse struct {
 
 // ValidateToken 验证JWT令牌
 func (s *Service) ValidateToken(ctx context.Context, req ValidateTokenRequest) (*ValidateTokenResponse, error) {
-	claims, err := parseServiceJWTToken(req.Token, s.signer.Algorithm(), s.signer.PublicKey())
+	claims, err := parseServiceJWTToken(s.signer, req.Token)
 	if err != nil {
 		return &ValidateTokenResponse{
 			Valid:   false,
@@ -429,10 +498,59 @@ func (s *Service) GenerateClientSecret() (string, error) {
 
 // hashToken 哈希令牌用于存储
 func (s *Service) hashToken(token string) string {
+	return HashToken(token)
+}
+
+// HashToken 计算服务令牌在 service_tokens 中保存的哈希
+func HashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return base64.URLEncoding.EncodeToString(hash[:])
 }
 
+// IntrospectServiceToken 内省服务令牌（RFC 7662）：签名有效、已登记且未过期未撤销、所属客户端仍启用时有效。
+// 令牌不是服务令牌或已失效时返回 nil；只有查询失败才返回错误。scope 取签发时登记的权限
+// /oauth/introspect 使用，/oauth/token 和服务认证接口签发的令牌都适用
+func IntrospectServiceToken(ctx context.Context, store Store, signer auth.JWTSigner, token string) (*auth.Introspection, error) {
+	claims := &serviceClaims{}
+	if err := signer.Parse(token, claims); err != nil || claims.Subject == "" {
+		return nil, nil
+	}
+	stored, err := store.GetServiceToken(ctx, HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service token: %w", err)
+	}
+	if stored.ClientID != claims.Subject {
+		return nil, nil
+	}
+	if _, err := store.GetInternalClient(ctx, stored.ClientID); errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get internal client: %w", err)
+	}
+	info := &auth.Introspection{
+		Active:    true,
+		Scope:     strings.Join(stored.Scopes, " "),
+		ClientID:  stored.ClientID,
+		TokenType: auth.TokenTypeBearer,
+		Sub:       stored.ClientID,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		Act:       claims.Act,
+	}
+	info.SetTimes(claims.RegisteredClaims)
+	return info, nil
+}
+
+// serviceClaims 服务令牌中内省和交换用到的声明，scope/scopes 取自登记记录
+type serviceClaims struct {
+	Act *auth.Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
 // LogAccess 记录服务访问日志
 func (s *Service) LogAccess(ctx context.Context, clientID, endpoint, method string, statusCode int, responseTimeMs int, ipAddress, userAgent, requestBody, responseBody string) error {
 	return s.store.LogServiceAccess(ctx, database.LogServiceAccessParams{
@@ -480,19 +598,11 @@ func (s *Service) ExtractClientIDFromToken(tokenString string) (string, error) {
 	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 
 	// 解析JWT令牌
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return s.signer.PublicKey(), nil
-	})
-
-	if err != nil || !token.Valid {
+	claims := jwt.MapClaims{}
+	if err := s.signer.Parse(tokenString, claims); err != nil {
 		return "", fmt.Errorf("invalid token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", fmt.Errorf("invalid token claims")
-	}
-
 	clientID, ok := claims["sub"].(string)
 	if !ok {
 		return "", fmt.Errorf("missing client ID in token")
@@ -509,38 +619,13 @@ func getSigningMethod(alg string) jwt.SigningMethod {
 	return jwt.SigningMethodHS256
 }
 
-func parseServiceJWTToken(tokenString string, alg string, publicKey interface{}) (*jwt.MapClaims, error) {
-	var keyFunc jwt.Keyfunc
-	if alg == "RS256" {
-		keyFunc = func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return publicKey, nil
-		}
-	} else if alg == "ES256" {
-		keyFunc = func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return publicKey, nil
-		}
-	} else {
-		keyFunc = func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return publicKey, nil
-		}
-	}
-	parsed, err := jwt.Parse(tokenString, keyFunc)
-	if err != nil {
+// parseServiceJWTToken 按 kid 选择密钥校验服务令牌
+func parseServiceJWTToken(signer auth.JWTSigner, tokenString string) (*jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if err := signer.Parse(tokenString, claims); err != nil {
 		return nil, err
 	}
-	if claims, ok := parsed.Claims.(jwt.MapClaims); ok && parsed.Valid {
-		return &claims, nil
-	}
-	return nil, fmt.Errorf("invalid token")
+	return &claims, nil
 }
 
 // generateRandomID 生成唯一 client_id
@@ -0,0 +1,195 @@
+package internal_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 令牌交换（RFC 8693）的授权类型和令牌类型
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// 策略允许的主体令牌类型
+const (
+	// SubjectTypeUser 终端用户访问令牌
+	SubjectTypeUser = "user"
+	// SubjectTypeService 服务令牌
+	SubjectTypeService = "service"
+)
+
+// ExchangedTokenTTL 交换得到的令牌的最长有效期
+const ExchangedTokenTTL = 5 * time.Minute
+
+var (
+	// ErrClientNotFound 内部客户端不存在或已停用
+	ErrClientNotFound = errors.New("client not found")
+	// ErrTokenExchangePolicyNotFound 客户端没有该受众的令牌交换策略
+	ErrTokenExchangePolicyNotFound = errors.New("token exchange policy not found")
+	// ErrInvalidTokenExchangePolicy 策略中的主体类型或权限不合法
+	ErrInvalidTokenExchangePolicy = errors.New("invalid token exchange policy")
+	// ErrScopeNotAllowed 请求的权限超出策略允许的范围
+	ErrScopeNotAllowed = errors.New("requested scope is not allowed by the token exchange policy")
+)
+
+// TokenExchangePolicyRequest 设置令牌交换策略请求
+type TokenExchangePolicyRequest struct {
+	// SubjectTypes 允许交换的主体令牌类型：user、service
+	SubjectTypes []string `json:"subject_types" binding:"required,min=1,dive,oneof=user service"`
+	// Scopes 交换后的令牌最多携带的权限
+	Scopes []string `json:"scopes" binding:"dive,required,max=255"`
+}
+
+// TokenExchangePolicyResponse 令牌交换策略
+type TokenExchangePolicyResponse struct {
+	ClientID     string    `json:"client_id"`
+	Audience     string    `json:"audience"`
+	SubjectTypes []string  `json:"subject_types"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func toTokenExchangePolicyResponse(p database.TokenExchangePolicy) *TokenExchangePolicyResponse {
+	return &TokenExchangePolicyResponse{
+		ClientID:     p.ClientID,
+		Audience:     p.Audience,
+		SubjectTypes: p.SubjectTypes,
+		Scopes:       p.Scopes,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
+
+// ListTokenExchangePolicies 列出客户端的令牌交换策略
+func (s *Service) ListTokenExchangePolicies(ctx context.Context, clientID string) ([]*TokenExchangePolicyResponse, error) {
+	policies, err := s.store.ListTokenExchangePolicies(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token exchange policies: %w", err)
+	}
+	result := make([]*TokenExchangePolicyResponse, 0, len(policies))
+	for _, p := range policies {
+		result = append(result, toTokenExchangePolicyResponse(p))
+	}
+	return result, nil
+}
+
+// SetTokenExchangePolicy 设置客户端为 audience 交换令牌的策略，已存在时整体替换
+func (s *Service) SetTokenExchangePolicy(ctx context.Context, clientID, audience string, req TokenExchangePolicyRequest) (*TokenExchangePolicyResponse, error) {
+	if audience == "" || len(audience) > 255 {
+		return nil, fmt.Errorf("%w: audience must be 1-255 characters", ErrInvalidTokenExchangePolicy)
+	}
+	for _, t := range req.SubjectTypes {
+		if t != SubjectTypeUser && t != SubjectTypeService {
+			return nil, fmt.Errorf("%w: unsupported subject type %q", ErrInvalidTokenExchangePolicy, t)
+		}
+	}
+	if _, err := s.store.GetInternalClient(ctx, clientID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrClientNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get internal client: %w", err)
+	}
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	policy, err := s.store.UpsertTokenExchangePolicy(ctx, database.UpsertTokenExchangePolicyParams{
+		ClientID:     clientID,
+		Audience:     audience,
+		SubjectTypes: slices.Compact(slices.Sorted(slices.Values(req.SubjectTypes))),
+		Scopes:       scopes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save token exchange policy: %w", err)
+	}
+	s.logger.Info("token exchange policy updated", "client_id", clientID, "audience", audience)
+	return toTokenExchangePolicyResponse(policy), nil
+}
+
+// DeleteTokenExchangePolicy 删除客户端为 audience 交换令牌的策略
+func (s *Service) DeleteTokenExchangePolicy(ctx context.Context, clientID, audience string) error {
+	n, err := s.store.DeleteTokenExchangePolicy(ctx, database.DeleteTokenExchangePolicyParams{ClientID: clientID, Audience: audience})
+	if err != nil {
+		return fmt.Errorf("failed to delete token exchange policy: %w", err)
+	}
+	if n == 0 {
+		return ErrTokenExchangePolicyNotFound
+	}
+	s.logger.Info("token exchange policy deleted", "client_id", clientID, "audience", audience)
+	return nil
+}
+
+// TokenExchangeScopes 查找客户端为 audience 交换令牌的策略，返回新令牌允许携带的权限：
+// 未请求权限时为策略的全部权限，否则请求的权限都必须在策略范围内
+// /oauth/token 的令牌交换使用
+func TokenExchangeScopes(ctx context.Context, store Store, clientID, audience string, requested []string) (database.TokenExchangePolicy, []string, error) {
+	policy, err := store.GetTokenExchangePolicy(ctx, database.GetTokenExchangePolicyParams{ClientID: clientID, Audience: audience})
+	if errors.Is(err, sql.ErrNoRows) {
+		return policy, nil, ErrTokenExchangePolicyNotFound
+	}
+	if err != nil {
+		return policy, nil, fmt.Errorf("failed to get token exchange policy: %w", err)
+	}
+	if len(requested) == 0 {
+		return policy, policy.Scopes, nil
+	}
+	for _, scope := range requested {
+		if !slices.Contains(policy.Scopes, scope) {
+			return policy, nil, fmt.Errorf("%w: %s", ErrScopeNotAllowed, scope)
+		}
+	}
+	return policy, requested, nil
+}
+
+// ExchangeServiceToken 以服务令牌为主体交换出发给目标服务的令牌（RFC 8693）。
+// 新令牌的 sub 仍为主体服务，权限收窄为主体权限与 req.Scopes 的交集，aud 为目标服务，
+// act 为发起交换的服务并链接主体令牌原有的 act；新令牌同样登记，可以被撤销和内省。
+// 主体不是有效的服务令牌时返回 nil；主体令牌的受众不包括发起交换的服务时返回 auth.ErrSubjectAudienceMismatch
+func ExchangeServiceToken(ctx context.Context, store Store, signer auth.JWTSigner, req auth.ExchangeRequest) (*auth.ExchangedToken, error) {
+	subject, err := IntrospectServiceToken(ctx, store, signer, req.SubjectToken)
+	if err != nil || subject == nil {
+		return nil, err
+	}
+	if err := auth.CheckSubjectAudience(subject.Aud, req.ClientID); err != nil {
+		return nil, err
+	}
+
+	scopes := auth.IntersectScopes(strings.Fields(subject.Scope), req.Scopes)
+	expiresAt := auth.ExchangeExpiry(jwt.NewNumericDate(time.Unix(subject.Exp, 0)), req.TTL)
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   subject.Sub,
+		"iss":   OAuthIssuer,
+		"aud":   req.Audience,
+		"scope": strings.Join(scopes, " "),
+		"act":   &auth.Actor{Subject: req.ClientID, ClientID: req.ClientID, Act: subject.Act},
+		"jti":   auth.NewTokenID(),
+		"iat":   now.Unix(),
+		"exp":   expiresAt.Unix(),
+	}
+	token, err := signer.Sign(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+	if err := store.StoreServiceToken(ctx, database.StoreServiceTokenParams{
+		ClientID:  subject.Sub,
+		TokenHash: HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store service token: %w", err)
+	}
+	return &auth.ExchangedToken{Token: token, ExpiresAt: expiresAt, Scopes: scopes}, nil
+}
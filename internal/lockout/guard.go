@@ -0,0 +1,254 @@
+// Package lockout 登录和客户端凭证的暴力破解防护。
+// 按账号、IP、client_id 分别统计失败次数：超过一半阈值后每次失败都要求等待一段
+// 逐次翻倍的时间，达到阈值后锁定一段时间。计数保存在数据库中，多实例共享
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/store/database"
+)
+
+// 计数维度
+const (
+	ScopeAccount = "account"
+	ScopeIP      = "ip"
+	ScopeClient  = "client"
+	// ScopeMFAChallenge 单个二次验证挑战，达到阈值后挑战作废
+	ScopeMFAChallenge = "mfa_challenge"
+)
+
+// SecurityEventLocked 达到阈值被锁定时记录的安全事件类型
+const SecurityEventLocked = "lockout"
+
+// ErrLocked 尝试过于频繁或已被锁定。对存在和不存在的账号返回相同的错误，避免泄露账号是否存在
+var ErrLocked = errors.New("too many failed attempts, please try again later")
+
+// LockedError 带有可重试时间的锁定错误，errors.Is(err, ErrLocked) 为true
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string { return ErrLocked.Error() }
+
+func (e *LockedError) Is(target error) bool { return target == ErrLocked }
+
+// Policy 单个维度的锁定策略
+type Policy struct {
+	// Threshold 观察窗口内失败达到该次数后锁定，0表示不限制
+	Threshold int
+	// LockoutDuration 锁定时长，同时作为失败计数的观察窗口
+	LockoutDuration time.Duration
+	// BaseDelay 渐进延迟的基数，失败次数超过一半阈值后每次翻倍，0表示只在达到阈值时锁定
+	BaseDelay time.Duration
+}
+
+// delay 第 failures 次失败后需要等待的时间
+func (p Policy) delay(failures int) time.Duration {
+	if p.Threshold <= 0 {
+		return 0
+	}
+	if failures >= p.Threshold {
+		return p.LockoutDuration
+	}
+	free := p.Threshold / 2
+	if failures <= free || p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay << (failures - free - 1)
+	if d <= 0 || d > p.LockoutDuration {
+		return p.LockoutDuration
+	}
+	return d
+}
+
+// Key 一个计数对象
+type Key struct {
+	Scope   string
+	Subject string
+	// TenantID 账号所属租户，仅用于记录安全事件
+	TenantID string
+}
+
+// AccountKey 账号维度，按租户和邮箱计数，不要求账号存在
+func AccountKey(tenantID, email string) Key {
+	return Key{Scope: ScopeAccount, Subject: tenantID + ":" + strings.ToLower(email), TenantID: tenantID}
+}
+
+// IPKey 来源IP维度
+func IPKey(ip string) Key {
+	return Key{Scope: ScopeIP, Subject: ip}
+}
+
+// MFAChallengeKey 二次验证挑战维度，按挑战令牌的 jti 计数
+func MFAChallengeKey(challengeID string) Key {
+	return Key{Scope: ScopeMFAChallenge, Subject: challengeID}
+}
+
+// ClientKey 内部客户端维度
+func ClientKey(clientID string) Key {
+	return Key{Scope: ScopeClient, Subject: clientID}
+}
+
+// Store 锁定计数需要的数据访问，database.Querier 已实现
+type Store interface {
+	GetAuthFailure(ctx context.Context, arg database.GetAuthFailureParams) (database.AuthFailure, error)
+	RecordAuthFailure(ctx context.Context, arg database.RecordAuthFailureParams) (database.AuthFailure, error)
+	LockAuthFailure(ctx context.Context, arg database.LockAuthFailureParams) error
+	ClearAuthFailure(ctx context.Context, arg database.ClearAuthFailureParams) error
+	CleanupAuthFailures(ctx context.Context, lastFailureAt time.Time) error
+	CreateSecurityEvent(ctx context.Context, arg database.CreateSecurityEventParams) error
+}
+
+// Guard 暴力破解防护
+type Guard struct {
+	store    Store
+	policies map[string]Policy
+}
+
+// NewGuard 创建防护，policies 按维度（ScopeAccount 等）配置，未配置的维度不限制
+func NewGuard(store Store, policies map[string]Policy) *Guard {
+	return &Guard{store: store, policies: policies}
+}
+
+// DefaultPolicies 默认策略：账号5次、IP 50次、客户端10次，锁定15分钟
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		ScopeAccount: {Threshold: 5, LockoutDuration: 15 * time.Minute, BaseDelay: time.Second},
+		ScopeIP:      {Threshold: 50, LockoutDuration: 15 * time.Minute, BaseDelay: time.Second},
+		ScopeClient:  {Threshold: 10, LockoutDuration: 15 * time.Minute, BaseDelay: time.Second},
+	}
+}
+
+// Check 校验是否允许尝试，任一维度处于等待或锁定期时返回 *LockedError
+func (g *Guard) Check(ctx context.Context, keys ...Key) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, k := range keys {
+		if k.Subject == "" {
+			continue
+		}
+		row, err := g.store.GetAuthFailure(ctx, database.GetAuthFailureParams{Scope: k.Scope, Subject: k.Subject})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return fmt.Errorf("failed to get auth failures: %w", err)
+		}
+		if row.LockedUntil.Valid && row.LockedUntil.Time.After(now) {
+			retryAfter = max(retryAfter, row.LockedUntil.Time.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail 记录一次失败，按策略设置等待或锁定时间；达到阈值时记录安全事件
+func (g *Guard) Fail(ctx context.Context, clientIP, userAgent string, keys ...Key) error {
+	for _, k := range keys {
+		policy, ok := g.policies[k.Scope]
+		if !ok || policy.Threshold <= 0 || k.Subject == "" {
+			continue
+		}
+		row, err := g.store.RecordAuthFailure(ctx, database.RecordAuthFailureParams{
+			Scope:         k.Scope,
+			Subject:       k.Subject,
+			LastFailureAt: time.Now().Add(-policy.LockoutDuration),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record auth failure: %w", err)
+		}
+
+		delay := policy.delay(int(row.Failures))
+		if delay == 0 {
+			continue
+		}
+		if err := g.store.LockAuthFailure(ctx, database.LockAuthFailureParams{
+			Scope:       k.Scope,
+			Subject:     k.Subject,
+			LockedUntil: sql.NullTime{Time: time.Now().Add(delay), Valid: true},
+		}); err != nil {
+			return fmt.Errorf("failed to lock: %w", err)
+		}
+		if int(row.Failures) == policy.Threshold {
+			g.recordLockout(ctx, k, int(row.Failures), policy.LockoutDuration, clientIP, userAgent)
+		}
+	}
+	return nil
+}
+
+// Succeed 认证成功后清除计数
+func (g *Guard) Succeed(ctx context.Context, keys ...Key) error {
+	for _, k := range keys {
+		if err := g.Unlock(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unlock 清除计数并解除锁定（管理员解锁）
+func (g *Guard) Unlock(ctx context.Context, k Key) error {
+	if err := g.store.ClearAuthFailure(ctx, database.ClearAuthFailureParams{Scope: k.Scope, Subject: k.Subject}); err != nil {
+		return fmt.Errorf("failed to clear auth failures: %w", err)
+	}
+	return nil
+}
+
+// recordLockout 记录锁定安全事件，失败只记日志
+func (g *Guard) recordLockout(ctx context.Context, k Key, failures int, duration time.Duration, clientIP, userAgent string) {
+	slog.Warn("Authentication locked out", "scope", k.Scope, "subject", k.Subject, "failures", failures, "client_ip", clientIP)
+
+	details, _ := json.Marshal(map[string]any{
+		"scope":           k.Scope,
+		"subject":         k.Subject,
+		"failures":        failures,
+		"lockout_seconds": int(duration.Seconds()),
+	})
+	if err := g.store.CreateSecurityEvent(ctx, database.CreateSecurityEventParams{
+		TenantID:  sql.NullString{String: k.TenantID, Valid: k.TenantID != ""},
+		EventType: SecurityEventLocked,
+		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+		Details:   details,
+	}); err != nil {
+		slog.Error("Failed to record security event", "event_type", SecurityEventLocked, "error", err)
+	}
+}
+
+// Prune 删除观察窗口外且未处于锁定期的计数
+func (g *Guard) Prune(ctx context.Context) error {
+	var window time.Duration
+	for _, p := range g.policies {
+		window = max(window, p.LockoutDuration)
+	}
+	if err := g.store.CleanupAuthFailures(ctx, time.Now().Add(-window)); err != nil {
+		return fmt.Errorf("failed to prune auth failures: %w", err)
+	}
+	return nil
+}
+
+// Run 按 interval 周期清理过期计数，直到ctx取消
+func (g *Guard) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := g.Prune(ctx); err != nil {
+				slog.Error("Failed to prune auth failures", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/store/database"
+)
+
+// fakeStore 内存实现的计数存储
+type fakeStore struct {
+	rows   map[Key]database.AuthFailure
+	events []database.CreateSecurityEventParams
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{rows: map[Key]database.AuthFailure{}}
+}
+
+func (f *fakeStore) GetAuthFailure(ctx context.Context, arg database.GetAuthFailureParams) (database.AuthFailure, error) {
+	row, ok := f.rows[Key{Scope: arg.Scope, Subject: arg.Subject}]
+	if !ok {
+		return database.AuthFailure{}, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+func (f *fakeStore) RecordAuthFailure(ctx context.Context, arg database.RecordAuthFailureParams) (database.AuthFailure, error) {
+	k := Key{Scope: arg.Scope, Subject: arg.Subject}
+	row, ok := f.rows[k]
+	if !ok || row.LastFailureAt.Before(arg.LastFailureAt) {
+		row = database.AuthFailure{Scope: arg.Scope, Subject: arg.Subject, LockedUntil: row.LockedUntil}
+	}
+	row.Failures++
+	row.LastFailureAt = time.Now()
+	f.rows[k] = row
+	return row, nil
+}
+
+func (f *fakeStore) LockAuthFailure(ctx context.Context, arg database.LockAuthFailureParams) error {
+	k := Key{Scope: arg.Scope, Subject: arg.Subject}
+	row := f.rows[k]
+	row.LockedUntil = arg.LockedUntil
+	f.rows[k] = row
+	return nil
+}
+
+func (f *fakeStore) ClearAuthFailure(ctx context.Context, arg database.ClearAuthFailureParams) error {
+	delete(f.rows, Key{Scope: arg.Scope, Subject: arg.Subject})
+	return nil
+}
+
+func (f *fakeStore) CleanupAuthFailures(ctx context.Context, lastFailureAt time.Time) error {
+	for k, row := range f.rows {
+		if row.LastFailureAt.Before(lastFailureAt) && (!row.LockedUntil.Valid || row.LockedUntil.Time.Before(time.Now())) {
+			delete(f.rows, k)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) CreateSecurityEvent(ctx context.Context, arg database.CreateSecurityEventParams) error {
+	f.events = append(f.events, arg)
+	return nil
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{Threshold: 10, LockoutDuration: 15 * time.Minute, BaseDelay: time.Second}
+	cases := map[int]time.Duration{
+		1:  0,
+		5:  0,
+		6:  time.Second,
+		7:  2 * time.Second,
+		9:  8 * time.Second,
+		10: 15 * time.Minute,
+		30: 15 * time.Minute,
+	}
+	for failures, want := range cases {
+		if got := p.delay(failures); got != want {
+			t.Errorf("delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+	if got := (Policy{}).delay(100); got != 0 {
+		t.Errorf("zero threshold should never delay, got %v", got)
+	}
+}
+
+func TestGuardLocksAndUnlocks(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	guard := NewGuard(store, map[string]Policy{
+		ScopeAccount: {Threshold: 4, LockoutDuration: time.Minute, BaseDelay: time.Second},
+	})
+	account := AccountKey("tnt_test", "Lee@Example.com")
+	ip := IPKey("10.0.0.1")
+
+	// 前一半失败不延迟，IP维度未配置策略不计数
+	for i := 0; i < 2; i++ {
+		if err := guard.Fail(ctx, "10.0.0.1", "", account, ip); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+	}
+	if err := guard.Check(ctx, account, ip); err != nil {
+		t.Fatalf("expected no delay below half the threshold, got %v", err)
+	}
+	if _, ok := store.rows[ip]; ok {
+		t.Fatal("scopes without a policy should not be counted")
+	}
+
+	if err := guard.Fail(ctx, "10.0.0.1", "", account); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	var locked *LockedError
+	if err := guard.Check(ctx, account); !errors.As(err, &locked) || locked.RetryAfter > time.Second {
+		t.Fatalf("expected a progressive delay of at most 1s, got %v", err)
+	}
+	if len(store.events) != 0 {
+		t.Fatal("delays should not be recorded as lockouts")
+	}
+
+	if err := guard.Fail(ctx, "10.0.0.1", "", account); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	if err := guard.Check(ctx, AccountKey("tnt_test", "lee@example.com")); !errors.As(err, &locked) || locked.RetryAfter <= time.Second {
+		t.Fatalf("expected lockout at threshold regardless of email case, got %v", err)
+	}
+	if len(store.events) != 1 || store.events[0].TenantID.String != "tnt_test" || store.events[0].EventType != SecurityEventLocked {
+		t.Fatalf("expected one lockout security event, got %+v", store.events)
+	}
+
+	if err := guard.Unlock(ctx, account); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := guard.Check(ctx, account); err != nil {
+		t.Fatalf("expected unlocked, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// Message 待发送的邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口，业务层只依赖该接口，便于替换为SMTP、日志或测试捕获实现
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer 只把邮件写入日志，用于本地开发（邮件正文可能包含令牌，禁止用于生产环境）
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+// NewLogMailer 创建日志邮件发送器
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send 记录邮件内容
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Info("mail sent (log mailer)", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// SMTPMailer 通过SMTP发送邮件
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer 创建SMTP邮件发送器
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send 发送纯文本邮件
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + m.from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	if err := smtp.SendMail(addr, auth, m.from, []string{msg.To}, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
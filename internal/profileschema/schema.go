@@ -0,0 +1,295 @@
+// Package profileschema 实现租户用于约束用户资料（profile）的 JSON Schema 子集。
+//
+// 支持的关键字：type（单一类型）、properties、required、additionalProperties（布尔值）、
+// maxProperties、items、minItems、maxItems、minLength、maxLength、minimum、maximum、
+// pattern、format（email、date、date-time、uri、uuid）、enum 和 readOnly。
+// 出现其他关键字时 Parse 返回错误，避免租户误以为不支持的约束已经生效
+package profileschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidSchema schema 不是合法的 JSON，或使用了不支持的关键字、类型或格式
+var ErrInvalidSchema = errors.New("invalid profile schema")
+
+// 支持的类型
+var types = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// formats 支持的 format 及其校验函数
+var formats = map[string]func(string) bool{
+	"email": func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	},
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	},
+	"uuid": uuidPattern.MatchString,
+}
+
+// Schema 解析后的 schema 节点
+type Schema struct {
+	// 注释性关键字，不参与校验
+	SchemaURI   string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Default     any    `json:"default,omitempty"`
+
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	// ReadOnly 只能由内部服务或租户后端写入，终端用户不能修改
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Violation 一条校验失败，Field 为出错的字段路径，如 address.city、tags[0]
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Parse 解析并检查 schema，根节点必须是 object 类型
+func Parse(raw []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var s Schema
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	if s.Type != "object" {
+		return nil, fmt.Errorf("%w: root type must be \"object\"", ErrInvalidSchema)
+	}
+	if err := s.compile("profile"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// compile 检查节点并预编译 pattern
+func (s *Schema) compile(path string) error {
+	if s.Type != "" && !slices.Contains(types, s.Type) {
+		return fmt.Errorf("%w: %s: unsupported type %q", ErrInvalidSchema, path, s.Type)
+	}
+	if s.Format != "" && formats[s.Format] == nil {
+		return fmt.Errorf("%w: %s: unsupported format %q", ErrInvalidSchema, path, s.Format)
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: %s: invalid pattern: %v", ErrInvalidSchema, path, err)
+		}
+		s.pattern = re
+	}
+	for _, n := range []*int{s.MaxProperties, s.MinItems, s.MaxItems, s.MinLength, s.MaxLength} {
+		if n != nil && *n < 0 {
+			return fmt.Errorf("%w: %s: size limits must not be negative", ErrInvalidSchema, path)
+		}
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("%w: %s: property must be a schema", ErrInvalidSchema, joinField(path, name))
+		}
+		if err := prop.compile(joinField(path, name)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+	return nil
+}
+
+func joinField(path, name string) string {
+	if path == "profile" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Validate 校验资料，返回全部违规项。profile 应为 JSON 解码得到的值，nil 视为空对象
+func (s *Schema) Validate(profile map[string]any) []Violation {
+	if profile == nil {
+		profile = map[string]any{}
+	}
+	var violations []Violation
+	s.validate("profile", profile, &violations)
+	return violations
+}
+
+func (s *Schema) validate(path string, value any, out *[]Violation) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, Violation{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && !hasType(value, s.Type) {
+		fail("must be of type %s", s.Type)
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return reflect.DeepEqual(e, value) }) {
+		fail("must be one of the allowed values")
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if s.MaxProperties != nil && len(v) > *s.MaxProperties {
+			fail("must have at most %d properties", *s.MaxProperties)
+		}
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*out = append(*out, Violation{Field: joinField(path, name), Message: "is required"})
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(joinField(path, name), v[name], out)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*out = append(*out, Violation{Field: joinField(path, name), Message: "is not allowed"})
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(path+"["+strconv.Itoa(i)+"]", item, out)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match pattern %s", s.Pattern)
+		}
+		if s.Format != "" && !formats[s.Format](v) {
+			fail("must be a valid %s", s.Format)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	}
+}
+
+// hasType 判断 JSON 解码得到的值是否属于指定类型
+func hasType(value any, typ string) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return typ == "object"
+	case []any:
+		return typ == "array"
+	case string:
+		return typ == "string"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == math.Trunc(v))
+	case bool:
+		return typ == "boolean"
+	case nil:
+		return typ == "null"
+	default:
+		return false
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// ApplyReadOnly 处理终端用户提交的资料：省略的只读字段保留 before 中的原值（写入 after），
+// 与原值不同的只读字段作为违规项返回。after 为nil时不做处理
+func (s *Schema) ApplyReadOnly(before, after map[string]any) []Violation {
+	if after == nil {
+		return nil
+	}
+	var violations []Violation
+	s.applyReadOnly("profile", before, after, &violations)
+	return violations
+}
+
+func (s *Schema) applyReadOnly(path string, before, after map[string]any, out *[]Violation) {
+	for _, name := range sortedKeys(toAnyMap(s.Properties)) {
+		prop := s.Properties[name]
+		old, hadOld := before[name]
+		value, hasValue := after[name]
+		switch {
+		case prop.ReadOnly && !hasValue:
+			if hadOld {
+				after[name] = old
+			}
+		case prop.ReadOnly:
+			if !hadOld || !reflect.DeepEqual(old, value) {
+				*out = append(*out, Violation{Field: joinField(path, name), Message: "is read-only"})
+			}
+		default:
+			// 嵌套对象中的只读字段
+			nested, ok := value.(map[string]any)
+			if ok && len(prop.Properties) > 0 {
+				oldNested, _ := old.(map[string]any)
+				prop.applyReadOnly(joinField(path, name), oldNested, nested, out)
+			}
+		}
+	}
+}
+
+func toAnyMap(props map[string]*Schema) map[string]any {
+	m := make(map[string]any, len(props))
+	for k, v := range props {
+		m[k] = v
+	}
+	return m
+}
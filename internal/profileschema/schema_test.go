@@ -0,0 +1,112 @@
+package profileschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 5},
+		"age": {"type": "integer", "minimum": 0},
+		"plan": {"type": "string", "enum": ["free", "pro"], "readOnly": true},
+		"website": {"type": "string", "format": "uri"},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[a-z]+$"}},
+		"address": {
+			"type": "object",
+			"properties": {
+				"city": {"type": "string"},
+				"verified": {"type": "boolean", "readOnly": true}
+			}
+		}
+	}
+}`
+
+func decode(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestParseRejectsUnsupportedSchemas(t *testing.T) {
+	for _, raw := range []string{
+		`not json`,
+		`{"type": "array"}`,
+		`{"type": "object", "oneOf": []}`,
+		`{"type": "object", "properties": {"a": {"type": "text"}}}`,
+		`{"type": "object", "properties": {"a": {"format": "ipv4"}}}`,
+		`{"type": "object", "properties": {"a": {"pattern": "("}}}`,
+		`{"type": "object", "properties": {"a": {"maxLength": -1}}}`,
+	} {
+		if _, err := Parse([]byte(raw)); !errors.Is(err, ErrInvalidSchema) {
+			t.Errorf("Parse(%s): expected ErrInvalidSchema, got %v", raw, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if v := schema.Validate(decode(t, `{"name": "Ann", "age": 30, "plan": "pro", "website": "https://example.com", "tags": ["a"], "address": {"city": "Oslo"}}`)); len(v) != 0 {
+		t.Fatalf("expected a valid profile, got %+v", v)
+	}
+
+	got := schema.Validate(decode(t, `{"age": 1.5, "plan": "gold", "website": "example", "tags": ["a", "B", "c"], "address": {"city": 1}, "extra": true}`))
+	want := []Violation{
+		{"name", "is required"},
+		{"address.city", "must be of type string"},
+		{"age", "must be of type integer"},
+		{"extra", "is not allowed"},
+		{"plan", "must be one of the allowed values"},
+		{"tags", "must have at most 2 items"},
+		{"tags[1]", "must match pattern ^[a-z]+$"},
+		{"website", "must be a valid uri"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected violations:\n got %+v\nwant %+v", got, want)
+	}
+
+	if v := schema.Validate(decode(t, `{"name": "Ann-Marie"}`)); len(v) != 1 || v[0].Message != "must be at most 5 characters" {
+		t.Fatalf("expected a max length violation, got %+v", v)
+	}
+}
+
+func TestApplyReadOnly(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	before := decode(t, `{"name": "Ann", "plan": "pro", "address": {"city": "Oslo", "verified": true}}`)
+
+	// 省略的只读字段保留原值，未修改的只读字段允许原样提交
+	after := decode(t, `{"name": "Bo", "address": {"city": "Bergen", "verified": true}}`)
+	if v := schema.ApplyReadOnly(before, after); len(v) != 0 {
+		t.Fatalf("unexpected violations: %+v", v)
+	}
+	if after["plan"] != "pro" {
+		t.Fatalf("expected the read-only plan to be kept, got %+v", after)
+	}
+
+	after = decode(t, `{"name": "Bo", "plan": "free", "address": {"verified": false}}`)
+	want := []Violation{{"address.verified", "is read-only"}, {"plan", "is read-only"}}
+	if got := schema.ApplyReadOnly(before, after); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected violations: %+v", got)
+	}
+
+	// 新建用户时不能提交只读字段
+	if got := schema.ApplyReadOnly(nil, decode(t, `{"name": "Bo", "plan": "pro"}`)); len(got) != 1 || got[0].Field != "plan" {
+		t.Fatalf("expected plan to be rejected, got %+v", got)
+	}
+}
@@ -0,0 +1,159 @@
+// Package revocation 访问令牌吊销表。吊销记录写入数据库并在进程内缓存，
+// JWTAuth 每次请求只查内存；多实例部署时其他实例的吊销在下一次同步后生效
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+)
+
+// 吊销对象类型
+const (
+	subjectToken   = "jti"
+	subjectSession = "session"
+	subjectUser    = "user"
+)
+
+type key struct {
+	subjectType string
+	subject     string
+}
+
+// Store 访问令牌吊销表
+type Store struct {
+	db database.Querier
+	// maxTokenTTL 访问令牌的最长有效期，按会话或用户吊销的记录保留这么久
+	maxTokenTTL time.Duration
+
+	mu      sync.RWMutex
+	entries map[key]database.TokenRevocation
+}
+
+// NewStore 创建吊销表，maxTokenTTL 为所有访问令牌中最长的有效期
+func NewStore(db database.Querier, maxTokenTTL time.Duration) *Store {
+	return &Store{
+		db:          db,
+		maxTokenTTL: maxTokenTTL,
+		entries:     map[key]database.TokenRevocation{},
+	}
+}
+
+func (s *Store) revoke(ctx context.Context, subjectType, subject string, expiresAt time.Time) error {
+	entry, err := s.db.UpsertTokenRevocation(ctx, database.UpsertTokenRevocationParams{
+		SubjectType: subjectType,
+		Subject:     subject,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke %s: %w", subjectType, err)
+	}
+	s.mu.Lock()
+	s.entries[key{subjectType, subject}] = entry
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeToken 吊销单个访问令牌，记录保留到令牌过期
+func (s *Store) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return s.revoke(ctx, subjectToken, jti, expiresAt)
+}
+
+// RevokeSession 吊销会话签发的所有访问令牌（sid 声明等于该会话）
+func (s *Store) RevokeSession(ctx context.Context, sessionID string) error {
+	return s.revoke(ctx, subjectSession, sessionID, time.Now().Add(s.maxTokenTTL))
+}
+
+// RevokeUser 吊销用户此前签发的、不属于任何会话的访问令牌（如内部接口签发的令牌）。
+// 带 sid 的令牌由 RevokeSession 吊销，避免误伤吊销之后同一秒内新登录签发的令牌
+func (s *Store) RevokeUser(ctx context.Context, userID string) error {
+	return s.revoke(ctx, subjectUser, userID, time.Now().Add(s.maxTokenTTL))
+}
+
+// IsRevoked 判断访问令牌是否已被吊销，只查内存
+func (s *Store) IsRevoked(claims *auth.Claims) bool {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	active := func(subjectType, subject string) (database.TokenRevocation, bool) {
+		entry, ok := s.entries[key{subjectType, subject}]
+		return entry, ok && entry.ExpiresAt.After(now)
+	}
+
+	if claims.ID != "" {
+		if _, ok := active(subjectToken, claims.ID); ok {
+			return true
+		}
+	}
+	if claims.SessionID != "" {
+		_, ok := active(subjectSession, claims.SessionID)
+		return ok
+	}
+	if entry, ok := active(subjectUser, claims.UserID); ok {
+		// iat 只有秒级精度，同一秒内签发的令牌也视为已吊销
+		return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(entry.RevokedAt)
+	}
+	return false
+}
+
+// Sync 从数据库加载有效的吊销记录。吊销不可撤销，只合并不删除，
+// 过期记录由 Prune 清理
+func (s *Store) Sync(ctx context.Context) error {
+	entries, err := s.db.ListActiveTokenRevocations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list token revocations: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		s.entries[key{entry.SubjectType, entry.Subject}] = entry
+	}
+	return nil
+}
+
+// Prune 删除已过期的吊销记录（数据库和内存）
+func (s *Store) Prune(ctx context.Context) error {
+	n, err := s.db.DeleteExpiredTokenRevocations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to prune token revocations: %w", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for k, entry := range s.entries {
+		if !entry.ExpiresAt.After(now) {
+			delete(s.entries, k)
+		}
+	}
+	s.mu.Unlock()
+
+	if n > 0 {
+		slog.Debug("Pruned expired token revocations", "count", n)
+	}
+	return nil
+}
+
+// Run 按 interval 周期同步和清理，直到ctx取消
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(ctx); err != nil {
+				slog.Error("Failed to sync token revocations", "error", err)
+			}
+			if err := s.Prune(ctx); err != nil {
+				slog.Error("Failed to prune token revocations", "error", err)
+			}
+		}
+	}
+}
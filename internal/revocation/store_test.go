@@ -0,0 +1,129 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeQuerier 多个Store共享同一个fakeQuerier，模拟多实例共用数据库
+type fakeQuerier struct {
+	database.Querier
+	rows map[key]database.TokenRevocation
+}
+
+func (f *fakeQuerier) UpsertTokenRevocation(ctx context.Context, arg database.UpsertTokenRevocationParams) (database.TokenRevocation, error) {
+	entry := database.TokenRevocation{SubjectType: arg.SubjectType, Subject: arg.Subject, RevokedAt: time.Now(), ExpiresAt: arg.ExpiresAt}
+	f.rows[key{arg.SubjectType, arg.Subject}] = entry
+	return entry, nil
+}
+
+func (f *fakeQuerier) ListActiveTokenRevocations(ctx context.Context) ([]database.TokenRevocation, error) {
+	entries := []database.TokenRevocation{}
+	for _, entry := range f.rows {
+		if entry.ExpiresAt.After(time.Now()) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeQuerier) DeleteExpiredTokenRevocations(ctx context.Context) (int64, error) {
+	var n int64
+	for k, entry := range f.rows {
+		if !entry.ExpiresAt.After(time.Now()) {
+			delete(f.rows, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func claimsAt(userID, sessionID, jti string, issuedAt time.Time) *auth.Claims {
+	return &auth.Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       jti,
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+	}
+}
+
+func TestRevokeTokenSessionAndUser(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(&fakeQuerier{rows: map[key]database.TokenRevocation{}}, time.Hour)
+	issued := time.Now().Add(-time.Minute)
+
+	if err := store.RevokeToken(ctx, "jti-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if !store.IsRevoked(claimsAt("usr_1", "ses_1", "jti-1", issued)) {
+		t.Fatal("expected token revoked by jti")
+	}
+	if store.IsRevoked(claimsAt("usr_1", "ses_1", "jti-2", issued)) {
+		t.Fatal("other tokens of the session should stay valid")
+	}
+
+	if err := store.RevokeSession(ctx, "ses_1"); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if !store.IsRevoked(claimsAt("usr_1", "ses_1", "jti-2", issued)) {
+		t.Fatal("expected token revoked by session")
+	}
+
+	if err := store.RevokeUser(ctx, "usr_1"); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+	if !store.IsRevoked(claimsAt("usr_1", "", "jti-3", issued)) {
+		t.Fatal("expected session-less token issued before revocation to be revoked")
+	}
+	if store.IsRevoked(claimsAt("usr_1", "", "jti-4", time.Now().Add(2*time.Second))) {
+		t.Fatal("tokens issued after user revocation should stay valid")
+	}
+	if store.IsRevoked(claimsAt("usr_1", "ses_2", "jti-5", issued)) {
+		t.Fatal("tokens of other sessions are covered by session revocation, not user revocation")
+	}
+}
+
+func TestSyncAndPrune(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeQuerier{rows: map[key]database.TokenRevocation{}}
+	a := NewStore(db, time.Hour)
+	b := NewStore(db, time.Hour)
+	claims := claimsAt("usr_1", "ses_1", "jti-1", time.Now())
+
+	if err := a.RevokeSession(ctx, "ses_1"); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if b.IsRevoked(claims) {
+		t.Fatal("other instance should only see the revocation after sync")
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if !b.IsRevoked(claims) {
+		t.Fatal("expected revocation after sync")
+	}
+
+	if err := a.RevokeToken(ctx, "jti-expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if err := a.Prune(ctx); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if _, ok := db.rows[key{subjectToken, "jti-expired"}]; ok {
+		t.Fatal("expected expired revocation to be pruned from the database")
+	}
+	if _, ok := a.entries[key{subjectToken, "jti-expired"}]; ok {
+		t.Fatal("expected expired revocation to be pruned from the cache")
+	}
+	if !a.IsRevoked(claims) {
+		t.Fatal("active revocations must survive pruning")
+	}
+}
@@ -0,0 +1,102 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: auth_failure.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const cleanupAuthFailures = `-- name: CleanupAuthFailures :exec
+DELETE FROM auth_failures
+WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW())
+`
+
+func (q *Queries) CleanupAuthFailures(ctx context.Context, lastFailureAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, cleanupAuthFailures, lastFailureAt)
+	return err
+}
+
+const clearAuthFailure = `-- name: ClearAuthFailure :exec
+DELETE FROM auth_failures WHERE scope = $1 AND subject = $2
+`
+
+type ClearAuthFailureParams struct {
+	Scope   string `json:"scope"`
+	Subject string `json:"subject"`
+}
+
+func (q *Queries) ClearAuthFailure(ctx context.Context, arg ClearAuthFailureParams) error {
+	_, err := q.db.ExecContext(ctx, clearAuthFailure, arg.Scope, arg.Subject)
+	return err
+}
+
+const getAuthFailure = `-- name: GetAuthFailure :one
+SELECT scope, subject, failures, last_failure_at, locked_until FROM auth_failures WHERE scope = $1 AND subject = $2
+`
+
+type GetAuthFailureParams struct {
+	Scope   string `json:"scope"`
+	Subject string `json:"subject"`
+}
+
+func (q *Queries) GetAuthFailure(ctx context.Context, arg GetAuthFailureParams) (AuthFailure, error) {
+	row := q.db.QueryRowContext(ctx, getAuthFailure, arg.Scope, arg.Subject)
+	var i AuthFailure
+	err := row.Scan(
+		&i.Scope,
+		&i.Subject,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const lockAuthFailure = `-- name: LockAuthFailure :exec
+UPDATE auth_failures SET locked_until = $3 WHERE scope = $1 AND subject = $2
+`
+
+type LockAuthFailureParams struct {
+	Scope       string       `json:"scope"`
+	Subject     string       `json:"subject"`
+	LockedUntil sql.NullTime `json:"locked_until"`
+}
+
+func (q *Queries) LockAuthFailure(ctx context.Context, arg LockAuthFailureParams) error {
+	_, err := q.db.ExecContext(ctx, lockAuthFailure, arg.Scope, arg.Subject, arg.LockedUntil)
+	return err
+}
+
+const recordAuthFailure = `-- name: RecordAuthFailure :one
+INSERT INTO auth_failures (scope, subject, failures, last_failure_at)
+VALUES ($1, $2, 1, NOW())
+ON CONFLICT (scope, subject) DO UPDATE
+SET failures = CASE WHEN auth_failures.last_failure_at < $3 THEN 1 ELSE auth_failures.failures + 1 END,
+    last_failure_at = NOW()
+RETURNING scope, subject, failures, last_failure_at, locked_until
+`
+
+type RecordAuthFailureParams struct {
+	Scope         string    `json:"scope"`
+	Subject       string    `json:"subject"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
+
+// 上次失败早于 $3（观察窗口起点）时重新计数
+func (q *Queries) RecordAuthFailure(ctx context.Context, arg RecordAuthFailureParams) (AuthFailure, error) {
+	row := q.db.QueryRowContext(ctx, recordAuthFailure, arg.Scope, arg.Subject, arg.LastFailureAt)
+	var i AuthFailure
+	err := row.Scan(
+		&i.Scope,
+		&i.Subject,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
@@ -0,0 +1,53 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: claims_mapping.sql
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const deleteClaimsMapping = `-- name: DeleteClaimsMapping :execrows
+DELETE FROM tenant_claims_mappings WHERE tenant_id = $1
+`
+
+func (q *Queries) DeleteClaimsMapping(ctx context.Context, tenantID string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteClaimsMapping, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getClaimsMapping = `-- name: GetClaimsMapping :one
+SELECT tenant_id, mapping, updated_at FROM tenant_claims_mappings WHERE tenant_id = $1
+`
+
+func (q *Queries) GetClaimsMapping(ctx context.Context, tenantID string) (TenantClaimsMapping, error) {
+	row := q.db.QueryRowContext(ctx, getClaimsMapping, tenantID)
+	var i TenantClaimsMapping
+	err := row.Scan(&i.TenantID, &i.Mapping, &i.UpdatedAt)
+	return i, err
+}
+
+const upsertClaimsMapping = `-- name: UpsertClaimsMapping :one
+INSERT INTO tenant_claims_mappings (tenant_id, mapping)
+VALUES ($1, $2)
+ON CONFLICT (tenant_id) DO UPDATE SET mapping = EXCLUDED.mapping, updated_at = NOW()
+RETURNING tenant_id, mapping, updated_at
+`
+
+type UpsertClaimsMappingParams struct {
+	TenantID string          `json:"tenant_id"`
+	Mapping  json.RawMessage `json:"mapping"`
+}
+
+func (q *Queries) UpsertClaimsMapping(ctx context.Context, arg UpsertClaimsMappingParams) (TenantClaimsMapping, error) {
+	row := q.db.QueryRowContext(ctx, upsertClaimsMapping, arg.TenantID, arg.Mapping)
+	var i TenantClaimsMapping
+	err := row.Scan(&i.TenantID, &i.Mapping, &i.UpdatedAt)
+	return i, err
+}
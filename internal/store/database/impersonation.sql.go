@@ -0,0 +1,120 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: impersonation.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const createImpersonation = `-- name: CreateImpersonation :one
+INSERT INTO user_impersonations (id, tenant_id, user_id, client_id, actor, reason, token_id, expires_at, client_ip, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, tenant_id, user_id, client_id, actor, reason, token_id, expires_at, client_ip, user_agent, created_at
+`
+
+type CreateImpersonationParams struct {
+	ID        string         `json:"id"`
+	TenantID  string         `json:"tenant_id"`
+	UserID    string         `json:"user_id"`
+	ClientID  string         `json:"client_id"`
+	Actor     string         `json:"actor"`
+	Reason    string         `json:"reason"`
+	TokenID   string         `json:"token_id"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	ClientIp  sql.NullString `json:"client_ip"`
+	UserAgent sql.NullString `json:"user_agent"`
+}
+
+func (q *Queries) CreateImpersonation(ctx context.Context, arg CreateImpersonationParams) (UserImpersonation, error) {
+	row := q.db.QueryRowContext(ctx, createImpersonation,
+		arg.ID,
+		arg.TenantID,
+		arg.UserID,
+		arg.ClientID,
+		arg.Actor,
+		arg.Reason,
+		arg.TokenID,
+		arg.ExpiresAt,
+		arg.ClientIp,
+		arg.UserAgent,
+	)
+	var i UserImpersonation
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.UserID,
+		&i.ClientID,
+		&i.Actor,
+		&i.Reason,
+		&i.TokenID,
+		&i.ExpiresAt,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listImpersonations = `-- name: ListImpersonations :many
+SELECT id, tenant_id, user_id, client_id, actor, reason, token_id, expires_at, client_ip, user_agent, created_at FROM user_impersonations
+WHERE tenant_id = $1
+  AND ($2::text IS NULL OR user_id = $2)
+  AND ($3::text IS NULL OR actor = $3)
+ORDER BY created_at DESC, id
+LIMIT $5 OFFSET $4
+`
+
+type ListImpersonationsParams struct {
+	TenantID  string         `json:"tenant_id"`
+	UserID    sql.NullString `json:"user_id"`
+	Actor     sql.NullString `json:"actor"`
+	RowOffset int32          `json:"row_offset"`
+	RowLimit  int32          `json:"row_limit"`
+}
+
+// 模拟记录按创建时间倒序；user_id、actor 为空时不过滤
+func (q *Queries) ListImpersonations(ctx context.Context, arg ListImpersonationsParams) ([]UserImpersonation, error) {
+	rows, err := q.db.QueryContext(ctx, listImpersonations,
+		arg.TenantID,
+		arg.UserID,
+		arg.Actor,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserImpersonation{}
+	for rows.Next() {
+		var i UserImpersonation
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.UserID,
+			&i.ClientID,
+			&i.Actor,
+			&i.Reason,
+			&i.TokenID,
+			&i.ExpiresAt,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -450,6 +450,23 @@ func (q *Queries) LogServiceAccess(ctx context.Context, arg LogServiceAccessPara
 	return err
 }
 
+const rehashInternalClientSecret = `-- name: RehashInternalClientSecret :exec
+UPDATE internal_clients SET client_secret_hash = $1
+WHERE client_id = $2 AND client_secret_hash = $3
+`
+
+type RehashInternalClientSecretParams struct {
+	NewHash  string `json:"new_hash"`
+	ClientID string `json:"client_id"`
+	OldHash  string `json:"old_hash"`
+}
+
+// 认证通过后升级过时的哈希，旧哈希已被替换时不覆盖
+func (q *Queries) RehashInternalClientSecret(ctx context.Context, arg RehashInternalClientSecretParams) error {
+	_, err := q.db.ExecContext(ctx, rehashInternalClientSecret, arg.NewHash, arg.ClientID, arg.OldHash)
+	return err
+}
+
 const revokeScopeFromClient = `-- name: RevokeScopeFromClient :exec
 DELETE FROM client_scopes WHERE client_id = $1 AND scope_id = $2
 `
@@ -0,0 +1,273 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: invitation.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const createInvitation = `-- name: CreateInvitation :one
+INSERT INTO invitations (id, tenant_id, organization_id, email, role_ids, inviter_user_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, tenant_id, organization_id, email, role_ids, token_hash, created_at, expires_at, accepted_at, revoked_at, inviter_user_id, last_sent_at, accepted_by
+`
+
+type CreateInvitationParams struct {
+	ID             string         `json:"id"`
+	TenantID       string         `json:"tenant_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+	Email          string         `json:"email"`
+	RoleIds        []string       `json:"role_ids"`
+	InviterUserID  sql.NullString `json:"inviter_user_id"`
+	TokenHash      string         `json:"token_hash"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+}
+
+func (q *Queries) CreateInvitation(ctx context.Context, arg CreateInvitationParams) (Invitation, error) {
+	row := q.db.QueryRowContext(ctx, createInvitation,
+		arg.ID,
+		arg.TenantID,
+		arg.OrganizationID,
+		arg.Email,
+		pq.Array(arg.RoleIds),
+		arg.InviterUserID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+	)
+	var i Invitation
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.OrganizationID,
+		&i.Email,
+		pq.Array(&i.RoleIds),
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.RevokedAt,
+		&i.InviterUserID,
+		&i.LastSentAt,
+		&i.AcceptedBy,
+	)
+	return i, err
+}
+
+const deleteInvitationsByEmail = `-- name: DeleteInvitationsByEmail :exec
+DELETE FROM invitations WHERE tenant_id = $1 AND email = $2::text
+`
+
+type DeleteInvitationsByEmailParams struct {
+	TenantID string `json:"tenant_id"`
+	Email    string `json:"email"`
+}
+
+// 删除用户数据时一并删除发给该邮箱的邀请
+func (q *Queries) DeleteInvitationsByEmail(ctx context.Context, arg DeleteInvitationsByEmailParams) error {
+	_, err := q.db.ExecContext(ctx, deleteInvitationsByEmail, arg.TenantID, arg.Email)
+	return err
+}
+
+const getInvitation = `-- name: GetInvitation :one
+SELECT id, tenant_id, organization_id, email, role_ids, token_hash, created_at, expires_at, accepted_at, revoked_at, inviter_user_id, last_sent_at, accepted_by FROM invitations WHERE id = $1 AND tenant_id = $2
+`
+
+type GetInvitationParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) GetInvitation(ctx context.Context, arg GetInvitationParams) (Invitation, error) {
+	row := q.db.QueryRowContext(ctx, getInvitation, arg.ID, arg.TenantID)
+	var i Invitation
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.OrganizationID,
+		&i.Email,
+		pq.Array(&i.RoleIds),
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.RevokedAt,
+		&i.InviterUserID,
+		&i.LastSentAt,
+		&i.AcceptedBy,
+	)
+	return i, err
+}
+
+const getPendingInvitationByTokenHash = `-- name: GetPendingInvitationByTokenHash :one
+SELECT id, tenant_id, organization_id, email, role_ids, token_hash, created_at, expires_at, accepted_at, revoked_at, inviter_user_id, last_sent_at, accepted_by FROM invitations
+WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+`
+
+func (q *Queries) GetPendingInvitationByTokenHash(ctx context.Context, tokenHash string) (Invitation, error) {
+	row := q.db.QueryRowContext(ctx, getPendingInvitationByTokenHash, tokenHash)
+	var i Invitation
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.OrganizationID,
+		&i.Email,
+		pq.Array(&i.RoleIds),
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.RevokedAt,
+		&i.InviterUserID,
+		&i.LastSentAt,
+		&i.AcceptedBy,
+	)
+	return i, err
+}
+
+const listInvitations = `-- name: ListInvitations :many
+SELECT id, tenant_id, organization_id, email, role_ids, token_hash, created_at, expires_at, accepted_at, revoked_at, inviter_user_id, last_sent_at, accepted_by FROM invitations
+WHERE tenant_id = $1
+  AND ($2::text IS NULL OR organization_id = $2)
+  AND ($3::text IS NULL OR $3 = CASE
+      WHEN accepted_at IS NOT NULL THEN 'accepted'
+      WHEN revoked_at IS NOT NULL THEN 'revoked'
+      WHEN expires_at <= NOW() THEN 'expired'
+      ELSE 'pending'
+  END)
+ORDER BY created_at DESC, id
+`
+
+type ListInvitationsParams struct {
+	TenantID       string         `json:"tenant_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+	Status         sql.NullString `json:"status"`
+}
+
+// 邀请列表按创建时间倒序；organization_id、status 为空时不过滤
+func (q *Queries) ListInvitations(ctx context.Context, arg ListInvitationsParams) ([]Invitation, error) {
+	rows, err := q.db.QueryContext(ctx, listInvitations, arg.TenantID, arg.OrganizationID, arg.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Invitation{}
+	for rows.Next() {
+		var i Invitation
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.OrganizationID,
+			&i.Email,
+			pq.Array(&i.RoleIds),
+			&i.TokenHash,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.AcceptedAt,
+			&i.RevokedAt,
+			&i.InviterUserID,
+			&i.LastSentAt,
+			&i.AcceptedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markInvitationAccepted = `-- name: MarkInvitationAccepted :execrows
+UPDATE invitations SET accepted_at = NOW(), accepted_by = $2
+WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+`
+
+type MarkInvitationAcceptedParams struct {
+	ID         string         `json:"id"`
+	AcceptedBy sql.NullString `json:"accepted_by"`
+}
+
+// 条件更新保证同一邀请只能接受一次
+func (q *Queries) MarkInvitationAccepted(ctx context.Context, arg MarkInvitationAcceptedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markInvitationAccepted, arg.ID, arg.AcceptedBy)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resendInvitation = `-- name: ResendInvitation :one
+UPDATE invitations SET token_hash = $1, expires_at = $2, last_sent_at = NOW()
+WHERE id = $3 AND tenant_id = $4
+  AND ($5::text IS NULL OR organization_id = $5)
+  AND accepted_at IS NULL AND revoked_at IS NULL
+RETURNING id, tenant_id, organization_id, email, role_ids, token_hash, created_at, expires_at, accepted_at, revoked_at, inviter_user_id, last_sent_at, accepted_by
+`
+
+type ResendInvitationParams struct {
+	TokenHash      string         `json:"token_hash"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	ID             string         `json:"id"`
+	TenantID       string         `json:"tenant_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+}
+
+// 重新发送时换新令牌并重新计算有效期，旧令牌随之失效
+func (q *Queries) ResendInvitation(ctx context.Context, arg ResendInvitationParams) (Invitation, error) {
+	row := q.db.QueryRowContext(ctx, resendInvitation,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.ID,
+		arg.TenantID,
+		arg.OrganizationID,
+	)
+	var i Invitation
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.OrganizationID,
+		&i.Email,
+		pq.Array(&i.RoleIds),
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.RevokedAt,
+		&i.InviterUserID,
+		&i.LastSentAt,
+		&i.AcceptedBy,
+	)
+	return i, err
+}
+
+const revokeInvitation = `-- name: RevokeInvitation :execrows
+UPDATE invitations SET revoked_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+  AND ($3::text IS NULL OR organization_id = $3)
+  AND accepted_at IS NULL AND revoked_at IS NULL
+`
+
+type RevokeInvitationParams struct {
+	ID             string         `json:"id"`
+	TenantID       string         `json:"tenant_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+}
+
+func (q *Queries) RevokeInvitation(ctx context.Context, arg RevokeInvitationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeInvitation, arg.ID, arg.TenantID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
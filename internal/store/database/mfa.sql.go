@@ -0,0 +1,166 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: mfa.sql
+
+package database
+
+import (
+	"context"
+)
+
+const confirmUserTOTP = `-- name: ConfirmUserTOTP :one
+UPDATE user_mfa_totp
+SET confirmed_at = NOW(), last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NULL
+RETURNING user_id, secret, confirmed_at, last_used_step, created_at
+`
+
+type ConfirmUserTOTPParams struct {
+	UserID       string `json:"user_id"`
+	LastUsedStep int64  `json:"last_used_step"`
+}
+
+func (q *Queries) ConfirmUserTOTP(ctx context.Context, arg ConfirmUserTOTPParams) (UserMfaTotp, error) {
+	row := q.db.QueryRowContext(ctx, confirmUserTOTP, arg.UserID, arg.LastUsedStep)
+	var i UserMfaTotp
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const countUnusedRecoveryCodes = `-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL
+`
+
+func (q *Queries) CountUnusedRecoveryCodes(ctx context.Context, userID string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnusedRecoveryCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createUserRecoveryCode = `-- name: CreateUserRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash)
+VALUES ($1, $2)
+`
+
+type CreateUserRecoveryCodeParams struct {
+	UserID   string `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) CreateUserRecoveryCode(ctx context.Context, arg CreateUserRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createUserRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const deleteUserRecoveryCodes = `-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserRecoveryCodes(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, deleteUserRecoveryCodes, userID)
+	return err
+}
+
+const deleteUserTOTP = `-- name: DeleteUserTOTP :exec
+DELETE FROM user_mfa_totp WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserTOTP(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, deleteUserTOTP, userID)
+	return err
+}
+
+const getUserTOTP = `-- name: GetUserTOTP :one
+SELECT user_id, secret, confirmed_at, last_used_step, created_at FROM user_mfa_totp WHERE user_id = $1
+`
+
+func (q *Queries) GetUserTOTP(ctx context.Context, userID string) (UserMfaTotp, error) {
+	row := q.db.QueryRowContext(ctx, getUserTOTP, userID)
+	var i UserMfaTotp
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateUserTOTPLastUsedStep = `-- name: UpdateUserTOTPLastUsedStep :execrows
+UPDATE user_mfa_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2
+`
+
+type UpdateUserTOTPLastUsedStepParams struct {
+	UserID       string `json:"user_id"`
+	LastUsedStep int64  `json:"last_used_step"`
+}
+
+func (q *Queries) UpdateUserTOTPLastUsedStep(ctx context.Context, arg UpdateUserTOTPLastUsedStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserTOTPLastUsedStep, arg.UserID, arg.LastUsedStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertUserTOTP = `-- name: UpsertUserTOTP :one
+INSERT INTO user_mfa_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, confirmed_at = NULL, last_used_step = 0, created_at = NOW()
+RETURNING user_id, secret, confirmed_at, last_used_step, created_at
+`
+
+type UpsertUserTOTPParams struct {
+	UserID string `json:"user_id"`
+	Secret string `json:"secret"`
+}
+
+func (q *Queries) UpsertUserTOTP(ctx context.Context, arg UpsertUserTOTPParams) (UserMfaTotp, error) {
+	row := q.db.QueryRowContext(ctx, upsertUserTOTP, arg.UserID, arg.Secret)
+	var i UserMfaTotp
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.ConfirmedAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useUserRecoveryCode = `-- name: UseUserRecoveryCode :one
+UPDATE user_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+RETURNING id, user_id, code_hash, used_at, created_at
+`
+
+type UseUserRecoveryCodeParams struct {
+	UserID   string `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) UseUserRecoveryCode(ctx context.Context, arg UseUserRecoveryCodeParams) (UserRecoveryCode, error) {
+	row := q.db.QueryRowContext(ctx, useUserRecoveryCode, arg.UserID, arg.CodeHash)
+	var i UserRecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CodeHash,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -6,11 +6,20 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/sqlc-dev/pqtype"
 )
 
+type AuthFailure struct {
+	Scope         string       `json:"scope"`
+	Subject       string       `json:"subject"`
+	Failures      int32        `json:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
 type ClientScope struct {
 	ClientID  string         `json:"client_id"`
 	ScopeID   int32          `json:"scope_id"`
@@ -28,6 +37,90 @@ type InternalClient struct {
 	UpdatedAt        time.Time      `json:"updated_at"`
 }
 
+type Invitation struct {
+	ID             string         `json:"id"`
+	TenantID       string         `json:"tenant_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+	Email          string         `json:"email"`
+	RoleIds        []string       `json:"role_ids"`
+	TokenHash      string         `json:"token_hash"`
+	CreatedAt      time.Time      `json:"created_at"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	AcceptedAt     sql.NullTime   `json:"accepted_at"`
+	RevokedAt      sql.NullTime   `json:"revoked_at"`
+	InviterUserID  sql.NullString `json:"inviter_user_id"`
+	LastSentAt     time.Time      `json:"last_sent_at"`
+	AcceptedBy     sql.NullString `json:"accepted_by"`
+}
+
+type OidcAuthorizationCode struct {
+	CodeHash      string         `json:"code_hash"`
+	TenantID      string         `json:"tenant_id"`
+	ClientID      string         `json:"client_id"`
+	UserID        string         `json:"user_id"`
+	SessionID     string         `json:"session_id"`
+	RedirectUri   string         `json:"redirect_uri"`
+	Scopes        []string       `json:"scopes"`
+	Nonce         sql.NullString `json:"nonce"`
+	CodeChallenge string         `json:"code_challenge"`
+	Amr           []string       `json:"amr"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+	UsedAt        sql.NullTime   `json:"used_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type OidcClient struct {
+	ID               string         `json:"id"`
+	TenantID         string         `json:"tenant_id"`
+	Name             string         `json:"name"`
+	ClientSecretHash sql.NullString `json:"client_secret_hash"`
+	RedirectUris     []string       `json:"redirect_uris"`
+	SkipConsent      bool           `json:"skip_consent"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+type OidcConsent struct {
+	UserID    string    `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	TenantID  string    `json:"tenant_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Organization struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID string    `json:"organization_id"`
+	UserID         string    `json:"user_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type OrganizationMemberRole struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+	RoleID         string `json:"role_id"`
+}
+
+type Role struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type RolePermission struct {
+	RoleID     string `json:"role_id"`
+	Permission string `json:"permission"`
+}
+
 type Scope struct {
 	ID          int32          `json:"id"`
 	ScopeName   string         `json:"scope_name"`
@@ -36,6 +129,17 @@ type Scope struct {
 	CreatedAt   time.Time      `json:"created_at"`
 }
 
+type SecurityEvent struct {
+	ID        int64           `json:"id"`
+	TenantID  sql.NullString  `json:"tenant_id"`
+	UserID    sql.NullString  `json:"user_id"`
+	EventType string          `json:"event_type"`
+	ClientIp  sql.NullString  `json:"client_ip"`
+	UserAgent sql.NullString  `json:"user_agent"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
 type ServiceAccessLog struct {
 	ID             int32          `json:"id"`
 	ClientID       string         `json:"client_id"`
@@ -61,20 +165,139 @@ type ServiceToken struct {
 }
 
 type Tenant struct {
-	ID               string    `json:"id"`
-	Name             string    `json:"name"`
-	ApiSecretKeyHash string    `json:"api_secret_key_hash"`
-	ApiPublicKey     string    `json:"api_public_key"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	ApiSecretKeyHash string          `json:"api_secret_key_hash"`
+	ApiPublicKey     string          `json:"api_public_key"`
+	CreatedAt        time.Time       `json:"created_at"`
+	Settings         json.RawMessage `json:"settings"`
+}
+
+type TenantClaimsMapping struct {
+	TenantID  string          `json:"tenant_id"`
+	Mapping   json.RawMessage `json:"mapping"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type TenantProfileSchema struct {
+	TenantID  string          `json:"tenant_id"`
+	Version   int32           `json:"version"`
+	Schema    json.RawMessage `json:"schema"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type TokenExchangePolicy struct {
+	ClientID     string    `json:"client_id"`
+	Audience     string    `json:"audience"`
+	SubjectTypes []string  `json:"subject_types"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type TokenRevocation struct {
+	SubjectType string    `json:"subject_type"`
+	Subject     string    `json:"subject"`
+	RevokedAt   time.Time `json:"revoked_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
 
 type User struct {
-	ID             string                `json:"id"`
-	TenantID       string                `json:"tenant_id"`
-	Email          string                `json:"email"`
-	HashedPassword sql.NullString        `json:"hashed_password"`
-	Profile        pqtype.NullRawMessage `json:"profile"`
-	CreatedAt      time.Time             `json:"created_at"`
+	ID                    string                `json:"id"`
+	TenantID              string                `json:"tenant_id"`
+	Email                 string                `json:"email"`
+	HashedPassword        sql.NullString        `json:"hashed_password"`
+	Profile               pqtype.NullRawMessage `json:"profile"`
+	CreatedAt             time.Time             `json:"created_at"`
+	EmailVerified         bool                  `json:"email_verified"`
+	EmailVerifiedAt       sql.NullTime          `json:"email_verified_at"`
+	PasswordResetRequired bool                  `json:"password_reset_required"`
+	PasswordChangedAt     sql.NullTime          `json:"password_changed_at"`
+	Status                string                `json:"status"`
+	DeletedAt             sql.NullTime          `json:"deleted_at"`
+}
+
+type UserActionToken struct {
+	ID         int32        `json:"id"`
+	UserID     string       `json:"user_id"`
+	TenantID   string       `json:"tenant_id"`
+	Purpose    string       `json:"purpose"`
+	TokenHash  string       `json:"token_hash"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	ConsumedAt sql.NullTime `json:"consumed_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Attempts   int32        `json:"attempts"`
+}
+
+type UserErasure struct {
+	UserID       string       `json:"user_id"`
+	TenantID     string       `json:"tenant_id"`
+	RequestedBy  string       `json:"requested_by"`
+	RequestedAt  time.Time    `json:"requested_at"`
+	ScheduledAt  time.Time    `json:"scheduled_at"`
+	ErasedAt     sql.NullTime `json:"erased_at"`
+	DisabledUser bool         `json:"disabled_user"`
+}
+
+type UserImpersonation struct {
+	ID        string         `json:"id"`
+	TenantID  string         `json:"tenant_id"`
+	UserID    string         `json:"user_id"`
+	ClientID  string         `json:"client_id"`
+	Actor     string         `json:"actor"`
+	Reason    string         `json:"reason"`
+	TokenID   string         `json:"token_id"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	ClientIp  sql.NullString `json:"client_ip"`
+	UserAgent sql.NullString `json:"user_agent"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type UserImportError struct {
+	JobID     string         `json:"job_id"`
+	RowNumber int32          `json:"row_number"`
+	Email     sql.NullString `json:"email"`
+	Error     string         `json:"error"`
+}
+
+type UserImportJob struct {
+	ID            string         `json:"id"`
+	TenantID      string         `json:"tenant_id"`
+	Format        string         `json:"format"`
+	Status        string         `json:"status"`
+	Payload       []byte         `json:"payload"`
+	ProcessedRows int32          `json:"processed_rows"`
+	CreatedCount  int32          `json:"created_count"`
+	SkippedCount  int32          `json:"skipped_count"`
+	FailedCount   int32          `json:"failed_count"`
+	Error         sql.NullString `json:"error"`
+	CreatedAt     time.Time      `json:"created_at"`
+	StartedAt     sql.NullTime   `json:"started_at"`
+	HeartbeatAt   sql.NullTime   `json:"heartbeat_at"`
+	FinishedAt    sql.NullTime   `json:"finished_at"`
+}
+
+type UserMfaTotp struct {
+	UserID       string       `json:"user_id"`
+	Secret       string       `json:"secret"`
+	ConfirmedAt  sql.NullTime `json:"confirmed_at"`
+	LastUsedStep int64        `json:"last_used_step"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+type UserPasswordHistory struct {
+	ID             int64     `json:"id"`
+	UserID         string    `json:"user_id"`
+	HashedPassword string    `json:"hashed_password"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type UserRecoveryCode struct {
+	ID        int32        `json:"id"`
+	UserID    string       `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
 }
 
 type UserRefreshToken struct {
@@ -85,4 +308,45 @@ type UserRefreshToken struct {
 	CreatedAt time.Time      `json:"created_at"`
 	ClientIp  sql.NullString `json:"client_ip"`
 	UserAgent sql.NullString `json:"user_agent"`
+	TenantID  string         `json:"tenant_id"`
+	FamilyID  string         `json:"family_id"`
+	ParentID  sql.NullInt32  `json:"parent_id"`
+	Amr       []string       `json:"amr"`
+	RotatedAt sql.NullTime   `json:"rotated_at"`
+	RevokedAt sql.NullTime   `json:"revoked_at"`
+	ClientID  sql.NullString `json:"client_id"`
+	Scopes    []string       `json:"scopes"`
+}
+
+type UserRole struct {
+	UserID    string    `json:"user_id"`
+	RoleID    string    `json:"role_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserSession struct {
+	ID             string         `json:"id"`
+	UserID         string         `json:"user_id"`
+	TenantID       string         `json:"tenant_id"`
+	ClientIp       sql.NullString `json:"client_ip"`
+	UserAgent      sql.NullString `json:"user_agent"`
+	CreatedAt      time.Time      `json:"created_at"`
+	LastUsedAt     time.Time      `json:"last_used_at"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	RevokedAt      sql.NullTime   `json:"revoked_at"`
+	OrganizationID sql.NullString `json:"organization_id"`
+}
+
+type WebauthnCredential struct {
+	ID           string       `json:"id"`
+	UserID       string       `json:"user_id"`
+	TenantID     string       `json:"tenant_id"`
+	CredentialID []byte       `json:"credential_id"`
+	PublicKey    []byte       `json:"public_key"`
+	SignCount    int64        `json:"sign_count"`
+	Transports   []string     `json:"transports"`
+	Aaguid       []byte       `json:"aaguid"`
+	Name         string       `json:"name"`
+	LastUsedAt   sql.NullTime `json:"last_used_at"`
+	CreatedAt    time.Time    `json:"created_at"`
 }
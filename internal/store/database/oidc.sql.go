@@ -0,0 +1,344 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: oidc.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const createOIDCAuthorizationCode = `-- name: CreateOIDCAuthorizationCode :exec
+INSERT INTO oidc_authorization_codes (code_hash, tenant_id, client_id, user_id, session_id, redirect_uri, scopes, nonce, code_challenge, amr, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+`
+
+type CreateOIDCAuthorizationCodeParams struct {
+	CodeHash      string         `json:"code_hash"`
+	TenantID      string         `json:"tenant_id"`
+	ClientID      string         `json:"client_id"`
+	UserID        string         `json:"user_id"`
+	SessionID     string         `json:"session_id"`
+	RedirectUri   string         `json:"redirect_uri"`
+	Scopes        []string       `json:"scopes"`
+	Nonce         sql.NullString `json:"nonce"`
+	CodeChallenge string         `json:"code_challenge"`
+	Amr           []string       `json:"amr"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+}
+
+func (q *Queries) CreateOIDCAuthorizationCode(ctx context.Context, arg CreateOIDCAuthorizationCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createOIDCAuthorizationCode,
+		arg.CodeHash,
+		arg.TenantID,
+		arg.ClientID,
+		arg.UserID,
+		arg.SessionID,
+		arg.RedirectUri,
+		pq.Array(arg.Scopes),
+		arg.Nonce,
+		arg.CodeChallenge,
+		pq.Array(arg.Amr),
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createOIDCClient = `-- name: CreateOIDCClient :one
+INSERT INTO oidc_clients (id, tenant_id, name, client_secret_hash, redirect_uris, skip_consent)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, tenant_id, name, client_secret_hash, redirect_uris, skip_consent, created_at, updated_at
+`
+
+type CreateOIDCClientParams struct {
+	ID               string         `json:"id"`
+	TenantID         string         `json:"tenant_id"`
+	Name             string         `json:"name"`
+	ClientSecretHash sql.NullString `json:"client_secret_hash"`
+	RedirectUris     []string       `json:"redirect_uris"`
+	SkipConsent      bool           `json:"skip_consent"`
+}
+
+func (q *Queries) CreateOIDCClient(ctx context.Context, arg CreateOIDCClientParams) (OidcClient, error) {
+	row := q.db.QueryRowContext(ctx, createOIDCClient,
+		arg.ID,
+		arg.TenantID,
+		arg.Name,
+		arg.ClientSecretHash,
+		pq.Array(arg.RedirectUris),
+		arg.SkipConsent,
+	)
+	var i OidcClient
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.ClientSecretHash,
+		pq.Array(&i.RedirectUris),
+		&i.SkipConsent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteExpiredOIDCAuthorizationCodes = `-- name: DeleteExpiredOIDCAuthorizationCodes :execrows
+DELETE FROM oidc_authorization_codes WHERE expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredOIDCAuthorizationCodes(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredOIDCAuthorizationCodes)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOIDCClient = `-- name: DeleteOIDCClient :execrows
+DELETE FROM oidc_clients WHERE id = $1 AND tenant_id = $2
+`
+
+type DeleteOIDCClientParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) DeleteOIDCClient(ctx context.Context, arg DeleteOIDCClientParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOIDCClient, arg.ID, arg.TenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getOIDCAuthorizationCode = `-- name: GetOIDCAuthorizationCode :one
+SELECT code_hash, tenant_id, client_id, user_id, session_id, redirect_uri, scopes, nonce, code_challenge, amr, expires_at, used_at, created_at FROM oidc_authorization_codes WHERE code_hash = $1
+`
+
+func (q *Queries) GetOIDCAuthorizationCode(ctx context.Context, codeHash string) (OidcAuthorizationCode, error) {
+	row := q.db.QueryRowContext(ctx, getOIDCAuthorizationCode, codeHash)
+	var i OidcAuthorizationCode
+	err := row.Scan(
+		&i.CodeHash,
+		&i.TenantID,
+		&i.ClientID,
+		&i.UserID,
+		&i.SessionID,
+		&i.RedirectUri,
+		pq.Array(&i.Scopes),
+		&i.Nonce,
+		&i.CodeChallenge,
+		pq.Array(&i.Amr),
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOIDCClient = `-- name: GetOIDCClient :one
+SELECT id, tenant_id, name, client_secret_hash, redirect_uris, skip_consent, created_at, updated_at FROM oidc_clients WHERE id = $1 AND tenant_id = $2
+`
+
+type GetOIDCClientParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) GetOIDCClient(ctx context.Context, arg GetOIDCClientParams) (OidcClient, error) {
+	row := q.db.QueryRowContext(ctx, getOIDCClient, arg.ID, arg.TenantID)
+	var i OidcClient
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.ClientSecretHash,
+		pq.Array(&i.RedirectUris),
+		&i.SkipConsent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getOIDCClientByID = `-- name: GetOIDCClientByID :one
+SELECT id, tenant_id, name, client_secret_hash, redirect_uris, skip_consent, created_at, updated_at FROM oidc_clients WHERE id = $1
+`
+
+// /oauth/token 按 client_id 认证客户端，此时还不知道租户
+func (q *Queries) GetOIDCClientByID(ctx context.Context, id string) (OidcClient, error) {
+	row := q.db.QueryRowContext(ctx, getOIDCClientByID, id)
+	var i OidcClient
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.ClientSecretHash,
+		pq.Array(&i.RedirectUris),
+		&i.SkipConsent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getOIDCConsent = `-- name: GetOIDCConsent :one
+SELECT user_id, client_id, tenant_id, scopes, created_at, updated_at FROM oidc_consents WHERE user_id = $1 AND client_id = $2
+`
+
+type GetOIDCConsentParams struct {
+	UserID   string `json:"user_id"`
+	ClientID string `json:"client_id"`
+}
+
+func (q *Queries) GetOIDCConsent(ctx context.Context, arg GetOIDCConsentParams) (OidcConsent, error) {
+	row := q.db.QueryRowContext(ctx, getOIDCConsent, arg.UserID, arg.ClientID)
+	var i OidcConsent
+	err := row.Scan(
+		&i.UserID,
+		&i.ClientID,
+		&i.TenantID,
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listOIDCClients = `-- name: ListOIDCClients :many
+SELECT id, tenant_id, name, client_secret_hash, redirect_uris, skip_consent, created_at, updated_at FROM oidc_clients WHERE tenant_id = $1 ORDER BY created_at, id
+`
+
+func (q *Queries) ListOIDCClients(ctx context.Context, tenantID string) ([]OidcClient, error) {
+	rows, err := q.db.QueryContext(ctx, listOIDCClients, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []OidcClient{}
+	for rows.Next() {
+		var i OidcClient
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.ClientSecretHash,
+			pq.Array(&i.RedirectUris),
+			&i.SkipConsent,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markOIDCAuthorizationCodeUsed = `-- name: MarkOIDCAuthorizationCodeUsed :execrows
+UPDATE oidc_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND used_at IS NULL
+`
+
+// 条件更新保证授权码只能使用一次
+func (q *Queries) MarkOIDCAuthorizationCodeUsed(ctx context.Context, codeHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markOIDCAuthorizationCodeUsed, codeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateOIDCClient = `-- name: UpdateOIDCClient :one
+UPDATE oidc_clients
+SET name = $3, redirect_uris = $4, skip_consent = $5, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING id, tenant_id, name, client_secret_hash, redirect_uris, skip_consent, created_at, updated_at
+`
+
+type UpdateOIDCClientParams struct {
+	ID           string   `json:"id"`
+	TenantID     string   `json:"tenant_id"`
+	Name         string   `json:"name"`
+	RedirectUris []string `json:"redirect_uris"`
+	SkipConsent  bool     `json:"skip_consent"`
+}
+
+func (q *Queries) UpdateOIDCClient(ctx context.Context, arg UpdateOIDCClientParams) (OidcClient, error) {
+	row := q.db.QueryRowContext(ctx, updateOIDCClient,
+		arg.ID,
+		arg.TenantID,
+		arg.Name,
+		pq.Array(arg.RedirectUris),
+		arg.SkipConsent,
+	)
+	var i OidcClient
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.ClientSecretHash,
+		pq.Array(&i.RedirectUris),
+		&i.SkipConsent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateOIDCClientSecret = `-- name: UpdateOIDCClientSecret :execrows
+UPDATE oidc_clients
+SET client_secret_hash = $3, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+`
+
+type UpdateOIDCClientSecretParams struct {
+	ID               string         `json:"id"`
+	TenantID         string         `json:"tenant_id"`
+	ClientSecretHash sql.NullString `json:"client_secret_hash"`
+}
+
+func (q *Queries) UpdateOIDCClientSecret(ctx context.Context, arg UpdateOIDCClientSecretParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateOIDCClientSecret, arg.ID, arg.TenantID, arg.ClientSecretHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertOIDCConsent = `-- name: UpsertOIDCConsent :exec
+INSERT INTO oidc_consents (user_id, client_id, tenant_id, scopes)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, client_id) DO UPDATE
+SET scopes = ARRAY(SELECT DISTINCT unnest(oidc_consents.scopes || EXCLUDED.scopes) ORDER BY 1), updated_at = NOW()
+`
+
+type UpsertOIDCConsentParams struct {
+	UserID   string   `json:"user_id"`
+	ClientID string   `json:"client_id"`
+	TenantID string   `json:"tenant_id"`
+	Scopes   []string `json:"scopes"`
+}
+
+// 再次同意时与已同意的 scope 合并
+func (q *Queries) UpsertOIDCConsent(ctx context.Context, arg UpsertOIDCConsentParams) error {
+	_, err := q.db.ExecContext(ctx, upsertOIDCConsent,
+		arg.UserID,
+		arg.ClientID,
+		arg.TenantID,
+		pq.Array(arg.Scopes),
+	)
+	return err
+}
@@ -0,0 +1,320 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: organization.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const addOrganizationMember = `-- name: AddOrganizationMember :exec
+INSERT INTO organization_members (organization_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type AddOrganizationMemberParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (q *Queries) AddOrganizationMember(ctx context.Context, arg AddOrganizationMemberParams) error {
+	_, err := q.db.ExecContext(ctx, addOrganizationMember, arg.OrganizationID, arg.UserID)
+	return err
+}
+
+const addOrganizationMemberRole = `-- name: AddOrganizationMemberRole :exec
+INSERT INTO organization_member_roles (organization_id, user_id, role_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type AddOrganizationMemberRoleParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+	RoleID         string `json:"role_id"`
+}
+
+func (q *Queries) AddOrganizationMemberRole(ctx context.Context, arg AddOrganizationMemberRoleParams) error {
+	_, err := q.db.ExecContext(ctx, addOrganizationMemberRole, arg.OrganizationID, arg.UserID, arg.RoleID)
+	return err
+}
+
+const clearUserSessionOrganization = `-- name: ClearUserSessionOrganization :exec
+UPDATE user_sessions SET organization_id = NULL
+WHERE user_id = $1 AND organization_id = $2
+`
+
+type ClearUserSessionOrganizationParams struct {
+	UserID         string         `json:"user_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+}
+
+// 成员被移出组织后，以该组织为当前组织的会话不再沿用
+func (q *Queries) ClearUserSessionOrganization(ctx context.Context, arg ClearUserSessionOrganizationParams) error {
+	_, err := q.db.ExecContext(ctx, clearUserSessionOrganization, arg.UserID, arg.OrganizationID)
+	return err
+}
+
+const createOrganization = `-- name: CreateOrganization :one
+INSERT INTO organizations (id, tenant_id, name)
+VALUES ($1, $2, $3)
+RETURNING id, tenant_id, name, created_at
+`
+
+type CreateOrganizationParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+}
+
+func (q *Queries) CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, createOrganization, arg.ID, arg.TenantID, arg.Name)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteOrganization = `-- name: DeleteOrganization :execrows
+DELETE FROM organizations WHERE id = $1 AND tenant_id = $2
+`
+
+type DeleteOrganizationParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) DeleteOrganization(ctx context.Context, arg DeleteOrganizationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOrganization, arg.ID, arg.TenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOrganizationMemberRoles = `-- name: DeleteOrganizationMemberRoles :exec
+DELETE FROM organization_member_roles WHERE organization_id = $1 AND user_id = $2
+`
+
+type DeleteOrganizationMemberRolesParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (q *Queries) DeleteOrganizationMemberRoles(ctx context.Context, arg DeleteOrganizationMemberRolesParams) error {
+	_, err := q.db.ExecContext(ctx, deleteOrganizationMemberRoles, arg.OrganizationID, arg.UserID)
+	return err
+}
+
+const getOrganization = `-- name: GetOrganization :one
+SELECT id, tenant_id, name, created_at FROM organizations WHERE id = $1 AND tenant_id = $2
+`
+
+type GetOrganizationParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) GetOrganization(ctx context.Context, arg GetOrganizationParams) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, getOrganization, arg.ID, arg.TenantID)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOrganizationMember = `-- name: GetOrganizationMember :one
+SELECT organization_id, user_id, created_at FROM organization_members WHERE organization_id = $1 AND user_id = $2
+`
+
+type GetOrganizationMemberParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (q *Queries) GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRowContext(ctx, getOrganizationMember, arg.OrganizationID, arg.UserID)
+	var i OrganizationMember
+	err := row.Scan(&i.OrganizationID, &i.UserID, &i.CreatedAt)
+	return i, err
+}
+
+const listOrganizationMemberRoles = `-- name: ListOrganizationMemberRoles :many
+SELECT r.id, r.tenant_id, r.name, r.description, r.created_at FROM roles r
+JOIN organization_member_roles mr ON mr.role_id = r.id
+WHERE mr.organization_id = $1 AND mr.user_id = $2
+ORDER BY r.name
+`
+
+type ListOrganizationMemberRolesParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (q *Queries) ListOrganizationMemberRoles(ctx context.Context, arg ListOrganizationMemberRolesParams) ([]Role, error) {
+	rows, err := q.db.QueryContext(ctx, listOrganizationMemberRoles, arg.OrganizationID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Role{}
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.Description,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganizations = `-- name: ListOrganizations :many
+SELECT id, tenant_id, name, created_at FROM organizations WHERE tenant_id = $1 ORDER BY name, id
+`
+
+func (q *Queries) ListOrganizations(ctx context.Context, tenantID string) ([]Organization, error) {
+	rows, err := q.db.QueryContext(ctx, listOrganizations, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Organization{}
+	for rows.Next() {
+		var i Organization
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserOrganizations = `-- name: ListUserOrganizations :many
+SELECT o.id, o.tenant_id, o.name, o.created_at FROM organizations o
+JOIN organization_members m ON m.organization_id = o.id
+WHERE m.user_id = $1 AND o.tenant_id = $2
+ORDER BY o.name, o.id
+`
+
+type ListUserOrganizationsParams struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) ListUserOrganizations(ctx context.Context, arg ListUserOrganizationsParams) ([]Organization, error) {
+	rows, err := q.db.QueryContext(ctx, listUserOrganizations, arg.UserID, arg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Organization{}
+	for rows.Next() {
+		var i Organization
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeOrganizationMember = `-- name: RemoveOrganizationMember :execrows
+DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2
+`
+
+type RemoveOrganizationMemberParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (q *Queries) RemoveOrganizationMember(ctx context.Context, arg RemoveOrganizationMemberParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeOrganizationMember, arg.OrganizationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserSessionOrganization = `-- name: SetUserSessionOrganization :exec
+UPDATE user_sessions SET organization_id = $2 WHERE id = $1
+`
+
+type SetUserSessionOrganizationParams struct {
+	ID             string         `json:"id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+}
+
+func (q *Queries) SetUserSessionOrganization(ctx context.Context, arg SetUserSessionOrganizationParams) error {
+	_, err := q.db.ExecContext(ctx, setUserSessionOrganization, arg.ID, arg.OrganizationID)
+	return err
+}
+
+const updateOrganization = `-- name: UpdateOrganization :one
+UPDATE organizations SET name = $3
+WHERE id = $1 AND tenant_id = $2
+RETURNING id, tenant_id, name, created_at
+`
+
+type UpdateOrganizationParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+}
+
+func (q *Queries) UpdateOrganization(ctx context.Context, arg UpdateOrganizationParams) (Organization, error) {
+	row := q.db.QueryRowContext(ctx, updateOrganization, arg.ID, arg.TenantID, arg.Name)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,86 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: password_history.sql
+
+package database
+
+import (
+	"context"
+)
+
+const createPasswordHistory = `-- name: CreatePasswordHistory :exec
+INSERT INTO user_password_history (user_id, hashed_password)
+VALUES ($1, $2)
+`
+
+type CreatePasswordHistoryParams struct {
+	UserID         string `json:"user_id"`
+	HashedPassword string `json:"hashed_password"`
+}
+
+func (q *Queries) CreatePasswordHistory(ctx context.Context, arg CreatePasswordHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, createPasswordHistory, arg.UserID, arg.HashedPassword)
+	return err
+}
+
+const listPasswordHistory = `-- name: ListPasswordHistory :many
+SELECT id, user_id, hashed_password, created_at FROM user_password_history
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`
+
+type ListPasswordHistoryParams struct {
+	UserID string `json:"user_id"`
+	Limit  int32  `json:"limit"`
+}
+
+func (q *Queries) ListPasswordHistory(ctx context.Context, arg ListPasswordHistoryParams) ([]UserPasswordHistory, error) {
+	rows, err := q.db.QueryContext(ctx, listPasswordHistory, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserPasswordHistory{}
+	for rows.Next() {
+		var i UserPasswordHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.HashedPassword,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const prunePasswordHistory = `-- name: PrunePasswordHistory :exec
+DELETE FROM user_password_history
+WHERE user_password_history.user_id = $1 AND id NOT IN (
+    SELECT h.id FROM user_password_history h
+    WHERE h.user_id = $1
+    ORDER BY h.created_at DESC, h.id DESC
+    LIMIT $2
+)
+`
+
+type PrunePasswordHistoryParams struct {
+	UserID string `json:"user_id"`
+	Limit  int32  `json:"limit"`
+}
+
+// 只保留最近 $2 条
+func (q *Queries) PrunePasswordHistory(ctx context.Context, arg PrunePasswordHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, prunePasswordHistory, arg.UserID, arg.Limit)
+	return err
+}
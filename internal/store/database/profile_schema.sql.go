@@ -0,0 +1,74 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: profile_schema.sql
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const createProfileSchema = `-- name: CreateProfileSchema :one
+INSERT INTO tenant_profile_schemas (tenant_id, version, schema)
+SELECT $1, COALESCE(MAX(version), 0) + 1, $2
+FROM tenant_profile_schemas WHERE tenant_id = $1
+RETURNING tenant_id, version, schema, created_at
+`
+
+type CreateProfileSchemaParams struct {
+	TenantID string          `json:"tenant_id"`
+	Schema   json.RawMessage `json:"schema"`
+}
+
+// 发布新版本，版本号在租户内递增；并发发布时主键冲突，由调用方重试
+func (q *Queries) CreateProfileSchema(ctx context.Context, arg CreateProfileSchemaParams) (TenantProfileSchema, error) {
+	row := q.db.QueryRowContext(ctx, createProfileSchema, arg.TenantID, arg.Schema)
+	var i TenantProfileSchema
+	err := row.Scan(
+		&i.TenantID,
+		&i.Version,
+		&i.Schema,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLatestProfileSchema = `-- name: GetLatestProfileSchema :one
+SELECT tenant_id, version, schema, created_at FROM tenant_profile_schemas WHERE tenant_id = $1
+ORDER BY version DESC LIMIT 1
+`
+
+func (q *Queries) GetLatestProfileSchema(ctx context.Context, tenantID string) (TenantProfileSchema, error) {
+	row := q.db.QueryRowContext(ctx, getLatestProfileSchema, tenantID)
+	var i TenantProfileSchema
+	err := row.Scan(
+		&i.TenantID,
+		&i.Version,
+		&i.Schema,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getProfileSchema = `-- name: GetProfileSchema :one
+SELECT tenant_id, version, schema, created_at FROM tenant_profile_schemas WHERE tenant_id = $1 AND version = $2
+`
+
+type GetProfileSchemaParams struct {
+	TenantID string `json:"tenant_id"`
+	Version  int32  `json:"version"`
+}
+
+func (q *Queries) GetProfileSchema(ctx context.Context, arg GetProfileSchemaParams) (TenantProfileSchema, error) {
+	row := q.db.QueryRowContext(ctx, getProfileSchema, arg.TenantID, arg.Version)
+	var i TenantProfileSchema
+	err := row.Scan(
+		&i.TenantID,
+		&i.Version,
+		&i.Schema,
+		&i.CreatedAt,
+	)
+	return i, err
+}
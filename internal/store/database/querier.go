@@ -6,52 +6,214 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 type Querier interface {
 	ActivateInternalClient(ctx context.Context, clientID string) error
+	AddOrganizationMember(ctx context.Context, arg AddOrganizationMemberParams) error
+	AddOrganizationMemberRole(ctx context.Context, arg AddOrganizationMemberRoleParams) error
+	AddRolePermission(ctx context.Context, arg AddRolePermissionParams) error
+	// 角色可能已被删除或属于其他租户，由调用方先校验
+	AssignUserRole(ctx context.Context, arg AssignUserRoleParams) error
+	CancelUserErasure(ctx context.Context, arg CancelUserErasureParams) (UserErasure, error)
 	CheckClientHasScope(ctx context.Context, arg CheckClientHasScopeParams) (bool, error)
+	// 领取一个待处理的任务，或心跳已超时（处理实例已退出）的运行中任务
+	ClaimUserImportJob(ctx context.Context, staleBefore sql.NullTime) (UserImportJob, error)
+	CleanupAuthFailures(ctx context.Context, lastFailureAt time.Time) error
 	CleanupExpiredTokens(ctx context.Context) error
+	CleanupExpiredUserActionTokens(ctx context.Context) error
+	ClearAuthFailure(ctx context.Context, arg ClearAuthFailureParams) error
+	// 成员被移出组织后，以该组织为当前组织的会话不再沿用
+	ClearUserSessionOrganization(ctx context.Context, arg ClearUserSessionOrganizationParams) error
+	ConfirmUserTOTP(ctx context.Context, arg ConfirmUserTOTPParams) (UserMfaTotp, error)
+	ConsumeUserActionToken(ctx context.Context, arg ConsumeUserActionTokenParams) (UserActionToken, error)
+	CountRecentUserActionTokens(ctx context.Context, arg CountRecentUserActionTokensParams) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID string) (int64, error)
+	CreateImpersonation(ctx context.Context, arg CreateImpersonationParams) (UserImpersonation, error)
+	// 导入用户时保留原有的密码哈希；同一租户下已存在的邮箱跳过，因此重复导入是安全的
+	CreateImportedUser(ctx context.Context, arg CreateImportedUserParams) (int64, error)
 	CreateInternalClient(ctx context.Context, arg CreateInternalClientParams) (InternalClient, error)
+	CreateInvitation(ctx context.Context, arg CreateInvitationParams) (Invitation, error)
+	CreateOIDCAuthorizationCode(ctx context.Context, arg CreateOIDCAuthorizationCodeParams) error
+	CreateOIDCClient(ctx context.Context, arg CreateOIDCClientParams) (OidcClient, error)
+	CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (Organization, error)
+	CreatePasswordHistory(ctx context.Context, arg CreatePasswordHistoryParams) error
+	// 发布新版本，版本号在租户内递增；并发发布时主键冲突，由调用方重试
+	CreateProfileSchema(ctx context.Context, arg CreateProfileSchemaParams) (TenantProfileSchema, error)
 	// 用户Refresh Token表
 	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) error
+	CreateRole(ctx context.Context, arg CreateRoleParams) (Role, error)
 	CreateScope(ctx context.Context, arg CreateScopeParams) (Scope, error)
+	CreateSecurityEvent(ctx context.Context, arg CreateSecurityEventParams) error
 	CreateTenant(ctx context.Context, arg CreateTenantParams) (Tenant, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserActionToken(ctx context.Context, arg CreateUserActionTokenParams) error
+	CreateUserErasure(ctx context.Context, arg CreateUserErasureParams) (UserErasure, error)
+	CreateUserImportError(ctx context.Context, arg CreateUserImportErrorParams) error
+	CreateUserImportJob(ctx context.Context, arg CreateUserImportJobParams) (CreateUserImportJobRow, error)
+	CreateUserRecoveryCode(ctx context.Context, arg CreateUserRecoveryCodeParams) error
+	CreateUserSession(ctx context.Context, arg CreateUserSessionParams) (UserSession, error)
+	CreateWebAuthnCredential(ctx context.Context, arg CreateWebAuthnCredentialParams) (WebauthnCredential, error)
 	DeactivateInternalClient(ctx context.Context, clientID string) error
 	DeactivateScope(ctx context.Context, scopeName string) error
 	DeleteAllRefreshTokens(ctx context.Context, userID string) error
+	DeleteClaimsMapping(ctx context.Context, tenantID string) (int64, error)
+	DeleteExpiredOIDCAuthorizationCodes(ctx context.Context) (int64, error)
+	DeleteExpiredTokenRevocations(ctx context.Context) (int64, error)
 	DeleteInternalClient(ctx context.Context, clientID string) error
-	DeleteRefreshToken(ctx context.Context, arg DeleteRefreshTokenParams) error
+	// 删除用户数据时一并删除发给该邮箱的邀请
+	DeleteInvitationsByEmail(ctx context.Context, arg DeleteInvitationsByEmailParams) error
+	DeleteOIDCClient(ctx context.Context, arg DeleteOIDCClientParams) (int64, error)
+	DeleteOrganization(ctx context.Context, arg DeleteOrganizationParams) (int64, error)
+	DeleteOrganizationMemberRoles(ctx context.Context, arg DeleteOrganizationMemberRolesParams) error
+	DeleteRole(ctx context.Context, arg DeleteRoleParams) (int64, error)
 	DeleteTenant(ctx context.Context, id string) error
-	DeleteUser(ctx context.Context, arg DeleteUserParams) error
+	DeleteTokenExchangePolicy(ctx context.Context, arg DeleteTokenExchangePolicyParams) (int64, error)
+	DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error)
+	DeleteUserImportErrorsByEmail(ctx context.Context, arg DeleteUserImportErrorsByEmailParams) error
+	DeleteUserRecoveryCodes(ctx context.Context, userID string) error
+	DeleteUserSecurityEvents(ctx context.Context, arg DeleteUserSecurityEventsParams) error
+	DeleteUserTOTP(ctx context.Context, userID string) error
+	DeleteWebAuthnCredential(ctx context.Context, arg DeleteWebAuthnCredentialParams) (int64, error)
+	FinishUserImportJob(ctx context.Context, arg FinishUserImportJobParams) error
+	// 校验令牌但不消费，用于在消费前先完成其他校验
+	GetActiveUserActionToken(ctx context.Context, arg GetActiveUserActionTokenParams) (UserActionToken, error)
+	GetAuthFailure(ctx context.Context, arg GetAuthFailureParams) (AuthFailure, error)
+	GetClaimsMapping(ctx context.Context, tenantID string) (TenantClaimsMapping, error)
 	GetClientScopes(ctx context.Context, clientID string) ([]GetClientScopesRow, error)
 	GetClientStatistics(ctx context.Context, arg GetClientStatisticsParams) (GetClientStatisticsRow, error)
 	GetInternalClient(ctx context.Context, clientID string) (InternalClient, error)
 	GetInternalClientByID(ctx context.Context, clientID string) (InternalClient, error)
-	GetRefreshToken(ctx context.Context, arg GetRefreshTokenParams) (UserRefreshToken, error)
+	GetInvitation(ctx context.Context, arg GetInvitationParams) (Invitation, error)
+	GetLatestActiveUserActionToken(ctx context.Context, arg GetLatestActiveUserActionTokenParams) (UserActionToken, error)
+	GetLatestProfileSchema(ctx context.Context, tenantID string) (TenantProfileSchema, error)
+	GetOIDCAuthorizationCode(ctx context.Context, codeHash string) (OidcAuthorizationCode, error)
+	GetOIDCClient(ctx context.Context, arg GetOIDCClientParams) (OidcClient, error)
+	// /oauth/token 按 client_id 认证客户端，此时还不知道租户
+	GetOIDCClientByID(ctx context.Context, id string) (OidcClient, error)
+	GetOIDCConsent(ctx context.Context, arg GetOIDCConsentParams) (OidcConsent, error)
+	GetOrganization(ctx context.Context, arg GetOrganizationParams) (Organization, error)
+	GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error)
+	GetPendingInvitationByTokenHash(ctx context.Context, tokenHash string) (Invitation, error)
+	GetProfileSchema(ctx context.Context, arg GetProfileSchemaParams) (TenantProfileSchema, error)
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (UserRefreshToken, error)
+	GetRole(ctx context.Context, arg GetRoleParams) (Role, error)
+	GetRoleByName(ctx context.Context, arg GetRoleByNameParams) (Role, error)
 	GetScopeByName(ctx context.Context, scopeName string) (Scope, error)
 	GetServiceAccessLogs(ctx context.Context, arg GetServiceAccessLogsParams) ([]ServiceAccessLog, error)
 	GetServiceToken(ctx context.Context, tokenHash string) (ServiceToken, error)
 	GetTenantByID(ctx context.Context, id string) (Tenant, error)
 	GetTenantByPublicKey(ctx context.Context, apiPublicKey string) (Tenant, error)
 	GetTenantBySecretKeyHash(ctx context.Context, apiSecretKeyHash string) (Tenant, error)
+	GetTokenExchangePolicy(ctx context.Context, arg GetTokenExchangePolicyParams) (TokenExchangePolicy, error)
 	GetUserByEmail(ctx context.Context, arg GetUserByEmailParams) (User, error)
 	GetUserByID(ctx context.Context, id string) (User, error)
 	GetUserCountByTenant(ctx context.Context, tenantID string) (int64, error)
-	GetUsersByTenant(ctx context.Context, tenantID string) ([]User, error)
+	GetUserErasure(ctx context.Context, arg GetUserErasureParams) (UserErasure, error)
+	GetUserImportJob(ctx context.Context, arg GetUserImportJobParams) (GetUserImportJobRow, error)
+	// 包括已软删除的用户，用于数据导出和删除
+	GetUserIncludingDeleted(ctx context.Context, arg GetUserIncludingDeletedParams) (User, error)
+	GetUserSession(ctx context.Context, id string) (UserSession, error)
+	GetUserTOTP(ctx context.Context, userID string) (UserMfaTotp, error)
+	GetWebAuthnCredentialByCredentialID(ctx context.Context, arg GetWebAuthnCredentialByCredentialIDParams) (WebauthnCredential, error)
 	GrantScopeToClient(ctx context.Context, arg GrantScopeToClientParams) error
+	IncrementUserActionTokenAttempts(ctx context.Context, id int32) (int32, error)
+	InvalidateUserActionTokens(ctx context.Context, arg InvalidateUserActionTokensParams) error
+	ListActiveTokenRevocations(ctx context.Context) ([]TokenRevocation, error)
+	ListActiveUserSessions(ctx context.Context, arg ListActiveUserSessionsParams) ([]UserSession, error)
 	ListAllScopes(ctx context.Context) ([]Scope, error)
+	ListDueUserErasures(ctx context.Context, limit int32) ([]UserErasure, error)
+	// 模拟记录按创建时间倒序；user_id、actor 为空时不过滤
+	ListImpersonations(ctx context.Context, arg ListImpersonationsParams) ([]UserImpersonation, error)
 	ListInternalClients(ctx context.Context) ([]InternalClient, error)
+	// 邀请列表按创建时间倒序；organization_id、status 为空时不过滤
+	ListInvitations(ctx context.Context, arg ListInvitationsParams) ([]Invitation, error)
+	ListOIDCClients(ctx context.Context, tenantID string) ([]OidcClient, error)
+	ListOrganizationMemberRoles(ctx context.Context, arg ListOrganizationMemberRolesParams) ([]Role, error)
+	ListOrganizations(ctx context.Context, tenantID string) ([]Organization, error)
+	ListPasswordHistory(ctx context.Context, arg ListPasswordHistoryParams) ([]UserPasswordHistory, error)
+	ListRolePermissions(ctx context.Context, roleID string) ([]string, error)
+	ListRoles(ctx context.Context, tenantID string) ([]Role, error)
 	ListTenants(ctx context.Context) ([]Tenant, error)
+	ListTokenExchangePolicies(ctx context.Context, clientID string) ([]TokenExchangePolicy, error)
+	ListUserImportErrors(ctx context.Context, arg ListUserImportErrorsParams) ([]UserImportError, error)
+	ListUserOrganizations(ctx context.Context, arg ListUserOrganizationsParams) ([]Organization, error)
+	// 用户通过 ListUserRoles 中的角色获得的权限，去重排序
+	ListUserPermissions(ctx context.Context, arg ListUserPermissionsParams) ([]string, error)
+	ListUserRefreshTokens(ctx context.Context, arg ListUserRefreshTokensParams) ([]UserRefreshToken, error)
+	// 用户的租户级角色，organization_id 非空时加上用户在该组织内的角色
+	ListUserRoles(ctx context.Context, arg ListUserRolesParams) ([]Role, error)
+	// 用户本人的事件，以及按 "<tenant_id>:<email>" 记录的账号锁定事件
+	ListUserSecurityEvents(ctx context.Context, arg ListUserSecurityEventsParams) ([]SecurityEvent, error)
+	ListUserSessions(ctx context.Context, arg ListUserSessionsParams) ([]UserSession, error)
+	ListUsersByCreatedAtAsc(ctx context.Context, arg ListUsersByCreatedAtAscParams) ([]User, error)
+	// 用户列表按排序方式分为四个查询，使游标条件和排序都能直接使用索引。
+	// 游标为上一页最后一行的排序键；同一租户下未删除用户的邮箱唯一，按邮箱排序无需 id 兜底。
+	// email_prefix 中的 LIKE 通配符由调用方转义；organization_id 非空时只返回该组织的成员
+	ListUsersByCreatedAtDesc(ctx context.Context, arg ListUsersByCreatedAtDescParams) ([]User, error)
+	ListUsersByEmailAsc(ctx context.Context, arg ListUsersByEmailAscParams) ([]User, error)
+	ListUsersByEmailDesc(ctx context.Context, arg ListUsersByEmailDescParams) ([]User, error)
+	ListWebAuthnCredentialsByUser(ctx context.Context, userID string) ([]WebauthnCredential, error)
+	LockAuthFailure(ctx context.Context, arg LockAuthFailureParams) error
 	LogServiceAccess(ctx context.Context, arg LogServiceAccessParams) error
+	// 条件更新保证同一邀请只能接受一次
+	MarkInvitationAccepted(ctx context.Context, arg MarkInvitationAcceptedParams) (int64, error)
+	// 条件更新保证授权码只能使用一次
+	MarkOIDCAuthorizationCodeUsed(ctx context.Context, codeHash string) (int64, error)
+	MarkRefreshTokenRotated(ctx context.Context, id int32) (int64, error)
+	MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (User, error)
+	MarkUserErased(ctx context.Context, userID string) error
+	// 只保留最近 $2 条
+	PrunePasswordHistory(ctx context.Context, arg PrunePasswordHistoryParams) error
+	// 上次失败早于 $3（观察窗口起点）时重新计数
+	RecordAuthFailure(ctx context.Context, arg RecordAuthFailureParams) (AuthFailure, error)
+	// 认证通过后升级过时的哈希，旧哈希已被替换时不覆盖
+	RehashInternalClientSecret(ctx context.Context, arg RehashInternalClientSecretParams) error
+	// API密钥校验通过后升级过时的哈希，旧哈希已被替换时不覆盖
+	RehashTenantSecretKey(ctx context.Context, arg RehashTenantSecretKeyParams) error
+	// 登录校验通过后升级过时的哈希，不影响密码修改时间；旧哈希已被替换时不覆盖
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error
+	RemoveOrganizationMember(ctx context.Context, arg RemoveOrganizationMemberParams) (int64, error)
+	RemoveRolePermission(ctx context.Context, arg RemoveRolePermissionParams) (int64, error)
+	// 重新发送时换新令牌并重新计算有效期，旧令牌随之失效
+	ResendInvitation(ctx context.Context, arg ResendInvitationParams) (Invitation, error)
+	RevokeAllUserSessions(ctx context.Context, userID string) error
+	RevokeInvitation(ctx context.Context, arg RevokeInvitationParams) (int64, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
 	RevokeScopeFromClient(ctx context.Context, arg RevokeScopeFromClientParams) error
 	RevokeServiceToken(ctx context.Context, tokenHash string) error
+	RevokeUserSession(ctx context.Context, id string) error
+	// 全文搜索，表达式与 idx_users_search 保持一致
+	SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error)
+	SetUserPasswordResetRequired(ctx context.Context, arg SetUserPasswordResetRequiredParams) (User, error)
+	SetUserSessionOrganization(ctx context.Context, arg SetUserSessionOrganizationParams) error
+	SetUserStatus(ctx context.Context, arg SetUserStatusParams) (User, error)
+	SoftDeleteUser(ctx context.Context, arg SoftDeleteUserParams) (int64, error)
 	StoreServiceToken(ctx context.Context, arg StoreServiceTokenParams) error
+	TouchUserSession(ctx context.Context, arg TouchUserSessionParams) error
+	UnassignUserRole(ctx context.Context, arg UnassignUserRoleParams) (int64, error)
 	UpdateInternalClient(ctx context.Context, arg UpdateInternalClientParams) (InternalClient, error)
+	UpdateOIDCClient(ctx context.Context, arg UpdateOIDCClientParams) (OidcClient, error)
+	UpdateOIDCClientSecret(ctx context.Context, arg UpdateOIDCClientSecretParams) (int64, error)
+	UpdateOrganization(ctx context.Context, arg UpdateOrganizationParams) (Organization, error)
 	UpdateScope(ctx context.Context, arg UpdateScopeParams) (Scope, error)
 	UpdateTenant(ctx context.Context, arg UpdateTenantParams) (Tenant, error)
+	UpdateTenantSettings(ctx context.Context, arg UpdateTenantSettingsParams) (Tenant, error)
+	// 密码通过 UpdateUserPassword 单独修改，以便记录修改时间
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserImportJobProgress(ctx context.Context, arg UpdateUserImportJobProgressParams) error
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserTOTPLastUsedStep(ctx context.Context, arg UpdateUserTOTPLastUsedStepParams) (int64, error)
+	UpdateWebAuthnCredentialSignCount(ctx context.Context, arg UpdateWebAuthnCredentialSignCountParams) error
+	UpsertClaimsMapping(ctx context.Context, arg UpsertClaimsMappingParams) (TenantClaimsMapping, error)
+	// 再次同意时与已同意的 scope 合并
+	UpsertOIDCConsent(ctx context.Context, arg UpsertOIDCConsentParams) error
+	UpsertTokenExchangePolicy(ctx context.Context, arg UpsertTokenExchangePolicyParams) (TokenExchangePolicy, error)
+	UpsertTokenRevocation(ctx context.Context, arg UpsertTokenRevocationParams) (TokenRevocation, error)
+	UpsertUserTOTP(ctx context.Context, arg UpsertUserTOTPParams) (UserMfaTotp, error)
+	UseUserRecoveryCode(ctx context.Context, arg UseUserRecoveryCodeParams) (UserRecoveryCode, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,315 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: role.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const addRolePermission = `-- name: AddRolePermission :exec
+INSERT INTO role_permissions (role_id, permission)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type AddRolePermissionParams struct {
+	RoleID     string `json:"role_id"`
+	Permission string `json:"permission"`
+}
+
+func (q *Queries) AddRolePermission(ctx context.Context, arg AddRolePermissionParams) error {
+	_, err := q.db.ExecContext(ctx, addRolePermission, arg.RoleID, arg.Permission)
+	return err
+}
+
+const assignUserRole = `-- name: AssignUserRole :exec
+INSERT INTO user_roles (user_id, role_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type AssignUserRoleParams struct {
+	UserID string `json:"user_id"`
+	RoleID string `json:"role_id"`
+}
+
+// 角色可能已被删除或属于其他租户，由调用方先校验
+func (q *Queries) AssignUserRole(ctx context.Context, arg AssignUserRoleParams) error {
+	_, err := q.db.ExecContext(ctx, assignUserRole, arg.UserID, arg.RoleID)
+	return err
+}
+
+const createRole = `-- name: CreateRole :one
+INSERT INTO roles (id, tenant_id, name, description)
+VALUES ($1, $2, $3, $4)
+RETURNING id, tenant_id, name, description, created_at
+`
+
+type CreateRoleParams struct {
+	ID          string `json:"id"`
+	TenantID    string `json:"tenant_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (q *Queries) CreateRole(ctx context.Context, arg CreateRoleParams) (Role, error) {
+	row := q.db.QueryRowContext(ctx, createRole,
+		arg.ID,
+		arg.TenantID,
+		arg.Name,
+		arg.Description,
+	)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Description,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteRole = `-- name: DeleteRole :execrows
+DELETE FROM roles WHERE id = $1 AND tenant_id = $2
+`
+
+type DeleteRoleParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) DeleteRole(ctx context.Context, arg DeleteRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRole, arg.ID, arg.TenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getRole = `-- name: GetRole :one
+SELECT id, tenant_id, name, description, created_at FROM roles WHERE id = $1 AND tenant_id = $2
+`
+
+type GetRoleParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) GetRole(ctx context.Context, arg GetRoleParams) (Role, error) {
+	row := q.db.QueryRowContext(ctx, getRole, arg.ID, arg.TenantID)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Description,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRoleByName = `-- name: GetRoleByName :one
+SELECT id, tenant_id, name, description, created_at FROM roles WHERE tenant_id = $1 AND name = $2
+`
+
+type GetRoleByNameParams struct {
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+}
+
+func (q *Queries) GetRoleByName(ctx context.Context, arg GetRoleByNameParams) (Role, error) {
+	row := q.db.QueryRowContext(ctx, getRoleByName, arg.TenantID, arg.Name)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Description,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listRolePermissions = `-- name: ListRolePermissions :many
+SELECT permission FROM role_permissions WHERE role_id = $1 ORDER BY permission
+`
+
+func (q *Queries) ListRolePermissions(ctx context.Context, roleID string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listRolePermissions, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		items = append(items, permission)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoles = `-- name: ListRoles :many
+SELECT id, tenant_id, name, description, created_at FROM roles WHERE tenant_id = $1 ORDER BY name
+`
+
+func (q *Queries) ListRoles(ctx context.Context, tenantID string) ([]Role, error) {
+	rows, err := q.db.QueryContext(ctx, listRoles, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Role{}
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.Description,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserPermissions = `-- name: ListUserPermissions :many
+SELECT DISTINCT rp.permission FROM role_permissions rp
+JOIN roles r ON r.id = rp.role_id
+WHERE r.tenant_id = $1 AND (
+    r.id IN (SELECT ur.role_id FROM user_roles ur WHERE ur.user_id = $2)
+    OR r.id IN (SELECT mr.role_id FROM organization_member_roles mr WHERE mr.user_id = $2 AND mr.organization_id = $3)
+)
+ORDER BY rp.permission
+`
+
+type ListUserPermissionsParams struct {
+	TenantID       string         `json:"tenant_id"`
+	UserID         string         `json:"user_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+}
+
+// 用户通过 ListUserRoles 中的角色获得的权限，去重排序
+func (q *Queries) ListUserPermissions(ctx context.Context, arg ListUserPermissionsParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listUserPermissions, arg.TenantID, arg.UserID, arg.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		items = append(items, permission)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserRoles = `-- name: ListUserRoles :many
+SELECT r.id, r.tenant_id, r.name, r.description, r.created_at FROM roles r
+WHERE r.tenant_id = $1 AND (
+    r.id IN (SELECT ur.role_id FROM user_roles ur WHERE ur.user_id = $2)
+    OR r.id IN (SELECT mr.role_id FROM organization_member_roles mr WHERE mr.user_id = $2 AND mr.organization_id = $3)
+)
+ORDER BY r.name
+`
+
+type ListUserRolesParams struct {
+	TenantID       string         `json:"tenant_id"`
+	UserID         string         `json:"user_id"`
+	OrganizationID sql.NullString `json:"organization_id"`
+}
+
+// 用户的租户级角色，organization_id 非空时加上用户在该组织内的角色
+func (q *Queries) ListUserRoles(ctx context.Context, arg ListUserRolesParams) ([]Role, error) {
+	rows, err := q.db.QueryContext(ctx, listUserRoles, arg.TenantID, arg.UserID, arg.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Role{}
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.Description,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeRolePermission = `-- name: RemoveRolePermission :execrows
+DELETE FROM role_permissions WHERE role_id = $1 AND permission = $2
+`
+
+type RemoveRolePermissionParams struct {
+	RoleID     string `json:"role_id"`
+	Permission string `json:"permission"`
+}
+
+func (q *Queries) RemoveRolePermission(ctx context.Context, arg RemoveRolePermissionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeRolePermission, arg.RoleID, arg.Permission)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unassignUserRole = `-- name: UnassignUserRole :execrows
+DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2
+`
+
+type UnassignUserRoleParams struct {
+	UserID string `json:"user_id"`
+	RoleID string `json:"role_id"`
+}
+
+func (q *Queries) UnassignUserRole(ctx context.Context, arg UnassignUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unassignUserRole, arg.UserID, arg.RoleID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
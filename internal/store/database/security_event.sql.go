@@ -0,0 +1,38 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: security_event.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+const createSecurityEvent = `-- name: CreateSecurityEvent :exec
+INSERT INTO security_events (tenant_id, user_id, event_type, client_ip, user_agent, details)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type CreateSecurityEventParams struct {
+	TenantID  sql.NullString  `json:"tenant_id"`
+	UserID    sql.NullString  `json:"user_id"`
+	EventType string          `json:"event_type"`
+	ClientIp  sql.NullString  `json:"client_ip"`
+	UserAgent sql.NullString  `json:"user_agent"`
+	Details   json.RawMessage `json:"details"`
+}
+
+func (q *Queries) CreateSecurityEvent(ctx context.Context, arg CreateSecurityEventParams) error {
+	_, err := q.db.ExecContext(ctx, createSecurityEvent,
+		arg.TenantID,
+		arg.UserID,
+		arg.EventType,
+		arg.ClientIp,
+		arg.UserAgent,
+		arg.Details,
+	)
+	return err
+}
@@ -0,0 +1,164 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: session.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const createUserSession = `-- name: CreateUserSession :one
+INSERT INTO user_sessions (id, user_id, tenant_id, client_ip, user_agent, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, tenant_id, client_ip, user_agent, created_at, last_used_at, expires_at, revoked_at, organization_id
+`
+
+type CreateUserSessionParams struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"user_id"`
+	TenantID  string         `json:"tenant_id"`
+	ClientIp  sql.NullString `json:"client_ip"`
+	UserAgent sql.NullString `json:"user_agent"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+func (q *Queries) CreateUserSession(ctx context.Context, arg CreateUserSessionParams) (UserSession, error) {
+	row := q.db.QueryRowContext(ctx, createUserSession,
+		arg.ID,
+		arg.UserID,
+		arg.TenantID,
+		arg.ClientIp,
+		arg.UserAgent,
+		arg.ExpiresAt,
+	)
+	var i UserSession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TenantID,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const getUserSession = `-- name: GetUserSession :one
+SELECT id, user_id, tenant_id, client_ip, user_agent, created_at, last_used_at, expires_at, revoked_at, organization_id FROM user_sessions WHERE id = $1
+`
+
+func (q *Queries) GetUserSession(ctx context.Context, id string) (UserSession, error) {
+	row := q.db.QueryRowContext(ctx, getUserSession, id)
+	var i UserSession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TenantID,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const listActiveUserSessions = `-- name: ListActiveUserSessions :many
+SELECT id, user_id, tenant_id, client_ip, user_agent, created_at, last_used_at, expires_at, revoked_at, organization_id FROM user_sessions
+WHERE user_id = $1 AND tenant_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY created_at ASC
+`
+
+type ListActiveUserSessionsParams struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) ListActiveUserSessions(ctx context.Context, arg ListActiveUserSessionsParams) ([]UserSession, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveUserSessions, arg.UserID, arg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserSession{}
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TenantID,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllUserSessions = `-- name: RevokeAllUserSessions :exec
+UPDATE user_sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllUserSessions(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, revokeAllUserSessions, userID)
+	return err
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :exec
+UPDATE user_sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserSession(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, revokeUserSession, id)
+	return err
+}
+
+const touchUserSession = `-- name: TouchUserSession :exec
+UPDATE user_sessions
+SET last_used_at = NOW(), client_ip = $2, user_agent = $3, expires_at = $4
+WHERE id = $1
+`
+
+type TouchUserSessionParams struct {
+	ID        string         `json:"id"`
+	ClientIp  sql.NullString `json:"client_ip"`
+	UserAgent sql.NullString `json:"user_agent"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+func (q *Queries) TouchUserSession(ctx context.Context, arg TouchUserSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchUserSession,
+		arg.ID,
+		arg.ClientIp,
+		arg.UserAgent,
+		arg.ExpiresAt,
+	)
+	return err
+}
@@ -7,12 +7,13 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 )
 
 const createTenant = `-- name: CreateTenant :one
 INSERT INTO tenants (id, name, api_secret_key_hash, api_public_key)
 VALUES ($1, $2, $3, $4)
-RETURNING id, name, api_secret_key_hash, api_public_key, created_at
+RETURNING id, name, api_secret_key_hash, api_public_key, created_at, settings
 `
 
 type CreateTenantParams struct {
@@ -36,6 +37,7 @@ func (q *Queries) CreateTenant(ctx context.Context, arg CreateTenantParams) (Ten
 		&i.ApiSecretKeyHash,
 		&i.ApiPublicKey,
 		&i.CreatedAt,
+		&i.Settings,
 	)
 	return i, err
 }
@@ -50,7 +52,7 @@ func (q *Queries) DeleteTenant(ctx context.Context, id string) error {
 }
 
 const getTenantByID = `-- name: GetTenantByID :one
-SELECT id, name, api_secret_key_hash, api_public_key, created_at FROM tenants WHERE id = $1
+SELECT id, name, api_secret_key_hash, api_public_key, created_at, settings FROM tenants WHERE id = $1
 `
 
 func (q *Queries) GetTenantByID(ctx context.Context, id string) (Tenant, error) {
@@ -62,12 +64,13 @@ func (q *Queries) GetTenantByID(ctx context.Context, id string) (Tenant, error)
 		&i.ApiSecretKeyHash,
 		&i.ApiPublicKey,
 		&i.CreatedAt,
+		&i.Settings,
 	)
 	return i, err
 }
 
 const getTenantByPublicKey = `-- name: GetTenantByPublicKey :one
-SELECT id, name, api_secret_key_hash, api_public_key, created_at FROM tenants WHERE api_public_key = $1
+SELECT id, name, api_secret_key_hash, api_public_key, created_at, settings FROM tenants WHERE api_public_key = $1
 `
 
 func (q *Queries) GetTenantByPublicKey(ctx context.Context, apiPublicKey string) (Tenant, error) {
@@ -79,12 +82,13 @@ func (q *Queries) GetTenantByPublicKey(ctx context.Context, apiPublicKey string)
 		&i.ApiSecretKeyHash,
 		&i.ApiPublicKey,
 		&i.CreatedAt,
+		&i.Settings,
 	)
 	return i, err
 }
 
 const getTenantBySecretKeyHash = `-- name: GetTenantBySecretKeyHash :one
-SELECT id, name, api_secret_key_hash, api_public_key, created_at FROM tenants WHERE api_secret_key_hash = $1
+SELECT id, name, api_secret_key_hash, api_public_key, created_at, settings FROM tenants WHERE api_secret_key_hash = $1
 `
 
 func (q *Queries) GetTenantBySecretKeyHash(ctx context.Context, apiSecretKeyHash string) (Tenant, error) {
@@ -96,12 +100,13 @@ func (q *Queries) GetTenantBySecretKeyHash(ctx context.Context, apiSecretKeyHash
 		&i.ApiSecretKeyHash,
 		&i.ApiPublicKey,
 		&i.CreatedAt,
+		&i.Settings,
 	)
 	return i, err
 }
 
 const listTenants = `-- name: ListTenants :many
-SELECT id, name, api_secret_key_hash, api_public_key, created_at FROM tenants ORDER BY created_at DESC
+SELECT id, name, api_secret_key_hash, api_public_key, created_at, settings FROM tenants ORDER BY created_at DESC
 `
 
 func (q *Queries) ListTenants(ctx context.Context) ([]Tenant, error) {
@@ -119,6 +124,7 @@ func (q *Queries) ListTenants(ctx context.Context) ([]Tenant, error) {
 			&i.ApiSecretKeyHash,
 			&i.ApiPublicKey,
 			&i.CreatedAt,
+			&i.Settings,
 		); err != nil {
 			return nil, err
 		}
@@ -133,11 +139,28 @@ func (q *Queries) ListTenants(ctx context.Context) ([]Tenant, error) {
 	return items, nil
 }
 
+const rehashTenantSecretKey = `-- name: RehashTenantSecretKey :exec
+UPDATE tenants SET api_secret_key_hash = $1
+WHERE id = $2 AND api_secret_key_hash = $3
+`
+
+type RehashTenantSecretKeyParams struct {
+	NewHash string `json:"new_hash"`
+	ID      string `json:"id"`
+	OldHash string `json:"old_hash"`
+}
+
+// API密钥校验通过后升级过时的哈希，旧哈希已被替换时不覆盖
+func (q *Queries) RehashTenantSecretKey(ctx context.Context, arg RehashTenantSecretKeyParams) error {
+	_, err := q.db.ExecContext(ctx, rehashTenantSecretKey, arg.NewHash, arg.ID, arg.OldHash)
+	return err
+}
+
 const updateTenant = `-- name: UpdateTenant :one
 UPDATE tenants 
 SET name = $2, api_secret_key_hash = $3, api_public_key = $4
 WHERE id = $1
-RETURNING id, name, api_secret_key_hash, api_public_key, created_at
+RETURNING id, name, api_secret_key_hash, api_public_key, created_at, settings
 `
 
 type UpdateTenantParams struct {
@@ -161,6 +184,31 @@ func (q *Queries) UpdateTenant(ctx context.Context, arg UpdateTenantParams) (Ten
 		&i.ApiSecretKeyHash,
 		&i.ApiPublicKey,
 		&i.CreatedAt,
+		&i.Settings,
+	)
+	return i, err
+}
+
+const updateTenantSettings = `-- name: UpdateTenantSettings :one
+UPDATE tenants SET settings = $2 WHERE id = $1
+RETURNING id, name, api_secret_key_hash, api_public_key, created_at, settings
+`
+
+type UpdateTenantSettingsParams struct {
+	ID       string          `json:"id"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+func (q *Queries) UpdateTenantSettings(ctx context.Context, arg UpdateTenantSettingsParams) (Tenant, error) {
+	row := q.db.QueryRowContext(ctx, updateTenantSettings, arg.ID, arg.Settings)
+	var i Tenant
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ApiSecretKeyHash,
+		&i.ApiPublicKey,
+		&i.CreatedAt,
+		&i.Settings,
 	)
 	return i, err
 }
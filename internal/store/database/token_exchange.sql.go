@@ -0,0 +1,120 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: token_exchange.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+const deleteTokenExchangePolicy = `-- name: DeleteTokenExchangePolicy :execrows
+DELETE FROM token_exchange_policies WHERE client_id = $1 AND audience = $2
+`
+
+type DeleteTokenExchangePolicyParams struct {
+	ClientID string `json:"client_id"`
+	Audience string `json:"audience"`
+}
+
+func (q *Queries) DeleteTokenExchangePolicy(ctx context.Context, arg DeleteTokenExchangePolicyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteTokenExchangePolicy, arg.ClientID, arg.Audience)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getTokenExchangePolicy = `-- name: GetTokenExchangePolicy :one
+SELECT client_id, audience, subject_types, scopes, created_at, updated_at FROM token_exchange_policies WHERE client_id = $1 AND audience = $2
+`
+
+type GetTokenExchangePolicyParams struct {
+	ClientID string `json:"client_id"`
+	Audience string `json:"audience"`
+}
+
+func (q *Queries) GetTokenExchangePolicy(ctx context.Context, arg GetTokenExchangePolicyParams) (TokenExchangePolicy, error) {
+	row := q.db.QueryRowContext(ctx, getTokenExchangePolicy, arg.ClientID, arg.Audience)
+	var i TokenExchangePolicy
+	err := row.Scan(
+		&i.ClientID,
+		&i.Audience,
+		pq.Array(&i.SubjectTypes),
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listTokenExchangePolicies = `-- name: ListTokenExchangePolicies :many
+SELECT client_id, audience, subject_types, scopes, created_at, updated_at FROM token_exchange_policies WHERE client_id = $1 ORDER BY audience
+`
+
+func (q *Queries) ListTokenExchangePolicies(ctx context.Context, clientID string) ([]TokenExchangePolicy, error) {
+	rows, err := q.db.QueryContext(ctx, listTokenExchangePolicies, clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TokenExchangePolicy{}
+	for rows.Next() {
+		var i TokenExchangePolicy
+		if err := rows.Scan(
+			&i.ClientID,
+			&i.Audience,
+			pq.Array(&i.SubjectTypes),
+			pq.Array(&i.Scopes),
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertTokenExchangePolicy = `-- name: UpsertTokenExchangePolicy :one
+INSERT INTO token_exchange_policies (client_id, audience, subject_types, scopes)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (client_id, audience) DO UPDATE
+SET subject_types = EXCLUDED.subject_types, scopes = EXCLUDED.scopes, updated_at = NOW()
+RETURNING client_id, audience, subject_types, scopes, created_at, updated_at
+`
+
+type UpsertTokenExchangePolicyParams struct {
+	ClientID     string   `json:"client_id"`
+	Audience     string   `json:"audience"`
+	SubjectTypes []string `json:"subject_types"`
+	Scopes       []string `json:"scopes"`
+}
+
+func (q *Queries) UpsertTokenExchangePolicy(ctx context.Context, arg UpsertTokenExchangePolicyParams) (TokenExchangePolicy, error) {
+	row := q.db.QueryRowContext(ctx, upsertTokenExchangePolicy,
+		arg.ClientID,
+		arg.Audience,
+		pq.Array(arg.SubjectTypes),
+		pq.Array(arg.Scopes),
+	)
+	var i TokenExchangePolicy
+	err := row.Scan(
+		&i.ClientID,
+		&i.Audience,
+		pq.Array(&i.SubjectTypes),
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
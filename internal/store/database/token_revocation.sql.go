@@ -0,0 +1,81 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: token_revocation.sql
+
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const deleteExpiredTokenRevocations = `-- name: DeleteExpiredTokenRevocations :execrows
+DELETE FROM token_revocations WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredTokenRevocations(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredTokenRevocations)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listActiveTokenRevocations = `-- name: ListActiveTokenRevocations :many
+SELECT subject_type, subject, revoked_at, expires_at FROM token_revocations WHERE expires_at > NOW()
+`
+
+func (q *Queries) ListActiveTokenRevocations(ctx context.Context) ([]TokenRevocation, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveTokenRevocations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TokenRevocation{}
+	for rows.Next() {
+		var i TokenRevocation
+		if err := rows.Scan(
+			&i.SubjectType,
+			&i.Subject,
+			&i.RevokedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertTokenRevocation = `-- name: UpsertTokenRevocation :one
+INSERT INTO token_revocations (subject_type, subject, revoked_at, expires_at)
+VALUES ($1, $2, NOW(), $3)
+ON CONFLICT (subject_type, subject) DO UPDATE
+SET revoked_at = NOW(), expires_at = GREATEST(token_revocations.expires_at, EXCLUDED.expires_at)
+RETURNING subject_type, subject, revoked_at, expires_at
+`
+
+type UpsertTokenRevocationParams struct {
+	SubjectType string    `json:"subject_type"`
+	Subject     string    `json:"subject"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (q *Queries) UpsertTokenRevocation(ctx context.Context, arg UpsertTokenRevocationParams) (TokenRevocation, error) {
+	row := q.db.QueryRowContext(ctx, upsertTokenRevocation, arg.SubjectType, arg.Subject, arg.ExpiresAt)
+	var i TokenRevocation
+	err := row.Scan(
+		&i.SubjectType,
+		&i.Subject,
+		&i.RevokedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
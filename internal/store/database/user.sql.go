@@ -10,30 +10,43 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/sqlc-dev/pqtype"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :exec
-INSERT INTO user_refresh_tokens (user_id, token_hash, expires_at, created_at, client_ip, user_agent)
-VALUES ($1, $2, $3, NOW(), $4, $5)
+INSERT INTO user_refresh_tokens (user_id, tenant_id, family_id, parent_id, token_hash, amr, expires_at, created_at, client_ip, user_agent, client_id, scopes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), $8, $9, $10, $11)
 `
 
 type CreateRefreshTokenParams struct {
 	UserID    string         `json:"user_id"`
+	TenantID  string         `json:"tenant_id"`
+	FamilyID  string         `json:"family_id"`
+	ParentID  sql.NullInt32  `json:"parent_id"`
 	TokenHash string         `json:"token_hash"`
+	Amr       []string       `json:"amr"`
 	ExpiresAt time.Time      `json:"expires_at"`
 	ClientIp  sql.NullString `json:"client_ip"`
 	UserAgent sql.NullString `json:"user_agent"`
+	ClientID  sql.NullString `json:"client_id"`
+	Scopes    []string       `json:"scopes"`
 }
 
 // 用户Refresh Token表
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) error {
 	_, err := q.db.ExecContext(ctx, createRefreshToken,
 		arg.UserID,
+		arg.TenantID,
+		arg.FamilyID,
+		arg.ParentID,
 		arg.TokenHash,
+		pq.Array(arg.Amr),
 		arg.ExpiresAt,
 		arg.ClientIp,
 		arg.UserAgent,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
 	)
 	return err
 }
@@ -41,7 +54,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, tenant_id, email, hashed_password, profile)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, tenant_id, email, hashed_password, profile, created_at
+RETURNING id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at
 `
 
 type CreateUserParams struct {
@@ -68,6 +81,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.HashedPassword,
 		&i.Profile,
 		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -81,21 +100,7 @@ func (q *Queries) DeleteAllRefreshTokens(ctx context.Context, userID string) err
 	return err
 }
 
-const deleteRefreshToken = `-- name: DeleteRefreshToken :exec
-DELETE FROM user_refresh_tokens WHERE user_id = $1 AND token_hash = $2
-`
-
-type DeleteRefreshTokenParams struct {
-	UserID    string `json:"user_id"`
-	TokenHash string `json:"token_hash"`
-}
-
-func (q *Queries) DeleteRefreshToken(ctx context.Context, arg DeleteRefreshTokenParams) error {
-	_, err := q.db.ExecContext(ctx, deleteRefreshToken, arg.UserID, arg.TokenHash)
-	return err
-}
-
-const deleteUser = `-- name: DeleteUser :exec
+const deleteUser = `-- name: DeleteUser :execrows
 DELETE FROM users WHERE id = $1 AND tenant_id = $2
 `
 
@@ -104,22 +109,20 @@ type DeleteUserParams struct {
 	TenantID string `json:"tenant_id"`
 }
 
-func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) error {
-	_, err := q.db.ExecContext(ctx, deleteUser, arg.ID, arg.TenantID)
-	return err
+func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, arg.ID, arg.TenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT id, user_id, token_hash, expires_at, created_at, client_ip, user_agent FROM user_refresh_tokens WHERE user_id = $1 AND token_hash = $2 AND expires_at > NOW()
+const getRefreshTokenByHash = `-- name: GetRefreshTokenByHash :one
+SELECT id, user_id, token_hash, expires_at, created_at, client_ip, user_agent, tenant_id, family_id, parent_id, amr, rotated_at, revoked_at, client_id, scopes FROM user_refresh_tokens WHERE token_hash = $1
 `
 
-type GetRefreshTokenParams struct {
-	UserID    string `json:"user_id"`
-	TokenHash string `json:"token_hash"`
-}
-
-func (q *Queries) GetRefreshToken(ctx context.Context, arg GetRefreshTokenParams) (UserRefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, arg.UserID, arg.TokenHash)
+func (q *Queries) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (UserRefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenByHash, tokenHash)
 	var i UserRefreshToken
 	err := row.Scan(
 		&i.ID,
@@ -129,12 +132,20 @@ func (q *Queries) GetRefreshToken(ctx context.Context, arg GetRefreshTokenParams
 		&i.CreatedAt,
 		&i.ClientIp,
 		&i.UserAgent,
+		&i.TenantID,
+		&i.FamilyID,
+		&i.ParentID,
+		pq.Array(&i.Amr),
+		&i.RotatedAt,
+		&i.RevokedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, tenant_id, email, hashed_password, profile, created_at FROM users WHERE tenant_id = $1 AND email = $2
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users WHERE tenant_id = $1 AND email = $2 AND deleted_at IS NULL
 `
 
 type GetUserByEmailParams struct {
@@ -152,12 +163,18 @@ func (q *Queries) GetUserByEmail(ctx context.Context, arg GetUserByEmailParams)
 		&i.HashedPassword,
 		&i.Profile,
 		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, tenant_id, email, hashed_password, profile, created_at FROM users WHERE id = $1
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
@@ -170,12 +187,18 @@ func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
 		&i.HashedPassword,
 		&i.Profile,
 		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserCountByTenant = `-- name: GetUserCountByTenant :one
-SELECT COUNT(*) FROM users WHERE tenant_id = $1
+SELECT COUNT(*) FROM users WHERE tenant_id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUserCountByTenant(ctx context.Context, tenantID string) (int64, error) {
@@ -185,12 +208,46 @@ func (q *Queries) GetUserCountByTenant(ctx context.Context, tenantID string) (in
 	return count, err
 }
 
-const getUsersByTenant = `-- name: GetUsersByTenant :many
-SELECT id, tenant_id, email, hashed_password, profile, created_at FROM users WHERE tenant_id = $1 ORDER BY created_at DESC
+const listUsersByCreatedAtAsc = `-- name: ListUsersByCreatedAtAsc :many
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users
+WHERE tenant_id = $1 AND deleted_at IS NULL
+  AND ($2::text IS NULL OR email LIKE $2 || '%')
+  AND ($3::timestamptz IS NULL OR created_at >= $3)
+  AND ($4::timestamptz IS NULL OR created_at < $4)
+  AND ($5::text IS NULL OR status = $5)
+  AND ($6::jsonb IS NULL OR profile @> $6)
+  AND ($7::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = $7))
+  AND ($8::timestamptz IS NULL OR (created_at, id) > ($8, $9::text))
+ORDER BY created_at, id
+LIMIT $10
 `
 
-func (q *Queries) GetUsersByTenant(ctx context.Context, tenantID string) ([]User, error) {
-	rows, err := q.db.QueryContext(ctx, getUsersByTenant, tenantID)
+type ListUsersByCreatedAtAscParams struct {
+	TenantID        string                `json:"tenant_id"`
+	EmailPrefix     sql.NullString        `json:"email_prefix"`
+	CreatedAfter    sql.NullTime          `json:"created_after"`
+	CreatedBefore   sql.NullTime          `json:"created_before"`
+	Status          sql.NullString        `json:"status"`
+	Profile         pqtype.NullRawMessage `json:"profile"`
+	OrganizationID  sql.NullString        `json:"organization_id"`
+	CursorCreatedAt sql.NullTime          `json:"cursor_created_at"`
+	CursorID        string                `json:"cursor_id"`
+	Lim             int32                 `json:"lim"`
+}
+
+func (q *Queries) ListUsersByCreatedAtAsc(ctx context.Context, arg ListUsersByCreatedAtAscParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByCreatedAtAsc,
+		arg.TenantID,
+		arg.EmailPrefix,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.Status,
+		arg.Profile,
+		arg.OrganizationID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Lim,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -205,6 +262,12 @@ func (q *Queries) GetUsersByTenant(ctx context.Context, tenantID string) ([]User
 			&i.HashedPassword,
 			&i.Profile,
 			&i.CreatedAt,
+			&i.EmailVerified,
+			&i.EmailVerifiedAt,
+			&i.PasswordResetRequired,
+			&i.PasswordChangedAt,
+			&i.Status,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -219,28 +282,466 @@ func (q *Queries) GetUsersByTenant(ctx context.Context, tenantID string) ([]User
 	return items, nil
 }
 
-const updateUser = `-- name: UpdateUser :one
-UPDATE users 
-SET email = $3, hashed_password = $4, profile = $5
-WHERE id = $1 AND tenant_id = $2
-RETURNING id, tenant_id, email, hashed_password, profile, created_at
+const listUsersByCreatedAtDesc = `-- name: ListUsersByCreatedAtDesc :many
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users
+WHERE tenant_id = $1 AND deleted_at IS NULL
+  AND ($2::text IS NULL OR email LIKE $2 || '%')
+  AND ($3::timestamptz IS NULL OR created_at >= $3)
+  AND ($4::timestamptz IS NULL OR created_at < $4)
+  AND ($5::text IS NULL OR status = $5)
+  AND ($6::jsonb IS NULL OR profile @> $6)
+  AND ($7::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = $7))
+  AND ($8::timestamptz IS NULL OR (created_at, id) < ($8, $9::text))
+ORDER BY created_at DESC, id DESC
+LIMIT $10
 `
 
-type UpdateUserParams struct {
-	ID             string                `json:"id"`
+type ListUsersByCreatedAtDescParams struct {
+	TenantID        string                `json:"tenant_id"`
+	EmailPrefix     sql.NullString        `json:"email_prefix"`
+	CreatedAfter    sql.NullTime          `json:"created_after"`
+	CreatedBefore   sql.NullTime          `json:"created_before"`
+	Status          sql.NullString        `json:"status"`
+	Profile         pqtype.NullRawMessage `json:"profile"`
+	OrganizationID  sql.NullString        `json:"organization_id"`
+	CursorCreatedAt sql.NullTime          `json:"cursor_created_at"`
+	CursorID        string                `json:"cursor_id"`
+	Lim             int32                 `json:"lim"`
+}
+
+// 用户列表按排序方式分为四个查询，使游标条件和排序都能直接使用索引。
+// 游标为上一页最后一行的排序键；同一租户下未删除用户的邮箱唯一，按邮箱排序无需 id 兜底。
+// email_prefix 中的 LIKE 通配符由调用方转义；organization_id 非空时只返回该组织的成员
+func (q *Queries) ListUsersByCreatedAtDesc(ctx context.Context, arg ListUsersByCreatedAtDescParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByCreatedAtDesc,
+		arg.TenantID,
+		arg.EmailPrefix,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.Status,
+		arg.Profile,
+		arg.OrganizationID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Lim,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Profile,
+			&i.CreatedAt,
+			&i.EmailVerified,
+			&i.EmailVerifiedAt,
+			&i.PasswordResetRequired,
+			&i.PasswordChangedAt,
+			&i.Status,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByEmailAsc = `-- name: ListUsersByEmailAsc :many
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users
+WHERE tenant_id = $1 AND deleted_at IS NULL
+  AND ($2::text IS NULL OR email LIKE $2 || '%')
+  AND ($3::timestamptz IS NULL OR created_at >= $3)
+  AND ($4::timestamptz IS NULL OR created_at < $4)
+  AND ($5::text IS NULL OR status = $5)
+  AND ($6::jsonb IS NULL OR profile @> $6)
+  AND ($7::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = $7))
+  AND ($8::text IS NULL OR email > $8)
+ORDER BY email
+LIMIT $9
+`
+
+type ListUsersByEmailAscParams struct {
 	TenantID       string                `json:"tenant_id"`
-	Email          string                `json:"email"`
-	HashedPassword sql.NullString        `json:"hashed_password"`
+	EmailPrefix    sql.NullString        `json:"email_prefix"`
+	CreatedAfter   sql.NullTime          `json:"created_after"`
+	CreatedBefore  sql.NullTime          `json:"created_before"`
+	Status         sql.NullString        `json:"status"`
 	Profile        pqtype.NullRawMessage `json:"profile"`
+	OrganizationID sql.NullString        `json:"organization_id"`
+	CursorEmail    sql.NullString        `json:"cursor_email"`
+	Lim            int32                 `json:"lim"`
+}
+
+func (q *Queries) ListUsersByEmailAsc(ctx context.Context, arg ListUsersByEmailAscParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByEmailAsc,
+		arg.TenantID,
+		arg.EmailPrefix,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.Status,
+		arg.Profile,
+		arg.OrganizationID,
+		arg.CursorEmail,
+		arg.Lim,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Profile,
+			&i.CreatedAt,
+			&i.EmailVerified,
+			&i.EmailVerifiedAt,
+			&i.PasswordResetRequired,
+			&i.PasswordChangedAt,
+			&i.Status,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByEmailDesc = `-- name: ListUsersByEmailDesc :many
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users
+WHERE tenant_id = $1 AND deleted_at IS NULL
+  AND ($2::text IS NULL OR email LIKE $2 || '%')
+  AND ($3::timestamptz IS NULL OR created_at >= $3)
+  AND ($4::timestamptz IS NULL OR created_at < $4)
+  AND ($5::text IS NULL OR status = $5)
+  AND ($6::jsonb IS NULL OR profile @> $6)
+  AND ($7::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = $7))
+  AND ($8::text IS NULL OR email < $8)
+ORDER BY email DESC
+LIMIT $9
+`
+
+type ListUsersByEmailDescParams struct {
+	TenantID       string                `json:"tenant_id"`
+	EmailPrefix    sql.NullString        `json:"email_prefix"`
+	CreatedAfter   sql.NullTime          `json:"created_after"`
+	CreatedBefore  sql.NullTime          `json:"created_before"`
+	Status         sql.NullString        `json:"status"`
+	Profile        pqtype.NullRawMessage `json:"profile"`
+	OrganizationID sql.NullString        `json:"organization_id"`
+	CursorEmail    sql.NullString        `json:"cursor_email"`
+	Lim            int32                 `json:"lim"`
+}
+
+func (q *Queries) ListUsersByEmailDesc(ctx context.Context, arg ListUsersByEmailDescParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByEmailDesc,
+		arg.TenantID,
+		arg.EmailPrefix,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.Status,
+		arg.Profile,
+		arg.OrganizationID,
+		arg.CursorEmail,
+		arg.Lim,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Profile,
+			&i.CreatedAt,
+			&i.EmailVerified,
+			&i.EmailVerifiedAt,
+			&i.PasswordResetRequired,
+			&i.PasswordChangedAt,
+			&i.Status,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markRefreshTokenRotated = `-- name: MarkRefreshTokenRotated :execrows
+UPDATE user_refresh_tokens
+SET rotated_at = NOW()
+WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+`
+
+func (q *Queries) MarkRefreshTokenRotated(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markRefreshTokenRotated, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified = TRUE, email_verified_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markUserEmailVerified, arg.ID, arg.TenantID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Profile,
+		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $1
+WHERE id = $2 AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewHash sql.NullString `json:"new_hash"`
+	ID      string         `json:"id"`
+	OldHash sql.NullString `json:"old_hash"`
+}
+
+// 登录校验通过后升级过时的哈希，不影响密码修改时间；旧哈希已被替换时不覆盖
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewHash, arg.ID, arg.OldHash)
+	return err
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE user_refresh_tokens
+SET revoked_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	return err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users
+WHERE tenant_id = $1 AND deleted_at IS NULL
+  AND (to_tsvector('simple', email || ' ' || replace(email, '@', ' ')) || jsonb_to_tsvector('simple', COALESCE(profile, '{}'::jsonb), '["string"]')) @@ to_tsquery('simple', $2)
+  AND ($3::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = $3))
+ORDER BY ts_rank(to_tsvector('simple', email || ' ' || replace(email, '@', ' ')) || jsonb_to_tsvector('simple', COALESCE(profile, '{}'::jsonb), '["string"]'), to_tsquery('simple', $2)) DESC, created_at DESC
+LIMIT $4
+`
+
+type SearchUsersParams struct {
+	TenantID       string         `json:"tenant_id"`
+	Query          string         `json:"query"`
+	OrganizationID sql.NullString `json:"organization_id"`
+	Lim            int32          `json:"lim"`
 }
 
+// 全文搜索，表达式与 idx_users_search 保持一致
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.TenantID,
+		arg.Query,
+		arg.OrganizationID,
+		arg.Lim,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Profile,
+			&i.CreatedAt,
+			&i.EmailVerified,
+			&i.EmailVerifiedAt,
+			&i.PasswordResetRequired,
+			&i.PasswordChangedAt,
+			&i.Status,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserPasswordResetRequired = `-- name: SetUserPasswordResetRequired :one
+UPDATE users
+SET password_reset_required = TRUE
+WHERE id = $1 AND tenant_id = $2
+RETURNING id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at
+`
+
+type SetUserPasswordResetRequiredParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) SetUserPasswordResetRequired(ctx context.Context, arg SetUserPasswordResetRequiredParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserPasswordResetRequired, arg.ID, arg.TenantID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Profile,
+		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setUserStatus = `-- name: SetUserStatus :one
+UPDATE users SET status = $3
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+RETURNING id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at
+`
+
+type SetUserStatusParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Status   string `json:"status"`
+}
+
+func (q *Queries) SetUserStatus(ctx context.Context, arg SetUserStatusParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserStatus, arg.ID, arg.TenantID, arg.Status)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Profile,
+		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :execrows
+UPDATE users SET deleted_at = NOW()
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+`
+
+type SoftDeleteUserParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, arg SoftDeleteUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteUser, arg.ID, arg.TenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET email = $3, profile = $4, email_verified = $5,
+    email_verified_at = CASE WHEN $5::boolean THEN email_verified_at ELSE NULL END
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+RETURNING id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at
+`
+
+type UpdateUserParams struct {
+	ID            string                `json:"id"`
+	TenantID      string                `json:"tenant_id"`
+	Email         string                `json:"email"`
+	Profile       pqtype.NullRawMessage `json:"profile"`
+	EmailVerified bool                  `json:"email_verified"`
+}
+
+// 密码通过 UpdateUserPassword 单独修改，以便记录修改时间
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
 	row := q.db.QueryRowContext(ctx, updateUser,
 		arg.ID,
 		arg.TenantID,
 		arg.Email,
-		arg.HashedPassword,
 		arg.Profile,
+		arg.EmailVerified,
 	)
 	var i User
 	err := row.Scan(
@@ -250,6 +751,45 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.HashedPassword,
 		&i.Profile,
 		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $3, password_changed_at = NOW(), password_reset_required = FALSE
+WHERE id = $1 AND tenant_id = $2
+RETURNING id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at
+`
+
+type UpdateUserPasswordParams struct {
+	ID             string         `json:"id"`
+	TenantID       string         `json:"tenant_id"`
+	HashedPassword sql.NullString `json:"hashed_password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.ID, arg.TenantID, arg.HashedPassword)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Profile,
+		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,178 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: user_action_token.sql
+
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const cleanupExpiredUserActionTokens = `-- name: CleanupExpiredUserActionTokens :exec
+DELETE FROM user_action_tokens WHERE expires_at < NOW()
+`
+
+func (q *Queries) CleanupExpiredUserActionTokens(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, cleanupExpiredUserActionTokens)
+	return err
+}
+
+const consumeUserActionToken = `-- name: ConsumeUserActionToken :one
+UPDATE user_action_tokens
+SET consumed_at = NOW()
+WHERE token_hash = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()
+RETURNING id, user_id, tenant_id, purpose, token_hash, expires_at, consumed_at, created_at, attempts
+`
+
+type ConsumeUserActionTokenParams struct {
+	TokenHash string `json:"token_hash"`
+	Purpose   string `json:"purpose"`
+}
+
+func (q *Queries) ConsumeUserActionToken(ctx context.Context, arg ConsumeUserActionTokenParams) (UserActionToken, error) {
+	row := q.db.QueryRowContext(ctx, consumeUserActionToken, arg.TokenHash, arg.Purpose)
+	var i UserActionToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TenantID,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.ConsumedAt,
+		&i.CreatedAt,
+		&i.Attempts,
+	)
+	return i, err
+}
+
+const countRecentUserActionTokens = `-- name: CountRecentUserActionTokens :one
+SELECT COUNT(*) FROM user_action_tokens
+WHERE user_id = $1 AND purpose = $2 AND created_at > $3
+`
+
+type CountRecentUserActionTokensParams struct {
+	UserID    string    `json:"user_id"`
+	Purpose   string    `json:"purpose"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) CountRecentUserActionTokens(ctx context.Context, arg CountRecentUserActionTokensParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecentUserActionTokens, arg.UserID, arg.Purpose, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createUserActionToken = `-- name: CreateUserActionToken :exec
+INSERT INTO user_action_tokens (user_id, tenant_id, purpose, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateUserActionTokenParams struct {
+	UserID    string    `json:"user_id"`
+	TenantID  string    `json:"tenant_id"`
+	Purpose   string    `json:"purpose"`
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateUserActionToken(ctx context.Context, arg CreateUserActionTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createUserActionToken,
+		arg.UserID,
+		arg.TenantID,
+		arg.Purpose,
+		arg.TokenHash,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const getActiveUserActionToken = `-- name: GetActiveUserActionToken :one
+SELECT id, user_id, tenant_id, purpose, token_hash, expires_at, consumed_at, created_at, attempts FROM user_action_tokens
+WHERE token_hash = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()
+`
+
+type GetActiveUserActionTokenParams struct {
+	TokenHash string `json:"token_hash"`
+	Purpose   string `json:"purpose"`
+}
+
+// 校验令牌但不消费，用于在消费前先完成其他校验
+func (q *Queries) GetActiveUserActionToken(ctx context.Context, arg GetActiveUserActionTokenParams) (UserActionToken, error) {
+	row := q.db.QueryRowContext(ctx, getActiveUserActionToken, arg.TokenHash, arg.Purpose)
+	var i UserActionToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TenantID,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.ConsumedAt,
+		&i.CreatedAt,
+		&i.Attempts,
+	)
+	return i, err
+}
+
+const getLatestActiveUserActionToken = `-- name: GetLatestActiveUserActionToken :one
+SELECT id, user_id, tenant_id, purpose, token_hash, expires_at, consumed_at, created_at, attempts FROM user_action_tokens
+WHERE user_id = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+type GetLatestActiveUserActionTokenParams struct {
+	UserID  string `json:"user_id"`
+	Purpose string `json:"purpose"`
+}
+
+func (q *Queries) GetLatestActiveUserActionToken(ctx context.Context, arg GetLatestActiveUserActionTokenParams) (UserActionToken, error) {
+	row := q.db.QueryRowContext(ctx, getLatestActiveUserActionToken, arg.UserID, arg.Purpose)
+	var i UserActionToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TenantID,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.ConsumedAt,
+		&i.CreatedAt,
+		&i.Attempts,
+	)
+	return i, err
+}
+
+const incrementUserActionTokenAttempts = `-- name: IncrementUserActionTokenAttempts :one
+UPDATE user_action_tokens
+SET attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts
+`
+
+func (q *Queries) IncrementUserActionTokenAttempts(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRowContext(ctx, incrementUserActionTokenAttempts, id)
+	var attempts int32
+	err := row.Scan(&attempts)
+	return attempts, err
+}
+
+const invalidateUserActionTokens = `-- name: InvalidateUserActionTokens :exec
+UPDATE user_action_tokens
+SET consumed_at = NOW()
+WHERE user_id = $1 AND purpose = $2 AND consumed_at IS NULL
+`
+
+type InvalidateUserActionTokensParams struct {
+	UserID  string `json:"user_id"`
+	Purpose string `json:"purpose"`
+}
+
+func (q *Queries) InvalidateUserActionTokens(ctx context.Context, arg InvalidateUserActionTokensParams) error {
+	_, err := q.db.ExecContext(ctx, invalidateUserActionTokens, arg.UserID, arg.Purpose)
+	return err
+}
@@ -0,0 +1,349 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: user_erasure.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const cancelUserErasure = `-- name: CancelUserErasure :one
+DELETE FROM user_erasures
+WHERE user_id = $1 AND tenant_id = $2 AND erased_at IS NULL
+RETURNING user_id, tenant_id, requested_by, requested_at, scheduled_at, erased_at, disabled_user
+`
+
+type CancelUserErasureParams struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) CancelUserErasure(ctx context.Context, arg CancelUserErasureParams) (UserErasure, error) {
+	row := q.db.QueryRowContext(ctx, cancelUserErasure, arg.UserID, arg.TenantID)
+	var i UserErasure
+	err := row.Scan(
+		&i.UserID,
+		&i.TenantID,
+		&i.RequestedBy,
+		&i.RequestedAt,
+		&i.ScheduledAt,
+		&i.ErasedAt,
+		&i.DisabledUser,
+	)
+	return i, err
+}
+
+const createUserErasure = `-- name: CreateUserErasure :one
+INSERT INTO user_erasures (user_id, tenant_id, requested_by, scheduled_at, disabled_user)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING user_id, tenant_id, requested_by, requested_at, scheduled_at, erased_at, disabled_user
+`
+
+type CreateUserErasureParams struct {
+	UserID       string    `json:"user_id"`
+	TenantID     string    `json:"tenant_id"`
+	RequestedBy  string    `json:"requested_by"`
+	ScheduledAt  time.Time `json:"scheduled_at"`
+	DisabledUser bool      `json:"disabled_user"`
+}
+
+func (q *Queries) CreateUserErasure(ctx context.Context, arg CreateUserErasureParams) (UserErasure, error) {
+	row := q.db.QueryRowContext(ctx, createUserErasure,
+		arg.UserID,
+		arg.TenantID,
+		arg.RequestedBy,
+		arg.ScheduledAt,
+		arg.DisabledUser,
+	)
+	var i UserErasure
+	err := row.Scan(
+		&i.UserID,
+		&i.TenantID,
+		&i.RequestedBy,
+		&i.RequestedAt,
+		&i.ScheduledAt,
+		&i.ErasedAt,
+		&i.DisabledUser,
+	)
+	return i, err
+}
+
+const deleteUserImportErrorsByEmail = `-- name: DeleteUserImportErrorsByEmail :exec
+DELETE FROM user_import_errors e
+USING user_import_jobs j
+WHERE e.job_id = j.id AND j.tenant_id = $1 AND lower(e.email) = lower($2)
+`
+
+type DeleteUserImportErrorsByEmailParams struct {
+	TenantID string `json:"tenant_id"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) DeleteUserImportErrorsByEmail(ctx context.Context, arg DeleteUserImportErrorsByEmailParams) error {
+	_, err := q.db.ExecContext(ctx, deleteUserImportErrorsByEmail, arg.TenantID, arg.Email)
+	return err
+}
+
+const deleteUserSecurityEvents = `-- name: DeleteUserSecurityEvents :exec
+DELETE FROM security_events
+WHERE user_id = $1::text
+   OR (tenant_id = $2::text AND details->>'subject' = $3::text)
+`
+
+type DeleteUserSecurityEventsParams struct {
+	UserID         string `json:"user_id"`
+	TenantID       string `json:"tenant_id"`
+	LockoutSubject string `json:"lockout_subject"`
+}
+
+func (q *Queries) DeleteUserSecurityEvents(ctx context.Context, arg DeleteUserSecurityEventsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSecurityEvents, arg.UserID, arg.TenantID, arg.LockoutSubject)
+	return err
+}
+
+const getUserErasure = `-- name: GetUserErasure :one
+SELECT user_id, tenant_id, requested_by, requested_at, scheduled_at, erased_at, disabled_user FROM user_erasures WHERE user_id = $1 AND tenant_id = $2
+`
+
+type GetUserErasureParams struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) GetUserErasure(ctx context.Context, arg GetUserErasureParams) (UserErasure, error) {
+	row := q.db.QueryRowContext(ctx, getUserErasure, arg.UserID, arg.TenantID)
+	var i UserErasure
+	err := row.Scan(
+		&i.UserID,
+		&i.TenantID,
+		&i.RequestedBy,
+		&i.RequestedAt,
+		&i.ScheduledAt,
+		&i.ErasedAt,
+		&i.DisabledUser,
+	)
+	return i, err
+}
+
+const getUserIncludingDeleted = `-- name: GetUserIncludingDeleted :one
+SELECT id, tenant_id, email, hashed_password, profile, created_at, email_verified, email_verified_at, password_reset_required, password_changed_at, status, deleted_at FROM users WHERE id = $1 AND tenant_id = $2
+`
+
+type GetUserIncludingDeletedParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+// 包括已软删除的用户，用于数据导出和删除
+func (q *Queries) GetUserIncludingDeleted(ctx context.Context, arg GetUserIncludingDeletedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserIncludingDeleted, arg.ID, arg.TenantID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Profile,
+		&i.CreatedAt,
+		&i.EmailVerified,
+		&i.EmailVerifiedAt,
+		&i.PasswordResetRequired,
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const listDueUserErasures = `-- name: ListDueUserErasures :many
+SELECT user_id, tenant_id, requested_by, requested_at, scheduled_at, erased_at, disabled_user FROM user_erasures
+WHERE erased_at IS NULL AND scheduled_at <= NOW()
+ORDER BY scheduled_at
+LIMIT $1
+`
+
+func (q *Queries) ListDueUserErasures(ctx context.Context, limit int32) ([]UserErasure, error) {
+	rows, err := q.db.QueryContext(ctx, listDueUserErasures, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserErasure{}
+	for rows.Next() {
+		var i UserErasure
+		if err := rows.Scan(
+			&i.UserID,
+			&i.TenantID,
+			&i.RequestedBy,
+			&i.RequestedAt,
+			&i.ScheduledAt,
+			&i.ErasedAt,
+			&i.DisabledUser,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserRefreshTokens = `-- name: ListUserRefreshTokens :many
+SELECT id, user_id, token_hash, expires_at, created_at, client_ip, user_agent, tenant_id, family_id, parent_id, amr, rotated_at, revoked_at, client_id, scopes FROM user_refresh_tokens
+WHERE user_id = $1 AND tenant_id = $2
+ORDER BY created_at, id
+`
+
+type ListUserRefreshTokensParams struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) ListUserRefreshTokens(ctx context.Context, arg ListUserRefreshTokensParams) ([]UserRefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, listUserRefreshTokens, arg.UserID, arg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserRefreshToken{}
+	for rows.Next() {
+		var i UserRefreshToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TokenHash,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.TenantID,
+			&i.FamilyID,
+			&i.ParentID,
+			pq.Array(&i.Amr),
+			&i.RotatedAt,
+			&i.RevokedAt,
+			&i.ClientID,
+			pq.Array(&i.Scopes),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserSecurityEvents = `-- name: ListUserSecurityEvents :many
+SELECT id, tenant_id, user_id, event_type, client_ip, user_agent, details, created_at FROM security_events
+WHERE user_id = $1::text
+   OR (tenant_id = $2::text AND details->>'subject' = $3::text)
+ORDER BY created_at, id
+`
+
+type ListUserSecurityEventsParams struct {
+	UserID         string `json:"user_id"`
+	TenantID       string `json:"tenant_id"`
+	LockoutSubject string `json:"lockout_subject"`
+}
+
+// 用户本人的事件，以及按 "<tenant_id>:<email>" 记录的账号锁定事件
+func (q *Queries) ListUserSecurityEvents(ctx context.Context, arg ListUserSecurityEventsParams) ([]SecurityEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listUserSecurityEvents, arg.UserID, arg.TenantID, arg.LockoutSubject)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SecurityEvent{}
+	for rows.Next() {
+		var i SecurityEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.UserID,
+			&i.EventType,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.Details,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT id, user_id, tenant_id, client_ip, user_agent, created_at, last_used_at, expires_at, revoked_at, organization_id FROM user_sessions
+WHERE user_id = $1 AND tenant_id = $2
+ORDER BY created_at
+`
+
+type ListUserSessionsParams struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+func (q *Queries) ListUserSessions(ctx context.Context, arg ListUserSessionsParams) ([]UserSession, error) {
+	rows, err := q.db.QueryContext(ctx, listUserSessions, arg.UserID, arg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserSession{}
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TenantID,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markUserErased = `-- name: MarkUserErased :exec
+UPDATE user_erasures SET erased_at = NOW() WHERE user_id = $1
+`
+
+func (q *Queries) MarkUserErased(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, markUserErased, userID)
+	return err
+}
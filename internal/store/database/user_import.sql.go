@@ -0,0 +1,285 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: user_import.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sqlc-dev/pqtype"
+)
+
+const claimUserImportJob = `-- name: ClaimUserImportJob :one
+UPDATE user_import_jobs
+SET status = 'running', started_at = COALESCE(started_at, NOW()), heartbeat_at = NOW()
+WHERE id = (
+    SELECT j.id FROM user_import_jobs j
+    WHERE j.status = 'pending' OR (j.status = 'running' AND j.heartbeat_at < $1)
+    ORDER BY j.created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, tenant_id, format, status, payload, processed_rows, created_count, skipped_count, failed_count, error, created_at, started_at, heartbeat_at, finished_at
+`
+
+// 领取一个待处理的任务，或心跳已超时（处理实例已退出）的运行中任务
+func (q *Queries) ClaimUserImportJob(ctx context.Context, staleBefore sql.NullTime) (UserImportJob, error) {
+	row := q.db.QueryRowContext(ctx, claimUserImportJob, staleBefore)
+	var i UserImportJob
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Format,
+		&i.Status,
+		&i.Payload,
+		&i.ProcessedRows,
+		&i.CreatedCount,
+		&i.SkippedCount,
+		&i.FailedCount,
+		&i.Error,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.HeartbeatAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const createImportedUser = `-- name: CreateImportedUser :execrows
+INSERT INTO users (id, tenant_id, email, hashed_password, profile, email_verified, email_verified_at)
+VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $6::boolean THEN NOW() END)
+ON CONFLICT (tenant_id, email) WHERE deleted_at IS NULL DO NOTHING
+`
+
+type CreateImportedUserParams struct {
+	ID             string                `json:"id"`
+	TenantID       string                `json:"tenant_id"`
+	Email          string                `json:"email"`
+	HashedPassword sql.NullString        `json:"hashed_password"`
+	Profile        pqtype.NullRawMessage `json:"profile"`
+	EmailVerified  bool                  `json:"email_verified"`
+}
+
+// 导入用户时保留原有的密码哈希；同一租户下已存在的邮箱跳过，因此重复导入是安全的
+func (q *Queries) CreateImportedUser(ctx context.Context, arg CreateImportedUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createImportedUser,
+		arg.ID,
+		arg.TenantID,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Profile,
+		arg.EmailVerified,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createUserImportError = `-- name: CreateUserImportError :exec
+INSERT INTO user_import_errors (job_id, row_number, email, error)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (job_id, row_number) DO NOTHING
+`
+
+type CreateUserImportErrorParams struct {
+	JobID     string         `json:"job_id"`
+	RowNumber int32          `json:"row_number"`
+	Email     sql.NullString `json:"email"`
+	Error     string         `json:"error"`
+}
+
+func (q *Queries) CreateUserImportError(ctx context.Context, arg CreateUserImportErrorParams) error {
+	_, err := q.db.ExecContext(ctx, createUserImportError,
+		arg.JobID,
+		arg.RowNumber,
+		arg.Email,
+		arg.Error,
+	)
+	return err
+}
+
+const createUserImportJob = `-- name: CreateUserImportJob :one
+INSERT INTO user_import_jobs (id, tenant_id, format, payload)
+VALUES ($1, $2, $3, $4)
+RETURNING id, tenant_id, format, status, processed_rows, created_count, skipped_count, failed_count, error, created_at, started_at, finished_at
+`
+
+type CreateUserImportJobParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Format   string `json:"format"`
+	Payload  []byte `json:"payload"`
+}
+
+type CreateUserImportJobRow struct {
+	ID            string         `json:"id"`
+	TenantID      string         `json:"tenant_id"`
+	Format        string         `json:"format"`
+	Status        string         `json:"status"`
+	ProcessedRows int32          `json:"processed_rows"`
+	CreatedCount  int32          `json:"created_count"`
+	SkippedCount  int32          `json:"skipped_count"`
+	FailedCount   int32          `json:"failed_count"`
+	Error         sql.NullString `json:"error"`
+	CreatedAt     time.Time      `json:"created_at"`
+	StartedAt     sql.NullTime   `json:"started_at"`
+	FinishedAt    sql.NullTime   `json:"finished_at"`
+}
+
+func (q *Queries) CreateUserImportJob(ctx context.Context, arg CreateUserImportJobParams) (CreateUserImportJobRow, error) {
+	row := q.db.QueryRowContext(ctx, createUserImportJob,
+		arg.ID,
+		arg.TenantID,
+		arg.Format,
+		arg.Payload,
+	)
+	var i CreateUserImportJobRow
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Format,
+		&i.Status,
+		&i.ProcessedRows,
+		&i.CreatedCount,
+		&i.SkippedCount,
+		&i.FailedCount,
+		&i.Error,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const finishUserImportJob = `-- name: FinishUserImportJob :exec
+UPDATE user_import_jobs
+SET status = $2, error = $3, payload = NULL, finished_at = NOW()
+WHERE id = $1
+`
+
+type FinishUserImportJobParams struct {
+	ID     string         `json:"id"`
+	Status string         `json:"status"`
+	Error  sql.NullString `json:"error"`
+}
+
+func (q *Queries) FinishUserImportJob(ctx context.Context, arg FinishUserImportJobParams) error {
+	_, err := q.db.ExecContext(ctx, finishUserImportJob, arg.ID, arg.Status, arg.Error)
+	return err
+}
+
+const getUserImportJob = `-- name: GetUserImportJob :one
+SELECT id, tenant_id, format, status, processed_rows, created_count, skipped_count, failed_count, error, created_at, started_at, finished_at
+FROM user_import_jobs WHERE id = $1 AND tenant_id = $2
+`
+
+type GetUserImportJobParams struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+}
+
+type GetUserImportJobRow struct {
+	ID            string         `json:"id"`
+	TenantID      string         `json:"tenant_id"`
+	Format        string         `json:"format"`
+	Status        string         `json:"status"`
+	ProcessedRows int32          `json:"processed_rows"`
+	CreatedCount  int32          `json:"created_count"`
+	SkippedCount  int32          `json:"skipped_count"`
+	FailedCount   int32          `json:"failed_count"`
+	Error         sql.NullString `json:"error"`
+	CreatedAt     time.Time      `json:"created_at"`
+	StartedAt     sql.NullTime   `json:"started_at"`
+	FinishedAt    sql.NullTime   `json:"finished_at"`
+}
+
+func (q *Queries) GetUserImportJob(ctx context.Context, arg GetUserImportJobParams) (GetUserImportJobRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserImportJob, arg.ID, arg.TenantID)
+	var i GetUserImportJobRow
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Format,
+		&i.Status,
+		&i.ProcessedRows,
+		&i.CreatedCount,
+		&i.SkippedCount,
+		&i.FailedCount,
+		&i.Error,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const listUserImportErrors = `-- name: ListUserImportErrors :many
+SELECT job_id, row_number, email, error FROM user_import_errors
+WHERE job_id = $1 AND row_number > $2
+ORDER BY row_number
+LIMIT $3
+`
+
+type ListUserImportErrorsParams struct {
+	JobID    string `json:"job_id"`
+	AfterRow int32  `json:"after_row"`
+	Lim      int32  `json:"lim"`
+}
+
+func (q *Queries) ListUserImportErrors(ctx context.Context, arg ListUserImportErrorsParams) ([]UserImportError, error) {
+	rows, err := q.db.QueryContext(ctx, listUserImportErrors, arg.JobID, arg.AfterRow, arg.Lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserImportError{}
+	for rows.Next() {
+		var i UserImportError
+		if err := rows.Scan(
+			&i.JobID,
+			&i.RowNumber,
+			&i.Email,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserImportJobProgress = `-- name: UpdateUserImportJobProgress :exec
+UPDATE user_import_jobs
+SET processed_rows = $2, created_count = $3, skipped_count = $4, failed_count = $5, heartbeat_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserImportJobProgressParams struct {
+	ID            string `json:"id"`
+	ProcessedRows int32  `json:"processed_rows"`
+	CreatedCount  int32  `json:"created_count"`
+	SkippedCount  int32  `json:"skipped_count"`
+	FailedCount   int32  `json:"failed_count"`
+}
+
+func (q *Queries) UpdateUserImportJobProgress(ctx context.Context, arg UpdateUserImportJobProgressParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserImportJobProgress,
+		arg.ID,
+		arg.ProcessedRows,
+		arg.CreatedCount,
+		arg.SkippedCount,
+		arg.FailedCount,
+	)
+	return err
+}
@@ -0,0 +1,159 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: webauthn.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+const createWebAuthnCredential = `-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials (id, user_id, tenant_id, credential_id, public_key, sign_count, transports, aaguid, name)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, user_id, tenant_id, credential_id, public_key, sign_count, transports, aaguid, name, last_used_at, created_at
+`
+
+type CreateWebAuthnCredentialParams struct {
+	ID           string   `json:"id"`
+	UserID       string   `json:"user_id"`
+	TenantID     string   `json:"tenant_id"`
+	CredentialID []byte   `json:"credential_id"`
+	PublicKey    []byte   `json:"public_key"`
+	SignCount    int64    `json:"sign_count"`
+	Transports   []string `json:"transports"`
+	Aaguid       []byte   `json:"aaguid"`
+	Name         string   `json:"name"`
+}
+
+func (q *Queries) CreateWebAuthnCredential(ctx context.Context, arg CreateWebAuthnCredentialParams) (WebauthnCredential, error) {
+	row := q.db.QueryRowContext(ctx, createWebAuthnCredential,
+		arg.ID,
+		arg.UserID,
+		arg.TenantID,
+		arg.CredentialID,
+		arg.PublicKey,
+		arg.SignCount,
+		pq.Array(arg.Transports),
+		arg.Aaguid,
+		arg.Name,
+	)
+	var i WebauthnCredential
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TenantID,
+		&i.CredentialID,
+		&i.PublicKey,
+		&i.SignCount,
+		pq.Array(&i.Transports),
+		&i.Aaguid,
+		&i.Name,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteWebAuthnCredential = `-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2
+`
+
+type DeleteWebAuthnCredentialParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) DeleteWebAuthnCredential(ctx context.Context, arg DeleteWebAuthnCredentialParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebAuthnCredential, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWebAuthnCredentialByCredentialID = `-- name: GetWebAuthnCredentialByCredentialID :one
+SELECT id, user_id, tenant_id, credential_id, public_key, sign_count, transports, aaguid, name, last_used_at, created_at FROM webauthn_credentials WHERE tenant_id = $1 AND credential_id = $2
+`
+
+type GetWebAuthnCredentialByCredentialIDParams struct {
+	TenantID     string `json:"tenant_id"`
+	CredentialID []byte `json:"credential_id"`
+}
+
+func (q *Queries) GetWebAuthnCredentialByCredentialID(ctx context.Context, arg GetWebAuthnCredentialByCredentialIDParams) (WebauthnCredential, error) {
+	row := q.db.QueryRowContext(ctx, getWebAuthnCredentialByCredentialID, arg.TenantID, arg.CredentialID)
+	var i WebauthnCredential
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TenantID,
+		&i.CredentialID,
+		&i.PublicKey,
+		&i.SignCount,
+		pq.Array(&i.Transports),
+		&i.Aaguid,
+		&i.Name,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listWebAuthnCredentialsByUser = `-- name: ListWebAuthnCredentialsByUser :many
+SELECT id, user_id, tenant_id, credential_id, public_key, sign_count, transports, aaguid, name, last_used_at, created_at FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) ListWebAuthnCredentialsByUser(ctx context.Context, userID string) ([]WebauthnCredential, error) {
+	rows, err := q.db.QueryContext(ctx, listWebAuthnCredentialsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WebauthnCredential{}
+	for rows.Next() {
+		var i WebauthnCredential
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TenantID,
+			&i.CredentialID,
+			&i.PublicKey,
+			&i.SignCount,
+			pq.Array(&i.Transports),
+			&i.Aaguid,
+			&i.Name,
+			&i.LastUsedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateWebAuthnCredentialSignCount = `-- name: UpdateWebAuthnCredentialSignCount :exec
+UPDATE webauthn_credentials
+SET sign_count = $2, last_used_at = NOW()
+WHERE id = $1
+`
+
+type UpdateWebAuthnCredentialSignCountParams struct {
+	ID        string `json:"id"`
+	SignCount int64  `json:"sign_count"`
+}
+
+func (q *Queries) UpdateWebAuthnCredentialSignCount(ctx context.Context, arg UpdateWebAuthnCredentialSignCountParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebAuthnCredentialSignCount, arg.ID, arg.SignCount)
+	return err
+}
@@ -0,0 +1,21 @@
+-- name: GetAuthFailure :one
+SELECT * FROM auth_failures WHERE scope = $1 AND subject = $2;
+
+-- 上次失败早于 $3（观察窗口起点）时重新计数
+-- name: RecordAuthFailure :one
+INSERT INTO auth_failures (scope, subject, failures, last_failure_at)
+VALUES ($1, $2, 1, NOW())
+ON CONFLICT (scope, subject) DO UPDATE
+SET failures = CASE WHEN auth_failures.last_failure_at < $3 THEN 1 ELSE auth_failures.failures + 1 END,
+    last_failure_at = NOW()
+RETURNING *;
+
+-- name: LockAuthFailure :exec
+UPDATE auth_failures SET locked_until = $3 WHERE scope = $1 AND subject = $2;
+
+-- name: ClearAuthFailure :exec
+DELETE FROM auth_failures WHERE scope = $1 AND subject = $2;
+
+-- name: CleanupAuthFailures :exec
+DELETE FROM auth_failures
+WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW());
@@ -0,0 +1,11 @@
+-- name: GetClaimsMapping :one
+SELECT * FROM tenant_claims_mappings WHERE tenant_id = $1;
+
+-- name: UpsertClaimsMapping :one
+INSERT INTO tenant_claims_mappings (tenant_id, mapping)
+VALUES ($1, $2)
+ON CONFLICT (tenant_id) DO UPDATE SET mapping = EXCLUDED.mapping, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteClaimsMapping :execrows
+DELETE FROM tenant_claims_mappings WHERE tenant_id = $1;
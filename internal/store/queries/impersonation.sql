@@ -0,0 +1,13 @@
+-- name: CreateImpersonation :one
+INSERT INTO user_impersonations (id, tenant_id, user_id, client_id, actor, reason, token_id, expires_at, client_ip, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
+
+-- 模拟记录按创建时间倒序；user_id、actor 为空时不过滤
+-- name: ListImpersonations :many
+SELECT * FROM user_impersonations
+WHERE tenant_id = sqlc.arg(tenant_id)
+  AND (sqlc.narg(user_id)::text IS NULL OR user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(actor)::text IS NULL OR actor = sqlc.narg(actor))
+ORDER BY created_at DESC, id
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
@@ -101,4 +101,8 @@ SELECT
     AVG(response_time_ms) as avg_response_time,
     COUNT(CASE WHEN status_code >= 400 THEN 1 END) as error_count
 FROM service_access_logs 
-WHERE client_id = $1 AND created_at >= $2; 
\ No newline at end of file
+WHERE client_id = $1 AND created_at >= $2; 
+-- 认证通过后升级过时的哈希，旧哈希已被替换时不覆盖
+-- name: RehashInternalClientSecret :exec
+UPDATE internal_clients SET client_secret_hash = sqlc.arg(new_hash)
+WHERE client_id = sqlc.arg(client_id) AND client_secret_hash = sqlc.arg(old_hash);
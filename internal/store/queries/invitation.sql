@@ -0,0 +1,47 @@
+-- name: CreateInvitation :one
+INSERT INTO invitations (id, tenant_id, organization_id, email, role_ids, inviter_user_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetInvitation :one
+SELECT * FROM invitations WHERE id = $1 AND tenant_id = $2;
+
+-- 邀请列表按创建时间倒序；organization_id、status 为空时不过滤
+-- name: ListInvitations :many
+SELECT * FROM invitations
+WHERE tenant_id = sqlc.arg(tenant_id)
+  AND (sqlc.narg(organization_id)::text IS NULL OR organization_id = sqlc.narg(organization_id))
+  AND (sqlc.narg(status)::text IS NULL OR sqlc.narg(status) = CASE
+      WHEN accepted_at IS NOT NULL THEN 'accepted'
+      WHEN revoked_at IS NOT NULL THEN 'revoked'
+      WHEN expires_at <= NOW() THEN 'expired'
+      ELSE 'pending'
+  END)
+ORDER BY created_at DESC, id;
+
+-- name: RevokeInvitation :execrows
+UPDATE invitations SET revoked_at = NOW()
+WHERE id = sqlc.arg(id) AND tenant_id = sqlc.arg(tenant_id)
+  AND (sqlc.narg(organization_id)::text IS NULL OR organization_id = sqlc.narg(organization_id))
+  AND accepted_at IS NULL AND revoked_at IS NULL;
+
+-- 重新发送时换新令牌并重新计算有效期，旧令牌随之失效
+-- name: ResendInvitation :one
+UPDATE invitations SET token_hash = sqlc.arg(token_hash), expires_at = sqlc.arg(expires_at), last_sent_at = NOW()
+WHERE id = sqlc.arg(id) AND tenant_id = sqlc.arg(tenant_id)
+  AND (sqlc.narg(organization_id)::text IS NULL OR organization_id = sqlc.narg(organization_id))
+  AND accepted_at IS NULL AND revoked_at IS NULL
+RETURNING *;
+
+-- name: GetPendingInvitationByTokenHash :one
+SELECT * FROM invitations
+WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW();
+
+-- 条件更新保证同一邀请只能接受一次
+-- name: MarkInvitationAccepted :execrows
+UPDATE invitations SET accepted_at = NOW(), accepted_by = $2
+WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL;
+
+-- 删除用户数据时一并删除发给该邮箱的邀请
+-- name: DeleteInvitationsByEmail :exec
+DELETE FROM invitations WHERE tenant_id = sqlc.arg(tenant_id) AND email = sqlc.arg(email)::text;
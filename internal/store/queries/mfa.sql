@@ -0,0 +1,39 @@
+-- name: UpsertUserTOTP :one
+INSERT INTO user_mfa_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, confirmed_at = NULL, last_used_step = 0, created_at = NOW()
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_mfa_totp WHERE user_id = $1;
+
+-- name: ConfirmUserTOTP :one
+UPDATE user_mfa_totp
+SET confirmed_at = NOW(), last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserTOTPLastUsedStep :execrows
+UPDATE user_mfa_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_mfa_totp WHERE user_id = $1;
+
+-- name: CreateUserRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseUserRecoveryCode :one
+UPDATE user_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+RETURNING *;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_id = $1;
@@ -0,0 +1,54 @@
+-- name: CreateOIDCClient :one
+INSERT INTO oidc_clients (id, tenant_id, name, client_secret_hash, redirect_uris, skip_consent)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetOIDCClient :one
+SELECT * FROM oidc_clients WHERE id = $1 AND tenant_id = $2;
+
+-- /oauth/token 按 client_id 认证客户端，此时还不知道租户
+-- name: GetOIDCClientByID :one
+SELECT * FROM oidc_clients WHERE id = $1;
+
+-- name: ListOIDCClients :many
+SELECT * FROM oidc_clients WHERE tenant_id = $1 ORDER BY created_at, id;
+
+-- name: UpdateOIDCClient :one
+UPDATE oidc_clients
+SET name = $3, redirect_uris = $4, skip_consent = $5, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- name: UpdateOIDCClientSecret :execrows
+UPDATE oidc_clients
+SET client_secret_hash = $3, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: DeleteOIDCClient :execrows
+DELETE FROM oidc_clients WHERE id = $1 AND tenant_id = $2;
+
+-- name: CreateOIDCAuthorizationCode :exec
+INSERT INTO oidc_authorization_codes (code_hash, tenant_id, client_id, user_id, session_id, redirect_uri, scopes, nonce, code_challenge, amr, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
+
+-- name: GetOIDCAuthorizationCode :one
+SELECT * FROM oidc_authorization_codes WHERE code_hash = $1;
+
+-- 条件更新保证授权码只能使用一次
+-- name: MarkOIDCAuthorizationCodeUsed :execrows
+UPDATE oidc_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND used_at IS NULL;
+
+-- name: DeleteExpiredOIDCAuthorizationCodes :execrows
+DELETE FROM oidc_authorization_codes WHERE expires_at < NOW();
+
+-- name: GetOIDCConsent :one
+SELECT * FROM oidc_consents WHERE user_id = $1 AND client_id = $2;
+
+-- 再次同意时与已同意的 scope 合并
+-- name: UpsertOIDCConsent :exec
+INSERT INTO oidc_consents (user_id, client_id, tenant_id, scopes)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, client_id) DO UPDATE
+SET scopes = ARRAY(SELECT DISTINCT unnest(oidc_consents.scopes || EXCLUDED.scopes) ORDER BY 1), updated_at = NOW();
@@ -0,0 +1,57 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (id, tenant_id, name)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations WHERE id = $1 AND tenant_id = $2;
+
+-- name: ListOrganizations :many
+SELECT * FROM organizations WHERE tenant_id = $1 ORDER BY name, id;
+
+-- name: UpdateOrganization :one
+UPDATE organizations SET name = $3
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- name: DeleteOrganization :execrows
+DELETE FROM organizations WHERE id = $1 AND tenant_id = $2;
+
+-- name: AddOrganizationMember :exec
+INSERT INTO organization_members (organization_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetOrganizationMember :one
+SELECT * FROM organization_members WHERE organization_id = $1 AND user_id = $2;
+
+-- name: RemoveOrganizationMember :execrows
+DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2;
+
+-- name: AddOrganizationMemberRole :exec
+INSERT INTO organization_member_roles (organization_id, user_id, role_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteOrganizationMemberRoles :exec
+DELETE FROM organization_member_roles WHERE organization_id = $1 AND user_id = $2;
+
+-- name: ListOrganizationMemberRoles :many
+SELECT r.* FROM roles r
+JOIN organization_member_roles mr ON mr.role_id = r.id
+WHERE mr.organization_id = $1 AND mr.user_id = $2
+ORDER BY r.name;
+
+-- name: ListUserOrganizations :many
+SELECT o.* FROM organizations o
+JOIN organization_members m ON m.organization_id = o.id
+WHERE m.user_id = $1 AND o.tenant_id = $2
+ORDER BY o.name, o.id;
+
+-- name: SetUserSessionOrganization :exec
+UPDATE user_sessions SET organization_id = $2 WHERE id = $1;
+
+-- 成员被移出组织后，以该组织为当前组织的会话不再沿用
+-- name: ClearUserSessionOrganization :exec
+UPDATE user_sessions SET organization_id = NULL
+WHERE user_id = $1 AND organization_id = $2;
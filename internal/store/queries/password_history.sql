@@ -0,0 +1,19 @@
+-- name: CreatePasswordHistory :exec
+INSERT INTO user_password_history (user_id, hashed_password)
+VALUES ($1, $2);
+
+-- name: ListPasswordHistory :many
+SELECT * FROM user_password_history
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- 只保留最近 $2 条
+-- name: PrunePasswordHistory :exec
+DELETE FROM user_password_history
+WHERE user_password_history.user_id = $1 AND id NOT IN (
+    SELECT h.id FROM user_password_history h
+    WHERE h.user_id = $1
+    ORDER BY h.created_at DESC, h.id DESC
+    LIMIT $2
+);
@@ -0,0 +1,13 @@
+-- 发布新版本，版本号在租户内递增；并发发布时主键冲突，由调用方重试
+-- name: CreateProfileSchema :one
+INSERT INTO tenant_profile_schemas (tenant_id, version, schema)
+SELECT sqlc.arg(tenant_id), COALESCE(MAX(version), 0) + 1, sqlc.arg(schema)
+FROM tenant_profile_schemas WHERE tenant_id = sqlc.arg(tenant_id)
+RETURNING *;
+
+-- name: GetLatestProfileSchema :one
+SELECT * FROM tenant_profile_schemas WHERE tenant_id = $1
+ORDER BY version DESC LIMIT 1;
+
+-- name: GetProfileSchema :one
+SELECT * FROM tenant_profile_schemas WHERE tenant_id = $1 AND version = $2;
@@ -0,0 +1,55 @@
+-- name: CreateRole :one
+INSERT INTO roles (id, tenant_id, name, description)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetRole :one
+SELECT * FROM roles WHERE id = $1 AND tenant_id = $2;
+
+-- name: GetRoleByName :one
+SELECT * FROM roles WHERE tenant_id = $1 AND name = $2;
+
+-- name: ListRoles :many
+SELECT * FROM roles WHERE tenant_id = $1 ORDER BY name;
+
+-- name: DeleteRole :execrows
+DELETE FROM roles WHERE id = $1 AND tenant_id = $2;
+
+-- name: AddRolePermission :exec
+INSERT INTO role_permissions (role_id, permission)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveRolePermission :execrows
+DELETE FROM role_permissions WHERE role_id = $1 AND permission = $2;
+
+-- name: ListRolePermissions :many
+SELECT permission FROM role_permissions WHERE role_id = $1 ORDER BY permission;
+
+-- 角色可能已被删除或属于其他租户，由调用方先校验
+-- name: AssignUserRole :exec
+INSERT INTO user_roles (user_id, role_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnassignUserRole :execrows
+DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2;
+
+-- 用户的租户级角色，organization_id 非空时加上用户在该组织内的角色
+-- name: ListUserRoles :many
+SELECT r.* FROM roles r
+WHERE r.tenant_id = sqlc.arg(tenant_id) AND (
+    r.id IN (SELECT ur.role_id FROM user_roles ur WHERE ur.user_id = sqlc.arg(user_id))
+    OR r.id IN (SELECT mr.role_id FROM organization_member_roles mr WHERE mr.user_id = sqlc.arg(user_id) AND mr.organization_id = sqlc.narg(organization_id))
+)
+ORDER BY r.name;
+
+-- 用户通过 ListUserRoles 中的角色获得的权限，去重排序
+-- name: ListUserPermissions :many
+SELECT DISTINCT rp.permission FROM role_permissions rp
+JOIN roles r ON r.id = rp.role_id
+WHERE r.tenant_id = sqlc.arg(tenant_id) AND (
+    r.id IN (SELECT ur.role_id FROM user_roles ur WHERE ur.user_id = sqlc.arg(user_id))
+    OR r.id IN (SELECT mr.role_id FROM organization_member_roles mr WHERE mr.user_id = sqlc.arg(user_id) AND mr.organization_id = sqlc.narg(organization_id))
+)
+ORDER BY rp.permission;
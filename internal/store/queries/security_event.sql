@@ -0,0 +1,4 @@
+-- name: CreateSecurityEvent :exec
+INSERT INTO security_events (tenant_id, user_id, event_type, client_ip, user_agent, details)
+VALUES ($1, $2, $3, $4, $5, $6);
+
@@ -0,0 +1,27 @@
+-- name: CreateUserSession :one
+INSERT INTO user_sessions (id, user_id, tenant_id, client_ip, user_agent, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetUserSession :one
+SELECT * FROM user_sessions WHERE id = $1;
+
+-- name: ListActiveUserSessions :many
+SELECT * FROM user_sessions
+WHERE user_id = $1 AND tenant_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY created_at ASC;
+
+-- name: TouchUserSession :exec
+UPDATE user_sessions
+SET last_used_at = NOW(), client_ip = $2, user_agent = $3, expires_at = $4
+WHERE id = $1;
+
+-- name: RevokeUserSession :exec
+UPDATE user_sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeAllUserSessions :exec
+UPDATE user_sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -22,4 +22,12 @@ WHERE id = $1
 RETURNING *;
 
 -- name: DeleteTenant :exec
-DELETE FROM tenants WHERE id = $1; 
\ No newline at end of file
+DELETE FROM tenants WHERE id = $1; 
+-- name: UpdateTenantSettings :one
+UPDATE tenants SET settings = $2 WHERE id = $1
+RETURNING *;
+
+-- API密钥校验通过后升级过时的哈希，旧哈希已被替换时不覆盖
+-- name: RehashTenantSecretKey :exec
+UPDATE tenants SET api_secret_key_hash = sqlc.arg(new_hash)
+WHERE id = sqlc.arg(id) AND api_secret_key_hash = sqlc.arg(old_hash);
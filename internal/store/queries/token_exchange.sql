@@ -0,0 +1,15 @@
+-- name: GetTokenExchangePolicy :one
+SELECT * FROM token_exchange_policies WHERE client_id = $1 AND audience = $2;
+
+-- name: ListTokenExchangePolicies :many
+SELECT * FROM token_exchange_policies WHERE client_id = $1 ORDER BY audience;
+
+-- name: UpsertTokenExchangePolicy :one
+INSERT INTO token_exchange_policies (client_id, audience, subject_types, scopes)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (client_id, audience) DO UPDATE
+SET subject_types = EXCLUDED.subject_types, scopes = EXCLUDED.scopes, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteTokenExchangePolicy :execrows
+DELETE FROM token_exchange_policies WHERE client_id = $1 AND audience = $2;
@@ -0,0 +1,12 @@
+-- name: UpsertTokenRevocation :one
+INSERT INTO token_revocations (subject_type, subject, revoked_at, expires_at)
+VALUES ($1, $2, NOW(), $3)
+ON CONFLICT (subject_type, subject) DO UPDATE
+SET revoked_at = NOW(), expires_at = GREATEST(token_revocations.expires_at, EXCLUDED.expires_at)
+RETURNING *;
+
+-- name: ListActiveTokenRevocations :many
+SELECT * FROM token_revocations WHERE expires_at > NOW();
+
+-- name: DeleteExpiredTokenRevocations :execrows
+DELETE FROM token_revocations WHERE expires_at <= NOW();
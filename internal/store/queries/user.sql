@@ -4,47 +4,139 @@ VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
 -- name: GetUserByID :one
-SELECT * FROM users WHERE id = $1;
+SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users WHERE tenant_id = $1 AND email = $2;
+SELECT * FROM users WHERE tenant_id = $1 AND email = $2 AND deleted_at IS NULL;
 
--- name: GetUsersByTenant :many
-SELECT * FROM users WHERE tenant_id = $1 ORDER BY created_at DESC;
+-- 用户列表按排序方式分为四个查询，使游标条件和排序都能直接使用索引。
+-- 游标为上一页最后一行的排序键；同一租户下未删除用户的邮箱唯一，按邮箱排序无需 id 兜底。
+-- email_prefix 中的 LIKE 通配符由调用方转义；organization_id 非空时只返回该组织的成员
+-- name: ListUsersByCreatedAtDesc :many
+SELECT * FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (sqlc.narg(email_prefix)::text IS NULL OR email LIKE sqlc.narg(email_prefix) || '%')
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(profile)::jsonb IS NULL OR profile @> sqlc.narg(profile))
+  AND (sqlc.narg(organization_id)::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = sqlc.narg(organization_id)))
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.arg(cursor_id)::text))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(lim);
 
+-- name: ListUsersByCreatedAtAsc :many
+SELECT * FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (sqlc.narg(email_prefix)::text IS NULL OR email LIKE sqlc.narg(email_prefix) || '%')
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(profile)::jsonb IS NULL OR profile @> sqlc.narg(profile))
+  AND (sqlc.narg(organization_id)::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = sqlc.narg(organization_id)))
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.arg(cursor_id)::text))
+ORDER BY created_at, id
+LIMIT sqlc.arg(lim);
+
+-- name: ListUsersByEmailAsc :many
+SELECT * FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (sqlc.narg(email_prefix)::text IS NULL OR email LIKE sqlc.narg(email_prefix) || '%')
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(profile)::jsonb IS NULL OR profile @> sqlc.narg(profile))
+  AND (sqlc.narg(organization_id)::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = sqlc.narg(organization_id)))
+  AND (sqlc.narg(cursor_email)::text IS NULL OR email > sqlc.narg(cursor_email))
+ORDER BY email
+LIMIT sqlc.arg(lim);
+
+-- name: ListUsersByEmailDesc :many
+SELECT * FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (sqlc.narg(email_prefix)::text IS NULL OR email LIKE sqlc.narg(email_prefix) || '%')
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(profile)::jsonb IS NULL OR profile @> sqlc.narg(profile))
+  AND (sqlc.narg(organization_id)::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = sqlc.narg(organization_id)))
+  AND (sqlc.narg(cursor_email)::text IS NULL OR email < sqlc.narg(cursor_email))
+ORDER BY email DESC
+LIMIT sqlc.arg(lim);
+
+-- 全文搜索，表达式与 idx_users_search 保持一致
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE tenant_id = sqlc.arg(tenant_id) AND deleted_at IS NULL
+  AND (to_tsvector('simple', email || ' ' || replace(email, '@', ' ')) || jsonb_to_tsvector('simple', COALESCE(profile, '{}'::jsonb), '["string"]')) @@ to_tsquery('simple', sqlc.arg(query))
+  AND (sqlc.narg(organization_id)::text IS NULL OR EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.organization_id = sqlc.narg(organization_id)))
+ORDER BY ts_rank(to_tsvector('simple', email || ' ' || replace(email, '@', ' ')) || jsonb_to_tsvector('simple', COALESCE(profile, '{}'::jsonb), '["string"]'), to_tsquery('simple', sqlc.arg(query))) DESC, created_at DESC
+LIMIT sqlc.arg(lim);
+
+-- 密码通过 UpdateUserPassword 单独修改，以便记录修改时间
 -- name: UpdateUser :one
-UPDATE users 
-SET email = $3, hashed_password = $4, profile = $5
-WHERE id = $1 AND tenant_id = $2
+UPDATE users
+SET email = $3, profile = $4, email_verified = $5,
+    email_verified_at = CASE WHEN $5::boolean THEN email_verified_at ELSE NULL END
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
 RETURNING *;
 
--- name: DeleteUser :exec
+-- name: SetUserStatus :one
+UPDATE users SET status = $3
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: SoftDeleteUser :execrows
+UPDATE users SET deleted_at = NOW()
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL;
+
+-- name: DeleteUser :execrows
 DELETE FROM users WHERE id = $1 AND tenant_id = $2;
 
 -- name: GetUserCountByTenant :one
-SELECT COUNT(*) FROM users WHERE tenant_id = $1; 
+SELECT COUNT(*) FROM users WHERE tenant_id = $1 AND deleted_at IS NULL;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified = TRUE, email_verified_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $3, password_changed_at = NOW(), password_reset_required = FALSE
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- 登录校验通过后升级过时的哈希，不影响密码修改时间；旧哈希已被替换时不覆盖
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg(new_hash)
+WHERE id = sqlc.arg(id) AND hashed_password = sqlc.arg(old_hash);
+
+-- name: SetUserPasswordResetRequired :one
+UPDATE users
+SET password_reset_required = TRUE
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
 
 -- 用户Refresh Token表
 -- name: CreateRefreshToken :exec
-INSERT INTO user_refresh_tokens (user_id, token_hash, expires_at, created_at, client_ip, user_agent)
-VALUES ($1, $2, $3, NOW(), $4, $5);
+INSERT INTO user_refresh_tokens (user_id, tenant_id, family_id, parent_id, token_hash, amr, expires_at, created_at, client_ip, user_agent, client_id, scopes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), $8, $9, $10, $11);
 
--- name: GetRefreshToken :one
-SELECT * FROM user_refresh_tokens WHERE user_id = $1 AND token_hash = $2 AND expires_at > NOW();
+-- name: GetRefreshTokenByHash :one
+SELECT * FROM user_refresh_tokens WHERE token_hash = $1;
 
--- name: DeleteRefreshToken :exec
-DELETE FROM user_refresh_tokens WHERE user_id = $1 AND token_hash = $2;
+-- name: MarkRefreshTokenRotated :execrows
+UPDATE user_refresh_tokens
+SET rotated_at = NOW()
+WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE user_refresh_tokens
+SET revoked_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
 
 -- name: DeleteAllRefreshTokens :exec
 DELETE FROM user_refresh_tokens WHERE user_id = $1;
-
--- 表结构建议（请在migrations中建表）
--- CREATE TABLE user_refresh_tokens (
---   id SERIAL PRIMARY KEY,
---   user_id VARCHAR NOT NULL,
---   token_hash VARCHAR NOT NULL,
---   expires_at TIMESTAMP NOT NULL,
---   created_at TIMESTAMP NOT NULL,
---   client_ip VARCHAR,
---   user_agent VARCHAR
--- ); 
\ No newline at end of file
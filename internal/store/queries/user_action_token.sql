@@ -0,0 +1,38 @@
+-- name: CreateUserActionToken :exec
+INSERT INTO user_action_tokens (user_id, tenant_id, purpose, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeUserActionToken :one
+UPDATE user_action_tokens
+SET consumed_at = NOW()
+WHERE token_hash = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: InvalidateUserActionTokens :exec
+UPDATE user_action_tokens
+SET consumed_at = NOW()
+WHERE user_id = $1 AND purpose = $2 AND consumed_at IS NULL;
+
+-- name: CleanupExpiredUserActionTokens :exec
+DELETE FROM user_action_tokens WHERE expires_at < NOW();
+
+-- name: GetLatestActiveUserActionToken :one
+SELECT * FROM user_action_tokens
+WHERE user_id = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: IncrementUserActionTokenAttempts :one
+UPDATE user_action_tokens
+SET attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts;
+
+-- name: CountRecentUserActionTokens :one
+SELECT COUNT(*) FROM user_action_tokens
+WHERE user_id = $1 AND purpose = $2 AND created_at > $3;
+
+-- 校验令牌但不消费，用于在消费前先完成其他校验
+-- name: GetActiveUserActionToken :one
+SELECT * FROM user_action_tokens
+WHERE token_hash = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW();
@@ -0,0 +1,52 @@
+-- name: CreateUserErasure :one
+INSERT INTO user_erasures (user_id, tenant_id, requested_by, scheduled_at, disabled_user)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetUserErasure :one
+SELECT * FROM user_erasures WHERE user_id = $1 AND tenant_id = $2;
+
+-- name: CancelUserErasure :one
+DELETE FROM user_erasures
+WHERE user_id = $1 AND tenant_id = $2 AND erased_at IS NULL
+RETURNING *;
+
+-- name: ListDueUserErasures :many
+SELECT * FROM user_erasures
+WHERE erased_at IS NULL AND scheduled_at <= NOW()
+ORDER BY scheduled_at
+LIMIT $1;
+
+-- name: MarkUserErased :exec
+UPDATE user_erasures SET erased_at = NOW() WHERE user_id = $1;
+
+-- 包括已软删除的用户，用于数据导出和删除
+-- name: GetUserIncludingDeleted :one
+SELECT * FROM users WHERE id = $1 AND tenant_id = $2;
+
+-- 用户本人的事件，以及按 "<tenant_id>:<email>" 记录的账号锁定事件
+-- name: ListUserSecurityEvents :many
+SELECT * FROM security_events
+WHERE user_id = sqlc.arg(user_id)::text
+   OR (tenant_id = sqlc.arg(tenant_id)::text AND details->>'subject' = sqlc.arg(lockout_subject)::text)
+ORDER BY created_at, id;
+
+-- name: DeleteUserSecurityEvents :exec
+DELETE FROM security_events
+WHERE user_id = sqlc.arg(user_id)::text
+   OR (tenant_id = sqlc.arg(tenant_id)::text AND details->>'subject' = sqlc.arg(lockout_subject)::text);
+
+-- name: ListUserSessions :many
+SELECT * FROM user_sessions
+WHERE user_id = $1 AND tenant_id = $2
+ORDER BY created_at;
+
+-- name: ListUserRefreshTokens :many
+SELECT * FROM user_refresh_tokens
+WHERE user_id = $1 AND tenant_id = $2
+ORDER BY created_at, id;
+
+-- name: DeleteUserImportErrorsByEmail :exec
+DELETE FROM user_import_errors e
+USING user_import_jobs j
+WHERE e.job_id = j.id AND j.tenant_id = sqlc.arg(tenant_id) AND lower(e.email) = lower(sqlc.arg(email));
@@ -0,0 +1,48 @@
+-- name: CreateUserImportJob :one
+INSERT INTO user_import_jobs (id, tenant_id, format, payload)
+VALUES ($1, $2, $3, $4)
+RETURNING id, tenant_id, format, status, processed_rows, created_count, skipped_count, failed_count, error, created_at, started_at, finished_at;
+
+-- name: GetUserImportJob :one
+SELECT id, tenant_id, format, status, processed_rows, created_count, skipped_count, failed_count, error, created_at, started_at, finished_at
+FROM user_import_jobs WHERE id = $1 AND tenant_id = $2;
+
+-- 领取一个待处理的任务，或心跳已超时（处理实例已退出）的运行中任务
+-- name: ClaimUserImportJob :one
+UPDATE user_import_jobs
+SET status = 'running', started_at = COALESCE(started_at, NOW()), heartbeat_at = NOW()
+WHERE id = (
+    SELECT j.id FROM user_import_jobs j
+    WHERE j.status = 'pending' OR (j.status = 'running' AND j.heartbeat_at < sqlc.arg(stale_before))
+    ORDER BY j.created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateUserImportJobProgress :exec
+UPDATE user_import_jobs
+SET processed_rows = $2, created_count = $3, skipped_count = $4, failed_count = $5, heartbeat_at = NOW()
+WHERE id = $1;
+
+-- name: FinishUserImportJob :exec
+UPDATE user_import_jobs
+SET status = $2, error = $3, payload = NULL, finished_at = NOW()
+WHERE id = $1;
+
+-- name: CreateUserImportError :exec
+INSERT INTO user_import_errors (job_id, row_number, email, error)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (job_id, row_number) DO NOTHING;
+
+-- name: ListUserImportErrors :many
+SELECT * FROM user_import_errors
+WHERE job_id = $1 AND row_number > sqlc.arg(after_row)
+ORDER BY row_number
+LIMIT sqlc.arg(lim);
+
+-- 导入用户时保留原有的密码哈希；同一租户下已存在的邮箱跳过，因此重复导入是安全的
+-- name: CreateImportedUser :execrows
+INSERT INTO users (id, tenant_id, email, hashed_password, profile, email_verified, email_verified_at)
+VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $6::boolean THEN NOW() END)
+ON CONFLICT (tenant_id, email) WHERE deleted_at IS NULL DO NOTHING;
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"yuyu-test/internal/auth"
 	"yuyu-test/internal/store/database"
@@ -13,12 +14,13 @@ import (
 
 // Service 租户服务
 type Service struct {
-	db database.Querier
+	db     database.Querier
+	hasher *auth.PasswordHasher
 }
 
 // NewService 创建新的租户服务
-func NewService(db database.Querier) *Service {
-	return &Service{db: db}
+func NewService(db database.Querier, hasher *auth.PasswordHasher) *Service {
+	return &Service{db: db, hasher: hasher}
 }
 
 // CreateTenantRequest 创建租户请求
@@ -40,12 +42,14 @@ func (s *Service) CreateTenant(ctx context.Context, req CreateTenantRequest) (*C
 	// 生成租户ID
 	tenantID := generateID("tnt")
 
-	// 生成API密钥
+	// 生成API密钥。密钥哈希带盐无法直接查找，
+	// 私钥以 "<公钥>.<随机串>" 的形式下发，校验时先按公钥定位租户
 	publicKey := generateAPIKey()
-	secretKey := generateAPIKey()
+	secret := generateAPIKey()
+	secretKey := publicKey + "." + secret
 
 	// 哈希密钥
-	secretKeyHash, err := auth.HashPassword(secretKey)
+	secretKeyHash, err := s.hasher.Hash(secret)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash secret key: %w", err)
 	}
@@ -81,23 +85,48 @@ func (s *Service) GetTenantByID(ctx context.Context, tenantID string) (*database
 	return &tenant, nil
 }
 
-// ValidateAPIKey 验证API密钥
+// ValidateAPIKey 验证API密钥，支持公钥和 "<公钥>.<随机串>" 形式的私钥
 func (s *Service) ValidateAPIKey(ctx context.Context, apiKey string) (*database.Tenant, error) {
-	// 先尝试通过公钥查找
-	tenant, err := s.db.GetTenantByPublicKey(ctx, apiKey)
-	if err == nil {
-		return &tenant, nil
-	}
+	publicKey, secret, isSecret := strings.Cut(apiKey, ".")
 
-	// 如果公钥不匹配，尝试通过密钥哈希查找
-	tenant, err = s.db.GetTenantBySecretKeyHash(ctx, apiKey)
+	tenant, err := s.db.GetTenantByPublicKey(ctx, publicKey)
 	if err != nil {
+		if isSecret {
+			s.hasher.VerifyDummy(secret)
+		}
 		return nil, fmt.Errorf("invalid API key")
 	}
+	if !isSecret {
+		return &tenant, nil
+	}
 
+	match, rehash := s.hasher.Verify(secret, tenant.ApiSecretKeyHash)
+	if !match {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	if rehash {
+		s.rehashSecretKey(ctx, tenant, secret)
+	}
 	return &tenant, nil
 }
 
+// rehashSecretKey 用当前哈希配置重新哈希过时的私钥，失败只记日志
+func (s *Service) rehashSecretKey(ctx context.Context, tenant database.Tenant, secret string) {
+	hash, err := s.hasher.Hash(secret)
+	if err == nil {
+		err = s.db.RehashTenantSecretKey(ctx, database.RehashTenantSecretKeyParams{
+			NewHash: hash,
+			ID:      tenant.ID,
+			OldHash: tenant.ApiSecretKeyHash,
+		})
+	}
+	if err != nil {
+		slog.Error("Failed to rehash tenant secret key", "tenant_id", tenant.ID, "error", err)
+		return
+	}
+	slog.Info("Tenant secret key rehashed", "tenant_id", tenant.ID)
+}
+
 // ListTenants 获取所有租户
 func (s *Service) ListTenants(ctx context.Context) ([]database.Tenant, error) {
 	return s.db.ListTenants(ctx)
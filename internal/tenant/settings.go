@@ -0,0 +1,132 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"yuyu-test/internal/store/database"
+)
+
+// Settings 租户级策略配置，持久化在 tenants.settings (JSONB)
+type Settings struct {
+	// RequireEmailVerification 为true时，未验证邮箱的用户无法登录
+	RequireEmailVerification bool `json:"require_email_verification"`
+	// EmailVerificationURL 验证邮件中的链接地址，令牌以 token 查询参数附加；为空时邮件中只包含令牌
+	EmailVerificationURL string `json:"email_verification_url,omitempty"`
+	// PasswordResetURL 重置密码邮件中的链接地址，令牌以 token 查询参数附加；为空时邮件中只包含令牌
+	PasswordResetURL string `json:"password_reset_url,omitempty"`
+	// MFARequired 为true时，所有用户登录都必须完成第二因素，未绑定的用户在登录时被要求先绑定
+	MFARequired bool `json:"mfa_required"`
+	// PasswordlessEnabled 为true时允许通过邮件魔法链接或验证码免密码登录
+	PasswordlessEnabled bool `json:"passwordless_enabled"`
+	// PasswordlessURL 魔法链接地址，令牌以 token 查询参数附加；为空时邮件中只包含令牌
+	PasswordlessURL string `json:"passwordless_url,omitempty"`
+	// SignupMode 终端用户自助注册方式：open（默认，开放注册）、invite_only（仅能通过邀请注册）或 allowed_domains（仅允许 AllowedEmailDomains 中的邮箱域名）。
+	// 内部服务创建用户、批量导入和接受邀请注册不受限制
+	SignupMode string `json:"signup_mode,omitempty" binding:"omitempty,oneof=open invite_only allowed_domains"`
+	// AllowedEmailDomains SignupMode 为 allowed_domains 时允许自助注册的邮箱域名（不区分大小写，不含子域名）
+	AllowedEmailDomains []string `json:"allowed_email_domains,omitempty"`
+	// InvitationURL 邀请邮件中的链接地址，令牌以 token 查询参数附加；为空时邮件中只包含令牌
+	InvitationURL string `json:"invitation_url,omitempty"`
+	// WebAuthnRPID WebAuthn依赖方ID（如 example.com），为空时该租户不启用Passkey
+	WebAuthnRPID string `json:"webauthn_rp_id,omitempty"`
+	// WebAuthnRPName 认证器中展示的依赖方名称，为空时使用租户名称
+	WebAuthnRPName string `json:"webauthn_rp_name,omitempty"`
+	// WebAuthnOrigins 允许发起WebAuthn仪式的来源，为空时默认 https://<rp_id>
+	WebAuthnOrigins []string `json:"webauthn_origins,omitempty"`
+	// MaxSessions 每个用户允许的最大并发会话数，0表示不限制，最大100
+	MaxSessions int `json:"max_sessions,omitempty" binding:"min=0,max=100"`
+	// SessionLimitPolicy 会话数达到上限时的处理方式：evict_oldest（默认，注销最早的会话）或 reject（拒绝新登录）
+	SessionLimitPolicy string `json:"session_limit_policy,omitempty" binding:"omitempty,oneof=evict_oldest reject"`
+	// ErasureGraceDays 用户数据删除请求的宽限天数，期满后删除用户及其全部数据，默认30
+	ErasureGraceDays int `json:"erasure_grace_days,omitempty"`
+	// PasswordPolicy 密码策略，注册、修改/重置密码和内部创建用户时校验
+	PasswordPolicy PasswordPolicy `json:"password_policy"`
+}
+
+// PasswordPolicy 租户密码策略，字段为零值时使用默认值或不限制
+type PasswordPolicy struct {
+	// MinLength 最小长度（字符数），默认6
+	MinLength int `json:"min_length,omitempty"`
+	// MaxLength 最大长度（字符数），默认且最大128；使用bcrypt时另受72字节限制
+	MaxLength int `json:"max_length,omitempty"`
+	// RequireUppercase 必须包含大写字母
+	RequireUppercase bool `json:"require_uppercase,omitempty"`
+	// RequireLowercase 必须包含小写字母
+	RequireLowercase bool `json:"require_lowercase,omitempty"`
+	// RequireDigit 必须包含数字
+	RequireDigit bool `json:"require_digit,omitempty"`
+	// RequireSymbol 必须包含字母数字以外的字符
+	RequireSymbol bool `json:"require_symbol,omitempty"`
+	// HistoryCount 不允许与最近N次使用过的密码（含当前密码）相同，0表示不限制，最大24
+	HistoryCount int `json:"history_count,omitempty" binding:"min=0,max=24"`
+	// MaxAgeDays 密码有效天数，过期后需重置密码才能使用密码登录，0表示永不过期
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+	// AllowBreached 为true时不检查泄露密码库。服务端未配置泄露密码库时不检查
+	AllowBreached bool `json:"allow_breached,omitempty"`
+}
+
+// 密码策略默认值
+const (
+	DefaultPasswordMinLength = 6
+	DefaultPasswordMaxLength = 128
+)
+
+// DefaultErasureGraceDays 未配置 ErasureGraceDays 时的宽限天数
+const DefaultErasureGraceDays = 30
+
+// 终端用户自助注册方式
+const (
+	SignupOpen           = "open"
+	SignupInviteOnly     = "invite_only"
+	SignupAllowedDomains = "allowed_domains"
+)
+
+// 会话数达到上限时的处理方式
+const (
+	SessionLimitEvictOldest = "evict_oldest"
+	SessionLimitReject      = "reject"
+)
+
+// ParseSettings 解析租户配置，空值返回默认配置
+func ParseSettings(raw json.RawMessage) (*Settings, error) {
+	settings := &Settings{}
+	if len(raw) == 0 {
+		return settings, nil
+	}
+	if err := json.Unmarshal(raw, settings); err != nil {
+		return nil, fmt.Errorf("invalid tenant settings: %w", err)
+	}
+	return settings, nil
+}
+
+// GetSettings 获取租户配置
+func (s *Service) GetSettings(ctx context.Context, tenantID string) (*Settings, error) {
+	tenant, err := s.db.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return ParseSettings(tenant.Settings)
+}
+
+// UpdateSettings 整体替换租户配置
+func (s *Service) UpdateSettings(ctx context.Context, tenantID string, settings Settings) (*Settings, error) {
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	tenant, err := s.db.UpdateTenantSettings(ctx, database.UpdateTenantSettingsParams{
+		ID:       tenantID,
+		Settings: raw,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update tenant settings: %w", err)
+	}
+
+	slog.Info("Tenant settings updated", "tenant_id", tenantID)
+
+	return ParseSettings(tenant.Settings)
+}
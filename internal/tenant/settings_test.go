@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestSettingsValidation(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings Settings
+		valid    bool
+	}{
+		{"defaults", Settings{}, true},
+		{"session limit", Settings{MaxSessions: 5, SessionLimitPolicy: SessionLimitReject}, true},
+		{"negative max sessions", Settings{MaxSessions: -1}, false},
+		{"too many sessions", Settings{MaxSessions: 101}, false},
+		{"unknown session limit policy", Settings{MaxSessions: 5, SessionLimitPolicy: "drop_newest"}, false},
+		{"password history", Settings{PasswordPolicy: PasswordPolicy{HistoryCount: 24}}, true},
+		{"negative password history", Settings{PasswordPolicy: PasswordPolicy{HistoryCount: -1}}, false},
+		{"password history too long", Settings{PasswordPolicy: PasswordPolicy{HistoryCount: 25}}, false},
+	}
+	for _, tc := range cases {
+		err := binding.Validator.ValidateStruct(&tc.settings)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%v, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/claimsmap"
+	"yuyu-test/internal/store/database"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrClaimsMappingNotFound 租户未配置声明映射
+var ErrClaimsMappingNotFound = errors.New("claims mapping not found")
+
+// ClaimsMappingResponse 租户的声明映射
+type ClaimsMappingResponse struct {
+	TenantID  string          `json:"tenant_id"`
+	Mapping   json.RawMessage `json:"mapping"`
+	UpdatedAt string          `json:"updated_at"`
+}
+
+func toClaimsMappingResponse(m database.TenantClaimsMapping) *ClaimsMappingResponse {
+	return &ClaimsMappingResponse{
+		TenantID:  m.TenantID,
+		Mapping:   m.Mapping,
+		UpdatedAt: m.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// GetClaimsMapping 获取租户的声明映射
+func (s *Service) GetClaimsMapping(ctx context.Context, tenantID string) (*ClaimsMappingResponse, error) {
+	m, err := s.db.GetClaimsMapping(ctx, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrClaimsMappingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get claims mapping: %w", err)
+	}
+	return toClaimsMappingResponse(m), nil
+}
+
+// SetClaimsMapping 设置租户的声明映射，对之后签发的访问令牌生效
+func (s *Service) SetClaimsMapping(ctx context.Context, tenantID string, raw json.RawMessage) (*ClaimsMappingResponse, error) {
+	if _, err := claimsmap.Parse(raw); err != nil {
+		return nil, err
+	}
+	m, err := s.db.UpsertClaimsMapping(ctx, database.UpsertClaimsMappingParams{TenantID: tenantID, Mapping: raw})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save claims mapping: %w", err)
+	}
+	slog.Info("Claims mapping updated", "tenant_id", tenantID)
+	return toClaimsMappingResponse(m), nil
+}
+
+// DeleteClaimsMapping 删除租户的声明映射，之后签发的访问令牌恢复默认声明
+func (s *Service) DeleteClaimsMapping(ctx context.Context, tenantID string) error {
+	n, err := s.db.DeleteClaimsMapping(ctx, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to delete claims mapping: %w", err)
+	}
+	if n == 0 {
+		return ErrClaimsMappingNotFound
+	}
+	slog.Info("Claims mapping deleted", "tenant_id", tenantID)
+	return nil
+}
+
+// mapClaims 按租户的声明映射生成签发用的声明，租户未配置映射时原样返回
+func (s *Service) mapClaims(ctx context.Context, user database.User, claims *auth.Claims) (jwt.Claims, error) {
+	stored, err := s.db.GetClaimsMapping(ctx, user.TenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return claims, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get claims mapping: %w", err)
+	}
+	mapping, err := claimsmap.Parse(stored.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal claims: %w", err)
+	}
+	mapped := jwt.MapClaims{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&mapped); err != nil {
+		return nil, fmt.Errorf("failed to decode claims: %w", err)
+	}
+
+	src := claimsmap.Source{Roles: claims.Roles, Permissions: claims.Permissions}
+	if user.Profile.Valid && len(user.Profile.RawMessage) > 0 {
+		_ = json.Unmarshal(user.Profile.RawMessage, &src.Profile)
+	}
+	if skipped := mapping.Apply(mapped, src); len(skipped) > 0 {
+		slog.Warn("Custom claims exceed the token size limit and were omitted", "user_id", user.ID, "tenant_id", user.TenantID, "claims", skipped)
+	}
+	return mapped, nil
+}
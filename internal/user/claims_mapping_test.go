@@ -0,0 +1,79 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/claimsmap"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClaimsMapping(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	kai, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123", Profile: map[string]interface{}{"department": "R&D"}})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.SetClaimsMapping(ctx, "tnt_test", json.RawMessage(`{"rename": {"user_id": "uid"}}`)); !errors.Is(err, claimsmap.ErrInvalidMapping) {
+		t.Fatalf("expected ErrInvalidMapping, got %v", err)
+	}
+	if _, err := svc.SetClaimsMapping(ctx, "tnt_test", json.RawMessage(`{
+		"rename": {"email": "mail"},
+		"claims": [
+			{"name": "department", "source": "profile", "field": "department"},
+			{"name": "plan", "source": "static", "value": "pro"}
+		]
+	}`)); err != nil {
+		t.Fatalf("SetClaimsMapping: %v", err)
+	}
+
+	resp, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "kai@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	refreshed, err := svc.RefreshTokens(ctx, "tnt_test", resp.RefreshToken, "", "")
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	impersonated, err := svc.Impersonate(ctx, "tnt_test", kai.ID, "support-tool", ImpersonateRequest{Reason: "ticket 42"}, "", "")
+	if err != nil {
+		t.Fatalf("Impersonate: %v", err)
+	}
+
+	for name, token := range map[string]string{"login": resp.Token, "refresh": refreshed.Token, "impersonate": impersonated.Token} {
+		claims := jwt.MapClaims{}
+		if err := svc.signer.Parse(token, claims); err != nil {
+			t.Fatalf("%s: parse token: %v", name, err)
+		}
+		if claims["mail"] != "kai@example.com" || claims["department"] != "R&D" || claims["plan"] != "pro" {
+			t.Fatalf("%s: unexpected claims: %v", name, claims)
+		}
+		if _, ok := claims["email"]; ok {
+			t.Fatalf("%s: expected email to be renamed", name)
+		}
+		// 令牌仍可按默认声明结构解析
+		if c := accessTokenClaims(t, svc, token); c.UserID != kai.ID || c.TenantID != "tnt_test" {
+			t.Fatalf("%s: unexpected user claims: %+v", name, c)
+		}
+	}
+
+	// 删除映射后恢复默认声明
+	if err := svc.DeleteClaimsMapping(ctx, "tnt_test"); err != nil {
+		t.Fatalf("DeleteClaimsMapping: %v", err)
+	}
+	if _, err := svc.GetClaimsMapping(ctx, "tnt_test"); !errors.Is(err, ErrClaimsMappingNotFound) {
+		t.Fatalf("expected ErrClaimsMappingNotFound, got %v", err)
+	}
+	resp, err = svc.Login(ctx, "tnt_test", LoginRequest{Email: "kai@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if c := accessTokenClaims(t, svc, resp.Token); c.Email != "kai@example.com" {
+		t.Fatalf("expected default email claim, got %+v", c)
+	}
+}
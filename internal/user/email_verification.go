@@ -0,0 +1,197 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/mailer"
+	"yuyu-test/internal/store/database"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// purposeEmailVerification 邮箱验证令牌用途
+	purposeEmailVerification = "email_verification"
+	// emailVerificationTTL 邮箱验证令牌有效期
+	emailVerificationTTL = 24 * time.Hour
+)
+
+// ErrInvalidVerificationToken 验证令牌无效、过期或已使用
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// VerifyEmailRequest 邮箱验证请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest 重新发送验证邮件请求
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// hashToken 计算令牌的SHA-256哈希（十六进制），数据库中只保存哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%x", sum[:])
+}
+
+// tokenLink 把令牌作为 token 查询参数附加到租户配置的链接上
+func tokenLink(baseURL, token string) (string, bool) {
+	if baseURL == "" {
+		return "", false
+	}
+	link, err := url.Parse(baseURL)
+	if err != nil {
+		return "", false
+	}
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+	return link.String(), true
+}
+
+// sendVerificationEmail 生成签名的一次性验证令牌并发送验证邮件
+func (s *Service) sendVerificationEmail(ctx context.Context, user database.User) error {
+	if user.EmailVerified {
+		return nil
+	}
+
+	settings, err := s.tenantSettings(ctx, user.TenantID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(emailVerificationTTL)
+	claims := auth.PurposeClaims{
+		Purpose:  purposeEmailVerification,
+		TenantID: user.TenantID,
+		Email:    user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			ID:        generateID("evt"),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	token, err := s.signer.Sign(&claims)
+	if err != nil {
+		return fmt.Errorf("failed to sign verification token: %w", err)
+	}
+
+	// 旧令牌作废，保证同一时间只有最新一封邮件有效
+	if err := s.db.InvalidateUserActionTokens(ctx, database.InvalidateUserActionTokensParams{
+		UserID:  user.ID,
+		Purpose: purposeEmailVerification,
+	}); err != nil {
+		return fmt.Errorf("failed to invalidate old verification tokens: %w", err)
+	}
+	if err := s.db.CreateUserActionToken(ctx, database.CreateUserActionTokenParams{
+		UserID:    user.ID,
+		TenantID:  user.TenantID,
+		Purpose:   purposeEmailVerification,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	body := "Please verify your email address with the following token:\n\n" + token + "\n"
+	if link, ok := tokenLink(settings.EmailVerificationURL, token); ok {
+		body = "Please verify your email address by opening the following link:\n\n" + link + "\n"
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    body,
+	})
+}
+
+// VerifyEmail 校验验证令牌并标记邮箱已验证
+func (s *Service) VerifyEmail(ctx context.Context, tenantID string, req VerifyEmailRequest) (*RegisterResponse, error) {
+	claims, err := auth.ParsePurposeToken(s.signer, req.Token, purposeEmailVerification)
+	if err != nil || claims.TenantID != tenantID {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// 原子地消费令牌，保证一次性
+	token, err := s.db.ConsumeUserActionToken(ctx, database.ConsumeUserActionTokenParams{
+		TokenHash: hashToken(req.Token),
+		Purpose:   purposeEmailVerification,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, fmt.Errorf("failed to consume verification token: %w", err)
+	}
+	if token.UserID != claims.Subject {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.db.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	// 令牌签发后邮箱被修改，则旧邮箱的验证无效
+	if user.Email != claims.Email {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err = s.db.MarkUserEmailVerified(ctx, database.MarkUserEmailVerifiedParams{
+		ID:       token.UserID,
+		TenantID: tenantID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	slog.Info("User email verified", "user_id", user.ID, "tenant_id", tenantID)
+
+	return toUserResponse(user), nil
+}
+
+// ResendVerificationEmail 重新发送验证邮件。
+// 用户不存在或已验证时静默返回，避免泄露账号是否存在
+func (s *Service) ResendVerificationEmail(ctx context.Context, tenantID string, req ResendVerificationRequest) error {
+	user, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
+		TenantID: tenantID,
+		Email:    req.Email,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// RunTokenCleanup 定期删除过期的一次性令牌（邮箱验证、密码重置、免密登录、通行密钥挑战等），直到 ctx 取消
+func (s *Service) RunTokenCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.db.CleanupExpiredUserActionTokens(ctx); err != nil {
+				slog.Error("Failed to delete expired user action tokens", "error", err)
+			}
+		}
+	}
+}
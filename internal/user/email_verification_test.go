@@ -0,0 +1,88 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/auth"
+)
+
+func TestEmailVerificationFlow(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{"require_email_verification": true}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "alice@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if registered.EmailVerified {
+		t.Fatal("new user should not be verified")
+	}
+	if len(mail.messages) != 1 || mail.messages[0].To != "alice@example.com" {
+		t.Fatalf("expected one verification email to alice, got %+v", mail.messages)
+	}
+
+	// 未验证时登录被拒绝
+	_, err = svc.Login(ctx, "tnt_test", LoginRequest{Email: "alice@example.com", Password: "password123"}, "", "")
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected ErrEmailNotVerified, got %v", err)
+	}
+
+	token := lastToken(t, mail)
+	verified, err := svc.VerifyEmail(ctx, "tnt_test", VerifyEmailRequest{Token: token})
+	if err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if !verified.EmailVerified {
+		t.Fatal("user should be verified")
+	}
+
+	// 令牌只能使用一次
+	if _, err := svc.VerifyEmail(ctx, "tnt_test", VerifyEmailRequest{Token: token}); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("expected ErrInvalidVerificationToken on reuse, got %v", err)
+	}
+
+	resp, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "alice@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login after verification: %v", err)
+	}
+	claims := &auth.Claims{}
+	if err := svc.signer.Parse(resp.Token, claims); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	if !claims.EmailVerified {
+		t.Fatal("access token should carry email_verified=true")
+	}
+}
+
+func TestResendVerificationInvalidatesPreviousToken(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{}`)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "bob@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	first := lastToken(t, mail)
+
+	if err := svc.ResendVerificationEmail(ctx, "tnt_test", ResendVerificationRequest{Email: "bob@example.com"}); err != nil {
+		t.Fatalf("ResendVerificationEmail: %v", err)
+	}
+	second := lastToken(t, mail)
+
+	if _, err := svc.VerifyEmail(ctx, "tnt_test", VerifyEmailRequest{Token: first}); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("expected old token to be rejected, got %v", err)
+	}
+	if _, err := svc.VerifyEmail(ctx, "tnt_test", VerifyEmailRequest{Token: second}); err != nil {
+		t.Fatalf("VerifyEmail with new token: %v", err)
+	}
+
+	// 未知邮箱静默成功，不发送邮件
+	sent := len(mail.messages)
+	if err := svc.ResendVerificationEmail(ctx, "tnt_test", ResendVerificationRequest{Email: "nobody@example.com"}); err != nil {
+		t.Fatalf("ResendVerificationEmail for unknown email: %v", err)
+	}
+	if len(mail.messages) != sent {
+		t.Fatal("no email should be sent for unknown accounts")
+	}
+}
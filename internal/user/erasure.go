@@ -0,0 +1,234 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"yuyu-test/internal/lockout"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+)
+
+// 数据删除请求的发起方
+const (
+	ErasureRequestedByUser  = "user"
+	ErasureRequestedByAdmin = "admin"
+)
+
+// erasureBatchSize 每轮最多执行的到期删除请求数
+const erasureBatchSize = 100
+
+// ErrErasureNotFound 用户没有数据删除请求，或请求已执行无法撤销
+var ErrErasureNotFound = errors.New("erasure request not found")
+
+// ErasureResponse 数据删除请求，erased_at 非空表示已执行
+type ErasureResponse struct {
+	UserID      string `json:"user_id"`
+	RequestedBy string `json:"requested_by"`
+	RequestedAt string `json:"requested_at"`
+	ScheduledAt string `json:"scheduled_at"`
+	ErasedAt    string `json:"erased_at,omitempty"`
+}
+
+func toErasureResponse(e database.UserErasure) *ErasureResponse {
+	resp := &ErasureResponse{
+		UserID:      e.UserID,
+		RequestedBy: e.RequestedBy,
+		RequestedAt: e.RequestedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ScheduledAt: e.ScheduledAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if e.ErasedAt.Valid {
+		resp.ErasedAt = e.ErasedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return resp
+}
+
+// userIncludingDeleted 获取属于指定租户的用户，包括已软删除的用户
+func (s *Service) userIncludingDeleted(ctx context.Context, tenantID, userID string) (database.User, error) {
+	user, err := s.db.GetUserIncludingDeleted(ctx, database.GetUserIncludingDeletedParams{ID: userID, TenantID: tenantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// RequestErasure 申请删除用户的全部数据。用户立即被停用并注销所有会话，
+// 租户配置的宽限期满后由 RunErasures 执行删除。已有请求时返回原请求
+func (s *Service) RequestErasure(ctx context.Context, tenantID, userID, requestedBy string) (*ErasureResponse, error) {
+	user, err := s.userIncludingDeleted(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.db.GetUserErasure(ctx, database.GetUserErasureParams{UserID: userID, TenantID: tenantID})
+	if err == nil {
+		return toErasureResponse(existing), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get erasure request: %w", err)
+	}
+
+	settings, err := s.tenantSettings(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	graceDays := settings.ErasureGraceDays
+	if graceDays <= 0 {
+		graceDays = tenant.DefaultErasureGraceDays
+	}
+
+	// 记录是否由本请求停用，撤销时不能恢复此前已被管理员停用的用户
+	disabled := !user.DeletedAt.Valid && user.Status != StatusDisabled
+	if disabled {
+		if _, err := s.setStatus(ctx, user, StatusDisabled); err != nil {
+			return nil, err
+		}
+	} else if err := s.revokeAllSessions(ctx, user); err != nil {
+		return nil, err
+	}
+
+	erasure, err := s.db.CreateUserErasure(ctx, database.CreateUserErasureParams{
+		UserID:       userID,
+		TenantID:     tenantID,
+		RequestedBy:  requestedBy,
+		ScheduledAt:  time.Now().AddDate(0, 0, graceDays),
+		DisabledUser: disabled,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create erasure request: %w", err)
+	}
+
+	slog.Info("User erasure requested", "user_id", userID, "tenant_id", tenantID, "requested_by", requestedBy, "scheduled_at", erasure.ScheduledAt)
+
+	return toErasureResponse(erasure), nil
+}
+
+// GetErasure 查询用户的数据删除请求。用户数据删除后仍可查询，作为审计记录
+func (s *Service) GetErasure(ctx context.Context, tenantID, userID string) (*ErasureResponse, error) {
+	erasure, err := s.db.GetUserErasure(ctx, database.GetUserErasureParams{UserID: userID, TenantID: tenantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrErasureNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get erasure request: %w", err)
+	}
+	return toErasureResponse(erasure), nil
+}
+
+// CancelErasure 在宽限期内撤销数据删除请求。申请时由请求停用的用户恢复为 active，
+// 申请前已被停用的用户保持停用
+func (s *Service) CancelErasure(ctx context.Context, tenantID, userID string) error {
+	cancelled, err := s.db.CancelUserErasure(ctx, database.CancelUserErasureParams{UserID: userID, TenantID: tenantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrErasureNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel erasure request: %w", err)
+	}
+
+	if cancelled.DisabledUser {
+		user, err := s.userIncludingDeleted(ctx, tenantID, userID)
+		if err != nil {
+			return err
+		}
+		if !user.DeletedAt.Valid && user.Status == StatusDisabled {
+			if _, err := s.setStatus(ctx, user, StatusActive); err != nil {
+				return err
+			}
+		}
+	}
+
+	slog.Info("User erasure cancelled", "user_id", userID, "tenant_id", tenantID)
+	return nil
+}
+
+// RunErasures 定期执行宽限期已满的数据删除请求，直到 ctx 取消
+func (s *Service) RunErasures(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ProcessErasures(ctx)
+		}
+	}
+}
+
+// ProcessErasures 执行一批到期的数据删除请求。删除是幂等的，失败的请求在下一轮重试
+func (s *Service) ProcessErasures(ctx context.Context) {
+	due, err := s.db.ListDueUserErasures(ctx, erasureBatchSize)
+	if err != nil {
+		slog.Error("Failed to list due user erasures", "error", err)
+		return
+	}
+	for _, erasure := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.eraseUser(ctx, erasure); err != nil {
+			slog.Error("Failed to erase user", "user_id", erasure.UserID, "tenant_id", erasure.TenantID, "error", err)
+		}
+	}
+}
+
+// eraseUser 删除用户及其全部关联数据，只保留删除请求本身作为审计记录。
+// 会话、MFA、Passkey、历史密码等随 users 行级联删除，其余记录逐一清理
+func (s *Service) eraseUser(ctx context.Context, erasure database.UserErasure) error {
+	var lockoutSubject string
+	user, err := s.userIncludingDeleted(ctx, erasure.TenantID, erasure.UserID)
+	switch {
+	case err == nil:
+		if err := s.revokeAllSessions(ctx, user); err != nil {
+			return err
+		}
+		key := lockout.AccountKey(user.TenantID, user.Email)
+		if err := s.guard.Unlock(ctx, key); err != nil {
+			return err
+		}
+		lockoutSubject = key.Subject
+		if err := s.db.DeleteUserImportErrorsByEmail(ctx, database.DeleteUserImportErrorsByEmailParams{
+			TenantID: user.TenantID,
+			Email:    user.Email,
+		}); err != nil {
+			return fmt.Errorf("failed to delete import errors: %w", err)
+		}
+		if err := s.db.DeleteInvitationsByEmail(ctx, database.DeleteInvitationsByEmailParams{
+			TenantID: user.TenantID,
+			Email:    user.Email,
+		}); err != nil {
+			return fmt.Errorf("failed to delete invitations: %w", err)
+		}
+	case errors.Is(err, ErrUserNotFound):
+		// 用户已被硬删除，仍需清理不随 users 级联删除的记录
+		if err := s.db.DeleteAllRefreshTokens(ctx, erasure.UserID); err != nil {
+			return fmt.Errorf("failed to delete refresh tokens: %w", err)
+		}
+	default:
+		return err
+	}
+
+	if err := s.db.DeleteUserSecurityEvents(ctx, database.DeleteUserSecurityEventsParams{
+		UserID:         erasure.UserID,
+		TenantID:       erasure.TenantID,
+		LockoutSubject: lockoutSubject,
+	}); err != nil {
+		return fmt.Errorf("failed to delete security events: %w", err)
+	}
+	if _, err := s.db.DeleteUser(ctx, database.DeleteUserParams{ID: erasure.UserID, TenantID: erasure.TenantID}); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if err := s.db.MarkUserErased(ctx, erasure.UserID); err != nil {
+		return fmt.Errorf("failed to mark user erased: %w", err)
+	}
+
+	slog.Info("User erased", "user_id", erasure.UserID, "tenant_id", erasure.TenantID, "requested_by", erasure.RequestedBy)
+	return nil
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserErasure(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{"erasure_grace_days": 7}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ivan@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := LoginRequest{Email: "ivan@example.com", Password: "password123"}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	erasure, err := svc.RequestErasure(ctx, "tnt_test", registered.ID, ErasureRequestedByUser)
+	if err != nil {
+		t.Fatalf("RequestErasure: %v", err)
+	}
+	scheduled, _ := time.Parse(time.RFC3339, erasure.ScheduledAt)
+	if until := time.Until(scheduled); until < 6*24*time.Hour || until > 7*24*time.Hour {
+		t.Fatalf("expected a 7 day grace period, scheduled at %s", erasure.ScheduledAt)
+	}
+	if len(store.refresh) != 0 {
+		t.Fatal("expected refresh tokens to be revoked")
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("expected login to be blocked during the grace period, got %v", err)
+	}
+	again, err := svc.RequestErasure(ctx, "tnt_test", registered.ID, ErasureRequestedByAdmin)
+	if err != nil || again.ScheduledAt != erasure.ScheduledAt || again.RequestedBy != ErasureRequestedByUser {
+		t.Fatalf("expected the existing request to be returned, got %+v, %v", again, err)
+	}
+
+	// 宽限期内撤销后恢复正常
+	if err := svc.CancelErasure(ctx, "tnt_test", registered.ID); err != nil {
+		t.Fatalf("CancelErasure: %v", err)
+	}
+	if err := svc.CancelErasure(ctx, "tnt_test", registered.ID); !errors.Is(err, ErrErasureNotFound) {
+		t.Fatalf("expected ErrErasureNotFound, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); err != nil {
+		t.Fatalf("Login after cancel: %v", err)
+	}
+
+	// 未到期的请求不执行
+	if _, err := svc.RequestErasure(ctx, "tnt_test", registered.ID, ErasureRequestedByAdmin); err != nil {
+		t.Fatalf("RequestErasure: %v", err)
+	}
+	svc.ProcessErasures(ctx)
+	if _, ok := store.users[registered.ID]; !ok {
+		t.Fatal("user erased before the grace period ended")
+	}
+
+	pending := store.erasures[registered.ID]
+	pending.ScheduledAt = time.Now().Add(-time.Minute)
+	store.erasures[registered.ID] = pending
+	svc.recordSecurityEvent(ctx, "tnt_test", registered.ID, SecurityEventRefreshTokenReuse, "", "", nil)
+	svc.ProcessErasures(ctx)
+
+	if _, ok := store.users[registered.ID]; ok {
+		t.Fatal("expected the user to be deleted")
+	}
+	if len(store.events) != 0 {
+		t.Fatalf("expected security events to be deleted, got %+v", store.events)
+	}
+	tombstone, err := svc.GetErasure(ctx, "tnt_test", registered.ID)
+	if err != nil || tombstone.ErasedAt == "" || tombstone.RequestedBy != ErasureRequestedByAdmin {
+		t.Fatalf("expected an erased tombstone, got %+v, %v", tombstone, err)
+	}
+	if err := svc.CancelErasure(ctx, "tnt_test", registered.ID); !errors.Is(err, ErrErasureNotFound) {
+		t.Fatalf("expected an executed erasure not to be cancellable, got %v", err)
+	}
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ivan@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("expected the email to be reusable after erasure: %v", err)
+	}
+}
+
+// 申请前已被管理员停用的用户，撤销删除请求后仍保持停用
+func TestCancelErasureKeepsAdminDisabledUser(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "judy@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	disabled := StatusDisabled
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Status: &disabled}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	if _, err := svc.RequestErasure(ctx, "tnt_test", registered.ID, ErasureRequestedByUser); err != nil {
+		t.Fatalf("RequestErasure: %v", err)
+	}
+	if err := svc.CancelErasure(ctx, "tnt_test", registered.ID); err != nil {
+		t.Fatalf("CancelErasure: %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "judy@example.com", Password: "password123"}, "", ""); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("expected the user to stay disabled, got %v", err)
+	}
+}
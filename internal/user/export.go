@@ -0,0 +1,215 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"yuyu-test/internal/lockout"
+	"yuyu-test/internal/store/database"
+)
+
+// UserDataExport 用户数据导出，包含服务保存的与该用户有关的全部数据。
+// 密码、refresh token、TOTP密钥和恢复码等凭据只导出元数据，不导出哈希或密钥本身
+type UserDataExport struct {
+	ExportedAt     string                   `json:"exported_at"`
+	User           *ExportedUser            `json:"user"`
+	Sessions       []*SessionResponse       `json:"sessions"`
+	RefreshTokens  []*ExportedRefreshToken  `json:"refresh_tokens"`
+	SecurityEvents []*ExportedSecurityEvent `json:"security_events"`
+	Identities     *ExportedIdentities      `json:"identities"`
+	// Roles 用户在租户内的角色名
+	Roles []string `json:"roles"`
+	// Organizations 用户所属的组织及其组织角色
+	Organizations []*UserOrganizationResponse `json:"organizations"`
+	// Erasure 数据删除请求，没有请求时省略
+	Erasure *ErasureResponse `json:"erasure,omitempty"`
+}
+
+// ExportedUser 用户资料及账号状态
+type ExportedUser struct {
+	*RegisterResponse
+	EmailVerifiedAt       string `json:"email_verified_at,omitempty"`
+	PasswordSet           bool   `json:"password_set"`
+	PasswordChangedAt     string `json:"password_changed_at,omitempty"`
+	PasswordResetRequired bool   `json:"password_reset_required"`
+	// PasswordHistory 保存的历史密码的记录时间，不含哈希
+	PasswordHistory []string `json:"password_history"`
+	DeletedAt       string   `json:"deleted_at,omitempty"`
+}
+
+// ExportedRefreshToken refresh token 元数据，同一家族的令牌属于同一次登录
+type ExportedRefreshToken struct {
+	ID        int32    `json:"id"`
+	FamilyID  string   `json:"family_id"`
+	AMR       []string `json:"amr"`
+	ClientIP  string   `json:"client_ip,omitempty"`
+	UserAgent string   `json:"user_agent,omitempty"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt string   `json:"expires_at"`
+	RotatedAt string   `json:"rotated_at,omitempty"`
+	RevokedAt string   `json:"revoked_at,omitempty"`
+}
+
+// ExportedSecurityEvent 与用户有关的安全事件，包括按邮箱记录的账号锁定
+type ExportedSecurityEvent struct {
+	Type      string          `json:"type"`
+	ClientIP  string          `json:"client_ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// ExportedIdentities 用户绑定的登录凭据
+type ExportedIdentities struct {
+	Passkeys []*PasskeyResponse `json:"passkeys"`
+	// TOTP 已绑定或待确认的TOTP，未绑定时省略
+	TOTP                   *ExportedTOTP `json:"totp,omitempty"`
+	RecoveryCodesRemaining int64         `json:"recovery_codes_remaining"`
+}
+
+// ExportedTOTP TOTP绑定信息，不含密钥
+type ExportedTOTP struct {
+	CreatedAt   string `json:"created_at"`
+	ConfirmedAt string `json:"confirmed_at,omitempty"`
+}
+
+// formatNullTime 格式化可空时间，空值返回空字符串
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format("2006-01-02T15:04:05Z07:00")
+}
+
+// ExportUserData 导出用户的全部数据，包括已软删除的用户。currentSessionID 用于标记发起请求的会话
+func (s *Service) ExportUserData(ctx context.Context, tenantID, userID, currentSessionID string) (*UserDataExport, error) {
+	user, err := s.userIncludingDeleted(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.db.ListPasswordHistory(ctx, database.ListPasswordHistoryParams{UserID: userID, Limit: math.MaxInt32})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list password history: %w", err)
+	}
+	exported := &ExportedUser{
+		RegisterResponse:      toUserResponse(user),
+		EmailVerifiedAt:       formatNullTime(user.EmailVerifiedAt),
+		PasswordSet:           user.HashedPassword.Valid,
+		PasswordChangedAt:     formatNullTime(user.PasswordChangedAt),
+		PasswordResetRequired: user.PasswordResetRequired,
+		PasswordHistory:       make([]string, 0, len(history)),
+		DeletedAt:             formatNullTime(user.DeletedAt),
+	}
+	for _, h := range history {
+		exported.PasswordHistory = append(exported.PasswordHistory, h.CreatedAt.Format("2006-01-02T15:04:05Z07:00"))
+	}
+
+	export := &UserDataExport{
+		ExportedAt: time.Now().UTC().Format("2006-01-02T15:04:05Z07:00"),
+		User:       exported,
+	}
+
+	sessions, err := s.db.ListUserSessions(ctx, database.ListUserSessionsParams{UserID: userID, TenantID: tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	export.Sessions = make([]*SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, toSessionResponse(session, currentSessionID))
+	}
+
+	tokens, err := s.db.ListUserRefreshTokens(ctx, database.ListUserRefreshTokensParams{UserID: userID, TenantID: tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refresh tokens: %w", err)
+	}
+	export.RefreshTokens = make([]*ExportedRefreshToken, 0, len(tokens))
+	for _, t := range tokens {
+		export.RefreshTokens = append(export.RefreshTokens, &ExportedRefreshToken{
+			ID:        t.ID,
+			FamilyID:  t.FamilyID,
+			AMR:       t.Amr,
+			ClientIP:  t.ClientIp.String,
+			UserAgent: t.UserAgent.String,
+			CreatedAt: t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ExpiresAt: t.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+			RotatedAt: formatNullTime(t.RotatedAt),
+			RevokedAt: formatNullTime(t.RevokedAt),
+		})
+	}
+
+	events, err := s.db.ListUserSecurityEvents(ctx, database.ListUserSecurityEventsParams{
+		UserID:         userID,
+		TenantID:       tenantID,
+		LockoutSubject: lockout.AccountKey(tenantID, user.Email).Subject,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list security events: %w", err)
+	}
+	export.SecurityEvents = make([]*ExportedSecurityEvent, 0, len(events))
+	for _, e := range events {
+		export.SecurityEvents = append(export.SecurityEvents, &ExportedSecurityEvent{
+			Type:      e.EventType,
+			ClientIP:  e.ClientIp.String,
+			UserAgent: e.UserAgent.String,
+			Details:   e.Details,
+			CreatedAt: e.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		})
+	}
+
+	if export.Identities, err = s.exportIdentities(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.db.ListUserRoles(ctx, database.ListUserRolesParams{UserID: userID, TenantID: tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	export.Roles = make([]string, 0, len(roles))
+	for _, role := range roles {
+		export.Roles = append(export.Roles, role.Name)
+	}
+	if export.Organizations, err = s.userOrganizations(ctx, tenantID, userID, ""); err != nil {
+		return nil, err
+	}
+
+	erasure, err := s.db.GetUserErasure(ctx, database.GetUserErasureParams{UserID: userID, TenantID: tenantID})
+	if err == nil {
+		export.Erasure = toErasureResponse(erasure)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get erasure request: %w", err)
+	}
+
+	return export, nil
+}
+
+func (s *Service) exportIdentities(ctx context.Context, userID string) (*ExportedIdentities, error) {
+	creds, err := s.db.ListWebAuthnCredentialsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	identities := &ExportedIdentities{Passkeys: make([]*PasskeyResponse, 0, len(creds))}
+	for _, c := range creds {
+		identities.Passkeys = append(identities.Passkeys, toPasskeyResponse(c))
+	}
+
+	totp, err := s.db.GetUserTOTP(ctx, userID)
+	if err == nil {
+		identities.TOTP = &ExportedTOTP{
+			CreatedAt:   totp.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ConfirmedAt: formatNullTime(totp.ConfirmedAt),
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get TOTP: %w", err)
+	}
+
+	if identities.RecoveryCodesRemaining, err = s.db.CountUnusedRecoveryCodes(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return identities, nil
+}
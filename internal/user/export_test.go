@@ -0,0 +1,73 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/store/database"
+)
+
+func TestExportUserData(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "hana@example.com", Password: "password123", Profile: map[string]interface{}{"name": "Hana"}})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "hana@example.com", Password: "password123"}, "203.0.113.7", "laptop")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	// 连续失败触发按邮箱记录的账号锁定事件
+	for range 3 {
+		_, _ = svc.Login(ctx, "tnt_test", LoginRequest{Email: "hana@example.com", Password: "wrong-password"}, "203.0.113.7", "laptop")
+	}
+	svc.recordSecurityEvent(ctx, "tnt_test", registered.ID, SecurityEventRefreshTokenReuse, "203.0.113.8", "phone", nil)
+	store.passkeys["pk_1"] = database.WebauthnCredential{ID: "pk_1", UserID: registered.ID, TenantID: "tnt_test", Name: "YubiKey", PublicKey: []byte("public-key"), CreatedAt: time.Now()}
+
+	// 其他用户的数据不应出现在导出中
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "other@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "other@example.com", Password: "password123"}, "", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	export, err := svc.ExportUserData(ctx, "tnt_test", registered.ID, accessTokenSessionID(t, svc, login.Token))
+	if err != nil {
+		t.Fatalf("ExportUserData: %v", err)
+	}
+	if export.User.Email != "hana@example.com" || export.User.Profile["name"] != "Hana" || !export.User.PasswordSet {
+		t.Fatalf("unexpected user: %+v", export.User)
+	}
+	if len(export.Sessions) != 1 || !export.Sessions[0].Current || export.Sessions[0].ClientIP != "203.0.113.7" {
+		t.Fatalf("unexpected sessions: %+v", export.Sessions)
+	}
+	if len(export.RefreshTokens) != 1 || export.RefreshTokens[0].UserAgent != "laptop" {
+		t.Fatalf("unexpected refresh tokens: %+v", export.RefreshTokens)
+	}
+	if len(export.SecurityEvents) != 2 {
+		t.Fatalf("expected the lockout and refresh token events, got %+v", export.SecurityEvents)
+	}
+	if len(export.Identities.Passkeys) != 1 || export.Identities.Passkeys[0].Name != "YubiKey" || export.Identities.TOTP != nil {
+		t.Fatalf("unexpected identities: %+v", export.Identities)
+	}
+
+	// 导出中不包含任何凭据本身
+	raw, _ := json.Marshal(export)
+	user := store.users[registered.ID]
+	for _, secret := range []string{user.HashedPassword.String, login.RefreshToken, "public-key", "cHVibGljLWtleQ"} {
+		if strings.Contains(string(raw), secret) {
+			t.Fatalf("export leaks a credential: %s", raw)
+		}
+	}
+	for _, token := range store.refresh {
+		if strings.Contains(string(raw), token.TokenHash) {
+			t.Fatalf("export leaks a refresh token hash: %s", raw)
+		}
+	}
+}
@@ -0,0 +1,1433 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/lockout"
+	"yuyu-test/internal/mailer"
+	"yuyu-test/internal/revocation"
+	"yuyu-test/internal/store/database"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// captureMailer 记录发送的邮件，测试中替代SMTP
+type captureMailer struct {
+	messages []mailer.Message
+}
+
+func (m *captureMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// fakeStore 内存实现的Querier，只实现测试涉及的方法
+type fakeStore struct {
+	database.Querier
+	tenants      map[string]database.Tenant
+	users        map[string]database.User
+	actionTokens map[string]database.UserActionToken
+	totp         map[string]database.UserMfaTotp
+	recovery     map[string]database.UserRecoveryCode
+	passkeys     map[string]database.WebauthnCredential
+	refresh      map[string]database.UserRefreshToken
+	sessions     map[string]database.UserSession
+	revocations  map[string]database.TokenRevocation
+	events       []database.CreateSecurityEventParams
+	authFailures map[string]database.AuthFailure
+	history      []database.UserPasswordHistory
+	importJobs   map[string]database.UserImportJob
+	importErrors []database.UserImportError
+	erasures     map[string]database.UserErasure
+	schemas      []database.TenantProfileSchema
+	roles        map[string]database.Role
+	rolePerms    map[string][]string
+	userRoles    map[string][]string
+	orgs         map[string]database.Organization
+	orgMembers   map[[2]string]database.OrganizationMember
+	orgRoles     map[[2]string][]string
+	invitations  map[string]database.Invitation
+	claimsMaps   map[string]database.TenantClaimsMapping
+	oidcClients  map[string]database.OidcClient
+	oidcCodes    map[string]database.OidcAuthorizationCode
+	oidcConsents map[[2]string]database.OidcConsent
+	// impersonations 按写入顺序保存
+	impersonations []database.UserImpersonation
+	nextID         int32
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		tenants:      map[string]database.Tenant{},
+		users:        map[string]database.User{},
+		actionTokens: map[string]database.UserActionToken{},
+		totp:         map[string]database.UserMfaTotp{},
+		recovery:     map[string]database.UserRecoveryCode{},
+		passkeys:     map[string]database.WebauthnCredential{},
+		refresh:      map[string]database.UserRefreshToken{},
+		sessions:     map[string]database.UserSession{},
+		revocations:  map[string]database.TokenRevocation{},
+		authFailures: map[string]database.AuthFailure{},
+		importJobs:   map[string]database.UserImportJob{},
+		erasures:     map[string]database.UserErasure{},
+		roles:        map[string]database.Role{},
+		rolePerms:    map[string][]string{},
+		userRoles:    map[string][]string{},
+		orgs:         map[string]database.Organization{},
+		orgMembers:   map[[2]string]database.OrganizationMember{},
+		orgRoles:     map[[2]string][]string{},
+		invitations:  map[string]database.Invitation{},
+		claimsMaps:   map[string]database.TenantClaimsMapping{},
+		oidcClients:  map[string]database.OidcClient{},
+		oidcCodes:    map[string]database.OidcAuthorizationCode{},
+		oidcConsents: map[[2]string]database.OidcConsent{},
+	}
+}
+
+func (f *fakeStore) GetTenantByID(ctx context.Context, id string) (database.Tenant, error) {
+	t, ok := f.tenants[id]
+	if !ok {
+		return database.Tenant{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (f *fakeStore) CreateProfileSchema(ctx context.Context, arg database.CreateProfileSchemaParams) (database.TenantProfileSchema, error) {
+	schema := database.TenantProfileSchema{TenantID: arg.TenantID, Version: 1, Schema: arg.Schema, CreatedAt: time.Now()}
+	if latest, err := f.GetLatestProfileSchema(ctx, arg.TenantID); err == nil {
+		schema.Version = latest.Version + 1
+	}
+	f.schemas = append(f.schemas, schema)
+	return schema, nil
+}
+
+func (f *fakeStore) GetClaimsMapping(ctx context.Context, tenantID string) (database.TenantClaimsMapping, error) {
+	m, ok := f.claimsMaps[tenantID]
+	if !ok {
+		return database.TenantClaimsMapping{}, sql.ErrNoRows
+	}
+	return m, nil
+}
+
+func (f *fakeStore) CreateImpersonation(ctx context.Context, arg database.CreateImpersonationParams) (database.UserImpersonation, error) {
+	imp := database.UserImpersonation{
+		ID: arg.ID, TenantID: arg.TenantID, UserID: arg.UserID, ClientID: arg.ClientID, Actor: arg.Actor, Reason: arg.Reason,
+		TokenID: arg.TokenID, ExpiresAt: arg.ExpiresAt, ClientIp: arg.ClientIp, UserAgent: arg.UserAgent, CreatedAt: time.Now(),
+	}
+	f.impersonations = append(f.impersonations, imp)
+	return imp, nil
+}
+
+func (f *fakeStore) ListImpersonations(ctx context.Context, arg database.ListImpersonationsParams) ([]database.UserImpersonation, error) {
+	var result []database.UserImpersonation
+	for i := len(f.impersonations) - 1; i >= 0; i-- {
+		imp := f.impersonations[i]
+		if imp.TenantID != arg.TenantID || (arg.UserID.Valid && imp.UserID != arg.UserID.String) || (arg.Actor.Valid && imp.Actor != arg.Actor.String) {
+			continue
+		}
+		result = append(result, imp)
+	}
+	result = result[min(int(arg.RowOffset), len(result)):]
+	return result[:min(int(arg.RowLimit), len(result))], nil
+}
+
+func (f *fakeStore) UpsertClaimsMapping(ctx context.Context, arg database.UpsertClaimsMappingParams) (database.TenantClaimsMapping, error) {
+	m := database.TenantClaimsMapping{TenantID: arg.TenantID, Mapping: arg.Mapping, UpdatedAt: time.Now()}
+	f.claimsMaps[arg.TenantID] = m
+	return m, nil
+}
+
+func (f *fakeStore) DeleteClaimsMapping(ctx context.Context, tenantID string) (int64, error) {
+	if _, ok := f.claimsMaps[tenantID]; !ok {
+		return 0, nil
+	}
+	delete(f.claimsMaps, tenantID)
+	return 1, nil
+}
+
+func (f *fakeStore) GetLatestProfileSchema(ctx context.Context, tenantID string) (database.TenantProfileSchema, error) {
+	for _, s := range slices.Backward(f.schemas) {
+		if s.TenantID == tenantID {
+			return s, nil
+		}
+	}
+	return database.TenantProfileSchema{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) GetProfileSchema(ctx context.Context, arg database.GetProfileSchemaParams) (database.TenantProfileSchema, error) {
+	for _, s := range f.schemas {
+		if s.TenantID == arg.TenantID && s.Version == arg.Version {
+			return s, nil
+		}
+	}
+	return database.TenantProfileSchema{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) CreateRole(ctx context.Context, arg database.CreateRoleParams) (database.Role, error) {
+	role := database.Role{ID: arg.ID, TenantID: arg.TenantID, Name: arg.Name, Description: arg.Description, CreatedAt: time.Now()}
+	f.roles[arg.ID] = role
+	return role, nil
+}
+
+func (f *fakeStore) GetRole(ctx context.Context, arg database.GetRoleParams) (database.Role, error) {
+	role, ok := f.roles[arg.ID]
+	if !ok || role.TenantID != arg.TenantID {
+		return database.Role{}, sql.ErrNoRows
+	}
+	return role, nil
+}
+
+func (f *fakeStore) GetRoleByName(ctx context.Context, arg database.GetRoleByNameParams) (database.Role, error) {
+	for _, role := range f.roles {
+		if role.TenantID == arg.TenantID && role.Name == arg.Name {
+			return role, nil
+		}
+	}
+	return database.Role{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) ListRoles(ctx context.Context, tenantID string) ([]database.Role, error) {
+	roles := []database.Role{}
+	for _, role := range f.roles {
+		if role.TenantID == tenantID {
+			roles = append(roles, role)
+		}
+	}
+	slices.SortFunc(roles, func(a, b database.Role) int { return strings.Compare(a.Name, b.Name) })
+	return roles, nil
+}
+
+func (f *fakeStore) DeleteRole(ctx context.Context, arg database.DeleteRoleParams) (int64, error) {
+	if _, err := f.GetRole(ctx, database.GetRoleParams(arg)); err != nil {
+		return 0, nil
+	}
+	delete(f.roles, arg.ID)
+	delete(f.rolePerms, arg.ID)
+	for userID, roleIDs := range f.userRoles {
+		f.userRoles[userID] = slices.DeleteFunc(roleIDs, func(id string) bool { return id == arg.ID })
+	}
+	return 1, nil
+}
+
+func (f *fakeStore) AddRolePermission(ctx context.Context, arg database.AddRolePermissionParams) error {
+	if !slices.Contains(f.rolePerms[arg.RoleID], arg.Permission) {
+		f.rolePerms[arg.RoleID] = append(f.rolePerms[arg.RoleID], arg.Permission)
+	}
+	return nil
+}
+
+func (f *fakeStore) RemoveRolePermission(ctx context.Context, arg database.RemoveRolePermissionParams) (int64, error) {
+	before := len(f.rolePerms[arg.RoleID])
+	f.rolePerms[arg.RoleID] = slices.DeleteFunc(f.rolePerms[arg.RoleID], func(p string) bool { return p == arg.Permission })
+	return int64(before - len(f.rolePerms[arg.RoleID])), nil
+}
+
+func (f *fakeStore) ListRolePermissions(ctx context.Context, roleID string) ([]string, error) {
+	permissions := append([]string{}, f.rolePerms[roleID]...)
+	slices.Sort(permissions)
+	return permissions, nil
+}
+
+func (f *fakeStore) AssignUserRole(ctx context.Context, arg database.AssignUserRoleParams) error {
+	if !slices.Contains(f.userRoles[arg.UserID], arg.RoleID) {
+		f.userRoles[arg.UserID] = append(f.userRoles[arg.UserID], arg.RoleID)
+	}
+	return nil
+}
+
+func (f *fakeStore) UnassignUserRole(ctx context.Context, arg database.UnassignUserRoleParams) (int64, error) {
+	before := len(f.userRoles[arg.UserID])
+	f.userRoles[arg.UserID] = slices.DeleteFunc(f.userRoles[arg.UserID], func(id string) bool { return id == arg.RoleID })
+	return int64(before - len(f.userRoles[arg.UserID])), nil
+}
+
+func (f *fakeStore) ListUserRoles(ctx context.Context, arg database.ListUserRolesParams) ([]database.Role, error) {
+	ids := slices.Clone(f.userRoles[arg.UserID])
+	if arg.OrganizationID.Valid {
+		ids = append(ids, f.orgRoles[[2]string{arg.OrganizationID.String, arg.UserID}]...)
+	}
+	slices.Sort(ids)
+	roles := []database.Role{}
+	for _, id := range slices.Compact(ids) {
+		if role, ok := f.roles[id]; ok && role.TenantID == arg.TenantID {
+			roles = append(roles, role)
+		}
+	}
+	slices.SortFunc(roles, func(a, b database.Role) int { return strings.Compare(a.Name, b.Name) })
+	return roles, nil
+}
+
+func (f *fakeStore) ListUserPermissions(ctx context.Context, arg database.ListUserPermissionsParams) ([]string, error) {
+	roles, _ := f.ListUserRoles(ctx, database.ListUserRolesParams(arg))
+	permissions := []string{}
+	for _, role := range roles {
+		permissions = append(permissions, f.rolePerms[role.ID]...)
+	}
+	slices.Sort(permissions)
+	return slices.Compact(permissions), nil
+}
+
+func (f *fakeStore) CreateOrganization(ctx context.Context, arg database.CreateOrganizationParams) (database.Organization, error) {
+	org := database.Organization{ID: arg.ID, TenantID: arg.TenantID, Name: arg.Name, CreatedAt: time.Now()}
+	f.orgs[org.ID] = org
+	return org, nil
+}
+
+func (f *fakeStore) GetOrganization(ctx context.Context, arg database.GetOrganizationParams) (database.Organization, error) {
+	org, ok := f.orgs[arg.ID]
+	if !ok || org.TenantID != arg.TenantID {
+		return database.Organization{}, sql.ErrNoRows
+	}
+	return org, nil
+}
+
+func (f *fakeStore) DeleteOrganization(ctx context.Context, arg database.DeleteOrganizationParams) (int64, error) {
+	if _, err := f.GetOrganization(ctx, database.GetOrganizationParams(arg)); err != nil {
+		return 0, nil
+	}
+	delete(f.orgs, arg.ID)
+	for key := range f.orgMembers {
+		if key[0] == arg.ID {
+			delete(f.orgMembers, key)
+			delete(f.orgRoles, key)
+		}
+	}
+	for id, s := range f.sessions {
+		if s.OrganizationID.String == arg.ID {
+			s.OrganizationID = sql.NullString{}
+			f.sessions[id] = s
+		}
+	}
+	return 1, nil
+}
+
+func (f *fakeStore) AddOrganizationMember(ctx context.Context, arg database.AddOrganizationMemberParams) error {
+	key := [2]string{arg.OrganizationID, arg.UserID}
+	if _, ok := f.orgMembers[key]; !ok {
+		f.orgMembers[key] = database.OrganizationMember{OrganizationID: arg.OrganizationID, UserID: arg.UserID, CreatedAt: time.Now()}
+	}
+	return nil
+}
+
+func (f *fakeStore) GetOrganizationMember(ctx context.Context, arg database.GetOrganizationMemberParams) (database.OrganizationMember, error) {
+	member, ok := f.orgMembers[[2]string{arg.OrganizationID, arg.UserID}]
+	if !ok {
+		return database.OrganizationMember{}, sql.ErrNoRows
+	}
+	return member, nil
+}
+
+func (f *fakeStore) RemoveOrganizationMember(ctx context.Context, arg database.RemoveOrganizationMemberParams) (int64, error) {
+	key := [2]string{arg.OrganizationID, arg.UserID}
+	if _, ok := f.orgMembers[key]; !ok {
+		return 0, nil
+	}
+	delete(f.orgMembers, key)
+	delete(f.orgRoles, key)
+	return 1, nil
+}
+
+func (f *fakeStore) AddOrganizationMemberRole(ctx context.Context, arg database.AddOrganizationMemberRoleParams) error {
+	key := [2]string{arg.OrganizationID, arg.UserID}
+	if !slices.Contains(f.orgRoles[key], arg.RoleID) {
+		f.orgRoles[key] = append(f.orgRoles[key], arg.RoleID)
+	}
+	return nil
+}
+
+func (f *fakeStore) DeleteOrganizationMemberRoles(ctx context.Context, arg database.DeleteOrganizationMemberRolesParams) error {
+	delete(f.orgRoles, [2]string{arg.OrganizationID, arg.UserID})
+	return nil
+}
+
+func (f *fakeStore) ListOrganizationMemberRoles(ctx context.Context, arg database.ListOrganizationMemberRolesParams) ([]database.Role, error) {
+	roles := []database.Role{}
+	for _, id := range f.orgRoles[[2]string{arg.OrganizationID, arg.UserID}] {
+		roles = append(roles, f.roles[id])
+	}
+	slices.SortFunc(roles, func(a, b database.Role) int { return strings.Compare(a.Name, b.Name) })
+	return roles, nil
+}
+
+func (f *fakeStore) ListUserOrganizations(ctx context.Context, arg database.ListUserOrganizationsParams) ([]database.Organization, error) {
+	orgs := []database.Organization{}
+	for key := range f.orgMembers {
+		if org, ok := f.orgs[key[0]]; ok && key[1] == arg.UserID && org.TenantID == arg.TenantID {
+			orgs = append(orgs, org)
+		}
+	}
+	slices.SortFunc(orgs, func(a, b database.Organization) int { return strings.Compare(a.Name, b.Name) })
+	return orgs, nil
+}
+
+func (f *fakeStore) SetUserSessionOrganization(ctx context.Context, arg database.SetUserSessionOrganizationParams) error {
+	if s, ok := f.sessions[arg.ID]; ok {
+		s.OrganizationID = arg.OrganizationID
+		f.sessions[arg.ID] = s
+	}
+	return nil
+}
+
+func (f *fakeStore) ClearUserSessionOrganization(ctx context.Context, arg database.ClearUserSessionOrganizationParams) error {
+	for id, s := range f.sessions {
+		if s.UserID == arg.UserID && s.OrganizationID == arg.OrganizationID {
+			s.OrganizationID = sql.NullString{}
+			f.sessions[id] = s
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) CreateInvitation(ctx context.Context, arg database.CreateInvitationParams) (database.Invitation, error) {
+	now := time.Now()
+	inv := database.Invitation{
+		ID:             arg.ID,
+		TenantID:       arg.TenantID,
+		OrganizationID: arg.OrganizationID,
+		Email:          arg.Email,
+		RoleIds:        arg.RoleIds,
+		InviterUserID:  arg.InviterUserID,
+		TokenHash:      arg.TokenHash,
+		CreatedAt:      now,
+		ExpiresAt:      arg.ExpiresAt,
+		LastSentAt:     now,
+	}
+	f.invitations[inv.ID] = inv
+	return inv, nil
+}
+
+func (f *fakeStore) GetInvitation(ctx context.Context, arg database.GetInvitationParams) (database.Invitation, error) {
+	inv, ok := f.invitations[arg.ID]
+	if !ok || inv.TenantID != arg.TenantID {
+		return database.Invitation{}, sql.ErrNoRows
+	}
+	return inv, nil
+}
+
+func (f *fakeStore) ListInvitations(ctx context.Context, arg database.ListInvitationsParams) ([]database.Invitation, error) {
+	invs := []database.Invitation{}
+	for _, inv := range f.invitations {
+		if inv.TenantID != arg.TenantID ||
+			(arg.OrganizationID.Valid && inv.OrganizationID != arg.OrganizationID) ||
+			(arg.Status.Valid && invitationStatus(inv) != arg.Status.String) {
+			continue
+		}
+		invs = append(invs, inv)
+	}
+	slices.SortFunc(invs, func(a, b database.Invitation) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return invs, nil
+}
+
+// invitationInScope 邀请未接受、未撤销且属于参数指定的租户和组织
+func invitationInScope(inv database.Invitation, tenantID string, organizationID sql.NullString) bool {
+	return inv.TenantID == tenantID && (!organizationID.Valid || inv.OrganizationID == organizationID) &&
+		!inv.AcceptedAt.Valid && !inv.RevokedAt.Valid
+}
+
+func (f *fakeStore) RevokeInvitation(ctx context.Context, arg database.RevokeInvitationParams) (int64, error) {
+	inv, ok := f.invitations[arg.ID]
+	if !ok || !invitationInScope(inv, arg.TenantID, arg.OrganizationID) {
+		return 0, nil
+	}
+	inv.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.invitations[inv.ID] = inv
+	return 1, nil
+}
+
+func (f *fakeStore) ResendInvitation(ctx context.Context, arg database.ResendInvitationParams) (database.Invitation, error) {
+	inv, ok := f.invitations[arg.ID]
+	if !ok || !invitationInScope(inv, arg.TenantID, arg.OrganizationID) {
+		return database.Invitation{}, sql.ErrNoRows
+	}
+	inv.TokenHash = arg.TokenHash
+	inv.ExpiresAt = arg.ExpiresAt
+	inv.LastSentAt = time.Now()
+	f.invitations[inv.ID] = inv
+	return inv, nil
+}
+
+func (f *fakeStore) GetPendingInvitationByTokenHash(ctx context.Context, tokenHash string) (database.Invitation, error) {
+	for _, inv := range f.invitations {
+		if inv.TokenHash == tokenHash && !inv.AcceptedAt.Valid && !inv.RevokedAt.Valid && inv.ExpiresAt.After(time.Now()) {
+			return inv, nil
+		}
+	}
+	return database.Invitation{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) MarkInvitationAccepted(ctx context.Context, arg database.MarkInvitationAcceptedParams) (int64, error) {
+	inv, ok := f.invitations[arg.ID]
+	if !ok || inv.AcceptedAt.Valid || inv.RevokedAt.Valid {
+		return 0, nil
+	}
+	inv.AcceptedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	inv.AcceptedBy = arg.AcceptedBy
+	f.invitations[inv.ID] = inv
+	return 1, nil
+}
+
+func (f *fakeStore) DeleteInvitationsByEmail(ctx context.Context, arg database.DeleteInvitationsByEmailParams) error {
+	for id, inv := range f.invitations {
+		if inv.TenantID == arg.TenantID && inv.Email == arg.Email {
+			delete(f.invitations, id)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) GetUserByEmail(ctx context.Context, arg database.GetUserByEmailParams) (database.User, error) {
+	for _, u := range f.users {
+		if u.TenantID == arg.TenantID && u.Email == arg.Email && !u.DeletedAt.Valid {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) GetUserByID(ctx context.Context, id string) (database.User, error) {
+	u, ok := f.users[id]
+	if !ok || u.DeletedAt.Valid {
+		return database.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeStore) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	u := database.User{
+		ID:             arg.ID,
+		TenantID:       arg.TenantID,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Profile:        arg.Profile,
+		Status:         "active",
+		CreatedAt:      time.Now(),
+	}
+	f.users[u.ID] = u
+	return u, nil
+}
+
+func (f *fakeStore) MarkUserEmailVerified(ctx context.Context, arg database.MarkUserEmailVerifiedParams) (database.User, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID {
+		return database.User{}, sql.ErrNoRows
+	}
+	u.EmailVerified = true
+	u.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.users[u.ID] = u
+	return u, nil
+}
+
+func (f *fakeStore) CreateUserActionToken(ctx context.Context, arg database.CreateUserActionTokenParams) error {
+	f.nextID++
+	f.actionTokens[arg.TokenHash] = database.UserActionToken{
+		ID:        f.nextID,
+		UserID:    arg.UserID,
+		TenantID:  arg.TenantID,
+		Purpose:   arg.Purpose,
+		TokenHash: arg.TokenHash,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (f *fakeStore) InvalidateUserActionTokens(ctx context.Context, arg database.InvalidateUserActionTokensParams) error {
+	for hash, t := range f.actionTokens {
+		if t.UserID == arg.UserID && t.Purpose == arg.Purpose && !t.ConsumedAt.Valid {
+			t.ConsumedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			f.actionTokens[hash] = t
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) ConsumeUserActionToken(ctx context.Context, arg database.ConsumeUserActionTokenParams) (database.UserActionToken, error) {
+	t, ok := f.actionTokens[arg.TokenHash]
+	if !ok || t.Purpose != arg.Purpose || t.ConsumedAt.Valid || !t.ExpiresAt.After(time.Now()) {
+		return database.UserActionToken{}, sql.ErrNoRows
+	}
+	t.ConsumedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.actionTokens[arg.TokenHash] = t
+	return t, nil
+}
+
+func (f *fakeStore) GetActiveUserActionToken(ctx context.Context, arg database.GetActiveUserActionTokenParams) (database.UserActionToken, error) {
+	t, ok := f.actionTokens[arg.TokenHash]
+	if !ok || t.Purpose != arg.Purpose || t.ConsumedAt.Valid || !t.ExpiresAt.After(time.Now()) {
+		return database.UserActionToken{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (f *fakeStore) GetLatestActiveUserActionToken(ctx context.Context, arg database.GetLatestActiveUserActionTokenParams) (database.UserActionToken, error) {
+	var latest database.UserActionToken
+	for _, t := range f.actionTokens {
+		if t.UserID == arg.UserID && t.Purpose == arg.Purpose && !t.ConsumedAt.Valid && t.ExpiresAt.After(time.Now()) && t.ID > latest.ID {
+			latest = t
+		}
+	}
+	if latest.ID == 0 {
+		return database.UserActionToken{}, sql.ErrNoRows
+	}
+	return latest, nil
+}
+
+func (f *fakeStore) IncrementUserActionTokenAttempts(ctx context.Context, id int32) (int32, error) {
+	for hash, t := range f.actionTokens {
+		if t.ID == id {
+			t.Attempts++
+			f.actionTokens[hash] = t
+			return t.Attempts, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (f *fakeStore) CountRecentUserActionTokens(ctx context.Context, arg database.CountRecentUserActionTokensParams) (int64, error) {
+	var n int64
+	for _, t := range f.actionTokens {
+		if t.UserID == arg.UserID && t.Purpose == arg.Purpose && t.CreatedAt.After(arg.CreatedAt) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeStore) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID {
+		return database.User{}, sql.ErrNoRows
+	}
+	u.HashedPassword = arg.HashedPassword
+	u.PasswordChangedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	u.PasswordResetRequired = false
+	f.users[arg.ID] = u
+	return u, nil
+}
+
+func (f *fakeStore) SetUserPasswordResetRequired(ctx context.Context, arg database.SetUserPasswordResetRequiredParams) (database.User, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID {
+		return database.User{}, sql.ErrNoRows
+	}
+	u.PasswordResetRequired = true
+	f.users[arg.ID] = u
+	return u, nil
+}
+
+func (f *fakeStore) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID || u.DeletedAt.Valid {
+		return database.User{}, sql.ErrNoRows
+	}
+	u.Email = arg.Email
+	u.Profile = arg.Profile
+	u.EmailVerified = arg.EmailVerified
+	if !arg.EmailVerified {
+		u.EmailVerifiedAt = sql.NullTime{}
+	}
+	f.users[arg.ID] = u
+	return u, nil
+}
+
+// listUsers 模拟 ListUsersBy* 查询的过滤条件，after 为游标条件，less 为排序
+func (f *fakeStore) listUsers(arg database.ListUsersByEmailAscParams, after func(database.User) bool, less func(a, b database.User) int) []database.User {
+	var filter map[string]string
+	if arg.Profile.Valid {
+		_ = json.Unmarshal(arg.Profile.RawMessage, &filter)
+	}
+	prefix := strings.NewReplacer(`\\`, `\`, `\%`, `%`, `\_`, `_`).Replace(arg.EmailPrefix.String)
+	users := []database.User{}
+	for _, u := range f.users {
+		if u.TenantID != arg.TenantID || u.DeletedAt.Valid || !after(u) ||
+			(arg.EmailPrefix.Valid && !strings.HasPrefix(u.Email, prefix)) ||
+			(arg.CreatedAfter.Valid && u.CreatedAt.Before(arg.CreatedAfter.Time)) ||
+			(arg.CreatedBefore.Valid && !u.CreatedAt.Before(arg.CreatedBefore.Time)) ||
+			(arg.Status.Valid && u.Status != arg.Status.String) {
+			continue
+		}
+		if _, member := f.orgMembers[[2]string{arg.OrganizationID.String, u.ID}]; arg.OrganizationID.Valid && !member {
+			continue
+		}
+		var profile map[string]any
+		_ = json.Unmarshal(u.Profile.RawMessage, &profile)
+		matched := true
+		for k, v := range filter {
+			matched = matched && profile[k] == v
+		}
+		if matched {
+			users = append(users, u)
+		}
+	}
+	slices.SortFunc(users, less)
+	return users[:min(len(users), int(arg.Lim))]
+}
+
+func byCreatedAt(a, b database.User) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+func (f *fakeStore) ListUsersByCreatedAtAsc(ctx context.Context, arg database.ListUsersByCreatedAtAscParams) ([]database.User, error) {
+	cursor := database.User{CreatedAt: arg.CursorCreatedAt.Time, ID: arg.CursorID}
+	return f.listUsers(database.ListUsersByEmailAscParams{TenantID: arg.TenantID, EmailPrefix: arg.EmailPrefix, CreatedAfter: arg.CreatedAfter, CreatedBefore: arg.CreatedBefore, Status: arg.Status, Profile: arg.Profile, OrganizationID: arg.OrganizationID, Lim: arg.Lim},
+		func(u database.User) bool { return !arg.CursorCreatedAt.Valid || byCreatedAt(u, cursor) > 0 }, byCreatedAt), nil
+}
+
+func (f *fakeStore) ListUsersByCreatedAtDesc(ctx context.Context, arg database.ListUsersByCreatedAtDescParams) ([]database.User, error) {
+	cursor := database.User{CreatedAt: arg.CursorCreatedAt.Time, ID: arg.CursorID}
+	return f.listUsers(database.ListUsersByEmailAscParams{TenantID: arg.TenantID, EmailPrefix: arg.EmailPrefix, CreatedAfter: arg.CreatedAfter, CreatedBefore: arg.CreatedBefore, Status: arg.Status, Profile: arg.Profile, OrganizationID: arg.OrganizationID, Lim: arg.Lim},
+		func(u database.User) bool { return !arg.CursorCreatedAt.Valid || byCreatedAt(u, cursor) < 0 },
+		func(a, b database.User) int { return byCreatedAt(b, a) }), nil
+}
+
+func (f *fakeStore) ListUsersByEmailAsc(ctx context.Context, arg database.ListUsersByEmailAscParams) ([]database.User, error) {
+	return f.listUsers(arg,
+		func(u database.User) bool { return !arg.CursorEmail.Valid || u.Email > arg.CursorEmail.String },
+		func(a, b database.User) int { return strings.Compare(a.Email, b.Email) }), nil
+}
+
+func (f *fakeStore) ListUsersByEmailDesc(ctx context.Context, arg database.ListUsersByEmailDescParams) ([]database.User, error) {
+	return f.listUsers(database.ListUsersByEmailAscParams(arg),
+		func(u database.User) bool { return !arg.CursorEmail.Valid || u.Email < arg.CursorEmail.String },
+		func(a, b database.User) int { return strings.Compare(b.Email, a.Email) }), nil
+}
+
+func (f *fakeStore) CreateImportedUser(ctx context.Context, arg database.CreateImportedUserParams) (int64, error) {
+	if _, err := f.GetUserByEmail(ctx, database.GetUserByEmailParams{TenantID: arg.TenantID, Email: arg.Email}); err == nil {
+		return 0, nil
+	}
+	f.users[arg.ID] = database.User{
+		ID:             arg.ID,
+		TenantID:       arg.TenantID,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Profile:        arg.Profile,
+		EmailVerified:  arg.EmailVerified,
+		Status:         "active",
+		CreatedAt:      time.Now(),
+	}
+	return 1, nil
+}
+
+func (f *fakeStore) CreateUserImportJob(ctx context.Context, arg database.CreateUserImportJobParams) (database.CreateUserImportJobRow, error) {
+	job := database.UserImportJob{ID: arg.ID, TenantID: arg.TenantID, Format: arg.Format, Status: "pending", Payload: arg.Payload, CreatedAt: time.Now()}
+	f.importJobs[arg.ID] = job
+	row, _ := f.GetUserImportJob(ctx, database.GetUserImportJobParams{ID: arg.ID, TenantID: arg.TenantID})
+	return database.CreateUserImportJobRow(row), nil
+}
+
+func (f *fakeStore) GetUserImportJob(ctx context.Context, arg database.GetUserImportJobParams) (database.GetUserImportJobRow, error) {
+	j, ok := f.importJobs[arg.ID]
+	if !ok || j.TenantID != arg.TenantID {
+		return database.GetUserImportJobRow{}, sql.ErrNoRows
+	}
+	return database.GetUserImportJobRow{
+		ID: j.ID, TenantID: j.TenantID, Format: j.Format, Status: j.Status,
+		ProcessedRows: j.ProcessedRows, CreatedCount: j.CreatedCount, SkippedCount: j.SkippedCount, FailedCount: j.FailedCount,
+		Error: j.Error, CreatedAt: j.CreatedAt, StartedAt: j.StartedAt, FinishedAt: j.FinishedAt,
+	}, nil
+}
+
+func (f *fakeStore) ClaimUserImportJob(ctx context.Context, staleBefore sql.NullTime) (database.UserImportJob, error) {
+	for id, j := range f.importJobs {
+		if j.Status == "pending" || (j.Status == "running" && j.HeartbeatAt.Time.Before(staleBefore.Time)) {
+			j.Status = "running"
+			j.HeartbeatAt = sql.NullTime{Time: time.Now(), Valid: true}
+			if !j.StartedAt.Valid {
+				j.StartedAt = j.HeartbeatAt
+			}
+			f.importJobs[id] = j
+			return j, nil
+		}
+	}
+	return database.UserImportJob{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) UpdateUserImportJobProgress(ctx context.Context, arg database.UpdateUserImportJobProgressParams) error {
+	j := f.importJobs[arg.ID]
+	j.ProcessedRows, j.CreatedCount, j.SkippedCount, j.FailedCount = arg.ProcessedRows, arg.CreatedCount, arg.SkippedCount, arg.FailedCount
+	j.HeartbeatAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.importJobs[arg.ID] = j
+	return nil
+}
+
+func (f *fakeStore) FinishUserImportJob(ctx context.Context, arg database.FinishUserImportJobParams) error {
+	j := f.importJobs[arg.ID]
+	j.Status, j.Error, j.Payload = arg.Status, arg.Error, nil
+	j.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.importJobs[arg.ID] = j
+	return nil
+}
+
+func (f *fakeStore) DeleteUserImportErrorsByEmail(ctx context.Context, arg database.DeleteUserImportErrorsByEmailParams) error {
+	f.importErrors = slices.DeleteFunc(f.importErrors, func(e database.UserImportError) bool {
+		return f.importJobs[e.JobID].TenantID == arg.TenantID && strings.EqualFold(e.Email.String, arg.Email)
+	})
+	return nil
+}
+
+func (f *fakeStore) CreateUserImportError(ctx context.Context, arg database.CreateUserImportErrorParams) error {
+	for _, e := range f.importErrors {
+		if e.JobID == arg.JobID && e.RowNumber == arg.RowNumber {
+			return nil
+		}
+	}
+	f.importErrors = append(f.importErrors, database.UserImportError(arg))
+	return nil
+}
+
+func (f *fakeStore) ListUserImportErrors(ctx context.Context, arg database.ListUserImportErrorsParams) ([]database.UserImportError, error) {
+	rows := []database.UserImportError{}
+	for _, e := range f.importErrors {
+		if e.JobID == arg.JobID && e.RowNumber > arg.AfterRow && len(rows) < int(arg.Lim) {
+			rows = append(rows, e)
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeStore) SetUserStatus(ctx context.Context, arg database.SetUserStatusParams) (database.User, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID || u.DeletedAt.Valid {
+		return database.User{}, sql.ErrNoRows
+	}
+	u.Status = arg.Status
+	f.users[arg.ID] = u
+	return u, nil
+}
+
+func (f *fakeStore) SoftDeleteUser(ctx context.Context, arg database.SoftDeleteUserParams) (int64, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID || u.DeletedAt.Valid {
+		return 0, nil
+	}
+	u.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.users[arg.ID] = u
+	return 1, nil
+}
+
+func (f *fakeStore) DeleteUser(ctx context.Context, arg database.DeleteUserParams) (int64, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID {
+		return 0, nil
+	}
+	delete(f.users, arg.ID)
+	return 1, nil
+}
+
+func (f *fakeStore) GetUserIncludingDeleted(ctx context.Context, arg database.GetUserIncludingDeletedParams) (database.User, error) {
+	u, ok := f.users[arg.ID]
+	if !ok || u.TenantID != arg.TenantID {
+		return database.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeStore) CreateUserErasure(ctx context.Context, arg database.CreateUserErasureParams) (database.UserErasure, error) {
+	e := database.UserErasure{
+		UserID:       arg.UserID,
+		TenantID:     arg.TenantID,
+		RequestedBy:  arg.RequestedBy,
+		RequestedAt:  time.Now(),
+		ScheduledAt:  arg.ScheduledAt,
+		DisabledUser: arg.DisabledUser,
+	}
+	f.erasures[arg.UserID] = e
+	return e, nil
+}
+
+func (f *fakeStore) GetUserErasure(ctx context.Context, arg database.GetUserErasureParams) (database.UserErasure, error) {
+	e, ok := f.erasures[arg.UserID]
+	if !ok || e.TenantID != arg.TenantID {
+		return database.UserErasure{}, sql.ErrNoRows
+	}
+	return e, nil
+}
+
+func (f *fakeStore) CancelUserErasure(ctx context.Context, arg database.CancelUserErasureParams) (database.UserErasure, error) {
+	e, ok := f.erasures[arg.UserID]
+	if !ok || e.TenantID != arg.TenantID || e.ErasedAt.Valid {
+		return database.UserErasure{}, sql.ErrNoRows
+	}
+	delete(f.erasures, arg.UserID)
+	return e, nil
+}
+
+func (f *fakeStore) ListDueUserErasures(ctx context.Context, limit int32) ([]database.UserErasure, error) {
+	due := []database.UserErasure{}
+	for _, e := range f.erasures {
+		if !e.ErasedAt.Valid && !e.ScheduledAt.After(time.Now()) && len(due) < int(limit) {
+			due = append(due, e)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeStore) MarkUserErased(ctx context.Context, userID string) error {
+	e := f.erasures[userID]
+	e.ErasedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.erasures[userID] = e
+	return nil
+}
+
+func (f *fakeStore) RehashUserPassword(ctx context.Context, arg database.RehashUserPasswordParams) error {
+	u, ok := f.users[arg.ID]
+	if ok && u.HashedPassword == arg.OldHash {
+		u.HashedPassword = arg.NewHash
+		f.users[arg.ID] = u
+	}
+	return nil
+}
+
+func (f *fakeStore) CreatePasswordHistory(ctx context.Context, arg database.CreatePasswordHistoryParams) error {
+	f.nextID++
+	f.history = append(f.history, database.UserPasswordHistory{ID: int64(f.nextID), UserID: arg.UserID, HashedPassword: arg.HashedPassword, CreatedAt: time.Now()})
+	return nil
+}
+
+// ListPasswordHistory 按写入顺序倒序
+func (f *fakeStore) ListPasswordHistory(ctx context.Context, arg database.ListPasswordHistoryParams) ([]database.UserPasswordHistory, error) {
+	entries := []database.UserPasswordHistory{}
+	for i := len(f.history) - 1; i >= 0 && len(entries) < int(arg.Limit); i-- {
+		if f.history[i].UserID == arg.UserID {
+			entries = append(entries, f.history[i])
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeStore) PrunePasswordHistory(ctx context.Context, arg database.PrunePasswordHistoryParams) error {
+	keep, _ := f.ListPasswordHistory(ctx, database.ListPasswordHistoryParams{UserID: arg.UserID, Limit: arg.Limit})
+	f.history = slices.DeleteFunc(f.history, func(h database.UserPasswordHistory) bool {
+		return h.UserID == arg.UserID && !slices.ContainsFunc(keep, func(k database.UserPasswordHistory) bool { return k.ID == h.ID })
+	})
+	return nil
+}
+
+func (f *fakeStore) DeleteAllRefreshTokens(ctx context.Context, userID string) error {
+	for hash, t := range f.refresh {
+		if t.UserID == userID {
+			delete(f.refresh, hash)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) ListUserRefreshTokens(ctx context.Context, arg database.ListUserRefreshTokensParams) ([]database.UserRefreshToken, error) {
+	tokens := []database.UserRefreshToken{}
+	for _, t := range f.refresh {
+		if t.UserID == arg.UserID && t.TenantID == arg.TenantID {
+			tokens = append(tokens, t)
+		}
+	}
+	slices.SortFunc(tokens, func(a, b database.UserRefreshToken) int { return int(a.ID - b.ID) })
+	return tokens, nil
+}
+
+func (f *fakeStore) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	f.nextID++
+	f.refresh[arg.TokenHash] = database.UserRefreshToken{
+		ID:        f.nextID,
+		UserID:    arg.UserID,
+		TenantID:  arg.TenantID,
+		FamilyID:  arg.FamilyID,
+		ParentID:  arg.ParentID,
+		TokenHash: arg.TokenHash,
+		Amr:       arg.Amr,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: time.Now(),
+		ClientIp:  arg.ClientIp,
+		UserAgent: arg.UserAgent,
+		ClientID:  arg.ClientID,
+		Scopes:    arg.Scopes,
+	}
+	return nil
+}
+
+func (f *fakeStore) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (database.UserRefreshToken, error) {
+	t, ok := f.refresh[tokenHash]
+	if !ok {
+		return database.UserRefreshToken{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (f *fakeStore) MarkRefreshTokenRotated(ctx context.Context, id int32) (int64, error) {
+	for hash, t := range f.refresh {
+		if t.ID == id && !t.RotatedAt.Valid && !t.RevokedAt.Valid {
+			t.RotatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			f.refresh[hash] = t
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (f *fakeStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	for hash, t := range f.refresh {
+		if t.FamilyID == familyID && !t.RevokedAt.Valid {
+			t.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			f.refresh[hash] = t
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) CreateUserSession(ctx context.Context, arg database.CreateUserSessionParams) (database.UserSession, error) {
+	// 逐个错开创建时间，保证按创建时间排序稳定
+	now := time.Now().Add(time.Duration(len(f.sessions)) * time.Millisecond)
+	session := database.UserSession{
+		ID:         arg.ID,
+		UserID:     arg.UserID,
+		TenantID:   arg.TenantID,
+		ClientIp:   arg.ClientIp,
+		UserAgent:  arg.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  arg.ExpiresAt,
+	}
+	f.sessions[arg.ID] = session
+	return session, nil
+}
+
+func (f *fakeStore) GetUserSession(ctx context.Context, id string) (database.UserSession, error) {
+	session, ok := f.sessions[id]
+	if !ok {
+		return database.UserSession{}, sql.ErrNoRows
+	}
+	return session, nil
+}
+
+func (f *fakeStore) ListActiveUserSessions(ctx context.Context, arg database.ListActiveUserSessionsParams) ([]database.UserSession, error) {
+	sessions := []database.UserSession{}
+	for _, session := range f.sessions {
+		if session.UserID == arg.UserID && session.TenantID == arg.TenantID && !session.RevokedAt.Valid && session.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b database.UserSession) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return sessions, nil
+}
+
+func (f *fakeStore) ListUserSessions(ctx context.Context, arg database.ListUserSessionsParams) ([]database.UserSession, error) {
+	sessions := []database.UserSession{}
+	for _, session := range f.sessions {
+		if session.UserID == arg.UserID && session.TenantID == arg.TenantID {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b database.UserSession) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return sessions, nil
+}
+
+func (f *fakeStore) TouchUserSession(ctx context.Context, arg database.TouchUserSessionParams) error {
+	if session, ok := f.sessions[arg.ID]; ok {
+		session.LastUsedAt = time.Now()
+		session.ClientIp = arg.ClientIp
+		session.UserAgent = arg.UserAgent
+		session.ExpiresAt = arg.ExpiresAt
+		f.sessions[arg.ID] = session
+	}
+	return nil
+}
+
+func (f *fakeStore) RevokeUserSession(ctx context.Context, id string) error {
+	if session, ok := f.sessions[id]; ok && !session.RevokedAt.Valid {
+		session.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		f.sessions[id] = session
+	}
+	return nil
+}
+
+func (f *fakeStore) RevokeAllUserSessions(ctx context.Context, userID string) error {
+	for id, session := range f.sessions {
+		if session.UserID == userID && !session.RevokedAt.Valid {
+			session.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			f.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) UpsertTokenRevocation(ctx context.Context, arg database.UpsertTokenRevocationParams) (database.TokenRevocation, error) {
+	k := arg.SubjectType + ":" + arg.Subject
+	entry := database.TokenRevocation{SubjectType: arg.SubjectType, Subject: arg.Subject, RevokedAt: time.Now(), ExpiresAt: arg.ExpiresAt}
+	if old, ok := f.revocations[k]; ok && old.ExpiresAt.After(entry.ExpiresAt) {
+		entry.ExpiresAt = old.ExpiresAt
+	}
+	f.revocations[k] = entry
+	return entry, nil
+}
+
+func (f *fakeStore) CreateSecurityEvent(ctx context.Context, arg database.CreateSecurityEventParams) error {
+	f.events = append(f.events, arg)
+	return nil
+}
+
+// userEvent 与 ListUserSecurityEvents 的条件一致：用户本人的事件或该账号的锁定事件
+func userEvent(e database.CreateSecurityEventParams, userID, tenantID, lockoutSubject string) bool {
+	var details struct {
+		Subject string `json:"subject"`
+	}
+	_ = json.Unmarshal(e.Details, &details)
+	return e.UserID.String == userID || (e.TenantID.String == tenantID && details.Subject == lockoutSubject)
+}
+
+func (f *fakeStore) ListUserSecurityEvents(ctx context.Context, arg database.ListUserSecurityEventsParams) ([]database.SecurityEvent, error) {
+	events := []database.SecurityEvent{}
+	for i, e := range f.events {
+		if userEvent(e, arg.UserID, arg.TenantID, arg.LockoutSubject) {
+			events = append(events, database.SecurityEvent{
+				ID:        int64(i + 1),
+				TenantID:  e.TenantID,
+				UserID:    e.UserID,
+				EventType: e.EventType,
+				ClientIp:  e.ClientIp,
+				UserAgent: e.UserAgent,
+				Details:   e.Details,
+				CreatedAt: time.Now(),
+			})
+		}
+	}
+	return events, nil
+}
+
+func (f *fakeStore) DeleteUserSecurityEvents(ctx context.Context, arg database.DeleteUserSecurityEventsParams) error {
+	f.events = slices.DeleteFunc(f.events, func(e database.CreateSecurityEventParams) bool {
+		return userEvent(e, arg.UserID, arg.TenantID, arg.LockoutSubject)
+	})
+	return nil
+}
+
+func (f *fakeStore) GetAuthFailure(ctx context.Context, arg database.GetAuthFailureParams) (database.AuthFailure, error) {
+	row, ok := f.authFailures[arg.Scope+"|"+arg.Subject]
+	if !ok {
+		return database.AuthFailure{}, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+func (f *fakeStore) RecordAuthFailure(ctx context.Context, arg database.RecordAuthFailureParams) (database.AuthFailure, error) {
+	k := arg.Scope + "|" + arg.Subject
+	row, ok := f.authFailures[k]
+	if !ok || row.LastFailureAt.Before(arg.LastFailureAt) {
+		row = database.AuthFailure{Scope: arg.Scope, Subject: arg.Subject, LockedUntil: row.LockedUntil}
+	}
+	row.Failures++
+	row.LastFailureAt = time.Now()
+	f.authFailures[k] = row
+	return row, nil
+}
+
+func (f *fakeStore) LockAuthFailure(ctx context.Context, arg database.LockAuthFailureParams) error {
+	k := arg.Scope + "|" + arg.Subject
+	row := f.authFailures[k]
+	row.LockedUntil = arg.LockedUntil
+	f.authFailures[k] = row
+	return nil
+}
+
+func (f *fakeStore) ClearAuthFailure(ctx context.Context, arg database.ClearAuthFailureParams) error {
+	delete(f.authFailures, arg.Scope+"|"+arg.Subject)
+	return nil
+}
+
+func (f *fakeStore) UpsertUserTOTP(ctx context.Context, arg database.UpsertUserTOTPParams) (database.UserMfaTotp, error) {
+	t := database.UserMfaTotp{UserID: arg.UserID, Secret: arg.Secret, CreatedAt: time.Now()}
+	f.totp[arg.UserID] = t
+	return t, nil
+}
+
+func (f *fakeStore) GetUserTOTP(ctx context.Context, userID string) (database.UserMfaTotp, error) {
+	t, ok := f.totp[userID]
+	if !ok {
+		return database.UserMfaTotp{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+func (f *fakeStore) ConfirmUserTOTP(ctx context.Context, arg database.ConfirmUserTOTPParams) (database.UserMfaTotp, error) {
+	t, ok := f.totp[arg.UserID]
+	if !ok || t.ConfirmedAt.Valid {
+		return database.UserMfaTotp{}, sql.ErrNoRows
+	}
+	t.ConfirmedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	t.LastUsedStep = arg.LastUsedStep
+	f.totp[arg.UserID] = t
+	return t, nil
+}
+
+func (f *fakeStore) UpdateUserTOTPLastUsedStep(ctx context.Context, arg database.UpdateUserTOTPLastUsedStepParams) (int64, error) {
+	t, ok := f.totp[arg.UserID]
+	if !ok || t.LastUsedStep >= arg.LastUsedStep {
+		return 0, nil
+	}
+	t.LastUsedStep = arg.LastUsedStep
+	f.totp[arg.UserID] = t
+	return 1, nil
+}
+
+func (f *fakeStore) DeleteUserTOTP(ctx context.Context, userID string) error {
+	delete(f.totp, userID)
+	return nil
+}
+
+func (f *fakeStore) CreateUserRecoveryCode(ctx context.Context, arg database.CreateUserRecoveryCodeParams) error {
+	f.recovery[arg.CodeHash] = database.UserRecoveryCode{UserID: arg.UserID, CodeHash: arg.CodeHash, CreatedAt: time.Now()}
+	return nil
+}
+
+func (f *fakeStore) UseUserRecoveryCode(ctx context.Context, arg database.UseUserRecoveryCodeParams) (database.UserRecoveryCode, error) {
+	c, ok := f.recovery[arg.CodeHash]
+	if !ok || c.UserID != arg.UserID || c.UsedAt.Valid {
+		return database.UserRecoveryCode{}, sql.ErrNoRows
+	}
+	c.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.recovery[arg.CodeHash] = c
+	return c, nil
+}
+
+func (f *fakeStore) CountUnusedRecoveryCodes(ctx context.Context, userID string) (int64, error) {
+	var n int64
+	for _, c := range f.recovery {
+		if c.UserID == userID && !c.UsedAt.Valid {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeStore) DeleteUserRecoveryCodes(ctx context.Context, userID string) error {
+	for hash, c := range f.recovery {
+		if c.UserID == userID {
+			delete(f.recovery, hash)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) CreateWebAuthnCredential(ctx context.Context, arg database.CreateWebAuthnCredentialParams) (database.WebauthnCredential, error) {
+	c := database.WebauthnCredential{
+		ID:           arg.ID,
+		UserID:       arg.UserID,
+		TenantID:     arg.TenantID,
+		CredentialID: arg.CredentialID,
+		PublicKey:    arg.PublicKey,
+		SignCount:    arg.SignCount,
+		Transports:   arg.Transports,
+		Aaguid:       arg.Aaguid,
+		Name:         arg.Name,
+		CreatedAt:    time.Now(),
+	}
+	f.passkeys[c.ID] = c
+	return c, nil
+}
+
+func (f *fakeStore) GetWebAuthnCredentialByCredentialID(ctx context.Context, arg database.GetWebAuthnCredentialByCredentialIDParams) (database.WebauthnCredential, error) {
+	for _, c := range f.passkeys {
+		if c.TenantID == arg.TenantID && bytes.Equal(c.CredentialID, arg.CredentialID) {
+			return c, nil
+		}
+	}
+	return database.WebauthnCredential{}, sql.ErrNoRows
+}
+
+func (f *fakeStore) ListWebAuthnCredentialsByUser(ctx context.Context, userID string) ([]database.WebauthnCredential, error) {
+	creds := []database.WebauthnCredential{}
+	for _, c := range f.passkeys {
+		if c.UserID == userID {
+			creds = append(creds, c)
+		}
+	}
+	return creds, nil
+}
+
+func (f *fakeStore) UpdateWebAuthnCredentialSignCount(ctx context.Context, arg database.UpdateWebAuthnCredentialSignCountParams) error {
+	c := f.passkeys[arg.ID]
+	c.SignCount = arg.SignCount
+	c.LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.passkeys[arg.ID] = c
+	return nil
+}
+
+func (f *fakeStore) DeleteWebAuthnCredential(ctx context.Context, arg database.DeleteWebAuthnCredentialParams) (int64, error) {
+	c, ok := f.passkeys[arg.ID]
+	if !ok || c.UserID != arg.UserID {
+		return 0, nil
+	}
+	delete(f.passkeys, arg.ID)
+	return 1, nil
+}
+
+func (f *fakeStore) CreateOIDCClient(ctx context.Context, arg database.CreateOIDCClientParams) (database.OidcClient, error) {
+	now := time.Now()
+	c := database.OidcClient{
+		ID:               arg.ID,
+		TenantID:         arg.TenantID,
+		Name:             arg.Name,
+		ClientSecretHash: arg.ClientSecretHash,
+		RedirectUris:     arg.RedirectUris,
+		SkipConsent:      arg.SkipConsent,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	f.oidcClients[c.ID] = c
+	return c, nil
+}
+
+func (f *fakeStore) GetOIDCClient(ctx context.Context, arg database.GetOIDCClientParams) (database.OidcClient, error) {
+	c, ok := f.oidcClients[arg.ID]
+	if !ok || c.TenantID != arg.TenantID {
+		return database.OidcClient{}, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+func (f *fakeStore) GetOIDCClientByID(ctx context.Context, id string) (database.OidcClient, error) {
+	c, ok := f.oidcClients[id]
+	if !ok {
+		return database.OidcClient{}, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+func (f *fakeStore) UpdateOIDCClientSecret(ctx context.Context, arg database.UpdateOIDCClientSecretParams) (int64, error) {
+	c, ok := f.oidcClients[arg.ID]
+	if !ok || c.TenantID != arg.TenantID {
+		return 0, nil
+	}
+	c.ClientSecretHash = arg.ClientSecretHash
+	c.UpdatedAt = time.Now()
+	f.oidcClients[arg.ID] = c
+	return 1, nil
+}
+
+func (f *fakeStore) DeleteOIDCClient(ctx context.Context, arg database.DeleteOIDCClientParams) (int64, error) {
+	c, ok := f.oidcClients[arg.ID]
+	if !ok || c.TenantID != arg.TenantID {
+		return 0, nil
+	}
+	delete(f.oidcClients, arg.ID)
+	for hash, t := range f.refresh {
+		if t.ClientID.String == arg.ID {
+			delete(f.refresh, hash)
+		}
+	}
+	return 1, nil
+}
+
+func (f *fakeStore) CreateOIDCAuthorizationCode(ctx context.Context, arg database.CreateOIDCAuthorizationCodeParams) error {
+	f.oidcCodes[arg.CodeHash] = database.OidcAuthorizationCode{
+		CodeHash:      arg.CodeHash,
+		TenantID:      arg.TenantID,
+		ClientID:      arg.ClientID,
+		UserID:        arg.UserID,
+		SessionID:     arg.SessionID,
+		RedirectUri:   arg.RedirectUri,
+		Scopes:        arg.Scopes,
+		Nonce:         arg.Nonce,
+		CodeChallenge: arg.CodeChallenge,
+		Amr:           arg.Amr,
+		ExpiresAt:     arg.ExpiresAt,
+		CreatedAt:     time.Now(),
+	}
+	return nil
+}
+
+func (f *fakeStore) GetOIDCAuthorizationCode(ctx context.Context, codeHash string) (database.OidcAuthorizationCode, error) {
+	c, ok := f.oidcCodes[codeHash]
+	if !ok {
+		return database.OidcAuthorizationCode{}, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+func (f *fakeStore) MarkOIDCAuthorizationCodeUsed(ctx context.Context, codeHash string) (int64, error) {
+	c, ok := f.oidcCodes[codeHash]
+	if !ok || c.UsedAt.Valid {
+		return 0, nil
+	}
+	c.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	f.oidcCodes[codeHash] = c
+	return 1, nil
+}
+
+func (f *fakeStore) GetOIDCConsent(ctx context.Context, arg database.GetOIDCConsentParams) (database.OidcConsent, error) {
+	c, ok := f.oidcConsents[[2]string{arg.UserID, arg.ClientID}]
+	if !ok {
+		return database.OidcConsent{}, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+func (f *fakeStore) UpsertOIDCConsent(ctx context.Context, arg database.UpsertOIDCConsentParams) error {
+	key := [2]string{arg.UserID, arg.ClientID}
+	c, ok := f.oidcConsents[key]
+	if !ok {
+		c = database.OidcConsent{UserID: arg.UserID, ClientID: arg.ClientID, TenantID: arg.TenantID, CreatedAt: time.Now()}
+	}
+	for _, scope := range arg.Scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			c.Scopes = append(c.Scopes, scope)
+		}
+	}
+	slices.Sort(c.Scopes)
+	c.UpdatedAt = time.Now()
+	f.oidcConsents[key] = c
+	return nil
+}
+
+func newTestService(t *testing.T, settings string) (*Service, *fakeStore, *captureMailer) {
+	t.Helper()
+	store := newFakeStore()
+	store.tenants["tnt_test"] = database.Tenant{ID: "tnt_test", Name: "Test Tenant", Settings: json.RawMessage(settings)}
+	mail := &captureMailer{}
+	guard := lockout.NewGuard(store, map[string]lockout.Policy{
+		lockout.ScopeAccount:      {Threshold: 3, LockoutDuration: time.Minute},
+		lockout.ScopeMFAChallenge: MFAChallengePolicy,
+	})
+	svc := NewService(store, auth.NewHS256Signer("test-secret-key-for-unit-tests-only"), mail, revocation.NewStore(store, AccessTokenTTL), guard, nil, newTestHasher(t, auth.AlgorithmArgon2id), nil)
+	return svc, store, mail
+}
+
+// newTestHasher 使用低开销参数的密码哈希器，加快测试
+func newTestHasher(t *testing.T, algorithm string) *auth.PasswordHasher {
+	t.Helper()
+	cfg := auth.DefaultHasherConfig()
+	cfg.Algorithm = algorithm
+	cfg.Argon2id.Memory = 64
+	cfg.Argon2id.Iterations = 1
+	cfg.BcryptCost = bcrypt.MinCost
+	hasher, err := auth.NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewPasswordHasher: %v", err)
+	}
+	return hasher
+}
+
+// lastToken 从最近一封邮件正文中取出令牌（正文最后一行）
+func lastToken(t *testing.T, mail *captureMailer) string {
+	t.Helper()
+	if len(mail.messages) == 0 {
+		t.Fatal("expected a message to be sent")
+	}
+	body := strings.TrimSpace(mail.messages[len(mail.messages)-1].Body)
+	lines := strings.Split(body, "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
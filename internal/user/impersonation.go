@@ -0,0 +1,160 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+)
+
+// ImpersonationTokenTTL 模拟令牌有效期。模拟令牌不属于任何会话，不能刷新
+const ImpersonationTokenTTL = 15 * time.Minute
+
+// 模拟记录分页
+const (
+	// DefaultImpersonationPageSize 未指定 limit 时每页返回的记录数
+	DefaultImpersonationPageSize = 50
+	// MaxImpersonationPageSize 每页最多返回的记录数
+	MaxImpersonationPageSize = 200
+)
+
+// ErrImpersonationReasonRequired 未填写模拟原因
+var ErrImpersonationReasonRequired = errors.New("impersonation reason is required")
+
+// ImpersonateRequest 模拟用户请求
+type ImpersonateRequest struct {
+	// Reason 模拟原因，如工单号，写入审计记录
+	Reason string `json:"reason" binding:"required,max=500"`
+	// Actor 实际操作人（如客服人员的邮箱），为空时使用发起模拟的 client_id
+	Actor string `json:"actor" binding:"max=255"`
+}
+
+// ImpersonationResponse 一条模拟审计记录
+type ImpersonationResponse struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	ClientID  string `json:"client_id"`
+	Actor     string `json:"actor"`
+	Reason    string `json:"reason"`
+	TokenID   string `json:"token_id"`
+	ExpiresAt string `json:"expires_at"`
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ImpersonateResponse 模拟令牌及其审计记录
+type ImpersonateResponse struct {
+	Token         string                 `json:"token"`
+	TokenType     string                 `json:"token_type"`
+	ExpiresIn     int64                  `json:"expires_in"`
+	Impersonation *ImpersonationResponse `json:"impersonation"`
+}
+
+// ListImpersonationsRequest 模拟记录查询条件，为空的条件不过滤
+type ListImpersonationsRequest struct {
+	UserID string `form:"user_id"`
+	Actor  string `form:"actor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=200"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+}
+
+func toImpersonationResponse(imp database.UserImpersonation) *ImpersonationResponse {
+	return &ImpersonationResponse{
+		ID:        imp.ID,
+		UserID:    imp.UserID,
+		ClientID:  imp.ClientID,
+		Actor:     imp.Actor,
+		Reason:    imp.Reason,
+		TokenID:   imp.TokenID,
+		ExpiresAt: imp.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		ClientIP:  imp.ClientIp.String,
+		UserAgent: imp.UserAgent.String,
+		CreatedAt: imp.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// Impersonate 内部服务以用户身份签发模拟令牌。令牌携带 act 声明标明实际操作方，
+// 有效期为 ImpersonationTokenTTL，不属于任何会话；每次签发都写入审计记录，写入失败时不返回令牌。
+// 用户不存在时返回 ErrUserNotFound，停用或锁定时返回 ErrUserDisabled 或 ErrUserLocked
+func (s *Service) Impersonate(ctx context.Context, tenantID, userID, clientID string, req ImpersonateRequest, clientIP, userAgent string) (*ImpersonateResponse, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, ErrImpersonationReasonRequired
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if actor == "" {
+		actor = clientID
+	}
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.userClaims(ctx, user, "", "", nil, ImpersonationTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	claims.Act = &auth.Actor{Subject: actor, ClientID: clientID}
+	token, err := s.signClaims(ctx, user, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	imp, err := s.db.CreateImpersonation(ctx, database.CreateImpersonationParams{
+		ID:        generateID("impn"),
+		TenantID:  tenantID,
+		UserID:    user.ID,
+		ClientID:  clientID,
+		Actor:     actor,
+		Reason:    reason,
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record impersonation: %w", err)
+	}
+	slog.Warn("User impersonated", "tenant_id", tenantID, "user_id", user.ID, "client_id", clientID, "actor", actor, "impersonation_id", imp.ID)
+
+	return &ImpersonateResponse{
+		Token:         token,
+		TokenType:     "Bearer",
+		ExpiresIn:     int64(ImpersonationTokenTTL.Seconds()),
+		Impersonation: toImpersonationResponse(imp),
+	}, nil
+}
+
+// ListImpersonations 按创建时间倒序列出租户的模拟审计记录
+func (s *Service) ListImpersonations(ctx context.Context, tenantID string, req ListImpersonationsRequest) ([]*ImpersonationResponse, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultImpersonationPageSize
+	}
+	limit = min(limit, MaxImpersonationPageSize)
+	rows, err := s.db.ListImpersonations(ctx, database.ListImpersonationsParams{
+		TenantID:  tenantID,
+		UserID:    sql.NullString{String: req.UserID, Valid: req.UserID != ""},
+		Actor:     sql.NullString{String: req.Actor, Valid: req.Actor != ""},
+		RowLimit:  int32(limit),
+		RowOffset: int32(req.Offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list impersonations: %w", err)
+	}
+	result := make([]*ImpersonationResponse, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, toImpersonationResponse(row))
+	}
+	return result, nil
+}
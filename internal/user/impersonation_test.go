@@ -0,0 +1,67 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestImpersonate(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	kai, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.Impersonate(ctx, "tnt_test", kai.ID, "support-tool", ImpersonateRequest{Reason: "  "}, "", ""); !errors.Is(err, ErrImpersonationReasonRequired) {
+		t.Fatalf("expected ErrImpersonationReasonRequired, got %v", err)
+	}
+	if _, err := svc.Impersonate(ctx, "tnt_other", kai.ID, "support-tool", ImpersonateRequest{Reason: "ticket 42"}, "", ""); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	resp, err := svc.Impersonate(ctx, "tnt_test", kai.ID, "support-tool", ImpersonateRequest{Reason: "ticket 42", Actor: "alice@support.example.com"}, "10.0.0.1", "")
+	if err != nil {
+		t.Fatalf("Impersonate: %v", err)
+	}
+	claims := accessTokenClaims(t, svc, resp.Token)
+	if claims.UserID != kai.ID || claims.SessionID != "" || claims.Act == nil ||
+		claims.Act.Subject != "alice@support.example.com" || claims.Act.ClientID != "support-tool" {
+		t.Fatalf("unexpected impersonation claims: %+v", claims)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > ImpersonationTokenTTL || ttl < ImpersonationTokenTTL-time.Minute {
+		t.Fatalf("unexpected token lifetime: %v", ttl)
+	}
+	// 内省结果标明实际操作方
+	info, err := svc.IntrospectToken(ctx, resp.Token, "")
+	if err != nil || info == nil || info.Act == nil || info.Act.Subject != "alice@support.example.com" {
+		t.Fatalf("unexpected introspection: %+v, %v", info, err)
+	}
+
+	// 未提供操作人时使用 client_id
+	if _, err := svc.Impersonate(ctx, "tnt_test", kai.ID, "support-tool", ImpersonateRequest{Reason: "ticket 43"}, "", ""); err != nil {
+		t.Fatalf("Impersonate: %v", err)
+	}
+	records, err := svc.ListImpersonations(ctx, "tnt_test", ListImpersonationsRequest{UserID: kai.ID})
+	if err != nil {
+		t.Fatalf("ListImpersonations: %v", err)
+	}
+	if len(records) != 2 || records[0].Actor != "support-tool" || records[1].Reason != "ticket 42" ||
+		records[1].TokenID != claims.ID || records[1].ClientIP != "10.0.0.1" {
+		t.Fatalf("unexpected audit records: %+v", records)
+	}
+	if records, _ := svc.ListImpersonations(ctx, "tnt_test", ListImpersonationsRequest{Actor: "alice@support.example.com"}); len(records) != 1 {
+		t.Fatalf("expected one record for the actor, got %+v", records)
+	}
+
+	// 停用的用户不能被模拟
+	disabled := StatusDisabled
+	if _, err := svc.UpdateUser(ctx, "tnt_test", kai.ID, UpdateUserRequest{Status: &disabled}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := svc.Impersonate(ctx, "tnt_test", kai.ID, "support-tool", ImpersonateRequest{Reason: "ticket 44"}, "", ""); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("expected ErrUserDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,510 @@
+package user
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/profileschema"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+
+	"github.com/sqlc-dev/pqtype"
+)
+
+// 导入文件格式
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// 导入任务状态
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+const (
+	// MaxImportSize 单个导入文件的最大字节数，更大的导入需拆分为多个文件
+	MaxImportSize = 512 << 20
+	// importJobLease 心跳超过该时长的运行中任务视为处理实例已退出，可被重新领取
+	importJobLease = 5 * time.Minute
+	// importErrorsPageSize 任务状态中每次返回的失败行数
+	importErrorsPageSize = 1000
+	// maxImportLineSize NDJSON 单行的最大字节数
+	maxImportLineSize = 1 << 20
+)
+
+var (
+	// ErrInvalidImport 导入文件为空、格式不支持或表头不合法
+	ErrInvalidImport = errors.New("invalid import file")
+	// ErrImportJobNotFound 导入任务不存在或不属于当前租户
+	ErrImportJobNotFound = errors.New("import job not found")
+)
+
+// importColumns 导入文件支持的字段，CSV 表头和 NDJSON 的键相同
+var importColumns = []string{"email", "password", "password_hash", "email_verified", "profile"}
+
+// importRow 待导入的一行。password 为明文密码，按租户密码策略校验后哈希；
+// password_hash 为其他系统导出的哈希，原样保存，用户首次登录时升级为当前算法
+type importRow struct {
+	Email         string                 `json:"email"`
+	Password      string                 `json:"password"`
+	PasswordHash  string                 `json:"password_hash"`
+	EmailVerified bool                   `json:"email_verified"`
+	Profile       map[string]interface{} `json:"profile"`
+}
+
+// importRowError 单行数据无效，记录到任务的失败行后继续处理
+type importRowError struct {
+	msg string
+}
+
+func (e *importRowError) Error() string { return e.msg }
+
+func rowErrorf(format string, args ...any) error {
+	return &importRowError{msg: fmt.Sprintf(format, args...)}
+}
+
+// importReader 逐行读取导入文件，读完返回 io.EOF；单行无效时返回 *importRowError 并可继续读取
+type importReader interface {
+	next() (importRow, error)
+}
+
+func newImportReader(format string, payload []byte) (importReader, error) {
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidImport)
+	}
+	switch format {
+	case ImportFormatCSV:
+		return newCSVImportReader(payload)
+	case ImportFormatNDJSON:
+		scanner := bufio.NewScanner(bytes.NewReader(payload))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+		return &ndjsonImportReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImport, format)
+	}
+}
+
+type csvImportReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+func newCSVImportReader(payload []byte) (*csvImportReader, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(payload, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidImport, err)
+	}
+	seen := make(map[string]bool)
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(importColumns, name) || seen[name] {
+			return nil, fmt.Errorf("%w: unknown or duplicate CSV column %q", ErrInvalidImport, header[i])
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	if !seen["email"] {
+		return nil, fmt.Errorf("%w: CSV header must include an email column", ErrInvalidImport)
+	}
+	return &csvImportReader{r: r, columns: columns}, nil
+}
+
+func (c *csvImportReader) next() (importRow, error) {
+	var row importRow
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return row, err
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return row, rowErrorf("malformed CSV: %v", parseErr.Err)
+	}
+	if err != nil {
+		return row, err
+	}
+	if len(record) != len(c.columns) {
+		return row, rowErrorf("expected %d fields, got %d", len(c.columns), len(record))
+	}
+	for i, value := range record {
+		switch c.columns[i] {
+		case "email":
+			row.Email = strings.TrimSpace(value)
+		case "password":
+			row.Password = value
+		case "password_hash":
+			row.PasswordHash = strings.TrimSpace(value)
+		case "email_verified":
+			if value = strings.TrimSpace(value); value != "" {
+				if row.EmailVerified, err = strconv.ParseBool(value); err != nil {
+					return row, rowErrorf("invalid email_verified %q", value)
+				}
+			}
+		case "profile":
+			if strings.TrimSpace(value) != "" {
+				if err := json.Unmarshal([]byte(value), &row.Profile); err != nil {
+					return row, rowErrorf("profile must be a JSON object")
+				}
+			}
+		}
+	}
+	return row, nil
+}
+
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+}
+
+func (n *ndjsonImportReader) next() (importRow, error) {
+	var row importRow
+	for n.scanner.Scan() {
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row); err != nil {
+			return importRow{}, rowErrorf("invalid JSON: %v", err)
+		}
+		row.Email = strings.TrimSpace(row.Email)
+		row.PasswordHash = strings.TrimSpace(row.PasswordHash)
+		return row, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return row, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	return row, io.EOF
+}
+
+// ImportJobResponse 导入任务状态
+type ImportJobResponse struct {
+	ID            string `json:"id"`
+	Format        string `json:"format"`
+	Status        string `json:"status"`
+	ProcessedRows int32  `json:"processed_rows"`
+	Created       int32  `json:"created"`
+	Skipped       int32  `json:"skipped"`
+	Failed        int32  `json:"failed"`
+	// Error 任务整体失败的原因，单行失败见 Errors
+	Error      string `json:"error,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+	// Errors 失败的行，按行号排序，每次最多返回1000条
+	Errors []ImportRowError `json:"errors"`
+	// NextErrorsAfter 非零时可作为 errors_after 参数获取更多失败行
+	NextErrorsAfter int32 `json:"next_errors_after,omitempty"`
+}
+
+// ImportRowError 导入失败的一行，row 从1开始，不含CSV表头
+type ImportRowError struct {
+	Row   int32  `json:"row"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+func toImportJobResponse(job database.GetUserImportJobRow) *ImportJobResponse {
+	resp := &ImportJobResponse{
+		ID:            job.ID,
+		Format:        job.Format,
+		Status:        job.Status,
+		ProcessedRows: job.ProcessedRows,
+		Created:       job.CreatedCount,
+		Skipped:       job.SkippedCount,
+		Failed:        job.FailedCount,
+		Error:         job.Error.String,
+		CreatedAt:     job.CreatedAt.Format(time.RFC3339),
+		Errors:        []ImportRowError{},
+	}
+	if job.StartedAt.Valid {
+		resp.StartedAt = job.StartedAt.Time.Format(time.RFC3339)
+	}
+	if job.FinishedAt.Valid {
+		resp.FinishedAt = job.FinishedAt.Time.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// CreateImportJob 保存上传的导入文件并创建异步任务，文件格式和表头在此校验，数据行由后台任务处理
+func (s *Service) CreateImportJob(ctx context.Context, tenantID, format string, payload []byte) (*ImportJobResponse, error) {
+	if _, err := newImportReader(format, payload); err != nil {
+		return nil, err
+	}
+	job, err := s.db.CreateUserImportJob(ctx, database.CreateUserImportJobParams{
+		ID:       generateID("imp"),
+		TenantID: tenantID,
+		Format:   format,
+		Payload:  payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	slog.Info("User import job created", "job_id", job.ID, "tenant_id", tenantID, "format", format, "bytes", len(payload))
+
+	return toImportJobResponse(database.GetUserImportJobRow(job)), nil
+}
+
+// GetImportJob 获取导入任务的进度和行号大于 errorsAfter 的失败行
+func (s *Service) GetImportJob(ctx context.Context, tenantID, jobID string, errorsAfter int32) (*ImportJobResponse, error) {
+	job, err := s.db.GetUserImportJob(ctx, database.GetUserImportJobParams{ID: jobID, TenantID: tenantID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrImportJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	rowErrors, err := s.db.ListUserImportErrors(ctx, database.ListUserImportErrorsParams{
+		JobID:    jobID,
+		AfterRow: errorsAfter,
+		Lim:      importErrorsPageSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import errors: %w", err)
+	}
+
+	resp := toImportJobResponse(job)
+	for _, e := range rowErrors {
+		resp.Errors = append(resp.Errors, ImportRowError{Row: e.RowNumber, Email: e.Email.String, Error: e.Error})
+	}
+	if len(rowErrors) == importErrorsPageSize {
+		resp.NextErrorsAfter = rowErrors[len(rowErrors)-1].RowNumber
+	}
+	return resp, nil
+}
+
+// RunImports 定期领取并处理导入任务，直到 ctx 取消。多实例部署时每个任务只由一个实例处理
+func (s *Service) RunImports(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ProcessImportJobs(ctx)
+		}
+	}
+}
+
+// ProcessImportJobs 依次处理当前所有可领取的导入任务
+func (s *Service) ProcessImportJobs(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := s.db.ClaimUserImportJob(ctx, sql.NullTime{Time: time.Now().Add(-importJobLease), Valid: true})
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to claim user import job", "error", err)
+			return
+		}
+		// 中断的任务保持 running，心跳超时后从已保存的进度继续
+		if err := s.processImportJob(ctx, job); err != nil {
+			slog.Error("User import job interrupted", "job_id", job.ID, "tenant_id", job.TenantID, "processed_rows", job.ProcessedRows, "error", err)
+			return
+		}
+	}
+}
+
+// processImportJob 从上次保存的进度继续处理任务。无法读取的文件使任务失败，
+// 无效的行记录为失败行，已存在的邮箱计为跳过。
+// 每行处理后立即保存进度和计数，续跑时不会把已创建的用户重新计为跳过
+func (s *Service) processImportJob(ctx context.Context, job database.UserImportJob) error {
+	slog.Info("User import job started", "job_id", job.ID, "tenant_id", job.TenantID, "resume_from", job.ProcessedRows)
+
+	settings, err := s.tenantSettings(ctx, job.TenantID)
+	if err != nil {
+		return err
+	}
+	schema, schemaVersion, err := s.profileSchema(ctx, job.TenantID)
+	if err != nil {
+		return err
+	}
+	reader, err := newImportReader(job.Format, job.Payload)
+	if err != nil {
+		return s.finishImportJob(ctx, job.ID, ImportStatusFailed, err.Error())
+	}
+
+	progress := database.UpdateUserImportJobProgressParams{
+		ID:            job.ID,
+		ProcessedRows: job.ProcessedRows,
+		CreatedCount:  job.CreatedCount,
+		SkippedCount:  job.SkippedCount,
+		FailedCount:   job.FailedCount,
+	}
+	for rowNumber := int32(1); ; rowNumber++ {
+		row, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *importRowError
+		if err != nil && !errors.As(err, &rowErr) {
+			if err := s.db.UpdateUserImportJobProgress(ctx, progress); err != nil {
+				return fmt.Errorf("failed to save import progress: %w", err)
+			}
+			return s.finishImportJob(ctx, job.ID, ImportStatusFailed, err.Error())
+		}
+		// 已处理过的行直接跳过
+		if rowNumber <= job.ProcessedRows {
+			continue
+		}
+
+		created := false
+		if err == nil {
+			created, err = s.importUser(ctx, job.TenantID, settings.PasswordPolicy, schema, schemaVersion, row)
+			if err != nil && !errors.As(err, &rowErr) {
+				return err
+			}
+		}
+		switch {
+		case rowErr != nil:
+			progress.FailedCount++
+			if err := s.db.CreateUserImportError(ctx, database.CreateUserImportErrorParams{
+				JobID:     job.ID,
+				RowNumber: rowNumber,
+				Email:     sql.NullString{String: truncate(row.Email, 255), Valid: row.Email != ""},
+				Error:     rowErr.msg,
+			}); err != nil {
+				return fmt.Errorf("failed to record import error: %w", err)
+			}
+		case created:
+			progress.CreatedCount++
+		default:
+			progress.SkippedCount++
+		}
+
+		progress.ProcessedRows = rowNumber
+		if err := s.db.UpdateUserImportJobProgress(ctx, progress); err != nil {
+			return fmt.Errorf("failed to save import progress: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.UpdateUserImportJobProgress(ctx, progress); err != nil {
+		return fmt.Errorf("failed to save import progress: %w", err)
+	}
+	slog.Info("User import job completed", "job_id", job.ID, "tenant_id", job.TenantID,
+		"created", progress.CreatedCount, "skipped", progress.SkippedCount, "failed", progress.FailedCount)
+	return s.finishImportJob(ctx, job.ID, ImportStatusCompleted, "")
+}
+
+func (s *Service) finishImportJob(ctx context.Context, jobID, status, reason string) error {
+	if err := s.db.FinishUserImportJob(ctx, database.FinishUserImportJobParams{
+		ID:     jobID,
+		Status: status,
+		Error:  sql.NullString{String: reason, Valid: reason != ""},
+	}); err != nil {
+		return fmt.Errorf("failed to finish import job: %w", err)
+	}
+	if status == ImportStatusFailed {
+		slog.Warn("User import job failed", "job_id", jobID, "reason", reason)
+	}
+	return nil
+}
+
+// importUser 创建一个导入的用户，邮箱已存在时返回 false。不发送验证邮件。
+// schema 为租户的资料 schema，为nil时不校验资料；导入视为内部写入，可以包含只读字段
+func (s *Service) importUser(ctx context.Context, tenantID string, policy tenant.PasswordPolicy, schema *profileschema.Schema, schemaVersion int32, row importRow) (bool, error) {
+	if len(row.Email) > 255 {
+		return false, rowErrorf("email is too long")
+	}
+	if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+		return false, rowErrorf("invalid email %q", row.Email)
+	}
+
+	var hashedPassword sql.NullString
+	switch {
+	case row.Password != "" && row.PasswordHash != "":
+		return false, rowErrorf("password and password_hash are mutually exclusive")
+	case row.PasswordHash != "":
+		if len(row.PasswordHash) > 255 {
+			return false, rowErrorf("password_hash is too long")
+		}
+		if err := auth.ValidateHash(row.PasswordHash); err != nil {
+			return false, rowErrorf("%v", err)
+		}
+		hashedPassword = sql.NullString{String: row.PasswordHash, Valid: true}
+	case row.Password != "":
+		if err := s.validatePassword(ctx, policy, row.Password, nil); err != nil {
+			var policyErr *PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				return false, rowErrorf("%v", err)
+			}
+			return false, err
+		}
+		hash, err := s.hasher.Hash(row.Password)
+		if err != nil {
+			return false, fmt.Errorf("failed to hash password: %w", err)
+		}
+		hashedPassword = sql.NullString{String: hash, Valid: true}
+	}
+
+	if schema != nil {
+		checked, err := checkProfile(schema, schemaVersion, nil, row.Profile, true)
+		if err != nil {
+			var profileErr *ProfileValidationError
+			if errors.As(err, &profileErr) {
+				return false, rowErrorf("%v", err)
+			}
+			return false, err
+		}
+		row.Profile = checked
+	}
+
+	var profile pqtype.NullRawMessage
+	if row.Profile != nil {
+		raw, err := json.Marshal(row.Profile)
+		if err != nil {
+			return false, rowErrorf("invalid profile: %v", err)
+		}
+		profile = pqtype.NullRawMessage{RawMessage: raw, Valid: true}
+	}
+
+	created, err := s.db.CreateImportedUser(ctx, database.CreateImportedUserParams{
+		ID:             generateID("usr"),
+		TenantID:       tenantID,
+		Email:          row.Email,
+		HashedPassword: hashedPassword,
+		Profile:        profile,
+		EmailVerified:  row.EmailVerified,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create imported user: %w", err)
+	}
+	return created > 0, nil
+}
+
+// truncate 按字节截断字符串，不截断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
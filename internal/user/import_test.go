@@ -0,0 +1,196 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/store/database"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestImportUsersCSV(t *testing.T) {
+	ctx := context.Background()
+	svc, store, mail := newTestService(t, `{}`)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "existing@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	mail.messages = nil
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("s3cret-bcrypt"), bcrypt.MinCost)
+	csv := strings.Join([]string{
+		"email,password_hash,password,email_verified,profile",
+		fmt.Sprintf("bcrypt@example.com,%s,,true,\"{\"\"name\"\":\"\"Ann\"\"}\"", bcryptHash),
+		"django@example.com,pbkdf2_sha256$1000$djangosalt$ouBhjvwNxLXb8Rduzjf0oIjDFHUWkqIOpgX+oeR1KZc=,,,",
+		"plain@example.com,,password123,false,",
+		"existing@example.com,,password123,,",
+		"not-an-email,,password123,,",
+		"both@example.com,pbkdf2_sha256$1000$salt$a2V5,password123,,",
+		"badhash@example.com,md5$abc,,,",
+		"weak@example.com,,abc,,",
+		"short@example.com,,",
+		"nopassword@example.com,,,1,",
+	}, "\n")
+
+	job, err := svc.CreateImportJob(ctx, "tnt_test", ImportFormatCSV, []byte(csv))
+	if err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+	if job.Status != ImportStatusPending {
+		t.Fatalf("expected a pending job, got %+v", job)
+	}
+	svc.ProcessImportJobs(ctx)
+
+	job, err = svc.GetImportJob(ctx, "tnt_test", job.ID, 0)
+	if err != nil {
+		t.Fatalf("GetImportJob: %v", err)
+	}
+	if job.Status != ImportStatusCompleted || job.ProcessedRows != 10 || job.Created != 4 || job.Skipped != 1 || job.Failed != 5 {
+		t.Fatalf("unexpected job result: %+v", job)
+	}
+	wantErrors := map[int32]string{5: "invalid email", 6: "mutually exclusive", 7: "unsupported password hash", 8: "password does not meet", 9: "expected 5 fields"}
+	if len(job.Errors) != len(wantErrors) {
+		t.Fatalf("expected %d row errors, got %+v", len(wantErrors), job.Errors)
+	}
+	for _, e := range job.Errors {
+		if !strings.Contains(e.Error, wantErrors[e.Row]) {
+			t.Fatalf("row %d: unexpected error %q", e.Row, e.Error)
+		}
+	}
+	if len(mail.messages) != 0 {
+		t.Fatalf("imports should not send emails, sent %d", len(mail.messages))
+	}
+	if store.importJobs[job.ID].Payload != nil {
+		t.Fatal("expected the payload to be cleared after completion")
+	}
+
+	// 导入的外部哈希可以登录，并在登录时升级为当前算法
+	for email, password := range map[string]string{"bcrypt@example.com": "s3cret-bcrypt", "django@example.com": "s3cret"} {
+		resp, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: email, Password: password}, "", "")
+		if err != nil {
+			t.Fatalf("%s: Login: %v", email, err)
+		}
+		if !strings.HasPrefix(store.users[resp.User.ID].HashedPassword.String, "$argon2id$") {
+			t.Fatalf("%s: expected the imported hash to be rehashed", email)
+		}
+	}
+	imported, _ := svc.db.GetUserByEmail(ctx, database.GetUserByEmailParams{TenantID: "tnt_test", Email: "bcrypt@example.com"})
+	if !imported.EmailVerified || !strings.Contains(string(imported.Profile.RawMessage), "Ann") {
+		t.Fatalf("expected email_verified and profile to be imported, got %+v", imported)
+	}
+
+	// 重复导入同一文件不会创建重复用户
+	users := len(store.users)
+	again, err := svc.CreateImportJob(ctx, "tnt_test", ImportFormatCSV, []byte(csv))
+	if err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+	svc.ProcessImportJobs(ctx)
+	again, _ = svc.GetImportJob(ctx, "tnt_test", again.ID, 0)
+	if len(store.users) != users || again.Created != 0 || again.Skipped != 5 || again.Failed != 5 {
+		t.Fatalf("expected a re-run to skip existing users, got %+v", again)
+	}
+
+	if _, err := svc.GetImportJob(ctx, "tnt_other", job.ID, 0); !errors.Is(err, ErrImportJobNotFound) {
+		t.Fatalf("expected ErrImportJobNotFound across tenants, got %v", err)
+	}
+}
+
+func TestImportUsersResumesAndRejectsInvalidFiles(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	for name, tc := range map[string]struct{ format, payload string }{
+		"empty":          {ImportFormatCSV, "  \n"},
+		"unknown column": {ImportFormatCSV, "email,username\na@example.com,a"},
+		"missing email":  {ImportFormatCSV, "password\npassword123"},
+		"format":         {"xml", "<users/>"},
+	} {
+		if _, err := svc.CreateImportJob(ctx, "tnt_test", tc.format, []byte(tc.payload)); !errors.Is(err, ErrInvalidImport) {
+			t.Fatalf("%s: expected ErrInvalidImport, got %v", name, err)
+		}
+	}
+
+	ndjson := strings.Join([]string{
+		`{"email": "one@example.com", "password": "password123"}`,
+		``,
+		`{"email": "two@example.com", "password": "password123"}`,
+		`{"email": "three@example.com", "passwrd": "password123"}`,
+		`{"email": "four@example.com", "profile": {"team": "core"}}`,
+	}, "\n")
+	job, err := svc.CreateImportJob(ctx, "tnt_test", ImportFormatNDJSON, []byte(ndjson))
+	if err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+
+	// 模拟处理实例在第1行之后退出：任务仍为 running，心跳已超时
+	stale := store.importJobs[job.ID]
+	stale.Status = ImportStatusRunning
+	stale.ProcessedRows, stale.CreatedCount = 1, 1
+	stale.HeartbeatAt = sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+	store.importJobs[job.ID] = stale
+
+	svc.ProcessImportJobs(ctx)
+	result, err := svc.GetImportJob(ctx, "tnt_test", job.ID, 0)
+	if err != nil {
+		t.Fatalf("GetImportJob: %v", err)
+	}
+	if result.Status != ImportStatusCompleted || result.ProcessedRows != 4 || result.Created != 3 || result.Failed != 1 {
+		t.Fatalf("unexpected resumed job: %+v", result)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Row != 3 || !strings.Contains(result.Errors[0].Error, "unknown field") {
+		t.Fatalf("unexpected row errors: %+v", result.Errors)
+	}
+	if _, err := svc.db.GetUserByEmail(ctx, database.GetUserByEmailParams{TenantID: "tnt_test", Email: "one@example.com"}); err == nil {
+		t.Fatal("rows before the saved progress should not be processed again")
+	}
+}
+
+// failingImportStore 创建指定邮箱的用户时返回错误，模拟处理中途数据库故障
+type failingImportStore struct {
+	*fakeStore
+	failEmail string
+}
+
+func (f *failingImportStore) CreateImportedUser(ctx context.Context, arg database.CreateImportedUserParams) (int64, error) {
+	if arg.Email == f.failEmail {
+		return 0, errors.New("connection reset")
+	}
+	return f.fakeStore.CreateImportedUser(ctx, arg)
+}
+
+func TestImportResumeKeepsCounts(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	job, err := svc.CreateImportJob(ctx, "tnt_test", ImportFormatCSV, []byte("email\nann@example.com\nbob@example.com\ncarl@example.com\n"))
+	if err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+
+	// 第3行写入失败时中断，任务仍为 running
+	svc.db = &failingImportStore{fakeStore: store, failEmail: "carl@example.com"}
+	svc.ProcessImportJobs(ctx)
+	interrupted := store.importJobs[job.ID]
+	if interrupted.Status != ImportStatusRunning || interrupted.ProcessedRows != 2 || interrupted.CreatedCount != 2 {
+		t.Fatalf("expected progress saved up to the failing row, got %+v", interrupted)
+	}
+
+	// 心跳超时后续跑，已创建的用户不会被重新计为跳过
+	svc.db = store
+	interrupted.HeartbeatAt = sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+	store.importJobs[job.ID] = interrupted
+	svc.ProcessImportJobs(ctx)
+	result, err := svc.GetImportJob(ctx, "tnt_test", job.ID, 0)
+	if err != nil {
+		t.Fatalf("GetImportJob: %v", err)
+	}
+	if result.Status != ImportStatusCompleted || result.ProcessedRows != 3 || result.Created != 3 || result.Skipped != 0 {
+		t.Fatalf("unexpected resumed job: %+v", result)
+	}
+}
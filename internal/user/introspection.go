@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/auth"
+)
+
+// IntrospectToken 内省为租户用户签发的访问令牌或 refresh token（RFC 7662）。
+// hint 为 refresh_token 时先按 refresh token 查找。令牌不是用户令牌，或已过期、已吊销、
+// 用户已删除或被禁用时返回 nil；只有查询失败才返回错误
+func (s *Service) IntrospectToken(ctx context.Context, token, hint string) (*auth.Introspection, error) {
+	lookups := []func(context.Context, string) (*auth.Introspection, error){s.introspectAccessToken, s.introspectRefreshToken}
+	if hint == auth.TokenTypeRefresh {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		info, err := lookup(ctx, token)
+		if err != nil || info != nil {
+			return info, err
+		}
+	}
+	return nil, nil
+}
+
+// introspectAccessToken 校验签名、有效期和吊销状态，scope 为令牌授予的权限
+func (s *Service) introspectAccessToken(ctx context.Context, token string) (*auth.Introspection, error) {
+	claims := &auth.Claims{}
+	// 用途令牌不带 user_id，不能当作访问令牌
+	if err := s.signer.Parse(token, claims); err != nil || claims.UserID == "" {
+		return nil, nil
+	}
+	if s.revoker.IsRevoked(claims) {
+		return nil, nil
+	}
+	user, err := s.currentUser(ctx, claims.TenantID, claims.UserID)
+	if err != nil || checkUserStatus(user) != nil {
+		return nil, nil
+	}
+	permissions := claims.Permissions
+	if claims.AuthzOverflow {
+		authz, err := s.userAuthorization(ctx, user, claims.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		permissions = authz.Permissions
+	}
+	// 通过 OIDC 授权码签发的令牌，client_id 为依赖方
+	clientID := user.TenantID
+	if claims.ClientID != "" {
+		clientID = claims.ClientID
+	}
+	info := &auth.Introspection{
+		Active:    true,
+		Scope:     strings.Join(permissions, " "),
+		ClientID:  clientID,
+		Username:  user.Email,
+		TokenType: auth.TokenTypeBearer,
+		Sub:       user.ID,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		TenantID:  user.TenantID,
+		Act:       claims.Act,
+	}
+	info.SetTimes(claims.RegisteredClaims)
+	return info, nil
+}
+
+// introspectRefreshToken 与 RefreshTokens 的校验一致：已轮换、已吊销、已过期或会话已结束的令牌无效
+func (s *Service) introspectRefreshToken(ctx context.Context, token string) (*auth.Introspection, error) {
+	stored, err := s.db.GetRefreshTokenByHash(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if stored.RevokedAt.Valid || stored.RotatedAt.Valid || !stored.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	session, err := s.db.GetUserSession(ctx, stored.FamilyID)
+	if err != nil || session.RevokedAt.Valid {
+		return nil, nil
+	}
+	user, err := s.currentUser(ctx, stored.TenantID, stored.UserID)
+	if err != nil || checkUserStatus(user) != nil {
+		return nil, nil
+	}
+	info := &auth.Introspection{
+		Active:    true,
+		ClientID:  user.TenantID,
+		Username:  user.Email,
+		TokenType: auth.TokenTypeRefresh,
+		Exp:       stored.ExpiresAt.Unix(),
+		Iat:       stored.CreatedAt.Unix(),
+		Sub:       user.ID,
+		TenantID:  user.TenantID,
+	}
+	// 绑定 OIDC 客户端的令牌，scope 为授予该客户端的范围
+	if stored.ClientID.Valid {
+		info.ClientID = stored.ClientID.String
+		info.Scope = strings.Join(stored.Scopes, " ")
+	}
+	return info, nil
+}
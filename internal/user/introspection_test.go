@@ -0,0 +1,81 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"yuyu-test/internal/auth"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	kai, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	editor, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "editor", Permissions: []string{"posts:read", "posts:write"}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := svc.AssignRole(ctx, "tnt_test", kai.ID, AssignRoleRequest{RoleID: editor.ID}); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	login := LoginRequest{Email: "kai@example.com", Password: "password123"}
+	resp, err := svc.Login(ctx, "tnt_test", login, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	info, err := svc.IntrospectToken(ctx, resp.Token, "")
+	if err != nil {
+		t.Fatalf("IntrospectToken: %v", err)
+	}
+	if info == nil || !info.Active || info.TokenType != auth.TokenTypeBearer || info.Sub != kai.ID || info.ClientID != "tnt_test" ||
+		info.Username != "kai@example.com" || info.Scope != "posts:read posts:write" || info.Exp == 0 || info.Jti == "" {
+		t.Fatalf("unexpected access token introspection: %+v", info)
+	}
+	// 提示只影响查找顺序
+	for _, hint := range []string{"", auth.TokenTypeRefresh} {
+		info, err := svc.IntrospectToken(ctx, resp.RefreshToken, hint)
+		if err != nil || info == nil || info.TokenType != auth.TokenTypeRefresh || info.Sub != kai.ID || info.Scope != "" {
+			t.Fatalf("hint %q: unexpected refresh token introspection: %+v, %v", hint, info, err)
+		}
+	}
+	if info, err := svc.IntrospectToken(ctx, "not-a-token", ""); info != nil || err != nil {
+		t.Fatalf("expected unknown token to be inactive, got %+v, %v", info, err)
+	}
+
+	// 轮换后旧 refresh token 失效
+	refreshed, err := svc.RefreshTokens(ctx, "tnt_test", resp.RefreshToken, "", "")
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if info, _ := svc.IntrospectToken(ctx, resp.RefreshToken, ""); info != nil {
+		t.Fatalf("expected rotated refresh token to be inactive, got %+v", info)
+	}
+
+	// 退出登录后访问令牌和 refresh token 都失效
+	if err := svc.Logout(ctx, "tnt_test", kai.ID, accessTokenClaims(t, svc, refreshed.Token)); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	for _, token := range []string{refreshed.Token, refreshed.RefreshToken} {
+		if info, _ := svc.IntrospectToken(ctx, token, ""); info != nil {
+			t.Fatalf("expected logged-out token to be inactive, got %+v", info)
+		}
+	}
+
+	// 停用用户后其令牌失效
+	resp, err = svc.Login(ctx, "tnt_test", login, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	disabled := StatusDisabled
+	if _, err := svc.UpdateUser(ctx, "tnt_test", kai.ID, UpdateUserRequest{Status: &disabled}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if info, _ := svc.IntrospectToken(ctx, resp.Token, ""); info != nil {
+		t.Fatalf("expected disabled user's token to be inactive, got %+v", info)
+	}
+}
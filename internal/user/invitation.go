@@ -0,0 +1,376 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/mailer"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+)
+
+// invitationTTL 邀请的有效期，重新发送时重新计算
+const invitationTTL = 7 * 24 * time.Hour
+
+// 邀请状态，由接受、撤销和过期时间推导
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+var (
+	// ErrInvitationNotFound 邀请不存在、已接受、已撤销或已过期
+	ErrInvitationNotFound = errors.New("invitation not found or expired")
+	// ErrInvitationEmailMismatch 接受邀请的用户邮箱与受邀邮箱不一致
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email address")
+	// ErrInvitationUserExists 受邀邮箱已注册，需登录后接受邀请
+	ErrInvitationUserExists = errors.New("a user with the invited email already exists, log in to accept the invitation")
+)
+
+// CreateInvitationRequest 邀请邮箱加入租户。指定 organization_id 时接受后加入该组织，role_ids 为组织角色；
+// 否则 role_ids 为接受后分配的租户角色。inviter_user_id 为发出邀请的用户，会出现在邀请邮件中
+type CreateInvitationRequest struct {
+	Email          string   `json:"email" binding:"required,email"`
+	OrganizationID string   `json:"organization_id"`
+	RoleIDs        []string `json:"role_ids"`
+	InviterUserID  string   `json:"inviter_user_id"`
+}
+
+// ListInvitationsRequest 邀请列表过滤条件，为空时不过滤
+type ListInvitationsRequest struct {
+	OrganizationID string `form:"organization_id"`
+	Status         string `form:"status" binding:"omitempty,oneof=pending accepted revoked expired"`
+}
+
+// AcceptInvitationRequest 已登录用户接受邀请，token 为邀请邮件中的令牌
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// InvitationSignupRequest 受邀者凭邀请令牌注册，邮箱取自邀请
+type InvitationSignupRequest struct {
+	Token    string                 `json:"token" binding:"required"`
+	Password string                 `json:"password" binding:"required"`
+	Profile  map[string]interface{} `json:"profile"`
+}
+
+// InvitationResponse 邀请信息，不包含令牌
+type InvitationResponse struct {
+	ID             string   `json:"id"`
+	OrganizationID string   `json:"organization_id,omitempty"`
+	Email          string   `json:"email"`
+	RoleIDs        []string `json:"role_ids"`
+	InviterUserID  string   `json:"inviter_user_id,omitempty"`
+	Status         string   `json:"status"`
+	CreatedAt      string   `json:"created_at"`
+	ExpiresAt      string   `json:"expires_at"`
+	LastSentAt     string   `json:"last_sent_at"`
+	AcceptedAt     string   `json:"accepted_at,omitempty"`
+	AcceptedBy     string   `json:"accepted_by,omitempty"`
+	RevokedAt      string   `json:"revoked_at,omitempty"`
+}
+
+// invitationStatus 推导邀请状态
+func invitationStatus(inv database.Invitation) string {
+	switch {
+	case inv.AcceptedAt.Valid:
+		return InvitationAccepted
+	case inv.RevokedAt.Valid:
+		return InvitationRevoked
+	case !inv.ExpiresAt.After(time.Now()):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
+
+func toInvitationResponse(inv database.Invitation) *InvitationResponse {
+	return &InvitationResponse{
+		ID:             inv.ID,
+		OrganizationID: inv.OrganizationID.String,
+		Email:          inv.Email,
+		RoleIDs:        inv.RoleIds,
+		InviterUserID:  inv.InviterUserID.String,
+		Status:         invitationStatus(inv),
+		CreatedAt:      inv.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ExpiresAt:      inv.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		LastSentAt:     inv.LastSentAt.Format("2006-01-02T15:04:05Z07:00"),
+		AcceptedAt:     formatNullTime(inv.AcceptedAt),
+		AcceptedBy:     inv.AcceptedBy.String,
+		RevokedAt:      formatNullTime(inv.RevokedAt),
+	}
+}
+
+// checkInvitationOrganization organizationID 非空时确认组织属于该租户
+func (s *Service) checkInvitationOrganization(ctx context.Context, tenantID, organizationID string) error {
+	if organizationID == "" {
+		return nil
+	}
+	_, err := s.tenantOrganization(ctx, tenantID, organizationID)
+	return err
+}
+
+// CreateInvitation 邀请邮箱加入租户或组织并发送邀请邮件。受邀者凭令牌注册，已注册的用户登录后凭令牌接受邀请
+func (s *Service) CreateInvitation(ctx context.Context, tenantID string, req CreateInvitationRequest) (*InvitationResponse, error) {
+	if err := s.checkInvitationOrganization(ctx, tenantID, req.OrganizationID); err != nil {
+		return nil, err
+	}
+	for _, roleID := range req.RoleIDs {
+		if _, err := s.tenantRole(ctx, tenantID, roleID); err != nil {
+			return nil, err
+		}
+	}
+	if req.InviterUserID != "" {
+		if _, err := s.currentUser(ctx, tenantID, req.InviterUserID); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := generateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	roleIDs := req.RoleIDs
+	if roleIDs == nil {
+		roleIDs = []string{}
+	}
+	inv, err := s.db.CreateInvitation(ctx, database.CreateInvitationParams{
+		ID:             generateID("inv"),
+		TenantID:       tenantID,
+		OrganizationID: sql.NullString{String: req.OrganizationID, Valid: req.OrganizationID != ""},
+		Email:          req.Email,
+		RoleIds:        roleIDs,
+		InviterUserID:  sql.NullString{String: req.InviterUserID, Valid: req.InviterUserID != ""},
+		TokenHash:      hashToken(token),
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+	if err := s.sendInvitationEmail(ctx, inv, token); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Invitation created", "invitation_id", inv.ID, "organization_id", req.OrganizationID, "tenant_id", tenantID)
+	return toInvitationResponse(inv), nil
+}
+
+// sendInvitationEmail 发送邀请邮件，邮件中注明邀请加入的组织（无组织时为租户）和邀请人
+func (s *Service) sendInvitationEmail(ctx context.Context, inv database.Invitation, token string) error {
+	t, err := s.db.GetTenantByID(ctx, inv.TenantID)
+	if err != nil {
+		return fmt.Errorf("failed to get tenant: %w", err)
+	}
+	settings, err := tenant.ParseSettings(t.Settings)
+	if err != nil {
+		return err
+	}
+	target := t.Name
+	if inv.OrganizationID.Valid {
+		org, err := s.tenantOrganization(ctx, inv.TenantID, inv.OrganizationID.String)
+		if err != nil {
+			return err
+		}
+		target = org.Name
+	}
+	subject := "You have been invited to join " + target
+	if inv.InviterUserID.Valid {
+		if inviter, err := s.currentUser(ctx, inv.TenantID, inv.InviterUserID.String); err == nil {
+			subject = inviter.Email + " has invited you to join " + target
+		}
+	}
+
+	body := subject + ". Accept the invitation with the following token:\n\n" + token + "\n"
+	if link, ok := tokenLink(settings.InvitationURL, token); ok {
+		body = subject + ". Accept the invitation by opening the following link:\n\n" + link + "\n"
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      inv.Email,
+		Subject: subject,
+		Body:    body,
+	})
+}
+
+// ListInvitations 列出租户的邀请，按创建时间倒序
+func (s *Service) ListInvitations(ctx context.Context, tenantID string, req ListInvitationsRequest) ([]*InvitationResponse, error) {
+	if err := s.checkInvitationOrganization(ctx, tenantID, req.OrganizationID); err != nil {
+		return nil, err
+	}
+	invs, err := s.db.ListInvitations(ctx, database.ListInvitationsParams{
+		TenantID:       tenantID,
+		OrganizationID: sql.NullString{String: req.OrganizationID, Valid: req.OrganizationID != ""},
+		Status:         sql.NullString{String: req.Status, Valid: req.Status != ""},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	resp := make([]*InvitationResponse, 0, len(invs))
+	for _, inv := range invs {
+		resp = append(resp, toInvitationResponse(inv))
+	}
+	return resp, nil
+}
+
+// ResendInvitation 重新发送尚未接受或撤销的邀请（含已过期的），换发新令牌并重新计算有效期，旧令牌失效。
+// organizationID 非空时邀请必须属于该组织
+func (s *Service) ResendInvitation(ctx context.Context, tenantID, organizationID, invitationID string) (*InvitationResponse, error) {
+	if err := s.checkInvitationOrganization(ctx, tenantID, organizationID); err != nil {
+		return nil, err
+	}
+	token, err := generateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	inv, err := s.db.ResendInvitation(ctx, database.ResendInvitationParams{
+		TokenHash:      hashToken(token),
+		ExpiresAt:      time.Now().Add(invitationTTL),
+		ID:             invitationID,
+		TenantID:       tenantID,
+		OrganizationID: sql.NullString{String: organizationID, Valid: organizationID != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resend invitation: %w", err)
+	}
+	if err := s.sendInvitationEmail(ctx, inv, token); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Invitation resent", "invitation_id", inv.ID, "tenant_id", tenantID)
+	return toInvitationResponse(inv), nil
+}
+
+// RevokeInvitation 撤销尚未接受的邀请，organizationID 非空时邀请必须属于该组织
+func (s *Service) RevokeInvitation(ctx context.Context, tenantID, organizationID, invitationID string) error {
+	if err := s.checkInvitationOrganization(ctx, tenantID, organizationID); err != nil {
+		return err
+	}
+	n, err := s.db.RevokeInvitation(ctx, database.RevokeInvitationParams{
+		ID:             invitationID,
+		TenantID:       tenantID,
+		OrganizationID: sql.NullString{String: organizationID, Valid: organizationID != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	if n == 0 {
+		return ErrInvitationNotFound
+	}
+	slog.Info("Invitation revoked", "invitation_id", invitationID, "tenant_id", tenantID)
+	return nil
+}
+
+// pendingInvitation 按令牌获取租户内待接受的邀请
+func (s *Service) pendingInvitation(ctx context.Context, tenantID, token string) (database.Invitation, error) {
+	inv, err := s.db.GetPendingInvitationByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Invitation{}, ErrInvitationNotFound
+	}
+	if err != nil {
+		return database.Invitation{}, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	if inv.TenantID != tenantID {
+		return database.Invitation{}, ErrInvitationNotFound
+	}
+	return inv, nil
+}
+
+// AcceptInvitation 当前用户接受邀请，用户邮箱必须与受邀邮箱一致（不区分大小写）
+func (s *Service) AcceptInvitation(ctx context.Context, tenantID, userID string, req AcceptInvitationRequest) (*InvitationResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	inv, err := s.pendingInvitation(ctx, tenantID, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(inv.Email, user.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+	return s.acceptInvitation(ctx, user, inv)
+}
+
+// SignupWithInvitation 受邀者凭邀请令牌以受邀邮箱注册并接受邀请，不受租户注册方式限制。
+// 邀请令牌经邮件送达，注册的用户邮箱视为已验证。邮箱已注册时返回 ErrInvitationUserExists
+func (s *Service) SignupWithInvitation(ctx context.Context, tenantID string, req InvitationSignupRequest) (*RegisterResponse, error) {
+	inv, err := s.pendingInvitation(ctx, tenantID, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{TenantID: tenantID, Email: inv.Email}); err == nil {
+		return nil, ErrInvitationUserExists
+	}
+
+	user, err := s.createUser(ctx, tenantID, RegisterRequest{Email: inv.Email, Password: req.Password, Profile: req.Profile}, false)
+	if err != nil {
+		return nil, err
+	}
+	user, err = s.db.MarkUserEmailVerified(ctx, database.MarkUserEmailVerifiedParams{ID: user.ID, TenantID: tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	if _, err := s.acceptInvitation(ctx, user, inv); err != nil {
+		return nil, err
+	}
+	return toUserResponse(user), nil
+}
+
+// acceptInvitation 将邀请标记为已被 user 接受并授予邀请中的组织成员身份和角色。
+// 已是组织成员时保留现有组织角色并加上邀请中的角色；邀请发出后被删除的角色忽略
+func (s *Service) acceptInvitation(ctx context.Context, user database.User, inv database.Invitation) (*InvitationResponse, error) {
+	if err := s.checkInvitationOrganization(ctx, user.TenantID, inv.OrganizationID.String); err != nil {
+		return nil, err
+	}
+
+	// 条件更新保证并发请求中只有一个能接受邀请
+	accepted, err := s.db.MarkInvitationAccepted(ctx, database.MarkInvitationAcceptedParams{
+		ID:         inv.ID,
+		AcceptedBy: sql.NullString{String: user.ID, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if accepted == 0 {
+		return nil, ErrInvitationNotFound
+	}
+
+	var roleIDs []string
+	for _, roleID := range inv.RoleIds {
+		if _, err := s.tenantRole(ctx, user.TenantID, roleID); err == nil {
+			roleIDs = append(roleIDs, roleID)
+		} else if !errors.Is(err, ErrRoleNotFound) {
+			return nil, err
+		}
+	}
+	if inv.OrganizationID.Valid {
+		if err := s.db.AddOrganizationMember(ctx, database.AddOrganizationMemberParams{OrganizationID: inv.OrganizationID.String, UserID: user.ID}); err != nil {
+			return nil, fmt.Errorf("failed to add organization member: %w", err)
+		}
+		if err := s.addMemberRoles(ctx, inv.OrganizationID.String, user.ID, roleIDs); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, roleID := range roleIDs {
+			if err := s.db.AssignUserRole(ctx, database.AssignUserRoleParams{UserID: user.ID, RoleID: roleID}); err != nil {
+				return nil, fmt.Errorf("failed to assign role: %w", err)
+			}
+		}
+	}
+
+	inv, err = s.db.GetInvitation(ctx, database.GetInvitationParams{ID: inv.ID, TenantID: user.TenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	slog.Info("Invitation accepted", "invitation_id", inv.ID, "organization_id", inv.OrganizationID.String, "user_id", user.ID, "tenant_id", user.TenantID)
+	return toInvitationResponse(inv), nil
+}
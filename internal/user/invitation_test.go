@@ -0,0 +1,104 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSignupMode(t *testing.T) {
+	ctx := context.Background()
+
+	svc, _, _ := newTestService(t, `{"signup_mode":"invite_only"}`)
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"}); !errors.Is(err, ErrSignupNotAllowed) {
+		t.Fatalf("expected ErrSignupNotAllowed, got %v", err)
+	}
+	// 内部服务创建用户不受注册方式限制
+	if _, err := svc.CreateUser(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	svc, _, _ = newTestService(t, `{"signup_mode":"allowed_domains","allowed_email_domains":["example.com"]}`)
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@Example.COM", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	for _, email := range []string{"kai@other.com", "kai@sub.example.com"} {
+		if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: email, Password: "password123"}); !errors.Is(err, ErrSignupNotAllowed) {
+			t.Fatalf("%s: expected ErrSignupNotAllowed, got %v", email, err)
+		}
+	}
+}
+
+func TestInvitationSignup(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{"signup_mode":"invite_only","invitation_url":"https://app.example.com/join"}`)
+
+	kai, err := svc.CreateUser(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	editor, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "editor", Permissions: []string{"posts:write"}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+
+	inv, err := svc.CreateInvitation(ctx, "tnt_test", CreateInvitationRequest{Email: "bo@example.com", RoleIDs: []string{editor.ID}, InviterUserID: kai.ID})
+	if err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	if inv.Status != InvitationPending || inv.OrganizationID != "" || inv.InviterUserID != kai.ID {
+		t.Fatalf("unexpected invitation: %+v", inv)
+	}
+	if msg := mail.messages[len(mail.messages)-1]; msg.Subject != "kai@example.com has invited you to join Test Tenant" {
+		t.Fatalf("unexpected invitation subject: %q", msg.Subject)
+	}
+	oldToken := invitationToken(t, mail)
+
+	// 重新发送后旧令牌失效
+	if _, err := svc.ResendInvitation(ctx, "tnt_test", "", inv.ID); err != nil {
+		t.Fatalf("ResendInvitation: %v", err)
+	}
+	token := invitationToken(t, mail)
+	if token == oldToken {
+		t.Fatal("expected a new invitation token after resend")
+	}
+	if _, err := svc.SignupWithInvitation(ctx, "tnt_test", InvitationSignupRequest{Token: oldToken, Password: "password123"}); !errors.Is(err, ErrInvitationNotFound) {
+		t.Fatalf("expected ErrInvitationNotFound, got %v", err)
+	}
+
+	// 仅限邀请的租户中受邀者凭令牌注册，邮箱视为已验证并获得租户角色
+	bo, err := svc.SignupWithInvitation(ctx, "tnt_test", InvitationSignupRequest{Token: token, Password: "password123"})
+	if err != nil {
+		t.Fatalf("SignupWithInvitation: %v", err)
+	}
+	if bo.Email != "bo@example.com" || !bo.EmailVerified {
+		t.Fatalf("unexpected user: %+v", bo)
+	}
+	authz, err := svc.GetUserAuthorization(ctx, "tnt_test", bo.ID, "")
+	if err != nil || !slices.Equal(authz.Roles, []string{"editor"}) {
+		t.Fatalf("unexpected authorization: %+v, %v", authz, err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "bo@example.com", Password: "password123"}, "", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	accepted, _ := svc.ListInvitations(ctx, "tnt_test", ListInvitationsRequest{Status: InvitationAccepted})
+	if len(accepted) != 1 || accepted[0].AcceptedBy != bo.ID {
+		t.Fatalf("expected one accepted invitation, got %+v", accepted)
+	}
+	if _, err := svc.ResendInvitation(ctx, "tnt_test", "", inv.ID); !errors.Is(err, ErrInvitationNotFound) {
+		t.Fatalf("expected ErrInvitationNotFound, got %v", err)
+	}
+
+	// 受邀邮箱已注册时需登录后接受
+	if _, err := svc.CreateInvitation(ctx, "tnt_test", CreateInvitationRequest{Email: "kai@example.com"}); err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	if _, err := svc.SignupWithInvitation(ctx, "tnt_test", InvitationSignupRequest{Token: invitationToken(t, mail), Password: "password123"}); !errors.Is(err, ErrInvitationUserExists) {
+		t.Fatalf("expected ErrInvitationUserExists, got %v", err)
+	}
+	if body := mail.messages[len(mail.messages)-1].Body; !strings.HasPrefix(body, "You have been invited to join Test Tenant.") {
+		t.Fatalf("unexpected invitation body: %q", body)
+	}
+}
@@ -0,0 +1,232 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"yuyu-test/internal/store/database"
+
+	"github.com/sqlc-dev/pqtype"
+)
+
+// 用户列表排序方式，前缀 "-" 表示降序
+const (
+	SortCreatedAtDesc = "-created_at"
+	SortCreatedAtAsc  = "created_at"
+	SortEmailAsc      = "email"
+	SortEmailDesc     = "-email"
+)
+
+const (
+	// DefaultUserPageSize 未指定 limit 时每页返回的用户数
+	DefaultUserPageSize = 50
+	// MaxUserPageSize 每页最多返回的用户数
+	MaxUserPageSize = 200
+)
+
+var (
+	// ErrInvalidCursor 游标无法解析，或与当前排序方式不一致
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSearchQuery 搜索词为空或不含可搜索的字符
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+)
+
+// ListUsersRequest 用户列表查询条件，零值字段表示不过滤
+type ListUsersRequest struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=200"`
+	Cursor string `form:"cursor"`
+	// Sort 排序方式，默认按创建时间降序
+	Sort        string `form:"sort" binding:"omitempty,oneof=created_at -created_at email -email"`
+	EmailPrefix string `form:"email_prefix"`
+	// CreatedAfter 创建时间下界（含），CreatedBefore 上界（不含），RFC 3339 格式
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Status        string     `form:"status" binding:"omitempty,oneof=active disabled locked"`
+	// Profile 要求 profile 中对应属性等于给定的字符串值，对应查询参数 profile.<key>=<value>
+	Profile map[string]string `form:"-"`
+	// OrganizationID 只列出该组织的成员
+	OrganizationID string `form:"organization_id"`
+}
+
+// ListUsersResponse 一页用户，next_cursor 为空表示没有更多数据
+type ListUsersResponse struct {
+	Users      []*RegisterResponse `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// userCursor 上一页最后一个用户的排序键，编码后作为不透明的游标返回
+type userCursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"t,omitempty"`
+	Email     string    `json:"e,omitempty"`
+	ID        string    `json:"i,omitempty"`
+}
+
+func encodeUserCursor(sort string, last database.User) string {
+	raw, _ := json.Marshal(userCursor{Sort: sort, CreatedAt: last.CreatedAt, Email: last.Email, ID: last.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeUserCursor(sort, cursor string) (*userCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c userCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// escapeLikePattern 转义 LIKE 通配符，使前缀按字面匹配
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ListUsers 按条件分页列出租户下未删除的用户
+func (s *Service) ListUsers(ctx context.Context, tenantID string, req ListUsersRequest) (*ListUsersResponse, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultUserPageSize
+	}
+	limit = min(limit, MaxUserPageSize)
+	sort := req.Sort
+	if sort == "" {
+		sort = SortCreatedAtDesc
+	}
+
+	var cursor *userCursor
+	if req.Cursor != "" {
+		var err error
+		if cursor, err = decodeUserCursor(sort, req.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	params := database.ListUsersByCreatedAtDescParams{
+		TenantID: tenantID,
+		Lim:      int32(limit + 1), // 多取一行判断是否还有下一页
+	}
+	if req.EmailPrefix != "" {
+		params.EmailPrefix = sql.NullString{String: escapeLikePattern(req.EmailPrefix), Valid: true}
+	}
+	if req.CreatedAfter != nil {
+		params.CreatedAfter = sql.NullTime{Time: *req.CreatedAfter, Valid: true}
+	}
+	if req.CreatedBefore != nil {
+		params.CreatedBefore = sql.NullTime{Time: *req.CreatedBefore, Valid: true}
+	}
+	if req.Status != "" {
+		params.Status = sql.NullString{String: req.Status, Valid: true}
+	}
+	if len(req.Profile) > 0 {
+		raw, err := json.Marshal(req.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal profile filter: %w", err)
+		}
+		params.Profile = pqtype.NullRawMessage{RawMessage: raw, Valid: true}
+	}
+	if req.OrganizationID != "" {
+		params.OrganizationID = sql.NullString{String: req.OrganizationID, Valid: true}
+	}
+
+	emailParams := database.ListUsersByEmailAscParams{
+		TenantID:       params.TenantID,
+		EmailPrefix:    params.EmailPrefix,
+		CreatedAfter:   params.CreatedAfter,
+		CreatedBefore:  params.CreatedBefore,
+		Status:         params.Status,
+		Profile:        params.Profile,
+		OrganizationID: params.OrganizationID,
+		Lim:            params.Lim,
+	}
+	if cursor != nil {
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = cursor.ID
+		emailParams.CursorEmail = sql.NullString{String: cursor.Email, Valid: true}
+	}
+
+	var users []database.User
+	var err error
+	switch sort {
+	case SortCreatedAtAsc:
+		users, err = s.db.ListUsersByCreatedAtAsc(ctx, database.ListUsersByCreatedAtAscParams(params))
+	case SortEmailAsc:
+		users, err = s.db.ListUsersByEmailAsc(ctx, emailParams)
+	case SortEmailDesc:
+		users, err = s.db.ListUsersByEmailDesc(ctx, database.ListUsersByEmailDescParams(emailParams))
+	default:
+		users, err = s.db.ListUsersByCreatedAtDesc(ctx, params)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	resp := &ListUsersResponse{Users: make([]*RegisterResponse, 0, min(len(users), limit))}
+	if len(users) > limit {
+		users = users[:limit]
+		resp.NextCursor = encodeUserCursor(sort, users[len(users)-1])
+	}
+	for _, user := range users {
+		resp.Users = append(resp.Users, toUserResponse(user))
+	}
+	return resp, nil
+}
+
+// searchTSQuery 将搜索词转换为 to_tsquery 表达式：每个词按前缀匹配，多个词需同时命中。
+// 词中只保留字母、数字和 @ . _ -，避免注入 tsquery 运算符
+func searchTSQuery(query string) string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		term := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("@._-", r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, field)
+		if term != "" {
+			terms = append(terms, "'"+term+"':*")
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// SearchUsers 全文搜索租户下的用户，匹配邮箱和 profile 中的字符串值，按相关度排序。
+// organizationID 非空时只搜索该组织的成员
+func (s *Service) SearchUsers(ctx context.Context, tenantID, query, organizationID string, limit int) ([]*RegisterResponse, error) {
+	tsQuery := searchTSQuery(query)
+	if tsQuery == "" {
+		return nil, ErrInvalidSearchQuery
+	}
+	if limit <= 0 {
+		limit = DefaultUserPageSize
+	}
+	limit = min(limit, MaxUserPageSize)
+
+	params := database.SearchUsersParams{
+		TenantID: tenantID,
+		Query:    tsQuery,
+		Lim:      int32(limit),
+	}
+	if organizationID != "" {
+		params.OrganizationID = sql.NullString{String: organizationID, Valid: true}
+	}
+	users, err := s.db.SearchUsers(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	responses := make([]*RegisterResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, toUserResponse(user))
+	}
+	return responses, nil
+}
@@ -0,0 +1,164 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListUsersPagination(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	emails := []string{"a_1@example.com", "ax1@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	ids := make([]string, len(emails))
+	for i, email := range emails {
+		registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: email, Password: "password123"})
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		ids[i] = registered.ID
+		u := store.users[registered.ID]
+		u.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		store.users[registered.ID] = u
+	}
+
+	// 默认按创建时间降序，逐页翻完且不重复
+	var seen []string
+	req := ListUsersRequest{Limit: 2}
+	for page := 0; ; page++ {
+		resp, err := svc.ListUsers(ctx, "tnt_test", req)
+		if err != nil {
+			t.Fatalf("ListUsers page %d: %v", page, err)
+		}
+		for _, u := range resp.Users {
+			seen = append(seen, u.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		req.Cursor = resp.NextCursor
+	}
+	want := []string{ids[4], ids[3], ids[2], ids[1], ids[0]}
+	if len(seen) != len(want) {
+		t.Fatalf("expected %d users, got %v", len(want), seen)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("unexpected order: got %v, want %v", seen, want)
+		}
+	}
+
+	// 游标与排序方式绑定
+	first, err := svc.ListUsers(ctx, "tnt_test", ListUsersRequest{Limit: 2, Sort: SortEmailDesc})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if first.Users[0].Email != "d@example.com" || first.NextCursor == "" {
+		t.Fatalf("unexpected first page sorted by -email: %+v", first)
+	}
+	if _, err := svc.ListUsers(ctx, "tnt_test", ListUsersRequest{Cursor: first.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor for a cursor from another sort, got %v", err)
+	}
+	if _, err := svc.ListUsers(ctx, "tnt_test", ListUsersRequest{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	next, err := svc.ListUsers(ctx, "tnt_test", ListUsersRequest{Limit: 2, Sort: SortEmailDesc, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if next.Users[0].Email != "b@example.com" {
+		t.Fatalf("expected second page to continue after the cursor, got %+v", next.Users)
+	}
+
+	// 邮箱前缀中的 _ 按字面匹配
+	resp, err := svc.ListUsers(ctx, "tnt_test", ListUsersRequest{EmailPrefix: "a_"})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].ID != ids[0] {
+		t.Fatalf("expected only a_1@example.com, got %+v", resp.Users)
+	}
+}
+
+func TestListUsersFilters(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	profiles := []map[string]interface{}{{"role": "admin"}, {"role": "user"}, {"role": "admin"}}
+	ids := make([]string, len(profiles))
+	for i, profile := range profiles {
+		registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: string(rune('a'+i)) + "@example.com", Password: "password123", Profile: profile})
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		ids[i] = registered.ID
+		u := store.users[registered.ID]
+		u.CreatedAt = base.AddDate(0, 0, i)
+		store.users[registered.ID] = u
+	}
+	disabled := StatusDisabled
+	if _, err := svc.UpdateUser(ctx, "tnt_test", ids[2], UpdateUserRequest{Status: &disabled}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	after, before := base.AddDate(0, 0, 1), base.AddDate(0, 0, 3)
+	cases := []struct {
+		name string
+		req  ListUsersRequest
+		want []string
+	}{
+		{"profile", ListUsersRequest{Sort: SortCreatedAtAsc, Profile: map[string]string{"role": "admin"}}, []string{ids[0], ids[2]}},
+		{"status", ListUsersRequest{Status: StatusDisabled}, []string{ids[2]}},
+		{"created range", ListUsersRequest{Sort: SortCreatedAtAsc, CreatedAfter: &after, CreatedBefore: &before}, []string{ids[1], ids[2]}},
+		{"combined", ListUsersRequest{Status: StatusActive, Profile: map[string]string{"role": "admin"}}, []string{ids[0]}},
+	}
+	for _, tc := range cases {
+		resp, err := svc.ListUsers(ctx, "tnt_test", tc.req)
+		if err != nil {
+			t.Fatalf("%s: ListUsers: %v", tc.name, err)
+		}
+		var got []string
+		for _, u := range resp.Users {
+			got = append(got, u.ID)
+		}
+		if len(got) != len(tc.want) || (len(got) > 0 && (got[0] != tc.want[0] || got[len(got)-1] != tc.want[len(tc.want)-1])) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// 软删除的用户不出现在列表中
+	if err := svc.DeleteUser(ctx, "tnt_test", ids[0], false); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	resp, err := svc.ListUsers(ctx, "tnt_test", ListUsersRequest{})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(resp.Users) != 2 {
+		t.Fatalf("expected deleted user to be excluded, got %+v", resp.Users)
+	}
+}
+
+func TestSearchTSQuery(t *testing.T) {
+	cases := map[string]string{
+		"alice":                  "'alice':*",
+		"  Alice   Engineering ": "'alice':* & 'engineering':*",
+		"bob@example.com":        "'bob@example.com':*",
+		"a&b | !c ' ) :* \\":     "'ab':* & 'c':*",
+		"张三":                     "'张三':*",
+		"& | !":                  "",
+	}
+	for query, want := range cases {
+		if got := searchTSQuery(query); got != want {
+			t.Errorf("searchTSQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+	svc, _, _ := newTestService(t, `{}`)
+	if _, err := svc.SearchUsers(context.Background(), "tnt_test", "!!", "", 10); !errors.Is(err, ErrInvalidSearchQuery) {
+		t.Fatalf("expected ErrInvalidSearchQuery, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package user
+
+import (
+	"context"
+	"log/slog"
+
+	"yuyu-test/internal/lockout"
+)
+
+// UnlockUser 管理员解除账号的登录锁定，被管理员锁定的账号同时恢复为 active
+func (s *Service) UnlockUser(ctx context.Context, tenantID, userID string) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.guard.Unlock(ctx, lockout.AccountKey(tenantID, user.Email)); err != nil {
+		return err
+	}
+	if user.Status == StatusLocked {
+		if _, err := s.setStatus(ctx, user, StatusActive); err != nil {
+			return err
+		}
+	}
+	slog.Info("User login unlocked", "user_id", userID, "tenant_id", tenantID)
+	return nil
+}
@@ -0,0 +1,53 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/lockout"
+)
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kate@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// 存在和不存在的账号返回相同的错误，达到阈值后同样被锁定
+	for _, email := range []string{"kate@example.com", "nobody@example.com"} {
+		wrong := LoginRequest{Email: email, Password: "wrong-password"}
+		for i := 0; i < 3; i++ {
+			if _, err := svc.Login(ctx, "tnt_test", wrong, "10.0.0.1", ""); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("%s attempt %d: expected ErrInvalidCredentials, got %v", email, i+1, err)
+			}
+		}
+		_, err := svc.Login(ctx, "tnt_test", wrong, "10.0.0.1", "")
+		var locked *lockout.LockedError
+		if !errors.As(err, &locked) || locked.RetryAfter <= 0 {
+			t.Fatalf("%s: expected lockout after threshold, got %v", email, err)
+		}
+	}
+	if len(store.events) != 2 || store.events[0].EventType != lockout.SecurityEventLocked {
+		t.Fatalf("expected a lockout security event per account, got %+v", store.events)
+	}
+
+	// 锁定期间正确的密码也被拒绝
+	login := LoginRequest{Email: "kate@example.com", Password: "password123"}
+	if _, err := svc.Login(ctx, "tnt_test", login, "10.0.0.1", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("expected locked account to reject the correct password, got %v", err)
+	}
+
+	if err := svc.UnlockUser(ctx, "tnt_test", registered.ID); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "10.0.0.1", ""); err != nil {
+		t.Fatalf("expected login after unlock, got %v", err)
+	}
+	if err := svc.UnlockUser(ctx, "tnt_test", "usr_missing"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
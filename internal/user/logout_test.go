@@ -0,0 +1,70 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/revocation"
+)
+
+func TestLogoutRevokesAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+	revocations := svc.revoker.(*revocation.Store)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "jack@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := LoginRequest{Email: "jack@example.com", Password: "password123"}
+	parse := func(token string) *auth.Claims {
+		claims := &auth.Claims{}
+		if err := svc.signer.Parse(token, claims); err != nil {
+			t.Fatalf("parse access token: %v", err)
+		}
+		return claims
+	}
+
+	laptop, err := svc.Login(ctx, "tnt_test", login, "", "laptop")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	phone, err := svc.Login(ctx, "tnt_test", login, "", "phone")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	laptopClaims, phoneClaims := parse(laptop.Token), parse(phone.Token)
+	if laptopClaims.ID == "" || laptopClaims.ID == phoneClaims.ID {
+		t.Fatal("expected a unique jti on every access token")
+	}
+
+	if err := svc.Logout(ctx, "tnt_test", registered.ID, laptopClaims); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if !revocations.IsRevoked(laptopClaims) {
+		t.Fatal("expected the logged-out access token to be revoked")
+	}
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", laptop.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected the logged-out session's refresh token to be rejected, got %v", err)
+	}
+	if revocations.IsRevoked(phoneClaims) {
+		t.Fatal("other sessions should stay valid")
+	}
+
+	// 修改密码注销所有会话，已签发的访问令牌随之失效
+	if err := svc.ChangePassword(ctx, "tnt_test", registered.ID, ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword456"}); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if !revocations.IsRevoked(phoneClaims) {
+		t.Fatal("expected access tokens to be revoked after password change")
+	}
+	relogin, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "jack@example.com", Password: "newpassword456"}, "", "phone")
+	if err != nil {
+		t.Fatalf("Login after password change: %v", err)
+	}
+	if revocations.IsRevoked(parse(relogin.Token)) {
+		t.Fatal("tokens issued after the password change must stay valid")
+	}
+}
@@ -0,0 +1,228 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"yuyu-test/internal/store/database"
+
+	"github.com/sqlc-dev/pqtype"
+)
+
+// 用户状态
+const (
+	// StatusActive 正常
+	StatusActive = "active"
+	// StatusDisabled 被管理员停用
+	StatusDisabled = "disabled"
+	// StatusLocked 被管理员锁定，与登录失败导致的临时锁定无关
+	StatusLocked = "locked"
+)
+
+var (
+	// ErrUserDisabled 账号已停用
+	ErrUserDisabled = errors.New("user account is disabled")
+	// ErrUserLocked 账号已被管理员锁定
+	ErrUserLocked = errors.New("user account is locked")
+	// ErrInvalidUserStatus 不支持的用户状态
+	ErrInvalidUserStatus = errors.New("invalid user status")
+	// ErrEmailTaken 邮箱已被租户下的其他用户使用
+	ErrEmailTaken = errors.New("email already in use")
+)
+
+// UpdateUserRequest 部分更新用户，未提供的字段保持不变。
+// profile 整体替换；修改密码会按租户密码策略校验并注销该用户的所有会话
+type UpdateUserRequest struct {
+	Email    *string                `json:"email" binding:"omitempty,email"`
+	Profile  map[string]interface{} `json:"profile"`
+	Password *string                `json:"password"`
+	Status   *string                `json:"status" binding:"omitempty,oneof=active disabled locked"`
+}
+
+// checkUserStatus 非 active 的用户不能登录或刷新令牌
+func checkUserStatus(user database.User) error {
+	switch user.Status {
+	case StatusDisabled:
+		return ErrUserDisabled
+	case StatusLocked:
+		return ErrUserLocked
+	}
+	return nil
+}
+
+// UpdateUser 管理员或内部服务部分更新用户，可以修改只读资料字段
+func (s *Service) UpdateUser(ctx context.Context, tenantID, userID string, req UpdateUserRequest) (*RegisterResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != nil && *req.Status != StatusActive && *req.Status != StatusDisabled && *req.Status != StatusLocked {
+		return nil, ErrInvalidUserStatus
+	}
+
+	// 先完成所有校验，避免部分字段写入后才失败
+	settings, err := s.tenantSettings(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Password != nil {
+		if err := s.validatePassword(ctx, settings.PasswordPolicy, *req.Password, &user); err != nil {
+			return nil, err
+		}
+	}
+	emailChanged := req.Email != nil && *req.Email != user.Email
+	if emailChanged {
+		existing, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
+			TenantID: tenantID,
+			Email:    *req.Email,
+		})
+		if err == nil && existing.ID != "" {
+			return nil, ErrEmailTaken
+		}
+	}
+
+	var profile map[string]interface{}
+	if req.Profile != nil {
+		profile, err = s.validateProfile(ctx, tenantID, toUserResponse(user).Profile, req.Profile, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if emailChanged || profile != nil {
+		params := database.UpdateUserParams{
+			ID:            user.ID,
+			TenantID:      tenantID,
+			Email:         user.Email,
+			Profile:       user.Profile,
+			EmailVerified: user.EmailVerified,
+		}
+		// 新邮箱需要重新验证
+		if emailChanged {
+			params.Email = *req.Email
+			params.EmailVerified = false
+		}
+		if profile != nil {
+			profileBytes, err := json.Marshal(profile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal profile: %w", err)
+			}
+			params.Profile = pqtype.NullRawMessage{RawMessage: profileBytes, Valid: true}
+		}
+		user, err = s.db.UpdateUser(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	if req.Password != nil {
+		user, err = s.setPassword(ctx, user, settings.PasswordPolicy, *req.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Status != nil && *req.Status != user.Status {
+		user, err = s.setStatus(ctx, user, *req.Status)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("User updated", "user_id", user.ID, "tenant_id", tenantID)
+
+	if emailChanged {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			slog.Error("Failed to send verification email", "user_id", user.ID, "error", err)
+		}
+	}
+
+	return toUserResponse(user), nil
+}
+
+// UpdateProfileRequest 用户修改自己的资料，profile 整体替换
+type UpdateProfileRequest struct {
+	Profile map[string]interface{} `json:"profile" binding:"required"`
+}
+
+// UpdateProfile 用户修改自己的资料。省略的只读字段保留原值，修改只读字段会被拒绝
+func (s *Service) UpdateProfile(ctx context.Context, tenantID, userID string, req UpdateProfileRequest) (*RegisterResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.validateProfile(ctx, tenantID, toUserResponse(user).Profile, req.Profile, false)
+	if err != nil {
+		return nil, err
+	}
+	profileBytes, err := json.Marshal(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	user, err = s.db.UpdateUser(ctx, database.UpdateUserParams{
+		ID:            user.ID,
+		TenantID:      tenantID,
+		Email:         user.Email,
+		Profile:       pqtype.NullRawMessage{RawMessage: profileBytes, Valid: true},
+		EmailVerified: user.EmailVerified,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	slog.Info("User profile updated", "user_id", user.ID, "tenant_id", tenantID)
+	return toUserResponse(user), nil
+}
+
+// setStatus 修改用户状态，停用或锁定时注销所有会话并吊销已签发的访问令牌
+func (s *Service) setStatus(ctx context.Context, user database.User, status string) (database.User, error) {
+	updated, err := s.db.SetUserStatus(ctx, database.SetUserStatusParams{
+		ID:       user.ID,
+		TenantID: user.TenantID,
+		Status:   status,
+	})
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to set user status: %w", err)
+	}
+	if status != StatusActive {
+		if err := s.revokeAllSessions(ctx, updated); err != nil {
+			return database.User{}, err
+		}
+	}
+
+	slog.Info("User status changed", "user_id", user.ID, "tenant_id", user.TenantID, "from", user.Status, "to", status)
+
+	return updated, nil
+}
+
+// DeleteUser 删除用户并注销其所有会话。软删除保留数据但用户不再可见，邮箱可被重新注册；
+// 硬删除级联删除用户的全部关联数据
+func (s *Service) DeleteUser(ctx context.Context, tenantID, userID string, hard bool) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		return err
+	}
+
+	params := database.SoftDeleteUserParams{ID: user.ID, TenantID: tenantID}
+	var deleted int64
+	if hard {
+		deleted, err = s.db.DeleteUser(ctx, database.DeleteUserParams(params))
+	} else {
+		deleted, err = s.db.SoftDeleteUser(ctx, params)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if deleted == 0 {
+		return ErrUserNotFound
+	}
+
+	slog.Info("User deleted", "user_id", user.ID, "tenant_id", tenantID, "hard", hard)
+	return nil
+}
@@ -0,0 +1,169 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/revocation"
+)
+
+func TestUpdateUser(t *testing.T) {
+	ctx := context.Background()
+	svc, store, mail := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "liam@example.com", Password: "password123", Profile: map[string]interface{}{"name": "Liam"}})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "mia@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	u := store.users[registered.ID]
+	u.EmailVerified = true
+	store.users[registered.ID] = u
+
+	// 只修改 profile 时邮箱和验证状态保持不变
+	updated, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Profile: map[string]interface{}{"name": "Liam B."}})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.Email != "liam@example.com" || !updated.EmailVerified || updated.Profile["name"] != "Liam B." {
+		t.Fatalf("unexpected user after profile update: %+v", updated)
+	}
+
+	taken := "mia@example.com"
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Email: &taken}); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+
+	// 修改邮箱需重新验证，并向新邮箱发送验证邮件
+	newEmail := "liam.b@example.com"
+	sent := len(mail.messages)
+	updated, err = svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Email: &newEmail})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.Email != newEmail || updated.EmailVerified {
+		t.Fatalf("expected unverified new email, got %+v", updated)
+	}
+	if len(mail.messages) != sent+1 || mail.messages[len(mail.messages)-1].To != newEmail {
+		t.Fatalf("expected a verification email to the new address, got %+v", mail.messages)
+	}
+
+	// 新密码按租户策略校验，不合规时其他字段也不写入
+	short, name := "abc", map[string]interface{}{"name": "unchanged?"}
+	var policyErr *PasswordPolicyError
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Password: &short, Profile: name}); !errors.As(err, &policyErr) {
+		t.Fatalf("expected PasswordPolicyError, got %v", err)
+	}
+	if got, _ := svc.GetUserByID(ctx, registered.ID); got.Profile["name"] != "Liam B." {
+		t.Fatalf("expected profile to stay unchanged, got %+v", got.Profile)
+	}
+
+	password := "newpassword456"
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Password: &password}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: newEmail, Password: password}, "", ""); err != nil {
+		t.Fatalf("expected login with the new password, got %v", err)
+	}
+
+	invalid := "archived"
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Status: &invalid}); !errors.Is(err, ErrInvalidUserStatus) {
+		t.Fatalf("expected ErrInvalidUserStatus, got %v", err)
+	}
+	if _, err := svc.UpdateUser(ctx, "tnt_other", registered.ID, UpdateUserRequest{Profile: name}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound across tenants, got %v", err)
+	}
+}
+
+func TestUserStatusBlocksLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+	revocations := svc.revoker.(*revocation.Store)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "noah@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := LoginRequest{Email: "noah@example.com", Password: "password123"}
+	resp, err := svc.Login(ctx, "tnt_test", login, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims := &auth.Claims{}
+	if err := svc.signer.Parse(resp.Token, claims); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+
+	// 停用后已签发的令牌失效，且不能再登录或刷新
+	disabled := StatusDisabled
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Status: &disabled}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if !revocations.IsRevoked(claims) {
+		t.Fatal("expected access tokens to be revoked when the user is disabled")
+	}
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", resp.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected refresh tokens to be deleted, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("expected ErrUserDisabled, got %v", err)
+	}
+	// 密码错误时不暴露账号状态
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: login.Email, Password: "wrong-password"}, "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	// 管理员锁定的账号通过解锁恢复
+	locked := StatusLocked
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Status: &locked}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); !errors.Is(err, ErrUserLocked) {
+		t.Fatalf("expected ErrUserLocked, got %v", err)
+	}
+	if err := svc.UnlockUser(ctx, "tnt_test", registered.ID); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); err != nil {
+		t.Fatalf("expected login after unlock, got %v", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	for _, hard := range []bool{false, true} {
+		registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "olivia@example.com", Password: "password123"})
+		if err != nil {
+			t.Fatalf("hard=%v: Register: %v", hard, err)
+		}
+		resp, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "olivia@example.com", Password: "password123"}, "", "")
+		if err != nil {
+			t.Fatalf("hard=%v: Login: %v", hard, err)
+		}
+
+		if err := svc.DeleteUser(ctx, "tnt_other", registered.ID, hard); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("hard=%v: expected ErrUserNotFound across tenants, got %v", hard, err)
+		}
+		if err := svc.DeleteUser(ctx, "tnt_test", registered.ID, hard); err != nil {
+			t.Fatalf("hard=%v: DeleteUser: %v", hard, err)
+		}
+		if _, err := svc.RefreshTokens(ctx, "tnt_test", resp.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("hard=%v: expected refresh tokens to be deleted, got %v", hard, err)
+		}
+		if _, err := svc.GetUserByID(ctx, registered.ID); err == nil {
+			t.Fatalf("hard=%v: expected deleted user to be hidden", hard)
+		}
+		if _, kept := store.users[registered.ID]; kept == hard {
+			t.Fatalf("hard=%v: unexpected row retention", hard)
+		}
+		if err := svc.DeleteUser(ctx, "tnt_test", registered.ID, hard); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("hard=%v: expected ErrUserNotFound on second delete, got %v", hard, err)
+		}
+	}
+}
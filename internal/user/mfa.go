@@ -0,0 +1,521 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/lockout"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// purposeMFAChallenge 密码验证通过后的二次验证挑战令牌用途
+	purposeMFAChallenge = "mfa_challenge"
+	// mfaChallengeTTL 挑战令牌有效期
+	mfaChallengeTTL = 5 * time.Minute
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+	// mfaChallengeMaxAttempts 单个挑战允许的验证码错误次数，达到后挑战作废，需重新输入密码
+	mfaChallengeMaxAttempts = 5
+)
+
+// MFAChallengePolicy 挑战维度（lockout.ScopeMFAChallenge）的锁定策略：
+// 错误次数达到上限后在挑战的剩余有效期内拒绝该挑战
+var MFAChallengePolicy = lockout.Policy{Threshold: mfaChallengeMaxAttempts, LockoutDuration: mfaChallengeTTL}
+
+// 认证方式引用（RFC 8176），写入访问令牌的 amr 声明
+const (
+	amrPassword = "pwd"
+	amrOTP      = "otp"
+	amrMFA      = "mfa"
+)
+
+var (
+	// ErrInvalidMFAToken 挑战令牌无效或过期
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+	// ErrInvalidMFACode 验证码或恢复码错误
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+	// ErrMFAAlreadyEnabled 用户已启用TOTP
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	// ErrMFANotEnabled 用户未启用TOTP或绑定尚未开始
+	ErrMFANotEnabled = errors.New("mfa not enabled")
+)
+
+// MFAVerifyRequest 登录第二步：挑战令牌加TOTP验证码或恢复码
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFAEnrollRequest 租户强制MFA时，登录过程中开始绑定TOTP
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// TOTPCodeRequest 需要提供当前TOTP验证码的操作
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TOTPEnrollmentResponse 开始绑定TOTP的响应，secret仅在此返回
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse 恢复码响应，明文仅展示这一次
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAStatusResponse 当前用户的MFA状态
+type MFAStatusResponse struct {
+	TOTPEnabled            bool  `json:"totp_enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// confirmedTOTP 获取用户已确认的TOTP配置
+func (s *Service) confirmedTOTP(ctx context.Context, userID string) (database.UserMfaTotp, bool, error) {
+	totp, err := s.db.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.UserMfaTotp{}, false, nil
+		}
+		return database.UserMfaTotp{}, false, fmt.Errorf("failed to get totp: %w", err)
+	}
+	return totp, totp.ConfirmedAt.Valid, nil
+}
+
+// mfaChallenge 需要二次验证时返回挑战响应，否则返回nil。
+// amr 为已完成的第一因素，完成二次验证后写入访问令牌
+func (s *Service) mfaChallenge(ctx context.Context, user database.User, settings *tenant.Settings, amr []string) (*LoginResponse, error) {
+	_, enabled, err := s.confirmedTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled && !settings.MFARequired {
+		return nil, nil
+	}
+
+	now := time.Now()
+	claims := auth.PurposeClaims{
+		Purpose:  purposeMFAChallenge,
+		TenantID: user.TenantID,
+		AMR:      amr,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			ID:        generateID("mfa"),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	token, err := s.signer.Sign(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign mfa token: %w", err)
+	}
+	// 保存挑战ID，验证成功时原子地消费，挑战令牌只能完成一次登录
+	if err := s.db.CreateUserActionToken(ctx, database.CreateUserActionTokenParams{
+		UserID:    user.ID,
+		TenantID:  user.TenantID,
+		Purpose:   purposeMFAChallenge,
+		TokenHash: hashToken(claims.ID),
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store mfa challenge: %w", err)
+	}
+
+	slog.Info("MFA challenge issued", "user_id", user.ID, "tenant_id", user.TenantID, "enrollment_required", !enabled)
+
+	return &LoginResponse{
+		MFARequired:           true,
+		MFAToken:              token,
+		MFAEnrollmentRequired: !enabled,
+	}, nil
+}
+
+// challengeUser 校验挑战令牌且尚未使用，返回对应用户、已完成的第一因素和挑战ID（jti）
+func (s *Service) challengeUser(ctx context.Context, tenantID, mfaToken string) (database.User, []string, string, error) {
+	claims, err := auth.ParsePurposeToken(s.signer, mfaToken, purposeMFAChallenge)
+	if err != nil || claims.TenantID != tenantID {
+		return database.User{}, nil, "", ErrInvalidMFAToken
+	}
+	if _, err := s.db.GetActiveUserActionToken(ctx, database.GetActiveUserActionTokenParams{
+		TokenHash: hashToken(claims.ID),
+		Purpose:   purposeMFAChallenge,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, nil, "", ErrInvalidMFAToken
+		}
+		return database.User{}, nil, "", fmt.Errorf("failed to get mfa challenge: %w", err)
+	}
+	user, err := s.db.GetUserByID(ctx, claims.Subject)
+	if err != nil || user.TenantID != tenantID {
+		return database.User{}, nil, "", ErrInvalidMFAToken
+	}
+	amr := claims.AMR
+	if len(amr) == 0 {
+		amr = []string{amrPassword}
+	}
+	return user, amr, claims.ID, nil
+}
+
+// useTOTPCode 校验验证码并记录时间步，同一时间步的验证码只能使用一次
+func (s *Service) useTOTPCode(ctx context.Context, totp database.UserMfaTotp, code string) error {
+	step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok || step <= totp.LastUsedStep {
+		return ErrInvalidMFACode
+	}
+	n, err := s.db.UpdateUserTOTPLastUsedStep(ctx, database.UpdateUserTOTPLastUsedStepParams{
+		UserID:       totp.UserID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update totp step: %w", err)
+	}
+	if n == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// useCurrentTOTPCode 已登录用户修改MFA设置前校验当前验证码。
+// 错误与登录二次验证共用账号和IP计数，持有访问令牌也不能无限次尝试
+func (s *Service) useCurrentTOTPCode(ctx context.Context, user database.User, totp database.UserMfaTotp, code, clientIP, userAgent string) error {
+	keys := []lockout.Key{lockout.AccountKey(user.TenantID, user.Email), lockout.IPKey(clientIP)}
+	if err := s.guard.Check(ctx, keys...); err != nil {
+		return err
+	}
+	if err := s.useTOTPCode(ctx, totp, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.guard.Fail(ctx, clientIP, userAgent, keys...); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return s.guard.Succeed(ctx, keys[0])
+}
+
+// useRecoveryCode 消费一个恢复码
+func (s *Service) useRecoveryCode(ctx context.Context, userID, code string) error {
+	_, err := s.db.UseUserRecoveryCode(ctx, database.UseUserRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: hashRecoveryCode(userID, code),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidMFACode
+		}
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	slog.Info("Recovery code used", "user_id", userID)
+	return nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// hashRecoveryCode 恢复码哈希，加入用户ID避免跨用户比对
+func hashRecoveryCode(userID, code string) string {
+	return hashToken(userID + ":" + normalizeRecoveryCode(code))
+}
+
+// generateRecoveryCodes 作废旧恢复码并生成一组新的，返回明文
+func (s *Service) generateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	if err := s.db.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := hex.EncodeToString(b)
+		code := raw[:5] + "-" + raw[5:]
+		if err := s.db.CreateUserRecoveryCode(ctx, database.CreateUserRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(userID, code),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// startTOTPEnrollment 生成新的未确认TOTP密钥，已启用时拒绝
+func (s *Service) startTOTPEnrollment(ctx context.Context, user database.User) (*TOTPEnrollmentResponse, error) {
+	_, enabled, err := s.confirmedTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	t, err := s.db.GetTenantByID(ctx, user.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	if _, err := s.db.UpsertUserTOTP(ctx, database.UpsertUserTOTPParams{
+		UserID: user.ID,
+		Secret: secret,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store totp secret: %w", err)
+	}
+
+	return &TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(t.Name, user.Email, secret),
+	}, nil
+}
+
+// confirmTOTPEnrollment 用第一个验证码确认绑定，并生成恢复码
+func (s *Service) confirmTOTPEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	totp, err := s.db.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+	if totp.ConfirmedAt.Valid {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	if _, err := s.db.ConfirmUserTOTP(ctx, database.ConfirmUserTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, fmt.Errorf("failed to confirm totp: %w", err)
+	}
+
+	slog.Info("TOTP enabled", "user_id", userID)
+
+	return s.generateRecoveryCodes(ctx, userID)
+}
+
+// VerifyMFA 登录第二步：校验挑战令牌和验证码（或恢复码）后签发令牌。
+// 租户强制MFA且用户正在登录中绑定时，第一个验证码同时用于确认绑定
+func (s *Service) VerifyMFA(ctx context.Context, tenantID string, req MFAVerifyRequest, clientIP, userAgent string) (*LoginResponse, error) {
+	return s.verifyMFA(ctx, tenantID, req, clientIP, userAgent, s.completeLogin)
+}
+
+// verifyMFA 校验挑战令牌和验证码（或恢复码），通过后调用 complete 完成登录。
+// 验证码错误按挑战、账号和IP计数：账号与密码登录共用计数，同一挑战错误达到上限后作废
+func (s *Service) verifyMFA(ctx context.Context, tenantID string, req MFAVerifyRequest, clientIP, userAgent string, complete loginCompleter) (*LoginResponse, error) {
+	user, firstFactors, challengeID, err := s.challengeUser(ctx, tenantID, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	challengeKey := lockout.MFAChallengeKey(challengeID)
+	if err := s.guard.Check(ctx, challengeKey); err != nil {
+		if errors.Is(err, lockout.ErrLocked) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	keys := []lockout.Key{lockout.AccountKey(tenantID, user.Email), lockout.IPKey(clientIP)}
+	if err := s.guard.Check(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	amr, recoveryCodes, err := s.checkMFACode(ctx, user, firstFactors, req)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if err := s.guard.Fail(ctx, clientIP, userAgent, append(keys, challengeKey)...); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 并发请求中只有一个能消费挑战
+	if _, err := s.db.ConsumeUserActionToken(ctx, database.ConsumeUserActionTokenParams{
+		TokenHash: hashToken(challengeID),
+		Purpose:   purposeMFAChallenge,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, fmt.Errorf("failed to consume mfa challenge: %w", err)
+	}
+	// 全部认证因素通过后才清除账号的失败计数
+	if err := s.guard.Succeed(ctx, keys[0]); err != nil {
+		return nil, err
+	}
+
+	resp, err := complete(ctx, user, amr, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	resp.RecoveryCodes = recoveryCodes
+	return resp, nil
+}
+
+// checkMFACode 校验验证码或恢复码，返回完成的认证方式。
+// 用户正在登录中绑定TOTP时，第一个验证码用于确认绑定并返回新生成的恢复码
+func (s *Service) checkMFACode(ctx context.Context, user database.User, firstFactors []string, req MFAVerifyRequest) ([]string, []string, error) {
+	withOTP := append(slices.Clone(firstFactors), amrOTP, amrMFA)
+
+	totp, enabled, err := s.confirmedTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !enabled {
+		if req.Code == "" {
+			return nil, nil, ErrInvalidMFACode
+		}
+		codes, err := s.confirmTOTPEnrollment(ctx, user.ID, req.Code)
+		if err != nil {
+			return nil, nil, err
+		}
+		return withOTP, codes, nil
+	}
+
+	switch {
+	case req.Code != "":
+		if err := s.useTOTPCode(ctx, totp, req.Code); err != nil {
+			return nil, nil, err
+		}
+		return withOTP, nil, nil
+	case req.RecoveryCode != "":
+		if err := s.useRecoveryCode(ctx, user.ID, req.RecoveryCode); err != nil {
+			return nil, nil, err
+		}
+		return append(slices.Clone(firstFactors), amrMFA), nil, nil
+	default:
+		return nil, nil, ErrInvalidMFACode
+	}
+}
+
+// EnrollMFAWithChallenge 租户强制MFA时，未绑定用户凭挑战令牌开始绑定TOTP
+func (s *Service) EnrollMFAWithChallenge(ctx context.Context, tenantID string, req MFAEnrollRequest) (*TOTPEnrollmentResponse, error) {
+	user, _, _, err := s.challengeUser(ctx, tenantID, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.startTOTPEnrollment(ctx, user)
+}
+
+// GetMFAStatus 获取当前用户的MFA状态
+func (s *Service) GetMFAStatus(ctx context.Context, tenantID, userID string) (*MFAStatusResponse, error) {
+	if _, err := s.currentUser(ctx, tenantID, userID); err != nil {
+		return nil, err
+	}
+	_, enabled, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := s.db.CountUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return &MFAStatusResponse{TOTPEnabled: enabled, RecoveryCodesRemaining: remaining}, nil
+}
+
+// EnrollTOTP 已登录用户开始绑定TOTP
+func (s *Service) EnrollTOTP(ctx context.Context, tenantID, userID string) (*TOTPEnrollmentResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.startTOTPEnrollment(ctx, user)
+}
+
+// ConfirmTOTP 已登录用户用验证码确认绑定，返回恢复码
+func (s *Service) ConfirmTOTP(ctx context.Context, tenantID, userID string, req TOTPCodeRequest) (*RecoveryCodesResponse, error) {
+	if _, err := s.currentUser(ctx, tenantID, userID); err != nil {
+		return nil, err
+	}
+	codes, err := s.confirmTOTPEnrollment(ctx, userID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP 校验当前验证码后解绑TOTP并删除恢复码
+func (s *Service) DisableTOTP(ctx context.Context, tenantID, userID string, req TOTPCodeRequest, clientIP, userAgent string) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	totp, enabled, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrMFANotEnabled
+	}
+	if err := s.useCurrentTOTPCode(ctx, user, totp, req.Code, clientIP, userAgent); err != nil {
+		return err
+	}
+
+	if err := s.db.DeleteUserTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+	if err := s.db.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	slog.Info("TOTP disabled", "user_id", userID, "tenant_id", tenantID)
+
+	return nil
+}
+
+// RegenerateRecoveryCodes 校验当前验证码后重新生成恢复码，旧恢复码全部失效
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, tenantID, userID string, req TOTPCodeRequest, clientIP, userAgent string) (*RecoveryCodesResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	totp, enabled, err := s.confirmedTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.useCurrentTOTPCode(ctx, user, totp, req.Code, clientIP, userAgent); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.generateRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
@@ -0,0 +1,257 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/lockout"
+)
+
+// currentCode 计算当前时间偏移offset个时间步的验证码
+func currentCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	return code
+}
+
+func accessTokenAMR(t *testing.T, svc *Service, token string) []string {
+	t.Helper()
+	claims := &auth.Claims{}
+	if err := svc.signer.Parse(token, claims); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	return claims.AMR
+}
+
+func TestTOTPEnrollmentAndTwoStepLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "carol@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := LoginRequest{Email: "carol@example.com", Password: "password123"}
+
+	resp, err := svc.Login(ctx, "tnt_test", login, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if resp.MFARequired || !slices.Equal(accessTokenAMR(t, svc, resp.Token), []string{"pwd"}) {
+		t.Fatalf("expected single-factor login with amr=[pwd], got %+v", resp)
+	}
+
+	enrollment, err := svc.EnrollTOTP(ctx, "tnt_test", registered.ID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	confirmed, err := svc.ConfirmTOTP(ctx, "tnt_test", registered.ID, TOTPCodeRequest{Code: currentCode(t, enrollment.Secret, 0)})
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	if len(confirmed.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(confirmed.RecoveryCodes))
+	}
+
+	// 启用后密码登录只返回挑战
+	challenge, err := svc.Login(ctx, "tnt_test", login, "", "")
+	if err != nil {
+		t.Fatalf("Login with MFA: %v", err)
+	}
+	if !challenge.MFARequired || challenge.MFAToken == "" || challenge.Token != "" || challenge.RefreshToken != "" {
+		t.Fatalf("expected mfa challenge without tokens, got %+v", challenge)
+	}
+
+	// 确认绑定时已用过当前时间步，同一验证码不能再次使用
+	_, err = svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: currentCode(t, enrollment.Secret, 0)}, "", "")
+	if !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected replayed code to be rejected, got %v", err)
+	}
+
+	verified, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: currentCode(t, enrollment.Secret, 1)}, "", "")
+	if err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if !slices.Equal(accessTokenAMR(t, svc, verified.Token), []string{"pwd", "otp", "mfa"}) {
+		t.Fatalf("unexpected amr %v", accessTokenAMR(t, svc, verified.Token))
+	}
+
+	// 挑战令牌完成一次登录后不能再次使用，恢复码也不会因此被消耗
+	if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: challenge.MFAToken, RecoveryCode: confirmed.RecoveryCodes[0]}, "", ""); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("expected a used mfa token to be rejected, got %v", err)
+	}
+
+	// 恢复码只能使用一次
+	newChallenge := func() string {
+		t.Helper()
+		resp, err := svc.Login(ctx, "tnt_test", login, "", "")
+		if err != nil {
+			t.Fatalf("Login with MFA: %v", err)
+		}
+		return resp.MFAToken
+	}
+	if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: newChallenge(), RecoveryCode: confirmed.RecoveryCodes[0]}, "", ""); err != nil {
+		t.Fatalf("VerifyMFA with recovery code: %v", err)
+	}
+	if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: newChallenge(), RecoveryCode: confirmed.RecoveryCodes[0]}, "", ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected used recovery code to be rejected, got %v", err)
+	}
+	status, err := svc.GetMFAStatus(ctx, "tnt_test", registered.ID)
+	if err != nil {
+		t.Fatalf("GetMFAStatus: %v", err)
+	}
+	if !status.TOTPEnabled || status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// 挑战令牌不能跨租户使用
+	if _, err := svc.VerifyMFA(ctx, "tnt_other", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: "000000"}, "", ""); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("expected ErrInvalidMFAToken for other tenant, got %v", err)
+	}
+}
+
+func TestTenantRequiredMFAEnrollsDuringLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{"mfa_required": true}`)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "dave@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	challenge, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "dave@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !challenge.MFARequired || !challenge.MFAEnrollmentRequired {
+		t.Fatalf("expected enrollment challenge, got %+v", challenge)
+	}
+
+	// 未绑定时不能直接完成登录
+	if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: "123456"}, "", ""); !errors.Is(err, ErrMFANotEnabled) {
+		t.Fatalf("expected ErrMFANotEnabled before enrollment, got %v", err)
+	}
+
+	enrollment, err := svc.EnrollMFAWithChallenge(ctx, "tnt_test", MFAEnrollRequest{MFAToken: challenge.MFAToken})
+	if err != nil {
+		t.Fatalf("EnrollMFAWithChallenge: %v", err)
+	}
+	resp, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: currentCode(t, enrollment.Secret, 0)}, "", "")
+	if err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if resp.Token == "" || len(resp.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected tokens and recovery codes, got %+v", resp)
+	}
+}
+
+func TestMFAAttemptsAreLimited(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "erin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	enrollment, err := svc.EnrollTOTP(ctx, "tnt_test", registered.ID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	if _, err := svc.ConfirmTOTP(ctx, "tnt_test", registered.ID, TOTPCodeRequest{Code: currentCode(t, enrollment.Secret, 0)}); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	login := LoginRequest{Email: "erin@example.com", Password: "password123"}
+	challenge := func() string {
+		t.Helper()
+		resp, err := svc.Login(ctx, "tnt_test", login, "", "")
+		if err != nil || !resp.MFARequired {
+			t.Fatalf("expected mfa challenge, got %+v, %v", resp, err)
+		}
+		return resp.MFAToken
+	}
+	wrongCode := currentCode(t, enrollment.Secret, 10)
+
+	// 验证码错误计入账号的失败次数，输对密码不会清除计数（阈值为3）
+	first := challenge()
+	for i := 0; i < 2; i++ {
+		if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: first, Code: wrongCode}, "", ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: expected ErrInvalidMFACode, got %v", i+1, err)
+		}
+	}
+	second := challenge()
+	if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: second, RecoveryCode: "not-a-code"}, "", ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected ErrInvalidMFACode, got %v", err)
+	}
+	if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: second, Code: currentCode(t, enrollment.Secret, 1)}, "", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("expected the account to be locked, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("expected password login to be locked as well, got %v", err)
+	}
+
+	// 只看挑战维度：同一挑战错误达到上限后作废，重新登录获得的新挑战不受影响
+	svc.guard = lockout.NewGuard(store, map[string]lockout.Policy{lockout.ScopeMFAChallenge: MFAChallengePolicy})
+	clear(store.authFailures)
+	exhausted := challenge()
+	for i := 0; i < mfaChallengeMaxAttempts; i++ {
+		if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: exhausted, Code: wrongCode}, "", ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: expected ErrInvalidMFACode, got %v", i+1, err)
+		}
+	}
+	if _, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: exhausted, Code: currentCode(t, enrollment.Secret, 1)}, "", ""); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("expected the exhausted challenge to be rejected, got %v", err)
+	}
+	resp, err := svc.VerifyMFA(ctx, "tnt_test", MFAVerifyRequest{MFAToken: challenge(), Code: currentCode(t, enrollment.Secret, 1)}, "", "")
+	if err != nil || resp.Token == "" {
+		t.Fatalf("expected a fresh challenge to succeed, got %+v, %v", resp, err)
+	}
+}
+
+// 持有访问令牌也不能无限次尝试验证码来解绑TOTP或重新生成恢复码
+func TestMFASettingsCodeAttemptsAreLimited(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "fiona@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	enrollment, err := svc.EnrollTOTP(ctx, "tnt_test", registered.ID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	if _, err := svc.ConfirmTOTP(ctx, "tnt_test", registered.ID, TOTPCodeRequest{Code: currentCode(t, enrollment.Secret, 0)}); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	wrongCode := TOTPCodeRequest{Code: currentCode(t, enrollment.Secret, 10)}
+
+	// 两个接口共用账号计数（阈值为3）
+	if _, err := svc.RegenerateRecoveryCodes(ctx, "tnt_test", registered.ID, wrongCode, "", ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected ErrInvalidMFACode, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := svc.DisableTOTP(ctx, "tnt_test", registered.ID, wrongCode, "", ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: expected ErrInvalidMFACode, got %v", i+1, err)
+		}
+	}
+	validCode := TOTPCodeRequest{Code: currentCode(t, enrollment.Secret, 1)}
+	if err := svc.DisableTOTP(ctx, "tnt_test", registered.ID, validCode, "", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("expected DisableTOTP to be locked, got %v", err)
+	}
+	if _, err := svc.RegenerateRecoveryCodes(ctx, "tnt_test", registered.ID, validCode, "", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("expected RegenerateRecoveryCodes to be locked, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "fiona@example.com", Password: "password123"}, "", ""); !errors.Is(err, lockout.ErrLocked) {
+		t.Fatalf("expected password login to be locked as well, got %v", err)
+	}
+	status, err := svc.GetMFAStatus(ctx, "tnt_test", registered.ID)
+	if err != nil || !status.TOTPEnabled {
+		t.Fatalf("expected TOTP to stay enabled, got %+v, %v", status, err)
+	}
+}
@@ -0,0 +1,750 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// /oauth/token 支持的 OIDC 授权类型
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// 支持的 scope
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	// ScopeOfflineAccess 授予时才签发 refresh token
+	ScopeOfflineAccess = "offline_access"
+)
+
+const (
+	// IDTokenTTL id_token 有效期
+	IDTokenTTL = time.Hour
+	// OIDCSessionTTL 托管登录页会话 cookie 的有效期，期间再次授权无需重新登录
+	OIDCSessionTTL = 12 * time.Hour
+	// authorizationCodeTTL 授权码有效期
+	authorizationCodeTTL = time.Minute
+	// purposeOIDCSession 托管登录页会话 cookie 中的用途令牌，jti 为会话ID
+	purposeOIDCSession = "oidc_session"
+)
+
+// SupportedScopes 授权请求中可以使用的 scope，其他 scope 被忽略
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// profileClaims profile scope 对应的标准声明，取自用户资料中的同名字段
+var profileClaims = []string{
+	"name", "given_name", "family_name", "middle_name", "nickname", "preferred_username",
+	"profile", "picture", "website", "gender", "birthdate", "zoneinfo", "locale",
+}
+
+// codeChallengePattern S256 code_challenge 为 SHA-256 摘要的 base64url 编码（无填充）
+var codeChallengePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+// codeVerifierPattern PKCE code_verifier 格式（RFC 7636 4.1）
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+var (
+	// ErrTenantNotFound 租户不存在
+	ErrTenantNotFound = errors.New("tenant not found")
+	// ErrInvalidGrant 授权码或 refresh token 无效、已过期、已使用，或与客户端、回调地址、PKCE 不匹配
+	ErrInvalidGrant = errors.New("invalid grant")
+	// ErrInvalidAccessToken /userinfo 的访问令牌无效
+	ErrInvalidAccessToken = errors.New("invalid access token")
+	// ErrInsufficientScope 访问令牌未授予 openid scope
+	ErrInsufficientScope = errors.New("insufficient scope")
+	// ErrSymmetricSigningKey 用户令牌使用对称密钥签名，依赖方无法通过 JWKS 校验 id_token
+	ErrSymmetricSigningKey = errors.New("openid connect requires an asymmetric signing key")
+)
+
+// AuthorizeError 可以重定向回客户端的授权错误（RFC 6749 4.1.2.1、OIDC Core 3.1.2.6）
+type AuthorizeError struct {
+	Code        string
+	Description string
+}
+
+func (e *AuthorizeError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// AuthorizeRequest 授权请求参数。托管登录页的表单原样带回这些参数
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	// Prompt 空格分隔的 none、login、consent
+	Prompt string `form:"prompt"`
+}
+
+// Values 转换为查询参数，空参数省略
+func (r AuthorizeRequest) Values() url.Values {
+	v := url.Values{}
+	for name, value := range map[string]string{
+		"response_type":         r.ResponseType,
+		"client_id":             r.ClientID,
+		"redirect_uri":          r.RedirectURI,
+		"scope":                 r.Scope,
+		"state":                 r.State,
+		"nonce":                 r.Nonce,
+		"code_challenge":        r.CodeChallenge,
+		"code_challenge_method": r.CodeChallengeMethod,
+		"prompt":                r.Prompt,
+	} {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	return v
+}
+
+// AuthorizeResult 授权请求的处理结果：重定向回客户端、显示登录页或显示授权同意页
+type AuthorizeResult struct {
+	// RedirectURL 非空时重定向回客户端，携带授权码或错误
+	RedirectURL string
+	// LoginRequired 没有有效的登录会话，或客户端要求重新登录
+	LoginRequired bool
+	// ConsentRequired 用户尚未同意客户端请求的 scope
+	ConsentRequired bool
+	TenantName      string
+	Client          *OIDCClientResponse
+	// Scopes 去除不支持的 scope 后客户端请求的 scope
+	Scopes []string
+	// User 已登录的用户，显示授权同意页时使用
+	User *RegisterResponse
+}
+
+// OIDCLoginResponse 托管登录页登录结果。需要二次验证时只返回挑战令牌
+type OIDCLoginResponse struct {
+	// SessionToken 写入会话 cookie 的令牌
+	SessionToken          string
+	MFARequired           bool
+	MFAToken              string
+	MFAEnrollmentRequired bool
+}
+
+// OIDCTokenResponse /oauth/token 授权码和 refresh token 授权的响应
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+	// RefreshToken 只在授予 offline_access 时签发
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// DiscoveryDocument OpenID Provider 元数据（OIDC Discovery 3）
+type DiscoveryDocument struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	PromptValuesSupported                      []string `json:"prompt_values_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+// OIDCProvider 基于租户和用户服务的 OpenID Provider。每个租户是一个独立的 issuer：
+// <baseURL>/oidc/<tenant_id>。用户在托管登录页登录后得到一个普通的登录会话，
+// 通过授权码签发的访问令牌、refresh token 和 id_token 都属于该会话，注销会话即全部失效
+type OIDCProvider struct {
+	users   *Service
+	baseURL string
+}
+
+// NewOIDCProvider 创建 OpenID Provider，baseURL 为对外访问地址，不带结尾斜杠。
+// id_token 与用户令牌使用同一签名密钥，密钥为 HS256 时返回 ErrSymmetricSigningKey
+func NewOIDCProvider(users *Service, baseURL string) (*OIDCProvider, error) {
+	if strings.HasPrefix(users.signer.Algorithm(), "HS") {
+		return nil, ErrSymmetricSigningKey
+	}
+	return &OIDCProvider{users: users, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+// BaseURL 对外访问地址
+func (p *OIDCProvider) BaseURL() string {
+	return p.baseURL
+}
+
+// Issuer 租户的 issuer 标识
+func (p *OIDCProvider) Issuer(tenantID string) string {
+	return p.baseURL + "/oidc/" + url.PathEscape(tenantID)
+}
+
+// UserInfoEndpoint 租户的 userinfo 端点，也是依赖方访问令牌的受众
+func (p *OIDCProvider) UserInfoEndpoint(tenantID string) string {
+	return p.Issuer(tenantID) + "/userinfo"
+}
+
+// Discovery 租户的 OpenID Provider 元数据，租户不存在时返回 ErrTenantNotFound
+func (p *OIDCProvider) Discovery(ctx context.Context, tenantID string) (*DiscoveryDocument, error) {
+	if _, err := p.users.db.GetTenantByID(ctx, tenantID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTenantNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	issuer := p.Issuer(tenantID)
+	return &DiscoveryDocument{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     p.baseURL + "/oauth/token",
+		UserinfoEndpoint:                  p.UserInfoEndpoint(tenantID),
+		JWKSURI:                           p.baseURL + "/.well-known/jwks.json",
+		ScopesSupported:                   SupportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{p.users.signer.Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		PromptValuesSupported:             []string{"none", "login", "consent"},
+		ClaimsSupported: append([]string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "sid", "email", "email_verified",
+		}, profileClaims...),
+		AuthorizationResponseIssParameterSupported: true,
+	}, nil
+}
+
+// Authorize 处理授权请求。sessionToken 为托管登录页会话 cookie，可以为空。
+// 客户端不存在或回调地址未登记时返回 ErrOIDCClientNotFound 或 ErrInvalidRedirectURI，此时不能重定向；
+// 其余请求错误通过 RedirectURL 返回给客户端
+func (p *OIDCProvider) Authorize(ctx context.Context, tenantID, sessionToken string, req AuthorizeRequest) (*AuthorizeResult, error) {
+	result, client, err := p.authorizeContext(ctx, tenantID, req)
+	if err != nil || result.RedirectURL != "" {
+		return result, err
+	}
+
+	prompts := strings.Fields(req.Prompt)
+	session, user, amr, err := p.currentSession(ctx, tenantID, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || slices.Contains(prompts, "login") {
+		if slices.Contains(prompts, "none") {
+			return p.authorizeError(tenantID, req, "login_required", "user is not logged in"), nil
+		}
+		result.LoginRequired = true
+		return result, nil
+	}
+
+	consented, err := p.hasConsent(ctx, client, user.ID, result.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if !consented || slices.Contains(prompts, "consent") {
+		if slices.Contains(prompts, "none") {
+			return p.authorizeError(tenantID, req, "consent_required", "user has not consented to the requested scopes"), nil
+		}
+		result.ConsentRequired = true
+		result.User = toUserResponse(user)
+		return result, nil
+	}
+
+	result.RedirectURL, err = p.issueCode(ctx, client, *session, user, amr, req, result.Scopes)
+	return result, err
+}
+
+// Consent 处理授权同意页的提交。allow 为 false 时以 access_denied 重定向回客户端；
+// 同意后保存授权范围并签发授权码。会话已失效时返回 LoginRequired
+func (p *OIDCProvider) Consent(ctx context.Context, tenantID, sessionToken string, req AuthorizeRequest, allow bool) (*AuthorizeResult, error) {
+	result, client, err := p.authorizeContext(ctx, tenantID, req)
+	if err != nil || result.RedirectURL != "" {
+		return result, err
+	}
+	session, user, amr, err := p.currentSession(ctx, tenantID, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		result.LoginRequired = true
+		return result, nil
+	}
+	if !allow {
+		slog.Info("OIDC consent denied", "tenant_id", tenantID, "user_id", user.ID, "client_id", client.ID)
+		return p.authorizeError(tenantID, req, "access_denied", "user denied the request"), nil
+	}
+
+	if err := p.users.db.UpsertOIDCConsent(ctx, database.UpsertOIDCConsentParams{
+		UserID:   user.ID,
+		ClientID: client.ID,
+		TenantID: tenantID,
+		Scopes:   result.Scopes,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to save oidc consent: %w", err)
+	}
+	slog.Info("OIDC consent granted", "tenant_id", tenantID, "user_id", user.ID, "client_id", client.ID, "scopes", result.Scopes)
+
+	result.RedirectURL, err = p.issueCode(ctx, client, *session, user, amr, req, result.Scopes)
+	return result, err
+}
+
+// Login 托管登录页的密码登录。与 /v1/auth/login 的校验一致，但只创建登录会话，不签发令牌
+func (p *OIDCProvider) Login(ctx context.Context, tenantID string, req LoginRequest, clientIP, userAgent string) (*OIDCLoginResponse, error) {
+	resp, err := p.users.passwordLogin(ctx, tenantID, req, clientIP, userAgent, p.completeLogin)
+	if err != nil {
+		return nil, err
+	}
+	return toOIDCLoginResponse(resp), nil
+}
+
+// VerifyMFA 托管登录页的二次验证
+func (p *OIDCProvider) VerifyMFA(ctx context.Context, tenantID string, req MFAVerifyRequest, clientIP, userAgent string) (*OIDCLoginResponse, error) {
+	resp, err := p.users.verifyMFA(ctx, tenantID, req, clientIP, userAgent, p.completeLogin)
+	if err != nil {
+		return nil, err
+	}
+	return toOIDCLoginResponse(resp), nil
+}
+
+func toOIDCLoginResponse(resp *LoginResponse) *OIDCLoginResponse {
+	return &OIDCLoginResponse{
+		SessionToken:          resp.Token,
+		MFARequired:           resp.MFARequired,
+		MFAToken:              resp.MFAToken,
+		MFAEnrollmentRequired: resp.MFAEnrollmentRequired,
+	}
+}
+
+// completeLogin 创建登录会话，Token 为会话 cookie 的用途令牌
+func (p *OIDCProvider) completeLogin(ctx context.Context, user database.User, amr []string, clientIP, userAgent string) (*LoginResponse, error) {
+	session, err := p.users.startSession(ctx, user, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	claims := auth.PurposeClaims{
+		Purpose:  purposeOIDCSession,
+		TenantID: user.TenantID,
+		AMR:      amr,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			ID:        session.ID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCSessionTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	token, err := p.users.signer.Sign(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign session token: %w", err)
+	}
+
+	slog.Info("User logged in", "user_id", user.ID, "email", user.Email, "tenant_id", user.TenantID, "amr", amr, "session_id", session.ID, "via", "oidc")
+	return &LoginResponse{User: toUserResponse(user), Token: token}, nil
+}
+
+// currentSession 校验会话 cookie，返回会话、用户和登录时完成的认证方式。
+// cookie 无效、会话已注销或用户不可用时返回 nil 会话
+func (p *OIDCProvider) currentSession(ctx context.Context, tenantID, sessionToken string) (*database.UserSession, database.User, []string, error) {
+	if sessionToken == "" {
+		return nil, database.User{}, nil, nil
+	}
+	claims, err := auth.ParsePurposeToken(p.users.signer, sessionToken, purposeOIDCSession)
+	if err != nil || claims.TenantID != tenantID {
+		return nil, database.User{}, nil, nil
+	}
+	session, err := p.users.db.GetUserSession(ctx, claims.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.User{}, nil, nil
+	}
+	if err != nil {
+		return nil, database.User{}, nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.RevokedAt.Valid || !session.ExpiresAt.After(time.Now()) || session.UserID != claims.Subject {
+		return nil, database.User{}, nil, nil
+	}
+	user, err := p.users.currentUser(ctx, tenantID, session.UserID)
+	if err != nil || checkUserStatus(user) != nil {
+		return nil, database.User{}, nil, nil
+	}
+	return &session, user, claims.AMR, nil
+}
+
+// authorizeContext 校验授权请求，返回页面需要的客户端信息和 scope。
+// 可以重定向的错误通过 RedirectURL 返回
+func (p *OIDCProvider) authorizeContext(ctx context.Context, tenantID string, req AuthorizeRequest) (*AuthorizeResult, database.OidcClient, error) {
+	client, err := p.users.tenantOIDCClient(ctx, tenantID, req.ClientID)
+	if err != nil {
+		return nil, client, err
+	}
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		return nil, client, ErrInvalidRedirectURI
+	}
+	t, err := p.users.db.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return nil, client, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	scopes, authErr := validateAuthorizeRequest(req)
+	if authErr != nil {
+		return p.authorizeError(tenantID, req, authErr.Code, authErr.Description), client, nil
+	}
+	return &AuthorizeResult{
+		TenantName: t.Name,
+		Client:     toOIDCClientResponse(client),
+		Scopes:     scopes,
+	}, client, nil
+}
+
+// validateAuthorizeRequest 校验可以重定向回客户端报告的参数错误，返回支持的 scope
+func validateAuthorizeRequest(req AuthorizeRequest) ([]string, *AuthorizeError) {
+	if req.ResponseType != "code" {
+		return nil, &AuthorizeError{"unsupported_response_type", "response_type must be code"}
+	}
+	requested := strings.Fields(req.Scope)
+	if !slices.Contains(requested, ScopeOpenID) {
+		return nil, &AuthorizeError{"invalid_scope", "openid scope is required"}
+	}
+	scopes := make([]string, 0, len(SupportedScopes))
+	for _, scope := range SupportedScopes {
+		if slices.Contains(requested, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.CodeChallenge == "" {
+		return nil, &AuthorizeError{"invalid_request", "code_challenge is required"}
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return nil, &AuthorizeError{"invalid_request", "code_challenge_method must be S256"}
+	}
+	if !codeChallengePattern.MatchString(req.CodeChallenge) {
+		return nil, &AuthorizeError{"invalid_request", "invalid code_challenge"}
+	}
+	prompts := strings.Fields(req.Prompt)
+	for _, prompt := range prompts {
+		if prompt != "none" && prompt != "login" && prompt != "consent" {
+			return nil, &AuthorizeError{"invalid_request", "unsupported prompt value " + prompt}
+		}
+	}
+	if slices.Contains(prompts, "none") && len(prompts) > 1 {
+		return nil, &AuthorizeError{"invalid_request", "prompt=none cannot be combined with other values"}
+	}
+	return scopes, nil
+}
+
+// authorizeError 生成携带错误的回调地址
+func (p *OIDCProvider) authorizeError(tenantID string, req AuthorizeRequest, code, description string) *AuthorizeResult {
+	params := url.Values{"error": {code}, "error_description": {description}}
+	return &AuthorizeResult{RedirectURL: p.callbackURL(tenantID, req, params)}
+}
+
+// callbackURL 在回调地址上附加参数、state 和 iss（RFC 9207）
+func (p *OIDCProvider) callbackURL(tenantID string, req AuthorizeRequest, params url.Values) string {
+	u, _ := url.Parse(req.RedirectURI)
+	q := u.Query()
+	for name, values := range params {
+		q[name] = values
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	q.Set("iss", p.Issuer(tenantID))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// hasConsent 用户已同意的 scope 是否覆盖本次请求。第一方客户端不需要同意
+func (p *OIDCProvider) hasConsent(ctx context.Context, client database.OidcClient, userID string, scopes []string) (bool, error) {
+	if client.SkipConsent {
+		return true, nil
+	}
+	consent, err := p.users.db.GetOIDCConsent(ctx, database.GetOIDCConsentParams{UserID: userID, ClientID: client.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get oidc consent: %w", err)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(consent.Scopes, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// issueCode 签发授权码，返回携带授权码的回调地址
+func (p *OIDCProvider) issueCode(ctx context.Context, client database.OidcClient, session database.UserSession, user database.User, amr []string, req AuthorizeRequest, scopes []string) (string, error) {
+	code, err := generateRefreshToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+	if amr == nil {
+		amr = []string{}
+	}
+	if err := p.users.db.CreateOIDCAuthorizationCode(ctx, database.CreateOIDCAuthorizationCodeParams{
+		CodeHash:      hashToken(code),
+		TenantID:      client.TenantID,
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		SessionID:     session.ID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         sql.NullString{String: req.Nonce, Valid: req.Nonce != ""},
+		CodeChallenge: req.CodeChallenge,
+		Amr:           amr,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	}); err != nil {
+		return "", fmt.Errorf("failed to store authorization code: %w", err)
+	}
+	slog.Info("OIDC authorization code issued", "tenant_id", client.TenantID, "user_id", user.ID, "client_id", client.ID, "session_id", session.ID)
+	return p.callbackURL(client.TenantID, req, url.Values{"code": {code}}), nil
+}
+
+// verifyCodeChallenge 校验 code_verifier 的 SHA-256 摘要与授权请求中的 code_challenge 一致
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// ExchangeCode 授权码换取令牌。授权码只能使用一次，再次使用时注销签发它的会话并记录安全事件。
+// 客户端认证失败返回 ErrInvalidOIDCClient，授权码无效返回 ErrInvalidGrant
+func (p *OIDCProvider) ExchangeCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier, clientIP, userAgent string) (*OIDCTokenResponse, error) {
+	client, err := p.users.authenticateOIDCClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := p.users.db.GetOIDCAuthorizationCode(ctx, hashToken(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authorization code: %w", err)
+	}
+	if stored.ClientID != client.ID {
+		return nil, ErrInvalidGrant
+	}
+	if stored.UsedAt.Valid {
+		return nil, p.revokeReusedCode(ctx, stored, clientIP, userAgent)
+	}
+	if !stored.ExpiresAt.After(time.Now()) || stored.RedirectUri != redirectURI || !verifyCodeChallenge(codeVerifier, stored.CodeChallenge) {
+		return nil, ErrInvalidGrant
+	}
+	used, err := p.users.db.MarkOIDCAuthorizationCodeUsed(ctx, stored.CodeHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark authorization code used: %w", err)
+	}
+	if used == 0 {
+		return nil, p.revokeReusedCode(ctx, stored, clientIP, userAgent)
+	}
+
+	session, err := p.users.db.GetUserSession(ctx, stored.SessionID)
+	if err != nil || session.RevokedAt.Valid {
+		return nil, ErrInvalidGrant
+	}
+	user, err := p.users.currentUser(ctx, client.TenantID, stored.UserID)
+	if err != nil || checkUserStatus(user) != nil {
+		return nil, ErrInvalidGrant
+	}
+	return p.issueTokens(ctx, client, user, session, stored.Scopes, stored.Amr, stored.Nonce.String, nil, clientIP, userAgent)
+}
+
+// revokeReusedCode 授权码被重放说明可能已泄露，注销签发它的会话
+func (p *OIDCProvider) revokeReusedCode(ctx context.Context, code database.OidcAuthorizationCode, clientIP, userAgent string) error {
+	if err := p.users.revokeSession(ctx, code.SessionID); err != nil {
+		return err
+	}
+	p.users.recordSecurityEvent(ctx, code.TenantID, code.UserID, SecurityEventAuthorizationCodeReuse, clientIP, userAgent, map[string]any{
+		"client_id":  code.ClientID,
+		"session_id": code.SessionID,
+	})
+	return ErrInvalidGrant
+}
+
+// Refresh 轮换客户端的 refresh token，签发新的访问令牌和 id_token，授权范围不变。
+// 令牌只能由签发时的客户端使用，与 /v1/auth/refresh 一样检测重放
+func (p *OIDCProvider) Refresh(ctx context.Context, clientID, clientSecret, refreshToken, clientIP, userAgent string) (*OIDCTokenResponse, error) {
+	client, err := p.users.authenticateOIDCClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	user, session, token, err := p.users.rotateRefreshToken(ctx, client.TenantID, client.ID, refreshToken, clientIP, userAgent)
+	switch {
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused),
+		errors.Is(err, ErrUserDisabled), errors.Is(err, ErrUserLocked):
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGrant, err)
+	case err != nil:
+		return nil, err
+	}
+	return p.issueTokens(ctx, client, user, session, token.Scopes, token.Amr, "", &token, clientIP, userAgent)
+}
+
+// issueTokens 签发访问令牌和 id_token。parent 为被轮换的 refresh token；
+// 为nil时在授予 offline_access 的情况下为会话签发客户端的第一个 refresh token
+func (p *OIDCProvider) issueTokens(ctx context.Context, client database.OidcClient, user database.User, session database.UserSession, scopes, amr []string, nonce string, parent *database.UserRefreshToken, clientIP, userAgent string) (*OIDCTokenResponse, error) {
+	organizationID, err := p.users.sessionOrganization(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := p.users.userClaims(ctx, user, session.ID, organizationID, amr, AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	claims.Issuer = p.Issuer(client.TenantID)
+	// 访问令牌的受众为 userinfo 端点，不能用来调用本服务的用户接口
+	claims.Audience = jwt.ClaimStrings{p.UserInfoEndpoint(client.TenantID)}
+	claims.ClientID = client.ID
+	claims.Scope = strings.Join(scopes, " ")
+	accessToken, err := p.users.signClaims(ctx, user, claims)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := p.signIDToken(client, user, session, scopes, amr, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var refreshToken string
+	switch {
+	case parent != nil:
+		refreshToken, err = p.users.renewRefreshToken(ctx, user, session, parent, clientIP, userAgent)
+	case slices.Contains(scopes, ScopeOfflineAccess):
+		refreshToken, err = p.users.storeRefreshToken(ctx, database.CreateRefreshTokenParams{
+			UserID:    user.ID,
+			TenantID:  user.TenantID,
+			FamilyID:  session.ID,
+			Amr:       amr,
+			ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
+			UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+			ClientID:  sql.NullString{String: client.ID, Valid: true},
+			Scopes:    scopes,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    auth.TokenTypeBearer,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+		IDToken:      idToken,
+		RefreshToken: refreshToken,
+		Scope:        claims.Scope,
+	}, nil
+}
+
+// signIDToken 签发 id_token，auth_time 为会话创建（用户登录）的时间
+func (p *OIDCProvider) signIDToken(client database.OidcClient, user database.User, session database.UserSession, scopes, amr []string, nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims(standardClaims(user, scopes))
+	claims["iss"] = p.Issuer(client.TenantID)
+	claims["aud"] = client.ID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(IDTokenTTL).Unix()
+	claims["auth_time"] = session.CreatedAt.Unix()
+	claims["sid"] = session.ID
+	if len(amr) > 0 {
+		claims["amr"] = amr
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	token, err := p.users.signer.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign id token: %w", err)
+	}
+	return token, nil
+}
+
+// standardClaims 按授予的 scope 返回用户的标准声明（OIDC Core 5.1），sub 为用户ID
+func standardClaims(user database.User, scopes []string) map[string]any {
+	claims := map[string]any{"sub": user.ID}
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if slices.Contains(scopes, ScopeProfile) && user.Profile.Valid {
+		var profile map[string]any
+		if err := json.Unmarshal(user.Profile.RawMessage, &profile); err == nil {
+			for _, name := range profileClaims {
+				if value, ok := profile[name]; ok && value != nil {
+					claims[name] = value
+				}
+			}
+		}
+	}
+	return claims
+}
+
+// UserInfo 返回访问令牌授予的 scope 对应的用户声明。令牌须由该租户通过授权码签发并包含 openid scope
+func (p *OIDCProvider) UserInfo(ctx context.Context, tenantID, accessToken string) (map[string]any, error) {
+	claims := &auth.Claims{}
+	if err := p.users.signer.Parse(accessToken, claims); err != nil || claims.UserID == "" || claims.TenantID != tenantID {
+		return nil, ErrInvalidAccessToken
+	}
+	if claims.ClientID == "" || !slices.Contains(claims.Audience, p.UserInfoEndpoint(tenantID)) {
+		return nil, ErrInvalidAccessToken
+	}
+	if p.users.revoker.IsRevoked(claims) {
+		return nil, ErrInvalidAccessToken
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return nil, ErrInsufficientScope
+	}
+	user, err := p.users.currentUser(ctx, claims.TenantID, claims.UserID)
+	if err != nil || checkUserStatus(user) != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	return standardClaims(user, scopes), nil
+}
+
+// RunCleanup 定期删除过期的授权码，直到 ctx 取消
+func (p *OIDCProvider) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := p.users.db.DeleteExpiredOIDCAuthorizationCodes(ctx)
+			if err != nil {
+				slog.Error("Failed to delete expired authorization codes", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Debug("Deleted expired authorization codes", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package user
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+
+	"yuyu-test/internal/store/database"
+)
+
+// maxRedirectURIs 每个客户端最多登记的回调地址数
+const maxRedirectURIs = 20
+
+var (
+	// ErrOIDCClientNotFound OIDC 客户端不存在或不属于当前租户
+	ErrOIDCClientNotFound = errors.New("oidc client not found")
+	// ErrInvalidRedirectURI 回调地址不合法，或授权请求中的回调地址未登记
+	ErrInvalidRedirectURI = errors.New("invalid redirect_uri")
+	// ErrInvalidOIDCClient /oauth/token 客户端认证失败
+	ErrInvalidOIDCClient = errors.New("invalid client credentials")
+)
+
+// OIDCClientRequest 创建或修改 OIDC 客户端请求
+type OIDCClientRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// RedirectURIs 回调地址，须为 https，本机开发可以使用 http://localhost
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,max=20"`
+	// Public 公开客户端（如单页应用）没有密钥，只能凭 PKCE 换取令牌。创建后不能修改
+	Public bool `json:"public"`
+	// SkipConsent 第一方应用跳过授权同意页
+	SkipConsent bool `json:"skip_consent"`
+}
+
+// OIDCClientResponse OIDC 客户端
+type OIDCClientResponse struct {
+	ID           string   `json:"client_id"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Public       bool     `json:"public"`
+	SkipConsent  bool     `json:"skip_consent"`
+	// ClientSecret 只在创建和重置密钥时返回一次
+	ClientSecret string `json:"client_secret,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+}
+
+func toOIDCClientResponse(c database.OidcClient) *OIDCClientResponse {
+	return &OIDCClientResponse{
+		ID:           c.ID,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectUris,
+		Public:       !c.ClientSecretHash.Valid,
+		SkipConsent:  c.SkipConsent,
+		CreatedAt:    c.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:    c.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// validateRedirectURIs 回调地址必须是不带片段的绝对地址；除本机回环地址外只允许 https
+func validateRedirectURIs(uris []string) error {
+	if len(uris) == 0 || len(uris) > maxRedirectURIs {
+		return fmt.Errorf("%w: 1-%d redirect URIs are required", ErrInvalidRedirectURI, maxRedirectURIs)
+	}
+	for _, raw := range uris {
+		u, err := url.Parse(raw)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidRedirectURI, raw)
+		}
+		switch u.Scheme {
+		case "https":
+		case "http":
+			if host := u.Hostname(); host != "localhost" && !isLoopback(host) {
+				return fmt.Errorf("%w: %q must use https", ErrInvalidRedirectURI, raw)
+			}
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidRedirectURI, raw)
+		}
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// generateClientSecret 生成客户端密钥，返回明文和保存用的哈希
+func generateClientSecret() (string, sql.NullString, error) {
+	secret, err := generateRefreshToken()
+	if err != nil {
+		return "", sql.NullString{}, fmt.Errorf("failed to generate client secret: %w", err)
+	}
+	return secret, sql.NullString{String: hashToken(secret), Valid: true}, nil
+}
+
+// tenantOIDCClient 获取属于指定租户的客户端，不存在时返回 ErrOIDCClientNotFound
+func (s *Service) tenantOIDCClient(ctx context.Context, tenantID, clientID string) (database.OidcClient, error) {
+	client, err := s.db.GetOIDCClient(ctx, database.GetOIDCClientParams{ID: clientID, TenantID: tenantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return client, ErrOIDCClientNotFound
+	}
+	if err != nil {
+		return client, fmt.Errorf("failed to get oidc client: %w", err)
+	}
+	return client, nil
+}
+
+// CreateOIDCClient 登记 OIDC 客户端。机密客户端的密钥只在响应中返回这一次
+func (s *Service) CreateOIDCClient(ctx context.Context, tenantID string, req OIDCClientRequest) (*OIDCClientResponse, error) {
+	if err := validateRedirectURIs(req.RedirectURIs); err != nil {
+		return nil, err
+	}
+	var (
+		secret     string
+		secretHash sql.NullString
+		err        error
+	)
+	if !req.Public {
+		if secret, secretHash, err = generateClientSecret(); err != nil {
+			return nil, err
+		}
+	}
+	client, err := s.db.CreateOIDCClient(ctx, database.CreateOIDCClientParams{
+		ID:               generateID("oidc"),
+		TenantID:         tenantID,
+		Name:             req.Name,
+		ClientSecretHash: secretHash,
+		RedirectUris:     req.RedirectURIs,
+		SkipConsent:      req.SkipConsent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create oidc client: %w", err)
+	}
+
+	slog.Info("OIDC client created", "client_id", client.ID, "tenant_id", tenantID, "public", req.Public)
+	resp := toOIDCClientResponse(client)
+	resp.ClientSecret = secret
+	return resp, nil
+}
+
+// ListOIDCClients 列出租户的 OIDC 客户端
+func (s *Service) ListOIDCClients(ctx context.Context, tenantID string) ([]*OIDCClientResponse, error) {
+	clients, err := s.db.ListOIDCClients(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list oidc clients: %w", err)
+	}
+	result := make([]*OIDCClientResponse, 0, len(clients))
+	for _, c := range clients {
+		result = append(result, toOIDCClientResponse(c))
+	}
+	return result, nil
+}
+
+// GetOIDCClient 获取 OIDC 客户端
+func (s *Service) GetOIDCClient(ctx context.Context, tenantID, clientID string) (*OIDCClientResponse, error) {
+	client, err := s.tenantOIDCClient(ctx, tenantID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return toOIDCClientResponse(client), nil
+}
+
+// UpdateOIDCClient 修改客户端名称、回调地址和是否跳过授权同意，req.Public 被忽略
+func (s *Service) UpdateOIDCClient(ctx context.Context, tenantID, clientID string, req OIDCClientRequest) (*OIDCClientResponse, error) {
+	if err := validateRedirectURIs(req.RedirectURIs); err != nil {
+		return nil, err
+	}
+	client, err := s.db.UpdateOIDCClient(ctx, database.UpdateOIDCClientParams{
+		ID:           clientID,
+		TenantID:     tenantID,
+		Name:         req.Name,
+		RedirectUris: req.RedirectURIs,
+		SkipConsent:  req.SkipConsent,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOIDCClientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update oidc client: %w", err)
+	}
+	slog.Info("OIDC client updated", "client_id", clientID, "tenant_id", tenantID)
+	return toOIDCClientResponse(client), nil
+}
+
+// ResetOIDCClientSecret 为机密客户端生成新密钥，旧密钥立即失效；公开客户端没有密钥，返回 ErrOIDCClientNotFound
+func (s *Service) ResetOIDCClientSecret(ctx context.Context, tenantID, clientID string) (*OIDCClientResponse, error) {
+	client, err := s.tenantOIDCClient(ctx, tenantID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !client.ClientSecretHash.Valid {
+		return nil, ErrOIDCClientNotFound
+	}
+	secret, secretHash, err := generateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.db.UpdateOIDCClientSecret(ctx, database.UpdateOIDCClientSecretParams{ID: clientID, TenantID: tenantID, ClientSecretHash: secretHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update oidc client secret: %w", err)
+	}
+	if n == 0 {
+		return nil, ErrOIDCClientNotFound
+	}
+	slog.Info("OIDC client secret reset", "client_id", clientID, "tenant_id", tenantID)
+	resp := toOIDCClientResponse(client)
+	resp.ClientSecret = secret
+	return resp, nil
+}
+
+// DeleteOIDCClient 删除 OIDC 客户端，其授权码、授权同意和 refresh token 一并删除
+func (s *Service) DeleteOIDCClient(ctx context.Context, tenantID, clientID string) error {
+	n, err := s.db.DeleteOIDCClient(ctx, database.DeleteOIDCClientParams{ID: clientID, TenantID: tenantID})
+	if err != nil {
+		return fmt.Errorf("failed to delete oidc client: %w", err)
+	}
+	if n == 0 {
+		return ErrOIDCClientNotFound
+	}
+	slog.Info("OIDC client deleted", "client_id", clientID, "tenant_id", tenantID)
+	return nil
+}
+
+// authenticateOIDCClient /oauth/token 的客户端认证：机密客户端须提供正确的密钥，
+// 公开客户端不能提供密钥。失败时统一返回 ErrInvalidOIDCClient
+func (s *Service) authenticateOIDCClient(ctx context.Context, clientID, secret string) (database.OidcClient, error) {
+	client, err := s.db.GetOIDCClientByID(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return client, ErrInvalidOIDCClient
+	}
+	if err != nil {
+		return client, fmt.Errorf("failed to get oidc client: %w", err)
+	}
+	if !client.ClientSecretHash.Valid {
+		if secret != "" {
+			return client, ErrInvalidOIDCClient
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.ClientSecretHash.String)) != 1 {
+		return client, ErrInvalidOIDCClient
+	}
+	return client, nil
+}
@@ -0,0 +1,424 @@
+package user_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"yuyu-test/internal/api"
+	"yuyu-test/internal/api/handlers"
+	"yuyu-test/internal/api/middleware"
+	"yuyu-test/internal/user"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const rpRedirectURI = "http://127.0.0.1:9999/callback"
+
+// hiddenInputPattern 托管登录页表单中的隐藏字段
+var hiddenInputPattern = regexp.MustCompile(`<input type="hidden" name="([^"]+)" value="([^"]*)">`)
+
+// testRP 最小的 OIDC 依赖方：发现 → 授权（PKCE）→ 换取令牌 → 按 kid 用 JWKS 校验 id_token → userinfo
+type testRP struct {
+	t        *testing.T
+	server   *httptest.Server
+	http     *http.Client
+	issuer   string
+	config   user.DiscoveryDocument
+	keys     map[string]*rsa.PublicKey
+	clientID string
+	secret   string
+}
+
+// startTestServer 启动使用真实路由和 JWTAuth 的测试服务器，返回服务器和其使用的用户服务
+func startTestServer(t *testing.T) (*httptest.Server, *user.Service) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	svc, signer, revocations := user.NewRS256TestService(t)
+
+	// 对外地址取决于测试服务器，路由在服务器启动后创建
+	var handler http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	provider, err := user.NewOIDCProvider(svc, server.URL)
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+	oidcHandler := handlers.NewOIDCHandler(provider)
+	handler = api.NewRouter(
+		nil,
+		svc,
+		middleware.NewAuthMiddleware(nil, signer, revocations, svc),
+		nil,
+		handlers.NewInternalAuthHandler(nil, nil, nil, nil, svc, oidcHandler),
+		oidcHandler,
+		handlers.NewJWKSHandler(signer, signer),
+		nil,
+		&middleware.InternalAuthMiddleware{},
+		nil,
+	).Setup()
+	return server, svc
+}
+
+// getMe 以 Bearer 令牌调用 GET /v1/users/me，返回状态码
+func getMe(t *testing.T, server *httptest.Server, accessToken string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /v1/users/me: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// startOIDCServer 启动测试服务器，返回 RP 和 OP 使用的用户服务
+func startOIDCServer(t *testing.T) (*testRP, *user.Service) {
+	t.Helper()
+	server, svc := startTestServer(t)
+
+	jar, _ := cookiejar.New(nil)
+	rp := &testRP{
+		t:      t,
+		server: server,
+		issuer: server.URL + "/oidc/tnt_test",
+		keys:   map[string]*rsa.PublicKey{},
+		http: &http.Client{
+			Jar: jar,
+			// 回调到 RP 时停止跟随重定向
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if strings.HasPrefix(req.URL.String(), rpRedirectURI) {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		},
+	}
+	resp := rp.do(rp.http.Get(rp.issuer + "/.well-known/openid-configuration"))
+	rp.decode(resp, http.StatusOK, &rp.config)
+	if rp.config.Issuer != rp.issuer {
+		t.Fatalf("expected issuer %s, got %s", rp.issuer, rp.config.Issuer)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	rp.decode(rp.do(rp.http.Get(rp.config.JWKSURI)), http.StatusOK, &jwks)
+	for _, key := range jwks.Keys {
+		n, err1 := base64.RawURLEncoding.DecodeString(key.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(key.E)
+		if key.Kty != "RSA" || key.Alg != "RS256" || err1 != nil || err2 != nil {
+			t.Fatalf("unexpected jwk: %+v", key)
+		}
+		rp.keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(rp.keys) != 1 {
+		t.Fatalf("expected one published key, got %+v", jwks)
+	}
+	return rp, svc
+}
+
+func (rp *testRP) do(resp *http.Response, err error) *http.Response {
+	rp.t.Helper()
+	if err != nil {
+		rp.t.Fatalf("request failed: %v", err)
+	}
+	rp.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (rp *testRP) decode(resp *http.Response, status int, v any) {
+	rp.t.Helper()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != status {
+		rp.t.Fatalf("expected status %d from %s, got %d: %s", status, resp.Request.URL, resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		rp.t.Fatalf("decode %s: %v", body, err)
+	}
+}
+
+// submit 提交页面上的表单，隐藏字段原样带回
+func (rp *testRP) submit(page *http.Response, action string, fields url.Values) *http.Response {
+	rp.t.Helper()
+	body, _ := io.ReadAll(page.Body)
+	form := url.Values{}
+	for _, m := range hiddenInputPattern.FindAllStringSubmatch(string(body), -1) {
+		form.Add(html.UnescapeString(m[1]), html.UnescapeString(m[2]))
+	}
+	if form.Get("csrf_token") == "" {
+		rp.t.Fatalf("expected a form on %s, got %d: %s", page.Request.URL, page.StatusCode, body)
+	}
+	for name, values := range fields {
+		form[name] = values
+	}
+	target, _ := page.Request.URL.Parse(action)
+	return rp.do(rp.http.PostForm(target.String(), form))
+}
+
+// authorize 发起授权请求，返回 code_verifier、nonce 和 state
+func (rp *testRP) authorize(scope, prompt string) (*http.Response, string, string, string) {
+	rp.t.Helper()
+	verifier := randomString(rp.t)
+	sum := sha256.Sum256([]byte(verifier))
+	nonce, state := randomString(rp.t), randomString(rp.t)
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.clientID},
+		"redirect_uri":          {rpRedirectURI},
+		"scope":                 {scope},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if prompt != "" {
+		params.Set("prompt", prompt)
+	}
+	return rp.do(rp.http.Get(rp.config.AuthorizationEndpoint + "?" + params.Encode())), verifier, nonce, state
+}
+
+// callback 校验回调中的 state 和 iss，返回授权码
+func (rp *testRP) callback(resp *http.Response, state string) string {
+	rp.t.Helper()
+	if resp.StatusCode != http.StatusFound && resp.StatusCode != http.StatusSeeOther {
+		body, _ := io.ReadAll(resp.Body)
+		rp.t.Fatalf("expected a redirect to the RP, got %d: %s", resp.StatusCode, body)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), rpRedirectURI) {
+		rp.t.Fatalf("expected a redirect to %s, got %s", rpRedirectURI, resp.Header.Get("Location"))
+	}
+	q := location.Query()
+	if q.Get("state") != state || q.Get("iss") != rp.issuer {
+		rp.t.Fatalf("expected state and iss in callback, got %s", location)
+	}
+	if q.Get("code") == "" {
+		rp.t.Fatalf("expected an authorization code, got %s", location)
+	}
+	return q.Get("code")
+}
+
+func (rp *testRP) token(form url.Values) *http.Response {
+	rp.t.Helper()
+	// 机密客户端使用 Basic Auth，公开客户端只在表单中提供 client_id
+	if rp.secret == "" {
+		form.Set("client_id", rp.clientID)
+	}
+	req, _ := http.NewRequest(http.MethodPost, rp.config.TokenEndpoint, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if rp.secret != "" {
+		req.SetBasicAuth(url.QueryEscape(rp.clientID), url.QueryEscape(rp.secret))
+	}
+	return rp.do(http.DefaultClient.Do(req))
+}
+
+// exchange 授权码换取令牌并校验 id_token
+func (rp *testRP) exchange(code, verifier, nonce string) (user.OIDCTokenResponse, jwt.MapClaims) {
+	rp.t.Helper()
+	var tokens user.OIDCTokenResponse
+	rp.decode(rp.token(url.Values{
+		"grant_type":    {user.GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {rpRedirectURI},
+		"code_verifier": {verifier},
+	}), http.StatusOK, &tokens)
+	return tokens, rp.verifyIDToken(tokens.IDToken, nonce)
+}
+
+func (rp *testRP) verifyIDToken(idToken, nonce string) jwt.MapClaims {
+	rp.t.Helper()
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := rp.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(rp.issuer), jwt.WithAudience(rp.clientID), jwt.WithExpirationRequired())
+	if err != nil {
+		rp.t.Fatalf("invalid id_token: %v", err)
+	}
+	if nonce != "" && claims["nonce"] != nonce {
+		rp.t.Fatalf("expected nonce %s, got %v", nonce, claims["nonce"])
+	}
+	return claims
+}
+
+func (rp *testRP) userInfo(accessToken string) *http.Response {
+	rp.t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, rp.config.UserinfoEndpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return rp.do(http.DefaultClient.Do(req))
+}
+
+func randomString(t *testing.T) string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestOIDCEndToEnd(t *testing.T) {
+	ctx := context.Background()
+	rp, svc := startOIDCServer(t)
+	if _, err := svc.Register(ctx, "tnt_test", user.RegisterRequest{Email: "rp@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	client, err := svc.CreateOIDCClient(ctx, "tnt_test", user.OIDCClientRequest{Name: "Test RP", RedirectURIs: []string{rpRedirectURI}})
+	if err != nil {
+		t.Fatalf("CreateOIDCClient: %v", err)
+	}
+	rp.clientID, rp.secret = client.ID, client.ClientSecret
+
+	// 登录页 → 密码错误 → 重新登录 → 授权同意页 → 回调
+	page, verifier, nonce, state := rp.authorize("openid email offline_access", "")
+	retry := rp.submit(page, "login", url.Values{"email": {"rp@example.com"}, "password": {"wrong-password"}})
+	if retry.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong password, got %d", retry.StatusCode)
+	}
+	consent := rp.submit(retry, "login", url.Values{"email": {"rp@example.com"}, "password": {"password123"}})
+	code := rp.callback(rp.submit(consent, "consent", url.Values{"action": {"allow"}}), state)
+
+	tokens, idClaims := rp.exchange(code, verifier, nonce)
+	if tokens.RefreshToken == "" || tokens.Scope != "openid email offline_access" {
+		t.Fatalf("expected a refresh token for offline_access, got %+v", tokens)
+	}
+	if idClaims["email"] != "rp@example.com" || idClaims["sid"] == nil || idClaims["auth_time"] == nil {
+		t.Fatalf("unexpected id_token claims: %v", idClaims)
+	}
+	sub := idClaims["sub"]
+
+	var info map[string]any
+	rp.decode(rp.userInfo(tokens.AccessToken), http.StatusOK, &info)
+	if info["sub"] != sub || info["email"] != "rp@example.com" {
+		t.Fatalf("unexpected userinfo: %v", info)
+	}
+	// 依赖方的访问令牌只能调用 userinfo，不能代替用户调用用户接口
+	if status := getMe(t, rp.server, tokens.AccessToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected the relying party access token to be rejected by /v1/users/me, got %d", status)
+	}
+
+	// 授权码只能使用一次；重放注销会话，已签发的 refresh token 随之失效
+	var oauthErr map[string]string
+	rp.decode(rp.token(url.Values{
+		"grant_type":    {user.GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {rpRedirectURI},
+		"code_verifier": {verifier},
+	}), http.StatusBadRequest, &oauthErr)
+	if oauthErr["error"] != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for a replayed code, got %v", oauthErr)
+	}
+	rp.decode(rp.token(url.Values{"grant_type": {user.GrantTypeRefreshToken}, "refresh_token": {tokens.RefreshToken}}), http.StatusBadRequest, &oauthErr)
+	if oauthErr["error"] != "invalid_grant" {
+		t.Fatalf("expected invalid_grant after session revocation, got %v", oauthErr)
+	}
+
+	// 会话已注销，prompt=none 返回 login_required；重新登录后已同意的 scope 不再询问
+	resp, _, _, state := rp.authorize("openid email", "none")
+	if location, _ := url.Parse(resp.Header.Get("Location")); location.Query().Get("error") != "login_required" {
+		t.Fatalf("expected login_required, got %s", resp.Header.Get("Location"))
+	}
+	page, verifier, nonce, state = rp.authorize("openid email offline_access", "")
+	code = rp.callback(rp.submit(page, "login", url.Values{"email": {"rp@example.com"}, "password": {"password123"}}), state)
+	tokens, _ = rp.exchange(code, verifier, nonce)
+
+	// refresh token 轮换：新的访问令牌和 id_token，用户不变
+	var refreshed user.OIDCTokenResponse
+	rp.decode(rp.token(url.Values{"grant_type": {user.GrantTypeRefreshToken}, "refresh_token": {tokens.RefreshToken}}), http.StatusOK, &refreshed)
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("expected a rotated refresh token, got %+v", refreshed)
+	}
+	if claims := rp.verifyIDToken(refreshed.IDToken, ""); claims["sub"] != sub {
+		t.Fatalf("expected the same subject after refresh, got %v", claims["sub"])
+	}
+
+	// 错误的客户端密钥
+	secret := rp.secret
+	rp.secret = "wrong-secret"
+	rp.decode(rp.token(url.Values{"grant_type": {user.GrantTypeRefreshToken}, "refresh_token": {refreshed.RefreshToken}}), http.StatusUnauthorized, &oauthErr)
+	if oauthErr["error"] != "invalid_client" {
+		t.Fatalf("expected invalid_client, got %v", oauthErr)
+	}
+	rp.secret = secret
+
+	// 已登录且已同意时 prompt=none 直接回调
+	resp, verifier, nonce, state = rp.authorize("openid", "none")
+	if _, claims := rp.exchange(rp.callback(resp, state), verifier, nonce); claims["email"] != nil {
+		t.Fatalf("expected no email claim without the email scope, got %v", claims)
+	}
+}
+
+func TestOIDCPublicClientDenied(t *testing.T) {
+	ctx := context.Background()
+	rp, svc := startOIDCServer(t)
+	if _, err := svc.Register(ctx, "tnt_test", user.RegisterRequest{Email: "spa@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	client, err := svc.CreateOIDCClient(ctx, "tnt_test", user.OIDCClientRequest{Name: "SPA", RedirectURIs: []string{rpRedirectURI}, Public: true})
+	if err != nil {
+		t.Fatalf("CreateOIDCClient: %v", err)
+	}
+	rp.clientID = client.ID
+
+	// 拒绝授权时以 access_denied 回调
+	page, _, _, state := rp.authorize("openid profile", "")
+	consent := rp.submit(page, "login", url.Values{"email": {"spa@example.com"}, "password": {"password123"}})
+	resp := rp.submit(consent, "consent", url.Values{"action": {"deny"}})
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	if location.Query().Get("error") != "access_denied" || location.Query().Get("state") != state {
+		t.Fatalf("expected access_denied, got %s", location)
+	}
+
+	// 同意后公开客户端只凭 PKCE 换取令牌，未请求 offline_access 时不签发 refresh token
+	page, verifier, nonce, state := rp.authorize("openid profile", "consent")
+	code := rp.callback(rp.submit(page, "consent", url.Values{"action": {"allow"}}), state)
+	var oauthErr map[string]string
+	rp.decode(rp.token(url.Values{
+		"grant_type":    {user.GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {rpRedirectURI},
+		"code_verifier": {randomString(t)},
+	}), http.StatusBadRequest, &oauthErr)
+	if oauthErr["error"] != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for a wrong code_verifier, got %v", oauthErr)
+	}
+	page, verifier, nonce, state = rp.authorize("openid profile", "")
+	tokens, _ := rp.exchange(rp.callback(page, state), verifier, nonce)
+	if tokens.RefreshToken != "" {
+		t.Fatal("expected no refresh token without offline_access")
+	}
+
+	// 无效的访问令牌不能访问 userinfo
+	resp = rp.userInfo("not-a-token")
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(resp.Header.Get("WWW-Authenticate"), "invalid_token") {
+		t.Fatalf("expected invalid_token, got %d %s", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+}
@@ -0,0 +1,348 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"yuyu-test/internal/store/database"
+)
+
+var (
+	// ErrOrganizationNotFound 组织不存在或不属于当前租户
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrNotOrganizationMember 用户不是该组织的成员
+	ErrNotOrganizationMember = errors.New("user is not a member of the organization")
+)
+
+// CreateOrganizationRequest 创建组织请求
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+// UpdateOrganizationRequest 修改组织请求
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+// OrganizationResponse 组织信息
+type OrganizationResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// SetOrganizationMemberRequest 将用户加入组织并设置其在组织内的角色，role_ids 替换已有的组织角色
+type SetOrganizationMemberRequest struct {
+	RoleIDs []string `json:"role_ids"`
+}
+
+// OrganizationMemberResponse 组织成员及其在组织内的角色名
+type OrganizationMemberResponse struct {
+	OrganizationID string   `json:"organization_id"`
+	UserID         string   `json:"user_id"`
+	Roles          []string `json:"roles"`
+	JoinedAt       string   `json:"joined_at"`
+}
+
+// UserOrganizationResponse 用户所属的组织及其在组织内的角色名
+type UserOrganizationResponse struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+	// Active 是否为当前会话选择的组织
+	Active bool `json:"active"`
+}
+
+// SwitchOrganizationRequest 切换当前会话的组织，organization_id 为空表示不选择组织
+type SwitchOrganizationRequest struct {
+	OrganizationID string `json:"organization_id"`
+}
+
+func toOrganizationResponse(org database.Organization) *OrganizationResponse {
+	return &OrganizationResponse{
+		ID:        org.ID,
+		Name:      org.Name,
+		CreatedAt: org.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// tenantOrganization 获取属于指定租户的组织，不存在时返回 ErrOrganizationNotFound
+func (s *Service) tenantOrganization(ctx context.Context, tenantID, organizationID string) (database.Organization, error) {
+	org, err := s.db.GetOrganization(ctx, database.GetOrganizationParams{ID: organizationID, TenantID: tenantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Organization{}, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return database.Organization{}, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org, nil
+}
+
+// memberRoleNames 查询成员在组织内的角色名
+func (s *Service) memberRoleNames(ctx context.Context, organizationID, userID string) ([]string, error) {
+	roles, err := s.db.ListOrganizationMemberRoles(ctx, database.ListOrganizationMemberRolesParams{OrganizationID: organizationID, UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization member roles: %w", err)
+	}
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names, nil
+}
+
+// CreateOrganization 在租户下创建组织
+func (s *Service) CreateOrganization(ctx context.Context, tenantID string, req CreateOrganizationRequest) (*OrganizationResponse, error) {
+	org, err := s.db.CreateOrganization(ctx, database.CreateOrganizationParams{
+		ID:       generateID("org"),
+		TenantID: tenantID,
+		Name:     req.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+	slog.Info("Organization created", "organization_id", org.ID, "tenant_id", tenantID)
+	return toOrganizationResponse(org), nil
+}
+
+// ListOrganizations 列出租户的全部组织，按名称排序
+func (s *Service) ListOrganizations(ctx context.Context, tenantID string) ([]*OrganizationResponse, error) {
+	orgs, err := s.db.ListOrganizations(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	resp := make([]*OrganizationResponse, 0, len(orgs))
+	for _, org := range orgs {
+		resp = append(resp, toOrganizationResponse(org))
+	}
+	return resp, nil
+}
+
+// GetOrganization 获取组织信息
+func (s *Service) GetOrganization(ctx context.Context, tenantID, organizationID string) (*OrganizationResponse, error) {
+	org, err := s.tenantOrganization(ctx, tenantID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return toOrganizationResponse(org), nil
+}
+
+// UpdateOrganization 修改组织名称
+func (s *Service) UpdateOrganization(ctx context.Context, tenantID, organizationID string, req UpdateOrganizationRequest) (*OrganizationResponse, error) {
+	org, err := s.db.UpdateOrganization(ctx, database.UpdateOrganizationParams{ID: organizationID, TenantID: tenantID, Name: req.Name})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+	return toOrganizationResponse(org), nil
+}
+
+// DeleteOrganization 删除组织及其成员关系和邀请。以该组织为当前组织的会话在下次刷新令牌时回到不选择组织
+func (s *Service) DeleteOrganization(ctx context.Context, tenantID, organizationID string) error {
+	n, err := s.db.DeleteOrganization(ctx, database.DeleteOrganizationParams{ID: organizationID, TenantID: tenantID})
+	if err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	if n == 0 {
+		return ErrOrganizationNotFound
+	}
+	slog.Info("Organization deleted", "organization_id", organizationID, "tenant_id", tenantID)
+	return nil
+}
+
+// SetOrganizationMember 将用户加入组织（已是成员时保留加入时间），并把其组织角色替换为 role_ids。
+// 角色使用租户定义的角色，新角色在用户下次获取访问令牌时写入令牌
+func (s *Service) SetOrganizationMember(ctx context.Context, tenantID, organizationID, userID string, req SetOrganizationMemberRequest) (*OrganizationMemberResponse, error) {
+	org, err := s.tenantOrganization(ctx, tenantID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, roleID := range req.RoleIDs {
+		if _, err := s.tenantRole(ctx, tenantID, roleID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.AddOrganizationMember(ctx, database.AddOrganizationMemberParams{OrganizationID: org.ID, UserID: user.ID}); err != nil {
+		return nil, fmt.Errorf("failed to add organization member: %w", err)
+	}
+	if err := s.db.DeleteOrganizationMemberRoles(ctx, database.DeleteOrganizationMemberRolesParams{OrganizationID: org.ID, UserID: user.ID}); err != nil {
+		return nil, fmt.Errorf("failed to reset organization member roles: %w", err)
+	}
+	if err := s.addMemberRoles(ctx, org.ID, user.ID, req.RoleIDs); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Organization member set", "organization_id", org.ID, "user_id", user.ID, "tenant_id", tenantID, "role_ids", req.RoleIDs)
+	return s.GetOrganizationMember(ctx, tenantID, org.ID, user.ID)
+}
+
+func (s *Service) addMemberRoles(ctx context.Context, organizationID, userID string, roleIDs []string) error {
+	for _, roleID := range roleIDs {
+		if err := s.db.AddOrganizationMemberRole(ctx, database.AddOrganizationMemberRoleParams{
+			OrganizationID: organizationID,
+			UserID:         userID,
+			RoleID:         roleID,
+		}); err != nil {
+			return fmt.Errorf("failed to add organization member role: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetOrganizationMember 获取组织成员及其组织角色
+func (s *Service) GetOrganizationMember(ctx context.Context, tenantID, organizationID, userID string) (*OrganizationMemberResponse, error) {
+	org, err := s.tenantOrganization(ctx, tenantID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.db.GetOrganizationMember(ctx, database.GetOrganizationMemberParams{OrganizationID: org.ID, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotOrganizationMember
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+	roles, err := s.memberRoleNames(ctx, org.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &OrganizationMemberResponse{
+		OrganizationID: member.OrganizationID,
+		UserID:         member.UserID,
+		Roles:          roles,
+		JoinedAt:       member.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}, nil
+}
+
+// ListOrganizationMembers 分页列出组织成员，过滤和排序条件与 ListUsers 相同
+func (s *Service) ListOrganizationMembers(ctx context.Context, tenantID, organizationID string, req ListUsersRequest) (*ListUsersResponse, error) {
+	org, err := s.tenantOrganization(ctx, tenantID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	req.OrganizationID = org.ID
+	return s.ListUsers(ctx, tenantID, req)
+}
+
+// RemoveOrganizationMember 将用户移出组织，同时移除其组织角色。
+// 以该组织为当前组织的会话回到不选择组织，已签发的访问令牌在过期前仍带有组织角色
+func (s *Service) RemoveOrganizationMember(ctx context.Context, tenantID, organizationID, userID string) error {
+	org, err := s.tenantOrganization(ctx, tenantID, organizationID)
+	if err != nil {
+		return err
+	}
+	n, err := s.db.RemoveOrganizationMember(ctx, database.RemoveOrganizationMemberParams{OrganizationID: org.ID, UserID: userID})
+	if err != nil {
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	if n == 0 {
+		return ErrNotOrganizationMember
+	}
+	if err := s.db.ClearUserSessionOrganization(ctx, database.ClearUserSessionOrganizationParams{
+		UserID:         userID,
+		OrganizationID: sql.NullString{String: org.ID, Valid: true},
+	}); err != nil {
+		return fmt.Errorf("failed to clear session organization: %w", err)
+	}
+	slog.Info("Organization member removed", "organization_id", org.ID, "user_id", userID, "tenant_id", tenantID)
+	return nil
+}
+
+// ListMyOrganizations 列出用户所属的组织，activeOrganizationID 为访问令牌中的 org_id
+func (s *Service) ListMyOrganizations(ctx context.Context, tenantID, userID, activeOrganizationID string) ([]*UserOrganizationResponse, error) {
+	if _, err := s.currentUser(ctx, tenantID, userID); err != nil {
+		return nil, err
+	}
+	return s.userOrganizations(ctx, tenantID, userID, activeOrganizationID)
+}
+
+func (s *Service) userOrganizations(ctx context.Context, tenantID, userID, activeOrganizationID string) ([]*UserOrganizationResponse, error) {
+	orgs, err := s.db.ListUserOrganizations(ctx, database.ListUserOrganizationsParams{UserID: userID, TenantID: tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user organizations: %w", err)
+	}
+	resp := make([]*UserOrganizationResponse, 0, len(orgs))
+	for _, org := range orgs {
+		roles, err := s.memberRoleNames(ctx, org.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, &UserOrganizationResponse{
+			ID:     org.ID,
+			Name:   org.Name,
+			Roles:  roles,
+			Active: org.ID == activeOrganizationID,
+		})
+	}
+	return resp, nil
+}
+
+// SwitchOrganization 切换会话当前的组织并签发新的访问令牌，无需重新登录。
+// 会话的 refresh token 不变，之后刷新得到的令牌沿用新组织
+func (s *Service) SwitchOrganization(ctx context.Context, tenantID, userID, sessionID string, amr []string, req SwitchOrganizationRequest) (*LoginResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+	session, err := s.db.GetUserSession(ctx, sessionID)
+	if err != nil || session.UserID != user.ID || session.TenantID != tenantID || session.RevokedAt.Valid {
+		return nil, ErrSessionNotFound
+	}
+
+	if req.OrganizationID != "" {
+		org, err := s.tenantOrganization(ctx, tenantID, req.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.db.GetOrganizationMember(ctx, database.GetOrganizationMemberParams{OrganizationID: org.ID, UserID: user.ID}); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrNotOrganizationMember
+			}
+			return nil, fmt.Errorf("failed to get organization member: %w", err)
+		}
+	}
+	if err := s.db.SetUserSessionOrganization(ctx, database.SetUserSessionOrganizationParams{
+		ID:             session.ID,
+		OrganizationID: sql.NullString{String: req.OrganizationID, Valid: req.OrganizationID != ""},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to switch organization: %w", err)
+	}
+
+	token, err := s.signAccessToken(ctx, user, session.ID, req.OrganizationID, amr)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Organization switched", "user_id", user.ID, "tenant_id", tenantID, "session_id", session.ID, "organization_id", req.OrganizationID)
+	return &LoginResponse{User: toUserResponse(user), Token: token}, nil
+}
+
+// sessionOrganization 返回会话当前的组织；用户已不是该组织成员时清除会话的组织并返回空
+func (s *Service) sessionOrganization(ctx context.Context, session database.UserSession) (string, error) {
+	if !session.OrganizationID.Valid {
+		return "", nil
+	}
+	_, err := s.db.GetOrganizationMember(ctx, database.GetOrganizationMemberParams{OrganizationID: session.OrganizationID.String, UserID: session.UserID})
+	if err == nil {
+		return session.OrganizationID.String, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to get organization member: %w", err)
+	}
+	if err := s.db.SetUserSessionOrganization(ctx, database.SetUserSessionOrganizationParams{ID: session.ID}); err != nil {
+		return "", fmt.Errorf("failed to clear session organization: %w", err)
+	}
+	return "", nil
+}
@@ -0,0 +1,188 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// invitationToken 从最近一封邀请邮件的链接中取出令牌
+func invitationToken(t *testing.T, mail *captureMailer) string {
+	t.Helper()
+	link, err := url.Parse(lastToken(t, mail))
+	if err != nil || !strings.HasPrefix(link.String(), "https://app.example.com/join?") {
+		t.Fatalf("unexpected invitation link: %v", link)
+	}
+	return link.Query().Get("token")
+}
+
+func TestOrganizationMembershipAndSwitch(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	kai, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "bo@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	acme, err := svc.CreateOrganization(ctx, "tnt_test", CreateOrganizationRequest{Name: "Acme"})
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	globex, err := svc.CreateOrganization(ctx, "tnt_test", CreateOrganizationRequest{Name: "Globex"})
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	billing, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "billing", Permissions: []string{"invoices:read"}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+
+	// 其他租户的组织不可见
+	if _, err := svc.GetOrganization(ctx, "tnt_other", acme.ID); !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("expected ErrOrganizationNotFound, got %v", err)
+	}
+	if _, err := svc.SetOrganizationMember(ctx, "tnt_test", acme.ID, kai.ID, SetOrganizationMemberRequest{RoleIDs: []string{"rol_missing"}}); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("expected ErrRoleNotFound, got %v", err)
+	}
+	member, err := svc.SetOrganizationMember(ctx, "tnt_test", acme.ID, kai.ID, SetOrganizationMemberRequest{RoleIDs: []string{billing.ID}})
+	if err != nil {
+		t.Fatalf("SetOrganizationMember: %v", err)
+	}
+	if !slices.Equal(member.Roles, []string{"billing"}) {
+		t.Fatalf("unexpected member roles: %v", member.Roles)
+	}
+
+	// 用户列表和成员列表按组织过滤
+	for _, sort := range []string{SortCreatedAtDesc, SortEmailAsc} {
+		page, err := svc.ListUsers(ctx, "tnt_test", ListUsersRequest{Sort: sort, OrganizationID: acme.ID})
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		if len(page.Users) != 1 || page.Users[0].ID != kai.ID {
+			t.Fatalf("expected only kai in acme, got %+v", page.Users)
+		}
+	}
+	if _, err := svc.ListOrganizationMembers(ctx, "tnt_test", "org_missing", ListUsersRequest{}); !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("expected ErrOrganizationNotFound, got %v", err)
+	}
+
+	// 登录时不选择组织，切换后令牌带有 org_id 和组织角色
+	resp, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "kai@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims := accessTokenClaims(t, svc, resp.Token)
+	if claims.OrganizationID != "" || len(claims.Permissions) != 0 {
+		t.Fatalf("expected no organization after login, got %+v", claims)
+	}
+	if _, err := svc.SwitchOrganization(ctx, "tnt_test", kai.ID, claims.SessionID, claims.AMR, SwitchOrganizationRequest{OrganizationID: globex.ID}); !errors.Is(err, ErrNotOrganizationMember) {
+		t.Fatalf("expected ErrNotOrganizationMember, got %v", err)
+	}
+	switched, err := svc.SwitchOrganization(ctx, "tnt_test", kai.ID, claims.SessionID, claims.AMR, SwitchOrganizationRequest{OrganizationID: acme.ID})
+	if err != nil {
+		t.Fatalf("SwitchOrganization: %v", err)
+	}
+	if c := accessTokenClaims(t, svc, switched.Token); c.OrganizationID != acme.ID || c.SessionID != claims.SessionID || !slices.Equal(c.Permissions, []string{"invoices:read"}) {
+		t.Fatalf("unexpected claims after switch: %+v", c)
+	}
+	orgs, err := svc.ListMyOrganizations(ctx, "tnt_test", kai.ID, acme.ID)
+	if err != nil || len(orgs) != 1 || !orgs[0].Active || !slices.Equal(orgs[0].Roles, []string{"billing"}) {
+		t.Fatalf("unexpected organizations: %+v, %v", orgs, err)
+	}
+
+	// 刷新令牌沿用会话的组织
+	refreshed, err := svc.RefreshTokens(ctx, "tnt_test", resp.RefreshToken, "", "")
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if c := accessTokenClaims(t, svc, refreshed.Token); c.OrganizationID != acme.ID {
+		t.Fatalf("expected org_id to survive refresh, got %q", c.OrganizationID)
+	}
+
+	// 移出组织后刷新得到的令牌不再带有该组织
+	if err := svc.RemoveOrganizationMember(ctx, "tnt_test", acme.ID, kai.ID); err != nil {
+		t.Fatalf("RemoveOrganizationMember: %v", err)
+	}
+	refreshed, err = svc.RefreshTokens(ctx, "tnt_test", refreshed.RefreshToken, "", "")
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if c := accessTokenClaims(t, svc, refreshed.Token); c.OrganizationID != "" || len(c.Permissions) != 0 {
+		t.Fatalf("expected organization to be dropped, got %+v", c)
+	}
+}
+
+func TestOrganizationInvitations(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{"invitation_url":"https://app.example.com/join"}`)
+
+	kai, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	bo, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "bo@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	acme, err := svc.CreateOrganization(ctx, "tnt_test", CreateOrganizationRequest{Name: "Acme"})
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	admin, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "org-admin"})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+
+	inv, err := svc.CreateInvitation(ctx, "tnt_test", CreateInvitationRequest{Email: "Bo@example.com", OrganizationID: acme.ID, RoleIDs: []string{admin.ID}})
+	if err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	if msg := mail.messages[len(mail.messages)-1]; msg.To != "Bo@example.com" {
+		t.Fatalf("unexpected invitation recipient: %q", msg.To)
+	}
+	token := invitationToken(t, mail)
+	if pending, _ := svc.ListInvitations(ctx, "tnt_test", ListInvitationsRequest{OrganizationID: acme.ID, Status: InvitationPending}); len(pending) != 1 || pending[0].ID != inv.ID {
+		t.Fatalf("expected one pending invitation, got %+v", pending)
+	}
+
+	// 邀请只能由受邀邮箱的用户接受，且只能接受一次
+	if _, err := svc.AcceptInvitation(ctx, "tnt_test", kai.ID, AcceptInvitationRequest{Token: token}); !errors.Is(err, ErrInvitationEmailMismatch) {
+		t.Fatalf("expected ErrInvitationEmailMismatch, got %v", err)
+	}
+	accepted, err := svc.AcceptInvitation(ctx, "tnt_test", bo.ID, AcceptInvitationRequest{Token: token})
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if accepted.Status != InvitationAccepted || accepted.AcceptedBy != bo.ID {
+		t.Fatalf("unexpected invitation after accept: %+v", accepted)
+	}
+	member, err := svc.GetOrganizationMember(ctx, "tnt_test", acme.ID, bo.ID)
+	if err != nil || !slices.Equal(member.Roles, []string{"org-admin"}) {
+		t.Fatalf("unexpected membership: %+v, %v", member, err)
+	}
+	if _, err := svc.AcceptInvitation(ctx, "tnt_test", bo.ID, AcceptInvitationRequest{Token: token}); !errors.Is(err, ErrInvitationNotFound) {
+		t.Fatalf("expected ErrInvitationNotFound, got %v", err)
+	}
+
+	// 撤销后的邀请不能再接受
+	if _, err := svc.CreateInvitation(ctx, "tnt_test", CreateInvitationRequest{Email: "kai@example.com", OrganizationID: acme.ID}); err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	pending, _ := svc.ListInvitations(ctx, "tnt_test", ListInvitationsRequest{OrganizationID: acme.ID, Status: InvitationPending})
+	if len(pending) != 1 {
+		t.Fatalf("expected one pending invitation, got %d", len(pending))
+	}
+	if err := svc.RevokeInvitation(ctx, "tnt_test", acme.ID, pending[0].ID); err != nil {
+		t.Fatalf("RevokeInvitation: %v", err)
+	}
+	revokedToken := invitationToken(t, mail)
+	if _, err := svc.AcceptInvitation(ctx, "tnt_test", kai.ID, AcceptInvitationRequest{Token: revokedToken}); !errors.Is(err, ErrInvitationNotFound) {
+		t.Fatalf("expected ErrInvitationNotFound, got %v", err)
+	}
+}
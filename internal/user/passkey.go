@@ -0,0 +1,416 @@
+package user
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+	"yuyu-test/internal/webauthn"
+)
+
+const (
+	// purposeWebAuthnRegistration Passkey注册挑战用途
+	purposeWebAuthnRegistration = "webauthn_registration"
+	// purposeWebAuthnLogin Passkey登录挑战用途
+	purposeWebAuthnLogin = "webauthn_login"
+	// webAuthnChallengeTTL 挑战有效期，与前端仪式超时一致
+	webAuthnChallengeTTL = webauthn.ChallengeTimeout * time.Millisecond
+	// webAuthnLoginChallengeLimit 单个用户在挑战有效期内最多保存的登录挑战数
+	webAuthnLoginChallengeLimit = 10
+
+	// amrHardwareKey 持有密钥证明（RFC 8176）
+	amrHardwareKey = "hwk"
+)
+
+var (
+	// ErrWebAuthnNotConfigured 租户未配置WebAuthn依赖方
+	ErrWebAuthnNotConfigured = errors.New("webauthn is not configured for this tenant")
+	// ErrInvalidWebAuthnResponse 挑战无效或凭证校验失败
+	ErrInvalidWebAuthnResponse = errors.New("invalid webauthn response")
+	// ErrPasskeyNotFound Passkey不存在或不属于当前用户
+	ErrPasskeyNotFound = errors.New("passkey not found")
+)
+
+// PasskeyRegistrationRequest 完成Passkey注册
+type PasskeyRegistrationRequest struct {
+	// Name 用户为该Passkey起的名称，便于管理
+	Name       string                        `json:"name" binding:"max=255"`
+	Credential webauthn.RegistrationResponse `json:"credential" binding:"required"`
+}
+
+// PasskeyLoginBeginRequest 开始Passkey登录
+type PasskeyLoginBeginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// PasskeyLoginFinishRequest 完成Passkey登录
+type PasskeyLoginFinishRequest struct {
+	Credential webauthn.AssertionResponse `json:"credential" binding:"required"`
+}
+
+// CreationOptionsResponse 传给 navigator.credentials.create() 的选项
+type CreationOptionsResponse struct {
+	PublicKey *webauthn.CreationOptions `json:"publicKey"`
+}
+
+// RequestOptionsResponse 传给 navigator.credentials.get() 的选项
+type RequestOptionsResponse struct {
+	PublicKey *webauthn.RequestOptions `json:"publicKey"`
+}
+
+// PasskeyResponse Passkey信息
+type PasskeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Transports []string `json:"transports"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+}
+
+func toPasskeyResponse(c database.WebauthnCredential) *PasskeyResponse {
+	resp := &PasskeyResponse{
+		ID:         c.ID,
+		Name:       c.Name,
+		Transports: c.Transports,
+		CreatedAt:  c.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if c.LastUsedAt.Valid {
+		resp.LastUsedAt = c.LastUsedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return resp
+}
+
+// relyingParty 根据租户配置构造依赖方
+func (s *Service) relyingParty(ctx context.Context, tenantID string) (*webauthn.RelyingParty, error) {
+	t, err := s.db.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	settings, err := tenant.ParseSettings(t.Settings)
+	if err != nil {
+		return nil, err
+	}
+	if settings.WebAuthnRPID == "" {
+		return nil, ErrWebAuthnNotConfigured
+	}
+
+	rp := &webauthn.RelyingParty{
+		ID:      settings.WebAuthnRPID,
+		Name:    settings.WebAuthnRPName,
+		Origins: settings.WebAuthnOrigins,
+	}
+	if rp.Name == "" {
+		rp.Name = t.Name
+	}
+	if len(rp.Origins) == 0 {
+		rp.Origins = []string{"https://" + rp.ID}
+	}
+	return rp, nil
+}
+
+// storeChallenge 保存挑战哈希，同一用途下旧挑战作废
+func (s *Service) storeChallenge(ctx context.Context, user database.User, purpose, challenge string) error {
+	if err := s.db.InvalidateUserActionTokens(ctx, database.InvalidateUserActionTokensParams{
+		UserID:  user.ID,
+		Purpose: purpose,
+	}); err != nil {
+		return fmt.Errorf("failed to invalidate old challenges: %w", err)
+	}
+	return s.addChallenge(ctx, user, purpose, challenge)
+}
+
+// addChallenge 保存挑战哈希，不影响同一用户的其他挑战。
+// 挑战随机生成并通过 clientDataJSON 返回，本身即用于找到对应的请求
+func (s *Service) addChallenge(ctx context.Context, user database.User, purpose, challenge string) error {
+	if err := s.db.CreateUserActionToken(ctx, database.CreateUserActionTokenParams{
+		UserID:    user.ID,
+		TenantID:  user.TenantID,
+		Purpose:   purpose,
+		TokenHash: hashToken(challenge),
+		ExpiresAt: time.Now().Add(webAuthnChallengeTTL),
+	}); err != nil {
+		return fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return nil
+}
+
+// addLoginChallenge 保存登录挑战，有效期内的挑战数达到上限时静默丢弃
+func (s *Service) addLoginChallenge(ctx context.Context, user database.User, challenge string) error {
+	live, err := s.db.CountRecentUserActionTokens(ctx, database.CountRecentUserActionTokensParams{
+		UserID:    user.ID,
+		Purpose:   purposeWebAuthnLogin,
+		CreatedAt: time.Now().Add(-webAuthnChallengeTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count recent challenges: %w", err)
+	}
+	if live >= webAuthnLoginChallengeLimit {
+		slog.Warn("Passkey login challenges rate limited", "user_id", user.ID, "tenant_id", user.TenantID)
+		return nil
+	}
+	return s.addChallenge(ctx, user, purposeWebAuthnLogin, challenge)
+}
+
+// consumeChallenge 取出响应中的挑战并原子地消费，返回挑战对应的用户ID
+func (s *Service) consumeChallenge(ctx context.Context, tenantID, purpose, clientDataJSON string) (string, string, error) {
+	challenge, err := webauthn.ChallengeOf(clientDataJSON)
+	if err != nil {
+		return "", "", ErrInvalidWebAuthnResponse
+	}
+	token, err := s.db.ConsumeUserActionToken(ctx, database.ConsumeUserActionTokenParams{
+		TokenHash: hashToken(challenge),
+		Purpose:   purpose,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrInvalidWebAuthnResponse
+		}
+		return "", "", fmt.Errorf("failed to consume challenge: %w", err)
+	}
+	if token.TenantID != tenantID {
+		return "", "", ErrInvalidWebAuthnResponse
+	}
+	return token.UserID, challenge, nil
+}
+
+// credentialDescriptors 用户已注册的凭证列表
+func (s *Service) credentialDescriptors(ctx context.Context, userID string) ([]webauthn.CredentialDescriptor, error) {
+	creds, err := s.db.ListWebAuthnCredentialsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         webauthn.EncodeBase64URL(c.CredentialID),
+			Transports: c.Transports,
+		})
+	}
+	return descriptors, nil
+}
+
+// BeginPasskeyRegistration 已登录用户开始注册Passkey
+func (s *Service) BeginPasskeyRegistration(ctx context.Context, tenantID, userID string) (*CreationOptionsResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := s.relyingParty(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := s.credentialDescriptors(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	if err := s.storeChallenge(ctx, user, purposeWebAuthnRegistration, challenge); err != nil {
+		return nil, err
+	}
+
+	return &CreationOptionsResponse{
+		PublicKey: rp.NewCreationOptions(challenge, []byte(user.ID), user.Email, exclude),
+	}, nil
+}
+
+// FinishPasskeyRegistration 校验注册响应并保存凭证
+func (s *Service) FinishPasskeyRegistration(ctx context.Context, tenantID, userID string, req PasskeyRegistrationRequest) (*PasskeyResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := s.relyingParty(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	challengeUserID, challenge, err := s.consumeChallenge(ctx, tenantID, purposeWebAuthnRegistration, req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if challengeUserID != user.ID {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	cred, err := rp.VerifyRegistration(challenge, &req.Credential)
+	if err != nil {
+		slog.Warn("Passkey registration rejected", "user_id", user.ID, "tenant_id", tenantID, "error", err)
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	// 同一租户内凭证ID唯一
+	if _, err := s.db.GetWebAuthnCredentialByCredentialID(ctx, database.GetWebAuthnCredentialByCredentialIDParams{
+		TenantID:     tenantID,
+		CredentialID: cred.ID,
+	}); err == nil {
+		return nil, ErrInvalidWebAuthnResponse
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to check passkey: %w", err)
+	}
+
+	transports := cred.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+	stored, err := s.db.CreateWebAuthnCredential(ctx, database.CreateWebAuthnCredentialParams{
+		ID:           generateID("pk"),
+		UserID:       user.ID,
+		TenantID:     tenantID,
+		CredentialID: cred.ID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    int64(cred.SignCount),
+		Transports:   transports,
+		Aaguid:       cred.AAGUID,
+		Name:         req.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store passkey: %w", err)
+	}
+
+	slog.Info("Passkey registered", "user_id", user.ID, "tenant_id", tenantID, "passkey_id", stored.ID)
+
+	return toPasskeyResponse(stored), nil
+}
+
+// ListPasskeys 列出当前用户的Passkey
+func (s *Service) ListPasskeys(ctx context.Context, tenantID, userID string) ([]*PasskeyResponse, error) {
+	if _, err := s.currentUser(ctx, tenantID, userID); err != nil {
+		return nil, err
+	}
+	creds, err := s.db.ListWebAuthnCredentialsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	responses := make([]*PasskeyResponse, 0, len(creds))
+	for _, c := range creds {
+		responses = append(responses, toPasskeyResponse(c))
+	}
+	return responses, nil
+}
+
+// DeletePasskey 删除当前用户的Passkey
+func (s *Service) DeletePasskey(ctx context.Context, tenantID, userID, passkeyID string) error {
+	if _, err := s.currentUser(ctx, tenantID, userID); err != nil {
+		return err
+	}
+	n, err := s.db.DeleteWebAuthnCredential(ctx, database.DeleteWebAuthnCredentialParams{
+		ID:     passkeyID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	if n == 0 {
+		return ErrPasskeyNotFound
+	}
+	slog.Info("Passkey deleted", "user_id", userID, "tenant_id", tenantID, "passkey_id", passkeyID)
+	return nil
+}
+
+// decoyCredential 不存在或未注册Passkey的账号使用的诱饵凭证，按邮箱确定性生成，
+// 同一邮箱每次返回相同的凭证ID，使登录选项与真实账号无法区分
+func (s *Service) decoyCredential(tenantID, email string) webauthn.CredentialDescriptor {
+	mac := hmac.New(sha256.New, s.decoySecret)
+	mac.Write([]byte(tenantID + ":" + strings.ToLower(email)))
+	return webauthn.CredentialDescriptor{Type: "public-key", ID: webauthn.EncodeBase64URL(mac.Sum(nil))}
+}
+
+// BeginPasskeyLogin 开始Passkey登录，返回断言选项。
+// 用户不存在或未注册Passkey时返回诱饵凭证且不保存挑战，完成阶段统一失败。
+// 每次请求单独保存挑战，不会使同一用户进行中的其他登录失效；
+// 接口无需认证，有效期内的挑战数达到上限后不再保存，返回的选项无法完成登录
+func (s *Service) BeginPasskeyLogin(ctx context.Context, tenantID string, req PasskeyLoginBeginRequest) (*RequestOptionsResponse, error) {
+	rp, err := s.relyingParty(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	var allow []webauthn.CredentialDescriptor
+	user, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
+		TenantID: tenantID,
+		Email:    req.Email,
+	})
+	switch {
+	case err == nil:
+		if allow, err = s.credentialDescriptors(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		if len(allow) > 0 {
+			if err := s.addLoginChallenge(ctx, user, challenge); err != nil {
+				return nil, err
+			}
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if len(allow) == 0 {
+		allow = []webauthn.CredentialDescriptor{s.decoyCredential(tenantID, req.Email)}
+	}
+
+	return &RequestOptionsResponse{PublicKey: rp.NewRequestOptions(challenge, allow)}, nil
+}
+
+// FinishPasskeyLogin 校验断言后按与密码登录相同的流程签发令牌。
+// 断言要求用户验证（UV），因此本身即满足多因素要求
+func (s *Service) FinishPasskeyLogin(ctx context.Context, tenantID string, req PasskeyLoginFinishRequest, clientIP, userAgent string) (*LoginResponse, error) {
+	rp, err := s.relyingParty(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, challenge, err := s.consumeChallenge(ctx, tenantID, purposeWebAuthnLogin, req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID, err := webauthn.DecodeBase64URL(req.Credential.ID)
+	if err != nil {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+	cred, err := s.db.GetWebAuthnCredentialByCredentialID(ctx, database.GetWebAuthnCredentialByCredentialIDParams{
+		TenantID:     tenantID,
+		CredentialID: credentialID,
+	})
+	if err != nil || cred.UserID != userID {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	signCount, err := rp.VerifyAssertion(challenge, &req.Credential, cred.PublicKey, uint32(cred.SignCount))
+	if err != nil {
+		slog.Warn("Passkey assertion rejected", "user_id", userID, "tenant_id", tenantID, "passkey_id", cred.ID, "error", err)
+		return nil, ErrInvalidWebAuthnResponse
+	}
+	if err := s.db.UpdateWebAuthnCredentialSignCount(ctx, database.UpdateWebAuthnCredentialSignCountParams{
+		ID:        cred.ID,
+		SignCount: int64(signCount),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to update passkey: %w", err)
+	}
+
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if _, err := s.checkLoginPolicy(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user, []string{amrHardwareKey, amrMFA}, clientIP, userAgent)
+}
@@ -0,0 +1,217 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"yuyu-test/internal/webauthn"
+	"yuyu-test/internal/webauthn/webauthntest"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://login.example.com"
+)
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{"webauthn_rp_id": "example.com", "webauthn_origins": ["https://login.example.com"]}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "erin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	authenticator, err := webauthntest.New()
+	if err != nil {
+		t.Fatalf("webauthntest.New: %v", err)
+	}
+
+	creation, err := svc.BeginPasskeyRegistration(ctx, "tnt_test", registered.ID)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	if creation.PublicKey.RP.ID != testRPID || creation.PublicKey.RP.Name != "Test Tenant" {
+		t.Fatalf("unexpected rp %+v", creation.PublicKey.RP)
+	}
+	passkey, err := svc.FinishPasskeyRegistration(ctx, "tnt_test", registered.ID, PasskeyRegistrationRequest{
+		Name:       "laptop",
+		Credential: *authenticator.Register(testRPID, testOrigin, creation.PublicKey.Challenge),
+	})
+	if err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+	if passkey.Name != "laptop" || len(store.passkeys) != 1 {
+		t.Fatalf("expected passkey to be stored, got %+v", passkey)
+	}
+
+	request, err := svc.BeginPasskeyLogin(ctx, "tnt_test", PasskeyLoginBeginRequest{Email: "erin@example.com"})
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	if len(request.PublicKey.AllowCredentials) != 1 || request.PublicKey.AllowCredentials[0].ID != webauthn.EncodeBase64URL(authenticator.CredentialID) {
+		t.Fatalf("expected the registered credential to be allowed, got %+v", request.PublicKey.AllowCredentials)
+	}
+
+	assertion := authenticator.Login(testRPID, testOrigin, request.PublicKey.Challenge, []byte(registered.ID))
+	resp, err := svc.FinishPasskeyLogin(ctx, "tnt_test", PasskeyLoginFinishRequest{Credential: *assertion}, "", "")
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("expected tokens, got %+v", resp)
+	}
+	if amr := accessTokenAMR(t, svc, resp.Token); !slices.Equal(amr, []string{"hwk", "mfa"}) {
+		t.Fatalf("unexpected amr %v", amr)
+	}
+
+	// 挑战只能使用一次
+	if _, err := svc.FinishPasskeyLogin(ctx, "tnt_test", PasskeyLoginFinishRequest{Credential: *assertion}, "", ""); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Fatalf("expected replayed assertion to be rejected, got %v", err)
+	}
+}
+
+func TestPasskeyLoginRejectsOtherUsersCredential(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{"webauthn_rp_id": "example.com", "webauthn_origins": ["https://login.example.com"]}`)
+
+	alice, _ := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "alice@example.com", Password: "password123"})
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "mallory@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	authenticator, _ := webauthntest.New()
+	creation, err := svc.BeginPasskeyRegistration(ctx, "tnt_test", alice.ID)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	if _, err := svc.FinishPasskeyRegistration(ctx, "tnt_test", alice.ID, PasskeyRegistrationRequest{
+		Credential: *authenticator.Register(testRPID, testOrigin, creation.PublicKey.Challenge),
+	}); err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+
+	// 为 mallory 发起的挑战不能用 alice 的凭证完成
+	request, err := svc.BeginPasskeyLogin(ctx, "tnt_test", PasskeyLoginBeginRequest{Email: "mallory@example.com"})
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	assertion := authenticator.Login(testRPID, testOrigin, request.PublicKey.Challenge, []byte(alice.ID))
+	if _, err := svc.FinishPasskeyLogin(ctx, "tnt_test", PasskeyLoginFinishRequest{Credential: *assertion}, "", ""); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Fatalf("expected ErrInvalidWebAuthnResponse, got %v", err)
+	}
+}
+
+func TestPasskeyLoginDoesNotRevealAccounts(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{"webauthn_rp_id": "example.com", "webauthn_origins": ["https://login.example.com"]}`)
+
+	frank, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "frank@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "grace@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	authenticator, _ := webauthntest.New()
+	creation, err := svc.BeginPasskeyRegistration(ctx, "tnt_test", frank.ID)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	if _, err := svc.FinishPasskeyRegistration(ctx, "tnt_test", frank.ID, PasskeyRegistrationRequest{
+		Credential: *authenticator.Register(testRPID, testOrigin, creation.PublicKey.Challenge),
+	}); err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+
+	begin := func(email string) *webauthn.RequestOptions {
+		t.Helper()
+		request, err := svc.BeginPasskeyLogin(ctx, "tnt_test", PasskeyLoginBeginRequest{Email: email})
+		if err != nil {
+			t.Fatalf("BeginPasskeyLogin: %v", err)
+		}
+		return request.PublicKey
+	}
+
+	// 不存在的账号和未注册Passkey的账号返回稳定的诱饵凭证，与真实账号的选项形式相同
+	for _, email := range []string{"nobody@example.com", "grace@example.com"} {
+		first, second := begin(email), begin(email)
+		if len(first.AllowCredentials) != 1 || first.AllowCredentials[0].ID == "" || first.AllowCredentials[0].ID != second.AllowCredentials[0].ID {
+			t.Fatalf("%s: expected one stable decoy credential, got %+v and %+v", email, first.AllowCredentials, second.AllowCredentials)
+		}
+	}
+	if begin("nobody@example.com").AllowCredentials[0].ID == begin("grace@example.com").AllowCredentials[0].ID {
+		t.Fatal("expected decoy credentials to differ per email")
+	}
+
+	// 他人为同一邮箱发起登录不会使进行中的挑战失效
+	pending := begin("frank@example.com")
+	begin("frank@example.com")
+	assertion := authenticator.Login(testRPID, testOrigin, pending.Challenge, []byte(frank.ID))
+	if _, err := svc.FinishPasskeyLogin(ctx, "tnt_test", PasskeyLoginFinishRequest{Credential: *assertion}, "", ""); err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+}
+
+func TestPasskeyLoginChallengesAreCapped(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{"webauthn_rp_id": "example.com", "webauthn_origins": ["https://login.example.com"]}`)
+
+	heidi, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "heidi@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	ivan, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ivan@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	authenticator, _ := webauthntest.New()
+	creation, err := svc.BeginPasskeyRegistration(ctx, "tnt_test", heidi.ID)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	if _, err := svc.FinishPasskeyRegistration(ctx, "tnt_test", heidi.ID, PasskeyRegistrationRequest{
+		Credential: *authenticator.Register(testRPID, testOrigin, creation.PublicKey.Challenge),
+	}); err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+
+	var last *webauthn.RequestOptions
+	for range webAuthnLoginChallengeLimit + 5 {
+		for _, email := range []string{"heidi@example.com", "ivan@example.com"} {
+			request, err := svc.BeginPasskeyLogin(ctx, "tnt_test", PasskeyLoginBeginRequest{Email: email})
+			if err != nil {
+				t.Fatalf("BeginPasskeyLogin: %v", err)
+			}
+			if email == "heidi@example.com" {
+				last = request.PublicKey
+			}
+		}
+	}
+
+	// 未注册Passkey的账号不保存挑战，已注册的账号保存数不超过上限
+	stored := map[string]int{}
+	for _, token := range store.actionTokens {
+		if token.Purpose == purposeWebAuthnLogin {
+			stored[token.UserID]++
+		}
+	}
+	if stored[heidi.ID] != webAuthnLoginChallengeLimit || stored[ivan.ID] != 0 {
+		t.Fatalf("expected %d challenges for heidi and none for ivan, got %d and %d", webAuthnLoginChallengeLimit, stored[heidi.ID], stored[ivan.ID])
+	}
+	// 超出上限后返回的挑战无法完成登录
+	assertion := authenticator.Login(testRPID, testOrigin, last.Challenge, []byte(heidi.ID))
+	if _, err := svc.FinishPasskeyLogin(ctx, "tnt_test", PasskeyLoginFinishRequest{Credential: *assertion}, "", ""); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Fatalf("expected ErrInvalidWebAuthnResponse, got %v", err)
+	}
+}
+
+func TestPasskeyRequiresTenantConfiguration(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+	if _, err := svc.BeginPasskeyLogin(ctx, "tnt_test", PasskeyLoginBeginRequest{Email: "x@example.com"}); !errors.Is(err, ErrWebAuthnNotConfigured) {
+		t.Fatalf("expected ErrWebAuthnNotConfigured, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"yuyu-test/internal/mailer"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+)
+
+const (
+	// purposePasswordReset 密码重置令牌用途
+	purposePasswordReset = "password_reset"
+	// passwordResetTTL 密码重置令牌有效期
+	passwordResetTTL = time.Hour
+)
+
+var (
+	// ErrInvalidResetToken 重置令牌无效、过期或已使用
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	// ErrPasswordResetRequired 管理员要求用户重置密码后才能登录
+	ErrPasswordResetRequired = errors.New("password reset required")
+	// ErrInvalidCurrentPassword 修改密码时当前密码错误
+	ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+)
+
+// ForgotPasswordRequest 忘记密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 使用重置令牌设置新密码
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePasswordRequest 已登录用户修改密码
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// sendPasswordResetEmail 生成一次性重置令牌（仅保存哈希）并发送重置邮件
+func (s *Service) sendPasswordResetEmail(ctx context.Context, user database.User) error {
+	settings, err := s.tenantSettings(ctx, user.TenantID)
+	if err != nil {
+		return err
+	}
+
+	token, err := generateRefreshToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	// 旧令牌作废，保证同一时间只有最新一封邮件有效
+	if err := s.db.InvalidateUserActionTokens(ctx, database.InvalidateUserActionTokensParams{
+		UserID:  user.ID,
+		Purpose: purposePasswordReset,
+	}); err != nil {
+		return fmt.Errorf("failed to invalidate old reset tokens: %w", err)
+	}
+	if err := s.db.CreateUserActionToken(ctx, database.CreateUserActionTokenParams{
+		UserID:    user.ID,
+		TenantID:  user.TenantID,
+		Purpose:   purposePasswordReset,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}); err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	body := "Use the following token to reset your password:\n\n" + token + "\n"
+	if link, ok := tokenLink(settings.PasswordResetURL, token); ok {
+		body = "Reset your password by opening the following link:\n\n" + link + "\n"
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	})
+}
+
+// setPassword 更新密码并撤销该用户的全部refresh token，调用方需先按 policy 校验新密码
+func (s *Service) setPassword(ctx context.Context, user database.User, policy tenant.PasswordPolicy, newPassword string) (database.User, error) {
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	updated, err := s.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		TenantID:       user.TenantID,
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	})
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to update password: %w", err)
+	}
+	if err := s.recordPasswordHistory(ctx, policy, user); err != nil {
+		return database.User{}, err
+	}
+
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		return database.User{}, err
+	}
+
+	slog.Info("User password changed", "user_id", user.ID, "tenant_id", user.TenantID)
+
+	return updated, nil
+}
+
+// checkPassword 校验密码是否与哈希匹配
+func (s *Service) checkPassword(password, hash string) bool {
+	match, _ := s.hasher.Verify(password, hash)
+	return match
+}
+
+// ForgotPassword 发送密码重置邮件。
+// 用户不存在时静默返回，避免泄露账号是否存在
+func (s *Service) ForgotPassword(ctx context.Context, tenantID string, req ForgotPasswordRequest) error {
+	user, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
+		TenantID: tenantID,
+		Email:    req.Email,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	return s.sendPasswordResetEmail(ctx, user)
+}
+
+// ResetPassword 校验重置令牌并设置新密码。
+// 新密码不符合策略时不消费令牌，用户可以换一个密码重试
+func (s *Service) ResetPassword(ctx context.Context, tenantID string, req ResetPasswordRequest) error {
+	token, err := s.db.GetActiveUserActionToken(ctx, database.GetActiveUserActionTokenParams{
+		TokenHash: hashToken(req.Token),
+		Purpose:   purposePasswordReset,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("failed to get reset token: %w", err)
+	}
+	if token.TenantID != tenantID {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.db.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	settings, err := s.tenantSettings(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if err := s.validatePassword(ctx, settings.PasswordPolicy, req.NewPassword, &user); err != nil {
+		return err
+	}
+
+	// 原子地消费令牌，保证一次性
+	if _, err := s.db.ConsumeUserActionToken(ctx, database.ConsumeUserActionTokenParams{
+		TokenHash: token.TokenHash,
+		Purpose:   purposePasswordReset,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("failed to consume reset token: %w", err)
+	}
+
+	_, err = s.setPassword(ctx, user, settings.PasswordPolicy, req.NewPassword)
+	return err
+}
+
+// ChangePassword 已登录用户校验当前密码后修改密码
+func (s *Service) ChangePassword(ctx context.Context, tenantID, userID string, req ChangePasswordRequest) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if !user.HashedPassword.Valid || !s.checkPassword(req.CurrentPassword, user.HashedPassword.String) {
+		return ErrInvalidCurrentPassword
+	}
+
+	settings, err := s.tenantSettings(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if err := s.validatePassword(ctx, settings.PasswordPolicy, req.NewPassword, &user); err != nil {
+		return err
+	}
+
+	_, err = s.setPassword(ctx, user, settings.PasswordPolicy, req.NewPassword)
+	return err
+}
+
+// ForcePasswordReset 管理员强制用户重置密码：
+// 标记必须重置、撤销全部refresh token并发送重置邮件
+func (s *Service) ForcePasswordReset(ctx context.Context, tenantID, userID string) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+
+	user, err = s.db.SetUserPasswordResetRequired(ctx, database.SetUserPasswordResetRequiredParams{
+		ID:       user.ID,
+		TenantID: tenantID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark password reset required: %w", err)
+	}
+
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		return err
+	}
+
+	slog.Info("Password reset forced", "user_id", user.ID, "tenant_id", tenantID)
+
+	return s.sendPasswordResetEmail(ctx, user)
+}
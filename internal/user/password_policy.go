@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+)
+
+var (
+	// ErrPasswordPolicy 密码不符合租户密码策略，具体违规项见 *PasswordPolicyError
+	ErrPasswordPolicy = errors.New("password does not meet the password policy")
+	// ErrPasswordExpired 密码超过租户规定的有效期，需重置密码后才能使用密码登录
+	ErrPasswordExpired = errors.New("password expired")
+)
+
+// 密码策略违规代码
+const (
+	PasswordViolationMinLength = "min_length"
+	PasswordViolationMaxLength = "max_length"
+	PasswordViolationUppercase = "uppercase"
+	PasswordViolationLowercase = "lowercase"
+	PasswordViolationDigit     = "digit"
+	PasswordViolationSymbol    = "symbol"
+	PasswordViolationReused    = "reused"
+	PasswordViolationBreached  = "breached"
+)
+
+// PasswordViolation 一条密码策略违规
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError 密码不符合策略，errors.Is(err, ErrPasswordPolicy) 为true
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
+	}
+	return ErrPasswordPolicy.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *PasswordPolicyError) Is(target error) bool { return target == ErrPasswordPolicy }
+
+// passwordLengthLimits 策略的有效长度范围
+func passwordLengthLimits(policy tenant.PasswordPolicy) (int, int) {
+	minLength, maxLength := policy.MinLength, policy.MaxLength
+	if minLength <= 0 {
+		minLength = tenant.DefaultPasswordMinLength
+	}
+	if maxLength <= 0 || maxLength > tenant.DefaultPasswordMaxLength {
+		maxLength = tenant.DefaultPasswordMaxLength
+	}
+	return minLength, maxLength
+}
+
+// checkPasswordRules 校验长度和字符类别，不涉及存储。maxBytes 为哈希算法的字节上限，0表示不限制
+func checkPasswordRules(policy tenant.PasswordPolicy, password string, maxBytes int) []PasswordViolation {
+	var violations []PasswordViolation
+	minLength, maxLength := passwordLengthLimits(policy)
+	length := utf8.RuneCountInString(password)
+	if length < minLength {
+		violations = append(violations, PasswordViolation{PasswordViolationMinLength, fmt.Sprintf("password must be at least %d characters", minLength)})
+	}
+	if length > maxLength || (maxBytes > 0 && len(password) > maxBytes) {
+		violations = append(violations, PasswordViolation{PasswordViolationMaxLength, fmt.Sprintf("password must be at most %d characters", maxLength)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if policy.RequireUppercase && !upper {
+		violations = append(violations, PasswordViolation{PasswordViolationUppercase, "password must contain an uppercase letter"})
+	}
+	if policy.RequireLowercase && !lower {
+		violations = append(violations, PasswordViolation{PasswordViolationLowercase, "password must contain a lowercase letter"})
+	}
+	if policy.RequireDigit && !digit {
+		violations = append(violations, PasswordViolation{PasswordViolationDigit, "password must contain a digit"})
+	}
+	if policy.RequireSymbol && !symbol {
+		violations = append(violations, PasswordViolation{PasswordViolationSymbol, "password must contain a symbol"})
+	}
+	return violations
+}
+
+// validatePassword 按租户策略校验新密码。user为nil表示新建用户，不检查历史密码
+func (s *Service) validatePassword(ctx context.Context, policy tenant.PasswordPolicy, password string, user *database.User) error {
+	violations := checkPasswordRules(policy, password, s.hasher.MaxPasswordBytes())
+
+	if s.breached != nil && !policy.AllowBreached {
+		breached, err := s.breached.IsBreached(password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{PasswordViolationBreached, "password has appeared in a data breach"})
+		}
+	}
+
+	if user != nil && policy.HistoryCount > 0 {
+		reused, err := s.passwordReused(ctx, *user, password, policy.HistoryCount)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, PasswordViolation{PasswordViolationReused, fmt.Sprintf("password must differ from the last %d passwords", policy.HistoryCount)})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// passwordReused 新密码是否与当前密码或最近 count-1 个历史密码相同
+func (s *Service) passwordReused(ctx context.Context, user database.User, password string, count int) (bool, error) {
+	if user.HashedPassword.Valid && s.checkPassword(password, user.HashedPassword.String) {
+		return true, nil
+	}
+	if count <= 1 {
+		return false, nil
+	}
+	history, err := s.db.ListPasswordHistory(ctx, database.ListPasswordHistoryParams{
+		UserID: user.ID,
+		Limit:  int32(count - 1),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list password history: %w", err)
+	}
+	for _, h := range history {
+		if s.checkPassword(password, h.HashedPassword) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordPasswordHistory 保存被替换的旧密码，只保留策略需要的条数
+func (s *Service) recordPasswordHistory(ctx context.Context, policy tenant.PasswordPolicy, user database.User) error {
+	if policy.HistoryCount <= 1 || !user.HashedPassword.Valid {
+		return nil
+	}
+	if err := s.db.CreatePasswordHistory(ctx, database.CreatePasswordHistoryParams{
+		UserID:         user.ID,
+		HashedPassword: user.HashedPassword.String,
+	}); err != nil {
+		return fmt.Errorf("failed to record password history: %w", err)
+	}
+	if err := s.db.PrunePasswordHistory(ctx, database.PrunePasswordHistoryParams{
+		UserID: user.ID,
+		Limit:  int32(policy.HistoryCount - 1),
+	}); err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+	return nil
+}
+
+// passwordExpired 密码是否超过策略规定的有效期，从未修改过的按创建时间计算
+func passwordExpired(policy tenant.PasswordPolicy, user database.User) bool {
+	if policy.MaxAgeDays <= 0 {
+		return false
+	}
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt.Valid {
+		changedAt = user.PasswordChangedAt.Time
+	}
+	return time.Since(changedAt) > time.Duration(policy.MaxAgeDays)*24*time.Hour
+}
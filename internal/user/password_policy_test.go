@@ -0,0 +1,124 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// breachedSet 测试用泄露密码库
+type breachedSet []string
+
+func (b breachedSet) IsBreached(password string) (bool, error) {
+	return slices.Contains(b, password), nil
+}
+
+func violationCodes(t *testing.T, err error) []string {
+	t.Helper()
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) || !errors.Is(err, ErrPasswordPolicy) {
+		t.Fatalf("expected *PasswordPolicyError, got %v", err)
+	}
+	codes := make([]string, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		codes[i] = v.Code
+	}
+	return codes
+}
+
+func TestRegisterEnforcesPasswordPolicy(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{"password_policy": {"min_length": 10, "require_uppercase": true, "require_digit": true, "require_symbol": true}}`)
+	svc.breached = breachedSet{"Password123!"}
+
+	_, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "mia@example.com", Password: "short"})
+	want := []string{PasswordViolationMinLength, PasswordViolationUppercase, PasswordViolationDigit, PasswordViolationSymbol}
+	if codes := violationCodes(t, err); !slices.Equal(codes, want) {
+		t.Fatalf("violations = %v, want %v", codes, want)
+	}
+
+	_, err = svc.Register(ctx, "tnt_test", RegisterRequest{Email: "mia@example.com", Password: "Password123!"})
+	if codes := violationCodes(t, err); !slices.Equal(codes, []string{PasswordViolationBreached}) {
+		t.Fatalf("expected breached violation, got %v", codes)
+	}
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "mia@example.com", Password: "Tr0ub4dor&3x"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// 超过默认最大长度128
+	long := "Aa1!" + strings.Repeat("x", 130)
+	_, err = svc.Register(ctx, "tnt_test", RegisterRequest{Email: "max@example.com", Password: long})
+	if codes := violationCodes(t, err); !slices.Equal(codes, []string{PasswordViolationMaxLength}) {
+		t.Fatalf("expected max_length violation, got %v", codes)
+	}
+}
+
+func TestPasswordHistoryAndReset(t *testing.T) {
+	ctx := context.Background()
+	svc, store, mail := newTestService(t, `{"password_policy": {"history_count": 3}}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ned@example.com", Password: "password-a"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	change := func(current, next string) error {
+		return svc.ChangePassword(ctx, "tnt_test", registered.ID, ChangePasswordRequest{CurrentPassword: current, NewPassword: next})
+	}
+	if err := change("password-a", "password-b"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if err := change("password-b", "password-c"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	for _, reused := range []string{"password-a", "password-b", "password-c"} {
+		if codes := violationCodes(t, change("password-c", reused)); !slices.Equal(codes, []string{PasswordViolationReused}) {
+			t.Fatalf("%s: expected reused violation, got %v", reused, codes)
+		}
+	}
+	if err := change("password-c", "password-d"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if len(store.history) != 2 {
+		t.Fatalf("expected history pruned to 2 entries, got %d", len(store.history))
+	}
+
+	// 重置密码同样检查历史，不符合策略时令牌不被消费
+	if err := svc.ForgotPassword(ctx, "tnt_test", ForgotPasswordRequest{Email: "ned@example.com"}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	token := lastToken(t, mail)
+	err = svc.ResetPassword(ctx, "tnt_test", ResetPasswordRequest{Token: token, NewPassword: "password-c"})
+	if codes := violationCodes(t, err); !slices.Equal(codes, []string{PasswordViolationReused}) {
+		t.Fatalf("expected reused violation on reset, got %v", codes)
+	}
+	// 超出历史范围的旧密码可以再次使用
+	if err := svc.ResetPassword(ctx, "tnt_test", ResetPasswordRequest{Token: token, NewPassword: "password-a"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+}
+
+func TestLoginRejectsExpiredPassword(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{"password_policy": {"max_age_days": 90}}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "olga@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := LoginRequest{Email: "olga@example.com", Password: "password123"}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	u := store.users[registered.ID]
+	u.PasswordChangedAt = sql.NullTime{Time: time.Now().AddDate(0, 0, -91), Valid: true}
+	store.users[registered.ID] = u
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); !errors.Is(err, ErrPasswordExpired) {
+		t.Fatalf("expected ErrPasswordExpired, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/revocation"
+)
+
+func TestForgotAndResetPassword(t *testing.T) {
+	ctx := context.Background()
+	svc, store, mail := newTestService(t, `{"password_policy": {"min_length": 10}}`)
+	revocations := svc.revoker.(*revocation.Store)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "olivia@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "olivia@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	mail.messages = nil
+
+	// 未知邮箱静默成功，不发送邮件
+	if err := svc.ForgotPassword(ctx, "tnt_test", ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	if len(mail.messages) != 0 {
+		t.Fatalf("expected no email for an unknown address, got %+v", mail.messages)
+	}
+
+	// 再次申请后只有最新的令牌有效
+	if err := svc.ForgotPassword(ctx, "tnt_test", ForgotPasswordRequest{Email: "olivia@example.com"}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	first := lastToken(t, mail)
+	if err := svc.ForgotPassword(ctx, "tnt_test", ForgotPasswordRequest{Email: "olivia@example.com"}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	token := lastToken(t, mail)
+	if err := svc.ResetPassword(ctx, "tnt_test", ResetPasswordRequest{Token: first, NewPassword: "new-password-1"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected the superseded token to be rejected, got %v", err)
+	}
+
+	// 令牌只能在签发它的租户使用
+	if err := svc.ResetPassword(ctx, "tnt_other", ResetPasswordRequest{Token: token, NewPassword: "new-password-1"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected ErrInvalidResetToken for another tenant, got %v", err)
+	}
+	// 新密码不符合策略时令牌不被消费
+	if err := svc.ResetPassword(ctx, "tnt_test", ResetPasswordRequest{Token: token, NewPassword: "short"}); !errors.Is(err, ErrPasswordPolicy) {
+		t.Fatalf("expected ErrPasswordPolicy, got %v", err)
+	}
+	if err := svc.ResetPassword(ctx, "tnt_test", ResetPasswordRequest{Token: token, NewPassword: "new-password-1"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if err := svc.ResetPassword(ctx, "tnt_test", ResetPasswordRequest{Token: token, NewPassword: "new-password-2"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected ErrInvalidResetToken on reuse, got %v", err)
+	}
+
+	// 重置后旧密码失效，此前签发的令牌全部吊销
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "olivia@example.com", Password: "password123"}, "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected the old password to be rejected, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "olivia@example.com", Password: "new-password-1"}, "", ""); err != nil {
+		t.Fatalf("Login with new password: %v", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", login.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected the refresh token issued before the reset to be revoked, got %v", err)
+	}
+	if !revocations.IsRevoked(accessTokenClaims(t, svc, login.Token)) {
+		t.Fatal("expected the access token issued before the reset to be revoked")
+	}
+	if len(store.refresh) != 1 {
+		t.Fatalf("expected only the refresh token of the new login, got %d", len(store.refresh))
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "pablo@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "pablo@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	err = svc.ChangePassword(ctx, "tnt_test", registered.ID, ChangePasswordRequest{CurrentPassword: "wrong-password", NewPassword: "newpassword456"})
+	if !errors.Is(err, ErrInvalidCurrentPassword) {
+		t.Fatalf("expected ErrInvalidCurrentPassword, got %v", err)
+	}
+	// 当前密码错误时不影响已有会话
+	refreshed, err := svc.RefreshTokens(ctx, "tnt_test", login.RefreshToken, "", "")
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if err := svc.ChangePassword(ctx, "tnt_other", registered.ID, ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword456"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound for another tenant, got %v", err)
+	}
+
+	if err := svc.ChangePassword(ctx, "tnt_test", registered.ID, ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword456"}); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", refreshed.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected refresh tokens to be revoked on password change, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "pablo@example.com", Password: "newpassword456"}, "", ""); err != nil {
+		t.Fatalf("Login with new password: %v", err)
+	}
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{}`)
+	revocations := svc.revoker.(*revocation.Store)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "quinn@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "quinn@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	mail.messages = nil
+
+	if err := svc.ForcePasswordReset(ctx, "tnt_other", registered.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound for another tenant, got %v", err)
+	}
+	if err := svc.ForcePasswordReset(ctx, "tnt_test", registered.ID); err != nil {
+		t.Fatalf("ForcePasswordReset: %v", err)
+	}
+	if len(mail.messages) != 1 || mail.messages[0].To != "quinn@example.com" {
+		t.Fatalf("expected one reset email to quinn, got %+v", mail.messages)
+	}
+
+	// 已有会话全部注销，重置前不能用原密码登录
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", login.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected the refresh token to be revoked, got %v", err)
+	}
+	if !revocations.IsRevoked(accessTokenClaims(t, svc, login.Token)) {
+		t.Fatal("expected the access token to be revoked")
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "quinn@example.com", Password: "password123"}, "", ""); !errors.Is(err, ErrPasswordResetRequired) {
+		t.Fatalf("expected ErrPasswordResetRequired, got %v", err)
+	}
+
+	if err := svc.ResetPassword(ctx, "tnt_test", ResetPasswordRequest{Token: lastToken(t, mail), NewPassword: "newpassword456"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "quinn@example.com", Password: "newpassword456"}, "", ""); err != nil {
+		t.Fatalf("Login after reset: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"yuyu-test/internal/mailer"
+	"yuyu-test/internal/store/database"
+)
+
+const (
+	// purposePasswordless 免密码登录令牌用途（魔法链接和验证码共用，新令牌使旧令牌失效）
+	purposePasswordless = "passwordless"
+	// passwordlessTTL 魔法链接和验证码有效期
+	passwordlessTTL = 10 * time.Minute
+	// passwordlessSendLimit 单个用户在 passwordlessSendWindow 内最多发送的次数
+	passwordlessSendLimit = 5
+	// passwordlessSendWindow 发送频率限制的时间窗口
+	passwordlessSendWindow = 15 * time.Minute
+	// passwordlessMaxAttempts 单个验证码允许的最大校验失败次数
+	passwordlessMaxAttempts = 5
+
+	// PasswordlessMethodLink 通过邮件发送魔法链接
+	PasswordlessMethodLink = "link"
+	// PasswordlessMethodCode 通过邮件发送6位验证码
+	PasswordlessMethodCode = "code"
+
+	// amrEmail 通过邮箱中的一次性令牌认证
+	amrEmail = "email"
+)
+
+var (
+	// ErrPasswordlessDisabled 租户未开启免密码登录
+	ErrPasswordlessDisabled = errors.New("passwordless login is disabled for this tenant")
+	// ErrInvalidPasswordlessToken 魔法链接或验证码无效、过期或已使用
+	ErrInvalidPasswordlessToken = errors.New("invalid or expired passwordless token")
+)
+
+// PasswordlessStartRequest 发起免密码登录
+type PasswordlessStartRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Method string `json:"method" binding:"required,oneof=link code"`
+}
+
+// PasswordlessVerifyRequest 校验魔法链接令牌，或邮箱加验证码
+type PasswordlessVerifyRequest struct {
+	Token string `json:"token"`
+	Email string `json:"email" binding:"omitempty,email"`
+	Code  string `json:"code"`
+}
+
+// passwordlessCodeHash 验证码哈希。6位验证码取值空间小，
+// 加入用户ID和令牌过期时间（微秒，与数据库精度一致）避免不同令牌间哈希冲突
+func passwordlessCodeHash(userID string, expiresAt time.Time, code string) string {
+	return hashToken(fmt.Sprintf("%s:%d:%s", userID, expiresAt.UnixMicro(), code))
+}
+
+// generateEmailCode 生成6位数字验证码
+func generateEmailCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// passwordlessEnabled 校验租户是否开启免密码登录
+func (s *Service) passwordlessEnabled(ctx context.Context, tenantID string) (string, error) {
+	settings, err := s.tenantSettings(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	if !settings.PasswordlessEnabled {
+		return "", ErrPasswordlessDisabled
+	}
+	return settings.PasswordlessURL, nil
+}
+
+// StartPasswordless 发送魔法链接或验证码。
+// 用户不存在或发送过于频繁时同样静默返回（不发送邮件），避免泄露账号是否存在
+func (s *Service) StartPasswordless(ctx context.Context, tenantID string, req PasswordlessStartRequest) error {
+	linkURL, err := s.passwordlessEnabled(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
+		TenantID: tenantID,
+		Email:    req.Email,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	sent, err := s.db.CountRecentUserActionTokens(ctx, database.CountRecentUserActionTokensParams{
+		UserID:    user.ID,
+		Purpose:   purposePasswordless,
+		CreatedAt: time.Now().Add(-passwordlessSendWindow),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count recent tokens: %w", err)
+	}
+	if sent >= passwordlessSendLimit {
+		slog.Warn("Passwordless send rate limited", "user_id", user.ID, "tenant_id", tenantID)
+		return nil
+	}
+
+	expiresAt := time.Now().Add(passwordlessTTL).Truncate(time.Microsecond)
+	var tokenHash, body string
+	switch req.Method {
+	case PasswordlessMethodCode:
+		code, err := generateEmailCode()
+		if err != nil {
+			return fmt.Errorf("failed to generate code: %w", err)
+		}
+		tokenHash = passwordlessCodeHash(user.ID, expiresAt, code)
+		body = "Your sign-in code is:\n\n" + code + "\n"
+	default:
+		token, err := generateRefreshToken()
+		if err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		tokenHash = hashToken(token)
+		body = "Use the following token to sign in:\n\n" + token + "\n"
+		if link, ok := tokenLink(linkURL, token); ok {
+			body = "Sign in by opening the following link:\n\n" + link + "\n"
+		}
+	}
+
+	// 旧令牌作废，保证同一时间只有最新一封邮件有效
+	if err := s.db.InvalidateUserActionTokens(ctx, database.InvalidateUserActionTokensParams{
+		UserID:  user.ID,
+		Purpose: purposePasswordless,
+	}); err != nil {
+		return fmt.Errorf("failed to invalidate old tokens: %w", err)
+	}
+	if err := s.db.CreateUserActionToken(ctx, database.CreateUserActionTokenParams{
+		UserID:    user.ID,
+		TenantID:  tenantID,
+		Purpose:   purposePasswordless,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body:    body,
+	})
+}
+
+// consumePasswordlessCode 校验邮箱验证码，失败次数超限后令牌作废
+func (s *Service) consumePasswordlessCode(ctx context.Context, tenantID, email, code string) (database.User, error) {
+	user, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
+		TenantID: tenantID,
+		Email:    email,
+	})
+	if err != nil {
+		return database.User{}, ErrInvalidPasswordlessToken
+	}
+
+	token, err := s.db.GetLatestActiveUserActionToken(ctx, database.GetLatestActiveUserActionTokenParams{
+		UserID:  user.ID,
+		Purpose: purposePasswordless,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, ErrInvalidPasswordlessToken
+		}
+		return database.User{}, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	attempts, err := s.db.IncrementUserActionTokenAttempts(ctx, token.ID)
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to record attempt: %w", err)
+	}
+	if attempts > passwordlessMaxAttempts {
+		_ = s.db.InvalidateUserActionTokens(ctx, database.InvalidateUserActionTokensParams{
+			UserID:  user.ID,
+			Purpose: purposePasswordless,
+		})
+		slog.Warn("Passwordless code attempts exceeded", "user_id", user.ID, "tenant_id", tenantID)
+		return database.User{}, ErrInvalidPasswordlessToken
+	}
+
+	hash := passwordlessCodeHash(user.ID, token.ExpiresAt, code)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(token.TokenHash)) != 1 {
+		return database.User{}, ErrInvalidPasswordlessToken
+	}
+
+	// 原子地消费令牌，保证一次性
+	if _, err := s.db.ConsumeUserActionToken(ctx, database.ConsumeUserActionTokenParams{
+		TokenHash: token.TokenHash,
+		Purpose:   purposePasswordless,
+	}); err != nil {
+		return database.User{}, ErrInvalidPasswordlessToken
+	}
+	return user, nil
+}
+
+// consumePasswordlessLink 校验魔法链接令牌
+func (s *Service) consumePasswordlessLink(ctx context.Context, tenantID, rawToken string) (database.User, error) {
+	token, err := s.db.ConsumeUserActionToken(ctx, database.ConsumeUserActionTokenParams{
+		TokenHash: hashToken(rawToken),
+		Purpose:   purposePasswordless,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, ErrInvalidPasswordlessToken
+		}
+		return database.User{}, fmt.Errorf("failed to consume token: %w", err)
+	}
+	if token.TenantID != tenantID {
+		return database.User{}, ErrInvalidPasswordlessToken
+	}
+	user, err := s.db.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// VerifyPasswordless 校验魔法链接或验证码并签发令牌。
+// 用户已绑定TOTP或租户强制MFA时，与密码登录一样返回二次验证挑战
+func (s *Service) VerifyPasswordless(ctx context.Context, tenantID string, req PasswordlessVerifyRequest, clientIP, userAgent string) (*LoginResponse, error) {
+	if _, err := s.passwordlessEnabled(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	var user database.User
+	var err error
+	switch {
+	case req.Token != "":
+		user, err = s.consumePasswordlessLink(ctx, tenantID, req.Token)
+	case req.Email != "" && req.Code != "":
+		user, err = s.consumePasswordlessCode(ctx, tenantID, req.Email, req.Code)
+	default:
+		return nil, ErrInvalidPasswordlessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 能收到邮件即证明拥有该邮箱，按已验证校验登录条件，通过后才写入，
+	// 被禁用或锁定的账号不会因此改变验证状态
+	verified := user
+	verified.EmailVerified = true
+	settings, err := s.checkLoginPolicy(ctx, verified)
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified {
+		user, err = s.db.MarkUserEmailVerified(ctx, database.MarkUserEmailVerifiedParams{
+			ID:       user.ID,
+			TenantID: tenantID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to mark email verified: %w", err)
+		}
+	}
+	if challenge, err := s.mfaChallenge(ctx, user, settings, []string{amrEmail}); err != nil || challenge != nil {
+		return challenge, err
+	}
+
+	return s.completeLogin(ctx, user, []string{amrEmail}, clientIP, userAgent)
+}
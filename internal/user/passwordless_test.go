@@ -0,0 +1,168 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestPasswordlessCodeLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{"passwordless_enabled": true}`)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "frank@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	start := PasswordlessStartRequest{Email: "frank@example.com", Method: PasswordlessMethodCode}
+	if err := svc.StartPasswordless(ctx, "tnt_test", start); err != nil {
+		t.Fatalf("StartPasswordless: %v", err)
+	}
+	code := lastToken(t, mail)
+	if len(code) != 6 {
+		t.Fatalf("expected 6-digit code, got %q", code)
+	}
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if _, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Email: "frank@example.com", Code: wrong}, "", ""); !errors.Is(err, ErrInvalidPasswordlessToken) {
+		t.Fatalf("expected wrong code to be rejected, got %v", err)
+	}
+
+	resp, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Email: "frank@example.com", Code: code}, "", "")
+	if err != nil {
+		t.Fatalf("VerifyPasswordless: %v", err)
+	}
+	if !resp.User.EmailVerified || !slices.Equal(accessTokenAMR(t, svc, resp.Token), []string{"email"}) {
+		t.Fatalf("expected verified user and amr=[email], got %+v", resp)
+	}
+
+	// 验证码只能使用一次
+	if _, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Email: "frank@example.com", Code: code}, "", ""); !errors.Is(err, ErrInvalidPasswordlessToken) {
+		t.Fatalf("expected reused code to be rejected, got %v", err)
+	}
+}
+
+func TestPasswordlessVerifiesEmailOnlyAfterLoginPolicy(t *testing.T) {
+	ctx := context.Background()
+	svc, store, mail := newTestService(t, `{"passwordless_enabled": true, "require_email_verification": true}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ken@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	start := PasswordlessStartRequest{Email: "ken@example.com", Method: PasswordlessMethodCode}
+	if err := svc.StartPasswordless(ctx, "tnt_test", start); err != nil {
+		t.Fatalf("StartPasswordless: %v", err)
+	}
+	code := lastToken(t, mail)
+
+	// 被禁用的账号登录失败，邮箱保持未验证
+	disabled := StatusDisabled
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Status: &disabled}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Email: "ken@example.com", Code: code}, "", ""); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("expected ErrUserDisabled, got %v", err)
+	}
+	if store.users[registered.ID].EmailVerified {
+		t.Fatal("expected a rejected login not to mark the email verified")
+	}
+
+	// 租户要求邮箱验证时，免密登录本身即完成验证
+	active := StatusActive
+	if _, err := svc.UpdateUser(ctx, "tnt_test", registered.ID, UpdateUserRequest{Status: &active}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := svc.StartPasswordless(ctx, "tnt_test", start); err != nil {
+		t.Fatalf("StartPasswordless: %v", err)
+	}
+	resp, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Email: "ken@example.com", Code: lastToken(t, mail)}, "", "")
+	if err != nil {
+		t.Fatalf("VerifyPasswordless: %v", err)
+	}
+	if !resp.User.EmailVerified || !store.users[registered.ID].EmailVerified {
+		t.Fatalf("expected the email to be verified after login, got %+v", resp.User)
+	}
+}
+
+func TestPasswordlessCodeAttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{"passwordless_enabled": true}`)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "grace@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := svc.StartPasswordless(ctx, "tnt_test", PasswordlessStartRequest{Email: "grace@example.com", Method: PasswordlessMethodCode}); err != nil {
+		t.Fatalf("StartPasswordless: %v", err)
+	}
+	code := lastToken(t, mail)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < passwordlessMaxAttempts; i++ {
+		_, _ = svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Email: "grace@example.com", Code: wrong}, "", "")
+	}
+	// 超过尝试次数后，正确的验证码也失效
+	if _, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Email: "grace@example.com", Code: code}, "", ""); !errors.Is(err, ErrInvalidPasswordlessToken) {
+		t.Fatalf("expected code to be invalidated after too many attempts, got %v", err)
+	}
+}
+
+func TestPasswordlessLinkAndRateLimit(t *testing.T) {
+	ctx := context.Background()
+	svc, _, mail := newTestService(t, `{"passwordless_enabled": true, "passwordless_url": "https://app.example.com/magic"}`)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "heidi@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	start := PasswordlessStartRequest{Email: "heidi@example.com", Method: PasswordlessMethodLink}
+	if err := svc.StartPasswordless(ctx, "tnt_test", start); err != nil {
+		t.Fatalf("StartPasswordless: %v", err)
+	}
+	link, err := url.Parse(lastToken(t, mail))
+	if err != nil || link.Host != "app.example.com" || link.Query().Get("token") == "" {
+		t.Fatalf("unexpected magic link %q", lastToken(t, mail))
+	}
+	token := link.Query().Get("token")
+
+	if _, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Token: token}, "", ""); err != nil {
+		t.Fatalf("VerifyPasswordless: %v", err)
+	}
+	if _, err := svc.VerifyPasswordless(ctx, "tnt_test", PasswordlessVerifyRequest{Token: token}, "", ""); !errors.Is(err, ErrInvalidPasswordlessToken) {
+		t.Fatalf("expected reused link to be rejected, got %v", err)
+	}
+
+	for i := 1; i < passwordlessSendLimit; i++ {
+		if err := svc.StartPasswordless(ctx, "tnt_test", start); err != nil {
+			t.Fatalf("StartPasswordless #%d: %v", i+1, err)
+		}
+	}
+	// 超出频率限制时不再发送，但响应与成功相同，不能据此判断账号是否存在
+	sent := len(mail.messages)
+	if err := svc.StartPasswordless(ctx, "tnt_test", start); err != nil {
+		t.Fatalf("expected a throttled request to look like success, got %v", err)
+	}
+	if len(mail.messages) != sent {
+		t.Fatal("expected no email once the send limit is reached")
+	}
+	if err := svc.StartPasswordless(ctx, "tnt_test", PasswordlessStartRequest{Email: "nobody@example.com", Method: PasswordlessMethodLink}); err != nil {
+		t.Fatalf("StartPasswordless for an unknown email: %v", err)
+	}
+}
+
+func TestPasswordlessDisabledByDefault(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+	err := svc.StartPasswordless(ctx, "tnt_test", PasswordlessStartRequest{Email: "x@example.com", Method: PasswordlessMethodLink})
+	if !errors.Is(err, ErrPasswordlessDisabled) {
+		t.Fatalf("expected ErrPasswordlessDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"yuyu-test/internal/profileschema"
+	"yuyu-test/internal/store/database"
+)
+
+// dryRunPageSize 试运行时每次读取的用户数
+const dryRunPageSize = 500
+
+var (
+	// ErrProfileSchemaNotFound 租户未发布资料 schema，或指定版本不存在
+	ErrProfileSchemaNotFound = errors.New("profile schema not found")
+	// ErrProfileInvalid 资料不符合租户的资料 schema，具体违规项见 *ProfileValidationError
+	ErrProfileInvalid = errors.New("profile does not match the profile schema")
+)
+
+// ProfileValidationError 资料校验失败，errors.Is(err, ErrProfileInvalid) 为true
+type ProfileValidationError struct {
+	SchemaVersion int32
+	Violations    []profileschema.Violation
+}
+
+func (e *ProfileValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Field + " " + v.Message
+	}
+	return ErrProfileInvalid.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ProfileValidationError) Is(target error) bool { return target == ErrProfileInvalid }
+
+// ProfileSchemaResponse 资料 schema 版本
+type ProfileSchemaResponse struct {
+	TenantID  string          `json:"tenant_id"`
+	Version   int32           `json:"version"`
+	Schema    json.RawMessage `json:"schema"`
+	CreatedAt string          `json:"created_at"`
+}
+
+func toProfileSchemaResponse(s database.TenantProfileSchema) *ProfileSchemaResponse {
+	return &ProfileSchemaResponse{
+		TenantID:  s.TenantID,
+		Version:   s.Version,
+		Schema:    s.Schema,
+		CreatedAt: s.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// ProfileSchemaDryRunResponse 试运行结果
+type ProfileSchemaDryRunResponse struct {
+	// Checked 检查的用户数（不含已删除用户）
+	Checked int `json:"checked"`
+	// Failed 不符合 schema 的用户数
+	Failed int `json:"failed"`
+	// Users 不符合 schema 的用户，最多返回 limit 个
+	Users []*ProfileSchemaDryRunUser `json:"users"`
+}
+
+// ProfileSchemaDryRunUser 一个不符合 schema 的用户
+type ProfileSchemaDryRunUser struct {
+	ID         string                    `json:"id"`
+	Email      string                    `json:"email"`
+	Violations []profileschema.Violation `json:"violations"`
+}
+
+// GetProfileSchema 获取租户的资料 schema，version 为0时返回当前生效的最新版本
+func (s *Service) GetProfileSchema(ctx context.Context, tenantID string, version int32) (*ProfileSchemaResponse, error) {
+	var (
+		schema database.TenantProfileSchema
+		err    error
+	)
+	if version == 0 {
+		schema, err = s.db.GetLatestProfileSchema(ctx, tenantID)
+	} else {
+		schema, err = s.db.GetProfileSchema(ctx, database.GetProfileSchemaParams{TenantID: tenantID, Version: version})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProfileSchemaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile schema: %w", err)
+	}
+	return toProfileSchemaResponse(schema), nil
+}
+
+// PublishProfileSchema 发布新版本的资料 schema，立即对之后的注册和资料修改生效，
+// 已有用户的资料不受影响，可先用 DryRunProfileSchema 检查
+func (s *Service) PublishProfileSchema(ctx context.Context, tenantID string, raw json.RawMessage) (*ProfileSchemaResponse, error) {
+	if _, err := profileschema.Parse(raw); err != nil {
+		return nil, err
+	}
+	schema, err := s.db.CreateProfileSchema(ctx, database.CreateProfileSchemaParams{TenantID: tenantID, Schema: raw})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile schema: %w", err)
+	}
+	return toProfileSchemaResponse(schema), nil
+}
+
+// DryRunProfileSchema 用未发布的 schema 检查租户的全部现有用户，返回不符合的用户
+func (s *Service) DryRunProfileSchema(ctx context.Context, tenantID string, raw json.RawMessage, limit int) (*ProfileSchemaDryRunResponse, error) {
+	schema, err := profileschema.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ProfileSchemaDryRunResponse{Users: []*ProfileSchemaDryRunUser{}}
+	params := database.ListUsersByCreatedAtAscParams{TenantID: tenantID, Lim: dryRunPageSize}
+	for {
+		users, err := s.db.ListUsersByCreatedAtAsc(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, user := range users {
+			resp.Checked++
+			violations := schema.Validate(toUserResponse(user).Profile)
+			if len(violations) == 0 {
+				continue
+			}
+			resp.Failed++
+			if len(resp.Users) < limit {
+				resp.Users = append(resp.Users, &ProfileSchemaDryRunUser{ID: user.ID, Email: user.Email, Violations: violations})
+			}
+		}
+		if len(users) < dryRunPageSize {
+			return resp, nil
+		}
+		last := users[len(users)-1]
+		params.CursorCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+		params.CursorID = last.ID
+	}
+}
+
+// profileSchema 获取租户当前生效的资料 schema，未发布时返回 nil
+func (s *Service) profileSchema(ctx context.Context, tenantID string) (*profileschema.Schema, int32, error) {
+	row, err := s.db.GetLatestProfileSchema(ctx, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get profile schema: %w", err)
+	}
+	schema, err := profileschema.Parse(row.Schema)
+	if err != nil {
+		return nil, 0, fmt.Errorf("stored profile schema version %d: %w", row.Version, err)
+	}
+	return schema, row.Version, nil
+}
+
+// validateProfile 按租户的资料 schema 校验新资料，返回要保存的资料。
+// before 为修改前的资料，新建用户时为nil。trusted 为false表示终端用户提交：
+// 省略的只读字段保留原值，修改只读字段视为违规
+func (s *Service) validateProfile(ctx context.Context, tenantID string, before, after map[string]interface{}, trusted bool) (map[string]interface{}, error) {
+	schema, version, err := s.profileSchema(ctx, tenantID)
+	if err != nil || schema == nil {
+		return after, err
+	}
+	return checkProfile(schema, version, before, after, trusted)
+}
+
+// checkProfile 按已加载的 schema 校验资料，见 validateProfile
+func checkProfile(schema *profileschema.Schema, version int32, before, after map[string]interface{}, trusted bool) (map[string]interface{}, error) {
+	// 经过一次 JSON 编解码，使数值等类型与存储后读取的一致
+	profile := map[string]interface{}{}
+	if after != nil {
+		raw, err := json.Marshal(after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal profile: %w", err)
+		}
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal profile: %w", err)
+		}
+	}
+
+	var violations []profileschema.Violation
+	if !trusted {
+		violations = schema.ApplyReadOnly(before, profile)
+	}
+	violations = append(violations, schema.Validate(profile)...)
+	if len(violations) > 0 {
+		return nil, &ProfileValidationError{SchemaVersion: version, Violations: violations}
+	}
+	if after == nil && len(profile) == 0 {
+		return nil, nil
+	}
+	return profile, nil
+}
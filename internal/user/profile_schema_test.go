@@ -0,0 +1,101 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/profileschema"
+)
+
+func TestProfileSchema(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	// 发布 schema 前已存在的用户
+	legacy, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "legacy@example.com", Password: "password123", Profile: map[string]interface{}{"name": 42}})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if _, err := svc.PublishProfileSchema(ctx, "tnt_test", json.RawMessage(`{"type": "object", "required": ["nmae"], "oneOf": []}`)); !errors.Is(err, profileschema.ErrInvalidSchema) {
+		t.Fatalf("expected ErrInvalidSchema, got %v", err)
+	}
+	raw := json.RawMessage(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string", "maxLength": 20},
+			"plan": {"type": "string", "enum": ["free", "pro"], "readOnly": true}
+		}
+	}`)
+	dryRun, err := svc.DryRunProfileSchema(ctx, "tnt_test", raw, 10)
+	if err != nil {
+		t.Fatalf("DryRunProfileSchema: %v", err)
+	}
+	if dryRun.Checked != 1 || dryRun.Failed != 1 || dryRun.Users[0].ID != legacy.ID || dryRun.Users[0].Violations[0].Field != "name" {
+		t.Fatalf("unexpected dry run result: %+v", dryRun)
+	}
+	if _, err := svc.GetProfileSchema(ctx, "tnt_test", 0); !errors.Is(err, ErrProfileSchemaNotFound) {
+		t.Fatalf("expected the dry run not to publish, got %v", err)
+	}
+
+	if _, err := svc.PublishProfileSchema(ctx, "tnt_test", json.RawMessage(`{"type": "object"}`)); err != nil {
+		t.Fatalf("PublishProfileSchema: %v", err)
+	}
+	published, err := svc.PublishProfileSchema(ctx, "tnt_test", raw)
+	if err != nil || published.Version != 2 {
+		t.Fatalf("expected version 2, got %+v, %v", published, err)
+	}
+	if first, err := svc.GetProfileSchema(ctx, "tnt_test", 1); err != nil || string(first.Schema) != `{"type": "object"}` {
+		t.Fatalf("expected version 1 to be kept, got %+v, %v", first, err)
+	}
+
+	// 终端用户注册：校验资料，不能写入只读字段
+	var profileErr *ProfileValidationError
+	_, err = svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ann@example.com", Password: "password123"})
+	if !errors.As(err, &profileErr) || profileErr.SchemaVersion != 2 || profileErr.Violations[0].Field != "name" {
+		t.Fatalf("expected a missing name violation, got %v", err)
+	}
+	_, err = svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ann@example.com", Password: "password123", Profile: map[string]interface{}{"name": "Ann", "plan": "pro"}})
+	if !errors.Is(err, ErrProfileInvalid) {
+		t.Fatalf("expected plan to be read-only for end users, got %v", err)
+	}
+
+	// 内部服务可以写入只读字段
+	created, err := svc.CreateUser(ctx, "tnt_test", RegisterRequest{Email: "ann@example.com", Password: "password123", Profile: map[string]interface{}{"name": "Ann", "plan": "pro"}})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := svc.CreateUser(ctx, "tnt_test", RegisterRequest{Email: "bo@example.com", Password: "password123", Profile: map[string]interface{}{"name": "Bo", "plan": "gold"}}); !errors.Is(err, ErrProfileInvalid) {
+		t.Fatalf("expected internal writes to be validated, got %v", err)
+	}
+
+	// 用户修改资料：省略的只读字段保留原值
+	updated, err := svc.UpdateProfile(ctx, "tnt_test", created.ID, UpdateProfileRequest{Profile: map[string]interface{}{"name": "Anne"}})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if updated.Profile["name"] != "Anne" || updated.Profile["plan"] != "pro" {
+		t.Fatalf("unexpected profile: %+v", updated.Profile)
+	}
+	if _, err := svc.UpdateProfile(ctx, "tnt_test", created.ID, UpdateProfileRequest{Profile: map[string]interface{}{"name": "Anne", "plan": "free"}}); !errors.Is(err, ErrProfileInvalid) {
+		t.Fatalf("expected plan to be read-only for end users, got %v", err)
+	}
+
+	// 管理员和内部服务修改资料
+	if _, err := svc.UpdateUser(ctx, "tnt_test", created.ID, UpdateUserRequest{Profile: map[string]interface{}{"plan": "free"}}); !errors.Is(err, ErrProfileInvalid) {
+		t.Fatalf("expected the required name to be enforced on update, got %v", err)
+	}
+	updated, err = svc.UpdateUser(ctx, "tnt_test", created.ID, UpdateUserRequest{Profile: map[string]interface{}{"name": "Anne", "plan": "free"}})
+	if err != nil || updated.Profile["plan"] != "free" {
+		t.Fatalf("expected the plan to be changed, got %+v, %v", updated, err)
+	}
+
+	// 未修改资料时不校验已有用户的旧资料
+	email := "legacy2@example.com"
+	if _, err := svc.UpdateUser(ctx, "tnt_test", legacy.ID, UpdateUserRequest{Email: &email}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"yuyu-test/internal/store/database"
+)
+
+// refreshTokenTTL refresh token有效期，每次轮换重新计算
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidRefreshToken refresh token不存在、已过期、已吊销或不属于当前租户
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused 已轮换的refresh token被再次使用，所在家族已全部吊销
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// createRefreshToken 保存新的refresh token。家族ID即会话ID；
+// parent 为nil时是该会话的第一个令牌，否则作为父令牌的子令牌，沿用父令牌绑定的客户端和授权范围
+func (s *Service) createRefreshToken(ctx context.Context, user database.User, familyID string, parent *database.UserRefreshToken, amr []string, clientIP, userAgent string) (string, error) {
+	params := database.CreateRefreshTokenParams{
+		UserID:    user.ID,
+		TenantID:  user.TenantID,
+		FamilyID:  familyID,
+		Amr:       amr,
+		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+	}
+	if parent != nil {
+		params.ParentID = sql.NullInt32{Int32: parent.ID, Valid: true}
+		params.ClientID = parent.ClientID
+		params.Scopes = parent.Scopes
+	}
+	return s.storeRefreshToken(ctx, params)
+}
+
+// storeRefreshToken 生成refresh token，按 params 保存其哈希
+func (s *Service) storeRefreshToken(ctx context.Context, params database.CreateRefreshTokenParams) (string, error) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	if params.Amr == nil {
+		params.Amr = []string{}
+	}
+	if params.Scopes == nil {
+		params.Scopes = []string{}
+	}
+	params.TokenHash = hashToken(refreshToken)
+	params.ExpiresAt = time.Now().Add(refreshTokenTTL)
+
+	if err := s.db.CreateRefreshToken(ctx, params); err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return refreshToken, nil
+}
+
+// RefreshTokens 轮换refresh token并签发新的access_token。
+// 令牌只能在签发它的租户下使用；已轮换的令牌被再次使用说明可能已泄露，
+// 此时吊销整个家族（攻击者和合法用户都需要重新登录）并记录安全事件
+func (s *Service) RefreshTokens(ctx context.Context, tenantID, refreshToken, clientIP, userAgent string) (*LoginResponse, error) {
+	user, session, token, err := s.rotateRefreshToken(ctx, tenantID, "", refreshToken, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	organizationID, err := s.sessionOrganization(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := s.signAccessToken(ctx, user, session.ID, organizationID, token.Amr)
+	if err != nil {
+		return nil, err
+	}
+	newRefreshToken, err := s.renewRefreshToken(ctx, user, session, &token, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		User:         toUserResponse(user),
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+// rotateRefreshToken 校验refresh token并将其标记为已轮换，返回所属用户、会话和该令牌。
+// clientID 为令牌应绑定的 OIDC 客户端，第一方登录为空，不一致时视为无效令牌
+func (s *Service) rotateRefreshToken(ctx context.Context, tenantID, clientID, refreshToken, clientIP, userAgent string) (database.User, database.UserSession, database.UserRefreshToken, error) {
+	var (
+		user    database.User
+		session database.UserSession
+	)
+	token, err := s.db.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, session, token, ErrInvalidRefreshToken
+		}
+		return user, session, token, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if token.TenantID != tenantID {
+		s.recordSecurityEvent(ctx, token.TenantID, token.UserID, SecurityEventRefreshTokenTenantMismatch, clientIP, userAgent, map[string]any{
+			"family_id":         token.FamilyID,
+			"requesting_tenant": tenantID,
+		})
+		return user, session, token, ErrInvalidRefreshToken
+	}
+	if token.ClientID.String != clientID {
+		return user, session, token, ErrInvalidRefreshToken
+	}
+	if token.RevokedAt.Valid || !token.ExpiresAt.After(time.Now()) {
+		return user, session, token, ErrInvalidRefreshToken
+	}
+	if token.RotatedAt.Valid {
+		return user, session, token, s.revokeReusedFamily(ctx, token, clientIP, userAgent)
+	}
+	session, err = s.db.GetUserSession(ctx, token.FamilyID)
+	if err != nil || session.RevokedAt.Valid {
+		return user, session, token, ErrInvalidRefreshToken
+	}
+
+	// 条件更新保证并发请求中只有一个能完成轮换，其余视为重放
+	rotated, err := s.db.MarkRefreshTokenRotated(ctx, token.ID)
+	if err != nil {
+		return user, session, token, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if rotated == 0 {
+		return user, session, token, s.revokeReusedFamily(ctx, token, clientIP, userAgent)
+	}
+
+	user, err = s.currentUser(ctx, tenantID, token.UserID)
+	if err != nil {
+		return user, session, token, ErrInvalidRefreshToken
+	}
+	if err := checkUserStatus(user); err != nil {
+		return user, session, token, err
+	}
+	return user, session, token, nil
+}
+
+// renewRefreshToken 签发被轮换令牌的子令牌，并延长会话有效期
+func (s *Service) renewRefreshToken(ctx context.Context, user database.User, session database.UserSession, parent *database.UserRefreshToken, clientIP, userAgent string) (string, error) {
+	newRefreshToken, err := s.createRefreshToken(ctx, user, session.ID, parent, parent.Amr, clientIP, userAgent)
+	if err != nil {
+		return "", err
+	}
+	if err := s.db.TouchUserSession(ctx, database.TouchUserSessionParams{
+		ID:        session.ID,
+		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}); err != nil {
+		return "", fmt.Errorf("failed to update session: %w", err)
+	}
+	return newRefreshToken, nil
+}
+
+// revokeReusedFamily 注销重放令牌所在的会话（吊销整个家族）并记录安全事件
+func (s *Service) revokeReusedFamily(ctx context.Context, token database.UserRefreshToken, clientIP, userAgent string) error {
+	if err := s.revokeSession(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, token.TenantID, token.UserID, SecurityEventRefreshTokenReuse, clientIP, userAgent, map[string]any{
+		"family_id": token.FamilyID,
+		"token_id":  token.ID,
+	})
+	return ErrRefreshTokenReused
+}
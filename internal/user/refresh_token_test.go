@@ -0,0 +1,80 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"yuyu-test/internal/store/database"
+)
+
+func TestRefreshTokenRotationAndReuseDetection(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "erin@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "erin@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	rotated, err := svc.RefreshTokens(ctx, "tnt_test", login.RefreshToken, "203.0.113.7", "test-agent")
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+		t.Fatal("expected a new refresh token")
+	}
+	if !slices.Equal(accessTokenAMR(t, svc, rotated.Token), []string{"pwd"}) {
+		t.Fatal("expected amr to be carried over on refresh")
+	}
+	parent := store.refresh[hashToken(login.RefreshToken)]
+	child := store.refresh[hashToken(rotated.RefreshToken)]
+	if child.FamilyID != parent.FamilyID || !child.ParentID.Valid || child.ParentID.Int32 != parent.ID {
+		t.Fatalf("expected child token in the same family, got parent=%+v child=%+v", parent, child)
+	}
+
+	// 旧令牌重放：整个家族吊销，包括尚未使用的子令牌
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", login.RefreshToken, "198.51.100.9", "attacker"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", rotated.RefreshToken, "203.0.113.7", "test-agent"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected revoked child to be rejected, got %v", err)
+	}
+	if len(store.events) != 1 || store.events[0].EventType != SecurityEventRefreshTokenReuse {
+		t.Fatalf("expected one reuse security event, got %+v", store.events)
+	}
+	var details map[string]any
+	if err := json.Unmarshal(store.events[0].Details, &details); err != nil || details["family_id"] != parent.FamilyID {
+		t.Fatalf("expected family_id in event details, got %s", store.events[0].Details)
+	}
+}
+
+func TestRefreshTokenBoundToTenant(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+	store.tenants["tnt_other"] = database.Tenant{ID: "tnt_other", Name: "Other Tenant", Settings: json.RawMessage(`{}`)}
+
+	if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "frank@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "frank@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if _, err := svc.RefreshTokens(ctx, "tnt_other", login.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected cross-tenant refresh to fail, got %v", err)
+	}
+	if len(store.events) != 1 || store.events[0].EventType != SecurityEventRefreshTokenTenantMismatch {
+		t.Fatalf("expected tenant mismatch security event, got %+v", store.events)
+	}
+	// 跨租户尝试不消耗令牌，原租户仍可正常刷新
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", login.RefreshToken, "", ""); err != nil {
+		t.Fatalf("RefreshTokens in owning tenant: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"yuyu-test/internal/auth"
+)
+
+func TestLoginRehashesLegacyPassword(t *testing.T) {
+	ctx := context.Background()
+	svc, store, _ := newTestService(t, `{}`)
+
+	// 以bcrypt注册，模拟升级前创建的账号
+	svc.hasher = newTestHasher(t, auth.AlgorithmBcrypt)
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "pia@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	legacy := store.users[registered.ID]
+	if !strings.HasPrefix(legacy.HashedPassword.String, "$2") {
+		t.Fatalf("expected bcrypt hash, got %q", legacy.HashedPassword.String)
+	}
+
+	svc.hasher = newTestHasher(t, auth.AlgorithmArgon2id)
+	if _, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "pia@example.com", Password: "wrong-password"}, "", ""); err == nil {
+		t.Fatal("expected wrong password to fail")
+	}
+	if store.users[registered.ID].HashedPassword != legacy.HashedPassword {
+		t.Fatal("failed login must not rehash")
+	}
+
+	login := LoginRequest{Email: "pia@example.com", Password: "password123"}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	upgraded := store.users[registered.ID]
+	if !strings.HasPrefix(upgraded.HashedPassword.String, "$argon2id$") {
+		t.Fatalf("expected argon2id hash after login, got %q", upgraded.HashedPassword.String)
+	}
+	if upgraded.PasswordChangedAt != legacy.PasswordChangedAt {
+		t.Fatal("rehash must not count as a password change")
+	}
+	if _, err := svc.Login(ctx, "tnt_test", login, "", ""); err != nil {
+		t.Fatalf("Login with upgraded hash: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"yuyu-test/internal/store/database"
+)
+
+// authzClaimsMaxBytes 写入访问令牌的角色和权限的总字节数上限，超出时只设置 authz_overflow，
+// 避免令牌过大导致请求头超限
+const authzClaimsMaxBytes = 2048
+
+var (
+	// ErrRoleNotFound 角色不存在、不属于当前租户，或未分配给该用户
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleExists 租户下已有同名角色
+	ErrRoleExists = errors.New("role already exists")
+	// ErrInvalidRole 角色名或权限名不合法
+	ErrInvalidRole = errors.New("invalid role name or permission")
+)
+
+// namePattern 角色名和权限名的格式，如 admin、orders:read、billing.*
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.:*-]{1,100}$`)
+
+// CreateRoleRequest 创建角色请求
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// RolePermissionsRequest 为角色添加权限
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+// AssignRoleRequest 为用户分配角色
+type AssignRoleRequest struct {
+	RoleID string `json:"role_id" binding:"required"`
+}
+
+// RoleResponse 角色及其权限
+type RoleResponse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// UserAuthorizationResponse 用户当前生效的角色和权限
+type UserAuthorizationResponse struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+func validateNames(names ...string) error {
+	for _, name := range names {
+		if !namePattern.MatchString(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidRole, name)
+		}
+	}
+	return nil
+}
+
+// roleResponse 查询角色的权限并转换为响应结构
+func (s *Service) roleResponse(ctx context.Context, role database.Role) (*RoleResponse, error) {
+	permissions, err := s.db.ListRolePermissions(ctx, role.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role permissions: %w", err)
+	}
+	return &RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}, nil
+}
+
+// tenantRole 获取属于指定租户的角色，不存在时返回 ErrRoleNotFound
+func (s *Service) tenantRole(ctx context.Context, tenantID, roleID string) (database.Role, error) {
+	role, err := s.db.GetRole(ctx, database.GetRoleParams{ID: roleID, TenantID: tenantID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Role{}, ErrRoleNotFound
+	}
+	if err != nil {
+		return database.Role{}, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+// CreateRole 创建角色并授予初始权限
+func (s *Service) CreateRole(ctx context.Context, tenantID string, req CreateRoleRequest) (*RoleResponse, error) {
+	if err := validateNames(append([]string{req.Name}, req.Permissions...)...); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.GetRoleByName(ctx, database.GetRoleByNameParams{TenantID: tenantID, Name: req.Name}); err == nil {
+		return nil, ErrRoleExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	role, err := s.db.CreateRole(ctx, database.CreateRoleParams{
+		ID:          generateID("rol"),
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+	for _, permission := range req.Permissions {
+		if err := s.db.AddRolePermission(ctx, database.AddRolePermissionParams{RoleID: role.ID, Permission: permission}); err != nil {
+			return nil, fmt.Errorf("failed to add role permission: %w", err)
+		}
+	}
+
+	slog.Info("Role created", "role_id", role.ID, "name", role.Name, "tenant_id", tenantID)
+	return s.roleResponse(ctx, role)
+}
+
+// ListRoles 列出租户的全部角色
+func (s *Service) ListRoles(ctx context.Context, tenantID string) ([]*RoleResponse, error) {
+	roles, err := s.db.ListRoles(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	resp := make([]*RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		r, err := s.roleResponse(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, r)
+	}
+	return resp, nil
+}
+
+// GetRole 获取角色及其权限
+func (s *Service) GetRole(ctx context.Context, tenantID, roleID string) (*RoleResponse, error) {
+	role, err := s.tenantRole(ctx, tenantID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.roleResponse(ctx, role)
+}
+
+// DeleteRole 删除角色，同时从所有用户上移除。已签发的访问令牌在过期前仍带有该角色
+func (s *Service) DeleteRole(ctx context.Context, tenantID, roleID string) error {
+	n, err := s.db.DeleteRole(ctx, database.DeleteRoleParams{ID: roleID, TenantID: tenantID})
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if n == 0 {
+		return ErrRoleNotFound
+	}
+	slog.Info("Role deleted", "role_id", roleID, "tenant_id", tenantID)
+	return nil
+}
+
+// AddRolePermissions 为角色添加权限，已有的权限忽略
+func (s *Service) AddRolePermissions(ctx context.Context, tenantID, roleID string, req RolePermissionsRequest) (*RoleResponse, error) {
+	if err := validateNames(req.Permissions...); err != nil {
+		return nil, err
+	}
+	role, err := s.tenantRole(ctx, tenantID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	for _, permission := range req.Permissions {
+		if err := s.db.AddRolePermission(ctx, database.AddRolePermissionParams{RoleID: role.ID, Permission: permission}); err != nil {
+			return nil, fmt.Errorf("failed to add role permission: %w", err)
+		}
+	}
+	return s.roleResponse(ctx, role)
+}
+
+// RemoveRolePermission 移除角色的一个权限
+func (s *Service) RemoveRolePermission(ctx context.Context, tenantID, roleID, permission string) error {
+	role, err := s.tenantRole(ctx, tenantID, roleID)
+	if err != nil {
+		return err
+	}
+	n, err := s.db.RemoveRolePermission(ctx, database.RemoveRolePermissionParams{RoleID: role.ID, Permission: permission})
+	if err != nil {
+		return fmt.Errorf("failed to remove role permission: %w", err)
+	}
+	if n == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// AssignRole 为用户分配角色，已分配时忽略。新角色在用户下次获取访问令牌时写入令牌
+func (s *Service) AssignRole(ctx context.Context, tenantID, userID string, req AssignRoleRequest) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	role, err := s.tenantRole(ctx, tenantID, req.RoleID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.AssignUserRole(ctx, database.AssignUserRoleParams{UserID: user.ID, RoleID: role.ID}); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	slog.Info("Role assigned", "user_id", user.ID, "role_id", role.ID, "tenant_id", tenantID)
+	return nil
+}
+
+// UnassignRole 移除用户的角色
+func (s *Service) UnassignRole(ctx context.Context, tenantID, userID, roleID string) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	n, err := s.db.UnassignUserRole(ctx, database.UnassignUserRoleParams{UserID: user.ID, RoleID: roleID})
+	if err != nil {
+		return fmt.Errorf("failed to unassign role: %w", err)
+	}
+	if n == 0 {
+		return ErrRoleNotFound
+	}
+	slog.Info("Role unassigned", "user_id", user.ID, "role_id", roleID, "tenant_id", tenantID)
+	return nil
+}
+
+// GetUserAuthorization 查询用户当前生效的角色和权限，令牌中的角色和权限超出上限时使用。
+// organizationID 非空时包含用户在该组织内的角色
+func (s *Service) GetUserAuthorization(ctx context.Context, tenantID, userID, organizationID string) (*UserAuthorizationResponse, error) {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.userAuthorization(ctx, user, organizationID)
+}
+
+// UserPermissions 查询用户当前的全部权限，供 RequireUserPermission 中间件在令牌未携带权限时使用
+func (s *Service) UserPermissions(ctx context.Context, tenantID, userID, organizationID string) ([]string, error) {
+	authz, err := s.GetUserAuthorization(ctx, tenantID, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return authz.Permissions, nil
+}
+
+func (s *Service) userAuthorization(ctx context.Context, user database.User, organizationID string) (*UserAuthorizationResponse, error) {
+	orgID := sql.NullString{String: organizationID, Valid: organizationID != ""}
+	roles, err := s.db.ListUserRoles(ctx, database.ListUserRolesParams{TenantID: user.TenantID, UserID: user.ID, OrganizationID: orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	permissions, err := s.db.ListUserPermissions(ctx, database.ListUserPermissionsParams{TenantID: user.TenantID, UserID: user.ID, OrganizationID: orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user permissions: %w", err)
+	}
+	authz := &UserAuthorizationResponse{Roles: make([]string, 0, len(roles)), Permissions: permissions}
+	for _, role := range roles {
+		authz.Roles = append(authz.Roles, role.Name)
+	}
+	return authz, nil
+}
+
+// authorizationClaims 计算写入访问令牌的角色和权限，超出 authzClaimsMaxBytes 时返回 overflow
+func (s *Service) authorizationClaims(ctx context.Context, user database.User, organizationID string) (roles, permissions []string, overflow bool, err error) {
+	authz, err := s.userAuthorization(ctx, user, organizationID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	size := 0
+	for _, names := range [][]string{authz.Roles, authz.Permissions} {
+		for _, name := range names {
+			size += len(name) + 3 // 引号和逗号
+		}
+	}
+	if size > authzClaimsMaxBytes {
+		return nil, nil, true, nil
+	}
+	return authz.Roles, authz.Permissions, false, nil
+}
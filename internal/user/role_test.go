@@ -0,0 +1,123 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"yuyu-test/internal/auth"
+)
+
+func accessTokenClaims(t *testing.T, svc *Service, token string) *auth.Claims {
+	t.Helper()
+	claims := &auth.Claims{}
+	if err := svc.signer.Parse(token, claims); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	return claims
+}
+
+func TestRolesAndPermissions(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := LoginRequest{Email: "kai@example.com", Password: "password123"}
+
+	if _, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "bad role"}); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+	editor, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "editor", Permissions: []string{"posts:write", "posts:read"}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if !slices.Equal(editor.Permissions, []string{"posts:read", "posts:write"}) {
+		t.Fatalf("unexpected permissions: %v", editor.Permissions)
+	}
+	if _, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "editor"}); !errors.Is(err, ErrRoleExists) {
+		t.Fatalf("expected ErrRoleExists, got %v", err)
+	}
+	viewer, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "viewer", Permissions: []string{"posts:read"}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if _, err := svc.AddRolePermissions(ctx, "tnt_test", viewer.ID, RolePermissionsRequest{Permissions: []string{"comments:read"}}); err != nil {
+		t.Fatalf("AddRolePermissions: %v", err)
+	}
+
+	// 其他租户的角色不可见
+	if _, err := svc.GetRole(ctx, "tnt_other", editor.ID); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("expected ErrRoleNotFound, got %v", err)
+	}
+
+	for _, roleID := range []string{editor.ID, viewer.ID, viewer.ID} {
+		if err := svc.AssignRole(ctx, "tnt_test", registered.ID, AssignRoleRequest{RoleID: roleID}); err != nil {
+			t.Fatalf("AssignRole: %v", err)
+		}
+	}
+	authz, err := svc.GetUserAuthorization(ctx, "tnt_test", registered.ID, "")
+	if err != nil {
+		t.Fatalf("GetUserAuthorization: %v", err)
+	}
+	if !slices.Equal(authz.Roles, []string{"editor", "viewer"}) || !slices.Equal(authz.Permissions, []string{"comments:read", "posts:read", "posts:write"}) {
+		t.Fatalf("unexpected authorization: %+v", authz)
+	}
+
+	resp, err := svc.Login(ctx, "tnt_test", login, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims := accessTokenClaims(t, svc, resp.Token)
+	if !slices.Equal(claims.Roles, authz.Roles) || !slices.Equal(claims.Permissions, authz.Permissions) || claims.AuthzOverflow {
+		t.Fatalf("unexpected token claims: %+v", claims)
+	}
+
+	// 角色变化在刷新令牌后生效
+	if err := svc.UnassignRole(ctx, "tnt_test", registered.ID, editor.ID); err != nil {
+		t.Fatalf("UnassignRole: %v", err)
+	}
+	if err := svc.UnassignRole(ctx, "tnt_test", registered.ID, editor.ID); !errors.Is(err, ErrRoleNotFound) {
+		t.Fatalf("expected ErrRoleNotFound, got %v", err)
+	}
+	refreshed, err := svc.RefreshTokens(ctx, "tnt_test", resp.RefreshToken, "", "")
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if claims := accessTokenClaims(t, svc, refreshed.Token); !slices.Equal(claims.Permissions, []string{"comments:read", "posts:read"}) {
+		t.Fatalf("unexpected permissions after refresh: %v", claims.Permissions)
+	}
+
+	// 超出大小上限时令牌只携带 authz_overflow，通过查询接口获取权限
+	var many []string
+	for i := range 100 {
+		many = append(many, fmt.Sprintf("resource%03d:%s", i, strings.Repeat("x", 20)))
+	}
+	if _, err := svc.AddRolePermissions(ctx, "tnt_test", viewer.ID, RolePermissionsRequest{Permissions: many}); err != nil {
+		t.Fatalf("AddRolePermissions: %v", err)
+	}
+	resp, err = svc.Login(ctx, "tnt_test", login, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims = accessTokenClaims(t, svc, resp.Token)
+	if !claims.AuthzOverflow || claims.Roles != nil || claims.Permissions != nil {
+		t.Fatalf("expected authz_overflow, got %+v", claims)
+	}
+	permissions, err := svc.UserPermissions(ctx, "tnt_test", registered.ID, "")
+	if err != nil || len(permissions) != 102 {
+		t.Fatalf("expected 102 permissions from the lookup, got %d, %v", len(permissions), err)
+	}
+
+	if err := svc.DeleteRole(ctx, "tnt_test", viewer.ID); err != nil {
+		t.Fatalf("DeleteRole: %v", err)
+	}
+	if authz, _ := svc.GetUserAuthorization(ctx, "tnt_test", registered.ID, ""); len(authz.Roles) != 0 || len(authz.Permissions) != 0 {
+		t.Fatalf("expected no roles after deletion, got %+v", authz)
+	}
+}
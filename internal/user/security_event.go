@@ -0,0 +1,40 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"yuyu-test/internal/store/database"
+)
+
+// 安全事件类型
+const (
+	// SecurityEventRefreshTokenReuse 已轮换的refresh token被再次使用，整个家族已吊销
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	// SecurityEventRefreshTokenTenantMismatch 通过其他租户的API Key使用refresh token
+	SecurityEventRefreshTokenTenantMismatch = "refresh_token_tenant_mismatch"
+	// SecurityEventAuthorizationCodeReuse OIDC 授权码被再次使用，签发令牌的会话已注销
+	SecurityEventAuthorizationCodeReuse = "authorization_code_reuse"
+)
+
+// recordSecurityEvent 记录安全事件。写入失败只记日志，不影响主流程
+func (s *Service) recordSecurityEvent(ctx context.Context, tenantID, userID, eventType, clientIP, userAgent string, details map[string]any) {
+	slog.Warn("Security event", "event_type", eventType, "tenant_id", tenantID, "user_id", userID, "client_ip", clientIP, "details", details)
+
+	raw, err := json.Marshal(details)
+	if err != nil || details == nil {
+		raw = json.RawMessage("{}")
+	}
+	if err := s.db.CreateSecurityEvent(ctx, database.CreateSecurityEventParams{
+		TenantID:  sql.NullString{String: tenantID, Valid: tenantID != ""},
+		UserID:    sql.NullString{String: userID, Valid: userID != ""},
+		EventType: eventType,
+		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+		Details:   raw,
+	}); err != nil {
+		slog.Error("Failed to record security event", "event_type", eventType, "tenant_id", tenantID, "error", err)
+	}
+}
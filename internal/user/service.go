@@ -9,70 +9,205 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"yuyu-test/internal/auth"
+	"yuyu-test/internal/breach"
+	"yuyu-test/internal/lockout"
+	"yuyu-test/internal/mailer"
 	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
 
-	"crypto/sha256"
 	"encoding/base64"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sqlc-dev/pqtype"
 )
 
+var (
+	// ErrUserNotFound 用户不存在或不属于当前租户
+	ErrUserNotFound = errors.New("user not found")
+	// ErrEmailNotVerified 租户要求邮箱验证而用户尚未验证
+	ErrEmailNotVerified = errors.New("email not verified")
+	// ErrInvalidCredentials 邮箱或密码错误（不区分账号是否存在）
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrSignupNotAllowed 租户注册方式不允许该邮箱自助注册
+	ErrSignupNotAllowed = errors.New("signup not allowed")
+)
+
+// AccessTokenTTL 用户访问令牌有效期
+const AccessTokenTTL = 15 * time.Minute
+
+// TokenRevoker 吊销已签发的访问令牌并查询吊销状态，由 revocation.Store 实现
+type TokenRevoker interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeUser(ctx context.Context, userID string) error
+	IsRevoked(claims *auth.Claims) bool
+}
+
 // Service 用户服务
 type Service struct {
-	db     database.Querier
-	signer auth.JWTSigner
+	db      database.Querier
+	signer  auth.JWTSigner
+	mailer  mailer.Mailer
+	revoker TokenRevoker
+	guard   *lockout.Guard
+	// breached 泄露密码库，为nil时不检查
+	breached breach.Checker
+	hasher   *auth.PasswordHasher
+	// decoySecret 生成Passkey诱饵凭证ID的HMAC密钥
+	decoySecret []byte
 }
 
-// NewService 创建新的用户服务
-func NewService(db database.Querier, signer auth.JWTSigner) *Service {
-	return &Service{db: db, signer: signer}
+// NewService 创建新的用户服务。decoySecret 为空时随机生成，多实例部署时应配置相同的值
+func NewService(db database.Querier, signer auth.JWTSigner, mailer mailer.Mailer, revoker TokenRevoker, guard *lockout.Guard, breached breach.Checker, hasher *auth.PasswordHasher, decoySecret []byte) *Service {
+	if len(decoySecret) == 0 {
+		decoySecret = make([]byte, 32)
+		rand.Read(decoySecret)
+	}
+	return &Service{db: db, signer: signer, mailer: mailer, revoker: revoker, guard: guard, breached: breached, hasher: hasher, decoySecret: decoySecret}
 }
 
 // RegisterRequest 用户注册请求
 type RegisterRequest struct {
 	Email    string                 `json:"email" binding:"required,email"`
-	Password string                 `json:"password" binding:"required,min=6"`
+	Password string                 `json:"password" binding:"required"`
 	Profile  map[string]interface{} `json:"profile"`
 }
 
 // RegisterResponse 用户注册响应
 type RegisterResponse struct {
-	ID        string                 `json:"id"`
-	Email     string                 `json:"email"`
-	Profile   map[string]interface{} `json:"profile"`
-	CreatedAt string                 `json:"created_at"`
+	ID            string                 `json:"id"`
+	Email         string                 `json:"email"`
+	EmailVerified bool                   `json:"email_verified"`
+	Status        string                 `json:"status"`
+	Profile       map[string]interface{} `json:"profile"`
+	CreatedAt     string                 `json:"created_at"`
 }
 
-// Register 用户注册
+// toUserResponse 将数据库用户转换为响应结构
+func toUserResponse(user database.User) *RegisterResponse {
+	var profile map[string]interface{}
+	if user.Profile.Valid && len(user.Profile.RawMessage) > 0 {
+		_ = json.Unmarshal(user.Profile.RawMessage, &profile)
+	} else {
+		profile = make(map[string]interface{})
+	}
+	return &RegisterResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Status:        user.Status,
+		Profile:       profile,
+		CreatedAt:     user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// tenantSettings 获取租户策略配置
+func (s *Service) tenantSettings(ctx context.Context, tenantID string) (*tenant.Settings, error) {
+	t, err := s.db.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return tenant.ParseSettings(t.Settings)
+}
+
+// currentUser 获取属于指定租户的用户，不存在或跨租户时返回 ErrUserNotFound
+func (s *Service) currentUser(ctx context.Context, tenantID, userID string) (database.User, error) {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil || user.TenantID != tenantID {
+		return database.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// Register 终端用户注册，资料中不能包含 schema 规定的只读字段。租户注册方式不允许时返回 ErrSignupNotAllowed
 func (s *Service) Register(ctx context.Context, tenantID string, req RegisterRequest) (*RegisterResponse, error) {
+	settings, err := s.tenantSettings(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignupAllowed(settings, req.Email); err != nil {
+		return nil, err
+	}
+	return s.register(ctx, tenantID, req, false)
+}
+
+// checkSignupAllowed 按租户注册方式检查邮箱能否自助注册
+func checkSignupAllowed(settings *tenant.Settings, email string) error {
+	switch settings.SignupMode {
+	case tenant.SignupInviteOnly:
+		return fmt.Errorf("%w: an invitation is required", ErrSignupNotAllowed)
+	case tenant.SignupAllowedDomains:
+		domain := email[strings.LastIndex(email, "@")+1:]
+		if !slices.ContainsFunc(settings.AllowedEmailDomains, func(d string) bool { return strings.EqualFold(d, domain) }) {
+			return fmt.Errorf("%w: email domain %s is not allowed", ErrSignupNotAllowed, domain)
+		}
+	}
+	return nil
+}
+
+// CreateUser 内部服务创建用户，可以写入只读资料字段
+func (s *Service) CreateUser(ctx context.Context, tenantID string, req RegisterRequest) (*RegisterResponse, error) {
+	return s.register(ctx, tenantID, req, true)
+}
+
+func (s *Service) register(ctx context.Context, tenantID string, req RegisterRequest, trusted bool) (*RegisterResponse, error) {
+	user, err := s.createUser(ctx, tenantID, req, trusted)
+	if err != nil {
+		return nil, err
+	}
+
+	// 发送邮箱验证邮件，失败不影响注册结果，用户可稍后重新发送
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		slog.Error("Failed to send verification email", "user_id", user.ID, "error", err)
+	}
+
+	return toUserResponse(user), nil
+}
+
+// createUser 校验密码策略和资料后创建用户，不发送验证邮件
+func (s *Service) createUser(ctx context.Context, tenantID string, req RegisterRequest, trusted bool) (database.User, error) {
 	// 检查用户是否已存在
 	existingUser, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
 		TenantID: tenantID,
 		Email:    req.Email,
 	})
 	if err == nil && existingUser.ID != "" {
-		return nil, fmt.Errorf("user with email %s already exists", req.Email)
+		return database.User{}, fmt.Errorf("user with email %s already exists", req.Email)
+	}
+
+	// 按租户密码策略校验
+	settings, err := s.tenantSettings(ctx, tenantID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := s.validatePassword(ctx, settings.PasswordPolicy, req.Password, nil); err != nil {
+		return database.User{}, err
+	}
+	profile, err := s.validateProfile(ctx, tenantID, nil, req.Profile, trusted)
+	if err != nil {
+		return database.User{}, err
 	}
 
 	// 生成用户ID
 	userID := generateID("usr")
 
 	// 哈希密码
-	hashedPassword, err := auth.HashPassword(req.Password)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return database.User{}, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	// profile序列化
 	var profileRaw pqtype.NullRawMessage
-	if req.Profile != nil {
-		profileBytes, err := json.Marshal(req.Profile)
+	if profile != nil {
+		profileBytes, err := json.Marshal(profile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal profile: %w", err)
+			return database.User{}, fmt.Errorf("failed to marshal profile: %w", err)
 		}
 		profileRaw = pqtype.NullRawMessage{
 			RawMessage: profileBytes,
@@ -91,25 +226,11 @@ func (s *Service) Register(ctx context.Context, tenantID string, req RegisterReq
 		Profile:        profileRaw,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return database.User{}, fmt.Errorf("failed to create user: %w", err)
 	}
 
 	slog.Info("User registered", "user_id", userID, "email", req.Email, "tenant_id", tenantID)
-
-	// 反序列化profile
-	var profile map[string]interface{}
-	if user.Profile.Valid && len(user.Profile.RawMessage) > 0 {
-		_ = json.Unmarshal(user.Profile.RawMessage, &profile)
-	} else {
-		profile = make(map[string]interface{})
-	}
-
-	return &RegisterResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Profile:   profile,
-		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-	}, nil
+	return user, nil
 }
 
 // LoginRequest 用户登录请求
@@ -118,11 +239,20 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse 用户登录响应
+// LoginResponse 用户登录响应。
+// 需要二次验证时只返回 mfa_required 和 mfa_token，不签发令牌
 type LoginResponse struct {
-	User         *RegisterResponse `json:"user"`
-	Token        string            `json:"token"`
-	RefreshToken string            `json:"refresh_token"`
+	User         *RegisterResponse `json:"user,omitempty"`
+	Token        string            `json:"token,omitempty"`
+	RefreshToken string            `json:"refresh_token,omitempty"`
+	// MFARequired 为true时需调用 /v1/auth/mfa/verify 完成登录
+	MFARequired bool `json:"mfa_required,omitempty"`
+	// MFAToken 二次验证挑战令牌，短时有效
+	MFAToken string `json:"mfa_token,omitempty"`
+	// MFAEnrollmentRequired 租户强制MFA而用户尚未绑定，需先调用 /v1/auth/mfa/enroll
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+	// RecoveryCodes 登录时完成TOTP绑定会一并返回恢复码，仅展示这一次
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 // generateRefreshToken 生成高强度refresh token
@@ -134,216 +264,206 @@ func generateRefreshToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
+// loginCompleter 所有认证因素通过后完成登录，amr 为已完成的认证方式
+type loginCompleter func(ctx context.Context, user database.User, amr []string, clientIP, userAgent string) (*LoginResponse, error)
+
 // Login 用户登录
-func (s *Service) Login(ctx context.Context, tenantID string, req LoginRequest) (*LoginResponse, error) {
+func (s *Service) Login(ctx context.Context, tenantID string, req LoginRequest, clientIP, userAgent string) (*LoginResponse, error) {
+	return s.passwordLogin(ctx, tenantID, req, clientIP, userAgent, s.completeLogin)
+}
+
+// passwordLogin 校验邮箱和密码，需要二次验证时返回挑战，否则调用 complete 完成登录
+func (s *Service) passwordLogin(ctx context.Context, tenantID string, req LoginRequest, clientIP, userAgent string, complete loginCompleter) (*LoginResponse, error) {
+	// 账号按邮箱计数，不论账号是否存在，避免通过锁定行为探测账号
+	keys := []lockout.Key{lockout.AccountKey(tenantID, req.Email), lockout.IPKey(clientIP)}
+	if err := s.guard.Check(ctx, keys...); err != nil {
+		return nil, err
+	}
+
 	// 获取用户
 	user, err := s.db.GetUserByEmail(ctx, database.GetUserByEmailParams{
 		TenantID: tenantID,
 		Email:    req.Email,
 	})
 	if err != nil || !user.HashedPassword.Valid {
-		return nil, fmt.Errorf("invalid email or password")
+		// 账号不存在时同样做一次哈希比较，使响应时间一致
+		s.hasher.VerifyDummy(req.Password)
+		return nil, s.loginFailed(ctx, keys, clientIP, userAgent)
 	}
 
 	// 验证密码
-	if !auth.CheckPassword(req.Password, user.HashedPassword.String) {
-		return nil, fmt.Errorf("invalid email or password")
+	match, rehash := s.hasher.Verify(req.Password, user.HashedPassword.String)
+	if !match {
+		return nil, s.loginFailed(ctx, keys, clientIP, userAgent)
 	}
-
-	// 生成JWT令牌
-	claims := auth.Claims{
-		UserID:   user.ID,
-		TenantID: tenantID,
-		Email:    user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+	if rehash {
+		s.rehashPassword(ctx, user, req.Password)
 	}
-	token, err := s.signer.Sign(&claims)
+
+	settings, err := s.checkLoginPolicy(ctx, user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
+	}
+	if passwordExpired(settings.PasswordPolicy, user) {
+		return nil, ErrPasswordExpired
 	}
 
-	// 生成refresh_token
-	refreshToken, err := generateRefreshToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
-	}
-	refreshTokenHash := sha256.Sum256([]byte(refreshToken))
-	expiresAt := time.Now().Add(30 * 24 * time.Hour)
-	clientIP := ""                                // 可从ctx或请求中获取
-	userAgent := ""                               // 可从ctx或请求中获取
-	_ = s.db.DeleteAllRefreshTokens(ctx, user.ID) // 单端策略，先清理
-	err = s.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		TokenHash: fmt.Sprintf("%x", refreshTokenHash[:]),
-		ExpiresAt: expiresAt,
-		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
-		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	// 已绑定TOTP或租户强制MFA时，密码验证只返回二次验证挑战，
+	// 失败计数在二次验证通过后才清除，避免每次输对密码都重置账号的锁定计数
+	if challenge, err := s.mfaChallenge(ctx, user, settings, []string{amrPassword}); err != nil || challenge != nil {
+		return challenge, err
+	}
+	if err := s.guard.Succeed(ctx, keys[0]); err != nil {
+		return nil, err
 	}
 
-	slog.Info("User logged in", "user_id", user.ID, "email", user.Email, "tenant_id", tenantID)
+	return complete(ctx, user, []string{amrPassword}, clientIP, userAgent)
+}
 
-	// 反序列化profile
-	var profile map[string]interface{}
-	if user.Profile.Valid && len(user.Profile.RawMessage) > 0 {
-		_ = json.Unmarshal(user.Profile.RawMessage, &profile)
-	} else {
-		profile = make(map[string]interface{})
+// rehashPassword 密码校验通过后用当前哈希配置重新哈希过时的密码，失败只记日志不影响登录
+func (s *Service) rehashPassword(ctx context.Context, user database.User, password string) {
+	hash, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+			NewHash: sql.NullString{String: hash, Valid: true},
+			ID:      user.ID,
+			OldHash: user.HashedPassword,
+		})
+	}
+	if err != nil {
+		slog.Error("Failed to rehash password", "user_id", user.ID, "error", err)
+		return
 	}
+	slog.Info("User password rehashed", "user_id", user.ID, "tenant_id", user.TenantID)
+}
 
-	return &LoginResponse{
-		User: &RegisterResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			Profile:   profile,
-			CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		},
-		Token:        token,
-		RefreshToken: refreshToken,
-	}, nil
+// loginFailed 记录一次密码登录失败，统一返回 ErrInvalidCredentials
+func (s *Service) loginFailed(ctx context.Context, keys []lockout.Key, clientIP, userAgent string) error {
+	if err := s.guard.Fail(ctx, clientIP, userAgent, keys...); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
 }
 
-// GetUserByID 根据ID获取用户
-func (s *Service) GetUserByID(ctx context.Context, userID string) (*RegisterResponse, error) {
-	user, err := s.db.GetUserByID(ctx, userID)
+// checkLoginPolicy 校验与认证方式无关的登录前置条件，返回租户配置
+func (s *Service) checkLoginPolicy(ctx context.Context, user database.User) (*tenant.Settings, error) {
+	if err := checkUserStatus(user); err != nil {
+		return nil, err
+	}
+	settings, err := s.tenantSettings(ctx, user.TenantID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, err
 	}
-	var profile map[string]interface{}
-	if user.Profile.Valid && len(user.Profile.RawMessage) > 0 {
-		_ = json.Unmarshal(user.Profile.RawMessage, &profile)
-	} else {
-		profile = make(map[string]interface{})
+	// 租户要求邮箱验证时，未验证用户不允许登录
+	if settings.RequireEmailVerification && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
 	}
-	return &RegisterResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Profile:   profile,
-		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-	}, nil
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+	return settings, nil
 }
 
-// GetUsersByTenant 获取租户下的所有用户
-func (s *Service) GetUsersByTenant(ctx context.Context, tenantID string) ([]*RegisterResponse, error) {
-	users, err := s.db.GetUsersByTenant(ctx, tenantID)
+// completeLogin 所有认证因素通过后创建会话并签发令牌
+func (s *Service) completeLogin(ctx context.Context, user database.User, amr []string, clientIP, userAgent string) (*LoginResponse, error) {
+	session, err := s.startSession(ctx, user, clientIP, userAgent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return nil, err
 	}
-
-	var responses []*RegisterResponse
-	for _, user := range users {
-		var profile map[string]interface{}
-		if user.Profile.Valid && len(user.Profile.RawMessage) > 0 {
-			_ = json.Unmarshal(user.Profile.RawMessage, &profile)
-		} else {
-			profile = make(map[string]interface{})
-		}
-		responses = append(responses, &RegisterResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			Profile:   profile,
-			CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		})
+	resp, err := s.issueTokens(ctx, user, session.ID, amr, clientIP, userAgent)
+	if err != nil {
+		return nil, err
 	}
 
-	return responses, nil
-}
+	slog.Info("User logged in", "user_id", user.ID, "email", user.Email, "tenant_id", user.TenantID, "amr", amr, "session_id", session.ID)
 
-// generateID 生成唯一ID
-func generateID(prefix string) string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	return prefix + "_" + hex.EncodeToString(bytes)
+	return resp, nil
 }
 
-// VerifyAndRefreshToken 校验refresh_token，返回userID
-func (s *Service) VerifyAndRefreshToken(ctx context.Context, refreshToken, clientIP, userAgent string) (string, error) {
-	hash := sha256.Sum256([]byte(refreshToken))
-	// 需要用户ID，实际可通过前端传递或解析token内容
-	// 这里假设前端传user_id，或可遍历所有用户（不推荐，建议优化）
-	// 这里简化为遍历所有用户
-	// 实际生产建议refresh_token中带user_id信息
-	users, err := s.db.GetUsersByTenant(ctx, "") // 获取所有用户，实际应优化
+// issueTokens 签发access_token，并为会话开启新的refresh token家族
+func (s *Service) issueTokens(ctx context.Context, user database.User, sessionID string, amr []string, clientIP, userAgent string) (*LoginResponse, error) {
+	token, err := s.signAccessToken(ctx, user, sessionID, "", amr)
 	if err != nil {
-		return "", errors.New("invalid or expired refresh token")
+		return nil, err
 	}
-	var foundToken *database.UserRefreshToken
-	for _, u := range users {
-		token, err := s.db.GetRefreshToken(ctx, database.GetRefreshTokenParams{
-			UserID:    u.ID,
-			TokenHash: fmt.Sprintf("%x", hash[:]),
-		})
-		if err == nil && token.ExpiresAt.After(time.Now()) {
-			foundToken = &token
-			break
-		}
+	refreshToken, err := s.createRefreshToken(ctx, user, sessionID, nil, amr, clientIP, userAgent)
+	if err != nil {
+		return nil, err
 	}
-	if foundToken == nil {
-		return "", errors.New("invalid or expired refresh token")
+
+	return &LoginResponse{
+		User:         toUserResponse(user),
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// signAccessToken 签发access_token，sid 为所属会话，jti 用于单独吊销。
+// 令牌携带用户签发时的角色和权限；organizationID 为会话当前选择的组织，可以为空
+func (s *Service) signAccessToken(ctx context.Context, user database.User, sessionID, organizationID string, amr []string) (string, error) {
+	return s.signUserToken(ctx, user, sessionID, organizationID, amr, AccessTokenTTL)
+}
+
+// signUserToken 签发用户访问令牌，按租户的声明映射调整声明
+func (s *Service) signUserToken(ctx context.Context, user database.User, sessionID, organizationID string, amr []string, ttl time.Duration) (string, error) {
+	claims, err := s.userClaims(ctx, user, sessionID, organizationID, amr, ttl)
+	if err != nil {
+		return "", err
 	}
-	_ = s.db.DeleteRefreshToken(ctx, database.DeleteRefreshTokenParams{
-		UserID:    foundToken.UserID,
-		TokenHash: foundToken.TokenHash,
-	})
-	return foundToken.UserID, nil
+	return s.signClaims(ctx, user, claims)
 }
 
-// IssueNewTokens 为用户签发新access_token和refresh_token
-func (s *Service) IssueNewTokens(ctx context.Context, userID, clientIP, userAgent string) (*LoginResponse, error) {
-	user, err := s.db.GetUserByID(ctx, userID)
+// userClaims 生成用户访问令牌的默认声明，包含用户在租户（或组织）内的角色和权限
+func (s *Service) userClaims(ctx context.Context, user database.User, sessionID, organizationID string, amr []string, ttl time.Duration) (*auth.Claims, error) {
+	roles, permissions, overflow, err := s.authorizationClaims(ctx, user, organizationID)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, err
 	}
-	claims := auth.Claims{
-		UserID:   user.ID,
-		TenantID: user.TenantID,
-		Email:    user.Email,
+	return &auth.Claims{
+		UserID:         user.ID,
+		TenantID:       user.TenantID,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified,
+		AMR:            amr,
+		SessionID:      sessionID,
+		Roles:          roles,
+		Permissions:    permissions,
+		AuthzOverflow:  overflow,
+		OrganizationID: organizationID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
+			ID:        auth.NewTokenID(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
-	}
-	token, err := s.signer.Sign(&claims)
+	}, nil
+}
+
+// signClaims 按租户的声明映射签发访问令牌
+func (s *Service) signClaims(ctx context.Context, user database.User, claims *auth.Claims) (string, error) {
+	mapped, err := s.mapClaims(ctx, user, claims)
 	if err != nil {
-		return nil, errors.New("failed to generate token")
+		return "", err
 	}
-	refreshToken, err := generateRefreshToken()
-	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
-	}
-	refreshTokenHash := sha256.Sum256([]byte(refreshToken))
-	expiresAt := time.Now().Add(30 * 24 * time.Hour)
-	err = s.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		TokenHash: fmt.Sprintf("%x", refreshTokenHash[:]),
-		ExpiresAt: expiresAt,
-		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
-		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
-	})
+	token, err := s.signer.Sign(mapped)
 	if err != nil {
-		return nil, errors.New("failed to store refresh token")
+		return "", fmt.Errorf("failed to generate token: %w", err)
 	}
-	var profile map[string]interface{}
-	if user.Profile.Valid && len(user.Profile.RawMessage) > 0 {
-		_ = json.Unmarshal(user.Profile.RawMessage, &profile)
-	} else {
-		profile = make(map[string]interface{})
+	return token, nil
+}
+
+// GetUserByID 根据ID获取用户
+func (s *Service) GetUserByID(ctx context.Context, userID string) (*RegisterResponse, error) {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	return &LoginResponse{
-		User: &RegisterResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			Profile:   profile,
-			CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		},
-		Token:        token,
-		RefreshToken: refreshToken,
-	}, nil
+	return toUserResponse(user), nil
+}
+
+// generateID 生成唯一ID
+func generateID(prefix string) string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return prefix + "_" + hex.EncodeToString(bytes)
 }
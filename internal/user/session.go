@@ -0,0 +1,195 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/store/database"
+	"yuyu-test/internal/tenant"
+)
+
+var (
+	// ErrSessionNotFound 会话不存在、已注销或不属于当前用户
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionLimitReached 租户限制了并发会话数且策略为拒绝新登录
+	ErrSessionLimitReached = errors.New("maximum number of concurrent sessions reached")
+)
+
+// SessionResponse 会话信息
+type SessionResponse struct {
+	ID         string `json:"id"`
+	ClientIP   string `json:"client_ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at"`
+	ExpiresAt  string `json:"expires_at"`
+	RevokedAt  string `json:"revoked_at,omitempty"`
+	// Current 是否为发起请求的会话
+	Current bool `json:"current"`
+}
+
+func toSessionResponse(s database.UserSession, currentSessionID string) *SessionResponse {
+	resp := &SessionResponse{
+		ID:         s.ID,
+		ClientIP:   s.ClientIp.String,
+		UserAgent:  s.UserAgent.String,
+		CreatedAt:  s.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		LastUsedAt: s.LastUsedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ExpiresAt:  s.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		Current:    s.ID == currentSessionID,
+	}
+	if s.RevokedAt.Valid {
+		resp.RevokedAt = s.RevokedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return resp
+}
+
+// startSession 为一次登录创建会话，超出租户并发会话上限时按策略注销最早的会话或拒绝登录
+func (s *Service) startSession(ctx context.Context, user database.User, clientIP, userAgent string) (database.UserSession, error) {
+	settings, err := s.tenantSettings(ctx, user.TenantID)
+	if err != nil {
+		return database.UserSession{}, err
+	}
+
+	if settings.MaxSessions > 0 {
+		active, err := s.db.ListActiveUserSessions(ctx, database.ListActiveUserSessionsParams{
+			UserID:   user.ID,
+			TenantID: user.TenantID,
+		})
+		if err != nil {
+			return database.UserSession{}, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if excess := len(active) - settings.MaxSessions + 1; excess > 0 {
+			if settings.SessionLimitPolicy == tenant.SessionLimitReject {
+				slog.Warn("Session limit reached", "user_id", user.ID, "tenant_id", user.TenantID, "max_sessions", settings.MaxSessions)
+				return database.UserSession{}, ErrSessionLimitReached
+			}
+			// 列表按创建时间升序，最前面的最早
+			for _, old := range active[:excess] {
+				if err := s.revokeSession(ctx, old.ID); err != nil {
+					return database.UserSession{}, err
+				}
+				slog.Info("Session evicted", "user_id", user.ID, "tenant_id", user.TenantID, "session_id", old.ID)
+			}
+		}
+	}
+
+	session, err := s.db.CreateUserSession(ctx, database.CreateUserSessionParams{
+		ID:        generateID("ses"),
+		UserID:    user.ID,
+		TenantID:  user.TenantID,
+		ClientIp:  sql.NullString{String: clientIP, Valid: clientIP != ""},
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return database.UserSession{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, nil
+}
+
+// revokeSession 注销会话，吊销其refresh token家族和已签发的访问令牌
+func (s *Service) revokeSession(ctx context.Context, sessionID string) error {
+	if err := s.db.RevokeUserSession(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if err := s.db.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return s.revoker.RevokeSession(ctx, sessionID)
+}
+
+// revokeAllSessions 注销用户的所有会话，删除其refresh token并吊销已签发的访问令牌
+func (s *Service) revokeAllSessions(ctx context.Context, user database.User) error {
+	active, err := s.db.ListActiveUserSessions(ctx, database.ListActiveUserSessionsParams{
+		UserID:   user.ID,
+		TenantID: user.TenantID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if err := s.db.RevokeAllUserSessions(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if err := s.db.DeleteAllRefreshTokens(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	for _, session := range active {
+		if err := s.revoker.RevokeSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return s.revoker.RevokeUser(ctx, user.ID)
+}
+
+// ListSessions 列出当前用户的有效会话，currentSessionID 为访问令牌中的 sid
+func (s *Service) ListSessions(ctx context.Context, tenantID, userID, currentSessionID string) ([]*SessionResponse, error) {
+	if _, err := s.currentUser(ctx, tenantID, userID); err != nil {
+		return nil, err
+	}
+	sessions, err := s.db.ListActiveUserSessions(ctx, database.ListActiveUserSessionsParams{
+		UserID:   userID,
+		TenantID: tenantID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	responses := make([]*SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, toSessionResponse(session, currentSessionID))
+	}
+	return responses, nil
+}
+
+// RevokeSession 注销当前用户的指定会话（如退出另一台设备）
+func (s *Service) RevokeSession(ctx context.Context, tenantID, userID, sessionID string) error {
+	session, err := s.db.GetUserSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != userID || session.TenantID != tenantID || session.RevokedAt.Valid {
+		return ErrSessionNotFound
+	}
+	if err := s.revokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	slog.Info("Session revoked", "user_id", userID, "tenant_id", tenantID, "session_id", sessionID)
+	return nil
+}
+
+// RevokeAllSessions 注销当前用户的所有会话（在所有设备上退出登录）
+func (s *Service) RevokeAllSessions(ctx context.Context, tenantID, userID string) error {
+	user, err := s.currentUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		return err
+	}
+	slog.Info("All sessions revoked", "user_id", userID, "tenant_id", tenantID)
+	return nil
+}
+
+// Logout 退出登录：吊销当前访问令牌并注销其所属会话
+func (s *Service) Logout(ctx context.Context, tenantID, userID string, claims *auth.Claims) error {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.revoker.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+	if claims.SessionID != "" {
+		if err := s.RevokeSession(ctx, tenantID, userID, claims.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	slog.Info("User logged out", "user_id", userID, "tenant_id", tenantID, "session_id", claims.SessionID)
+	return nil
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/auth"
+)
+
+func accessTokenSessionID(t *testing.T, svc *Service, token string) string {
+	t.Helper()
+	claims := &auth.Claims{}
+	if err := svc.signer.Parse(token, claims); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	return claims.SessionID
+}
+
+func TestMultiDeviceSessions(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	registered, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "gina@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := LoginRequest{Email: "gina@example.com", Password: "password123"}
+	laptop, err := svc.Login(ctx, "tnt_test", login, "203.0.113.1", "laptop")
+	if err != nil {
+		t.Fatalf("Login laptop: %v", err)
+	}
+	phone, err := svc.Login(ctx, "tnt_test", login, "203.0.113.2", "phone")
+	if err != nil {
+		t.Fatalf("Login phone: %v", err)
+	}
+
+	// 手机登录不影响笔记本的会话
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", laptop.RefreshToken, "203.0.113.1", "laptop"); err != nil {
+		t.Fatalf("laptop refresh after phone login: %v", err)
+	}
+
+	phoneSID := accessTokenSessionID(t, svc, phone.Token)
+	sessions, err := svc.ListSessions(ctx, "tnt_test", registered.ID, phoneSID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].UserAgent != "laptop" || sessions[0].Current || !sessions[1].Current {
+		t.Fatalf("unexpected sessions: %+v", sessions)
+	}
+
+	// 在手机上退出笔记本的会话，笔记本的refresh token随之失效
+	if err := svc.RevokeSession(ctx, "tnt_test", registered.ID, sessions[0].ID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if err := svc.RevokeSession(ctx, "tnt_test", registered.ID, sessions[0].ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound for revoked session, got %v", err)
+	}
+
+	if err := svc.RevokeAllSessions(ctx, "tnt_test", registered.ID); err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, "tnt_test", phone.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected refresh to fail after logging out everywhere, got %v", err)
+	}
+	if sessions, _ := svc.ListSessions(ctx, "tnt_test", registered.ID, ""); len(sessions) != 0 {
+		t.Fatalf("expected no active sessions, got %d", len(sessions))
+	}
+}
+
+func TestSessionLimitPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("evict_oldest", func(t *testing.T) {
+		svc, _, _ := newTestService(t, `{"max_sessions": 2}`)
+		if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "hank@example.com", Password: "password123"}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		login := LoginRequest{Email: "hank@example.com", Password: "password123"}
+		first, _ := svc.Login(ctx, "tnt_test", login, "", "first")
+		if _, err := svc.Login(ctx, "tnt_test", login, "", "second"); err != nil {
+			t.Fatalf("second login: %v", err)
+		}
+		if _, err := svc.Login(ctx, "tnt_test", login, "", "third"); err != nil {
+			t.Fatalf("third login: %v", err)
+		}
+		if _, err := svc.RefreshTokens(ctx, "tnt_test", first.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("expected oldest session to be evicted, got %v", err)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		svc, _, _ := newTestService(t, `{"max_sessions": 1, "session_limit_policy": "reject"}`)
+		if _, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "ivy@example.com", Password: "password123"}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		login := LoginRequest{Email: "ivy@example.com", Password: "password123"}
+		first, err := svc.Login(ctx, "tnt_test", login, "", "first")
+		if err != nil {
+			t.Fatalf("first login: %v", err)
+		}
+		if _, err := svc.Login(ctx, "tnt_test", login, "", "second"); !errors.Is(err, ErrSessionLimitReached) {
+			t.Fatalf("expected ErrSessionLimitReached, got %v", err)
+		}
+		if _, err := svc.RefreshTokens(ctx, "tnt_test", first.RefreshToken, "", ""); err != nil {
+			t.Fatalf("existing session should keep working: %v", err)
+		}
+	})
+}
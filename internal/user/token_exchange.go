@@ -0,0 +1,68 @@
+package user
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"yuyu-test/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ExchangeToken 以用户访问令牌为主体交换出发给目标服务的令牌（RFC 8693）。
+// 新令牌保留主体的用户、会话和组织，权限收窄为主体权限与 req.Scopes 的交集，不携带角色，
+// aud 为目标服务，act 为发起交换的服务并链接主体令牌原有的 act。
+// 主体不是有效的用户访问令牌（已过期、已吊销、用户不可用）时返回 nil；
+// 主体令牌的受众不包括发起交换的服务时返回 auth.ErrSubjectAudienceMismatch
+func (s *Service) ExchangeToken(ctx context.Context, req auth.ExchangeRequest) (*auth.ExchangedToken, error) {
+	subject := &auth.Claims{}
+	if err := s.signer.Parse(req.SubjectToken, subject); err != nil || subject.UserID == "" {
+		return nil, nil
+	}
+	if s.revoker.IsRevoked(subject) {
+		return nil, nil
+	}
+	if err := auth.CheckSubjectAudience(subject.Audience, req.ClientID); err != nil {
+		return nil, err
+	}
+	user, err := s.currentUser(ctx, subject.TenantID, subject.UserID)
+	if err != nil || checkUserStatus(user) != nil {
+		return nil, nil
+	}
+	permissions := subject.Permissions
+	if subject.AuthzOverflow {
+		authz, err := s.userAuthorization(ctx, user, subject.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		permissions = authz.Permissions
+	}
+
+	scopes := auth.IntersectScopes(permissions, req.Scopes)
+	expiresAt := auth.ExchangeExpiry(subject.ExpiresAt, req.TTL)
+	claims := &auth.Claims{
+		UserID:         user.ID,
+		TenantID:       user.TenantID,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified,
+		AMR:            subject.AMR,
+		SessionID:      subject.SessionID,
+		Permissions:    scopes,
+		OrganizationID: subject.OrganizationID,
+		Act:            &auth.Actor{Subject: req.ClientID, ClientID: req.ClientID, Act: subject.Act},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        auth.NewTokenID(),
+			Audience:  jwt.ClaimStrings{req.Audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token, err := s.signClaims(ctx, user, claims)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("User token exchanged", "tenant_id", user.TenantID, "user_id", user.ID, "client_id", req.ClientID, "audience", req.Audience)
+	return &auth.ExchangedToken{Token: token, ExpiresAt: expiresAt, Scopes: scopes}, nil
+}
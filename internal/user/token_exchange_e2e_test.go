@@ -0,0 +1,36 @@
+package user_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/auth"
+	"yuyu-test/internal/user"
+)
+
+// 交换得到的令牌只发给目标服务，不能作为用户本人的访问令牌调用用户接口
+func TestExchangedTokenRejectedByUserAPI(t *testing.T) {
+	ctx := context.Background()
+	server, svc := startTestServer(t)
+
+	if _, err := svc.Register(ctx, "tnt_test", user.RegisterRequest{Email: "ivan@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", user.LoginRequest{Email: "ivan@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if status := getMe(t, server, login.Token); status != http.StatusOK {
+		t.Fatalf("expected the login token to be accepted, got %d", status)
+	}
+
+	exchanged, err := svc.ExchangeToken(ctx, auth.ExchangeRequest{SubjectToken: login.Token, ClientID: "gateway", Audience: "orders", TTL: time.Minute})
+	if err != nil || exchanged == nil {
+		t.Fatalf("ExchangeToken: %+v, %v", exchanged, err)
+	}
+	if status := getMe(t, server, exchanged.Token); status != http.StatusUnauthorized {
+		t.Fatalf("expected the exchanged token to be rejected, got %d", status)
+	}
+}
@@ -0,0 +1,76 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"yuyu-test/internal/auth"
+)
+
+func TestExchangeToken(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestService(t, `{}`)
+
+	kai, err := svc.Register(ctx, "tnt_test", RegisterRequest{Email: "kai@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	editor, err := svc.CreateRole(ctx, "tnt_test", CreateRoleRequest{Name: "editor", Permissions: []string{"orders:read", "orders:write", "posts:write"}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if err := svc.AssignRole(ctx, "tnt_test", kai.ID, AssignRoleRequest{RoleID: editor.ID}); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	login, err := svc.Login(ctx, "tnt_test", LoginRequest{Email: "kai@example.com", Password: "password123"}, "", "")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// 网关将用户令牌交换为发给订单服务的令牌，权限收窄为策略允许的范围
+	first, err := svc.ExchangeToken(ctx, auth.ExchangeRequest{
+		SubjectToken: login.Token, ClientID: "gateway", Audience: "orders", Scopes: []string{"orders:read", "billing:read"}, TTL: 5 * time.Minute,
+	})
+	if err != nil || first == nil {
+		t.Fatalf("ExchangeToken: %+v, %v", first, err)
+	}
+	claims := accessTokenClaims(t, svc, first.Token)
+	if claims.UserID != kai.ID || !slices.Equal(claims.Permissions, []string{"orders:read"}) || len(claims.Roles) != 0 ||
+		!slices.Equal(claims.Audience, []string{"orders"}) || claims.Act == nil || claims.Act.Subject != "gateway" || claims.SessionID == "" {
+		t.Fatalf("unexpected exchanged claims: %+v", claims)
+	}
+	if first.ExpiresAt.After(accessTokenClaims(t, svc, login.Token).ExpiresAt.Time) {
+		t.Fatal("exchanged token must not outlive the subject token")
+	}
+
+	// 只有令牌的受众可以继续交换，act 链记录每一跳
+	if _, err := svc.ExchangeToken(ctx, auth.ExchangeRequest{SubjectToken: first.Token, ClientID: "billing", Audience: "ledger", TTL: time.Minute}); !errors.Is(err, auth.ErrSubjectAudienceMismatch) {
+		t.Fatalf("expected ErrSubjectAudienceMismatch, got %v", err)
+	}
+	second, err := svc.ExchangeToken(ctx, auth.ExchangeRequest{SubjectToken: first.Token, ClientID: "orders", Audience: "ledger", Scopes: []string{"orders:read"}, TTL: time.Minute})
+	if err != nil || second == nil {
+		t.Fatalf("ExchangeToken: %+v, %v", second, err)
+	}
+	if act := accessTokenClaims(t, svc, second.Token).Act; act == nil || act.Subject != "orders" || act.Act == nil || act.Act.Subject != "gateway" {
+		t.Fatalf("unexpected act chain: %+v", act)
+	}
+
+	// 服务令牌或无效令牌不是用户主体
+	if issued, err := svc.ExchangeToken(ctx, auth.ExchangeRequest{SubjectToken: "not-a-token", ClientID: "gateway", Audience: "orders"}); issued != nil || err != nil {
+		t.Fatalf("expected no token for an invalid subject, got %+v, %v", issued, err)
+	}
+
+	// 退出登录后交换得到的令牌随会话失效，也不能再作为主体
+	if err := svc.Logout(ctx, "tnt_test", kai.ID, accessTokenClaims(t, svc, login.Token)); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if info, _ := svc.IntrospectToken(ctx, second.Token, ""); info != nil {
+		t.Fatalf("expected exchanged token to be revoked with its session, got %+v", info)
+	}
+	if issued, _ := svc.ExchangeToken(ctx, auth.ExchangeRequest{SubjectToken: first.Token, ClientID: "orders", Audience: "ledger", TTL: time.Minute}); issued != nil {
+		t.Fatal("expected revoked subject token to be rejected")
+	}
+}
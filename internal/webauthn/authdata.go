@@ -0,0 +1,121 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 认证器数据标志位（WebAuthn §6.1）
+const (
+	FlagUserPresent      byte = 0x01
+	FlagUserVerified     byte = 0x04
+	FlagAttestedCredData byte = 0x40
+	FlagExtensionData    byte = 0x80
+)
+
+// 客户端数据类型
+const (
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+)
+
+// CollectedClientData 浏览器生成的 clientDataJSON
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// AuthenticatorData 解析后的认证器数据
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// 以下字段仅在注册（AT标志）时存在
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// attestationObject 注册响应中的 attestationObject（CBOR）
+type attestationObject struct {
+	Format   string          `cbor:"fmt"`
+	AttStmt  cbor.RawMessage `cbor:"attStmt"`
+	AuthData []byte          `cbor:"authData"`
+}
+
+// DecodeBase64URL 解码base64url，兼容带填充和不带填充两种格式
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// EncodeBase64URL 编码为不带填充的base64url
+func EncodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseClientData 解析clientDataJSON
+func parseClientData(raw []byte) (*CollectedClientData, error) {
+	var cd CollectedClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("invalid clientDataJSON: %w", err)
+	}
+	return &cd, nil
+}
+
+// ParseAuthenticatorData 解析认证器数据：rpIdHash(32) | flags(1) | signCount(4) | [attestedCredentialData] | [extensions]
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data too short")
+	}
+	ad := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if ad.Flags&FlagAttestedCredData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data too short")
+		}
+		ad.AAGUID = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen > 1023 || len(rest) < idLen {
+			return nil, errors.New("invalid credential id length")
+		}
+		ad.CredentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		// 公钥是一个CBOR对象，其后可能紧跟扩展数据
+		var key cbor.RawMessage
+		remaining, err := cbor.UnmarshalFirst(rest, &key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		ad.PublicKey = key
+		rest = remaining
+	}
+
+	if ad.Flags&FlagExtensionData != 0 {
+		var ext cbor.RawMessage
+		remaining, err := cbor.UnmarshalFirst(rest, &ext)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension data: %w", err)
+		}
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("unexpected trailing authenticator data")
+	}
+	return ad, nil
+}
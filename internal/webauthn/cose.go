@@ -0,0 +1,136 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE算法标识（RFC 8152 / RFC 8812）
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE密钥类型与曲线
+const (
+	coseKtyOKP     = 1
+	coseKtyEC2     = 2
+	coseKtyRSA     = 3
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// SupportedAlgorithms 注册时声明支持的签名算法，按优先级排序
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// coseKeyHeader COSE_Key 的公共字段，先据此判断密钥类型
+type coseKeyHeader struct {
+	Kty int `cbor:"1,keyasint"`
+	Alg int `cbor:"3,keyasint"`
+}
+
+// coseKey EC2/OKP密钥，负数标签随密钥类型含义不同
+type coseKey struct {
+	Kty int    `cbor:"1,keyasint"`
+	Alg int    `cbor:"3,keyasint"`
+	Crv int    `cbor:"-1,keyasint,omitempty"`
+	X   []byte `cbor:"-2,keyasint,omitempty"`
+	Y   []byte `cbor:"-3,keyasint,omitempty"`
+}
+
+// rsaCOSEKey RSA密钥中 -1 为模数n、-2 为指数e
+type rsaCOSEKey struct {
+	Kty int    `cbor:"1,keyasint"`
+	Alg int    `cbor:"3,keyasint"`
+	N   []byte `cbor:"-1,keyasint"`
+	E   []byte `cbor:"-2,keyasint"`
+}
+
+// PublicKey 解析后的凭证公钥
+type PublicKey struct {
+	Algorithm int
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey 解析COSE编码的凭证公钥
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	var header coseKeyHeader
+	if err := cbor.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid COSE key: %w", err)
+	}
+
+	var key coseKey
+	if header.Kty == coseKtyEC2 || header.Kty == coseKtyOKP {
+		if err := cbor.Unmarshal(data, &key); err != nil {
+			return nil, fmt.Errorf("invalid COSE key: %w", err)
+		}
+	}
+
+	switch header.Kty {
+	case coseKtyEC2:
+		if key.Alg != AlgES256 || key.Crv != coseCrvP256 || len(key.X) != 32 || len(key.Y) != 32 {
+			return nil, errors.New("unsupported EC2 key")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(key.X),
+			Y:     new(big.Int).SetBytes(key.Y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("EC2 point is not on curve")
+		}
+		return &PublicKey{Algorithm: AlgES256, Key: pub}, nil
+	case coseKtyOKP:
+		if key.Alg != AlgEdDSA || key.Crv != coseCrvEd25519 || len(key.X) != ed25519.PublicKeySize {
+			return nil, errors.New("unsupported OKP key")
+		}
+		return &PublicKey{Algorithm: AlgEdDSA, Key: ed25519.PublicKey(key.X)}, nil
+	case coseKtyRSA:
+		var rk rsaCOSEKey
+		if err := cbor.Unmarshal(data, &rk); err != nil {
+			return nil, fmt.Errorf("invalid RSA COSE key: %w", err)
+		}
+		if rk.Alg != AlgRS256 || len(rk.N) < 256 || len(rk.E) == 0 || len(rk.E) > 4 {
+			return nil, errors.New("unsupported RSA key")
+		}
+		return &PublicKey{Algorithm: AlgRS256, Key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(rk.N),
+			E: int(new(big.Int).SetBytes(rk.E).Int64()),
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported COSE key type %d", header.Kty)
+	}
+}
+
+// Verify 校验签名，ES256为ASN.1 DER编码
+func (k *PublicKey) Verify(message, signature []byte) error {
+	switch pub := k.Key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(pub, digest[:], signature) {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, message, signature) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrInvalidSignature
+	}
+	return nil
+}
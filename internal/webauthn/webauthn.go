@@ -0,0 +1,300 @@
+// Package webauthn 实现WebAuthn（Passkey）注册与断言校验中服务端需要的部分。
+// 只请求 "none" 证明（attestation），不校验认证器型号，只信任凭证公钥本身
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ChallengeTimeout 仪式超时时间（毫秒），与服务端挑战有效期一致
+const ChallengeTimeout = 5 * 60 * 1000
+
+var (
+	// ErrInvalidSignature 断言签名校验失败
+	ErrInvalidSignature = errors.New("invalid webauthn signature")
+	// ErrSignCountRegression 签名计数器未递增，可能是凭证被克隆
+	ErrSignCountRegression = errors.New("webauthn sign count did not increase")
+)
+
+// RelyingParty 依赖方配置，每个租户一份
+type RelyingParty struct {
+	// ID 依赖方ID，通常为站点的可注册域名，如 example.com
+	ID string
+	// Name 展示给用户的名称
+	Name string
+	// Origins 允许的来源，如 https://login.example.com
+	Origins []string
+}
+
+// CredentialDescriptor allowCredentials/excludeCredentials 中的凭证描述
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// CreationOptions PublicKeyCredentialCreationOptions 的JSON形式，二进制字段为base64url
+type CreationOptions struct {
+	RP struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	Challenge        string `json:"challenge"`
+	PubKeyCredParams []struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions PublicKeyCredentialRequestOptions 的JSON形式
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int                    `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse 浏览器 navigator.credentials.create() 结果的JSON形式
+type RegistrationResponse struct {
+	ID       string `json:"id" binding:"required"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject" binding:"required"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse 浏览器 navigator.credentials.get() 结果的JSON形式
+type AssertionResponse struct {
+	ID       string `json:"id" binding:"required"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential 注册成功后需要保存的凭证信息
+type Credential struct {
+	ID         []byte
+	PublicKey  []byte
+	SignCount  uint32
+	AAGUID     []byte
+	Transports []string
+}
+
+// NewChallenge 生成32字节随机挑战，返回base64url编码
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return EncodeBase64URL(b), nil
+}
+
+// NewCreationOptions 构造注册选项，要求用户验证（UV）以便Passkey登录可视为多因素
+func (rp *RelyingParty) NewCreationOptions(challenge string, userHandle []byte, userName string, exclude []CredentialDescriptor) *CreationOptions {
+	opts := &CreationOptions{
+		Challenge:          challenge,
+		Timeout:            ChallengeTimeout,
+		ExcludeCredentials: exclude,
+		Attestation:        "none",
+	}
+	opts.RP.ID = rp.ID
+	opts.RP.Name = rp.Name
+	opts.User.ID = EncodeBase64URL(userHandle)
+	opts.User.Name = userName
+	opts.User.DisplayName = userName
+	for _, alg := range SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, struct {
+			Type string `json:"type"`
+			Alg  int    `json:"alg"`
+		}{Type: "public-key", Alg: alg})
+	}
+	opts.AuthenticatorSelection.ResidentKey = "preferred"
+	opts.AuthenticatorSelection.UserVerification = "required"
+	return opts
+}
+
+// NewRequestOptions 构造登录（断言）选项
+func (rp *RelyingParty) NewRequestOptions(challenge string, allow []CredentialDescriptor) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          ChallengeTimeout,
+		AllowCredentials: allow,
+		UserVerification: "required",
+	}
+}
+
+// ChallengeOf 取出响应中的挑战，用于查找服务端保存的挑战
+func ChallengeOf(clientDataJSON string) (string, error) {
+	raw, err := DecodeBase64URL(clientDataJSON)
+	if err != nil {
+		return "", fmt.Errorf("invalid clientDataJSON encoding: %w", err)
+	}
+	cd, err := parseClientData(raw)
+	if err != nil {
+		return "", err
+	}
+	return cd.Challenge, nil
+}
+
+// verifyClientData 校验类型、挑战和来源，返回clientDataJSON原文
+func (rp *RelyingParty) verifyClientData(encoded, wantType, challenge string) ([]byte, error) {
+	raw, err := DecodeBase64URL(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clientDataJSON encoding: %w", err)
+	}
+	cd, err := parseClientData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if cd.Type != wantType {
+		return nil, fmt.Errorf("unexpected client data type %q", cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return nil, errors.New("challenge mismatch")
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return nil, fmt.Errorf("origin %q is not allowed", cd.Origin)
+	}
+	return raw, nil
+}
+
+// verifyAuthenticatorData 校验RP ID哈希以及用户在场、用户验证标志
+func (rp *RelyingParty) verifyAuthenticatorData(ad *AuthenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.RPIDHash, rpIDHash[:]) {
+		return errors.New("rp id hash mismatch")
+	}
+	if ad.Flags&FlagUserPresent == 0 {
+		return errors.New("user not present")
+	}
+	if ad.Flags&FlagUserVerified == 0 {
+		return errors.New("user not verified")
+	}
+	return nil
+}
+
+// VerifyRegistration 校验注册仪式，返回需要保存的凭证
+func (rp *RelyingParty) VerifyRegistration(challenge string, resp *RegistrationResponse) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, fmt.Errorf("unexpected credential type %q", resp.Type)
+	}
+	if _, err := rp.verifyClientData(resp.Response.ClientDataJSON, clientDataTypeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	rawAtt, err := DecodeBase64URL(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestationObject encoding: %w", err)
+	}
+	var att attestationObject
+	if err := cbor.Unmarshal(rawAtt, &att); err != nil {
+		return nil, fmt.Errorf("invalid attestationObject: %w", err)
+	}
+
+	ad, err := ParseAuthenticatorData(att.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(ad); err != nil {
+		return nil, err
+	}
+	if ad.Flags&FlagAttestedCredData == 0 {
+		return nil, errors.New("missing attested credential data")
+	}
+
+	// 校验公钥可解析且算法在支持列表内
+	key, err := ParsePublicKey(ad.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(SupportedAlgorithms, key.Algorithm) {
+		return nil, fmt.Errorf("unsupported algorithm %d", key.Algorithm)
+	}
+
+	// id 必须与认证器数据中的凭证ID一致
+	id, err := DecodeBase64URL(resp.ID)
+	if err != nil || !bytes.Equal(id, ad.CredentialID) {
+		return nil, errors.New("credential id mismatch")
+	}
+
+	return &Credential{
+		ID:         ad.CredentialID,
+		PublicKey:  ad.PublicKey,
+		SignCount:  ad.SignCount,
+		AAGUID:     ad.AAGUID,
+		Transports: resp.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion 校验登录断言，返回新的签名计数
+func (rp *RelyingParty) VerifyAssertion(challenge string, resp *AssertionResponse, publicKey []byte, storedSignCount uint32) (uint32, error) {
+	if resp.Type != "public-key" {
+		return 0, fmt.Errorf("unexpected credential type %q", resp.Type)
+	}
+	clientData, err := rp.verifyClientData(resp.Response.ClientDataJSON, clientDataTypeGet, challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := DecodeBase64URL(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("invalid authenticatorData encoding: %w", err)
+	}
+	ad, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(ad); err != nil {
+		return 0, err
+	}
+
+	signature, err := DecodeBase64URL(resp.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if err := key.Verify(signed, signature); err != nil {
+		return 0, err
+	}
+
+	// 计数器为0表示认证器不支持计数（多数同步Passkey），否则必须递增
+	if (ad.SignCount != 0 || storedSignCount != 0) && ad.SignCount <= storedSignCount {
+		return 0, ErrSignCountRegression
+	}
+	return ad.SignCount, nil
+}
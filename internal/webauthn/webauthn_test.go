@@ -0,0 +1,90 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"yuyu-test/internal/webauthn"
+	"yuyu-test/internal/webauthn/webauthntest"
+)
+
+var rp = &webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://login.example.com"}}
+
+func register(t *testing.T) (*webauthntest.Authenticator, *webauthn.Credential) {
+	t.Helper()
+	a, err := webauthntest.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	challenge, _ := webauthn.NewChallenge()
+	cred, err := rp.VerifyRegistration(challenge, a.Register(rp.ID, rp.Origins[0], challenge))
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return a, cred
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	a, cred := register(t)
+
+	challenge, _ := webauthn.NewChallenge()
+	count, err := rp.VerifyAssertion(challenge, a.Login(rp.ID, rp.Origins[0], challenge, []byte("usr_1")), cred.PublicKey, cred.SignCount)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected sign count 1, got %d", count)
+	}
+
+	// 计数器回退视为克隆凭证
+	a.SignCount = 0
+	challenge, _ = webauthn.NewChallenge()
+	_, err = rp.VerifyAssertion(challenge, a.Login(rp.ID, rp.Origins[0], challenge, nil), cred.PublicKey, count)
+	if !errors.Is(err, webauthn.ErrSignCountRegression) {
+		t.Fatalf("expected ErrSignCountRegression, got %v", err)
+	}
+}
+
+func TestAssertionRejectsTampering(t *testing.T) {
+	a, cred := register(t)
+	challenge, _ := webauthn.NewChallenge()
+
+	cases := map[string]func() *webauthn.AssertionResponse{
+		"wrong challenge": func() *webauthn.AssertionResponse {
+			other, _ := webauthn.NewChallenge()
+			return a.Login(rp.ID, rp.Origins[0], other, nil)
+		},
+		"wrong origin": func() *webauthn.AssertionResponse {
+			return a.Login(rp.ID, "https://evil.example.net", challenge, nil)
+		},
+		"wrong rp id": func() *webauthn.AssertionResponse {
+			return a.Login("evil.example.net", rp.Origins[0], challenge, nil)
+		},
+		"bad signature": func() *webauthn.AssertionResponse {
+			resp := a.Login(rp.ID, rp.Origins[0], challenge, nil)
+			other := a.Login(rp.ID, rp.Origins[0], challenge, nil)
+			resp.Response.Signature = other.Response.Signature
+			return resp
+		},
+		"user not verified": func() *webauthn.AssertionResponse {
+			a.Flags = webauthn.FlagUserPresent
+			defer func() { a.Flags = webauthn.FlagUserPresent | webauthn.FlagUserVerified }()
+			return a.Login(rp.ID, rp.Origins[0], challenge, nil)
+		},
+	}
+	for name, build := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := rp.VerifyAssertion(challenge, build(), cred.PublicKey, 0); err == nil {
+				t.Fatal("expected assertion to be rejected")
+			}
+		})
+	}
+}
+
+func TestRegistrationRejectsWrongOrigin(t *testing.T) {
+	a, _ := webauthntest.New()
+	challenge, _ := webauthn.NewChallenge()
+	if _, err := rp.VerifyRegistration(challenge, a.Register(rp.ID, "https://evil.example.net", challenge)); err == nil {
+		t.Fatal("expected registration from foreign origin to be rejected")
+	}
+}
@@ -0,0 +1,123 @@
+// Package webauthntest 提供软件实现的WebAuthn认证器，供测试模拟浏览器和硬件密钥
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+
+	"yuyu-test/internal/webauthn"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Authenticator 持有一个ES256凭证的软件认证器
+type Authenticator struct {
+	CredentialID []byte
+	Key          *ecdsa.PrivateKey
+	SignCount    uint32
+	// Flags 响应中使用的认证器标志，默认用户在场且已验证
+	Flags byte
+}
+
+// New 生成新的凭证密钥对
+func New() (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		CredentialID: id,
+		Key:          key,
+		Flags:        webauthn.FlagUserPresent | webauthn.FlagUserVerified,
+	}, nil
+}
+
+// coseKey 返回凭证公钥的COSE编码
+func (a *Authenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.Key.PublicKey.X.FillBytes(x)
+	a.Key.PublicKey.Y.FillBytes(y)
+	key, _ := cbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: x,
+		-3: y,
+	})
+	return key
+}
+
+func (a *Authenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	if attested {
+		flags |= webauthn.FlagAttestedCredData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID 全零
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.CredentialID)))
+		data = append(data, a.CredentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func clientDataJSON(typ, challenge, origin string) []byte {
+	raw, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	return raw
+}
+
+// Register 模拟 navigator.credentials.create()，使用 "none" 证明
+func (a *Authenticator) Register(rpID, origin, challenge string) *webauthn.RegistrationResponse {
+	att, _ := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(rpID, a.Flags, true),
+	})
+
+	resp := &webauthn.RegistrationResponse{
+		ID:    webauthn.EncodeBase64URL(a.CredentialID),
+		RawID: webauthn.EncodeBase64URL(a.CredentialID),
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = webauthn.EncodeBase64URL(clientDataJSON("webauthn.create", challenge, origin))
+	resp.Response.AttestationObject = webauthn.EncodeBase64URL(att)
+	resp.Response.Transports = []string{"internal"}
+	return resp
+}
+
+// Login 模拟 navigator.credentials.get()，每次签名计数加一
+func (a *Authenticator) Login(rpID, origin, challenge string, userHandle []byte) *webauthn.AssertionResponse {
+	a.SignCount++
+	authData := a.authData(rpID, a.Flags, false)
+	clientData := clientDataJSON("webauthn.get", challenge, origin)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, _ := ecdsa.SignASN1(rand.Reader, a.Key, digest[:])
+
+	resp := &webauthn.AssertionResponse{
+		ID:    webauthn.EncodeBase64URL(a.CredentialID),
+		RawID: webauthn.EncodeBase64URL(a.CredentialID),
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = webauthn.EncodeBase64URL(clientData)
+	resp.Response.AuthenticatorData = webauthn.EncodeBase64URL(authData)
+	resp.Response.Signature = webauthn.EncodeBase64URL(sig)
+	resp.Response.UserHandle = webauthn.EncodeBase64URL(userHandle)
+	return resp
+}
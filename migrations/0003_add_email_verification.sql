@@ -0,0 +1,20 @@
+-- 用户邮箱验证状态
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- 租户级策略配置（JSON）
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
+
+-- 用户一次性操作令牌表（邮箱验证等），仅存储令牌哈希
+CREATE TABLE IF NOT EXISTS user_action_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    purpose VARCHAR(64) NOT NULL,
+    token_hash VARCHAR(128) UNIQUE NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_action_tokens_user_purpose ON user_action_tokens(user_id, purpose);
+CREATE INDEX IF NOT EXISTS idx_user_action_tokens_expires_at ON user_action_tokens(expires_at);
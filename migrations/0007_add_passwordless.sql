@@ -0,0 +1,3 @@
+-- 一次性令牌的校验失败次数，用于限制邮箱验证码等短码的猜测
+ALTER TABLE user_action_tokens ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_user_action_tokens_user_purpose_created ON user_action_tokens(user_id, purpose, created_at);
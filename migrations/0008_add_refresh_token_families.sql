@@ -0,0 +1,33 @@
+-- refresh token 轮换：按哈希直接查找，同一次登录签发的令牌属于同一个家族（family），
+-- 每次刷新生成子令牌并标记父令牌已轮换；已轮换的令牌被再次使用时吊销整个家族
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS family_id VARCHAR(255);
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES user_refresh_tokens(id) ON DELETE SET NULL;
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS amr TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMP;
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP;
+
+-- 旧令牌补齐租户和家族，每个旧令牌自成一个家族
+UPDATE user_refresh_tokens t SET tenant_id = u.tenant_id FROM users u WHERE t.user_id = u.id AND t.tenant_id IS NULL;
+DELETE FROM user_refresh_tokens WHERE tenant_id IS NULL;
+UPDATE user_refresh_tokens SET family_id = 'rtf_legacy_' || id WHERE family_id IS NULL;
+ALTER TABLE user_refresh_tokens ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE user_refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_user_refresh_tokens_token_hash;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_refresh_tokens_token_hash_unique ON user_refresh_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_user_refresh_tokens_family_id ON user_refresh_tokens(family_id);
+
+-- 安全事件（令牌重放等），供审计和告警
+CREATE TABLE IF NOT EXISTS security_events (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id VARCHAR(255),
+    event_type VARCHAR(64) NOT NULL,
+    client_ip VARCHAR,
+    user_agent VARCHAR,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_security_events_tenant_created ON security_events(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id);
@@ -0,0 +1,21 @@
+-- 用户会话：每次登录（每台设备）一条记录，会话ID即该次登录的refresh token家族ID
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    client_ip VARCHAR,
+    user_agent VARCHAR,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id, created_at);
+
+-- 为仍然有效的refresh token家族补建会话
+INSERT INTO user_sessions (id, user_id, tenant_id, client_ip, user_agent, created_at, last_used_at, expires_at)
+SELECT DISTINCT ON (family_id) family_id, user_id, tenant_id, client_ip, user_agent, created_at, created_at, expires_at
+FROM user_refresh_tokens
+WHERE rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY family_id, created_at DESC
+ON CONFLICT (id) DO NOTHING;
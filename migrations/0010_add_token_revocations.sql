@@ -0,0 +1,11 @@
+-- 访问令牌吊销记录：按 jti 吊销单个令牌，按 session 吊销会话签发的令牌，
+-- 按 user 吊销该用户在 revoked_at 之前签发的所有令牌。
+-- 访问令牌过期后记录即无意义，expires_at 之后定期清理
+CREATE TABLE IF NOT EXISTS token_revocations (
+    subject_type VARCHAR(16) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    revoked_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (subject_type, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_token_revocations_expires_at ON token_revocations(expires_at);
@@ -0,0 +1,14 @@
+-- 登录和客户端凭证的失败计数，按账号（租户+邮箱）、IP、client_id 分别计数。
+-- locked_until 之前的尝试直接拒绝：失败次数较少时为渐进延迟，达到阈值后为锁定
+CREATE TABLE IF NOT EXISTS auth_failures (
+    scope VARCHAR(16) NOT NULL,
+    subject VARCHAR(512) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (scope, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_auth_failures_last_failure_at ON auth_failures(last_failure_at);
+
+-- 内部客户端不属于任何租户，其锁定事件不带租户
+ALTER TABLE security_events ALTER COLUMN tenant_id DROP NOT NULL;
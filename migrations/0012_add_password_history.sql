@@ -0,0 +1,8 @@
+-- 用户历史密码哈希，用于禁止重复使用最近N次的密码。修改密码时写入被替换的旧密码
+CREATE TABLE IF NOT EXISTS user_password_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hashed_password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_password_history_user_id ON user_password_history(user_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- 用户状态：active 正常、disabled 由管理员停用、locked 由管理员锁定。非 active 的用户不能登录或刷新令牌
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'disabled', 'locked'));
+
+-- 软删除：deleted_at 非空的用户视为不存在，邮箱可被重新注册
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_tenant_id_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_email_active ON users(tenant_id, email) WHERE deleted_at IS NULL;
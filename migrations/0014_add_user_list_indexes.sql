@@ -0,0 +1,13 @@
+-- 用户列表按创建时间、邮箱游标分页和过滤所需的索引，均只包含未删除的用户。
+-- 按邮箱排序使用 0013 的唯一索引 idx_users_tenant_email_active
+CREATE INDEX IF NOT EXISTS idx_users_tenant_created_at ON users(tenant_id, created_at, id) WHERE deleted_at IS NULL;
+-- 邮箱前缀匹配（LIKE 'prefix%'）不受数据库排序规则影响
+CREATE INDEX IF NOT EXISTS idx_users_tenant_email_pattern ON users(tenant_id, email text_pattern_ops) WHERE deleted_at IS NULL;
+-- profile 属性过滤（profile @> '{"key": "value"}'）
+CREATE INDEX IF NOT EXISTS idx_users_profile ON users USING GIN (profile jsonb_path_ops) WHERE deleted_at IS NULL;
+
+-- 全文搜索：邮箱（含 @ 前的用户名）和 profile 中的所有字符串值。
+-- 查询必须使用完全相同的表达式才能命中索引
+CREATE INDEX IF NOT EXISTS idx_users_search ON users USING GIN (
+    (to_tsvector('simple', email || ' ' || replace(email, '@', ' ')) || jsonb_to_tsvector('simple', COALESCE(profile, '{}'::jsonb), '["string"]'))
+) WHERE deleted_at IS NULL;
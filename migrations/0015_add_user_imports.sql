@@ -0,0 +1,29 @@
+-- 批量导入用户的异步任务。上传内容暂存在 payload 中，任务结束后清空。
+-- processed_rows 记录已处理的数据行数，实例中断后其他实例在心跳超时后从该位置继续
+CREATE TABLE IF NOT EXISTS user_import_jobs (
+    id VARCHAR(255) PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    format VARCHAR(16) NOT NULL CHECK (format IN ('csv', 'ndjson')),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    payload BYTEA,
+    processed_rows INT NOT NULL DEFAULT 0,
+    created_count INT NOT NULL DEFAULT 0,
+    skipped_count INT NOT NULL DEFAULT 0,
+    failed_count INT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    started_at TIMESTAMPTZ,
+    heartbeat_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_import_jobs_status ON user_import_jobs(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_user_import_jobs_tenant_id ON user_import_jobs(tenant_id, created_at DESC);
+
+-- 导入失败的行，row_number 从1开始，不含CSV表头
+CREATE TABLE IF NOT EXISTS user_import_errors (
+    job_id VARCHAR(255) NOT NULL REFERENCES user_import_jobs(id) ON DELETE CASCADE,
+    row_number INT NOT NULL,
+    email VARCHAR(255),
+    error TEXT NOT NULL,
+    PRIMARY KEY (job_id, row_number)
+);
@@ -0,0 +1,12 @@
+-- 用户数据删除请求。宽限期内 erased_at 为空，可以撤销；到期后删除用户及其全部数据，
+-- 本行作为审计记录保留，不含任何个人信息，因此 user_id 不引用 users
+CREATE TABLE IF NOT EXISTS user_erasures (
+    user_id VARCHAR(255) PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    requested_by VARCHAR(16) NOT NULL CHECK (requested_by IN ('user', 'admin')),
+    requested_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    erased_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_erasures_pending ON user_erasures(scheduled_at) WHERE erased_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_user_erasures_tenant_id ON user_erasures(tenant_id);
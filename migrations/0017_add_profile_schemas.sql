@@ -0,0 +1,8 @@
+-- 租户用户资料 JSON Schema。每次发布新增一个版本，最新版本生效，旧版本保留用于审计和回滚
+CREATE TABLE IF NOT EXISTS tenant_profile_schemas (
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    schema JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant_id, version)
+);
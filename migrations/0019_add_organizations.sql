@@ -0,0 +1,44 @@
+-- 租户内的组织（B2B 模式下租户的客户公司）。用户可以属于多个组织，并在每个组织内拥有不同的角色
+CREATE TABLE IF NOT EXISTS organizations (
+    id VARCHAR(255) PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_organizations_tenant_id ON organizations(tenant_id, name);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id VARCHAR(255) NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (organization_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+
+-- 成员在组织内的角色，角色定义与租户级角色共用
+CREATE TABLE IF NOT EXISTS organization_member_roles (
+    organization_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    role_id VARCHAR(255) NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (organization_id, user_id, role_id),
+    FOREIGN KEY (organization_id, user_id) REFERENCES organization_members(organization_id, user_id) ON DELETE CASCADE
+);
+
+-- 邀请按邮箱发出，受邀用户登录后凭邮件中的令牌加入组织。只保存令牌哈希
+CREATE TABLE IF NOT EXISTS invitations (
+    id VARCHAR(255) PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    organization_id VARCHAR(255) NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role_ids TEXT[] NOT NULL DEFAULT '{}',
+    token_hash VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_organization_id ON invitations(organization_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_invitations_tenant_email ON invitations(tenant_id, email);
+
+-- 会话当前选择的组织，刷新令牌时沿用
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS organization_id VARCHAR(255) REFERENCES organizations(id) ON DELETE SET NULL;
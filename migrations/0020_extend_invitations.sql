@@ -0,0 +1,7 @@
+-- 邀请可以只授予租户级角色而不加入组织；记录邀请人、最近发送时间和接受邀请的用户。
+-- 邀请状态由 accepted_at、revoked_at、expires_at 推导
+ALTER TABLE invitations ALTER COLUMN organization_id DROP NOT NULL;
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS inviter_user_id VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS last_sent_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL;
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS accepted_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_invitations_tenant_id ON invitations(tenant_id, created_at);
@@ -0,0 +1,6 @@
+-- 租户对用户访问令牌声明的自定义映射，每个租户一份，不存在时使用默认声明
+CREATE TABLE IF NOT EXISTS tenant_claims_mappings (
+    tenant_id VARCHAR(255) PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    mapping JSONB NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
@@ -0,0 +1,22 @@
+-- 内部服务代用户登录（模拟）的权限
+INSERT INTO scopes (scope_name, description) VALUES
+('user:impersonate', '以终端用户身份签发模拟令牌')
+ON CONFLICT (scope_name) DO NOTHING;
+
+-- 模拟审计记录，每签发一个模拟令牌记录一条。用户被删除后保留记录
+CREATE TABLE IF NOT EXISTS user_impersonations (
+    id VARCHAR(255) PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    -- 发起模拟的内部服务 client_id 和操作人（如客服人员），未提供操作人时与 client_id 相同
+    client_id VARCHAR(255) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    token_id VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    client_ip VARCHAR,
+    user_agent VARCHAR,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_impersonations_tenant_created ON user_impersonations(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_user_impersonations_user_id ON user_impersonations(user_id);
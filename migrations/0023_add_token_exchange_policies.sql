@@ -0,0 +1,12 @@
+-- 令牌交换（RFC 8693）策略：内部服务 client_id 可以为哪些受众（目标服务）交换令牌，
+-- 接受哪类主体令牌，以及交换后的令牌最多携带哪些权限。没有策略的组合不允许交换
+CREATE TABLE IF NOT EXISTS token_exchange_policies (
+    client_id VARCHAR(255) NOT NULL REFERENCES internal_clients(client_id) ON DELETE CASCADE,
+    audience VARCHAR(255) NOT NULL,
+    -- 允许的主体令牌类型：user（终端用户访问令牌）、service（服务令牌）
+    subject_types TEXT[] NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (client_id, audience)
+);
@@ -0,0 +1,48 @@
+-- OpenID Connect 客户端（依赖方）。client_secret_hash 为空的是公开客户端（如单页应用），只能凭 PKCE 换取令牌
+CREATE TABLE IF NOT EXISTS oidc_clients (
+    id VARCHAR(255) PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    client_secret_hash VARCHAR(255),
+    -- 允许的回调地址，授权请求中的 redirect_uri 必须与其中之一完全一致
+    redirect_uris TEXT[] NOT NULL,
+    -- 第一方应用可以跳过授权同意页
+    skip_consent BOOLEAN DEFAULT FALSE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_oidc_clients_tenant_id ON oidc_clients(tenant_id);
+
+-- 授权码，只保存哈希。session_id 为托管登录页的会话，换取的令牌属于该会话
+CREATE TABLE IF NOT EXISTS oidc_authorization_codes (
+    code_hash VARCHAR(255) PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    client_id VARCHAR(255) NOT NULL REFERENCES oidc_clients(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    nonce TEXT,
+    code_challenge VARCHAR(128) NOT NULL,
+    amr TEXT[] NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_oidc_authorization_codes_expires_at ON oidc_authorization_codes(expires_at);
+
+-- 用户对客户端的授权同意，之后请求的 scope 不超出已同意范围时不再询问
+CREATE TABLE IF NOT EXISTS oidc_consents (
+    user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(255) NOT NULL REFERENCES oidc_clients(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- 通过授权码签发的 refresh token 绑定客户端和授权范围，只能由该客户端在 /oauth/token 使用。
+-- 第一方登录签发的令牌 client_id 为空
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS client_id VARCHAR(255) REFERENCES oidc_clients(id) ON DELETE CASCADE;
+ALTER TABLE user_refresh_tokens ADD COLUMN IF NOT EXISTS scopes TEXT[] DEFAULT '{}' NOT NULL;
@@ -0,0 +1,3 @@
+-- 申请删除时是否由删除请求停用了用户。撤销请求时只恢复由它停用的用户，
+-- 申请前已被管理员停用的用户保持停用
+ALTER TABLE user_erasures ADD COLUMN IF NOT EXISTS disabled_user BOOLEAN DEFAULT FALSE NOT NULL;